@@ -0,0 +1,152 @@
+// Package api serves the optional HTTP API.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/events"
+)
+
+const (
+	shutdownTimeout   = 5 * time.Second
+	heartbeatInterval = 15 * time.Second
+)
+
+type Server struct {
+	logger zerolog.Logger
+	conf   config.API
+	bus    *events.Bus
+}
+
+func New(logger zerolog.Logger, conf config.API, bus *events.Bus) *Server {
+	return &Server{
+		logger: logger,
+		conf:   conf,
+		bus:    bus,
+	}
+}
+
+// Handler returns the HTTP handler of the API endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", s.authorized(s.handleEvents))
+
+	return mux
+}
+
+// Run serves the API on the configured listen address until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{ //nolint:exhaustruct
+		Addr:              s.conf.ListenAddr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("listen and serve: %v", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); nil != err {
+		return fmt.Errorf("shutdown server: %v", err)
+	}
+
+	if err := <-errs; nil != err && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("listen and serve: %v", err)
+	}
+
+	return nil
+}
+
+// authorized rejects requests without the configured token, if any. As browser EventSource clients cannot
+// set headers, the token can be passed either as a bearer token, or using the token query parameter.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.conf.Token == "" {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("token")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.conf.Token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleEvents streams job lifecycle and upload progress events as Server-Sent Events.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	ch, unsubscribe := s.bus.Subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); nil != err {
+		s.logger.Error().Err(err).Msg("Failed to flush event stream headers")
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); nil != err {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(e)
+			if nil != err {
+				s.logger.Error().Err(err).Msg("Failed to encode event")
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data); nil != err {
+				return
+			}
+		}
+
+		if err := rc.Flush(); nil != err {
+			return
+		}
+	}
+}
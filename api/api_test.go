@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/api"
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/events"
+)
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	bus := events.NewBus()
+	conf := config.API{Enabled: true, ListenAddr: "", Token: "secret"}
+	srv := httptest.NewServer(api.New(zerolog.Nop(), conf, bus).Handler())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/events") //nolint:noctx
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	bus.Publish(events.Event{Kind: events.KindUploadProgress, Percent: 42}) //nolint:exhaustruct
+
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: upload_progress\n", line)
+
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Contains(t, line, `"kind":"upload_progress"`)
+	assert.Contains(t, line, `"percent":42`)
+}
@@ -0,0 +1,412 @@
+package audit
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+	"go.etcd.io/bbolt"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+var jobsBucketName = []byte("jobs")
+
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed    Outcome = "failed"
+	OutcomeCanceled  Outcome = "canceled"
+	OutcomeTimedOut  Outcome = "timed_out"
+	OutcomeShutdown  Outcome = "shutdown"
+	OutcomeRejected  Outcome = "rejected"
+)
+
+func (o Outcome) Emoji() string {
+	switch o {
+	case OutcomeSucceeded:
+		return "✅"
+	case OutcomeFailed:
+		return "❌"
+	case OutcomeCanceled:
+		return "⏹️"
+	case OutcomeTimedOut:
+		return "⌛️"
+	case OutcomeShutdown:
+		return "♿️"
+	case OutcomeRejected:
+		return "🈲"
+	}
+
+	return "❔"
+}
+
+type Entry struct {
+	ID         uint64        `json:"id"`
+	LinkKind   string        `json:"link_kind"`
+	LinkID     string        `json:"link_id"`
+	TrackCount int           `json:"track_count"`
+	TrackIDs   []string      `json:"track_ids,omitempty"`
+	Bytes      int64         `json:"bytes"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+	UserID     int64         `json:"user_id"`
+	ChatID     int64         `json:"chat_id"`
+	Outcome    Outcome       `json:"outcome"`
+	Error      string        `json:"error,omitempty"`
+	// DownloadOnly reports whether the link was only downloaded, and not uploaded.
+	DownloadOnly bool `json:"download_only,omitempty"`
+	// PostURLs are the t.me links of the channel posts the link was uploaded as, if any.
+	PostURLs []string `json:"post_urls,omitempty"`
+	// FailedTrackIDs are the IDs of the tracks of the link that failed to download, and were skipped, if any.
+	FailedTrackIDs []string `json:"failed_track_ids,omitempty"`
+}
+
+func NewEntry(link types.Link, userID, chatID int64) Entry {
+	return Entry{
+		ID:             0,
+		LinkKind:       link.Kind.String(),
+		LinkID:         link.ID,
+		TrackCount:     0,
+		TrackIDs:       nil,
+		Bytes:          0,
+		StartedAt:      time.Now().UTC(),
+		Duration:       0,
+		UserID:         userID,
+		ChatID:         chatID,
+		Outcome:        OutcomeFailed,
+		Error:          "",
+		DownloadOnly:   false,
+		PostURLs:       nil,
+		FailedTrackIDs: nil,
+	}
+}
+
+type Store struct {
+	db *bbolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	opts := &bbolt.Options{ //nolint:exhaustruct
+		NoFreelistSync: true,
+		ReadOnly:       false,
+		Timeout:        1 * time.Second,
+		NoGrowSync:     false,
+		FreelistType:   bbolt.FreelistArrayType,
+	}
+	db, err := bbolt.Open(path, 0o600, opts)
+	if nil != err {
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(jobsBucketName); nil != err {
+			return fmt.Errorf("create jobs bucket: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("create buckets: %v", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	if err := s.db.Close(); nil != err {
+		return fmt.Errorf("close database: %v", err)
+	}
+
+	return nil
+}
+
+// Record persists e, assigning it the next sequential ID.
+func (s *Store) Record(e Entry) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(jobsBucketName)
+
+		id, err := bucket.NextSequence()
+		if nil != err {
+			return fmt.Errorf("get next sequence: %v", err)
+		}
+		e.ID = id
+
+		v, err := json.Marshal(e)
+		if nil != err {
+			return fmt.Errorf("encode entry: %v", err)
+		}
+
+		if err := bucket.Put(itob(id), v); nil != err {
+			return fmt.Errorf("put entry: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("record entry: %v", err)
+	}
+
+	return nil
+}
+
+// Get returns the entry with id, or nil if there is no such entry.
+func (s *Store) Get(id uint64) (*Entry, error) {
+	var out *Entry
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(jobsBucketName).Get(itob(id))
+		if nil == v {
+			return nil
+		}
+
+		var e Entry
+		if err := json.Unmarshal(v, &e); nil != err {
+			return fmt.Errorf("decode entry %d: %v", id, err)
+		}
+		out = &e
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("read entry: %v", err)
+	}
+
+	return out, nil
+}
+
+// Last returns up to n most recent entries, newest first.
+func (s *Store) Last(n int) ([]Entry, error) {
+	out := make([]Entry, 0, n)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(jobsBucketName).Cursor()
+		for k, v := c.Last(); k != nil && len(out) < n; k, v = c.Prev() {
+			var e Entry
+			if err := json.Unmarshal(v, &e); nil != err {
+				return fmt.Errorf("decode entry %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+			out = append(out, e)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("read last entries: %v", err)
+	}
+
+	return out, nil
+}
+
+// All returns all entries, oldest first.
+func (s *Store) All() ([]Entry, error) {
+	var out []Entry
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucketName).ForEach(func(k, v []byte) error {
+			var e Entry
+			if err := json.Unmarshal(v, &e); nil != err {
+				return fmt.Errorf("decode entry %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+			out = append(out, e)
+
+			return nil
+		})
+	})
+	if nil != err {
+		return nil, fmt.Errorf("read all entries: %v", err)
+	}
+
+	return out, nil
+}
+
+type Stats struct {
+	Jobs          int
+	Tracks        int
+	TotalDuration time.Duration
+	ByOutcome     map[Outcome]int
+	ByKind        map[string]int
+	FirstAt       time.Time
+	LastAt        time.Time
+}
+
+func (s *Store) Stats() (*Stats, error) {
+	stats := Stats{
+		Jobs:          0,
+		Tracks:        0,
+		TotalDuration: 0,
+		ByOutcome:     make(map[Outcome]int),
+		ByKind:        make(map[string]int),
+		FirstAt:       time.Time{},
+		LastAt:        time.Time{},
+	}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucketName).ForEach(func(k, v []byte) error {
+			var e Entry
+			if err := json.Unmarshal(v, &e); nil != err {
+				return fmt.Errorf("decode entry %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+
+			stats.Jobs++
+			stats.TotalDuration += e.Duration
+			stats.ByOutcome[e.Outcome]++
+			stats.ByKind[e.LinkKind]++
+			if e.Outcome == OutcomeSucceeded {
+				stats.Tracks += e.TrackCount
+			}
+			if stats.FirstAt.IsZero() || e.StartedAt.Before(stats.FirstAt) {
+				stats.FirstAt = e.StartedAt
+			}
+			if e.StartedAt.After(stats.LastAt) {
+				stats.LastAt = e.StartedAt
+			}
+
+			return nil
+		})
+	})
+	if nil != err {
+		return nil, fmt.Errorf("compute stats: %v", err)
+	}
+
+	return &stats, nil
+}
+
+// Upload is the most recent successful upload of a link.
+type Upload struct {
+	LinkKind string
+	LinkID   string
+	At       time.Time
+}
+
+// Uploads returns the most recent successful upload of each link, least recently uploaded first. Links that were
+// only downloaded are not uploads, and their files are kept.
+func (s *Store) Uploads() ([]Upload, error) {
+	latest := make(map[[2]string]time.Time)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucketName).ForEach(func(k, v []byte) error {
+			var e Entry
+			if err := json.Unmarshal(v, &e); nil != err {
+				return fmt.Errorf("decode entry %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+
+			if e.Outcome != OutcomeSucceeded || e.DownloadOnly {
+				return nil
+			}
+
+			key := [2]string{e.LinkKind, e.LinkID}
+			if at := e.StartedAt.Add(e.Duration); at.After(latest[key]) {
+				latest[key] = at
+			}
+
+			return nil
+		})
+	})
+	if nil != err {
+		return nil, fmt.Errorf("read uploads: %v", err)
+	}
+
+	out := make([]Upload, 0, len(latest))
+	for key, at := range latest {
+		out = append(out, Upload{LinkKind: key[0], LinkID: key[1], At: at})
+	}
+	slices.SortFunc(out, func(a, b Upload) int { return a.At.Compare(b.At) })
+
+	return out, nil
+}
+
+// UploadedTrackIDs returns the IDs of the tracks of link that were uploaded by succeeded jobs, in upload order.
+func (s *Store) UploadedTrackIDs(link types.Link) ([]string, error) {
+	var (
+		out  []string
+		seen = make(map[string]struct{})
+		kind = link.Kind.String()
+	)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucketName).ForEach(func(k, v []byte) error {
+			var e Entry
+			if err := json.Unmarshal(v, &e); nil != err {
+				return fmt.Errorf("decode entry %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+
+			if e.Outcome != OutcomeSucceeded || e.DownloadOnly || e.LinkKind != kind || e.LinkID != link.ID {
+				return nil
+			}
+
+			for _, id := range e.TrackIDs {
+				if _, ok := seen[id]; !ok {
+					seen[id] = struct{}{}
+					out = append(out, id)
+				}
+			}
+
+			return nil
+		})
+	})
+	if nil != err {
+		return nil, fmt.Errorf("read uploaded track ids: %v", err)
+	}
+
+	return out, nil
+}
+
+// FailedTrackIDs returns the most recent entry of chatID with failed tracks, if any, and the IDs of its failed
+// tracks that did not succeed as track links since.
+func (s *Store) FailedTrackIDs(chatID int64) (*Entry, []string, error) {
+	var (
+		out       *Entry
+		succeeded = make(map[string]struct{})
+		track     = types.LinkKindTrack.String()
+	)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(jobsBucketName).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var e Entry
+			if err := json.Unmarshal(v, &e); nil != err {
+				return fmt.Errorf("decode entry %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+
+			if e.ChatID != chatID {
+				continue
+			}
+
+			if len(e.FailedTrackIDs) > 0 {
+				out = &e
+				return nil
+			}
+
+			if e.Outcome == OutcomeSucceeded && e.LinkKind == track {
+				succeeded[e.LinkID] = struct{}{}
+			}
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, nil, fmt.Errorf("read failed track ids: %v", err)
+	}
+
+	if nil == out {
+		return nil, nil, nil
+	}
+
+	ids := slices.DeleteFunc(slices.Clone(out.FailedTrackIDs), func(id string) bool {
+		_, ok := succeeded[id]
+		return ok
+	})
+
+	return out, ids, nil
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+
+	return b
+}
@@ -0,0 +1,197 @@
+package audit_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	store, err := audit.Open(filepath.Join(t.TempDir(), "audit.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	entries, err := store.Last(10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	album := audit.NewEntry(types.Link{Kind: types.LinkKindAlbum, ID: "1"}, 100, 200)
+	album.Outcome = audit.OutcomeSucceeded
+	album.TrackCount = 12
+	album.Duration = 2 * time.Minute
+	require.NoError(t, store.Record(album))
+
+	track := audit.NewEntry(types.Link{Kind: types.LinkKindTrack, ID: "2"}, 100, 200)
+	track.Outcome = audit.OutcomeFailed
+	track.TrackCount = 1
+	track.Duration = time.Minute
+	track.Error = "boom"
+	require.NoError(t, store.Record(track))
+
+	entries, err = store.Last(1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, uint64(2), entries[0].ID)
+	assert.Equal(t, "track", entries[0].LinkKind)
+	assert.Equal(t, "boom", entries[0].Error)
+
+	entries, err = store.Last(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(1), entries[1].ID)
+
+	stats, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Jobs)
+	assert.Equal(t, 12, stats.Tracks)
+	assert.Equal(t, 3*time.Minute, stats.TotalDuration)
+	assert.Equal(t, 1, stats.ByOutcome[audit.OutcomeSucceeded])
+	assert.Equal(t, 1, stats.ByOutcome[audit.OutcomeFailed])
+	assert.Equal(t, 1, stats.ByKind["album"])
+	assert.Equal(t, 1, stats.ByKind["track"])
+
+	uploads, err := store.Uploads()
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "album", uploads[0].LinkKind)
+	assert.Equal(t, "1", uploads[0].LinkID)
+	assert.True(t, album.StartedAt.Add(album.Duration).Equal(uploads[0].At))
+
+	entries, err = store.All()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(1), entries[0].ID)
+	assert.Equal(t, uint64(2), entries[1].ID)
+
+	entry, err := store.Get(2)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, "2", entry.LinkID)
+
+	entry, err = store.Get(3)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestStoreUploadedTrackIDs(t *testing.T) {
+	t.Parallel()
+
+	store, err := audit.Open(filepath.Join(t.TempDir(), "audit.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	link := types.Link{Kind: types.LinkKindAlbum, ID: "1"}
+
+	trackIDs, err := store.UploadedTrackIDs(link)
+	require.NoError(t, err)
+	assert.Empty(t, trackIDs)
+
+	original := audit.NewEntry(link, 100, 200)
+	original.Outcome = audit.OutcomeSucceeded
+	original.TrackIDs = []string{"10", "11"}
+	require.NoError(t, store.Record(original))
+
+	failed := audit.NewEntry(link, 100, 200)
+	failed.TrackIDs = []string{"12"}
+	require.NoError(t, store.Record(failed))
+
+	other := audit.NewEntry(types.Link{Kind: types.LinkKindPlaylist, ID: "1"}, 100, 200)
+	other.Outcome = audit.OutcomeSucceeded
+	other.TrackIDs = []string{"20"}
+	require.NoError(t, store.Record(other))
+
+	update := audit.NewEntry(link, 100, 200)
+	update.Outcome = audit.OutcomeSucceeded
+	update.TrackIDs = []string{"11", "13"}
+	require.NoError(t, store.Record(update))
+
+	downloaded := audit.NewEntry(link, 100, 200)
+	downloaded.Outcome = audit.OutcomeSucceeded
+	downloaded.TrackIDs = []string{"14"}
+	downloaded.DownloadOnly = true
+	require.NoError(t, store.Record(downloaded))
+
+	trackIDs, err = store.UploadedTrackIDs(link)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10", "11", "13"}, trackIDs)
+
+	uploads, err := store.Uploads()
+	require.NoError(t, err)
+	require.Len(t, uploads, 2)
+	assert.True(t, update.StartedAt.Add(update.Duration).Equal(uploads[1].At))
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	album := audit.NewEntry(types.Link{Kind: types.LinkKindAlbum, ID: "1"}, 100, 200)
+	album.StartedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	album.Outcome = audit.OutcomeSucceeded
+	album.TrackCount = 12
+	album.Bytes = 1024
+	album.Duration = 90*time.Second + 400*time.Millisecond
+
+	mix := audit.NewEntry(types.Link{Kind: types.LinkKindMix, ID: "abc"}, 0, 200)
+	mix.StartedAt = time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	mix.Outcome = audit.OutcomeFailed
+	mix.Duration = time.Minute
+
+	var buf bytes.Buffer
+	require.NoError(t, audit.WriteCSV(&buf, []audit.Entry{album, mix}))
+
+	expected := "date,requester,link,kind,tracks,bytes,duration,status\n" +
+		"2026-01-02T03:04:05Z,100,https://tidal.com/album/1,album,12,1024,90,succeeded\n" +
+		"2026-01-03T00:00:00Z,,https://tidal.com/mix/abc,mix,0,0,60,failed\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestStoreFailedTrackIDs(t *testing.T) {
+	t.Parallel()
+
+	store, err := audit.Open(filepath.Join(t.TempDir(), "audit.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	entry, trackIDs, err := store.FailedTrackIDs(200)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+	assert.Empty(t, trackIDs)
+
+	older := audit.NewEntry(types.Link{Kind: types.LinkKindMix, ID: "1"}, 100, 200)
+	older.Outcome = audit.OutcomeSucceeded
+	older.FailedTrackIDs = []string{"5"}
+	require.NoError(t, store.Record(older))
+
+	playlist := audit.NewEntry(types.Link{Kind: types.LinkKindPlaylist, ID: "1"}, 100, 200)
+	playlist.Outcome = audit.OutcomeSucceeded
+	playlist.FailedTrackIDs = []string{"10", "11", "12"}
+	require.NoError(t, store.Record(playlist))
+
+	other := audit.NewEntry(types.Link{Kind: types.LinkKindPlaylist, ID: "2"}, 100, 300)
+	other.Outcome = audit.OutcomeSucceeded
+	other.FailedTrackIDs = []string{"20"}
+	require.NoError(t, store.Record(other))
+
+	retried := audit.NewEntry(types.Link{Kind: types.LinkKindTrack, ID: "11"}, 100, 200)
+	retried.Outcome = audit.OutcomeSucceeded
+	require.NoError(t, store.Record(retried))
+
+	failedAgain := audit.NewEntry(types.Link{Kind: types.LinkKindTrack, ID: "12"}, 100, 200)
+	require.NoError(t, store.Record(failedAgain))
+
+	entry, trackIDs, err = store.FailedTrackIDs(200)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, "1", entry.LinkID)
+	assert.Equal(t, types.LinkKindPlaylist.String(), entry.LinkKind)
+	assert.Equal(t, []string{"10", "12"}, trackIDs)
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+var csvHeader = []string{"date", "requester", "link", "kind", "tracks", "bytes", "duration", "status"}
+
+// WriteCSV writes entries to w as CSV, one row per entry, preceded by a header row.
+// Durations are written in whole seconds. Requester is empty for jobs that were not started by a user,
+// e.g., watched links syncs.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); nil != err {
+		return fmt.Errorf("write header: %v", err)
+	}
+
+	for _, e := range entries {
+		var requester string
+		if e.UserID != 0 {
+			requester = strconv.FormatInt(e.UserID, 10)
+		}
+
+		link := e.LinkID
+		if kind, ok := types.ParseLinkKind(e.LinkKind); ok {
+			link = types.Link{Kind: kind, ID: e.LinkID}.URL()
+		}
+
+		row := []string{
+			e.StartedAt.UTC().Format(time.RFC3339),
+			requester,
+			link,
+			e.LinkKind,
+			strconv.Itoa(e.TrackCount),
+			strconv.FormatInt(e.Bytes, 10),
+			strconv.FormatInt(int64(e.Duration.Round(time.Second)/time.Second), 10),
+			string(e.Outcome),
+		}
+		if err := cw.Write(row); nil != err {
+			return fmt.Errorf("write entry %d: %v", e.ID, err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); nil != err {
+		return fmt.Errorf("flush: %v", err)
+	}
+
+	return nil
+}
@@ -14,11 +14,16 @@ import (
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
 	"github.com/rs/zerolog"
 
+	"github.com/xeptore/tidalgram/audit"
 	"github.com/xeptore/tidalgram/config"
 	"github.com/xeptore/tidalgram/constant"
+	"github.com/xeptore/tidalgram/events"
+	"github.com/xeptore/tidalgram/janitor"
+	"github.com/xeptore/tidalgram/linkkind"
 	"github.com/xeptore/tidalgram/telegram"
 	"github.com/xeptore/tidalgram/tidal"
 	"github.com/xeptore/tidalgram/tidal/types"
@@ -33,6 +38,13 @@ type Bot struct {
 	logger     zerolog.Logger
 	papaChatID int64
 	mamaChatID int64
+	webhook    config.BotWebhook
+	// updates receives the messages of the user account frontend. It is nil if the Bot API is used.
+	updates *UserUpdates
+	// senders are the users whose messages are received by the user account frontend, by their IDs.
+	senders map[int64]gotgbot.User
+	// dispatched is closed once the dispatcher handled the last message received by the user account frontend.
+	dispatched chan struct{}
 	Account    Account
 }
 
@@ -83,7 +95,30 @@ func New(ctx context.Context, logger zerolog.Logger, conf config.Bot) (*Bot, err
 		return nil, fmt.Errorf("create bot: %v", err)
 	}
 
-	dispatcher := ext.NewDispatcher(&ext.DispatcherOpts{ //nolint:exhaustruct
+	dispatcher := newDispatcher(ctx, logger)
+	updater := ext.NewUpdater(dispatcher, nil)
+
+	if IsLocalAPIServer(conf.APIURL) {
+		logger.Info().Str("api_url", conf.APIURL).Msg("Using local Bot API server. Documents are sent by their file paths")
+	}
+
+	return &Bot{
+		bot:        b,
+		updater:    updater,
+		dispatcher: dispatcher,
+		logger:     logger,
+		papaChatID: conf.PapaID,
+		mamaChatID: conf.MamaID,
+		webhook:    conf.Webhook,
+		updates:    nil,
+		senders:    nil,
+		dispatched: nil,
+		Account:    fillAccount(b),
+	}, nil
+}
+
+func newDispatcher(ctx context.Context, logger zerolog.Logger) *ext.Dispatcher {
+	return ext.NewDispatcher(&ext.DispatcherOpts{ //nolint:exhaustruct
 		Error: func(_ *gotgbot.Bot, _ *ext.Context, err error) ext.DispatcherAction {
 			if ctxErr := ctx.Err(); nil != ctxErr && errors.Is(ctxErr, context.Canceled) && errors.Is(err, context.Canceled) {
 				logger.Warn().Msg("Context cancelled while handling update")
@@ -99,17 +134,6 @@ func New(ctx context.Context, logger zerolog.Logger, conf config.Bot) (*Bot, err
 		},
 		MaxRoutines: 10,
 	})
-	updater := ext.NewUpdater(dispatcher, nil)
-
-	return &Bot{
-		bot:        b,
-		updater:    updater,
-		dispatcher: dispatcher,
-		logger:     logger,
-		papaChatID: conf.PapaID,
-		mamaChatID: conf.MamaID,
-		Account:    fillAccount(b),
-	}, nil
 }
 
 func fillAccount(b *gotgbot.Bot) Account {
@@ -124,19 +148,27 @@ func fillAccount(b *gotgbot.Bot) Account {
 }
 
 func (b *Bot) Start(ctx context.Context) error {
-	pollOpts := ext.PollingOpts{
-		DropPendingUpdates: true,
-		GetUpdatesOpts: &gotgbot.GetUpdatesOpts{ //nolint:exhaustruct
-			Timeout: 9,
-			RequestOpts: &gotgbot.RequestOpts{ //nolint:exhaustruct
-				Timeout: time.Second * 10,
+	if nil != b.updates {
+		b.startUserAccount()
+	} else if b.webhook.Enabled() {
+		if err := b.startWebhook(ctx); nil != err {
+			return fmt.Errorf("start webhook: %w", err)
+		}
+	} else {
+		pollOpts := ext.PollingOpts{
+			DropPendingUpdates: true,
+			GetUpdatesOpts: &gotgbot.GetUpdatesOpts{ //nolint:exhaustruct
+				Timeout: 9,
+				RequestOpts: &gotgbot.RequestOpts{ //nolint:exhaustruct
+					Timeout: time.Second * 10,
+				},
+				AllowedUpdates: []string{"message", "callback_query"},
 			},
-			AllowedUpdates: []string{"message"},
-		},
-		EnableWebhookDeletion: true,
-	}
-	if err := b.updater.StartPolling(b.bot, &pollOpts); nil != err {
-		return fmt.Errorf("start polling: %v", err)
+			EnableWebhookDeletion: true,
+		}
+		if err := b.updater.StartPolling(b.bot, &pollOpts); nil != err {
+			return fmt.Errorf("start polling: %v", err)
+		}
 	}
 
 	sendOpts := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
@@ -191,13 +223,89 @@ func (b *Bot) Start(ctx context.Context) error {
 			Command:     "/cancel",
 			Description: "Cancels the running download job if any.",
 		},
+		{
+			Command:     "/pause",
+			Description: "Pauses the running download job before its next track.",
+		},
+		{
+			Command:     "/resume",
+			Description: "Resumes the paused download job.",
+		},
 		{
 			Command:     "/tidal_login",
-			Description: "Starts Tidal authorization flow.",
+			Description: "Starts Tidal authorization flow, optionally of the named account.",
 		},
 		{
 			Command:     "/tidal_auth_status",
-			Description: "Pings Tidal and reports authentication state.",
+			Description: "Reports the logged in Tidal account and its subscription.",
+		},
+		{
+			Command:     "/history",
+			Description: "Lists the most recent download jobs, or exports all as CSV with export.",
+		},
+		{
+			Command:     "/stats",
+			Description: "Summarizes all recorded download jobs.",
+		},
+		{
+			Command:     "/status",
+			Description: "Reports the running job, maintenance mode, and space reclaimed by cleanups.",
+		},
+		{
+			Command:     "/sync",
+			Description: "Uploads playlist or mix tracks added since the last sync.",
+		},
+		{
+			Command:     "/update",
+			Description: "Uploads album tracks added since the album was uploaded, replying to its post.",
+		},
+		{
+			Command:     "/retry_failed",
+			Description: "Downloads and uploads again the tracks skipped by the last job as failed.",
+		},
+		{
+			Command:     "/watch",
+			Description: "Watches a playlist or mix, or lists the watched ones.",
+		},
+		{
+			Command:     "/unwatch",
+			Description: "Stops watching a playlist or mix.",
+		},
+		{
+			Command:     "/sendto",
+			Description: "Uploads links to an allowed destination instead of the configured peers.",
+		},
+		{
+			Command:     "/zip",
+			Description: "Uploads albums as ZIP archives instead of separate tracks.",
+		},
+		{
+			Command:     "/strict",
+			Description: "Fails the job if any track is missing lyrics, credits, a cover, or an ISRC.",
+		},
+		{
+			Command:     "/download_only",
+			Description: "Downloads and tags links, and exports them if configured, without uploading them.",
+		},
+		{
+			Command:     "/notify",
+			Description: "Uploads links with notifications instead of silently.",
+		},
+		{
+			Command:     "/schedule",
+			Description: "Uploads links as posts scheduled after a delay, e.g., 2h, or at a time of day, e.g., 21:00.",
+		},
+		{
+			Command:     "/debug",
+			Description: "Sends the ffmpeg debug bundle of a job track that failed to be tagged.",
+		},
+		{
+			Command:     "/maintenance",
+			Description: "Turns maintenance mode, which declines new links, on or off.",
+		},
+		{
+			Command:     "/reload",
+			Description: "Reloads the config file, applying timeouts, concurrency, captions, and signatures to new jobs.",
 		},
 	}
 	if _, err := b.bot.SetMyCommandsWithContext(ctx, commands, nil); nil != err {
@@ -208,11 +316,75 @@ func (b *Bot) Start(ctx context.Context) error {
 	return nil
 }
 
+// NotifyPeerNotFound tells papa that the configured upload peer of peerErr was not found, listing the dialogs with
+// the closest IDs, if any, so that a mistyped ID can be fixed without digging through logs. The bot does not
+// need to be started.
+func (b *Bot) NotifyPeerNotFound(ctx context.Context, peerErr *telegram.PeerNotFoundError) error {
+	lines := []string{
+		"🔎 Configured Telegram " + peerErr.Peer.Kind + " peer " + strconv.FormatInt(peerErr.Peer.ID, 10) +
+			" was not found among the dialogs, so I can't start, papa.",
+	}
+	if len(peerErr.Candidates) > 0 {
+		lines = append(lines, "", "Did you mean one of these?")
+		for _, c := range peerErr.Candidates {
+			line := "• " + c.Kind + " " + strconv.FormatInt(c.ID, 10) + ": " + c.Title
+			if len(c.Username) > 0 {
+				line += " (@" + c.Username + ")"
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// Titles are sent as is, hence no parse mode.
+	if _, err := b.bot.SendMessageWithContext(ctx, b.papaChatID, strings.Join(lines, "\n"), nil); nil != err {
+		return fmt.Errorf("send message: %w", err)
+	}
+
+	return nil
+}
+
+// startWebhook starts the webhook server, and then registers its public URL with Telegram, so that no update is
+// sent to it before it is listening.
+func (b *Bot) startWebhook(ctx context.Context) error {
+	webhookOpts := ext.WebhookOpts{
+		ListenAddr:        b.webhook.ListenAddr,
+		ListenNet:         "tcp",
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		CertFile:          b.webhook.CertFile,
+		KeyFile:           b.webhook.KeyFile,
+		SecretToken:       b.webhook.SecretToken,
+	}
+	if err := b.updater.StartWebhook(b.bot, b.webhook.Path(), webhookOpts); nil != err {
+		return fmt.Errorf("start webhook server: %v", err)
+	}
+
+	setOpts := &gotgbot.SetWebhookOpts{ //nolint:exhaustruct
+		AllowedUpdates:     []string{"message", "callback_query"},
+		DropPendingUpdates: true,
+		SecretToken:        b.webhook.SecretToken,
+	}
+	if _, err := b.bot.SetWebhookWithContext(ctx, b.webhook.URL, setOpts); nil != err {
+		return fmt.Errorf("set webhook: %w", err)
+	}
+	b.logger.Info().Str("url", b.webhook.URL).Str("listen_addr", b.webhook.ListenAddr).Msg("Receiving updates via webhook")
+
+	return nil
+}
+
 func (b *Bot) Stop() error {
-	if err := b.updater.Stop(); nil != err {
+	if nil != b.updates {
+		b.stopUserAccount()
+	} else if err := b.updater.Stop(); nil != err {
 		return fmt.Errorf("bot stop updater: %v", err)
 	}
 
+	if nil == b.updates && b.webhook.Enabled() {
+		if _, err := b.bot.DeleteWebhook(nil); nil != err {
+			b.logger.Error().Err(err).Msg("Failed to delete webhook")
+		}
+	}
+
 	sendOpts := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
 		ParseMode: gotgbot.ParseModeMarkdown,
 	}
@@ -290,14 +462,124 @@ func (b *Bot) RegisterHandlers(
 	td *tidal.Client,
 	up *telegram.Uploader,
 	worker *Worker,
+	store *audit.Store,
+	watcher *Watcher,
+	jn *janitor.Janitor,
+	outbox *Outbox,
+	bus *events.Bus,
+	maintenance *Maintenance,
+	reloader *Reloader,
 ) {
+	prompts := NewLinkOptionsPrompts()
+	// Documents of the user account frontend are uploaded over MTProto, rather than by the Bot API server.
+	localAPI := IsLocalAPIServer(conf.APIURL) && nil == b.updates
+
 	b.dispatcher.AddHandler(
 		handlers.
 			NewMessage(
 				tidalURLFilter,
 				NewChainHandler(
 					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
-					NewTidalURLHandler(ctx, logger, td, conf, up, worker),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewTidalURLHandler(ctx, logger, td, conf, up, worker, store, jn, outbox, bus, prompts),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				sendToCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewTidalURLHandler(ctx, logger, td, conf, up, worker, store, jn, outbox, bus, prompts),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				zipCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewTidalURLHandler(ctx, logger, td, conf, up, worker, store, jn, outbox, bus, prompts),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				strictCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewTidalURLHandler(ctx, logger, td, conf, up, worker, store, jn, outbox, bus, prompts),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				downloadOnlyCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewTidalURLHandler(ctx, logger, td, conf, up, worker, store, jn, outbox, bus, prompts),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				notifyCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewTidalURLHandler(ctx, logger, td, conf, up, worker, store, jn, outbox, bus, prompts),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				scheduleCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewTidalURLHandler(ctx, logger, td, conf, up, worker, store, jn, outbox, bus, prompts),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewMessage(
+				linksFileFilter,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewLinksFileHandler(ctx, logger, td, up, worker, store, jn, outbox, bus, conf.CleanupRequests),
 				),
 			).
 			SetAllowChannel(false).
@@ -309,7 +591,7 @@ func (b *Bot) RegisterHandlers(
 			NewCommand(
 				"hello",
 				NewChainHandler(
-					NewHelloCommandHandler(ctx, conf.PapaID, conf.MamaID),
+					NewHelloCommandHandler(ctx, conf.PapaID, conf.MamaID, outbox),
 				),
 			).
 			SetAllowChannel(false).
@@ -322,7 +604,33 @@ func (b *Bot) RegisterHandlers(
 				"cancel",
 				NewChainHandler(
 					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
-					NewCancelCommandHandler(ctx, worker),
+					NewCancelCommandHandler(ctx, worker, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				"pause",
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewPauseCommandHandler(ctx, worker, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				"resume",
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewResumeCommandHandler(ctx, worker, outbox),
 				),
 			).
 			SetAllowChannel(false).
@@ -335,7 +643,7 @@ func (b *Bot) RegisterHandlers(
 				tidalLoginCommand,
 				NewChainHandler(
 					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
-					NewTidalLoginCommandHandler(ctx, logger, td),
+					NewTidalLoginCommandHandler(ctx, logger, td, outbox),
 				),
 			).
 			SetAllowChannel(false).
@@ -345,15 +653,204 @@ func (b *Bot) RegisterHandlers(
 	b.dispatcher.AddHandler(
 		handlers.
 			NewCommand(
-				"tidal_auth_status",
+				tidalAuthStatusCommand,
 				NewChainHandler(
 					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
-					NewTidalAuthStatusCommandHandler(ctx, logger, td),
+					NewTidalAuthStatusCommandHandler(ctx, logger, td, outbox),
 				),
 			).
 			SetAllowChannel(false).
 			SetAllowEdited(false),
 	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				historyCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewHistoryCommandHandler(ctx, logger, conf.Audit, store, localAPI, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				debugCommand,
+				NewChainHandler(
+					NewPapaOnlyGuard(conf.PapaID),
+					NewDebugCommandHandler(ctx, logger, td, store, localAPI, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				maintenanceCommand,
+				NewChainHandler(
+					NewPapaOnlyGuard(conf.PapaID),
+					NewMaintenanceCommandHandler(ctx, logger, maintenance, worker, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				reloadCommand,
+				NewChainHandler(
+					NewPapaOnlyGuard(conf.PapaID),
+					NewReloadCommandHandler(ctx, logger, reloader, worker, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				statsCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewStatsCommandHandler(ctx, logger, store, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				statusCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewStatusCommandHandler(ctx, logger, worker, maintenance, jn, td, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				syncCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewSyncCommandHandler(ctx, logger, td, up, worker, store, jn, outbox, bus),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				updateCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewUpdateCommandHandler(ctx, logger, td, up, worker, store, jn, outbox, bus),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				retryFailedCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewMaintenanceGuard(maintenance, outbox),
+					NewRetryFailedCommandHandler(ctx, logger, td, up, worker, store, jn, outbox, bus),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				watchCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewWatchCommandHandler(ctx, logger, watcher, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.
+			NewCommand(
+				unwatchCommand,
+				NewChainHandler(
+					NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+					NewUnwatchCommandHandler(ctx, logger, watcher, outbox),
+				),
+			).
+			SetAllowChannel(false).
+			SetAllowEdited(false),
+	)
+
+	b.dispatcher.AddHandler(
+		handlers.NewCallback(
+			callbackquery.Prefix(linkOptionsCallbackPrefix),
+			NewChainHandler(
+				NewPapaOrMamaOnlyGuard(conf.PapaID, conf.MamaID),
+				NewLinkOptionsCallbackHandler(ctx, logger, prompts),
+			),
+		),
+	)
+}
+
+// extractDestination returns the username of the peer that the message links are asked to be uploaded to,
+// using either the "<links> -> @username" or the "/sendto @username <links>" syntax. It returns an empty
+// username if neither syntax is used, and false if either is used without a username.
+func extractDestination(msg *gotgbot.Message) (string, bool) {
+	var rest string
+	if hasCommand(msg, sendToCommand) {
+		rest = strings.TrimSpace(strings.TrimPrefix(msg.Text, strings.Fields(msg.Text)[0]))
+	} else if _, after, found := strings.Cut(msg.Text, "->"); found {
+		rest = after
+	} else {
+		return "", true
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "@") || len(fields[0]) == 1 {
+		return "", false
+	}
+
+	return strings.TrimPrefix(fields[0], "@"), true
+}
+
+// hasCommand reports whether the message starts with the given bot command.
+func hasCommand(msg *gotgbot.Message, command string) bool {
+	fields := strings.Fields(msg.Text)
+	return len(fields) > 0 && isCommand(fields[0], command)
+}
+
+// isCommand reports whether word is the given bot command, optionally suffixed with the bot username.
+func isCommand(word, command string) bool {
+	name, _, _ := strings.Cut(word, "@")
+	return name == "/"+command
 }
 
 func tidalURLFilter(msg *gotgbot.Message) bool {
@@ -366,7 +863,13 @@ func tidalURLFilter(msg *gotgbot.Message) bool {
 			continue
 		}
 
-		if IsTidalURL(gotgbot.ParseEntity(msg.Text, ent).Url) {
+		msgURL := gotgbot.ParseEntity(msg.Text, ent).Url
+		if _, err := types.ParseURL(msgURL); !errors.Is(err, types.ErrNotTidalURL) {
+			// Unrecognized Tidal URLs are accepted as well, so that the handler can report them.
+			return true
+		}
+
+		if _, ok := linkkind.ParseURL(msgURL); ok {
 			return true
 		}
 	}
@@ -375,39 +878,38 @@ func tidalURLFilter(msg *gotgbot.Message) bool {
 }
 
 func IsTidalURL(msg string) bool {
-	u, err := url.Parse(msg)
-	if nil != err {
-		return false
-	}
+	_, err := types.ParseURL(msg)
+	return nil == err
+}
 
-	switch u.Scheme {
-	case "https":
-	default:
-		return false
-	}
+// unrecognizedMessageLinks returns the descriptions of the Tidal URLs in msg that cannot be parsed into links,
+// e.g., shortened share links, and links to unsupported Tidal pages.
+func unrecognizedMessageLinks(msg *gotgbot.Message) []string {
+	var out []string
+	for _, ent := range msg.Entities {
+		if ent.Type != "url" {
+			continue
+		}
 
-	switch u.Host {
-	case "tidal.com", "www.tidal.com", "listen.tidal.com":
-	default:
-		return false
+		if desc, ok := describeUnrecognizedURL(gotgbot.ParseEntity(msg.Text, ent).Url); ok {
+			out = append(out, desc)
+		}
 	}
 
-	pathParts := tidal.NormalizePathParts(u.Path)
-	if len(pathParts) < 2 {
-		return false
-	}
-	// Reject URLs where "u" is the ID (e.g., /track/u) - missing actual ID
-	if len(pathParts) == 2 && pathParts[1] == "u" {
-		return false
-	}
+	return out
+}
 
-	switch pathParts[0] {
-	case "mix", "playlist", "album", "artist", "credits", "track", "video":
+// describeUnrecognizedURL describes why raw cannot be parsed into a link. It returns false if raw is either a
+// recognized link, or not a Tidal URL at all.
+func describeUnrecognizedURL(raw string) (string, bool) {
+	switch _, err := types.ParseURL(raw); {
+	case errors.Is(err, types.ErrShortenedURL):
+		return "`" + raw + "`: shortened link. Open it, and send the full link instead.", true
+	case errors.Is(err, types.ErrUnsupportedURL):
+		return "`" + raw + "`: not a link to a supported Tidal page.", true
 	default:
-		return false
+		return "", false
 	}
-
-	return true
 }
 
 func extractMessageLinks(msg *gotgbot.Message) []types.Link {
@@ -419,12 +921,11 @@ func extractMessageLinks(msg *gotgbot.Message) []types.Link {
 		}
 
 		msgURL := gotgbot.ParseEntity(msg.Text, ent).Url
-		if !IsTidalURL(msgURL) {
-			continue
+		if IsTidalURL(msgURL) {
+			out = append(out, tidal.ParseLink(msgURL))
+		} else if link, ok := linkkind.ParseURL(msgURL); ok {
+			out = append(out, link)
 		}
-
-		link := tidal.ParseLink(msgURL)
-		out = append(out, link)
 	}
 
 	return out[:len(out):len(out)]
@@ -1,11 +1,18 @@
 package bot_test
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/xeptore/tidalgram/bot"
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/tidal/types"
 )
 
 func TestIsTidalURL(t *testing.T) {
@@ -252,3 +259,121 @@ func TestIsTidalURL(t *testing.T) {
 		})
 	}
 }
+
+func TestIsLocalAPIServer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		url      string
+		expected bool
+	}{
+		{name: "cloud", url: "https://api.telegram.org", expected: false},
+		{name: "cloud with different case", url: "https://API.Telegram.org/", expected: false},
+		{name: "localhost", url: "http://localhost:8081", expected: true},
+		{name: "compose service", url: "http://telegram-bot-api:8081", expected: true},
+		{name: "invalid", url: "://", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, bot.IsLocalAPIServer(tt.url), "URL: %s", tt.url)
+		})
+	}
+}
+
+func TestParseLinksFile(t *testing.T) {
+	t.Parallel()
+
+	file := strings.Join(
+		[]string{
+			"https://tidal.com/album/123",
+			"",
+			"kind,url",
+			"track,https://tidal.com/browse/track/456/u",
+			`"playlist";"https://listen.tidal.com/playlist/abc-def"`,
+			"https://tidal.com/album/123 https://tidal.com/mix/789\thttps://example.com/album/1",
+			"https://tidal.link/xyz",
+		},
+		"\n",
+	)
+
+	links, unrecognized, err := bot.ParseLinksFile(strings.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]types.Link{
+			{Kind: types.LinkKindAlbum, ID: "123"},
+			{Kind: types.LinkKindTrack, ID: "456"},
+			{Kind: types.LinkKindPlaylist, ID: "abc-def"},
+			{Kind: types.LinkKindMix, ID: "789"},
+		},
+		links,
+	)
+	require.Len(t, unrecognized, 1)
+	assert.Contains(t, unrecognized[0], "https://tidal.link/xyz")
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, time.March, 14, 20, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{name: "delay", input: "2h30m", expected: time.Date(2025, time.March, 14, 23, 0, 0, 0, time.UTC)},
+		{name: "time of day later today", input: "21:00", expected: time.Date(2025, time.March, 14, 21, 0, 0, 0, time.UTC)},
+		{name: "time of day passed today", input: "09:15", expected: time.Date(2025, time.March, 15, 9, 15, 0, 0, time.UTC)},
+		{name: "time of day too soon", input: "20:30", expected: time.Date(2025, time.March, 15, 20, 30, 0, 0, time.UTC)},
+		{name: "delay too short", input: "30s", wantErr: true},
+		{name: "delay too long", input: "9000h", wantErr: true},
+		{name: "invalid", input: "tonight", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			at, err := bot.ParseScheduleTime(tt.input, now)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, at)
+		})
+	}
+}
+
+func TestOutboxDropsRequestsAfterStop(t *testing.T) {
+	t.Parallel()
+
+	outbox := bot.NewOutbox(&bot.Bot{}, zerolog.Nop(), config.BotOutbox{Interval: config.Duration{Duration: time.Millisecond}}) //nolint:exhaustruct
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	outbox.Run(ctx)
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+
+		// More requests than the queue can hold, which would block forever if they were not dropped.
+		for range 2048 {
+			outbox.Send(1, "text", nil)
+		}
+		outbox.NewStatus(1, "status", nil).Keep()
+		outbox.Collecting().DeleteCollected(1)
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "outbox requests blocked after it stopped")
+	}
+}
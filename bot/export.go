@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// exporter copies the tracks of download only jobs to the configured export destinations.
+type exporter struct {
+	conf config.BotExport
+	dir  fs.DownloadsDir
+}
+
+func newExporter(conf config.BotExport, dir fs.DownloadsDir) *exporter {
+	return &exporter{conf: conf, dir: dir}
+}
+
+// export copies the downloaded tracks of link to the export path, and the rclone remote, if configured, and
+// returns the number of exported tracks. Tracks are named by their library paths if they are linked to the
+// library, and are put in a directory named after link otherwise.
+func (e *exporter) export(ctx context.Context, logger zerolog.Logger, link types.Link) (int, error) {
+	if nil == e || (e.conf.Path == "" && e.conf.RcloneRemote == "") {
+		return 0, nil
+	}
+
+	tracks, err := e.dir.NamedTracks(link)
+	if nil != err {
+		return 0, fmt.Errorf("get link tracks: %v", err)
+	}
+
+	for _, track := range tracks {
+		if len(e.conf.Path) > 0 {
+			if err := exportFile(track.Path, filepath.Join(e.conf.Path, track.RelPath)); nil != err {
+				return 0, fmt.Errorf("export track %s: %v", track.ID, err)
+			}
+		}
+
+		if len(e.conf.RcloneRemote) > 0 {
+			if err := rcloneCopy(ctx, logger, track.Path, rcloneTarget(e.conf.RcloneRemote, track.RelPath)); nil != err {
+				return 0, fmt.Errorf("copy track %s to rclone remote: %w", track.ID, err)
+			}
+		}
+	}
+
+	return len(tracks), nil
+}
+
+func exportFile(src, dst string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o0755); nil != err {
+		return fmt.Errorf("create export directory: %v", err)
+	}
+
+	in, err := os.Open(src)
+	if nil != err {
+		return fmt.Errorf("open track file: %v", err)
+	}
+	defer func() {
+		if closeErr := in.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close track file: %v", closeErr))
+		}
+	}()
+
+	// Exported files are watched by other tools, e.g., media servers, which must not see partial files.
+	tmpPath := dst + ".part"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o0644)
+	if nil != err {
+		return fmt.Errorf("create exported file: %v", err)
+	}
+	if _, err := io.Copy(out, in); nil != err {
+		return errors.Join(fmt.Errorf("copy track file: %v", err), out.Close(), os.Remove(tmpPath))
+	}
+	if err := out.Close(); nil != err {
+		return errors.Join(fmt.Errorf("close exported file: %v", err), os.Remove(tmpPath))
+	}
+
+	if err := os.Rename(tmpPath, dst); nil != err {
+		return fmt.Errorf("rename exported file: %v", err)
+	}
+
+	return nil
+}
+
+// rcloneTarget returns the path of relPath inside remote, which is either only the remote name, e.g.,
+// "drive:", or also a path inside it, e.g., "drive:music".
+func rcloneTarget(remote, relPath string) string {
+	relPath = filepath.ToSlash(relPath)
+	if strings.HasSuffix(remote, ":") || strings.HasSuffix(remote, "/") {
+		return remote + relPath
+	}
+
+	return remote + "/" + relPath
+}
+
+func rcloneCopy(ctx context.Context, logger zerolog.Logger, src, dst string) error {
+	args := []string{"copyto", src, dst}
+	cmd := exec.CommandContext(ctx, "rclone", args...)
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running rclone to export track")
+	if err := cmd.Run(); nil != err {
+		return fmt.Errorf("run rclone (%v): %s", err, stdErr.String())
+	}
+
+	return nil
+}
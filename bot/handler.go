@@ -1,9 +1,15 @@
 package bot
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,261 +20,1611 @@ import (
 	"github.com/samber/lo"
 	"golang.org/x/sync/semaphore"
 
+	"github.com/xeptore/tidalgram/audit"
 	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/errs"
+	"github.com/xeptore/tidalgram/events"
+	"github.com/xeptore/tidalgram/janitor"
+	"github.com/xeptore/tidalgram/pipeline"
+	"github.com/xeptore/tidalgram/provider"
 	"github.com/xeptore/tidalgram/telegram"
 	"github.com/xeptore/tidalgram/tidal"
 	"github.com/xeptore/tidalgram/tidal/types"
 )
 
 const (
-	tidalLoginCommand = "tidal_login"
-	codeBlockOpenTxt  = "```txt"
-	codeBlockClose    = "```"
+	tidalLoginCommand      = "tidal_login"
+	tidalAuthStatusCommand = "tidal_auth_status"
+	historyCommand         = "history"
+	historyExportArg       = "export"
+	statsCommand           = "stats"
+	statusCommand          = "status"
+	syncCommand            = "sync"
+	updateCommand          = "update"
+	watchCommand           = "watch"
+	unwatchCommand         = "unwatch"
+	sendToCommand          = "sendto"
+	zipCommand             = "zip"
+	strictCommand          = "strict"
+	downloadOnlyCommand    = "download_only"
+	notifyCommand          = "notify"
+	scheduleCommand        = "schedule"
+	debugCommand           = "debug"
+	maintenanceCommand     = "maintenance"
+	reloadCommand          = "reload"
+	retryFailedCommand     = "retry_failed"
+	maxHistoryLimit        = 50
 )
 
-var ErrNotPapaOrMama = errors.New("sender is not papa or mama")
+var (
+	ErrNotPapaOrMama = errors.New("sender is not papa or mama")
+	ErrNotPapa       = errors.New("sender is not papa")
+
+	errNoUploadedTracks = errors.New("no uploaded tracks recorded")
+	errInMaintenance    = errors.New("bot is in maintenance mode")
+)
+
+// jobMode is how the links of a job are downloaded and uploaded.
+type jobMode int
+
+const (
+	// jobModeDownload downloads and uploads all link tracks.
+	jobModeDownload jobMode = iota
+	// jobModeSync only downloads and uploads the playlist or mix tracks added since the last sync.
+	jobModeSync
+	// jobModeUpdate only downloads and uploads the album tracks that were not uploaded before, as additions
+	// to the album post.
+	jobModeUpdate
+	// jobModeDownloadOnly downloads all link tracks, and exports them instead of uploading them.
+	jobModeDownloadOnly
+)
 
 func NewChainHandler(handlers ...handlers.Response) handlers.Response {
 	return func(b *gotgbot.Bot, u *ext.Context) error {
-		for _, handler := range handlers {
-			if err := handler(b, u); nil != err {
-				if errors.Is(err, ErrNotPapaOrMama) {
-					return ext.EndGroups
-				}
+		for _, handler := range handlers {
+			if err := handler(b, u); nil != err {
+				if errors.Is(err, ErrNotPapaOrMama) || errors.Is(err, ErrNotPapa) || errors.Is(err, errInMaintenance) {
+					return ext.EndGroups
+				}
+
+				return err
+			}
+		}
+
+		return ext.ContinueGroups
+	}
+}
+
+func NewPapaOrMamaOnlyGuard(papaID int64, mamaID int64) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		senderID := u.EffectiveSender.Id()
+		if senderID != papaID && senderID != mamaID {
+			return ErrNotPapaOrMama
+		}
+
+		return nil
+	}
+}
+
+func NewPapaOnlyGuard(papaID int64) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		if u.EffectiveSender.Id() != papaID {
+			return ErrNotPapa
+		}
+
+		return nil
+	}
+}
+
+// NewMaintenanceGuard declines the update with a friendly message while maintenance mode is on. It guards the
+// handlers that start new jobs.
+func NewMaintenanceGuard(maintenance *Maintenance, outbox *Outbox) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		state := maintenance.State()
+		if !state.Enabled {
+			return nil
+		}
+
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		msg := "🛠️ I'm under maintenance since " + state.Since.Format(time.DateTime) + " UTC, and not accepting new links for now. Please try again later."
+		outbox.Send(u.EffectiveMessage.Chat.Id, msg, sendOpt)
+
+		return errInMaintenance
+	}
+}
+
+func NewTidalURLHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	conf config.Bot,
+	up *telegram.Uploader,
+	worker *Worker,
+	store *audit.Store,
+	jn *janitor.Janitor,
+	outbox *Outbox,
+	bus *events.Bus,
+	prompts *LinkOptionsPrompts,
+) handlers.Response {
+	exp := newExporter(conf.Export, src.DownloadsDir())
+
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		logger = logger.
+			With().
+			Int64("chat_id", u.EffectiveMessage.Chat.Id).
+			Int64("message_id", u.EffectiveMessage.MessageId).
+			Int64("sender_id", u.EffectiveSender.Id()).
+			Logger()
+
+		msgID := u.EffectiveMessage.MessageId
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: msgID,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		if unrecognized := unrecognizedMessageLinks(u.EffectiveMessage); len(unrecognized) > 0 {
+			msg := "🤷 Unrecognized Tidal links:\n" + strings.Join(unrecognized, "\n")
+			outbox.Send(chatID, msg, sendOpt)
+
+			if len(extractMessageLinks(u.EffectiveMessage)) == 0 {
+				return nil
+			}
+		}
+
+		archive := hasCommand(u.EffectiveMessage, zipCommand)
+		strict := hasCommand(u.EffectiveMessage, strictCommand)
+		notify := hasCommand(u.EffectiveMessage, notifyCommand)
+		scheduleAt, scheduleOK := extractSchedule(u.EffectiveMessage, time.Now())
+		dest, ok := extractDestination(u.EffectiveMessage)
+		// Links asked to be uploaded in some way are uploaded even if plain link messages are only downloaded.
+		downloadOnly := hasCommand(u.EffectiveMessage, downloadOnlyCommand) ||
+			(conf.DownloadOnly && !archive && !notify && !hasCommand(u.EffectiveMessage, scheduleCommand) &&
+				len(dest) == 0 && !hasCommand(u.EffectiveMessage, sendToCommand))
+		if !ok || !scheduleOK || len(extractMessageLinks(u.EffectiveMessage)) == 0 {
+			msg := "🤨 Usage: `/" + sendToCommand + " @username <Tidal URLs>` or `<Tidal URLs> -> @username`"
+			if archive {
+				msg = "🤨 Usage: `/" + zipCommand + " <Tidal album URLs>`"
+			} else if strict {
+				msg = "🤨 Usage: `/" + strictCommand + " <Tidal URLs>`"
+			} else if hasCommand(u.EffectiveMessage, downloadOnlyCommand) {
+				msg = "🤨 Usage: `/" + downloadOnlyCommand + " <Tidal URLs>`"
+			} else if notify {
+				msg = "🤨 Usage: `/" + notifyCommand + " <Tidal URLs>`"
+			} else if hasCommand(u.EffectiveMessage, scheduleCommand) {
+				msg = "🤨 Usage: `/" + scheduleCommand + " <delay|HH:MM> <Tidal URLs>`, e.g., `/" + scheduleCommand +
+					" 2h30m <Tidal URLs>`, or `/" + scheduleCommand + " 21:00 <Tidal URLs>`"
+			}
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if len(dest) > 0 && !up.AllowsDestination(dest) {
+			msg := "🈲 Uploading to @" + dest + " is not allowed. Add it to the upload destinations in config first."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if !ensureFreeSpace(logger, outbox, jn, chatID, sendOpt) {
+			return nil
+		}
+
+		if conf.LinkOptions.Enabled && !archive && !strict && !downloadOnly && !notify && scheduleAt.IsZero() &&
+			len(dest) == 0 && !hasCommand(u.EffectiveMessage, sendToCommand) {
+			links := extractMessageLinks(u.EffectiveMessage)
+			timeout := conf.LinkOptions.Timeout.Duration
+			opts, ok, err := askLinkOptions(ctx, logger, b, prompts, chatID, sendOpt, links, up.Destinations(), timeout)
+			if nil != err {
+				return fmt.Errorf("ask link options: %w", err)
+			} else if !ok {
+				return nil
+			}
+			dest, archive = opts.Destination, opts.Archive
+		}
+
+		ctx, ok := worker.TryAcquireJob(ctx)
+		if !ok {
+			msg := "🈵 Another download is in progress. Try again later."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+		defer worker.ReleaseJob()
+
+		links := extractMessageLinks(u.EffectiveMessage)
+		if len(links) == 0 {
+			return nil
+		}
+
+		if strict {
+			ctx = tidal.WithStrictMetadata(ctx)
+		}
+
+		header := "🚧 Downloading links"
+		if archive {
+			header += " to upload albums as ZIP archives"
+		}
+		if strict {
+			header += " with strict metadata"
+		}
+		if downloadOnly {
+			header += " without uploading them"
+		}
+		if len(dest) > 0 {
+			header += " for @" + dest
+		}
+		if notify {
+			header += " to post with notifications"
+		}
+		if !scheduleAt.IsZero() {
+			header += " to post at " + scheduleAt.Format("2006/01/02 15:04 MST")
+		}
+		header += ":"
+		linkLines := lo.Map(links, func(link types.Link, _ int) string {
+			return link.Kind.String() + ": `" + link.ID + "`"
+		})
+
+		jobOutbox := outbox
+		if conf.CleanupRequests {
+			jobOutbox = outbox.Collecting()
+		}
+		jobOutbox.Send(chatID, strings.Join(append([]string{header}, linkLines...), "\n"), sendOpt)
+
+		mode, done := jobModeDownload, "uploaded"
+		if downloadOnly {
+			mode, done = jobModeDownloadOnly, "downloaded"
+		}
+
+		opts := telegram.UploadOptions{ //nolint:exhaustruct
+			Destination: dest,
+			Archive:     archive,
+			Notify:      notify,
+			ScheduleAt:  scheduleAt,
+		}
+		if ok := processLinks(ctx, logger, jobOutbox, bus, src, up, exp, store, u.EffectiveSender.Id(), chatID, sendOpt, links, opts, mode); !ok {
+			return nil
+		}
+
+		if conf.CleanupRequests {
+			// The link message is deleted, hence the summary lists the links, and does not reply to it.
+			jobOutbox.DeleteCollected(chatID, msgID)
+			msg := strings.Join(append([]string{"✅ Tidal links were successfully " + done + ":"}, linkLines...), "\n")
+			outbox.Send(chatID, msg, &gotgbot.SendMessageOpts{ParseMode: gotgbot.ParseModeMarkdown}) //nolint:exhaustruct
+
+			return nil
+		}
+
+		outbox.Send(chatID, "✅ Tidal links were successfully "+done+".", sendOpt)
+
+		return nil
+	}
+}
+
+// NewSyncCommandHandler handles the sync command which uploads only the playlist or mix tracks
+// that were added since the last sync of the same link.
+func NewSyncCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	up *telegram.Uploader,
+	worker *Worker,
+	store *audit.Store,
+	jn *janitor.Janitor,
+	outbox *Outbox,
+	bus *events.Bus,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		logger = logger.
+			With().
+			Int64("chat_id", u.EffectiveMessage.Chat.Id).
+			Int64("message_id", u.EffectiveMessage.MessageId).
+			Int64("sender_id", u.EffectiveSender.Id()).
+			Logger()
+
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		links := extractMessageLinks(u.EffectiveMessage)
+		if len(links) == 0 {
+			msg := "Usage: `/" + syncCommand + " <playlist or mix URL>`"
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if !ensureFreeSpace(logger, outbox, jn, chatID, sendOpt) {
+			return nil
+		}
+
+		ctx, ok := worker.TryAcquireJob(ctx)
+		if !ok {
+			msg := "🈵 Another download is in progress. Try again later."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+		defer worker.ReleaseJob()
+
+		opts := telegram.UploadOptions{} //nolint:exhaustruct
+		if ok := processLinks(ctx, logger, outbox, bus, src, up, nil, store, u.EffectiveSender.Id(), chatID, sendOpt, links, opts, jobModeSync); !ok {
+			return nil
+		}
+
+		outbox.Send(chatID, "✅ Tidal links were successfully synced.", sendOpt)
+
+		return nil
+	}
+}
+
+// NewUpdateCommandHandler handles the update command which uploads only the album tracks that were not
+// uploaded before, e.g., tracks added by a deluxe edition, as additions replying to the album post.
+func NewUpdateCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	up *telegram.Uploader,
+	worker *Worker,
+	store *audit.Store,
+	jn *janitor.Janitor,
+	outbox *Outbox,
+	bus *events.Bus,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		logger = logger.
+			With().
+			Int64("chat_id", u.EffectiveMessage.Chat.Id).
+			Int64("message_id", u.EffectiveMessage.MessageId).
+			Int64("sender_id", u.EffectiveSender.Id()).
+			Logger()
+
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		links := extractMessageLinks(u.EffectiveMessage)
+		if len(links) == 0 {
+			msg := "Usage: `/" + updateCommand + " <album URL>`"
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if !ensureFreeSpace(logger, outbox, jn, chatID, sendOpt) {
+			return nil
+		}
+
+		ctx, ok := worker.TryAcquireJob(ctx)
+		if !ok {
+			msg := "🈵 Another download is in progress. Try again later."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+		defer worker.ReleaseJob()
+
+		opts := telegram.UploadOptions{Additions: true} //nolint:exhaustruct
+		if ok := processLinks(ctx, logger, outbox, bus, src, up, nil, store, u.EffectiveSender.Id(), chatID, sendOpt, links, opts, jobModeUpdate); !ok {
+			return nil
+		}
+
+		outbox.Send(chatID, "✅ Tidal albums were successfully updated.", sendOpt)
+
+		return nil
+	}
+}
+
+// NewRetryFailedCommandHandler handles the retry failed command which downloads, and uploads again only the
+// tracks that failed to download, and were skipped by the most recent link of the chat that had any.
+func NewRetryFailedCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	up *telegram.Uploader,
+	worker *Worker,
+	store *audit.Store,
+	jn *janitor.Janitor,
+	outbox *Outbox,
+	bus *events.Bus,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		logger = logger.
+			With().
+			Int64("chat_id", u.EffectiveMessage.Chat.Id).
+			Int64("message_id", u.EffectiveMessage.MessageId).
+			Int64("sender_id", u.EffectiveSender.Id()).
+			Logger()
+
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		entry, trackIDs, err := store.FailedTrackIDs(chatID)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to read failed tracks")
+			msg := "❌ Failed to read failed tracks. Insult logs for details."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if len(trackIDs) == 0 {
+			msg := "🆗 There are no failed tracks to retry."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if !ensureFreeSpace(logger, outbox, jn, chatID, sendOpt) {
+			return nil
+		}
+
+		ctx, ok := worker.TryAcquireJob(ctx)
+		if !ok {
+			msg := "🈵 Another download is in progress. Try again later."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+		defer worker.ReleaseJob()
+
+		links := lo.Map(trackIDs, func(id string, _ int) types.Link { return types.Link{Kind: types.LinkKindTrack, ID: id} })
+		header := "🔁 Retrying the failed tracks of " + entry.LinkKind + " `" + entry.LinkID + "`:"
+		linkLines := lo.Map(links, func(link types.Link, _ int) string { return link.Kind.String() + ": `" + link.ID + "`" })
+		outbox.Send(chatID, strings.Join(append([]string{header}, linkLines...), "\n"), sendOpt)
+
+		opts := telegram.UploadOptions{} //nolint:exhaustruct
+		if ok := processLinks(ctx, logger, outbox, bus, src, up, nil, store, u.EffectiveSender.Id(), chatID, sendOpt, links, opts, jobModeDownload); !ok {
+			return nil
+		}
+
+		outbox.Send(chatID, "✅ Failed tracks were successfully uploaded.", sendOpt)
+
+		return nil
+	}
+}
+
+// NewWatchCommandHandler adds the given playlist or mix links to the watchlist, or lists
+// the watched links if none is given.
+func NewWatchCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	watcher *Watcher,
+	outbox *Outbox,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		links := extractMessageLinks(u.EffectiveMessage)
+		if len(links) == 0 {
+			watched, err := watcher.Links()
+			if nil != err {
+				logger.Error().Err(err).Msg("Failed to get watched links")
+				outbox.Send(chatID, "❌ Failed to get watched links. Insult logs for details.", sendOpt)
+
+				return nil
+			}
+
+			if len(watched) == 0 {
+				msg := "📭 No links are watched. Usage: `/" + watchCommand + " <playlist or mix URL>`"
+				outbox.Send(chatID, msg, sendOpt)
+
+				return nil
+			}
+
+			lines := make([]string, 0, len(watched)+1)
+			lines = append(lines, "👀 Watched links:")
+			for _, link := range watched {
+				lines = append(lines, link.Kind.String()+": `"+link.ID+"`")
+			}
+			outbox.Send(chatID, strings.Join(lines, "\n"), sendOpt)
+
+			return nil
+		}
+
+		lines := make([]string, 0, len(links))
+		for _, link := range links {
+			target := link.Kind.String() + " `" + link.ID + "`"
+
+			added, err := watcher.Add(link)
+			switch {
+			case errors.Is(err, ErrUnsupportedWatchLink):
+				lines = append(lines, "🈲 "+target+" cannot be watched. Only playlist and mix links can be watched.")
+			case nil != err:
+				logger.Error().Err(err).Str("link_id", link.ID).Msg("Failed to add link to watchlist")
+				lines = append(lines, "❌ Failed to watch "+target+". Insult logs for details.")
+			case !added:
+				lines = append(lines, "🆗 "+target+" is already watched.")
+			default:
+				lines = append(lines, "👀 "+target+" is now watched.")
+			}
+		}
+		outbox.Send(chatID, strings.Join(lines, "\n"), sendOpt)
+
+		return nil
+	}
+}
+
+// NewUnwatchCommandHandler removes the given links from the watchlist.
+func NewUnwatchCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	watcher *Watcher,
+	outbox *Outbox,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		links := extractMessageLinks(u.EffectiveMessage)
+		if len(links) == 0 {
+			msg := "Usage: `/" + unwatchCommand + " <playlist or mix URL>`"
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		lines := make([]string, 0, len(links))
+		for _, link := range links {
+			target := link.Kind.String() + " `" + link.ID + "`"
+
+			if watcher.IsConfigured(link) {
+				lines = append(lines, "🈲 "+target+" is watched via config file and cannot be unwatched using command.")
+				continue
+			}
+
+			removed, err := watcher.Remove(link)
+			switch {
+			case nil != err:
+				logger.Error().Err(err).Str("link_id", link.ID).Msg("Failed to remove link from watchlist")
+				lines = append(lines, "❌ Failed to unwatch "+target+". Insult logs for details.")
+			case !removed:
+				lines = append(lines, "🆗 "+target+" is not watched.")
+			default:
+				lines = append(lines, "🙈 "+target+" is no longer watched.")
+			}
+		}
+		outbox.Send(chatID, strings.Join(lines, "\n"), sendOpt)
+
+		return nil
+	}
+}
+
+// ensureFreeSpace replies to chatID and returns false if there is not enough disk space to start a new job.
+func ensureFreeSpace(
+	logger zerolog.Logger,
+	outbox *Outbox,
+	jn *janitor.Janitor,
+	chatID int64,
+	sendOpt *gotgbot.SendMessageOpts,
+) bool {
+	err := jn.CheckFreeSpace()
+	if nil == err {
+		return true
+	}
+
+	if errors.Is(err, janitor.ErrLowDiskSpace) {
+		outbox.Send(chatID, "💾 Not enough disk space to start a new download. Try again after cleanup.", sendOpt)
+		return false
+	}
+
+	logger.Error().Err(err).Msg("Failed to check free disk space")
+
+	return true
+}
+
+// processLinks processes links one after another, recording an audit entry for each of them.
+// It stops at the first link that did not succeed, in which case it returns false.
+func processLinks(
+	ctx context.Context,
+	logger zerolog.Logger,
+	outbox *Outbox,
+	bus *events.Bus,
+	src provider.Provider,
+	up *telegram.Uploader,
+	exp *exporter,
+	store *audit.Store,
+	userID int64,
+	chatID int64,
+	sendOpt *gotgbot.SendMessageOpts,
+	links []types.Link,
+	opts telegram.UploadOptions,
+	mode jobMode,
+) bool {
+	// Tracks of the links are deduplicated by ISRC across the whole job.
+	ctx = tidal.WithJob(ctx)
+	// jobOutcome is the outcome of the last processed link, as processing stops at the first unsuccessful one.
+	jobOutcome := audit.OutcomeSucceeded
+	bus.Publish(events.Event{ //nolint:exhaustruct
+		Kind:   events.KindJobStarted,
+		ChatID: chatID,
+		Links:  lo.Map(links, func(link types.Link, _ int) string { return link.URL() }),
+	})
+	defer func() {
+		bus.Publish(events.Event{Kind: events.KindJobFinished, ChatID: chatID, Outcome: string(jobOutcome)}) //nolint:exhaustruct
+	}()
+
+	for i, link := range links {
+		time.Sleep(time.Duration(i) * time.Second)
+
+		var cause error
+		jobOutcome, cause = processRetriedLink(ctx, logger, outbox, bus, src, up, exp, store, userID, chatID, sendOpt, link, opts, mode)
+		if jobOutcome != audit.OutcomeSucceeded {
+			if errors.Is(cause, telegram.ErrUnauthorized) {
+				savePendingJob(ctx, logger, src.DownloadsDir(), userID, chatID, links[i:], opts, mode)
+			}
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// processAuditedLink processes link, publishing its events, and recording its audit entry. It returns the
+// outcome of link, and its failure, if any.
+func processAuditedLink(
+	ctx context.Context,
+	logger zerolog.Logger,
+	outbox *Outbox,
+	bus *events.Bus,
+	src provider.Provider,
+	up *telegram.Uploader,
+	exp *exporter,
+	store *audit.Store,
+	userID int64,
+	chatID int64,
+	sendOpt *gotgbot.SendMessageOpts,
+	link types.Link,
+	opts telegram.UploadOptions,
+	mode jobMode,
+) (audit.Outcome, error) {
+	bus.Publish(events.Event{Kind: events.KindLinkStarted, ChatID: chatID, Link: link.URL()}) //nolint:exhaustruct
+
+	entry := audit.NewEntry(link, userID, chatID)
+	entry.DownloadOnly = mode == jobModeDownloadOnly
+	outcome, cause := processLink(ctx, logger, outbox, src, up, exp, store, &entry, chatID, sendOpt, link, opts, mode)
+	entry.Outcome = outcome
+	entry.Duration = time.Since(entry.StartedAt)
+	if nil != cause {
+		entry.Error = cause.Error()
+	}
+	bus.Publish(events.Event{ //nolint:exhaustruct
+		Kind:    events.KindLinkFinished,
+		ChatID:  chatID,
+		Link:    link.URL(),
+		Outcome: string(outcome),
+		Error:   entry.Error,
+	})
+	if outcome == audit.OutcomeSucceeded {
+		if trackIDs, err := src.DownloadsDir().TrackIDs(link); nil != err {
+			logger.Error().Err(err).Msg("Failed to read link track IDs")
+		} else {
+			entry.TrackCount = len(trackIDs)
+			entry.TrackIDs = trackIDs
+		}
+		if size, err := src.DownloadsDir().LinkSize(link); nil != err {
+			logger.Error().Err(err).Msg("Failed to compute link size")
+		} else {
+			entry.Bytes = size
+		}
+		if failed, err := src.DownloadsDir().FailedTracks(link); nil != err {
+			logger.Error().Err(err).Msg("Failed to read skipped failed tracks")
+		} else if len(failed) > 0 {
+			entry.FailedTrackIDs = lo.Map(failed, func(t types.StoredFailedTrack, _ int) string { return t.ID })
+			outbox.Send(chatID, failedTracksText(link, failed), sendOpt)
+		}
+	}
+	if err := store.Record(entry); nil != err {
+		logger.Error().Err(err).Msg("Failed to record job audit entry")
+	}
+
+	return outcome, cause
+}
+
+// filteredTracksText lists the tracks of link that were skipped by the configured filters, if any, with the
+// reasons they were skipped.
+func filteredTracksText(logger zerolog.Logger, src provider.Provider, link types.Link) string {
+	filtered, err := src.DownloadsDir().FilteredTracks(link)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to read filtered tracks")
+		return ""
+	} else if len(filtered) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(filtered)+1)
+	lines = append(lines, "\n\n⏭ "+strconv.Itoa(len(filtered))+" track(s) were skipped by filters:")
+	for _, t := range filtered {
+		lines = append(lines, "`"+t.ID+"` "+escapeMarkdown(t.Title)+": "+escapeMarkdown(t.Reason))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// markdownEscaper escapes the entities of legacy Markdown, e.g., in track titles.
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// escapeMarkdown escapes s to be shown as is in legacy Markdown messages.
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// failedTracksText lists the tracks of link that failed to download, and were skipped, with the stages, and the
+// codes of their failures.
+func failedTracksText(link types.Link, failed []types.StoredFailedTrack) string {
+	lines := make([]string, 0, len(failed)+3)
+	lines = append(lines, "⚠️ "+strconv.Itoa(len(failed))+" track(s) of "+link.Kind.String()+" `"+link.ID+"` failed to download, and were skipped:")
+	for _, t := range failed {
+		lines = append(lines, "`"+t.ID+"` at *"+t.Stage+"* stage: `"+t.Code+"`")
+	}
+	lines = append(lines, "", "Use /"+retryFailedCommand+" to retry them.")
+
+	return strings.Join(lines, "\n")
+}
+
+// processLink downloads and uploads a single link, reporting progress and failures to chatID.
+// The upload can be customized using opts, e.g., to upload to another destination than the configured peers.
+// Links of download only jobs are exported using exp instead of being uploaded.
+// The links of the uploaded channel posts are set in entry.
+// The returned error holds the download or upload failure, if any.
+func processLink(
+	ctx context.Context,
+	logger zerolog.Logger,
+	outbox *Outbox,
+	src provider.Provider,
+	up *telegram.Uploader,
+	exp *exporter,
+	store *audit.Store,
+	entry *audit.Entry,
+	chatID int64,
+	sendOpt *gotgbot.SendMessageOpts,
+	link types.Link,
+	opts telegram.UploadOptions,
+	mode jobMode,
+) (audit.Outcome, error) {
+	status := outbox.NewStatus(chatID, "🚧 Downloading "+link.Kind.String()+" `"+link.ID+"`...", sendOpt)
+	opts.Oversized = new(telegram.OversizedTracks)
+
+	logger.Debug().Str("link_id", link.ID).Str("link_kind", link.Kind.String()).Msg("Parsed link")
+	var (
+		newTrackIDs []string
+		dlErr       error
+		// pipelined is the upload started while the link is downloaded, if any.
+		pipelined *pipelinedUpload
+	)
+	switch mode {
+	case jobModeSync:
+		newTrackIDs, dlErr = src.TrySyncLink(ctx, logger, link)
+	case jobModeUpdate:
+		uploadedIDs, err := store.UploadedTrackIDs(link)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to read uploaded album tracks")
+			msg := "❌ Failed to read uploaded tracks of " + link.Kind.String() + " `" + link.ID + "`. Insult logs for details."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeFailed, err
+		}
+
+		if len(uploadedIDs) == 0 && link.Kind == types.LinkKindAlbum {
+			msg := "🈲 No uploaded tracks of album `" + link.ID + "` are recorded. Send its link to upload it first."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, errNoUploadedTracks
+		}
+
+		newTrackIDs, dlErr = src.TryUpdateAlbum(ctx, logger, link, uploadedIDs)
+	default:
+		if mode != jobModeDownloadOnly && up.Streams(link, opts) {
+			status.Update("🚧 Downloading " + link.Kind.String() + " `" + link.ID + "`, and streaming it to Telegram...")
+			stream := pipeline.NewTrack()
+			opts.Stream = stream
+			pipelined = startPipelinedUpload(ctx, logger, src, up, link, opts)
+			defer pipelined.wait()
+
+			dlErr = src.TryDownloadLink(tidal.WithTrackStream(ctx, stream), logger, link)
+			stream.Finish(dlErr)
+			break
+		}
+
+		if mode == jobModeDownloadOnly || !up.Pipelines(link, opts) {
+			dlErr = src.TryDownloadLink(ctx, logger, link)
+			break
+		}
+
+		status.Update("🚧 Downloading " + link.Kind.String() + " `" + link.ID + "`, and uploading its downloaded tracks to Telegram...")
+		pipe := pipeline.NewAlbum()
+		opts.Pipeline = pipe
+		pipelined = startPipelinedUpload(ctx, logger, src, up, link, opts)
+		// The upload fails as soon as it waits for more tracks if the download fails, and it must not outlive
+		// the job either way.
+		defer pipelined.wait()
+
+		dlErr = src.TryDownloadLink(tidal.WithPipeline(ctx, pipe), logger, link)
+		pipe.Finish(dlErr)
+	}
+	if nil != dlErr {
+		if errors.Is(dlErr, context.DeadlineExceeded) {
+			msg := "⌛️ Download request timed out. You might need to increase the timeout."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeTimedOut, dlErr
+		}
+
+		if errors.Is(dlErr, context.Canceled) {
+			if cause := context.Cause(ctx); errors.Is(cause, ErrJobCanceled) {
+				msg := "⏹️ Download was canceled."
+				outbox.Send(chatID, msg, sendOpt)
+
+				return audit.OutcomeCanceled, dlErr
+			}
+
+			msg := "♿️ Bot is shutting down. Download was not completed. Try again after bot restart."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeShutdown, dlErr
+		}
+
+		if errors.Is(dlErr, tidal.ErrLoginRequired) {
+			msg := "🔑 Tidal login required. Use /" + tidalLoginCommand + " command to authorize the bot."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, dlErr
+		}
+
+		if errors.Is(dlErr, tidal.ErrTokenRefreshed) {
+			msg := "🔄 Tidal login token just got refreshed. Retry in a few seconds."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, dlErr
+		}
+
+		if errors.Is(dlErr, tidal.ErrUnsupportedArtistLinkKind) {
+			msg := "🈲 Artist links are not supported yet."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, dlErr
+		}
+
+		if errors.Is(dlErr, tidal.ErrUnsupportedVideoLinkKind) {
+			msg := "🈲 Video links are not supported yet."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, dlErr
+		}
+
+		if errors.Is(dlErr, tidal.ErrUnsupportedSyncLinkKind) {
+			msg := "🈲 Only playlist and mix links can be synced."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, dlErr
+		}
+
+		if errors.Is(dlErr, tidal.ErrUnsupportedUpdateLinkKind) {
+			msg := "🈲 Only album links can be updated."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, dlErr
+		}
+
+		var spatialErr *tidal.SpatialAudioOnlyError
+		if errors.As(dlErr, &spatialErr) {
+			msg := "🎧 Track `" + spatialErr.TrackID + "` of " + link.Kind.String() + " `" + link.ID + "` is only available in " +
+				spatialErr.AudioMode + " audio mode, which cannot be downloaded."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeRejected, dlErr
+		}
+
+		var metaErr *tidal.MissingMetadataError
+		if errors.As(dlErr, &metaErr) {
+			msg := "🧾 Track `" + metaErr.TrackID + "` of " + link.Kind.String() + " `" + link.ID + "` is missing " +
+				strings.Join(metaErr.Missing, ", ") + ". Nothing was uploaded, as strict metadata is enabled."
+			outbox.Send(chatID, msg, sendOpt)
+
+			logger.Error().Err(dlErr).Msg("Track is missing metadata in strict metadata mode")
+
+			return audit.OutcomeFailed, dlErr
+		}
+
+		// Whether the link is retried, or the job fails, is told by processRetriedLink.
+		var rateErr *tidal.RateLimitedError
+		if errors.As(dlErr, &rateErr) {
+			return audit.OutcomeFailed, dlErr
+		}
+
+		msg := downloadFailureHeadline(link, dlErr) + "\n\n" + errorCodeLine(dlErr)
+		outbox.Send(chatID, msg, sendOpt)
+
+		logger.Error().Err(dlErr).Str("error_code", errs.Classify(dlErr).Code()).Msg("failed to download link")
+
+		return audit.OutcomeFailed, dlErr
+	}
+
+	if mode == jobModeSync && len(newTrackIDs) == 0 {
+		status.Update("🆗 Tidal " + link.Kind.String() + " `" + link.ID + "` has no new tracks since the last sync.")
+
+		return audit.OutcomeSucceeded, nil
+	}
+
+	if mode == jobModeUpdate && len(newTrackIDs) == 0 {
+		status.Update("🆗 Tidal " + link.Kind.String() + " `" + link.ID + "` has no new tracks since it was uploaded.")
+
+		return audit.OutcomeSucceeded, nil
+	}
+
+	if mode == jobModeDownloadOnly {
+		exported, err := exp.export(ctx, logger, link)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to export downloaded link")
+			msg := "❌ Tidal " + link.Kind.String() + " `" + link.ID + "` was downloaded, but exporting it failed. Insult logs for details."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeFailed, err
+		}
+
+		msg := "✅ Tidal " + link.Kind.String() + " `" + link.ID + "` was successfully downloaded."
+		if exported > 0 {
+			msg += " " + strconv.Itoa(exported) + " track(s) were exported."
+		}
+		msg += filteredTracksText(logger, src, link)
+		status.Update(msg)
+
+		return audit.OutcomeSucceeded, nil
+	}
+
+	status.Update("📤 Tidal " + link.Kind.String() + " `" + link.ID + "` downloaded. Uploading to Telegram...")
+
+	var (
+		postURLs []string
+		upErr    error
+	)
+	if nil != pipelined {
+		postURLs, upErr = pipelined.wait()
+	} else {
+		postURLs, upErr = up.Upload(ctx, logger, src.DownloadsDir(), link, opts)
+	}
+	if nil != upErr {
+		if errors.Is(upErr, context.DeadlineExceeded) {
+			msg := "⌛️ Upload request timed out. You might need to increase the timeout."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeTimedOut, upErr
+		}
+
+		if errors.Is(upErr, context.Canceled) {
+			if cause := context.Cause(ctx); errors.Is(cause, ErrJobCanceled) {
+				msg := "⏹️ Upload was canceled."
+				outbox.Send(chatID, msg, sendOpt)
+
+				return audit.OutcomeCanceled, upErr
+			}
+
+			msg := "♿️ Bot is shutting down. Upload was not completed. Try again after bot restart."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeShutdown, upErr
+		}
+
+		if errors.Is(upErr, telegram.ErrUnauthorized) {
+			msg := "🔑 Telegram session was revoked or expired. Upload was not completed. " +
+				"The job is resumed once I'm logged in to Telegram again."
+			outbox.Send(chatID, msg, sendOpt)
+
+			logger.Error().Err(upErr).Msg("Telegram session was lost during upload")
+
+			return audit.OutcomeFailed, upErr
+		}
+
+		msg := uploadFailureHeadline(link, upErr) + "\n\n" + errorCodeLine(upErr)
+		outbox.Send(chatID, msg, sendOpt)
+
+		logger.Error().Err(upErr).Str("error_code", errs.Classify(upErr).Code()).Msg("failed to upload to Telegram")
+
+		return audit.OutcomeFailed, upErr
+	}
+
+	entry.PostURLs = postURLs
+
+	if mode == jobModeSync {
+		if err := src.MarkSynced(link, newTrackIDs); nil != err {
+			logger.Error().Err(err).Msg("Failed to save sync state")
+
+			msg := "⚠️ Tidal " + link.Kind.String() + " `" + link.ID + "` was uploaded, but saving its sync state failed. " +
+				"Next sync will upload the same tracks again."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return audit.OutcomeSucceeded, nil
+		}
+	}
+
+	msg := "✅ Tidal " + link.Kind.String() + " `" + link.ID + "` was successfully uploaded."
+	if duplicateIDs, err := src.DownloadsDir().DuplicateTrackIDs(link); nil != err {
+		logger.Error().Err(err).Msg("Failed to read skipped duplicate tracks")
+	} else if n := len(duplicateIDs); n > 0 {
+		msg += " " + strconv.Itoa(n) + " duplicate track occurrence(s) were skipped."
+	}
+	msg += oversizedTracksText(opts.Oversized)
+	msg += filteredTracksText(logger, src, link)
+	links := postLinksText(postURLs)
+	if len(links) > 0 {
+		msg += "\n\n" + links
+	}
+	status.Update(msg)
+	if len(links) > 0 {
+		// The links are not part of the summary of cleaned up jobs, so the status message is kept.
+		status.Keep()
+	}
+
+	return audit.OutcomeSucceeded, nil
+}
+
+// pipelinedUpload is an upload that runs while its link is still being downloaded.
+type pipelinedUpload struct {
+	done     chan struct{}
+	postURLs []string
+	err      error
+}
+
+func startPipelinedUpload(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	up *telegram.Uploader,
+	link types.Link,
+	opts telegram.UploadOptions,
+) *pipelinedUpload {
+	p := &pipelinedUpload{done: make(chan struct{}), postURLs: nil, err: nil}
+	go func() {
+		defer close(p.done)
+		p.postURLs, p.err = up.Upload(ctx, logger, src.DownloadsDir(), link, opts)
+	}()
+
+	return p
+}
+
+// wait waits for the upload to finish, and returns its result.
+func (p *pipelinedUpload) wait() ([]string, error) {
+	<-p.done
+	return p.postURLs, p.err
+}
+
+// postLinksText lists the links of the uploaded channel posts, one per line. Links are labeled, rather than
+// sent as is, so that underscores in channel usernames are not parsed as Markdown.
+func postLinksText(urls []string) string {
+	lines := make([]string, len(urls))
+	for i, url := range urls {
+		lines[i] = "🔗 [Post " + strconv.Itoa(i+1) + "](" + url + ")"
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// errorCodeLine describes err to users as the code and message of its category. The full error is only
+// logged, as it might be long, and is not actionable for users.
+func errorCodeLine(err error) string {
+	category := errs.Classify(err)
+
+	return "🏷️ `" + category.Code() + "`: " + category.Message()
+}
+
+// downloadFailureHeadline describes which Tidal stage failed, and for which track if the failure was specific to one.
+func downloadFailureHeadline(link types.Link, err error) string {
+	target := link.Kind.String() + " `" + link.ID + "`"
+
+	var stageErr *tidal.StageError
+	if !errors.As(err, &stageErr) {
+		return "❌ Tidal failed while downloading " + target + ". Insult logs for details."
+	}
+
+	msg := "❌ Tidal failed at *" + string(stageErr.Stage) + "* stage while downloading " + target + "."
+	if len(stageErr.TrackID) > 0 {
+		msg += " Affected track: `" + stageErr.TrackID + "`."
+	}
+
+	return msg + " Insult logs for details."
+}
+
+// uploadFailureHeadline describes a Telegram upload failure along with the tracks it affected, if known.
+func uploadFailureHeadline(link types.Link, err error) string {
+	target := link.Kind.String() + " `" + link.ID + "`"
+
+	if errors.Is(err, telegram.ErrTrackTooLarge) {
+		return "❌ A track of " + target + " is larger than the max upload file size. " +
+			"Change the oversized tracks strategy to skip, transcode, or split such tracks."
+	}
+
+	var uploadErr *telegram.UploadError
+	if !errors.As(err, &uploadErr) || len(uploadErr.TrackIDs) == 0 {
+		return "❌ Telegram failed at *upload* stage for " + target + ". Insult logs for details."
+	}
+
+	trackIDs := make([]string, len(uploadErr.TrackIDs))
+	for i, id := range uploadErr.TrackIDs {
+		trackIDs[i] = "`" + id + "`"
+	}
+
+	return "❌ Telegram failed at *upload* stage for " + target + ". Affected tracks: " + strings.Join(trackIDs, ", ") + ". Insult logs for details."
+}
+
+// oversizedTracksText returns the summary of the oversized tracks of an upload, to append to its success message.
+func oversizedTracksText(oversized *telegram.OversizedTracks) string {
+	var text string
+	if n := len(oversized.Skipped); n > 0 {
+		text += " " + strconv.Itoa(n) + " track(s) larger than the max upload file size were skipped."
+	}
+	if n := len(oversized.Transcoded); n > 0 {
+		text += " " + strconv.Itoa(n) + " track(s) larger than the max upload file size were transcoded to 16-bit."
+	}
+	if n := len(oversized.Split); n > 0 {
+		text += " " + strconv.Itoa(n) + " track(s) larger than the max upload file size were split into parts."
+	}
+
+	return text
+}
+
+func NewHelloCommandHandler(ctx context.Context, papaID int64, mamaID int64, outbox *Outbox) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		senderID := u.EffectiveSender.Id()
+		chatID := u.EffectiveMessage.Chat.Id
+
+		if senderID == papaID {
+			outbox.Send(chatID, "Hello, papa! 👋🏻", sendOpt)
+
+			return nil
+		}
+
+		if senderID == mamaID {
+			outbox.Send(chatID, "Hello, mama! 👋🏻", sendOpt)
+
+			return nil
+		}
+
+		outbox.Send(chatID, "Hello! 👋🏻", sendOpt)
+
+		return nil
+	}
+}
+
+func NewCancelCommandHandler(ctx context.Context, worker *Worker, outbox *Outbox) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		worker.CancelJob()
+
+		outbox.Send(chatID, "Cancel request sent.", sendOpt)
+
+		return nil
+	}
+}
+
+func NewPauseCommandHandler(ctx context.Context, worker *Worker, outbox *Outbox) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
 
-				return err
-			}
+		msg := "⏸️ Paused. The running job stops before its next track, and new jobs wait until /resume."
+		if !worker.PauseJob() {
+			msg = "Already paused. Send /resume to continue."
 		}
 
-		return ext.ContinueGroups
+		outbox.Send(chatID, msg, sendOpt)
+
+		return nil
 	}
 }
 
-func NewPapaOrMamaOnlyGuard(papaID int64, mamaID int64) handlers.Response {
+func NewResumeCommandHandler(ctx context.Context, worker *Worker, outbox *Outbox) handlers.Response {
 	return func(b *gotgbot.Bot, u *ext.Context) error {
-		senderID := u.EffectiveSender.Id()
-		if senderID != papaID && senderID != mamaID {
-			return ErrNotPapaOrMama
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
 		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		msg := "▶️ Resumed."
+		if !worker.ResumeJob() {
+			msg = "Not paused."
+		}
+
+		outbox.Send(chatID, msg, sendOpt)
 
 		return nil
 	}
 }
 
-func NewTidalURLHandler(
+// NewMaintenanceCommandHandler handles the maintenance command, which turns maintenance mode on or off, or
+// reports whether it is on.
+func NewMaintenanceCommandHandler(
 	ctx context.Context,
 	logger zerolog.Logger,
-	td *tidal.Client,
-	conf config.Bot,
-	up *telegram.Uploader,
+	maintenance *Maintenance,
 	worker *Worker,
+	outbox *Outbox,
 ) handlers.Response {
 	return func(b *gotgbot.Bot, u *ext.Context) error {
-		logger = logger.
-			With().
-			Int64("chat_id", u.EffectiveMessage.Chat.Id).
-			Int64("message_id", u.EffectiveMessage.MessageId).
-			Int64("sender_id", u.EffectiveSender.Id()).
-			Logger()
-
-		msgID := u.EffectiveMessage.MessageId
 		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
 			ParseMode: gotgbot.ParseModeMarkdown,
 			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
-				MessageId: msgID,
+				MessageId: u.EffectiveMessage.MessageId,
 			},
 		}
 		chatID := u.EffectiveMessage.Chat.Id
 
-		ctx, ok := worker.TryAcquireJob(ctx)
-		if !ok {
-			msg := "🈵 Another download is in progress. Try again later."
-			if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
+		var msg string
+		switch args := strings.Fields(u.EffectiveMessage.Text)[1:]; {
+		case len(args) == 0:
+			if state := maintenance.State(); state.Enabled {
+				msg = "🛠️ Maintenance mode is on since " + state.Since.Format(time.DateTime) + " UTC."
+			} else {
+				msg = "Maintenance mode is off."
+			}
+		case len(args) == 1 && (args[0] == "on" || args[0] == "off"):
+			enabled := args[0] == "on"
+			changed, err := maintenance.Set(enabled)
+			if nil != err {
+				logger.Error().Err(err).Bool("enabled", enabled).Msg("Failed to set maintenance mode")
+				msg = "❌ Failed to set maintenance mode."
+			} else if !changed {
+				msg = "Maintenance mode is already " + args[0] + "."
+			} else if enabled {
+				logger.Warn().Msg("Maintenance mode turned on")
+				msg = "🛠️ Maintenance mode is on. New links are declined until `/" + maintenanceCommand + " off`."
+				if worker.Busy() {
+					msg += " The running job is left to finish."
+				}
+			} else {
+				logger.Info().Msg("Maintenance mode turned off")
+				msg = "✅ Maintenance mode is off. New links are accepted again."
 			}
+		default:
+			msg = "Usage: `/" + maintenanceCommand + " [on|off]`"
+		}
 
-			return nil
+		outbox.Send(chatID, msg, sendOpt)
+
+		return nil
+	}
+}
+
+func NewReloadCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	reloader *Reloader,
+	worker *Worker,
+	outbox *Outbox,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
 		}
-		defer worker.ReleaseJob()
+		chatID := u.EffectiveMessage.Chat.Id
 
-		links := extractMessageLinks(u.EffectiveMessage)
-		if len(links) == 0 {
-			return nil
+		if worker.Busy() {
+			outbox.Send(chatID, "⏳ Config will be reloaded once the running job finishes.", sendOpt)
 		}
 
-		msg := strings.Join(
-			append(
-				[]string{"🚧 Downloading links:"},
-				lo.Map(links, func(link types.Link, _ int) string {
-					return link.Kind.String() + ": `" + link.ID + "`"
-				})...,
-			),
-			"\n",
-		)
-		if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-			return fmt.Errorf("send message: %w", err)
+		var msg string
+		if restart, err := reloader.Reload(ctx); nil != err {
+			logger.Error().Err(err).Msg("Failed to reload config")
+			msg = "❌ Failed to reload config. Please check the logs."
+		} else if len(restart) > 0 {
+			sections := lo.Map(restart, func(s string, _ int) string { return "`" + s + "`" })
+			msg = "✅ Config reloaded, and applies to new jobs. Changes of " + strings.Join(sections, ", ") +
+				" options other than the Tidal downloader and Telegram upload ones require a restart."
+		} else {
+			msg = "✅ Config reloaded, and applies to new jobs."
 		}
 
-		for i, link := range links {
-			time.Sleep(time.Duration(i) * time.Second)
+		outbox.Send(chatID, msg, sendOpt)
+
+		return nil
+	}
+}
+
+func NewHistoryCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	conf config.BotAudit,
+	store *audit.Store,
+	localAPI bool,
+	outbox *Outbox,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
 
-			msg := "🚧 Downloading " + link.Kind.String() + " `" + link.ID + "`..."
-			if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
+		limit := conf.HistoryLimit
+		if args := strings.Fields(u.EffectiveMessage.Text); len(args) > 1 {
+			if args[1] == historyExportArg {
+				return sendHistoryExport(ctx, logger, b, localAPI, store, chatID, u.EffectiveMessage.MessageId)
 			}
 
-			logger.Debug().Str("link_id", link.ID).Str("link_kind", link.Kind.String()).Msg("Parsed link")
-			if err := td.TryDownloadLink(ctx, logger, link); nil != err {
-				if errors.Is(err, context.DeadlineExceeded) {
-					msg := "⌛️ Download request timed out. You might need to increase the timeout."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+			n, err := strconv.Atoi(args[1])
+			if nil != err || n <= 0 {
+				msg := "🤨 Usage: /" + historyCommand + " `[count|" + historyExportArg + "]`"
+				outbox.Send(chatID, msg, sendOpt)
 
-					return nil
-				}
+				return nil
+			}
+			limit = min(n, maxHistoryLimit)
+		}
 
-				if errors.Is(err, context.Canceled) {
-					if cause := context.Cause(ctx); errors.Is(cause, ErrJobCanceled) {
-						msg := "⏹️ Download was canceled."
-						if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-							return fmt.Errorf("send message: %w", err)
-						}
+		entries, err := store.Last(limit)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to read audit entries")
+			return fmt.Errorf("read audit entries: %v", err)
+		}
 
-						return nil
-					}
+		if len(entries) == 0 {
+			outbox.Send(chatID, "📭 No jobs recorded yet.", sendOpt)
 
-					msg := "♿️ Bot is shutting down. Download was not completed. Try again after bot restart."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+			return nil
+		}
 
-					return nil
-				}
+		lines := make([]string, 0, len(entries)+1)
+		lines = append(lines, "🗂️ Last "+strconv.Itoa(len(entries))+" jobs:")
+		for _, e := range entries {
+			lines = append(lines, formatAuditEntry(e))
+		}
 
-				if errors.Is(err, tidal.ErrLoginRequired) {
-					msg := "🔑 Tidal login required. Use /" + tidalLoginCommand + " command to authorize the bot."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+		outbox.Send(chatID, strings.Join(lines, "\n"), sendOpt)
 
-					return nil
-				}
+		return nil
+	}
+}
 
-				if errors.Is(err, tidal.ErrTokenRefreshed) {
-					msg := "🔄 Tidal login token just got refreshed. Retry in a few seconds."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+// NewDebugCommandHandler sends the debug bundle of a track that failed to be tagged in a recorded job, as
+// listed by the history command.
+func NewDebugCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	store *audit.Store,
+	localAPI bool,
+	outbox *Outbox,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
 
-					return nil
-				}
+		reply := func(msg string) error {
+			outbox.Send(chatID, msg, sendOpt)
 
-				if errors.Is(err, tidal.ErrUnsupportedArtistLinkKind) {
-					msg := "🈲 Artist links are not supported yet."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+			return nil
+		}
 
-					return nil
-				}
+		args := strings.Fields(u.EffectiveMessage.Text)
+		if len(args) != 3 {
+			return reply("🤨 Usage: /" + debugCommand + " `<job-id> <track-id>`")
+		}
 
-				if errors.Is(err, tidal.ErrUnsupportedVideoLinkKind) {
-					msg := "🈲 Video links are not supported yet."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+		jobID, err := strconv.ParseUint(strings.TrimPrefix(args[1], "#"), 10, 64)
+		if nil != err {
+			return reply("🤨 Usage: /" + debugCommand + " `<job-id> <track-id>`")
+		}
+		trackID := args[2]
 
-					return nil
-				}
+		entry, err := store.Get(jobID)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to read audit entry")
+			return fmt.Errorf("read audit entry: %v", err)
+		}
+		if nil == entry {
+			return reply("🤷 Job `#" + strconv.FormatUint(jobID, 10) + "` was not found.")
+		}
 
-				msg := strings.Join(
-					[]string{
-						"❌ Failed to download " + link.Kind.String() + " `" + link.ID + "`. Insult logs for details.",
-						"",
-						codeBlockOpenTxt,
-						err.Error(),
-						codeBlockClose,
-					},
-					"\n",
-				)
-				if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-					return fmt.Errorf("send message: %w", err)
-				}
+		kind, ok := types.ParseLinkKind(entry.LinkKind)
+		if !ok {
+			return reply("🤷 Job `#" + strconv.FormatUint(jobID, 10) + "` has an unknown link kind.")
+		}
 
-				logger.Error().Err(err).Msg("failed to download link")
+		bundle := src.DownloadsDir().DebugBundle(types.Link{Kind: kind, ID: entry.LinkID}, trackID)
+		if exists, err := bundle.Exists(); nil != err {
+			logger.Error().Err(err).Msg("Failed to check if debug bundle exists")
+			return fmt.Errorf("check if debug bundle exists: %v", err)
+		} else if !exists {
+			return reply("🤷 No debug bundle for track `" + trackID + "` of job `#" + strconv.FormatUint(jobID, 10) + "`.")
+		}
 
-				return nil
-			}
+		files, err := debugBundleFiles(bundle.Path)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to read debug bundle")
+			return fmt.Errorf("read debug bundle: %v", err)
+		}
 
-			msg = "📤 Tidal " + link.Kind.String() + " `" + link.ID + "` downloaded. Uploading to Telegram..."
-			if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
-			}
+		title := "Debug bundle of job `#" + strconv.FormatUint(jobID, 10) + "` track `" + trackID + "`"
+		name := "tidalgram-debug-" + strconv.FormatUint(jobID, 10) + "-" + trackID
+		if err := sendTransfer(ctx, logger, b, localAPI, chatID, u.EffectiveMessage.MessageId, title, name, files); nil != err {
+			logger.Error().Err(err).Msg("Failed to send debug bundle")
+			return fmt.Errorf("send debug bundle: %w", err)
+		}
 
-			if err := up.Upload(ctx, logger, td.DownloadsDirFs, link); nil != err {
-				if errors.Is(err, context.DeadlineExceeded) {
-					msg := "⌛️ Upload request timed out. You might need to increase the timeout."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+		return nil
+	}
+}
 
-					return nil
-				}
+// debugBundleFiles returns the files stored in the debug bundle ZIP archive at path.
+func debugBundleFiles(path string) (_ []transferFile, err error) {
+	zr, err := zip.OpenReader(path)
+	if nil != err {
+		return nil, fmt.Errorf("open debug bundle: %v", err)
+	}
+	defer func() {
+		if closeErr := zr.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close debug bundle: %v", closeErr))
+		}
+	}()
 
-				if errors.Is(err, context.Canceled) {
-					if cause := context.Cause(ctx); errors.Is(cause, ErrJobCanceled) {
-						msg := "⏹️ Upload was canceled."
-						if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-							return fmt.Errorf("send message: %w", err)
-						}
+	files := make([]transferFile, 0, len(zr.File))
+	for _, f := range zr.File {
+		content, err := readZipFile(f)
+		if nil != err {
+			return nil, fmt.Errorf("read %s entry: %v", f.Name, err)
+		}
 
-						return nil
-					}
+		files = append(files, transferFile{
+			Name:    f.Name,
+			Size:    int64(len(content)),
+			ModTime: f.Modified,
+			Open:    func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(content)), nil },
+		})
+	}
 
-					msg := "♿️ Bot is shutting down. Upload was not completed. Try again after bot restart."
-					if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-						return fmt.Errorf("send message: %w", err)
-					}
+	return files, nil
+}
 
-					return nil
-				}
+func readZipFile(f *zip.File) (_ []byte, err error) {
+	r, err := f.Open()
+	if nil != err {
+		return nil, fmt.Errorf("open entry: %v", err)
+	}
+	defer func() {
+		if closeErr := r.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close entry: %v", closeErr))
+		}
+	}()
 
-				msg := strings.Join(
-					[]string{
-						"❌ Failed to upload to Telegram. Insult logs for details.",
-						"",
-						codeBlockOpenTxt,
-						err.Error(),
-						codeBlockClose,
-					},
-					"\n",
-				)
-				if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-					return fmt.Errorf("send message: %w", err)
-				}
+	b, err := io.ReadAll(r)
+	if nil != err {
+		return nil, fmt.Errorf("read entry: %v", err)
+	}
 
-				logger.Error().Err(err).Msg("failed to upload to Telegram")
+	return b, nil
+}
 
-				return nil
-			}
+// sendHistoryExport sends all recorded jobs to chatID as a compressed CSV document.
+func sendHistoryExport(
+	ctx context.Context,
+	logger zerolog.Logger,
+	b *gotgbot.Bot,
+	localAPI bool,
+	store *audit.Store,
+	chatID int64,
+	replyTo int64,
+) error {
+	entries, err := store.All()
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to read audit entries")
+		return fmt.Errorf("read audit entries: %v", err)
+	}
 
-			msg = "✅ Tidal " + link.Kind.String() + " `" + link.ID + "` was successfully uploaded."
-			if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
-			}
-		}
+	var buf bytes.Buffer
+	if err := audit.WriteCSV(&buf, entries); nil != err {
+		logger.Error().Err(err).Msg("Failed to write audit entries CSV")
+		return fmt.Errorf("write audit entries CSV: %v", err)
+	}
 
-		msg = "✅ Tidal links were successfully uploaded."
-		if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-			return fmt.Errorf("send message: %w", err)
-		}
+	now := time.Now().UTC()
+	content := buf.Bytes()
+	files := []transferFile{
+		{
+			Name:    "tidalgram-history-" + now.Format("20060102") + ".csv",
+			Size:    int64(len(content)),
+			ModTime: now,
+			Open:    func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(content)), nil },
+		},
+	}
+	title := "History of " + strconv.Itoa(len(entries)) + " jobs"
+	if err := sendTransfer(ctx, logger, b, localAPI, chatID, replyTo, title, "tidalgram-history-"+now.Format("20060102"), files); nil != err {
+		logger.Error().Err(err).Msg("Failed to send history export")
+		return fmt.Errorf("send history export: %w", err)
+	}
 
-		return nil
+	return nil
+}
+
+func formatAuditEntry(e audit.Entry) string {
+	line := fmt.Sprintf(
+		"%s `#%d` %s `%s` · %s · %s",
+		e.Outcome.Emoji(),
+		e.ID,
+		e.LinkKind,
+		e.LinkID,
+		e.StartedAt.Format("2006/01/02 15:04"),
+		e.Duration.Round(time.Second).String(),
+	)
+	if e.TrackCount > 0 {
+		line += " · " + strconv.Itoa(e.TrackCount) + " tracks"
 	}
+	if len(e.PostURLs) > 0 {
+		// Only the first post, where the upload starts, is linked to keep entries on a single line.
+		line += " · [🔗 post](" + e.PostURLs[0] + ")"
+	}
+
+	return line
 }
 
-func NewHelloCommandHandler(ctx context.Context, papaID int64, mamaID int64) handlers.Response {
+func NewStatsCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	store *audit.Store,
+	outbox *Outbox,
+) handlers.Response {
 	return func(b *gotgbot.Bot, u *ext.Context) error {
 		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
 			ParseMode: gotgbot.ParseModeMarkdown,
@@ -276,34 +1632,63 @@ func NewHelloCommandHandler(ctx context.Context, papaID int64, mamaID int64) han
 				MessageId: u.EffectiveMessage.MessageId,
 			},
 		}
-		senderID := u.EffectiveSender.Id()
 		chatID := u.EffectiveMessage.Chat.Id
 
-		if senderID == papaID {
-			if _, err := b.SendMessage(chatID, "Hello, papa! 👋🏻", sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
-			}
-
-			return nil
+		stats, err := store.Stats()
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to compute audit stats")
+			return fmt.Errorf("compute audit stats: %v", err)
 		}
 
-		if senderID == mamaID {
-			if _, err := b.SendMessage(chatID, "Hello, mama! 👋🏻", sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
-			}
+		if stats.Jobs == 0 {
+			outbox.Send(chatID, "📭 No jobs recorded yet.", sendOpt)
 
 			return nil
 		}
 
-		if _, err := b.SendMessage(chatID, "Hello! 👋🏻", sendOpt); nil != err {
-			return fmt.Errorf("send message: %w", err)
+		lines := []string{
+			"📊 Jobs since " + stats.FirstAt.Format("2006/01/02") + ":",
+			"",
+			"Total: *" + strconv.Itoa(stats.Jobs) + "*",
+			"Tracks uploaded: *" + strconv.Itoa(stats.Tracks) + "*",
+			"Time spent: *" + stats.TotalDuration.Round(time.Second).String() + "*",
+			"Last job: *" + stats.LastAt.Format("2006/01/02 15:04") + "*",
+			"",
+		}
+		for _, o := range []audit.Outcome{
+			audit.OutcomeSucceeded,
+			audit.OutcomeFailed,
+			audit.OutcomeCanceled,
+			audit.OutcomeTimedOut,
+			audit.OutcomeShutdown,
+			audit.OutcomeRejected,
+		} {
+			if n := stats.ByOutcome[o]; n > 0 {
+				lines = append(lines, o.Emoji()+" "+strconv.Itoa(n))
+			}
+		}
+		lines = append(lines, "")
+		for _, kind := range slices.Sorted(maps.Keys(stats.ByKind)) {
+			lines = append(lines, kind+": "+strconv.Itoa(stats.ByKind[kind]))
 		}
 
+		outbox.Send(chatID, strings.Join(lines, "\n"), sendOpt)
+
 		return nil
 	}
 }
 
-func NewCancelCommandHandler(ctx context.Context, worker *Worker) handlers.Response {
+// NewStatusCommandHandler reports whether a job is running, the maintenance mode, the space reclaimed by the
+// janitor since startup, and the memory used by the covers cache.
+func NewStatusCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	worker *Worker,
+	maintenance *Maintenance,
+	jn *janitor.Janitor,
+	td *tidal.Client,
+	outbox *Outbox,
+) handlers.Response {
 	return func(b *gotgbot.Bot, u *ext.Context) error {
 		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
 			ParseMode: gotgbot.ParseModeMarkdown,
@@ -313,17 +1698,57 @@ func NewCancelCommandHandler(ctx context.Context, worker *Worker) handlers.Respo
 		}
 		chatID := u.EffectiveMessage.Chat.Id
 
-		worker.CancelJob()
+		job := "idle"
+		if worker.Busy() {
+			job = "running"
+		}
 
-		if _, err := b.SendMessage(chatID, "Cancel request sent.", sendOpt); nil != err {
-			return fmt.Errorf("send message: %w", err)
+		mode := "off"
+		if state := maintenance.State(); state.Enabled {
+			mode = "on since " + state.Since.Format("2006/01/02 15:04")
 		}
 
+		stats := jn.Stats()
+		lastRun := "not yet"
+		if !stats.LastRunAt.IsZero() {
+			lastRun = stats.LastRunAt.Format("2006/01/02 15:04")
+		}
+
+		lines := []string{
+			"🩺 Status:",
+			"",
+			"Job: *" + job + "*",
+			"Maintenance mode: *" + mode + "*",
+			"",
+			"🧹 Reclaimed since startup:",
+			"Downloads: *" + formatBytes(stats.DownloadsFreedBytes) + "* (" + strconv.Itoa(stats.PrunedLinks) + " link(s))",
+			"Job artifacts: *" + formatBytes(stats.ArtifactsFreedBytes) + "* (" + strconv.Itoa(stats.PrunedArtifacts) + " file(s))",
+			"Last cleanup: *" + lastRun + "*",
+		}
+		if stats.MirroredLinks > 0 || stats.ExpiredMirroredLinks > 0 {
+			lines = append(lines, "Mirrored links: *"+strconv.Itoa(stats.MirroredLinks)+"* ("+strconv.Itoa(stats.ExpiredMirroredLinks)+" expired)")
+		}
+
+		covers := td.CoverCacheStats()
+		lines = append(
+			lines,
+			"",
+			"🖼 Covers cache: *"+formatBytes(covers.Bytes)+"* of *"+formatBytes(covers.MaxBytes)+"* ("+strconv.Itoa(covers.Entries)+" cover(s))",
+			"Hits: *"+strconv.FormatInt(covers.Hits, 10)+"*, misses: *"+strconv.FormatInt(covers.Misses, 10)+"*, evicted: *"+strconv.FormatInt(covers.Evicted, 10)+"*",
+		)
+
+		outbox.Send(chatID, strings.Join(lines, "\n"), sendOpt)
+
 		return nil
 	}
 }
 
-func NewTidalLoginCommandHandler(ctx context.Context, logger zerolog.Logger, td *tidal.Client) handlers.Response {
+func NewTidalLoginCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	td *tidal.Client,
+	outbox *Outbox,
+) handlers.Response {
 	sem := semaphore.NewWeighted(1)
 
 	return func(b *gotgbot.Bot, u *ext.Context) error {
@@ -344,49 +1769,46 @@ func NewTidalLoginCommandHandler(ctx context.Context, logger zerolog.Logger, td
 
 		if !sem.TryAcquire(1) {
 			msg := "🈵 Another login flow is in progress. Try again later."
-			if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
-			}
+			outbox.Send(chatID, msg, sendOpt)
 
 			return nil
 		}
 		defer sem.Release(1)
 
-		link, wait, err := td.TryInitiateLoginFlow(ctx, logger)
+		name := tidal.PrimaryAccount
+		if args := strings.Fields(u.EffectiveMessage.Text); len(args) > 1 {
+			name = args[1]
+		}
+		logger = logger.With().Str("account", name).Logger()
+
+		link, wait, err := td.TryInitiateLoginFlow(ctx, logger, name)
 		if nil != err {
+			if errors.Is(err, tidal.ErrUnknownAccount) {
+				names := lo.Map(td.Accounts(), func(a tidal.AccountStatus, _ int) string { return "`" + a.Name + "`" })
+				msg := "🤨 Unknown Tidal account `" + name + "`. Configured accounts: " + strings.Join(names, ", ") + "."
+				outbox.Send(chatID, msg, sendOpt)
+
+				return nil
+			}
+
 			if errors.Is(err, context.DeadlineExceeded) {
 				msg := "⏳ Tidal login request timed out. You might need to increase the timeout."
-				if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-					return fmt.Errorf("send message: %w", err)
-				}
+				outbox.Send(chatID, msg, sendOpt)
 
 				return nil
 			}
 
 			if errors.Is(err, context.Canceled) {
 				msg := "♿️ Bot is shutting down. Login flow is not completed."
-				if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-					return fmt.Errorf("send message: %w", err)
-				}
+				outbox.Send(chatID, msg, sendOpt)
 
 				return nil
 			}
 
-			msg := strings.Join(
-				[]string{
-					"❌ Failed to initiate login flow. Necessary information is logged.",
-					"",
-					codeBlockOpenTxt,
-					err.Error(),
-					codeBlockClose,
-				},
-				"\n",
-			)
-			if _, err := b.SendMessage(chatID, msg, sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
-			}
+			msg := "❌ Failed to initiate login flow. Necessary information is logged.\n\n" + errorCodeLine(err)
+			outbox.Send(chatID, msg, sendOpt)
 
-			logger.Error().Err(err).Msg("failed to initiate login flow")
+			logger.Error().Err(err).Str("error_code", errs.Classify(err).Code()).Msg("failed to initiate login flow")
 
 			return nil
 		}
@@ -401,59 +1823,132 @@ func NewTidalLoginCommandHandler(ctx context.Context, logger zerolog.Logger, td
 			},
 			"\n",
 		)
-		if _, err = b.SendMessage(chatID, msg, sendOpt); nil != err {
-			return fmt.Errorf("send message: %w", err)
-		}
+		outbox.Send(chatID, msg, sendOpt)
 
 		if err := <-wait; nil != err {
 			if errors.Is(err, tidal.ErrLoginLinkExpired) {
 				msg := "⏳ Login link expired. You might need to start the login flow again."
-				if _, err = b.SendMessage(chatID, msg, sendOpt); nil != err {
-					return fmt.Errorf("send message: %w", err)
-				}
+				outbox.Send(chatID, msg, sendOpt)
 
 				return nil
 			}
 
 			if errors.Is(err, context.Canceled) {
 				msg := "♿️ Bot is shutting down. Login flow is not completed."
-				if _, err = b.SendMessage(chatID, msg, sendOpt); nil != err {
-					return fmt.Errorf("send message: %w", err)
-				}
+				outbox.Send(chatID, msg, sendOpt)
 
 				return nil
 			}
 
-			msg := strings.Join(
-				[]string{
-					"❌ Login wait failed due to unexpected error. See logs for details.",
-					"",
-					codeBlockOpenTxt,
-					err.Error(),
-					codeBlockClose,
-				},
-				"\n",
-			)
-			if _, err = b.SendMessage(chatID, msg, sendOpt); nil != err {
-				return fmt.Errorf("send message: %w", err)
-			}
+			msg := "❌ Login wait failed due to unexpected error. See logs for details.\n\n" + errorCodeLine(err)
+			outbox.Send(chatID, msg, sendOpt)
 
-			logger.Error().Err(err).Msg("failed to login wait")
+			logger.Error().Err(err).Str("error_code", errs.Classify(err).Code()).Msg("failed to login wait")
 
 			return nil
 		}
 
-		msg = "✅ Login successful. You can now use the bot to download Tidal links."
-		if _, err = b.SendMessage(chatID, msg, sendOpt); nil != err {
-			return fmt.Errorf("send message: %w", err)
+		lines := []string{"✅ Login successful. You can now use the bot to download Tidal links."}
+		if len(td.Accounts()) > 1 {
+			lines[0] = "✅ Login to Tidal account `" + name + "` successful."
 		}
+		if account, err := td.TryAccount(ctx, logger, name); nil != err {
+			logger.Error().Err(err).Msg("Failed to get Tidal account")
+			lines = append(lines, "", "⚠️ Failed to get the logged in account details. Check them using /"+tidalAuthStatusCommand+".")
+		} else {
+			lines = append(append(lines, ""), formatTidalAccount(account)...)
+		}
+		outbox.Send(chatID, strings.Join(lines, "\n"), sendOpt)
 
 		return nil
 	}
 }
 
-func NewTidalAuthStatusCommandHandler(ctx context.Context, logger zerolog.Logger, td *tidal.Client) handlers.Response {
+// NewTidalAuthStatusCommandHandler replies with the Tidal account the bot is logged in to, so that operators
+// can confirm they authorized the intended account.
+func NewTidalAuthStatusCommandHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	td *tidal.Client,
+	outbox *Outbox,
+) handlers.Response {
 	return func(b *gotgbot.Bot, u *ext.Context) error {
-		panic("not implemented")
+		logger = logger.
+			With().
+			Int64("chat_id", u.EffectiveMessage.Chat.Id).
+			Int64("message_id", u.EffectiveMessage.MessageId).
+			Int64("sender_id", u.EffectiveSender.Id()).
+			Logger()
+
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		accounts := td.Accounts()
+		active, _ := lo.Find(accounts, func(a tidal.AccountStatus) bool { return a.Active })
+		account, err := td.TryAccount(ctx, logger, active.Name)
+		if nil != err {
+			var msg string
+			switch {
+			case errors.Is(err, tidal.ErrLoginRequired):
+				msg = "🔑 Not logged in to Tidal. Use /" + tidalLoginCommand + " command to authorize the bot."
+			case errors.Is(err, context.DeadlineExceeded):
+				msg = "⏳ Tidal account request timed out. Try again later."
+			case errors.Is(err, context.Canceled):
+				msg = "♿️ Bot is shutting down. Try again after bot restart."
+			default:
+				logger.Error().Err(err).Msg("Failed to get Tidal account")
+				msg = "❌ Failed to get the logged in Tidal account. Insult logs for details."
+			}
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		lines := append([]string{"🔓 Logged in to Tidal."}, formatTidalAccount(account)...)
+		if len(accounts) > 1 {
+			lines = append(lines, "", "🔁 Accounts:")
+			for _, a := range accounts {
+				var state string
+				switch {
+				case a.Active:
+					state = "in use"
+				case !a.LoggedIn:
+					state = "not logged in"
+				case time.Now().Before(a.CooldownUntil):
+					state = "rate limited until " + a.CooldownUntil.Format(time.TimeOnly)
+				default:
+					state = "standby"
+				}
+				lines = append(lines, "• `"+a.Name+"`: "+state)
+			}
+		}
+		msg := strings.Join(lines, "\n")
+		outbox.Send(chatID, msg, sendOpt)
+
+		return nil
+	}
+}
+
+// formatTidalAccount describes account, one detail per line.
+func formatTidalAccount(account *tidal.Account) []string {
+	name := account.Name
+	if len(name) == 0 {
+		name = "unknown"
+	}
+
+	subscription := account.Subscription
+	if len(account.HighestSoundQuality) > 0 {
+		subscription += " (up to " + account.HighestSoundQuality + ")"
+	}
+
+	return []string{
+		"👤 Account: `" + name + "` (ID `" + strconv.FormatInt(account.UserID, 10) + "`)",
+		"💳 Subscription: `" + subscription + "`",
+		"🌍 Country: `" + account.CountryCode + "`",
 	}
 }
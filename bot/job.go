@@ -3,21 +3,34 @@ package bot
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/semaphore"
+
+	"github.com/xeptore/tidalgram/pause"
 )
 
-var ErrJobCanceled = errors.New("job canceled")
+var (
+	ErrJobCanceled = errors.New("job canceled")
+	ErrJobStuck    = errors.New("job stuck")
+)
 
 type Worker struct {
 	sem    *semaphore.Weighted
 	cancel context.CancelFunc
+	gate   *pause.Gate
+	// startedAt is when the running job started, in Unix nanoseconds. It is zero if no job is running.
+	startedAt atomic.Int64
 }
 
-func NewWorker(maxConcurrency int) *Worker {
+func NewWorker(maxConcurrency int, gate *pause.Gate) *Worker {
 	return &Worker{
-		sem:    semaphore.NewWeighted(int64(maxConcurrency)),
-		cancel: func() {},
+		sem:       semaphore.NewWeighted(int64(maxConcurrency)),
+		cancel:    func() {},
+		gate:      gate,
+		startedAt: atomic.Int64{},
 	}
 }
 
@@ -31,15 +44,72 @@ func (w *Worker) TryAcquireJob(ctx context.Context) (context.Context, bool) {
 	w.cancel = func() {
 		cancel(ErrJobCanceled)
 	}
+	w.startedAt.Store(time.Now().UnixNano())
 
 	return ctx, true
 }
 
+// AcquireJob waits for the running job, if any, to finish, and then acquires the worker like TryAcquireJob.
+func (w *Worker) AcquireJob(ctx context.Context) (context.Context, error) {
+	if err := w.sem.Acquire(ctx, 1); nil != err {
+		return nil, fmt.Errorf("wait for running job: %w", err)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	w.cancel = func() {
+		cancel(ErrJobCanceled)
+	}
+	w.startedAt.Store(time.Now().UnixNano())
+
+	return ctx, nil
+}
+
 func (w *Worker) ReleaseJob() {
+	w.startedAt.Store(0)
+	w.sem.Release(1)
+}
+
+// Busy reports whether a job is running.
+func (w *Worker) Busy() bool {
+	if !w.sem.TryAcquire(1) {
+		return true
+	}
 	w.sem.Release(1)
+
+	return false
+}
+
+// CheckStuck returns ErrJobStuck if the running job has been running longer than maxDuration, unless the
+// worker is paused.
+func (w *Worker) CheckStuck(maxDuration time.Duration) error {
+	startedAt := w.startedAt.Load()
+	if startedAt == 0 || w.gate.Paused() {
+		return nil
+	}
+
+	if elapsed := time.Since(time.Unix(0, startedAt)); elapsed > maxDuration {
+		return fmt.Errorf("%w: running for %s", ErrJobStuck, elapsed.Round(time.Second))
+	}
+
+	return nil
 }
 
+// CancelJob cancels the running job. It also resumes the worker if it is paused, as pausing does not outlive
+// canceled jobs.
 func (w *Worker) CancelJob() {
 	w.cancel()
 	w.cancel = func() {}
+	w.gate.Resume()
+}
+
+// PauseJob suspends the running job, and the jobs started after it, at their next checkpoint, i.e., before
+// downloading or uploading the next track or segment. It returns false if the worker is already paused.
+func (w *Worker) PauseJob() bool {
+	return w.gate.Pause()
+}
+
+// ResumeJob resumes the paused jobs. It returns false if the worker is not paused.
+func (w *Worker) ResumeJob() bool {
+	return w.gate.Resume()
 }
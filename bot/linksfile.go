@@ -0,0 +1,363 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/events"
+	"github.com/xeptore/tidalgram/janitor"
+	"github.com/xeptore/tidalgram/linkkind"
+	"github.com/xeptore/tidalgram/provider"
+	"github.com/xeptore/tidalgram/telegram"
+	"github.com/xeptore/tidalgram/tidal"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// maxLinksFileSize is the size limit of the documents of links, which is far more than any reasonable list of
+// links needs.
+const maxLinksFileSize = 1 << 20
+
+// maxSummaryLinks is the number of unsuccessful links listed in links file summaries, which keeps them within
+// the message length limit.
+const maxSummaryLinks = 50
+
+var errLinksFileTooLarge = errors.New("links file is too large")
+
+// linksFileFilter accepts the documents that might contain lists of links, i.e., plain text and CSV files.
+func linksFileFilter(msg *gotgbot.Message) bool {
+	if nil == msg.Document {
+		return false
+	}
+
+	switch strings.ToLower(filepath.Ext(msg.Document.FileName)) {
+	case ".txt", ".csv":
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseLinksFile parses the links in the text or CSV document r, one or more per line, separated by
+// whitespace, commas, or semicolons. Duplicate links are skipped. It also returns the descriptions of the
+// Tidal URLs that cannot be parsed into links. Other words and URLs are ignored.
+func ParseLinksFile(r io.Reader) (links []types.Link, unrecognized []string, err error) {
+	seen := make(map[types.Link]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.FieldsFunc(scanner.Text(), func(r rune) bool {
+			return r == ',' || r == ';' || r == '"' || r == '\'' || r == ' ' || r == '\t'
+		})
+		for _, field := range fields {
+			var link types.Link
+			if parsed, err := types.ParseURL(field); nil == err {
+				link = parsed
+			} else if parsed, ok := linkkind.ParseURL(field); ok {
+				link = parsed
+			} else {
+				if desc, ok := describeUnrecognizedURL(field); ok {
+					unrecognized = append(unrecognized, desc)
+				}
+
+				continue
+			}
+
+			if _, ok := seen[link]; ok {
+				continue
+			}
+			seen[link] = struct{}{}
+			links = append(links, link)
+		}
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, nil, fmt.Errorf("read links: %v", err)
+	}
+
+	return links, unrecognized, nil
+}
+
+// fileOpener is implemented by the bot clients whose files are not downloaded from Bot API file URLs.
+type fileOpener interface {
+	OpenFile(ctx context.Context, file *gotgbot.File) (io.ReadCloser, error)
+}
+
+// openFile returns the content of file, which is downloaded from its Bot API file URL, unless the bot client
+// is a [fileOpener].
+func openFile(ctx context.Context, b *gotgbot.Bot, file *gotgbot.File) (io.ReadCloser, error) {
+	if opener, ok := b.BotClient.(fileOpener); ok {
+		body, err := opener.OpenFile(ctx, file)
+		if nil != err {
+			return nil, fmt.Errorf("open file: %w", err)
+		}
+
+		return body, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.URL(b, nil), nil)
+	if nil != err {
+		return nil, fmt.Errorf("create request: %v", err)
+	}
+
+	client := http.DefaultClient
+	if c, ok := b.BotClient.(*gotgbot.BaseBotClient); ok {
+		client = &c.Client
+	}
+
+	resp, err := client.Do(req)
+	if nil != err {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// downloadLinksFile downloads the Telegram document with fileID, and parses its links.
+func downloadLinksFile(ctx context.Context, b *gotgbot.Bot, fileID string) ([]types.Link, []string, error) {
+	file, err := b.GetFileWithContext(ctx, fileID, nil)
+	if nil != err {
+		return nil, nil, fmt.Errorf("get file: %w", err)
+	}
+	if file.FileSize > maxLinksFileSize {
+		return nil, nil, errLinksFileTooLarge
+	}
+
+	body, err := openFile(ctx, b, file)
+	if nil != err {
+		return nil, nil, err
+	}
+	defer body.Close()
+
+	links, unrecognized, err := ParseLinksFile(io.LimitReader(body, maxLinksFileSize))
+	if nil != err {
+		return nil, nil, fmt.Errorf("parse file: %w", err)
+	}
+
+	return links, unrecognized, nil
+}
+
+// NewLinksFileHandler handles the text and CSV documents of links, downloading all of their links as a single
+// job. Unlike the links sent in messages, failing links do not stop the job, and a summary of all links is
+// sent after the job finishes.
+func NewLinksFileHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	up *telegram.Uploader,
+	worker *Worker,
+	store *audit.Store,
+	jn *janitor.Janitor,
+	outbox *Outbox,
+	bus *events.Bus,
+	cleanup bool,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		doc := u.EffectiveMessage.Document
+		logger = logger.
+			With().
+			Int64("chat_id", u.EffectiveMessage.Chat.Id).
+			Int64("message_id", u.EffectiveMessage.MessageId).
+			Int64("sender_id", u.EffectiveSender.Id()).
+			Str("file_name", doc.FileName).
+			Logger()
+
+		sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+			ParseMode: gotgbot.ParseModeMarkdown,
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: u.EffectiveMessage.MessageId,
+			},
+		}
+		chatID := u.EffectiveMessage.Chat.Id
+
+		if doc.FileSize > maxLinksFileSize {
+			msg := "🐘 Links file is too large. It must be at most " + strconv.Itoa(maxLinksFileSize>>10) + " KiB."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		links, unrecognized, err := downloadLinksFile(ctx, b, doc.FileId)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to download links file")
+
+			msg := "❌ Failed to download links file. Insult logs for details."
+			if errors.Is(err, errLinksFileTooLarge) {
+				msg = "🐘 Links file is too large. It must be at most " + strconv.Itoa(maxLinksFileSize>>10) + " KiB."
+			}
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if n := len(unrecognized); n > 0 {
+			if n > maxSummaryLinks {
+				unrecognized = append(unrecognized[:maxSummaryLinks], "…and "+strconv.Itoa(n-maxSummaryLinks)+" more.")
+			}
+			msg := "🤷 Unrecognized Tidal links:\n" + strings.Join(unrecognized, "\n")
+			outbox.Send(chatID, msg, sendOpt)
+		}
+
+		if len(links) == 0 {
+			msg := "🤨 Links file has no Tidal links. Put one link per line, or separate them by commas."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+
+		if !ensureFreeSpace(logger, outbox, jn, chatID, sendOpt) {
+			return nil
+		}
+
+		ctx, ok := worker.TryAcquireJob(ctx)
+		if !ok {
+			msg := "🈵 Another download is in progress. Try again later."
+			outbox.Send(chatID, msg, sendOpt)
+
+			return nil
+		}
+		defer worker.ReleaseJob()
+
+		jobOutbox := outbox
+		if cleanup {
+			jobOutbox = outbox.Collecting()
+		}
+		jobOutbox.Send(chatID, "🚧 Downloading "+strconv.Itoa(len(links))+" links of `"+doc.FileName+"`.", sendOpt)
+
+		opts := telegram.UploadOptions{} //nolint:exhaustruct
+		outcomes := processLinksFile(ctx, logger, jobOutbox, bus, src, up, store, u.EffectiveSender.Id(), chatID, sendOpt, links, opts)
+		summary := linksFileSummary(doc.FileName, links, outcomes)
+		if cleanup && linksFileOutcome(outcomes) == audit.OutcomeSucceeded && len(outcomes) == len(links) {
+			jobOutbox.DeleteCollected(chatID, u.EffectiveMessage.MessageId)
+			outbox.Send(chatID, summary, &gotgbot.SendMessageOpts{ParseMode: gotgbot.ParseModeMarkdown}) //nolint:exhaustruct
+
+			return nil
+		}
+		outbox.Send(chatID, summary, sendOpt)
+
+		return nil
+	}
+}
+
+// processLinksFile processes links one after another, like processLinks, but keeps going after failing
+// links, and reports the overall progress in a single status message. It only stops if the job is canceled,
+// the bot is shutting down, or the Telegram session is lost. It returns the outcomes of the processed links, in order.
+func processLinksFile(
+	ctx context.Context,
+	logger zerolog.Logger,
+	outbox *Outbox,
+	bus *events.Bus,
+	src provider.Provider,
+	up *telegram.Uploader,
+	store *audit.Store,
+	userID int64,
+	chatID int64,
+	sendOpt *gotgbot.SendMessageOpts,
+	links []types.Link,
+	opts telegram.UploadOptions,
+) []audit.Outcome {
+	// Tracks of the links are deduplicated by ISRC across the whole job.
+	ctx = tidal.WithJob(ctx)
+	outcomes := make([]audit.Outcome, 0, len(links))
+	bus.Publish(events.Event{ //nolint:exhaustruct
+		Kind:   events.KindJobStarted,
+		ChatID: chatID,
+		Links:  lo.Map(links, func(link types.Link, _ int) string { return link.URL() }),
+	})
+	defer func() {
+		bus.Publish(events.Event{Kind: events.KindJobFinished, ChatID: chatID, Outcome: string(linksFileOutcome(outcomes))}) //nolint:exhaustruct
+	}()
+
+	status := outbox.NewStatus(chatID, linksFileProgress(len(links), outcomes), sendOpt)
+	for i, link := range links {
+		time.Sleep(time.Duration(min(i, 1)) * time.Second)
+
+		outcome, cause := processRetriedLink(ctx, logger, outbox, bus, src, up, nil, store, userID, chatID, sendOpt, link, opts, jobModeDownload)
+		outcomes = append(outcomes, outcome)
+		status.Update(linksFileProgress(len(links), outcomes))
+
+		if outcome == audit.OutcomeCanceled || outcome == audit.OutcomeShutdown {
+			break
+		}
+
+		// The rest of the links would fail the same way until the bot is logged in again.
+		if errors.Is(cause, telegram.ErrUnauthorized) {
+			savePendingJob(ctx, logger, src.DownloadsDir(), userID, chatID, links[i:], opts, jobModeDownload)
+			break
+		}
+	}
+
+	return outcomes
+}
+
+// linksFileOutcome returns the outcome of a links file job with outcomes, which is the outcome of its first
+// unsuccessful link, if any.
+func linksFileOutcome(outcomes []audit.Outcome) audit.Outcome {
+	for _, outcome := range outcomes {
+		if outcome != audit.OutcomeSucceeded {
+			return outcome
+		}
+	}
+
+	return audit.OutcomeSucceeded
+}
+
+func linksFileProgress(total int, outcomes []audit.Outcome) string {
+	succeeded := lo.Count(outcomes, audit.OutcomeSucceeded)
+
+	return "📊 Processed " + strconv.Itoa(len(outcomes)) + " of " + strconv.Itoa(total) + " links: " +
+		strconv.Itoa(succeeded) + " succeeded, " + strconv.Itoa(len(outcomes)-succeeded) + " did not."
+}
+
+// linksFileSummary reports the outcomes of links of the links file named fileName, listing the links that
+// did not succeed, or were not processed.
+func linksFileSummary(fileName string, links []types.Link, outcomes []audit.Outcome) string {
+	succeeded := lo.Count(outcomes, audit.OutcomeSucceeded)
+
+	icon := "✅"
+	if succeeded < len(links) {
+		icon = "⚠️"
+	}
+
+	lines := []string{
+		icon + " " + strconv.Itoa(succeeded) + " of " + strconv.Itoa(len(links)) + " links of `" + fileName + "` were successfully uploaded.",
+	}
+	var listed int
+	for i, link := range links {
+		if i < len(outcomes) && outcomes[i] == audit.OutcomeSucceeded {
+			continue
+		}
+		if listed == maxSummaryLinks {
+			lines = append(lines, "…and "+strconv.Itoa(len(links)-succeeded-listed)+" more.")
+			break
+		}
+		listed++
+
+		target := link.Kind.String() + " `" + link.ID + "`"
+		if i >= len(outcomes) {
+			lines = append(lines, "⏭️ "+target+": not processed")
+		} else {
+			lines = append(lines, "❌ "+target+": "+strings.ReplaceAll(string(outcomes[i]), "_", " "))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/skip2/go-qrcode"
+)
+
+var ErrLoginCancelled = errors.New("login cancelled")
+
+const cancelLoginCommand = "cancel"
+
+// LoginPrompter asks papa for the details of logging in to Telegram in the bot chat, so that the uploader can be
+// authorized on headless servers without a TTY. It polls for updates itself, hence the bot must not be started
+// while it is used.
+type LoginPrompter struct {
+	bot    *gotgbot.Bot
+	chatID int64
+	// offset is the identifier of the next update to receive.
+	offset int64
+	// qrMessageID is the identifier of the message of the last shown QR code, or zero.
+	qrMessageID int64
+}
+
+// LoginPrompter deletes the webhook, if any, as updates cannot be polled while it is set, along with the pending
+// updates, so that earlier messages are not taken as answers.
+func (b *Bot) LoginPrompter(ctx context.Context) (*LoginPrompter, error) {
+	opts := &gotgbot.DeleteWebhookOpts{ //nolint:exhaustruct
+		DropPendingUpdates: true,
+	}
+	if _, err := b.bot.DeleteWebhookWithContext(ctx, opts); nil != err {
+		return nil, fmt.Errorf("delete webhook: %w", err)
+	}
+
+	return &LoginPrompter{
+		bot:         b.bot,
+		chatID:      b.papaChatID,
+		offset:      0,
+		qrMessageID: 0,
+	}, nil
+}
+
+func (p *LoginPrompter) ShowQR(ctx context.Context, url string) error {
+	png, err := qrcode.Encode(url, qrcode.Medium, 512)
+	if nil != err {
+		return fmt.Errorf("create qr code: %v", err)
+	}
+
+	// The QR code is refreshed every 30 seconds or so, hence the expired one is deleted to not clutter the chat.
+	if err := p.HideQR(ctx); nil != err {
+		return err
+	}
+
+	opts := &gotgbot.SendPhotoOpts{ //nolint:exhaustruct
+		Caption: strings.Join(
+			[]string{
+				"🔐 Scan this QR code to log me in to Telegram, papa.",
+				"",
+				"In a logged in Telegram app, open Settings › Devices › Link Desktop Device.",
+				"Send /" + cancelLoginCommand + " to cancel.",
+			},
+			"\n",
+		),
+	}
+	msg, err := p.bot.SendPhotoWithContext(ctx, p.chatID, gotgbot.InputFileByReader("qr.png", bytes.NewReader(png)), opts)
+	if nil != err {
+		return fmt.Errorf("send qr code: %w", err)
+	}
+	p.qrMessageID = msg.MessageId
+
+	return nil
+}
+
+func (p *LoginPrompter) HideQR(ctx context.Context) error {
+	if p.qrMessageID == 0 {
+		return nil
+	}
+
+	if _, err := p.bot.DeleteMessageWithContext(ctx, p.chatID, p.qrMessageID, nil); nil != err {
+		return fmt.Errorf("delete qr code message: %w", err)
+	}
+	p.qrMessageID = 0
+
+	return nil
+}
+
+func (p *LoginPrompter) Phone(ctx context.Context) (string, error) {
+	return p.ask(ctx, "📱 Send me the phone number of the account in international format, e.g., +15551234567, papa.", false)
+}
+
+func (p *LoginPrompter) Code(ctx context.Context, retry bool) (string, error) {
+	lines := []string{
+		"🔢 Send me the login code Telegram sent you, with spaces between its digits, e.g., 1 2 3 4 5, papa.",
+		"Telegram expires login codes sent in chats as is.",
+	}
+	if retry {
+		lines = append([]string{"❌ Wrong login code."}, lines...)
+	}
+
+	return p.ask(ctx, strings.Join(lines, "\n"), true)
+}
+
+func (p *LoginPrompter) Password(ctx context.Context, retry bool) (string, error) {
+	question := "🔑 Send me the 2FA password of the account, papa. I'll delete your message right away."
+	if retry {
+		question = "❌ Wrong 2FA password.\n" + question
+	}
+
+	return p.ask(ctx, question, true)
+}
+
+// Done tells papa the result of the login.
+func (p *LoginPrompter) Done(ctx context.Context, loginErr error) error {
+	msg := "✅ Logged in to Telegram, papa. You can start me now."
+	if nil != loginErr {
+		msg = "❌ Failed to log in to Telegram, papa. Check the logs for details."
+	}
+
+	if _, err := p.bot.SendMessageWithContext(ctx, p.chatID, msg, nil); nil != err {
+		return fmt.Errorf("send message: %w", err)
+	}
+
+	return nil
+}
+
+// ask sends question to papa, and waits for the reply. Secret replies are deleted from the chat once received.
+func (p *LoginPrompter) ask(ctx context.Context, question string, secret bool) (string, error) {
+	question += "\nSend /" + cancelLoginCommand + " to cancel."
+	if _, err := p.bot.SendMessageWithContext(ctx, p.chatID, question, nil); nil != err {
+		return "", fmt.Errorf("send question: %w", err)
+	}
+
+	for {
+		opts := &gotgbot.GetUpdatesOpts{
+			Offset:         p.offset,
+			Limit:          10,
+			Timeout:        50,
+			AllowedUpdates: []string{"message"},
+			RequestOpts: &gotgbot.RequestOpts{ //nolint:exhaustruct
+				Timeout: time.Minute,
+			},
+		}
+		updates, err := p.bot.GetUpdatesWithContext(ctx, opts)
+		if nil != err {
+			return "", fmt.Errorf("get updates: %w", err)
+		}
+
+		for _, update := range updates {
+			p.offset = update.UpdateId + 1
+
+			msg := update.Message
+			if nil == msg || msg.Chat.Id != p.chatID || len(msg.Text) == 0 {
+				continue
+			}
+
+			if secret {
+				if _, err := p.bot.DeleteMessageWithContext(ctx, p.chatID, msg.MessageId, nil); nil != err {
+					return "", fmt.Errorf("delete reply message: %w", err)
+				}
+			}
+
+			if hasCommand(msg, cancelLoginCommand) {
+				return "", ErrLoginCancelled
+			}
+
+			return strings.TrimSpace(msg.Text), nil
+		}
+	}
+}
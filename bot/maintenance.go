@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Maintenance is the maintenance mode switch, e.g., for Tidal API incidents or migrations. New jobs are declined
+// while it is on, and the running job is left to finish. Its state persists across restarts.
+type Maintenance struct {
+	mu    sync.Mutex
+	file  fs.Maintenance
+	state types.StoredMaintenance
+}
+
+// NewMaintenance returns the maintenance mode switch stored in dir.
+func NewMaintenance(dir fs.DownloadsDir) (*Maintenance, error) {
+	file := dir.Maintenance()
+	state, err := file.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read maintenance state: %v", err)
+	}
+
+	return &Maintenance{
+		mu:    sync.Mutex{},
+		file:  file,
+		state: *state,
+	}, nil
+}
+
+// State returns whether maintenance mode is on, and since when.
+func (m *Maintenance) State() types.StoredMaintenance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
+
+// Enabled reports whether maintenance mode is on. A nil switch is never on.
+func (m *Maintenance) Enabled() bool {
+	if nil == m {
+		return false
+	}
+
+	return m.State().Enabled
+}
+
+// Set turns maintenance mode on or off, and stores it. It returns false if it was already so.
+func (m *Maintenance) Set(enabled bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state.Enabled == enabled {
+		return false, nil
+	}
+
+	state := types.StoredMaintenance{Enabled: enabled, Since: time.Now().UTC()}
+	if err := m.file.InfoFile.Write(state); nil != err {
+		return false, fmt.Errorf("write maintenance state: %v", err)
+	}
+	m.state = state
+
+	return true, nil
+}
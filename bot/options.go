@@ -0,0 +1,298 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Callback data of the link options keyboard buttons. Destinations are referenced by their index, as callback
+// data is limited to 64 bytes.
+const (
+	linkOptionsCallbackPrefix = "lo:"
+	linkOptionsDestination    = "d:"
+	linkOptionsArchive        = "z"
+	linkOptionsStart          = "s"
+	linkOptionsCancel         = "c"
+)
+
+// linkOptions are the options of a link job chosen using the link options keyboard.
+type linkOptions struct {
+	// Destination is the username links are uploaded to, or empty for the configured peers.
+	Destination string
+	Archive     bool
+}
+
+func (o linkOptions) String() string {
+	msg := "🎯 Destination: "
+	if len(o.Destination) > 0 {
+		msg += "@" + o.Destination
+	} else {
+		msg += "default"
+	}
+	if o.Archive {
+		msg += "\n📦 Albums: ZIP archives"
+	}
+
+	return msg
+}
+
+// linkOptionsPrompt is the state of a link options keyboard. It is pending until its job is either started,
+// canceled, or its timeout passes.
+type linkOptionsPrompt struct {
+	mu           sync.Mutex
+	opts         linkOptions
+	destinations []string
+	albums       bool
+	// decided receives true if the job is started, and false if it is canceled.
+	decided chan bool
+}
+
+// apply applies the action of a pressed keyboard button to p. It returns false if the action is unknown, or
+// p is no longer pending.
+func (p *linkOptionsPrompt) apply(action string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case action == linkOptionsStart, action == linkOptionsCancel:
+		select {
+		case p.decided <- action == linkOptionsStart:
+			return true
+		default:
+			return false
+		}
+	case action == linkOptionsArchive && p.albums:
+		p.opts.Archive = !p.opts.Archive
+	case strings.HasPrefix(action, linkOptionsDestination):
+		idx, err := strconv.Atoi(strings.TrimPrefix(action, linkOptionsDestination))
+		if nil != err || idx < 0 || idx > len(p.destinations) {
+			return false
+		}
+		if idx == 0 {
+			p.opts.Destination = ""
+		} else {
+			p.opts.Destination = p.destinations[idx-1]
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (p *linkOptionsPrompt) options() linkOptions {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.opts
+}
+
+func (p *linkOptionsPrompt) keyboard() gotgbot.InlineKeyboardMarkup {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	button := func(text, action string, selected bool) gotgbot.InlineKeyboardButton {
+		if selected {
+			text = "✅ " + text
+		}
+
+		return gotgbot.InlineKeyboardButton{ //nolint:exhaustruct
+			Text:         text,
+			CallbackData: linkOptionsCallbackPrefix + action,
+		}
+	}
+
+	var rows [][]gotgbot.InlineKeyboardButton
+	if len(p.destinations) > 0 {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{
+			button("Default", linkOptionsDestination+"0", p.opts.Destination == ""),
+		})
+		for i, d := range p.destinations {
+			rows = append(rows, []gotgbot.InlineKeyboardButton{
+				button("@"+d, linkOptionsDestination+strconv.Itoa(i+1), p.opts.Destination == d),
+			})
+		}
+	}
+	if p.albums {
+		rows = append(rows, []gotgbot.InlineKeyboardButton{
+			button("🎵 Tracks", linkOptionsArchive, !p.opts.Archive),
+			button("📦 ZIP", linkOptionsArchive, p.opts.Archive),
+		})
+	}
+	rows = append(rows, []gotgbot.InlineKeyboardButton{
+		button("▶️ Start", linkOptionsStart, false),
+		button("✖️ Cancel", linkOptionsCancel, false),
+	})
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+type linkOptionsPromptKey struct {
+	chatID    int64
+	messageID int64
+}
+
+// LinkOptionsPrompts holds the pending link options keyboards, keyed by the message they are sent with, for
+// the callback query handler to apply the pressed buttons to.
+type LinkOptionsPrompts struct {
+	mu      sync.Mutex
+	prompts map[linkOptionsPromptKey]*linkOptionsPrompt
+}
+
+func NewLinkOptionsPrompts() *LinkOptionsPrompts {
+	return &LinkOptionsPrompts{
+		mu:      sync.Mutex{},
+		prompts: make(map[linkOptionsPromptKey]*linkOptionsPrompt),
+	}
+}
+
+func (lp *LinkOptionsPrompts) add(key linkOptionsPromptKey, p *linkOptionsPrompt) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	lp.prompts[key] = p
+}
+
+func (lp *LinkOptionsPrompts) remove(key linkOptionsPromptKey) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	delete(lp.prompts, key)
+}
+
+func (lp *LinkOptionsPrompts) get(key linkOptionsPromptKey) (*linkOptionsPrompt, bool) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	p, ok := lp.prompts[key]
+
+	return p, ok
+}
+
+// askLinkOptions replies to the links message with the link options keyboard, and waits until the job is
+// started, canceled, or timeout passes, in which case it is started with the options chosen so far. It returns
+// false if the job is canceled. It does not ask anything if there is nothing to choose from.
+func askLinkOptions(
+	ctx context.Context,
+	logger zerolog.Logger,
+	b *gotgbot.Bot,
+	prompts *LinkOptionsPrompts,
+	chatID int64,
+	sendOpt *gotgbot.SendMessageOpts,
+	links []types.Link,
+	destinations []string,
+	timeout time.Duration,
+) (linkOptions, bool, error) {
+	p := &linkOptionsPrompt{
+		mu:           sync.Mutex{},
+		opts:         linkOptions{Destination: "", Archive: false},
+		destinations: destinations,
+		albums:       lo.ContainsBy(links, func(l types.Link) bool { return l.Kind == types.LinkKindAlbum }),
+		decided:      make(chan bool, 1),
+	}
+	if len(p.destinations) == 0 && !p.albums {
+		return p.opts, true, nil
+	}
+
+	opt := *sendOpt
+	opt.ReplyMarkup = p.keyboard()
+	msg := "⚙️ Choose the options, or wait " + timeout.String() + " to start with the defaults."
+	sent, err := b.SendMessageWithContext(ctx, chatID, msg, &opt)
+	if nil != err {
+		return p.opts, false, fmt.Errorf("send link options message: %w", err)
+	}
+
+	key := linkOptionsPromptKey{chatID: chatID, messageID: sent.MessageId}
+	prompts.add(key, p)
+	defer prompts.remove(key)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var start bool
+	select {
+	case start = <-p.decided:
+	case <-timer.C:
+		start = p.apply(linkOptionsStart)
+		if !start {
+			start = <-p.decided
+		}
+	case <-ctx.Done():
+		return p.opts, false, ctx.Err()
+	}
+
+	opts := p.options()
+	if start {
+		msg = "⚙️ Options:\n" + opts.String()
+	} else {
+		msg = "✖️ Canceled."
+	}
+	editOpts := &gotgbot.EditMessageTextOpts{ //nolint:exhaustruct
+		ChatId:      chatID,
+		MessageId:   sent.MessageId,
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{}},
+	}
+	if _, _, err := b.EditMessageTextWithContext(ctx, msg, editOpts); nil != err {
+		logger.Error().Err(err).Msg("Failed to edit link options message")
+	}
+
+	return opts, start, nil
+}
+
+// NewLinkOptionsCallbackHandler applies the pressed link options keyboard buttons to their pending prompts.
+func NewLinkOptionsCallbackHandler(
+	ctx context.Context,
+	logger zerolog.Logger,
+	prompts *LinkOptionsPrompts,
+) handlers.Response {
+	return func(b *gotgbot.Bot, u *ext.Context) error {
+		cq := u.CallbackQuery
+		answerOpts := &gotgbot.AnswerCallbackQueryOpts{} //nolint:exhaustruct
+
+		var (
+			p  *linkOptionsPrompt
+			ok bool
+		)
+		if nil != cq.Message {
+			p, ok = prompts.get(linkOptionsPromptKey{chatID: cq.Message.GetChat().Id, messageID: cq.Message.GetMessageId()})
+		}
+		action := strings.TrimPrefix(cq.Data, linkOptionsCallbackPrefix)
+		if !ok || !p.apply(action) {
+			answerOpts.Text = "⌛ These options are no longer available."
+			if _, err := b.AnswerCallbackQueryWithContext(ctx, cq.Id, answerOpts); nil != err {
+				return fmt.Errorf("answer callback query: %w", err)
+			}
+
+			return nil
+		}
+
+		if action != linkOptionsStart && action != linkOptionsCancel {
+			editOpts := &gotgbot.EditMessageReplyMarkupOpts{ //nolint:exhaustruct
+				ChatId:      cq.Message.GetChat().Id,
+				MessageId:   cq.Message.GetMessageId(),
+				ReplyMarkup: p.keyboard(),
+			}
+			if _, _, err := b.EditMessageReplyMarkupWithContext(ctx, editOpts); nil != err {
+				logger.Error().Err(err).Msg("Failed to edit link options keyboard")
+			}
+		}
+
+		if _, err := b.AnswerCallbackQueryWithContext(ctx, cq.Id, answerOpts); nil != err {
+			return fmt.Errorf("answer callback query: %w", err)
+		}
+
+		return nil
+	}
+}
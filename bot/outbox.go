@@ -0,0 +1,286 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+const (
+	outboxQueueSize      = 1024
+	outboxMaxRateLimited = 3
+	outboxDrainTimeout   = 5 * time.Second
+)
+
+// Outbox sends and edits Bot API messages asynchronously from a single queue, spacing out requests
+// by the configured interval. Successive edits of the same status message that are still queued are
+// coalesced into a single edit with the latest text. Requests queued after the outbox stopped are dropped.
+type Outbox struct {
+	bot      *gotgbot.Bot
+	logger   zerolog.Logger
+	interval time.Duration
+	queue    chan func(ctx context.Context) error
+	// done is closed once the outbox stopped processing its queue.
+	done chan struct{}
+	// sent collects the IDs of the messages sent via the outbox, if set. See [Outbox.Collecting].
+	sent *sentMessages
+}
+
+type sentMessages struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+func NewOutbox(b *Bot, logger zerolog.Logger, conf config.BotOutbox) *Outbox {
+	return &Outbox{
+		bot:      b.bot,
+		logger:   logger,
+		interval: conf.Interval.Duration,
+		queue:    make(chan func(ctx context.Context) error, outboxQueueSize),
+		done:     make(chan struct{}),
+		sent:     nil,
+	}
+}
+
+// Collecting returns an outbox that shares the queue of o, and collects the IDs of the messages sent via it,
+// including status messages, so that they can be deleted using [Outbox.DeleteCollected].
+func (o *Outbox) Collecting() *Outbox {
+	c := *o
+	c.sent = &sentMessages{mu: sync.Mutex{}, ids: nil}
+
+	return &c
+}
+
+func (o *Outbox) collect(msgID int64) {
+	if nil == o.sent {
+		return
+	}
+
+	o.sent.mu.Lock()
+	o.sent.ids = append(o.sent.ids, msgID)
+	o.sent.mu.Unlock()
+}
+
+// DeleteCollected queues deleting the messages collected by o, which must be returned by [Outbox.Collecting],
+// along with the messages with extraIDs, from chatID. Only the messages sent before the deletion is processed
+// are deleted.
+func (o *Outbox) DeleteCollected(chatID int64, extraIDs ...int64) {
+	o.push(func(ctx context.Context) error {
+		o.sent.mu.Lock()
+		ids := append(slices.Clone(o.sent.ids), extraIDs...)
+		o.sent.mu.Unlock()
+
+		// Bot API deletes at most 100 messages per request.
+		for chunk := range slices.Chunk(ids, 100) {
+			if _, err := o.bot.DeleteMessagesWithContext(ctx, chatID, chunk, nil); nil != err {
+				return fmt.Errorf("delete messages: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Send queues sending text to chatID.
+func (o *Outbox) Send(chatID int64, text string, opts *gotgbot.SendMessageOpts) {
+	o.push(func(ctx context.Context) error {
+		msg, err := o.bot.SendMessageWithContext(ctx, chatID, text, opts)
+		if nil != err {
+			return fmt.Errorf("send message: %w", err)
+		}
+		o.collect(msg.MessageId)
+
+		return nil
+	})
+}
+
+// push queues op, unless the outbox stopped, in which case op is dropped, as it would never be processed.
+func (o *Outbox) push(op func(ctx context.Context) error) {
+	select {
+	case <-o.done:
+		o.logger.Warn().Msg("Outbox is stopped. Dropping request")
+		return
+	default:
+	}
+
+	select {
+	case o.queue <- op:
+	case <-o.done:
+		o.logger.Warn().Msg("Outbox is stopped. Dropping request")
+	}
+}
+
+// NewStatus queues sending text to chatID, and returns a status whose text can later be updated in place. opts
+// might be nil, in which case text is sent, and edited, without formatting.
+func (o *Outbox) NewStatus(chatID int64, text string, opts *gotgbot.SendMessageOpts) *Status {
+	if nil == opts {
+		// Edits take the parse mode of opts.
+		opts = &gotgbot.SendMessageOpts{} //nolint:exhaustruct
+	}
+
+	s := &Status{
+		mu:     sync.Mutex{},
+		outbox: o,
+		chatID: chatID,
+		opts:   opts,
+		text:   "",
+		queued: false,
+		msgID:  0,
+		sent:   "",
+	}
+	s.Update(text)
+
+	return s
+}
+
+// Run processes the queue until ctx is done. Requests that are still queued at that point, e.g., the
+// shutdown notices of the running job, are given a short grace period to be sent.
+func (o *Outbox) Run(ctx context.Context) {
+	defer close(o.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			o.drain(context.WithoutCancel(ctx))
+			return
+		case op := <-o.queue:
+			if err := o.do(ctx, op); nil != err && !errors.Is(err, context.Canceled) {
+				o.logger.Error().Err(err).Msg("Failed to process outbox request")
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(o.interval):
+			}
+		}
+	}
+}
+
+func (o *Outbox) drain(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, outboxDrainTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case op := <-o.queue:
+			if err := o.do(ctx, op); nil != err {
+				o.logger.Error().Err(err).Msg("Failed to process outbox request while draining")
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (o *Outbox) do(ctx context.Context, op func(ctx context.Context) error) error {
+	for range outboxMaxRateLimited {
+		err := op(ctx)
+		if nil == err {
+			return nil
+		}
+
+		var tgErr *gotgbot.TelegramError
+		if !errors.As(err, &tgErr) || tgErr.Code != http.StatusTooManyRequests || nil == tgErr.ResponseParams {
+			return err
+		}
+
+		retryAfter := time.Duration(tgErr.ResponseParams.RetryAfter) * time.Second
+		o.logger.Warn().Dur("retry_after", retryAfter).Msg("Bot API rate limit exceeded. Retrying outbox request")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+
+	return errors.New("outbox request was rate limited too many times")
+}
+
+// Status is a message whose text is updated in place by editing it.
+type Status struct {
+	mu     sync.Mutex
+	outbox *Outbox
+	chatID int64
+	opts   *gotgbot.SendMessageOpts
+	text   string
+	queued bool
+	// Fields below are only accessed from the outbox queue processor.
+	msgID int64
+	sent  string
+}
+
+// Update queues replacing the status text. If an update is already queued, it only changes the text
+// that the queued update sets.
+func (s *Status) Update(text string) {
+	s.mu.Lock()
+	s.text = text
+	queued := s.queued
+	s.queued = true
+	s.mu.Unlock()
+
+	if !queued {
+		s.outbox.push(s.flush)
+	}
+}
+
+func (s *Status) flush(ctx context.Context) error {
+	s.mu.Lock()
+	text := s.text
+	s.queued = false
+	s.mu.Unlock()
+
+	if s.msgID == 0 {
+		msg, err := s.outbox.bot.SendMessageWithContext(ctx, s.chatID, text, s.opts)
+		if nil != err {
+			return fmt.Errorf("send status message: %w", err)
+		}
+		s.msgID, s.sent = msg.MessageId, text
+		s.outbox.collect(msg.MessageId)
+
+		return nil
+	}
+
+	if text == s.sent {
+		return nil
+	}
+
+	editOpts := &gotgbot.EditMessageTextOpts{ //nolint:exhaustruct
+		ChatId:    s.chatID,
+		MessageId: s.msgID,
+		ParseMode: s.opts.ParseMode,
+	}
+	if _, _, err := s.outbox.bot.EditMessageTextWithContext(ctx, text, editOpts); nil != err {
+		return fmt.Errorf("edit status message: %w", err)
+	}
+	s.sent = text
+
+	return nil
+}
+
+// Keep queues excluding the status message from the messages collected by its outbox, so that it is not
+// deleted by [Outbox.DeleteCollected].
+func (s *Status) Keep() {
+	s.outbox.push(func(context.Context) error {
+		if nil == s.outbox.sent {
+			return nil
+		}
+
+		s.outbox.sent.mu.Lock()
+		s.outbox.sent.ids = slices.DeleteFunc(s.outbox.sent.ids, func(id int64) bool { return id == s.msgID })
+		s.outbox.sent.mu.Unlock()
+
+		return nil
+	})
+}
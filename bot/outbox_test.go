@@ -0,0 +1,95 @@
+package bot_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/bot"
+	"github.com/xeptore/tidalgram/config"
+)
+
+// fakeBotAPI is a Bot API server that records the methods called on it, along with their params.
+type fakeBotAPI struct {
+	mu    sync.Mutex
+	calls []fakeBotAPICall
+}
+
+type fakeBotAPICall struct {
+	method string
+	params map[string]string
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if err := r.ParseMultipartForm(1 << 20); nil != err {
+		_ = r.ParseForm()
+	}
+
+	params := make(map[string]string)
+	for k, v := range r.Form {
+		params[k] = v[0]
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeBotAPICall{method: method, params: params})
+	f.mu.Unlock()
+
+	var result any
+	switch method {
+	case "getMe":
+		result = map[string]any{"id": 1, "is_bot": true, "first_name": "Bot", "username": "bot"}
+	default:
+		result = map[string]any{"message_id": 10, "date": 0, "chat": map[string]any{"id": 1, "type": "private"}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func (f *fakeBotAPI) called(method string) []fakeBotAPICall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []fakeBotAPICall
+	for _, c := range f.calls {
+		if c.method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+func TestStatusWithoutOptsIsEdited(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeBotAPI{mu: sync.Mutex{}, calls: nil}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	b, err := bot.New(t.Context(), zerolog.Nop(), config.Bot{APIURL: srv.URL, Token: "1:token"}) //nolint:exhaustruct
+	require.NoError(t, err)
+
+	outbox := bot.NewOutbox(b, zerolog.Nop(), config.BotOutbox{Interval: config.Duration{Duration: 0}})
+	go outbox.Run(t.Context())
+
+	status := outbox.NewStatus(1, "Downloading...", nil)
+	require.Eventually(t, func() bool { return len(api.called("sendMessage")) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	status.Update("Uploading...")
+	require.Eventually(t, func() bool { return len(api.called("editMessageText")) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	edit := api.called("editMessageText")[0]
+	assert.Equal(t, "Uploading...", edit.params["text"])
+	assert.Equal(t, "10", edit.params["message_id"])
+	assert.Empty(t, edit.params["parse_mode"])
+}
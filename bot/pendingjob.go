@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/events"
+	"github.com/xeptore/tidalgram/provider"
+	"github.com/xeptore/tidalgram/telegram"
+	"github.com/xeptore/tidalgram/tidal"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// jobModeNames are the names jobs are stored with by their modes.
+var jobModeNames = map[jobMode]string{
+	jobModeDownload:     "download",
+	jobModeSync:         "sync",
+	jobModeUpdate:       "update",
+	jobModeDownloadOnly: "download_only",
+}
+
+// savePendingJob stores links, which are left to process since the Telegram session was lost, so that the job
+// is resumed by ResumePendingJob once the bot is logged in again.
+func savePendingJob(
+	ctx context.Context,
+	logger zerolog.Logger,
+	dir fs.DownloadsDir,
+	userID int64,
+	chatID int64,
+	links []types.Link,
+	opts telegram.UploadOptions,
+	mode jobMode,
+) {
+	job := types.StoredPendingJob{
+		ChatID:      chatID,
+		UserID:      userID,
+		Links:       lo.Map(links, func(link types.Link, _ int) string { return link.URL() }),
+		Mode:        jobModeNames[mode],
+		Destination: opts.Destination,
+		Archive:     opts.Archive,
+		Strict:      tidal.IsStrictMetadata(ctx),
+		Notify:      opts.Notify,
+		ScheduleAt:  opts.ScheduleAt,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := dir.PendingJob().InfoFile.Write(job); nil != err {
+		logger.Error().Err(err).Msg("Failed to store pending job. It is not resumed after login")
+		return
+	}
+	logger.Warn().Int("links", len(links)).Msg("Stored pending job to resume once logged in to Telegram again")
+}
+
+// ResumePendingJob runs the job interrupted by the loss of the Telegram session, if any, once the running job,
+// if any, finishes. It is meant to be called once the bot is started. The job is removed before it runs, so
+// that a job that keeps failing is not resumed on every start.
+func ResumePendingJob(
+	ctx context.Context,
+	logger zerolog.Logger,
+	src provider.Provider,
+	up *telegram.Uploader,
+	worker *Worker,
+	store *audit.Store,
+	outbox *Outbox,
+	bus *events.Bus,
+) error {
+	pending := src.DownloadsDir().PendingJob()
+	job, err := pending.Read()
+	if nil != err {
+		return fmt.Errorf("read pending job: %v", err)
+	} else if nil == job {
+		return nil
+	}
+
+	if err := pending.Remove(); nil != err {
+		return fmt.Errorf("remove pending job: %v", err)
+	}
+
+	logger = logger.With().Int64("chat_id", job.ChatID).Time("created_at", job.CreatedAt).Logger()
+
+	mode, ok := lo.FindKey(jobModeNames, job.Mode)
+	if !ok {
+		return fmt.Errorf("unsupported pending job mode: %s", job.Mode)
+	}
+
+	links := make([]types.Link, 0, len(job.Links))
+	for _, raw := range job.Links {
+		link, ok := src.ResolveLink(raw)
+		if !ok {
+			logger.Error().Str("url", raw).Msg("Failed to resolve pending job link. Skipping it")
+			continue
+		}
+		links = append(links, link)
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	ctx, err = worker.AcquireJob(ctx)
+	if nil != err {
+		return err
+	}
+	defer worker.ReleaseJob()
+
+	if job.Strict {
+		ctx = tidal.WithStrictMetadata(ctx)
+	}
+
+	sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+		ParseMode: gotgbot.ParseModeMarkdown,
+	}
+	linkLines := lo.Map(links, func(link types.Link, _ int) string {
+		return link.Kind.String() + ": `" + link.ID + "`"
+	})
+	header := "▶️ Resuming the job interrupted by the loss of the Telegram session:"
+	outbox.Send(job.ChatID, strings.Join(append([]string{header}, linkLines...), "\n"), sendOpt)
+	logger.Info().Int("links", len(links)).Msg("Resuming pending job")
+
+	opts := telegram.UploadOptions{ //nolint:exhaustruct
+		Destination: job.Destination,
+		Archive:     job.Archive,
+		Additions:   mode == jobModeUpdate,
+		Notify:      job.Notify,
+		// Posts whose schedule time passed while the bot was logged out are posted right away.
+		ScheduleAt: job.ScheduleAt,
+	}
+	if ok := processLinks(ctx, logger, outbox, bus, src, up, nil, store, job.UserID, job.ChatID, sendOpt, links, opts, mode); !ok {
+		return nil
+	}
+
+	outbox.Send(job.ChatID, "✅ Resumed Tidal links were successfully uploaded.", sendOpt)
+
+	return nil
+}
+
+// NotifyTelegramUnauthorized tells papa that the Telegram session was lost, and how to log in again. The bot
+// does not need to be started.
+func (b *Bot) NotifyTelegramUnauthorized(ctx context.Context) error {
+	msg := strings.Join(
+		[]string{
+			"🔑 My Telegram session was revoked or expired, papa, e.g., it was terminated from another device.",
+			"",
+			"I'm shutting down, so that I can be logged in again using `tidalgram telegram login --via-bot`. " +
+				"The interrupted job, if any, is resumed once I'm started again.",
+		},
+		"\n",
+	)
+	sendOpts := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+		ParseMode: gotgbot.ParseModeMarkdown,
+	}
+	if _, err := b.bot.SendMessageWithContext(ctx, b.papaChatID, msg, sendOpts); nil != err {
+		return fmt.Errorf("send message: %w", err)
+	}
+
+	return nil
+}
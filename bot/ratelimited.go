@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/events"
+	"github.com/xeptore/tidalgram/provider"
+	"github.com/xeptore/tidalgram/telegram"
+	"github.com/xeptore/tidalgram/tidal"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const (
+	// maxRateLimitRetries is the number of times a link is processed again once its download hits the Tidal
+	// rate limit.
+	maxRateLimitRetries = 3
+	// defaultRateLimitDelay is the delay before the first retry of a link whose throttled response did not tell
+	// how long to wait. It is doubled on each retry.
+	defaultRateLimitDelay = 30 * time.Second
+	// maxRateLimitDelay is the longest delay before retrying a link, no matter how long Tidal tells to wait.
+	maxRateLimitDelay = 10 * time.Minute
+)
+
+// processRetriedLink processes link like processAuditedLink, and processes it again after a delay each time its
+// download hits the Tidal rate limit, up to maxRateLimitRetries times. Every attempt is audited.
+func processRetriedLink(
+	ctx context.Context,
+	logger zerolog.Logger,
+	outbox *Outbox,
+	bus *events.Bus,
+	src provider.Provider,
+	up *telegram.Uploader,
+	exp *exporter,
+	store *audit.Store,
+	userID int64,
+	chatID int64,
+	sendOpt *gotgbot.SendMessageOpts,
+	link types.Link,
+	opts telegram.UploadOptions,
+	mode jobMode,
+) (audit.Outcome, error) {
+	target := link.Kind.String() + " `" + link.ID + "`"
+
+	for retry := 0; ; retry++ {
+		outcome, cause := processAuditedLink(ctx, logger, outbox, bus, src, up, exp, store, userID, chatID, sendOpt, link, opts, mode)
+
+		var rateErr *tidal.RateLimitedError
+		if !errors.As(cause, &rateErr) {
+			return outcome, cause
+		}
+
+		if retry == maxRateLimitRetries {
+			msg := "❌ Tidal rate limit was hit while downloading " + target + ", and retrying it did not help.\n\n" + errorCodeLine(cause)
+			outbox.Send(chatID, msg, sendOpt)
+
+			logger.Error().Err(cause).Msg("Link download kept hitting the Tidal rate limit")
+
+			return outcome, cause
+		}
+
+		delay := rateLimitDelay(rateErr.RetryAfter, retry)
+		msg := "⏳ Tidal rate limit was hit while downloading " + target + ". Retrying in *" + delay.String() + "* " +
+			"(retry " + strconv.Itoa(retry+1) + " of " + strconv.Itoa(maxRateLimitRetries) + ")."
+		outbox.Send(chatID, msg, sendOpt)
+
+		logger.Warn().Err(cause).Dur("delay", delay).Int("retry", retry+1).Msg("Link download hit the Tidal rate limit. Retrying it after delay")
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), ErrJobCanceled) {
+				outbox.Send(chatID, "⏹️ Download was canceled.", sendOpt)
+				return audit.OutcomeCanceled, ctx.Err()
+			}
+
+			outbox.Send(chatID, "♿️ Bot is shutting down. Download was not completed. Try again after bot restart.", sendOpt)
+
+			return audit.OutcomeShutdown, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// rateLimitDelay returns the delay before the retry-th retry of a link, starting from zero, whose throttled
+// response told to wait for retryAfter, if not zero.
+func rateLimitDelay(retryAfter time.Duration, retry int) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, maxRateLimitDelay)
+	}
+
+	return min(defaultRateLimitDelay<<retry, maxRateLimitDelay)
+}
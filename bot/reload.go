@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/telegram"
+	"github.com/xeptore/tidalgram/tidal"
+)
+
+// Reloader reloads the config file, and applies the options that do not require reconnecting, i.e., the Tidal
+// downloader options, e.g., timeouts and concurrency, and the Telegram upload options, e.g., captions and
+// signatures, to new jobs. Changes of other options are only applied on restart.
+type Reloader struct {
+	logger zerolog.Logger
+	// conf is the config the bot was started with.
+	conf   config.Config
+	load   func() (*config.Config, error)
+	td     *tidal.Client
+	up     *telegram.Uploader
+	worker *Worker
+	mu     sync.Mutex
+}
+
+func NewReloader(
+	logger zerolog.Logger,
+	conf config.Config,
+	load func() (*config.Config, error),
+	td *tidal.Client,
+	up *telegram.Uploader,
+	worker *Worker,
+) *Reloader {
+	return &Reloader{
+		logger: logger,
+		conf:   conf,
+		load:   load,
+		td:     td,
+		up:     up,
+		worker: worker,
+		mu:     sync.Mutex{},
+	}
+}
+
+// Reload loads the config file, waits for the running job to finish, and applies it. It returns the config
+// sections with changed options that require a restart to take effect.
+func (r *Reloader) Reload(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.load()
+	if nil != err {
+		return nil, fmt.Errorf("load config: %v", err)
+	}
+
+	// Jobs must not see options change midway, hence they are applied between jobs.
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if _, ok := r.worker.TryAcquireJob(ctx); ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for running job: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	defer r.worker.ReleaseJob()
+
+	telegramRestart, err := r.up.Reload(conf.Telegram)
+	if nil != err {
+		return nil, fmt.Errorf("reload telegram uploader: %v", err)
+	}
+
+	sections := []struct {
+		name    string
+		changed bool
+	}{
+		{name: "bot", changed: !reflect.DeepEqual(r.conf.Bot, conf.Bot)},
+		{name: "log", changed: !reflect.DeepEqual(r.conf.Log, conf.Log)},
+		{name: "tidal", changed: r.td.Reload(conf.Tidal)},
+		{name: "telegram", changed: telegramRestart},
+		{name: "api", changed: !reflect.DeepEqual(r.conf.API, conf.API)},
+		{name: "health", changed: !reflect.DeepEqual(r.conf.Health, conf.Health)},
+		{name: "encryption", changed: !reflect.DeepEqual(r.conf.Encryption, conf.Encryption)},
+	}
+
+	var restart []string
+	for _, section := range sections {
+		if section.changed {
+			restart = append(restart, section.name)
+		}
+	}
+
+	if len(restart) > 0 {
+		r.logger.Warn().Strs("sections", restart).Msg("Config reloaded. Some changed options require a restart to take effect")
+	} else {
+		r.logger.Info().Msg("Config reloaded")
+	}
+	r.logger.Debug().Dict("config", conf.ToDict()).Msg("Reloaded config")
+
+	return restart, nil
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+const (
+	// minScheduleDelay is how far in the future links can be scheduled to be posted at, as their upload takes
+	// a while.
+	minScheduleDelay = time.Minute
+	// maxScheduleDelay is how far in the future Telegram allows messages to be scheduled at.
+	maxScheduleDelay = 365 * 24 * time.Hour
+)
+
+var errInvalidScheduleTime = errors.New("invalid schedule time")
+
+// ParseScheduleTime parses when links are scheduled to be posted at, either as a delay from now, e.g., 2h30m,
+// or as a time of day in the time zone of now, e.g., 21:00, which is the next one after now.
+func ParseScheduleTime(s string, now time.Time) (time.Time, error) {
+	if delay, err := time.ParseDuration(s); nil == err {
+		if delay < minScheduleDelay || delay > maxScheduleDelay {
+			return time.Time{}, fmt.Errorf("%w: delay must be between %s and %s", errInvalidScheduleTime, minScheduleDelay, maxScheduleDelay)
+		}
+
+		return now.Add(delay), nil
+	}
+
+	clock, err := time.ParseInLocation("15:04", s, now.Location())
+	if nil != err {
+		return time.Time{}, fmt.Errorf("%w: %q is neither a delay nor a time of day", errInvalidScheduleTime, s)
+	}
+
+	at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if at.Before(now.Add(minScheduleDelay)) {
+		at = at.AddDate(0, 0, 1)
+	}
+
+	return at, nil
+}
+
+// extractSchedule returns when the links of the "/schedule <time> <links>" message are asked to be posted at.
+// It returns the zero time if the message is not a schedule command, and false if the time is missing or
+// invalid.
+func extractSchedule(msg *gotgbot.Message, now time.Time) (time.Time, bool) {
+	if !hasCommand(msg, scheduleCommand) {
+		return time.Time{}, true
+	}
+
+	fields := strings.Fields(msg.Text)
+	if len(fields) < 2 {
+		return time.Time{}, false
+	}
+
+	at, err := ParseScheduleTime(fields[1], now)
+	if nil != err {
+		return time.Time{}, false
+	}
+
+	return at, true
+}
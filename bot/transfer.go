@@ -0,0 +1,275 @@
+package bot
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// transferPartSize is the maximum size of each sent part of a transfer, which stays below the 50 MB document
+	// size limit of the Bot API.
+	transferPartSize = 45 * 1024 * 1024
+	// localTransferPartSize is transferPartSize for local Bot API servers, which stays below their 2000 MB
+	// document size limit.
+	localTransferPartSize = 1950 * 1024 * 1024
+	// cloudAPIHost is the host of the Bot API server hosted by Telegram.
+	cloudAPIHost = "api.telegram.org"
+)
+
+// IsLocalAPIServer reports whether apiURL is the URL of a local Bot API server, i.e., any server other than the
+// one hosted by Telegram. Local servers accept larger files, which they read from the file system given their
+// file:// URIs, rather than receiving them in requests.
+func IsLocalAPIServer(apiURL string) bool {
+	u, err := url.Parse(apiURL)
+	if nil != err {
+		return false
+	}
+
+	return len(u.Hostname()) > 0 && !strings.EqualFold(u.Hostname(), cloudAPIHost)
+}
+
+// transferFile is a file included in a transfer.
+type transferFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Open    func() (io.ReadCloser, error)
+}
+
+// sendTransfer sends files to chatID as a zstd-compressed tar archive named name.tar.zst, replying to replyTo.
+// A manifest message describing the archive contents is sent first, and the archive is sent in parts of at
+// most transferPartSize, or localTransferPartSize if localAPI is set, replying to the manifest, which can be
+// joined back together using cat. Parts are sent to local Bot API servers by their file paths.
+func sendTransfer(
+	ctx context.Context,
+	logger zerolog.Logger,
+	b *gotgbot.Bot,
+	localAPI bool,
+	chatID int64,
+	replyTo int64,
+	title string,
+	name string,
+	files []transferFile,
+) (err error) {
+	archive, err := os.CreateTemp("", "tidalgram-transfer-*.tar.zst")
+	if nil != err {
+		return fmt.Errorf("create archive file: %v", err)
+	}
+	defer func() {
+		if closeErr := archive.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close archive file: %v", closeErr))
+		}
+		if removeErr := os.Remove(archive.Name()); nil != removeErr {
+			logger.Error().Err(removeErr).Str("path", archive.Name()).Msg("Failed to remove transfer archive file")
+		}
+	}()
+
+	hash := sha256.New()
+	if err := writeTransferArchive(io.MultiWriter(archive, hash), files); nil != err {
+		return fmt.Errorf("write archive: %v", err)
+	}
+
+	stat, err := archive.Stat()
+	if nil != err {
+		return fmt.Errorf("stat archive file: %v", err)
+	}
+	size := stat.Size()
+	partSize := int64(transferPartSize)
+	if localAPI {
+		partSize = localTransferPartSize
+	}
+	parts := max(1, int((size+partSize-1)/partSize))
+	fileName := name + ".tar.zst"
+
+	manifest := transferManifest(title, fileName, files, size, parts, hex.EncodeToString(hash.Sum(nil)))
+	sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+		ParseMode: gotgbot.ParseModeMarkdown,
+		ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+			MessageId: replyTo,
+		},
+	}
+	msg, err := b.SendMessageWithContext(ctx, chatID, manifest, sendOpt)
+	if nil != err {
+		return fmt.Errorf("send manifest message: %w", err)
+	}
+
+	for i := range parts {
+		partName := fileName
+		if parts > 1 {
+			partName = fmt.Sprintf("%s.%03d", fileName, i+1)
+		}
+
+		opts := &gotgbot.SendDocumentOpts{ //nolint:exhaustruct
+			Caption: "📦 Part " + strconv.Itoa(i+1) + " / " + strconv.Itoa(parts),
+			ReplyParameters: &gotgbot.ReplyParameters{ //nolint:exhaustruct
+				MessageId: msg.MessageId,
+			},
+		}
+		part := io.NewSectionReader(archive, int64(i)*partSize, partSize)
+		if err := sendTransferPart(ctx, logger, b, localAPI, chatID, partName, part, opts); nil != err {
+			return fmt.Errorf("send archive part %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// sendTransferPart sends part as a document named name. Parts are written to temporary files, and sent by their
+// file:// URIs, if localAPI is set, which requires the local Bot API server to share the temporary directory.
+func sendTransferPart(
+	ctx context.Context,
+	logger zerolog.Logger,
+	b *gotgbot.Bot,
+	localAPI bool,
+	chatID int64,
+	name string,
+	part io.Reader,
+	opts *gotgbot.SendDocumentOpts,
+) (err error) {
+	if !localAPI {
+		if _, err := b.SendDocumentWithContext(ctx, chatID, gotgbot.InputFileByReader(name, part), opts); nil != err {
+			return fmt.Errorf("send document: %w", err)
+		}
+
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "tidalgram-transfer-part-*")
+	if nil != err {
+		return fmt.Errorf("create part directory: %v", err)
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(dir); nil != removeErr {
+			logger.Error().Err(removeErr).Str("path", dir).Msg("Failed to remove transfer part directory")
+		}
+	}()
+
+	// The local Bot API server names documents sent by path after their file names.
+	path, err := filepath.Abs(filepath.Join(dir, name))
+	if nil != err {
+		return fmt.Errorf("get part file absolute path: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if nil != err {
+		return fmt.Errorf("create part file: %v", err)
+	}
+	if _, err := io.Copy(f, part); nil != err {
+		return errors.Join(fmt.Errorf("write part file: %v", err), f.Close())
+	}
+	if err := f.Close(); nil != err {
+		return fmt.Errorf("close part file: %v", err)
+	}
+
+	fileURL := (&url.URL{Scheme: "file", Path: path}).String() //nolint:exhaustruct
+	if _, err := b.SendDocumentWithContext(ctx, chatID, gotgbot.InputFileByURL(fileURL), opts); nil != err {
+		return fmt.Errorf("send document by path: %w", err)
+	}
+
+	return nil
+}
+
+func writeTransferArchive(w io.Writer, files []transferFile) error {
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if nil != err {
+		return fmt.Errorf("create zstd encoder: %v", err)
+	}
+
+	tw := tar.NewWriter(enc)
+	for _, f := range files {
+		if err := writeTransferFile(tw, f); nil != err {
+			return errors.Join(fmt.Errorf("write %s: %v", f.Name, err), enc.Close())
+		}
+	}
+
+	if err := tw.Close(); nil != err {
+		return errors.Join(fmt.Errorf("close tar writer: %v", err), enc.Close())
+	}
+
+	if err := enc.Close(); nil != err {
+		return fmt.Errorf("close zstd encoder: %v", err)
+	}
+
+	return nil
+}
+
+func writeTransferFile(tw *tar.Writer, f transferFile) (err error) {
+	r, err := f.Open()
+	if nil != err {
+		return fmt.Errorf("open file: %v", err)
+	}
+	defer func() {
+		if closeErr := r.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+		}
+	}()
+
+	header := &tar.Header{ //nolint:exhaustruct
+		Typeflag: tar.TypeReg,
+		Name:     f.Name,
+		Size:     f.Size,
+		Mode:     0o600,
+		ModTime:  f.ModTime,
+	}
+	if err := tw.WriteHeader(header); nil != err {
+		return fmt.Errorf("write tar header: %v", err)
+	}
+
+	if _, err := io.Copy(tw, r); nil != err {
+		return fmt.Errorf("write tar entry: %v", err)
+	}
+
+	return nil
+}
+
+func transferManifest(title, fileName string, files []transferFile, size int64, parts int, sum string) string {
+	lines := []string{"📦 " + title, "", "Contents:"}
+	for _, f := range files {
+		lines = append(lines, "• `"+f.Name+"` ("+formatBytes(f.Size)+")")
+	}
+
+	lines = append(
+		lines,
+		"",
+		"🗜️ `"+fileName+"` · "+formatBytes(size)+" in "+strconv.Itoa(parts)+" part(s)",
+		"🔐 SHA-256: `"+sum+"`",
+	)
+	if parts > 1 {
+		lines = append(lines, "🧩 Join: `cat "+fileName+".* | zstd -d | tar -x`")
+	} else {
+		lines = append(lines, "🧩 Extract: `zstd -d < "+fileName+" | tar -x`")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatBytes formats n bytes using the largest binary unit it is at least one of, e.g., 1.5 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "iB"
+}
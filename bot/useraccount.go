@@ -0,0 +1,768 @@
+package bot
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/telegram/query"
+	"github.com/gotd/td/telegram/query/dialogs"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/markdown"
+)
+
+// maxPendingUserUpdates is the number of received messages that are buffered while the dispatcher is busy, after
+// which messages are dropped, as the MTProto connection must not be blocked by handlers sending replies over it.
+const maxPendingUserUpdates = 100
+
+var errUnsupportedUserMethod = errors.New("method is not supported by the user account frontend")
+
+// UserUpdates receives the private messages sent by papa, and mama, to the logged in Telegram account, and feeds
+// them to the bot dispatcher as Bot API updates. It is the MTProto update handler of the upload session, which
+// must be created before the bot, hence it drops messages until the bot is started. Messages of other users are
+// dropped too, so that strangers can't talk to the account, and its replies.
+type UserUpdates struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+	// updates is nil while the bot is not started.
+	updates chan json.RawMessage
+	// senders are the users whose messages are received, by their IDs.
+	senders  map[int64]gotgbot.User
+	updateID int64
+}
+
+func NewUserUpdates(logger zerolog.Logger) *UserUpdates {
+	return &UserUpdates{
+		logger:   logger,
+		mu:       sync.Mutex{},
+		updates:  nil,
+		senders:  nil,
+		updateID: 0,
+	}
+}
+
+func (u *UserUpdates) start(senders map[int64]gotgbot.User) <-chan json.RawMessage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.senders = senders
+	u.updates = make(chan json.RawMessage, maxPendingUserUpdates)
+
+	return u.updates
+}
+
+func (u *UserUpdates) stop() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if nil != u.updates {
+		close(u.updates)
+		u.updates = nil
+	}
+}
+
+// Handle implements the gotd update handler interface.
+func (u *UserUpdates) Handle(_ context.Context, updates tg.UpdatesClass) error {
+	var list []tg.UpdateClass
+	switch updates := updates.(type) {
+	case *tg.Updates:
+		list = updates.Updates
+	case *tg.UpdatesCombined:
+		list = updates.Updates
+	case *tg.UpdateShort:
+		list = []tg.UpdateClass{updates.Update}
+	default:
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if nil == u.updates {
+		return nil
+	}
+
+	for _, update := range list {
+		newMessage, ok := update.(*tg.UpdateNewMessage)
+		if !ok {
+			continue
+		}
+
+		msg, ok := newMessage.Message.(*tg.Message)
+		if !ok || msg.Out {
+			continue
+		}
+
+		peer, ok := msg.PeerID.(*tg.PeerUser)
+		if !ok {
+			continue
+		}
+
+		sender, ok := u.senders[peer.UserID]
+		if !ok {
+			continue
+		}
+
+		u.updateID++
+		raw, err := json.Marshal(gotgbot.Update{ //nolint:exhaustruct
+			UpdateId: u.updateID,
+			Message:  userMessage(msg, sender),
+		})
+		if nil != err {
+			u.logger.Error().Err(err).Int("message_id", msg.ID).Msg("Failed to encode received message")
+			continue
+		}
+
+		select {
+		case u.updates <- raw:
+		default:
+			u.logger.Warn().Int("message_id", msg.ID).Int64("sender_id", sender.Id).Msg("Too many pending messages. Dropping received message")
+		}
+	}
+
+	return nil
+}
+
+// userMessage converts msg, a private message received from sender, to its Bot API form. Only the fields read by
+// the handlers are set.
+func userMessage(msg *tg.Message, sender gotgbot.User) *gotgbot.Message {
+	out := &gotgbot.Message{ //nolint:exhaustruct
+		MessageId: int64(msg.ID),
+		From:      &sender,
+		Date:      int64(msg.Date),
+		Chat: gotgbot.Chat{ //nolint:exhaustruct
+			Id:        sender.Id,
+			Type:      gotgbot.ChatTypePrivate,
+			Username:  sender.Username,
+			FirstName: sender.FirstName,
+			LastName:  sender.LastName,
+		},
+	}
+
+	if media, ok := msg.Media.(*tg.MessageMediaDocument); ok {
+		if doc, ok := media.Document.(*tg.Document); ok {
+			out.Document = &gotgbot.Document{ //nolint:exhaustruct
+				FileId:       encodeUserFileID(doc),
+				FileUniqueId: strconv.FormatInt(doc.ID, 10),
+				FileName:     documentFileName(doc),
+				MimeType:     doc.MimeType,
+				FileSize:     doc.Size,
+			}
+		}
+		out.Caption, out.CaptionEntities = msg.Message, userEntities(msg.Message, msg.Entities)
+
+		return out
+	}
+
+	out.Text, out.Entities = msg.Message, userEntities(msg.Message, msg.Entities)
+
+	return out
+}
+
+// userEntities converts the URL, and bot command, entities of text. Unlike the Bot API, MTProto does not detect
+// them in every message, e.g., in messages sent by other MTProto clients, hence they are detected if missing.
+func userEntities(text string, entities []tg.MessageEntityClass) []gotgbot.MessageEntity {
+	var (
+		out        []gotgbot.MessageEntity
+		hasURL     bool
+		hasCommand bool
+	)
+	for _, ent := range entities {
+		switch ent := ent.(type) {
+		case *tg.MessageEntityURL:
+			hasURL = true
+			out = append(out, gotgbot.MessageEntity{Type: "url", Offset: int64(ent.Offset), Length: int64(ent.Length)}) //nolint:exhaustruct
+		case *tg.MessageEntityTextURL:
+			out = append(out, gotgbot.MessageEntity{Type: "text_link", Offset: int64(ent.Offset), Length: int64(ent.Length), Url: ent.URL}) //nolint:exhaustruct
+		case *tg.MessageEntityBotCommand:
+			hasCommand = true
+			out = append(out, gotgbot.MessageEntity{Type: "bot_command", Offset: int64(ent.Offset), Length: int64(ent.Length)}) //nolint:exhaustruct
+		}
+	}
+
+	if !hasCommand && strings.HasPrefix(text, "/") {
+		command := strings.Fields(text)[0]
+		out = append([]gotgbot.MessageEntity{{Type: "bot_command", Offset: 0, Length: utf16Len(command)}}, out...) //nolint:exhaustruct
+	}
+
+	if !hasURL {
+		var offset int64
+		for _, word := range strings.FieldsFunc(text, isSpace) {
+			// Words are found in order, hence the offset of each is searched for after the previous one.
+			i := strings.Index(text[offset:], word)
+			start := utf16Len(text[:int(offset)+i])
+			offset += int64(i + len(word))
+
+			if strings.HasPrefix(word, "https://") || strings.HasPrefix(word, "http://") {
+				out = append(out, gotgbot.MessageEntity{Type: "url", Offset: start, Length: utf16Len(word)}) //nolint:exhaustruct
+			}
+		}
+	}
+
+	return out
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r'
+}
+
+// utf16Len returns the length of s in UTF-16 code units, which entity offsets, and lengths, are measured in.
+func utf16Len(s string) int64 {
+	return int64(len(utf16.Encode([]rune(s))))
+}
+
+func documentFileName(doc *tg.Document) string {
+	for _, attr := range doc.Attributes {
+		if attr, ok := attr.(*tg.DocumentAttributeFilename); ok {
+			return attr.FileName
+		}
+	}
+
+	return ""
+}
+
+// encodeUserFileID encodes the location of doc into a file ID, which can be downloaded by [userClient.OpenFile].
+func encodeUserFileID(doc *tg.Document) string {
+	return strconv.FormatInt(doc.ID, 10) + ":" +
+		strconv.FormatInt(doc.AccessHash, 10) + ":" +
+		base64.RawURLEncoding.EncodeToString(doc.FileReference)
+}
+
+func decodeUserFileID(fileID string) (*tg.InputDocumentFileLocation, error) {
+	parts := strings.Split(fileID, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid file ID: %s", fileID)
+	}
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if nil != err {
+		return nil, fmt.Errorf("parse file ID document ID: %v", err)
+	}
+
+	accessHash, err := strconv.ParseInt(parts[1], 10, 64)
+	if nil != err {
+		return nil, fmt.Errorf("parse file ID access hash: %v", err)
+	}
+
+	fileReference, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if nil != err {
+		return nil, fmt.Errorf("decode file ID file reference: %v", err)
+	}
+
+	return &tg.InputDocumentFileLocation{
+		ID:            id,
+		AccessHash:    accessHash,
+		FileReference: fileReference,
+		ThumbSize:     "",
+	}, nil
+}
+
+// NewUserAccount creates a bot that receives messages with updates, and replies to them as the Telegram account
+// logged in to api, instead of using the Bot API. Papa, and mama, must be among the dialogs of the account.
+func NewUserAccount(
+	ctx context.Context,
+	logger zerolog.Logger,
+	conf config.Bot,
+	api *tg.Client,
+	updates *UserUpdates,
+) (*Bot, error) {
+	users, err := api.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}})
+	if nil != err {
+		return nil, fmt.Errorf("get self: %w", err)
+	}
+	if len(users) == 0 {
+		return nil, errors.New("get self: no user returned")
+	}
+	self, ok := users[0].(*tg.User)
+	if !ok {
+		return nil, fmt.Errorf("get self: unexpected user type: %T", users[0])
+	}
+
+	client := &userClient{
+		api:   api,
+		peers: make(map[int64]tg.InputPeerClass),
+	}
+	senders, err := client.resolveUsers(ctx, conf.PapaID, conf.MamaID)
+	if nil != err {
+		return nil, err
+	}
+
+	b, err := gotgbot.NewBot("", &gotgbot.BotOpts{ //nolint:exhaustruct
+		BotClient:         client,
+		DisableTokenCheck: true,
+	})
+	if nil != err {
+		return nil, fmt.Errorf("create bot: %v", err)
+	}
+	b.User = gotgbot.User{ //nolint:exhaustruct
+		Id:        self.ID,
+		IsBot:     self.Bot,
+		FirstName: self.FirstName,
+		LastName:  self.LastName,
+		Username:  self.Username,
+		IsPremium: self.Premium,
+	}
+
+	dispatcher := newDispatcher(ctx, logger)
+	logger.Info().Int64("id", self.ID).Msg("Receiving, and replying to, messages as the logged in Telegram account")
+
+	return &Bot{
+		bot:        b,
+		updater:    nil,
+		dispatcher: dispatcher,
+		logger:     logger,
+		papaChatID: conf.PapaID,
+		mamaChatID: conf.MamaID,
+		webhook:    conf.Webhook,
+		updates:    updates,
+		senders:    senders,
+		dispatched: nil,
+		Account:    fillAccount(b),
+	}, nil
+}
+
+// userClient implements the Bot API methods used by the bot with MTProto requests of a user account. Messages
+// can only be sent to the users resolved by [userClient.resolveUsers].
+type userClient struct {
+	api *tg.Client
+	// peers are the resolved users, by their IDs. It is only written before the bot is started.
+	peers map[int64]tg.InputPeerClass
+}
+
+// resolveUsers resolves the users with ids among the dialogs of the account, and returns their Bot API forms.
+// Zero IDs are skipped.
+func (c *userClient) resolveUsers(ctx context.Context, ids ...int64) (map[int64]gotgbot.User, error) {
+	out := make(map[int64]gotgbot.User, len(ids))
+	wanted := 0
+	for _, id := range ids {
+		if id != 0 {
+			wanted++
+		}
+	}
+
+	var dialogKey dialogs.DialogKey
+	err := query.
+		GetDialogs(c.api).
+		ForEach(ctx, func(ctx context.Context, elem dialogs.Elem) error {
+			if err := dialogKey.FromInputPeer(elem.Peer); nil != err {
+				return fmt.Errorf("get dialog key: %v", err)
+			}
+			if dialogKey.Kind != dialogs.User {
+				return nil
+			}
+
+			for _, id := range ids {
+				if id == 0 || id != dialogKey.ID {
+					continue
+				}
+
+				user, ok := elem.Entities.User(id)
+				if !ok {
+					continue
+				}
+
+				c.peers[id] = elem.Peer
+				out[id] = gotgbot.User{ //nolint:exhaustruct
+					Id:        id,
+					IsBot:     user.Bot,
+					FirstName: user.FirstName,
+					LastName:  user.LastName,
+					Username:  user.Username,
+					IsPremium: user.Premium,
+				}
+			}
+
+			if len(out) == wanted {
+				return os.ErrExist
+			}
+
+			return nil
+		})
+	if nil != err && !errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("get dialogs: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, ok := out[id]; id != 0 && !ok {
+			return nil, fmt.Errorf("user %d was not found among the dialogs of the account: %w", id, ErrChatNotFound)
+		}
+	}
+
+	return out, nil
+}
+
+func (c *userClient) RequestWithContext(
+	ctx context.Context,
+	_ string,
+	method string,
+	params map[string]any,
+	_ *gotgbot.RequestOpts,
+) (json.RawMessage, error) {
+	var (
+		out json.RawMessage
+		err error
+	)
+	switch method {
+	case "sendMessage":
+		out, err = c.sendMessage(ctx, params)
+	case "editMessageText":
+		out, err = c.editMessageText(ctx, params)
+	case "deleteMessage":
+		ids, _ := params["message_id"].(int64)
+		out, err = c.deleteMessages(ctx, []int64{ids})
+	case "deleteMessages":
+		ids, _ := params["message_ids"].([]int64)
+		out, err = c.deleteMessages(ctx, ids)
+	case "sendDocument":
+		out, err = c.sendDocument(ctx, params)
+	case "getFile":
+		fileID, _ := params["file_id"].(string)
+		out, err = json.Marshal(gotgbot.File{FileId: fileID, FilePath: fileID}) //nolint:exhaustruct
+	case "setMyCommands", "deleteMyCommands", "deleteWebhook":
+		// Commands, and webhooks, are only available to bots, and there is nothing to undo.
+		out = json.RawMessage("true")
+	default:
+		return nil, fmt.Errorf("%s: %w", method, errUnsupportedUserMethod)
+	}
+	if nil != err {
+		return nil, telegramError(method, params, err)
+	}
+
+	return out, nil
+}
+
+func (c *userClient) GetAPIURL(*gotgbot.RequestOpts) string {
+	return ""
+}
+
+func (c *userClient) FileURL(_ string, tgFilePath string, _ *gotgbot.RequestOpts) string {
+	return tgFilePath
+}
+
+// OpenFile streams the content of file, whose path is its file ID. Only links files are opened, hence the
+// download is stopped, and reading fails with errLinksFileTooLarge, once it exceeds maxLinksFileSize.
+func (c *userClient) OpenFile(ctx context.Context, file *gotgbot.File) (io.ReadCloser, error) {
+	location, err := decodeUserFileID(file.FilePath)
+	if nil != err {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		// The download fails, and stops, once r is closed, as writes to w fail then.
+		dst := &limitedWriter{w: w, remaining: maxLinksFileSize}
+		if _, err := downloader.NewDownloader().Download(c.api, location).Stream(ctx, dst); nil != err {
+			_ = w.CloseWithError(fmt.Errorf("download document: %w", err))
+			return
+		}
+		_ = w.Close()
+	}()
+
+	return r, nil
+}
+
+// limitedWriter writes to w up to remaining bytes, and fails with errLinksFileTooLarge beyond that.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		n, err := l.w.Write(p[:l.remaining])
+		l.remaining -= int64(n)
+		if nil != err {
+			return n, err
+		}
+
+		return n, errLinksFileTooLarge
+	}
+
+	n, err := l.w.Write(p)
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+func (c *userClient) peer(params map[string]any) (tg.InputPeerClass, error) {
+	chatID, _ := params["chat_id"].(int64)
+	peer, ok := c.peers[chatID]
+	if !ok {
+		return nil, fmt.Errorf("chat %d: %w", chatID, ErrChatNotFound)
+	}
+
+	return peer, nil
+}
+
+// formatted returns the plain text, and entities, of the text param formatted in the style of the parse_mode
+// param.
+func formatted(params map[string]any, key string) (string, []tg.MessageEntityClass) {
+	text, _ := params[key].(string)
+	switch params["parse_mode"] {
+	case gotgbot.ParseModeMarkdownV2:
+		return markdown.Parse(text, true)
+	case gotgbot.ParseModeMarkdown:
+		return markdown.Parse(text, false)
+	default:
+		return text, nil
+	}
+}
+
+func replyTo(params map[string]any) (tg.InputReplyToClass, bool) {
+	reply, _ := params["reply_parameters"].(*gotgbot.ReplyParameters)
+	if nil == reply || reply.MessageId == 0 {
+		return nil, false
+	}
+
+	return &tg.InputReplyToMessage{ReplyToMsgID: int(reply.MessageId)}, true //nolint:exhaustruct
+}
+
+func (c *userClient) sendMessage(ctx context.Context, params map[string]any) (json.RawMessage, error) {
+	peer, err := c.peer(params)
+	if nil != err {
+		return nil, err
+	}
+
+	text, entities := formatted(params, "text")
+	req := &tg.MessagesSendMessageRequest{ //nolint:exhaustruct
+		Peer:     peer,
+		Message:  text,
+		RandomID: rand.Int64(),
+	}
+	if len(entities) > 0 {
+		req.SetEntities(entities)
+	}
+	if reply, ok := replyTo(params); ok {
+		req.SetReplyTo(reply)
+	}
+	if silent, _ := params["disable_notification"].(bool); silent {
+		req.SetSilent(true)
+	}
+
+	updates, err := c.api.MessagesSendMessage(ctx, req)
+	if nil != err {
+		return nil, err
+	}
+
+	return sentMessage(params, updates)
+}
+
+func (c *userClient) editMessageText(ctx context.Context, params map[string]any) (json.RawMessage, error) {
+	peer, err := c.peer(params)
+	if nil != err {
+		return nil, err
+	}
+
+	messageID, _ := params["message_id"].(int64)
+	text, entities := formatted(params, "text")
+	req := &tg.MessagesEditMessageRequest{ //nolint:exhaustruct
+		Peer: peer,
+		ID:   int(messageID),
+	}
+	req.SetMessage(text)
+	req.SetEntities(entities)
+
+	if _, err := c.api.MessagesEditMessage(ctx, req); nil != err {
+		return nil, err
+	}
+
+	return json.RawMessage("true"), nil
+}
+
+func (c *userClient) deleteMessages(ctx context.Context, ids []int64) (json.RawMessage, error) {
+	req := &tg.MessagesDeleteMessagesRequest{ //nolint:exhaustruct
+		Revoke: true,
+		ID:     make([]int, len(ids)),
+	}
+	for i, id := range ids {
+		req.ID[i] = int(id)
+	}
+
+	if _, err := c.api.MessagesDeleteMessages(ctx, req); nil != err {
+		return nil, err
+	}
+
+	return json.RawMessage("true"), nil
+}
+
+// sendDocument uploads the document param, which is either sent by reader, or by its file:// URL, as the local
+// Bot API server expects.
+func (c *userClient) sendDocument(ctx context.Context, params map[string]any) (json.RawMessage, error) {
+	peer, err := c.peer(params)
+	if nil != err {
+		return nil, err
+	}
+
+	doc, ok := params["document"].(*gotgbot.FileReader)
+	if !ok {
+		return nil, fmt.Errorf("unsupported document type: %T", params["document"])
+	}
+
+	name, data := doc.Name, doc.Data
+	if nil == data {
+		raw, err := json.Marshal(doc)
+		if nil != err {
+			return nil, fmt.Errorf("encode document: %v", err)
+		}
+
+		var fileURL string
+		if err := json.Unmarshal(raw, &fileURL); nil != err {
+			return nil, fmt.Errorf("decode document URL: %v", err)
+		}
+
+		u, err := url.Parse(fileURL)
+		if nil != err || u.Scheme != "file" {
+			return nil, fmt.Errorf("unsupported document URL: %s", fileURL)
+		}
+
+		f, err := os.Open(u.Path)
+		if nil != err {
+			return nil, fmt.Errorf("open document file: %v", err)
+		}
+		defer f.Close()
+
+		name, data = filepath.Base(u.Path), f
+	}
+
+	file, err := uploader.NewUploader(c.api).FromReader(ctx, name, data)
+	if nil != err {
+		return nil, fmt.Errorf("upload document: %w", err)
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	caption, entities := formatted(params, "caption")
+	req := &tg.MessagesSendMediaRequest{ //nolint:exhaustruct
+		Peer: peer,
+		Media: &tg.InputMediaUploadedDocument{ //nolint:exhaustruct
+			ForceFile:  true,
+			File:       file,
+			MimeType:   mimeType,
+			Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: name}},
+		},
+		Message:  caption,
+		RandomID: rand.Int64(),
+	}
+	if len(entities) > 0 {
+		req.SetEntities(entities)
+	}
+	if reply, ok := replyTo(params); ok {
+		req.SetReplyTo(reply)
+	}
+
+	updates, err := c.api.MessagesSendMedia(ctx, req)
+	if nil != err {
+		return nil, err
+	}
+
+	return sentMessage(params, updates)
+}
+
+// sentMessage returns the Bot API form of the message sent by the request with params, which resulted in updates.
+func sentMessage(params map[string]any, updates tg.UpdatesClass) (json.RawMessage, error) {
+	var id int
+	switch updates := updates.(type) {
+	case *tg.UpdateShortSentMessage:
+		id = updates.ID
+	case *tg.UpdateShort:
+		id = sentMessageID([]tg.UpdateClass{updates.Update})
+	case *tg.Updates:
+		id = sentMessageID(updates.Updates)
+	case *tg.UpdatesCombined:
+		id = sentMessageID(updates.Updates)
+	}
+	if id == 0 {
+		return nil, fmt.Errorf("sent message ID not found in updates of type %T", updates)
+	}
+
+	chatID, _ := params["chat_id"].(int64)
+	out, err := json.Marshal(gotgbot.Message{ //nolint:exhaustruct
+		MessageId: int64(id),
+		Chat:      gotgbot.Chat{Id: chatID, Type: gotgbot.ChatTypePrivate}, //nolint:exhaustruct
+	})
+	if nil != err {
+		return nil, fmt.Errorf("encode sent message: %v", err)
+	}
+
+	return out, nil
+}
+
+func sentMessageID(updates []tg.UpdateClass) int {
+	for _, update := range updates {
+		switch update := update.(type) {
+		case *tg.UpdateMessageID:
+			return update.ID
+		case *tg.UpdateNewMessage:
+			return update.Message.GetID()
+		}
+	}
+
+	return 0
+}
+
+// telegramError converts the RPC errors of MTProto requests to their Bot API form, so that the callers can handle
+// them the same, e.g., retry after FLOOD_WAIT errors, which are reported as HTTP 429 errors by the Bot API.
+func telegramError(method string, params map[string]any, err error) error {
+	rpcErr, ok := tgerr.As(err)
+	if !ok {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	out := &gotgbot.TelegramError{
+		Method:         method,
+		Params:         params,
+		Code:           rpcErr.Code,
+		Description:    rpcErr.Message,
+		ResponseParams: nil,
+	}
+	if d, ok := tgerr.AsFloodWait(err); ok {
+		out.Code = http.StatusTooManyRequests
+		out.ResponseParams = &gotgbot.ResponseParameters{ //nolint:exhaustruct
+			RetryAfter: int64(d.Seconds()),
+		}
+	}
+
+	return out
+}
+
+// startUserAccount feeds the messages received by the user account frontend to the dispatcher.
+func (b *Bot) startUserAccount() {
+	updates := b.updates.start(b.senders)
+	b.dispatched = make(chan struct{})
+	go func() {
+		defer close(b.dispatched)
+		b.dispatcher.Start(b.bot, updates)
+	}()
+}
+
+// stopUserAccount stops receiving messages, and waits for the received ones to be handled.
+func (b *Bot) stopUserAccount() {
+	b.updates.stop()
+	if nil != b.dispatched {
+		<-b.dispatched
+	}
+	b.dispatcher.Stop()
+}
@@ -0,0 +1,264 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/events"
+	"github.com/xeptore/tidalgram/janitor"
+	"github.com/xeptore/tidalgram/provider"
+	"github.com/xeptore/tidalgram/telegram"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+var ErrUnsupportedWatchLink = errors.New("only playlist and mix links can be watched")
+
+// Watcher periodically syncs the watched playlists and mixes, uploading their newly added tracks.
+// Watched links are the ones set in config, plus the ones added using the watch command.
+type Watcher struct {
+	mu          sync.Mutex
+	logger      zerolog.Logger
+	schedule    cron.Schedule
+	configLinks []types.Link
+	list        fs.Watchlist
+	src         provider.Provider
+	up          *telegram.Uploader
+	worker      *Worker
+	store       *audit.Store
+	janitor     *janitor.Janitor
+	outbox      *Outbox
+	bus         *events.Bus
+	maintenance *Maintenance
+}
+
+// NewWatcher returns a watcher syncing the watched links on schedule, e.g., parsed from config using
+// [config.ParseWatchSchedule]. It fails if any of configURLs is not a playlist, or mix, link.
+func NewWatcher(
+	logger zerolog.Logger,
+	schedule cron.Schedule,
+	configURLs []string,
+	src provider.Provider,
+	up *telegram.Uploader,
+	worker *Worker,
+	store *audit.Store,
+	jn *janitor.Janitor,
+	outbox *Outbox,
+	bus *events.Bus,
+	maintenance *Maintenance,
+) (*Watcher, error) {
+	configLinks := make([]types.Link, 0, len(configURLs))
+	for _, u := range configURLs {
+		link, err := parseWatchLink(u)
+		if nil != err {
+			return nil, err
+		}
+
+		configLinks = append(configLinks, link)
+	}
+
+	return &Watcher{
+		mu:          sync.Mutex{},
+		logger:      logger,
+		schedule:    schedule,
+		configLinks: lo.Uniq(configLinks),
+		list:        src.DownloadsDir().Watchlist(),
+		src:         src,
+		up:          up,
+		worker:      worker,
+		store:       store,
+		janitor:     jn,
+		outbox:      outbox,
+		bus:         bus,
+		maintenance: maintenance,
+	}, nil
+}
+
+// Links returns all watched links.
+func (w *Watcher) Links() ([]types.Link, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stored, err := w.storedLinks()
+	if nil != err {
+		return nil, err
+	}
+
+	return lo.Uniq(append(slices.Clone(w.configLinks), stored...)), nil
+}
+
+// Add adds link to the watchlist. It returns false if link is already watched.
+func (w *Watcher) Add(link types.Link) (bool, error) {
+	if !isWatchableLink(link) {
+		return false, ErrUnsupportedWatchLink
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stored, err := w.storedLinks()
+	if nil != err {
+		return false, err
+	}
+
+	if lo.Contains(w.configLinks, link) || lo.Contains(stored, link) {
+		return false, nil
+	}
+
+	if err := w.writeLinks(append(stored, link)); nil != err {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Remove removes link from the watchlist. It returns false if link was not added using the watch command.
+func (w *Watcher) Remove(link types.Link) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stored, err := w.storedLinks()
+	if nil != err {
+		return false, err
+	}
+
+	if !lo.Contains(stored, link) {
+		return false, nil
+	}
+
+	if err := w.writeLinks(lo.Without(stored, link)); nil != err {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// IsConfigured reports whether link is watched via config, and hence cannot be removed using the unwatch command.
+func (w *Watcher) IsConfigured(link types.Link) bool {
+	return lo.Contains(w.configLinks, link)
+}
+
+// Run syncs all watched links on the configured schedule, reporting the results to papa, until ctx is done.
+// Scheduled syncs that are due while a previous one is still running are skipped.
+func (w *Watcher) Run(ctx context.Context, b *Bot) {
+	for {
+		next := w.schedule.Next(time.Now())
+		w.logger.Debug().Time("at", next).Msg("Scheduled next watched links sync round")
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			w.syncAll(ctx, b)
+		}
+	}
+}
+
+func (w *Watcher) syncAll(ctx context.Context, b *Bot) {
+	if w.maintenance.Enabled() {
+		w.logger.Warn().Msg("Skipping watched links sync round due to maintenance mode")
+		return
+	}
+
+	links, err := w.Links()
+	if nil != err {
+		w.logger.Error().Err(err).Msg("Failed to get watched links")
+		return
+	}
+
+	if len(links) == 0 {
+		return
+	}
+
+	if err := w.janitor.CheckFreeSpace(); nil != err {
+		w.logger.Warn().Err(err).Msg("Skipping watched links sync round due to failed free disk space check")
+		return
+	}
+
+	ctx, ok := w.worker.TryAcquireJob(ctx)
+	if !ok {
+		w.logger.Warn().Msg("Another download is in progress. Skipping watched links sync round")
+		return
+	}
+	defer w.worker.ReleaseJob()
+
+	logger := w.logger.With().Int64("chat_id", b.papaChatID).Logger()
+	sendOpt := &gotgbot.SendMessageOpts{ //nolint:exhaustruct
+		ParseMode: gotgbot.ParseModeMarkdown,
+	}
+
+	w.outbox.Send(b.papaChatID, "👀 Syncing "+strconv.Itoa(len(links))+" watched link(s)...", sendOpt)
+
+	opts := telegram.UploadOptions{} //nolint:exhaustruct
+	for _, link := range links {
+		if nil != ctx.Err() {
+			return
+		}
+
+		processLinks(ctx, logger, w.outbox, w.bus, w.src, w.up, nil, w.store, 0, b.papaChatID, sendOpt, []types.Link{link}, opts, jobModeSync)
+	}
+}
+
+// storedLinks returns the links added using the watch command. Stored links that are not valid watch links, e.g.,
+// edited by hand, are skipped.
+func (w *Watcher) storedLinks() ([]types.Link, error) {
+	list, err := w.list.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read watchlist: %v", err)
+	}
+
+	links := make([]types.Link, 0, len(list.URLs))
+	for _, u := range list.URLs {
+		link, err := parseWatchLink(u)
+		if nil != err {
+			w.logger.Warn().Err(err).Msg("Skipping invalid stored watch link")
+			continue
+		}
+
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+func (w *Watcher) writeLinks(links []types.Link) error {
+	list := types.StoredWatchlist{
+		URLs: lo.Map(links, func(l types.Link, _ int) string { return l.URL() }),
+	}
+	if err := w.list.InfoFile.Write(list); nil != err {
+		return fmt.Errorf("write watchlist: %v", err)
+	}
+
+	return nil
+}
+
+// parseWatchLink parses the watched playlist, or mix, link u.
+func parseWatchLink(u string) (types.Link, error) {
+	link, err := types.ParseURL(u)
+	if nil != err {
+		return types.Link{}, fmt.Errorf("watch link %q: %w", u, err) //nolint:exhaustruct
+	}
+
+	if !isWatchableLink(link) {
+		return types.Link{}, fmt.Errorf("watch link %q: %w", u, ErrUnsupportedWatchLink) //nolint:exhaustruct
+	}
+
+	return link, nil
+}
+
+func isWatchableLink(link types.Link) bool {
+	return link.Kind == types.LinkKindPlaylist || link.Kind == types.LinkKindMix
+}
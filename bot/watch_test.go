@@ -0,0 +1,183 @@
+package bot_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/bot"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// fakeProvider is a provider that only has a downloads directory.
+type fakeProvider struct {
+	dir fs.DownloadsDir
+}
+
+func (p fakeProvider) Name() string                          { return "fake" }
+func (p fakeProvider) ResolveLink(string) (types.Link, bool) { return types.Link{}, false } //nolint:exhaustruct
+func (p fakeProvider) DownloadsDir() fs.DownloadsDir         { return p.dir }
+func (p fakeProvider) MarkSynced(types.Link, []string) error { return nil }
+func (p fakeProvider) TryDownloadLink(context.Context, zerolog.Logger, types.Link) error {
+	return nil
+}
+
+func (p fakeProvider) TrySyncLink(context.Context, zerolog.Logger, types.Link) ([]string, error) {
+	return nil, nil
+}
+
+func (p fakeProvider) TryUpdateAlbum(context.Context, zerolog.Logger, types.Link, []string) ([]string, error) {
+	return nil, nil
+}
+
+// everySchedule fires every interval.
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// syncBuffer is a buffer that is safe to write logs to, and read them from, concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func newTestWatcher(
+	t *testing.T,
+	logger zerolog.Logger,
+	schedule everySchedule,
+	configURLs []string,
+	maintenance *bot.Maintenance,
+) (*bot.Watcher, fs.DownloadsDir, error) {
+	t.Helper()
+
+	dir := fs.DownloadsDirFrom(t.TempDir())
+	w, err := bot.NewWatcher(logger, schedule, configURLs, fakeProvider{dir: dir}, nil, nil, nil, nil, nil, nil, maintenance)
+
+	return w, dir, err
+}
+
+func TestNewWatcherRejectsInvalidConfigLinks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		url  string
+		err  error
+	}{
+		{
+			name: "not a Tidal URL",
+			url:  "https://example.com/playlist/1",
+			err:  types.ErrNotTidalURL,
+		},
+		{
+			name: "unsupported link kind",
+			url:  "https://tidal.com/album/1",
+			err:  bot.ErrUnsupportedWatchLink,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, err := newTestWatcher(t, zerolog.Nop(), everySchedule(time.Hour), []string{tt.url}, nil)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestWatcherLinksMergesConfigAndStoredLinks(t *testing.T) {
+	t.Parallel()
+
+	configURLs := []string{
+		"https://tidal.com/playlist/11111111-2222-3333-4444-555555555555",
+		"https://tidal.com/mix/0123456789abcdef",
+	}
+	w, dir, err := newTestWatcher(t, zerolog.Nop(), everySchedule(time.Hour), configURLs, nil)
+	require.NoError(t, err)
+
+	stored := types.StoredWatchlist{
+		URLs: []string{
+			"https://tidal.com/mix/0123456789abcdef",
+			"https://tidal.com/playlist/aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
+			"https://tidal.com/album/1",
+			"not a link",
+		},
+	}
+	require.NoError(t, dir.Watchlist().InfoFile.Write(stored))
+
+	links, err := w.Links()
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]types.Link{
+			{Kind: types.LinkKindPlaylist, ID: "11111111-2222-3333-4444-555555555555"},
+			{Kind: types.LinkKindMix, ID: "0123456789abcdef"},
+			{Kind: types.LinkKindPlaylist, ID: "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"},
+		},
+		links,
+	)
+
+	assert.True(t, w.IsConfigured(types.Link{Kind: types.LinkKindMix, ID: "0123456789abcdef"}))
+	assert.False(t, w.IsConfigured(types.Link{Kind: types.LinkKindPlaylist, ID: "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"}))
+
+	added, err := w.Add(types.Link{Kind: types.LinkKindMix, ID: "0123456789abcdef"})
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	_, err = w.Add(types.Link{Kind: types.LinkKindAlbum, ID: "1"})
+	require.ErrorIs(t, err, bot.ErrUnsupportedWatchLink)
+}
+
+func TestWatcherRunSyncsOnSchedule(t *testing.T) {
+	t.Parallel()
+
+	maintenance, err := bot.NewMaintenance(fs.DownloadsDirFrom(t.TempDir()))
+	require.NoError(t, err)
+	_, err = maintenance.Set(true)
+	require.NoError(t, err)
+
+	var logs syncBuffer
+	logger := zerolog.New(&logs)
+	w, _, err := newTestWatcher(t, logger, everySchedule(10*time.Millisecond), nil, maintenance)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx, nil)
+	}()
+
+	// Sync rounds are skipped in maintenance mode, which is all that is logged once a round is due.
+	assert.Eventually(t, func() bool {
+		return strings.Count(logs.String(), "Skipping watched links sync round due to maintenance mode") >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -3,9 +3,12 @@ package cache
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/karlseguin/ccache/v3"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/xeptore/tidalgram/tidal/types"
 )
@@ -17,13 +20,36 @@ var (
 	DefaultTrackCreditsTTL    = 1 * time.Hour
 )
 
+// evictInterval is how often expired values are evicted from the database. Expired values are deleted once they
+// are read as well, but values that are not read again would otherwise stay there until a restart.
+const evictInterval = 1 * time.Hour
+
 type Cache struct {
 	AlbumsMeta   AlbumsMetaCache
 	Covers       DownloadedCoversCache
 	TrackCredits TrackCreditsCache
+	store        *store
+	// stopEvicting stops evicting expired values from the database periodically.
+	stopEvicting chan struct{}
+	evicting     sync.WaitGroup
 }
 
-func New() *Cache {
+// New returns a cache that persists cached album metadata, and track credits in the database at path, so that
+// they are not fetched again after a restart while they are fresh. Values are only cached in memory if path is
+// empty. Covers are always only cached in memory, as they are too large to be persisted. Expired values are
+// evicted from the database once it is opened, and then every hour until the cache is closed. Covers cached in
+// memory take up to coversMaxBytes bytes, beyond which the least recently used ones are evicted.
+func New(logger zerolog.Logger, path string, coversMaxBytes int64) (*Cache, error) {
+	var s *store
+	if len(path) > 0 {
+		var err error
+		if s, err = openStore(path); nil != err {
+			return nil, fmt.Errorf("open cache store: %v", err)
+		}
+
+		evictExpired(logger, s)
+	}
+
 	albumsMetaCache := ccache.New(
 		ccache.Configure[*types.AlbumMeta]().
 			MaxSize(1000).
@@ -31,9 +57,17 @@ func New() *Cache {
 			PercentToPrune(10),
 	)
 
+	// Covers are sized by their lengths, hence the max size of the cache is in bytes.
 	downloadedCoversCache := ccache.New(
-		ccache.Configure[[]byte]().
-			MaxSize(100).
+		ccache.Configure[Cover]().
+			MaxSize(coversMaxBytes).
+			GetsPerPromote(3).
+			PercentToPrune(10),
+	)
+
+	coverPathsCache := ccache.New(
+		ccache.Configure[string]().
+			MaxSize(10_000).
 			GetsPerPromote(3).
 			PercentToPrune(10),
 	)
@@ -45,46 +79,217 @@ func New() *Cache {
 			PercentToPrune(10),
 	)
 
-	return &Cache{
+	c := &Cache{
 		AlbumsMeta: AlbumsMetaCache{
-			c:   albumsMetaCache,
-			mux: sync.Mutex{},
+			c:       albumsMetaCache,
+			fetches: singleflight.Group{},
+			store:   s,
+			logger:  logger,
 		},
 		Covers: DownloadedCoversCache{
-			c:   downloadedCoversCache,
-			mux: sync.Mutex{},
+			c:        downloadedCoversCache,
+			paths:    coverPathsCache,
+			mux:      sync.Mutex{},
+			logger:   logger,
+			maxBytes: coversMaxBytes,
+			hits:     atomic.Int64{},
+			misses:   atomic.Int64{},
+			evicted:  atomic.Int64{},
 		},
 		TrackCredits: TrackCreditsCache{
-			c:   trackCreditsCache,
-			mux: sync.Mutex{},
+			c:      trackCreditsCache,
+			mux:    sync.Mutex{},
+			store:  s,
+			logger: logger,
 		},
+		store:        s,
+		stopEvicting: make(chan struct{}),
+		evicting:     sync.WaitGroup{},
+	}
+	if nil != s {
+		c.evicting.Go(func() { c.evictPeriodically(logger) })
+	}
+
+	return c, nil
+}
+
+func evictExpired(logger zerolog.Logger, s *store) {
+	if evicted, err := s.evictExpired(); nil != err {
+		logger.Error().Err(err).Msg("Failed to evict expired cache entries")
+	} else if evicted > 0 {
+		logger.Debug().Int("evicted", evicted).Msg("Evicted expired cache entries")
+	}
+}
+
+func (c *Cache) evictPeriodically(logger zerolog.Logger) {
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopEvicting:
+			return
+		case <-ticker.C:
+			evictExpired(logger, c.store)
+		}
+	}
+}
+
+// Close stops evicting expired values, and closes the database cached values are persisted in, if any.
+func (c *Cache) Close() error {
+	close(c.stopEvicting)
+	c.evicting.Wait()
+
+	if err := c.store.close(); nil != err {
+		return fmt.Errorf("close cache store: %v", err)
+	}
+
+	return nil
+}
+
+// fetchThrough returns the value cached with k in memory, or in bucket of s, or else fetches it using fetch, and
+// caches it for ttl. Values loaded from s are cached in memory until they expire in s.
+func fetchThrough[T any](
+	logger zerolog.Logger,
+	c *ccache.Cache[T],
+	s *store,
+	bucket []byte,
+	k string,
+	ttl time.Duration,
+	fetch func() (T, error),
+) (*ccache.Item[T], error) {
+	if item := c.Get(k); nil != item && !item.Expired() {
+		return item, nil
 	}
+
+	var v T
+	if expiresAt, ok, err := s.load(bucket, k, &v); nil != err {
+		logger.Error().Err(err).Str("key", k).Msg("Failed to load cached value. Fetching it again")
+	} else if ok {
+		c.Set(k, v, time.Until(expiresAt))
+		if item := c.Get(k); nil != item {
+			return item, nil
+		}
+	}
+
+	return c.Fetch(k, ttl, func() (T, error) {
+		v, err := fetch()
+		if nil != err {
+			return v, err
+		}
+
+		if err := s.save(bucket, k, v, time.Now().Add(ttl)); nil != err {
+			logger.Error().Err(err).Str("key", k).Msg("Failed to persist cached value")
+		}
+
+		return v, nil
+	})
+}
+
+// Cover is a downloaded cover image. Its size is its length, which the covers cache is bounded by.
+type Cover []byte
+
+func (c Cover) Size() int64 {
+	return int64(len(c))
+}
+
+// CoverStats is the memory usage, and the effectiveness of the covers cache since startup.
+type CoverStats struct {
+	Entries  int
+	Bytes    int64
+	MaxBytes int64
+	Hits     int64
+	Misses   int64
+	// Evicted is the number of covers evicted to keep the cache under MaxBytes.
+	Evicted int64
 }
 
 type DownloadedCoversCache struct {
-	c   *ccache.Cache[[]byte]
-	mux sync.Mutex
+	c *ccache.Cache[Cover]
+	// paths holds the paths covers were written to, so that they are copied from there, rather than being
+	// kept in memory.
+	paths    *ccache.Cache[string]
+	mux      sync.Mutex
+	logger   zerolog.Logger
+	maxBytes int64
+	hits     atomic.Int64
+	misses   atomic.Int64
+	evicted  atomic.Int64
 }
 
 func (dcc *DownloadedCoversCache) Fetch(
 	k string,
 	ttl time.Duration,
 	fetch func() ([]byte, error),
-) (*ccache.Item[[]byte], error) {
+) (*ccache.Item[Cover], error) {
 	dcc.mux.Lock()
 	defer dcc.mux.Unlock()
 
-	v, err := dcc.c.Fetch(k, ttl, fetch)
+	fetched := false
+	// Covers are not persisted, hence they are fetched through no store.
+	v, err := fetchThrough(dcc.logger, dcc.c, nil, nil, k, ttl, func() (Cover, error) {
+		fetched = true
+		return fetch()
+	})
 	if nil != err {
 		return nil, fmt.Errorf("fetch cover: %w", err)
 	}
 
+	if fetched {
+		dcc.misses.Add(1)
+	} else {
+		dcc.hits.Add(1)
+	}
+
 	return v, nil
 }
 
+// Path returns the path the cover with k was written to, if it was written there in the last ttl passed to
+// SetPath. The file might have been removed since.
+func (dcc *DownloadedCoversCache) Path(k string) (string, bool) {
+	item := dcc.paths.Get(k)
+	if nil == item || item.Expired() {
+		return "", false
+	}
+	dcc.hits.Add(1)
+
+	return item.Value(), true
+}
+
+// SetPath records that the cover with k was written to path, and is copied from there for ttl, and evicts the
+// cover from memory.
+func (dcc *DownloadedCoversCache) SetPath(k, path string, ttl time.Duration) {
+	dcc.paths.Set(k, path, ttl)
+	dcc.c.Delete(k)
+}
+
+// ClearPaths forgets the paths covers were written to.
+func (dcc *DownloadedCoversCache) ClearPaths() {
+	dcc.paths.Clear()
+}
+
+func (dcc *DownloadedCoversCache) Stats() CoverStats {
+	// Covers are only accounted, and evicted once their pending promotions are applied.
+	dcc.c.SyncUpdates()
+	dcc.evicted.Add(int64(dcc.c.GetDropped()))
+
+	return CoverStats{
+		Entries:  dcc.c.ItemCount(),
+		Bytes:    dcc.c.GetSize(),
+		MaxBytes: dcc.maxBytes,
+		Hits:     dcc.hits.Load(),
+		Misses:   dcc.misses.Load(),
+		Evicted:  dcc.evicted.Load(),
+	}
+}
+
 type AlbumsMetaCache struct {
-	c   *ccache.Cache[*types.AlbumMeta]
-	mux sync.Mutex
+	c *ccache.Cache[*types.AlbumMeta]
+	// fetches deduplicates concurrent fetches of the same album, e.g., of the tracks of a playlist from the same
+	// album, while fetches of different albums are not blocked by each other.
+	fetches singleflight.Group
+	store   *store
+	logger  zerolog.Logger
 }
 
 func (amc *AlbumsMetaCache) Fetch(
@@ -92,20 +297,26 @@ func (amc *AlbumsMetaCache) Fetch(
 	ttl time.Duration,
 	fetch func() (*types.AlbumMeta, error),
 ) (*ccache.Item[*types.AlbumMeta], error) {
-	amc.mux.Lock()
-	defer amc.mux.Unlock()
-
-	v, err := amc.c.Fetch(k, ttl, fetch)
+	v, err, _ := amc.fetches.Do(k, func() (any, error) {
+		return fetchThrough(amc.logger, amc.c, amc.store, albumsMetaBucketName, k, ttl, fetch)
+	})
 	if nil != err {
 		return nil, fmt.Errorf("fetch album meta: %w", err)
 	}
 
-	return v, nil
+	item, ok := v.(*ccache.Item[*types.AlbumMeta])
+	if !ok {
+		panic(fmt.Sprintf("unexpected fetched album meta type: %T", v))
+	}
+
+	return item, nil
 }
 
 type TrackCreditsCache struct {
-	c   *ccache.Cache[*types.TrackCredits]
-	mux sync.Mutex
+	c      *ccache.Cache[*types.TrackCredits]
+	mux    sync.Mutex
+	store  *store
+	logger zerolog.Logger
 }
 
 func (tcc *TrackCreditsCache) Fetch(
@@ -116,7 +327,7 @@ func (tcc *TrackCreditsCache) Fetch(
 	tcc.mux.Lock()
 	defer tcc.mux.Unlock()
 
-	v, err := tcc.c.Fetch(k, ttl, fetch)
+	v, err := fetchThrough(tcc.logger, tcc.c, tcc.store, trackCreditsBucketName, k, ttl, fetch)
 	if nil != err {
 		return nil, fmt.Errorf("fetch track credits: %w", err)
 	}
@@ -124,6 +335,25 @@ func (tcc *TrackCreditsCache) Fetch(
 	return v, nil
 }
 
+// Cached reports whether credits are cached with k, either in memory, or in the store.
+func (tcc *TrackCreditsCache) Cached(k string) bool {
+	if item := tcc.c.Get(k); nil != item && !item.Expired() {
+		return true
+	}
+
+	var v types.TrackCredits
+	_, ok, err := tcc.store.load(trackCreditsBucketName, k, &v)
+	if nil != err {
+		tcc.logger.Error().Err(err).Str("key", k).Msg("Failed to load cached track credits")
+		return false
+	}
+
+	return ok
+}
+
 func (tcc *TrackCreditsCache) Set(k string, v *types.TrackCredits, ttl time.Duration) {
 	tcc.c.Set(k, v, ttl)
+	if err := tcc.store.save(trackCreditsBucketName, k, v, time.Now().Add(ttl)); nil != err {
+		tcc.logger.Error().Err(err).Str("key", k).Msg("Failed to persist cached track credits")
+	}
 }
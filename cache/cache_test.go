@@ -0,0 +1,224 @@
+package cache_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/xeptore/tidalgram/cache"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+func TestCachePersistsAcrossRestarts(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cache.db")
+	meta := &types.AlbumMeta{Artist: "Artist", Title: "Album", TotalTracks: 12, TotalVolumes: 1} //nolint:exhaustruct
+
+	c, err := cache.New(zerolog.Nop(), path, 1024)
+	require.NoError(t, err)
+
+	item, err := c.AlbumsMeta.Fetch("1", time.Hour, func() (*types.AlbumMeta, error) { return meta, nil })
+	require.NoError(t, err)
+	assert.Equal(t, meta, item.Value())
+
+	_, err = c.Covers.Fetch("expired", time.Nanosecond, func() ([]byte, error) { return []byte{1, 2, 3}, nil })
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	c, err = cache.New(zerolog.Nop(), path, 1024)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	errFetched := errors.New("fetched again")
+
+	item, err = c.AlbumsMeta.Fetch("1", time.Hour, func() (*types.AlbumMeta, error) { return nil, errFetched })
+	require.NoError(t, err)
+	assert.Equal(t, meta, item.Value())
+
+	_, err = c.Covers.Fetch("expired", time.Hour, func() ([]byte, error) { return nil, errFetched })
+	require.ErrorIs(t, err, errFetched)
+}
+
+func TestCacheInMemory(t *testing.T) {
+	t.Parallel()
+
+	c, err := cache.New(zerolog.Nop(), "", 1024)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	credits := &types.TrackCredits{} //nolint:exhaustruct
+	c.TrackCredits.Set("1", credits, time.Hour)
+
+	item, err := c.TrackCredits.Fetch("1", time.Hour, func() (*types.TrackCredits, error) {
+		return nil, errors.New("fetched again")
+	})
+	require.NoError(t, err)
+	assert.Same(t, credits, item.Value())
+}
+
+func TestCacheCoversBoundedByBytes(t *testing.T) {
+	t.Parallel()
+
+	c, err := cache.New(zerolog.Nop(), "", 1024)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	for _, k := range []string{"1", "2", "3"} {
+		_, err := c.Covers.Fetch(k, time.Hour, func() ([]byte, error) { return make([]byte, 512), nil })
+		require.NoError(t, err)
+	}
+
+	stats := c.Covers.Stats()
+	assert.LessOrEqual(t, stats.Bytes, int64(1024))
+	assert.Equal(t, int64(1024), stats.MaxBytes)
+	assert.Positive(t, stats.Evicted)
+	assert.Equal(t, int64(3), stats.Misses)
+
+	c.Covers.SetPath("3", "/covers/3.jpg", time.Hour)
+	path, ok := c.Covers.Path("3")
+	require.True(t, ok)
+	assert.Equal(t, "/covers/3.jpg", path)
+	assert.Equal(t, int64(1), c.Covers.Stats().Hits)
+}
+
+func TestCacheTrackCreditsCached(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	c, err := cache.New(zerolog.Nop(), path, 1024)
+	require.NoError(t, err)
+
+	assert.False(t, c.TrackCredits.Cached("1"))
+	c.TrackCredits.Set("1", &types.TrackCredits{}, time.Hour)       //nolint:exhaustruct
+	c.TrackCredits.Set("2", &types.TrackCredits{}, time.Nanosecond) //nolint:exhaustruct
+	assert.True(t, c.TrackCredits.Cached("1"))
+	require.NoError(t, c.Close())
+
+	c, err = cache.New(zerolog.Nop(), path, 1024)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	assert.True(t, c.TrackCredits.Cached("1"))
+	assert.False(t, c.TrackCredits.Cached("2"))
+}
+
+func TestCacheAlbumsMetaFetchedOnce(t *testing.T) {
+	t.Parallel()
+
+	c, err := cache.New(zerolog.Nop(), "", 1024)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	var (
+		fetches atomic.Int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	meta := &types.AlbumMeta{Title: "Album"} //nolint:exhaustruct
+	for range 5 {
+		wg.Go(func() {
+			item, err := c.AlbumsMeta.Fetch("1", time.Hour, func() (*types.AlbumMeta, error) {
+				fetches.Add(1)
+				<-release
+
+				return meta, nil
+			})
+			assert.NoError(t, err)
+			assert.Same(t, meta, item.Value())
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestCacheEvictsExpiredEntries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	c, err := cache.New(zerolog.Nop(), path, 1024)
+	require.NoError(t, err)
+
+	c.TrackCredits.Set("read", &types.TrackCredits{}, time.Nanosecond)   //nolint:exhaustruct
+	c.TrackCredits.Set("unread", &types.TrackCredits{}, time.Nanosecond) //nolint:exhaustruct
+	c.TrackCredits.Set("fresh", &types.TrackCredits{}, time.Hour)        //nolint:exhaustruct
+	time.Sleep(time.Millisecond)
+	assert.False(t, c.TrackCredits.Cached("read"))
+	require.NoError(t, c.Close())
+
+	assert.Equal(t, []string{"fresh", "unread"}, storedKeys(t, path, "track_credits"))
+
+	c, err = cache.New(zerolog.Nop(), path, 1024)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	assert.Equal(t, []string{"fresh"}, storedKeys(t, path, "track_credits"))
+}
+
+func TestCacheCoversNotPersisted(t *testing.T) {
+	t.Parallel()
+
+	const coverSize = 4 << 20
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	c, err := cache.New(zerolog.Nop(), path, 2*coverSize)
+	require.NoError(t, err)
+
+	for _, k := range []string{"1", "2", "3"} {
+		_, err := c.Covers.Fetch(k, time.Hour, func() ([]byte, error) { return make([]byte, coverSize), nil })
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.Close())
+
+	assert.Empty(t, storedKeys(t, path, "covers"))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(coverSize))
+
+	c, err = cache.New(zerolog.Nop(), path, 2*coverSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	errFetched := errors.New("fetched again")
+	_, err = c.Covers.Fetch("1", time.Hour, func() ([]byte, error) { return nil, errFetched })
+	require.ErrorIs(t, err, errFetched)
+}
+
+func storedKeys(t *testing.T, path, bucket string) []string {
+	t.Helper()
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{ReadOnly: true, Timeout: time.Second}) //nolint:exhaustruct
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	var keys []string
+	err = db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if nil == b {
+			return nil
+		}
+
+		return b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	return keys
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-json"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	albumsMetaBucketName   = []byte("albums_meta")
+	trackCreditsBucketName = []byte("track_credits")
+	// legacyCoversBucketName is the bucket covers were persisted in. Covers are only cached in memory, as they are
+	// too large to be persisted, hence the bucket is deleted once the database is opened.
+	legacyCoversBucketName = []byte("covers")
+)
+
+// store persists cached values, so that they are not fetched again after a restart while they are fresh. A nil
+// store persists nothing.
+type store struct {
+	db *bbolt.DB
+}
+
+// storedEntry is a cached value, and when it expires.
+type storedEntry struct {
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+func openStore(path string) (*store, error) {
+	opts := &bbolt.Options{ //nolint:exhaustruct
+		NoFreelistSync: true,
+		ReadOnly:       false,
+		Timeout:        1 * time.Second,
+		NoGrowSync:     false,
+		FreelistType:   bbolt.FreelistArrayType,
+	}
+	db, err := bbolt.Open(path, 0o600, opts)
+	if nil != err {
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{albumsMetaBucketName, trackCreditsBucketName} {
+			if _, err := tx.CreateBucketIfNotExists(name); nil != err {
+				return fmt.Errorf("create %s bucket: %v", name, err)
+			}
+		}
+
+		if nil != tx.Bucket(legacyCoversBucketName) {
+			if err := tx.DeleteBucket(legacyCoversBucketName); nil != err {
+				return fmt.Errorf("delete %s bucket: %v", legacyCoversBucketName, err)
+			}
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("create buckets: %v", err)
+	}
+
+	return &store{db: db}, nil
+}
+
+func (s *store) close() error {
+	if nil == s {
+		return nil
+	}
+
+	if err := s.db.Close(); nil != err {
+		return fmt.Errorf("close database: %v", err)
+	}
+
+	return nil
+}
+
+// load decodes the value stored with key in bucket into v, and returns when it expires. It returns false if no
+// such value is stored, or it is expired, in which case the expired value is deleted.
+func (s *store) load(bucket []byte, key string, v any) (time.Time, bool, error) {
+	if nil == s {
+		return time.Time{}, false, nil
+	}
+
+	var entry *storedEntry
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(bucket).Get([]byte(key))
+		if nil == raw {
+			return nil
+		}
+
+		entry = new(storedEntry)
+		if err := json.Unmarshal(raw, entry); nil != err {
+			return fmt.Errorf("decode entry: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return time.Time{}, false, fmt.Errorf("load entry: %v", err)
+	}
+	if nil == entry {
+		return time.Time{}, false, nil
+	}
+	if !time.Now().Before(entry.ExpiresAt) {
+		if err := s.deleteExpired(bucket, key); nil != err {
+			return time.Time{}, false, fmt.Errorf("delete expired entry: %v", err)
+		}
+
+		return time.Time{}, false, nil
+	}
+
+	if err := json.Unmarshal(entry.Value, v); nil != err {
+		return time.Time{}, false, fmt.Errorf("decode value: %v", err)
+	}
+
+	return entry.ExpiresAt, true, nil
+}
+
+// save stores v with key in bucket until expiresAt.
+func (s *store) save(bucket []byte, key string, v any, expiresAt time.Time) error {
+	if nil == s {
+		return nil
+	}
+
+	value, err := json.Marshal(v)
+	if nil != err {
+		return fmt.Errorf("encode value: %v", err)
+	}
+
+	raw, err := json.Marshal(storedEntry{Value: value, ExpiresAt: expiresAt.UTC()})
+	if nil != err {
+		return fmt.Errorf("encode entry: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket(bucket).Put([]byte(key), raw); nil != err {
+			return fmt.Errorf("put entry: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store entry: %v", err)
+	}
+
+	return nil
+}
+
+// deleteExpired deletes the value stored with key in bucket, if it is still expired. It might have been replaced
+// with a fresh one since it was loaded.
+func (s *store) deleteExpired(bucket []byte, key string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(bucket).Get([]byte(key))
+		if nil == raw {
+			return nil
+		}
+
+		var entry storedEntry
+		if err := json.Unmarshal(raw, &entry); nil == err && time.Now().Before(entry.ExpiresAt) {
+			return nil
+		}
+
+		if err := tx.Bucket(bucket).Delete([]byte(key)); nil != err {
+			return fmt.Errorf("delete entry: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("update database: %v", err)
+	}
+
+	return nil
+}
+
+// evictExpired removes the expired values of all buckets, and returns their number.
+func (s *store) evictExpired() (int, error) {
+	if nil == s {
+		return 0, nil
+	}
+
+	var evicted int
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		now := time.Now()
+		for _, name := range [][]byte{albumsMetaBucketName, trackCreditsBucketName} {
+			var expired [][]byte
+			err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+				var entry storedEntry
+				if err := json.Unmarshal(v, &entry); nil != err || !now.Before(entry.ExpiresAt) {
+					// Entries that cannot be decoded are evicted as well, as they are never loaded.
+					expired = append(expired, k)
+				}
+
+				return nil
+			})
+			if nil != err {
+				return fmt.Errorf("scan %s bucket: %v", name, err)
+			}
+
+			// Keys must not be deleted while iterating the bucket.
+			for _, k := range expired {
+				if err := tx.Bucket(name).Delete(k); nil != err {
+					return fmt.Errorf("delete %s entry: %v", name, err)
+				}
+			}
+			evicted += len(expired)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return 0, fmt.Errorf("evict expired entries: %v", err)
+	}
+
+	return evicted, nil
+}
@@ -4,23 +4,33 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"html/template"
+	"net"
 	"net/url"
 	"os"
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog"
 	"github.com/samber/lo"
 	"gopkg.in/yaml.v3"
 
 	"github.com/xeptore/tidalgram/redact"
+	"github.com/xeptore/tidalgram/tidal/types"
 )
 
 type Config struct {
-	Bot      Bot      `yaml:"bot"`
-	Log      Log      `yaml:"log"`
-	Tidal    Tidal    `yaml:"tidal"`
-	Telegram Telegram `yaml:"telegram"`
+	Bot        Bot        `yaml:"bot"`
+	Log        Log        `yaml:"log"`
+	Tidal      Tidal      `yaml:"tidal"`
+	Telegram   Telegram   `yaml:"telegram"`
+	API        API        `yaml:"api"`
+	Health     Health     `yaml:"health"`
+	Encryption Encryption `yaml:"encryption"`
 }
 
 func (conf *Config) ToDict() *zerolog.Event {
@@ -29,7 +39,10 @@ func (conf *Config) ToDict() *zerolog.Event {
 		Dict("bot", conf.Bot.ToDict()).
 		Dict("log", conf.Log.ToDict()).
 		Dict("tidal", conf.Tidal.ToDict()).
-		Dict("telegram", conf.Telegram.ToDict())
+		Dict("telegram", conf.Telegram.ToDict()).
+		Dict("api", conf.API.ToDict()).
+		Dict("health", conf.Health.ToDict()).
+		Dict("encryption", conf.Encryption.ToDict())
 }
 
 func (conf *Config) setDefaults() {
@@ -37,6 +50,8 @@ func (conf *Config) setDefaults() {
 	conf.Log.setDefaults()
 	conf.Tidal.setDefaults()
 	conf.Telegram.setDefaults()
+	conf.API.setDefaults()
+	conf.Health.setDefaults()
 }
 
 func (conf *Config) validate() error {
@@ -56,32 +71,94 @@ func (conf *Config) validate() error {
 		return fmt.Errorf("telegram config validation: %v", err)
 	}
 
+	if err := conf.API.validate(); nil != err {
+		return fmt.Errorf("api config validation: %v", err)
+	}
+
+	if err := conf.Health.validate(); nil != err {
+		return fmt.Errorf("health config validation: %v", err)
+	}
+
+	if err := conf.Encryption.validate(); nil != err {
+		return fmt.Errorf("encryption config validation: %v", err)
+	}
+
 	return nil
 }
 
 type Bot struct {
-	PapaID       int64    `yaml:"papa_id"`
-	MamaID       int64    `yaml:"mama_id"`
-	APIURL       string   `yaml:"api_url"`
-	Token        string   `yaml:"-"`
-	CredsDir     string   `yaml:"creds_dir"`
-	DownloadsDir string   `yaml:"downloads_dir"`
-	Proxy        BotProxy `yaml:"proxy"`
+	PapaID       int64  `yaml:"papa_id"`
+	MamaID       int64  `yaml:"mama_id"`
+	Frontend     string `yaml:"frontend"`
+	APIURL       string `yaml:"api_url"`
+	Token        string `yaml:"-"`
+	CredsDir     string `yaml:"creds_dir"`
+	DownloadsDir string `yaml:"downloads_dir"`
+	DownloadsFS  string `yaml:"downloads_fs"`
+	// CleanupRequests deletes the link messages, and the intermediate messages of their jobs, once the jobs
+	// succeed, keeping only their final summaries.
+	CleanupRequests bool           `yaml:"cleanup_requests"`
+	Proxy           BotProxy       `yaml:"proxy"`
+	Audit           BotAudit       `yaml:"audit"`
+	Watch           BotWatch       `yaml:"watch"`
+	Janitor         BotJanitor     `yaml:"janitor"`
+	Outbox          BotOutbox      `yaml:"outbox"`
+	Webhook         BotWebhook     `yaml:"webhook"`
+	LinkOptions     BotLinkOptions `yaml:"link_options"`
+	// DownloadOnly only downloads plain link messages, as the download_only command does, without uploading
+	// them to Telegram.
+	DownloadOnly bool      `yaml:"download_only"`
+	Export       BotExport `yaml:"export"`
 }
 
+// Kinds of the filesystem the downloads directory is on.
+const (
+	// DownloadsFSAuto detects the filesystem kind from the mount the downloads directory is on.
+	DownloadsFSAuto = "auto"
+	// DownloadsFSLocal writes track files directly to the downloads directory.
+	DownloadsFSLocal = "local"
+	// DownloadsFSNetwork assembles track files in a local temporary directory, and copies them to the
+	// downloads directory afterwards, as network filesystems do not handle synchronous writes and renames well.
+	DownloadsFSNetwork = "network"
+)
+
+// Frontends of the bot, i.e., how messages are received, and replied to.
+const (
+	// BotFrontendBotAPI receives, and replies to, messages as the bot of the token, using the Bot API.
+	BotFrontendBotAPI = "bot_api"
+	// BotFrontendUserAccount receives, and replies to, messages as the logged in Telegram account, over the
+	// same MTProto session that uploads tracks.
+	BotFrontendUserAccount = "user_account"
+)
+
 func (b *Bot) ToDict() *zerolog.Event {
 	return zerolog.
 		Dict().
 		Int64("papa_id", b.PapaID).
 		Int64("mama_id", b.MamaID).
+		Str("frontend", b.Frontend).
 		Str("api_url", b.APIURL).
 		Str("token", redact.String(b.Token)).
 		Str("creds_dir", b.CredsDir).
 		Str("downloads_dir", b.DownloadsDir).
-		Dict("proxy", b.Proxy.ToDict())
+		Str("downloads_fs", b.DownloadsFS).
+		Bool("cleanup_requests", b.CleanupRequests).
+		Dict("proxy", b.Proxy.ToDict()).
+		Dict("audit", b.Audit.ToDict()).
+		Dict("watch", b.Watch.ToDict()).
+		Dict("janitor", b.Janitor.ToDict()).
+		Dict("outbox", b.Outbox.ToDict()).
+		Dict("webhook", b.Webhook.ToDict()).
+		Dict("link_options", b.LinkOptions.ToDict()).
+		Bool("download_only", b.DownloadOnly).
+		Dict("export", b.Export.ToDict())
 }
 
 func (b *Bot) setDefaults() {
+	if b.Frontend == "" {
+		b.Frontend = BotFrontendBotAPI
+	}
+
 	if b.APIURL == "" {
 		b.APIURL = "https://api.telegram.org"
 	}
@@ -94,7 +171,17 @@ func (b *Bot) setDefaults() {
 		b.DownloadsDir = "./downloads"
 	}
 
+	if b.DownloadsFS == "" {
+		b.DownloadsFS = DownloadsFSAuto
+	}
+
 	b.Proxy.setDefaults()
+	b.Audit.setDefaults()
+	b.Watch.setDefaults()
+	b.Janitor.setDefaults()
+	b.Outbox.setDefaults()
+	b.Webhook.setDefaults()
+	b.LinkOptions.setDefaults()
 }
 
 type BotProxy struct {
@@ -144,8 +231,22 @@ func (b *Bot) validate() error {
 		return errors.New("papa_id is required")
 	}
 
-	if b.Token == "" {
-		return errors.New("make sure the BOT_TOKEN environment variable is set")
+	switch b.Frontend {
+	case BotFrontendBotAPI:
+		if b.Token == "" {
+			return errors.New("make sure the BOT_TOKEN environment variable is set")
+		}
+	case BotFrontendUserAccount:
+		// Webhooks, and inline keyboards, are only available to bots.
+		if b.Webhook.Enabled() {
+			return errors.New("webhook cannot be enabled with the user_account frontend")
+		}
+
+		if b.LinkOptions.Enabled {
+			return errors.New("link_options cannot be enabled with the user_account frontend")
+		}
+	default:
+		return fmt.Errorf("frontend must be one of: bot_api, user_account, got: %s", b.Frontend)
 	}
 
 	if i, err := os.Lstat(b.CredsDir); nil != err {
@@ -168,10 +269,504 @@ func (b *Bot) validate() error {
 		return errors.New("downloads_dir must be a directory")
 	}
 
+	if !slices.Contains([]string{DownloadsFSAuto, DownloadsFSLocal, DownloadsFSNetwork}, b.DownloadsFS) {
+		return fmt.Errorf("downloads_fs must be one of: auto, local, network, got: %s", b.DownloadsFS)
+	}
+
 	if err := b.Proxy.validate(); nil != err {
 		return fmt.Errorf("proxy config validation: %v", err)
 	}
 
+	if err := b.Audit.validate(); nil != err {
+		return fmt.Errorf("audit config validation: %v", err)
+	}
+
+	if err := b.Watch.validate(); nil != err {
+		return fmt.Errorf("watch config validation: %v", err)
+	}
+
+	if err := b.Janitor.validate(); nil != err {
+		return fmt.Errorf("janitor config validation: %v", err)
+	}
+
+	if err := b.Outbox.validate(); nil != err {
+		return fmt.Errorf("outbox config validation: %v", err)
+	}
+
+	if err := b.Webhook.validate(); nil != err {
+		return fmt.Errorf("webhook config validation: %v", err)
+	}
+
+	if err := b.LinkOptions.validate(); nil != err {
+		return fmt.Errorf("link_options config validation: %v", err)
+	}
+
+	if err := b.Export.validate(); nil != err {
+		return fmt.Errorf("export config validation: %v", err)
+	}
+
+	return nil
+}
+
+type BotAudit struct {
+	Path         string `yaml:"path"`
+	HistoryLimit int    `yaml:"history_limit"`
+}
+
+func (ba *BotAudit) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("path", ba.Path).
+		Int("history_limit", ba.HistoryLimit)
+}
+
+func (ba *BotAudit) setDefaults() {
+	if ba.Path == "" {
+		ba.Path = "./audit.db"
+	}
+
+	if ba.HistoryLimit == 0 {
+		ba.HistoryLimit = 10
+	}
+}
+
+func (ba *BotAudit) validate() error {
+	if ba.HistoryLimit <= 0 {
+		return errors.New("history_limit must be greater than 0")
+	}
+
+	return nil
+}
+
+type BotWatch struct {
+	Schedule string   `yaml:"schedule"`
+	Links    []string `yaml:"links"`
+}
+
+func (bw *BotWatch) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("schedule", bw.Schedule).
+		Strs("links", bw.Links)
+}
+
+func (bw *BotWatch) setDefaults() {
+	if bw.Schedule == "" {
+		bw.Schedule = "@daily"
+	}
+}
+
+func (bw *BotWatch) validate() error {
+	if _, err := ParseWatchSchedule(bw.Schedule); nil != err {
+		return fmt.Errorf("schedule: %v", err)
+	}
+
+	for _, link := range bw.Links {
+		parsed, err := types.ParseURL(link)
+		if nil != err || (parsed.Kind != types.LinkKindPlaylist && parsed.Kind != types.LinkKindMix) {
+			return fmt.Errorf("links must be Tidal playlist or mix URLs, got: %s", link)
+		}
+	}
+
+	return nil
+}
+
+// ParseWatchSchedule parses a cron expression, e.g., 0 3 * * *, or a descriptor, e.g., @daily, or @every 6h, of
+// when watched links are synced. Syncs must be at least a minute apart.
+func ParseWatchSchedule(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if nil != err {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", spec, err)
+	}
+
+	next := schedule.Next(time.Now())
+	if schedule.Next(next).Sub(next) < time.Minute {
+		return nil, errors.New("syncs must be at least 1m apart")
+	}
+
+	return schedule, nil
+}
+
+type BotJanitor struct {
+	Interval       Duration            `yaml:"interval"`
+	MaxAge         Duration            `yaml:"max_age"`
+	MaxSizeMB      int64               `yaml:"max_size_mb"`
+	MinFreeSpaceMB int64               `yaml:"min_free_space_mb"`
+	Artifacts      BotJanitorArtifacts `yaml:"artifacts"`
+	Mirror         BotJanitorMirror    `yaml:"mirror"`
+}
+
+func (bj *BotJanitor) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Dur("interval", bj.Interval.Duration).
+		Dur("max_age", bj.MaxAge.Duration).
+		Int64("max_size_mb", bj.MaxSizeMB).
+		Int64("min_free_space_mb", bj.MinFreeSpaceMB).
+		Dict("artifacts", bj.Artifacts.ToDict()).
+		Dict("mirror", bj.Mirror.ToDict())
+}
+
+func (bj *BotJanitor) setDefaults() {
+	if bj.Interval.Duration == 0 {
+		bj.Interval.Duration = time.Hour
+	}
+}
+
+func (bj *BotJanitor) validate() error {
+	if bj.Interval.Duration < time.Minute {
+		return errors.New("interval must be at least 1m")
+	}
+
+	if bj.MaxAge.Duration < 0 {
+		return errors.New("max_age must be greater than or equal to 0")
+	}
+
+	if bj.MaxSizeMB < 0 {
+		return errors.New("max_size_mb must be greater than or equal to 0")
+	}
+
+	if bj.MinFreeSpaceMB < 0 {
+		return errors.New("min_free_space_mb must be greater than or equal to 0")
+	}
+
+	if err := bj.Artifacts.validate(); nil != err {
+		return fmt.Errorf("artifacts config validation: %v", err)
+	}
+
+	if err := bj.Mirror.validate(); nil != err {
+		return fmt.Errorf("mirror config validation: %v", err)
+	}
+
+	return nil
+}
+
+// BotJanitorArtifacts configures the retention of job artifacts, e.g., debug bundles, which is independent of
+// the retention of downloaded links.
+type BotJanitorArtifacts struct {
+	MaxAge    Duration `yaml:"max_age"`
+	MaxSizeMB int64    `yaml:"max_size_mb"`
+}
+
+func (bja *BotJanitorArtifacts) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Dur("max_age", bja.MaxAge.Duration).
+		Int64("max_size_mb", bja.MaxSizeMB)
+}
+
+func (bja *BotJanitorArtifacts) validate() error {
+	if bja.MaxAge.Duration < 0 {
+		return errors.New("max_age must be greater than or equal to 0")
+	}
+
+	if bja.MaxSizeMB < 0 {
+		return errors.New("max_size_mb must be greater than or equal to 0")
+	}
+
+	return nil
+}
+
+// Storage backends uploaded links are mirrored to.
+const (
+	MirrorBackendS3     = "s3"
+	MirrorBackendWebDAV = "webdav"
+)
+
+// BotJanitorMirror configures mirroring the tracks of uploaded links to a storage backend before they are pruned
+// from the downloads directory, and the retention of the mirrored tracks. Mirroring is disabled if Backend is
+// empty.
+type BotJanitorMirror struct {
+	Backend string `yaml:"backend"`
+	// Prefix is prepended to the keys of the mirrored tracks, e.g., "tidalgram/".
+	Prefix    string                 `yaml:"prefix"`
+	Retention Duration               `yaml:"retention"`
+	S3        BotJanitorMirrorS3     `yaml:"s3"`
+	WebDAV    BotJanitorMirrorWebDAV `yaml:"webdav"`
+}
+
+func (bjm *BotJanitorMirror) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("backend", bjm.Backend).
+		Str("prefix", bjm.Prefix).
+		Dur("retention", bjm.Retention.Duration).
+		Dict("s3", bjm.S3.ToDict()).
+		Dict("webdav", bjm.WebDAV.ToDict())
+}
+
+func (bjm *BotJanitorMirror) validate() error {
+	if bjm.Retention.Duration < 0 {
+		return errors.New("retention must be greater than or equal to 0")
+	}
+
+	switch bjm.Backend {
+	case "":
+		return nil
+	case MirrorBackendS3:
+		if err := bjm.S3.validate(); nil != err {
+			return fmt.Errorf("s3 config validation: %v", err)
+		}
+	case MirrorBackendWebDAV:
+		if err := bjm.WebDAV.validate(); nil != err {
+			return fmt.Errorf("webdav config validation: %v", err)
+		}
+	default:
+		return fmt.Errorf("backend must be one of: s3, webdav, got: %s", bjm.Backend)
+	}
+
+	return nil
+}
+
+// BotJanitorMirrorS3 configures an S3 compatible object storage bucket.
+type BotJanitorMirrorS3 struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// PathStyle addresses the bucket in the URL path instead of the host name, which most S3 compatible
+	// storages other than AWS require.
+	PathStyle bool `yaml:"path_style"`
+}
+
+func (bjms *BotJanitorMirrorS3) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("endpoint", bjms.Endpoint).
+		Str("region", bjms.Region).
+		Str("bucket", bjms.Bucket).
+		Str("access_key_id", redact.String(bjms.AccessKeyID)).
+		Str("secret_access_key", redact.String(bjms.SecretAccessKey)).
+		Bool("path_style", bjms.PathStyle)
+}
+
+func (bjms *BotJanitorMirrorS3) validate() error {
+	endpoint, err := url.Parse(bjms.Endpoint)
+	if nil != err {
+		return fmt.Errorf("endpoint is not a valid URL: %v", err)
+	}
+
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return fmt.Errorf("endpoint scheme must be http or https, got: %s", endpoint.Scheme)
+	}
+
+	if endpoint.Host == "" {
+		return errors.New("endpoint must have a non-empty host")
+	}
+
+	if bjms.Region == "" {
+		return errors.New("region is required")
+	}
+
+	if bjms.Bucket == "" {
+		return errors.New("bucket is required")
+	}
+
+	if bjms.AccessKeyID == "" || bjms.SecretAccessKey == "" {
+		return errors.New("access_key_id and secret_access_key are required")
+	}
+
+	return nil
+}
+
+// BotJanitorMirrorWebDAV configures a WebDAV server, e.g., one served by rclone.
+type BotJanitorMirrorWebDAV struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+func (bjmw *BotJanitorMirrorWebDAV) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("url", bjmw.URL).
+		Str("username", redact.String(bjmw.Username)).
+		Str("password", redact.String(bjmw.Password))
+}
+
+func (bjmw *BotJanitorMirrorWebDAV) validate() error {
+	davURL, err := url.Parse(bjmw.URL)
+	if nil != err {
+		return fmt.Errorf("url is not a valid URL: %v", err)
+	}
+
+	if davURL.Scheme != "http" && davURL.Scheme != "https" {
+		return fmt.Errorf("url scheme must be http or https, got: %s", davURL.Scheme)
+	}
+
+	if davURL.Host == "" {
+		return errors.New("url must have a non-empty host")
+	}
+
+	return nil
+}
+
+type BotOutbox struct {
+	Interval Duration `yaml:"interval"`
+}
+
+func (bo *BotOutbox) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Dur("interval", bo.Interval.Duration)
+}
+
+func (bo *BotOutbox) setDefaults() {
+	if bo.Interval.Duration == 0 {
+		bo.Interval.Duration = 50 * time.Millisecond
+	}
+}
+
+func (bo *BotOutbox) validate() error {
+	if bo.Interval.Duration < 0 {
+		return errors.New("interval must be greater than 0")
+	}
+
+	return nil
+}
+
+// BotWebhook configures receiving bot updates via a webhook, instead of long polling. The webhook is enabled
+// if URL is set.
+type BotWebhook struct {
+	URL         string `yaml:"url"`
+	ListenAddr  string `yaml:"listen_addr"`
+	SecretToken string `yaml:"secret_token"`
+	CertFile    string `yaml:"cert_file"`
+	KeyFile     string `yaml:"key_file"`
+}
+
+var webhookSecretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+func (bw *BotWebhook) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("url", bw.URL).
+		Str("listen_addr", bw.ListenAddr).
+		Str("secret_token", redact.String(bw.SecretToken)).
+		Str("cert_file", bw.CertFile).
+		Str("key_file", bw.KeyFile)
+}
+
+// Enabled reports whether bot updates are received via the webhook.
+func (bw *BotWebhook) Enabled() bool {
+	return len(bw.URL) > 0
+}
+
+// Path returns the URL path the webhook server receives updates on, i.e., the path of URL.
+func (bw *BotWebhook) Path() string {
+	u, err := url.Parse(bw.URL)
+	if nil != err {
+		return ""
+	}
+
+	return strings.Trim(u.Path, "/")
+}
+
+func (bw *BotWebhook) setDefaults() {
+	if bw.ListenAddr == "" {
+		bw.ListenAddr = ":8080"
+	}
+}
+
+func (bw *BotWebhook) validate() error {
+	if !bw.Enabled() {
+		return nil
+	}
+
+	u, err := url.Parse(bw.URL)
+	if nil != err {
+		return fmt.Errorf("url is not a valid URL: %v", err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("url scheme must be https, got: %s", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return errors.New("url must have a non-empty host")
+	}
+
+	if bw.Path() == "" {
+		return errors.New("url must have a non-empty path, e.g., https://bot.example.com/tidalgram")
+	}
+
+	if _, _, err := net.SplitHostPort(bw.ListenAddr); nil != err {
+		return fmt.Errorf("listen_addr is not a valid address: %v", err)
+	}
+
+	if !webhookSecretTokenPattern.MatchString(bw.SecretToken) {
+		return errors.New("secret_token is required, and must be 1-256 characters of A-Z, a-z, 0-9, _, and -")
+	}
+
+	if (bw.CertFile == "") != (bw.KeyFile == "") {
+		return errors.New("cert_file and key_file must be set together")
+	}
+
+	return nil
+}
+
+// BotLinkOptions configures asking for the options of link jobs, i.e., their destination, and whether their
+// albums are uploaded as ZIP archives, using an inline keyboard before starting them.
+type BotLinkOptions struct {
+	Enabled bool     `yaml:"enabled"`
+	Timeout Duration `yaml:"timeout"`
+}
+
+func (blo *BotLinkOptions) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("enabled", blo.Enabled).
+		Dur("timeout", blo.Timeout.Duration)
+}
+
+func (blo *BotLinkOptions) setDefaults() {
+	if blo.Timeout.Duration == 0 {
+		blo.Timeout.Duration = 30 * time.Second
+	}
+}
+
+func (blo *BotLinkOptions) validate() error {
+	if blo.Timeout.Duration < time.Second {
+		return errors.New("timeout must be at least 1s")
+	}
+
+	return nil
+}
+
+// BotExport configures where the tracks of download only jobs are copied to, in addition to the downloads
+// directory.
+type BotExport struct {
+	Path string `yaml:"path"`
+	// RcloneRemote is the rclone remote, and the optional path inside it, e.g., "drive:music", that tracks are
+	// copied to using the rclone command.
+	RcloneRemote string `yaml:"rclone_remote"`
+}
+
+func (be *BotExport) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("path", be.Path).
+		Str("rclone_remote", be.RcloneRemote)
+}
+
+func (be *BotExport) validate() error {
+	if len(be.Path) > 0 {
+		if i, err := os.Lstat(be.Path); nil != err {
+			if errors.Is(err, os.ErrNotExist) {
+				return errors.New("path does not exist")
+			}
+
+			return fmt.Errorf("stat path: %v", err)
+		} else if !i.IsDir() {
+			return errors.New("path must be a directory")
+		}
+	}
+
+	if len(be.RcloneRemote) > 0 && !strings.Contains(be.RcloneRemote, ":") {
+		return fmt.Errorf("rclone_remote must be in the remote:path form, got: %s", be.RcloneRemote)
+	}
+
 	return nil
 }
 
@@ -213,20 +808,81 @@ func (l *Log) validate() error {
 }
 
 type Tidal struct {
-	Downloader TidalDownloader `yaml:"downloader"`
+	// CountryCode overrides the country of the logged in account in Tidal API requests.
+	CountryCode string `yaml:"country_code"`
+	// Accounts are the names of the creds_dir subdirectories holding the credentials of the accounts that are
+	// switched to when the account in use hits a rate limit.
+	Accounts []string `yaml:"accounts"`
+	// AccountCooldown is how long an account that hit a rate limit is not switched back to.
+	AccountCooldown Duration        `yaml:"account_cooldown"`
+	Proxy           TidalProxy      `yaml:"proxy"`
+	Cache           TidalCache      `yaml:"cache"`
+	RateLimit       TidalRateLimit  `yaml:"rate_limit"`
+	Downloader      TidalDownloader `yaml:"downloader"`
 }
 
+var (
+	countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+	accountNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+)
+
 func (t *Tidal) ToDict() *zerolog.Event {
 	return zerolog.
 		Dict().
+		Str("country_code", t.CountryCode).
+		Strs("accounts", t.Accounts).
+		Dur("account_cooldown", t.AccountCooldown.Duration).
+		Dict("proxy", t.Proxy.ToDict()).
+		Dict("cache", t.Cache.ToDict()).
+		Dict("rate_limit", t.RateLimit.ToDict()).
 		Dict("downloader", t.Downloader.ToDict())
 }
 
 func (t *Tidal) setDefaults() {
+	if t.AccountCooldown.Duration == 0 {
+		t.AccountCooldown.Duration = 15 * time.Minute
+	}
+	t.Proxy.setDefaults()
+	t.Cache.setDefaults()
+	t.RateLimit.setDefaults()
 	t.Downloader.setDefaults()
 }
 
 func (t *Tidal) validate() error {
+	if len(t.CountryCode) > 0 && !countryCodePattern.MatchString(t.CountryCode) {
+		return fmt.Errorf("country_code must be an uppercase ISO 3166-1 alpha-2 code, e.g., US, got: %s", t.CountryCode)
+	}
+
+	seen := make(map[string]struct{}, len(t.Accounts))
+	for i, name := range t.Accounts {
+		if !accountNamePattern.MatchString(name) {
+			return fmt.Errorf("accounts[%d] must consist of 1 to 32 lowercase letters, digits, underscores, or hyphens, got: %s", i, name)
+		}
+		if name == "primary" {
+			return fmt.Errorf("accounts[%d] must not be primary, as it names the account stored in creds_dir itself", i)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("accounts[%d] is a duplicate of another account: %s", i, name)
+		}
+		seen[name] = struct{}{}
+	}
+
+	if t.AccountCooldown.Duration < time.Minute {
+		return errors.New("account_cooldown must be at least 1m")
+	}
+
+	if err := t.Proxy.validate(); nil != err {
+		return fmt.Errorf("proxy config validation: %v", err)
+	}
+
+	if err := t.Cache.validate(); nil != err {
+		return fmt.Errorf("cache config validation: %v", err)
+	}
+
+	if err := t.RateLimit.validate(); nil != err {
+		return fmt.Errorf("rate_limit config validation: %v", err)
+	}
+
 	if err := t.Downloader.validate(); nil != err {
 		return fmt.Errorf("downloader config validation: %v", err)
 	}
@@ -234,23 +890,286 @@ func (t *Tidal) validate() error {
 	return nil
 }
 
+// Schemes of the Tidal proxy.
+const (
+	TidalProxySchemeSOCKS5 = "socks5"
+	TidalProxySchemeHTTP   = "http"
+	TidalProxySchemeHTTPS  = "https"
+)
+
+// TidalCache configures the database the fetched album metadata, covers, and track credits are cached in, so
+// that they are not fetched again after a restart while they are fresh.
+type TidalCache struct {
+	Path string `yaml:"path"`
+	// CoversMaxSize is the size in MiB covers cached in memory take up to, beyond which the least recently used
+	// ones are evicted.
+	CoversMaxSize int `yaml:"covers_max_size"`
+}
+
+func (tc *TidalCache) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("path", tc.Path).
+		Int("covers_max_size", tc.CoversMaxSize)
+}
+
+func (tc *TidalCache) setDefaults() {
+	if tc.Path == "" {
+		tc.Path = "./cache.db"
+	}
+
+	if tc.CoversMaxSize == 0 {
+		tc.CoversMaxSize = 64
+	}
+}
+
+func (tc *TidalCache) validate() error {
+	if tc.CoversMaxSize < 1 {
+		return errors.New("covers_max_size must be at least 1")
+	}
+
+	return nil
+}
+
+// TidalRateLimit configures the rates all Tidal requests are sent at by the classes of their endpoints. The rate
+// of a class is halved once its requests are throttled, down to its minimum, and raised back step by step on
+// sustained success.
+type TidalRateLimit struct {
+	// API is the rate of the Tidal API, and auth requests.
+	API TidalRateLimitClass `yaml:"api"`
+	// Stream is the rate of the requests of track stream URLs to the Hi-Fi API.
+	Stream TidalRateLimitClass `yaml:"stream"`
+	// Media is the rate of the downloads of covers, and track files.
+	Media TidalRateLimitClass `yaml:"media"`
+}
+
+func (trl *TidalRateLimit) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Dict("api", trl.API.ToDict()).
+		Dict("stream", trl.Stream.ToDict()).
+		Dict("media", trl.Media.ToDict())
+}
+
+func (trl *TidalRateLimit) setDefaults() {
+	trl.API.setDefaults(5, 0.5, 5)
+	trl.Stream.setDefaults(0.5, 0.1, 2)
+	trl.Media.setDefaults(20, 2, 20)
+}
+
+func (trl *TidalRateLimit) validate() error {
+	if err := trl.API.validate(); nil != err {
+		return fmt.Errorf("api config validation: %v", err)
+	}
+
+	if err := trl.Stream.validate(); nil != err {
+		return fmt.Errorf("stream config validation: %v", err)
+	}
+
+	if err := trl.Media.validate(); nil != err {
+		return fmt.Errorf("media config validation: %v", err)
+	}
+
+	return nil
+}
+
+// TidalRateLimitClass configures the rate of the requests of a class of endpoints in requests per second.
+// Requests are not limited if it is disabled.
+type TidalRateLimitClass struct {
+	Disabled bool    `yaml:"disabled"`
+	Rate     float64 `yaml:"rate"`
+	MinRate  float64 `yaml:"min_rate"`
+	Burst    int     `yaml:"burst"`
+}
+
+func (trlc *TidalRateLimitClass) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("disabled", trlc.Disabled).
+		Float64("rate", trlc.Rate).
+		Float64("min_rate", trlc.MinRate).
+		Int("burst", trlc.Burst)
+}
+
+func (trlc *TidalRateLimitClass) setDefaults(rate, minRate float64, burst int) {
+	if trlc.Rate == 0 {
+		trlc.Rate = rate
+	}
+
+	if trlc.MinRate == 0 {
+		trlc.MinRate = minRate
+	}
+
+	if trlc.Burst == 0 {
+		trlc.Burst = burst
+	}
+}
+
+func (trlc *TidalRateLimitClass) validate() error {
+	if trlc.Rate <= 0 {
+		return errors.New("rate must be greater than 0")
+	}
+
+	// Throttled requests would stall for more than 100 seconds each at lower min rates.
+	if trlc.MinRate < 0.01 || trlc.MinRate > trlc.Rate {
+		return errors.New("min_rate must be at least 0.01, and at most rate")
+	}
+
+	if trlc.Burst < 1 {
+		return errors.New("burst must be at least 1")
+	}
+
+	return nil
+}
+
+// TidalProxy configures the proxy all Tidal requests are sent through. It is enabled if Host is set.
+type TidalProxy struct {
+	Scheme   string `yaml:"scheme"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+func (tp *TidalProxy) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("scheme", tp.Scheme).
+		Str("host", tp.Host).
+		Int("port", tp.Port).
+		Str("username", redact.String(tp.Username)).
+		Str("password", redact.String(tp.Password))
+}
+
+// URL returns the URL of the proxy, or nil if the proxy is not enabled.
+func (tp *TidalProxy) URL() *url.URL {
+	if len(tp.Host) == 0 {
+		return nil
+	}
+
+	u := &url.URL{ //nolint:exhaustruct
+		Scheme: tp.Scheme,
+		Host:   net.JoinHostPort(tp.Host, strconv.Itoa(tp.Port)),
+	}
+	if len(tp.Username) > 0 {
+		u.User = url.UserPassword(tp.Username, tp.Password)
+	}
+
+	return u
+}
+
+func (tp *TidalProxy) setDefaults() {
+	if tp.Scheme == "" {
+		tp.Scheme = TidalProxySchemeSOCKS5
+	}
+}
+
+func (tp *TidalProxy) validate() error {
+	switch tp.Scheme {
+	case TidalProxySchemeSOCKS5, TidalProxySchemeHTTP, TidalProxySchemeHTTPS:
+	default:
+		return fmt.Errorf(
+			"scheme must be one of %s, %s, or %s, got: %s",
+			TidalProxySchemeSOCKS5,
+			TidalProxySchemeHTTP,
+			TidalProxySchemeHTTPS,
+			tp.Scheme,
+		)
+	}
+
+	if len(tp.Host) > 0 && tp.Port == 0 {
+		return errors.New("port is required if host is set")
+	}
+
+	if tp.Port != 0 {
+		if tp.Port < 0 || tp.Port > 65535 {
+			return errors.New("port must be between 1 and 65535")
+		}
+
+		if tp.Host == "" {
+			return errors.New("host is required if port is set")
+		}
+	}
+
+	if len(tp.Password) > 0 && tp.Username == "" {
+		return errors.New("username is required if password is set")
+	}
+
+	return nil
+}
+
+const (
+	// DuplicateTracksKeep uploads every occurrence of a track that appears more than once in a playlist or mix.
+	DuplicateTracksKeep = "keep"
+	// DuplicateTracksDedupe uploads only the first occurrence of a track that appears more than once
+	// in a playlist or mix, and notes the skipped duplicates.
+	DuplicateTracksDedupe = "dedupe"
+)
+
+const (
+	// FailedTracksAbort fails the download of a playlist, mix, or artist credits once any of its tracks fails.
+	FailedTracksAbort = "abort"
+	// FailedTracksSkip skips the tracks of a playlist, mix, or artist credits that fail, and reports them once
+	// the rest are uploaded, unless more than max_skipped tracks fail.
+	FailedTracksSkip = "skip"
+)
+
 type TidalDownloader struct {
-	HifiAPI     string                   `yaml:"hifi_api"`
-	Timeouts    TidalDownloadTimeouts    `yaml:"timeouts"`
-	Concurrency TidalDownloadConcurrency `yaml:"concurrency"`
+	HifiAPI         string                    `yaml:"hifi_api"`
+	CDNCacheURL     string                    `yaml:"cdn_cache_url"`
+	DuplicateTracks string                    `yaml:"duplicate_tracks"`
+	ReplayGain      bool                      `yaml:"replay_gain"`
+	NativeTagging   bool                      `yaml:"native_tagging"`
+	StrictMetadata  bool                      `yaml:"strict_metadata"`
+	MaxBandwidth    int                       `yaml:"max_bandwidth"`
+	Timeouts        TidalDownloadTimeouts     `yaml:"timeouts"`
+	Concurrency     TidalDownloadConcurrency  `yaml:"concurrency"`
+	Warmup          TidalDownloadWarmup       `yaml:"warmup"`
+	Cover           TidalDownloadCover        `yaml:"cover"`
+	FailedTracks    TidalDownloadFailedTracks `yaml:"failed_tracks"`
+	Filters         TidalDownloadFilters      `yaml:"filters"`
+	CreditsBatch    TidalDownloadCreditsBatch `yaml:"credits_batch"`
+	// Naming is the scheme of the human-readable paths downloaded tracks are linked to, relative to the library
+	// directory. Tracks are not linked to the library if it is empty. See [NamingPlaceholders].
+	Naming string `yaml:"naming"`
 }
 
+// NamingPlaceholders are the placeholders of the naming scheme of downloaded tracks, each of which is replaced
+// with the named track field, e.g., {title}.
+var NamingPlaceholders = []string{"artist", "album", "year", "disc", "track", "title", "ext", "id"}
+
+var namingPlaceholderRegex = regexp.MustCompile(`\{([^{}]*)\}`)
+
 func (td *TidalDownloader) ToDict() *zerolog.Event {
 	return zerolog.
 		Dict().
 		Str("hifi_api", td.HifiAPI).
+		Str("cdn_cache_url", td.CDNCacheURL).
+		Str("duplicate_tracks", td.DuplicateTracks).
+		Bool("replay_gain", td.ReplayGain).
+		Bool("native_tagging", td.NativeTagging).
+		Bool("strict_metadata", td.StrictMetadata).
+		Int("max_bandwidth", td.MaxBandwidth).
 		Dict("timeouts", td.Timeouts.ToDict()).
-		Dict("concurrency", td.Concurrency.ToDict())
+		Dict("concurrency", td.Concurrency.ToDict()).
+		Dict("warmup", td.Warmup.ToDict()).
+		Dict("cover", td.Cover.ToDict()).
+		Dict("failed_tracks", td.FailedTracks.ToDict()).
+		Dict("filters", td.Filters.ToDict()).
+		Dict("credits_batch", td.CreditsBatch.ToDict()).
+		Str("naming", td.Naming)
 }
 
 func (td *TidalDownloader) setDefaults() {
+	if td.DuplicateTracks == "" {
+		td.DuplicateTracks = DuplicateTracksKeep
+	}
 	td.Timeouts.setDefaults()
 	td.Concurrency.setDefaults()
+	td.Warmup.setDefaults()
+	td.Cover.setDefaults()
+	td.FailedTracks.setDefaults()
+	td.CreditsBatch.setDefaults()
 }
 
 func (td *TidalDownloader) validate() error {
@@ -274,6 +1193,29 @@ func (td *TidalDownloader) validate() error {
 		return errors.New("hifi_api must have a non-empty host")
 	}
 
+	if len(td.CDNCacheURL) > 0 {
+		cacheURL, err := url.Parse(td.CDNCacheURL)
+		if nil != err {
+			return fmt.Errorf("cdn_cache_url is not a valid URL: %v", err)
+		}
+
+		if cacheURL.Scheme != "http" && cacheURL.Scheme != "https" {
+			return fmt.Errorf("cdn_cache_url scheme must be http or https, got: %s", cacheURL.Scheme)
+		}
+
+		if cacheURL.Host == "" {
+			return errors.New("cdn_cache_url must have a non-empty host")
+		}
+	}
+
+	if td.DuplicateTracks != DuplicateTracksKeep && td.DuplicateTracks != DuplicateTracksDedupe {
+		return fmt.Errorf("duplicate_tracks must be either %s or %s, got: %s", DuplicateTracksKeep, DuplicateTracksDedupe, td.DuplicateTracks)
+	}
+
+	if td.MaxBandwidth < 0 {
+		return errors.New("max_bandwidth must be greater than or equal to 0")
+	}
+
 	if err := td.Timeouts.validate(); nil != err {
 		return fmt.Errorf("timeouts config validation: %v", err)
 	}
@@ -282,6 +1224,58 @@ func (td *TidalDownloader) validate() error {
 		return fmt.Errorf("concurrency config validation: %v", err)
 	}
 
+	if err := td.Warmup.validate(); nil != err {
+		return fmt.Errorf("warmup config validation: %v", err)
+	}
+
+	if err := td.Cover.validate(); nil != err {
+		return fmt.Errorf("cover config validation: %v", err)
+	}
+
+	if err := td.FailedTracks.validate(); nil != err {
+		return fmt.Errorf("failed_tracks config validation: %v", err)
+	}
+
+	if err := td.Filters.validate(); nil != err {
+		return fmt.Errorf("filters config validation: %v", err)
+	}
+
+	if err := td.CreditsBatch.validate(); nil != err {
+		return fmt.Errorf("credits_batch config validation: %v", err)
+	}
+
+	if err := validateNaming(td.Naming); nil != err {
+		return fmt.Errorf("naming is invalid: %v", err)
+	}
+
+	return nil
+}
+
+func validateNaming(scheme string) error {
+	if scheme == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(scheme, "/") {
+		return errors.New("must be relative to the library directory")
+	}
+
+	for _, segment := range strings.Split(scheme, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("must not have empty, . or .. path segments, got: %q", segment)
+		}
+	}
+
+	for _, match := range namingPlaceholderRegex.FindAllStringSubmatch(scheme, -1) {
+		if !slices.Contains(NamingPlaceholders, match[1]) {
+			return fmt.Errorf("unknown placeholder %s, must be one of: %s", match[0], strings.Join(NamingPlaceholders, ", "))
+		}
+	}
+
+	if !strings.HasSuffix(scheme, ".{ext}") {
+		return errors.New("must end with .{ext}")
+	}
+
 	return nil
 }
 
@@ -444,35 +1438,250 @@ func (tdc *TidalDownloadConcurrency) setDefaults() {
 		tdc.ArtistCreditsTracks = 7
 	}
 
-	if tdc.VNDTrackParts == 0 {
-		tdc.VNDTrackParts = 5
+	if tdc.VNDTrackParts == 0 {
+		tdc.VNDTrackParts = 5
+	}
+}
+
+func (tdc *TidalDownloadConcurrency) validate() error {
+	if tdc.AlbumTracks < 0 {
+		return errors.New("album_tracks must be greater than 0")
+	}
+
+	if tdc.PlaylistTracks < 0 {
+		return errors.New("playlist_tracks must be greater than 0")
+	}
+
+	if tdc.MixTracks < 0 {
+		return errors.New("mix_tracks must be greater than 0")
+	}
+
+	if tdc.ArtistCreditsTracks < 0 {
+		return errors.New("artist_credits_tracks must be greater than 0")
+	}
+
+	if tdc.VNDTrackParts < 0 {
+		return errors.New("vnd_track_parts must be greater than 0")
+	}
+
+	return nil
+}
+
+// TidalDownloadCreditsBatch configures fetching the credits of the tracks of playlists and mixes in batches of
+// their parent albums, which takes a request per album page instead of a request per track.
+type TidalDownloadCreditsBatch struct {
+	Disabled bool `yaml:"disabled"`
+	// MinTracks is the number of tracks of an album a playlist or mix must have for their credits to be fetched
+	// with the album. The credits of the rest of the tracks are fetched one by one.
+	MinTracks int `yaml:"min_tracks"`
+	// Concurrency is the number of albums whose credits are fetched concurrently.
+	Concurrency int `yaml:"concurrency"`
+}
+
+func (tdcb *TidalDownloadCreditsBatch) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("disabled", tdcb.Disabled).
+		Int("min_tracks", tdcb.MinTracks).
+		Int("concurrency", tdcb.Concurrency)
+}
+
+func (tdcb *TidalDownloadCreditsBatch) setDefaults() {
+	if tdcb.MinTracks == 0 {
+		tdcb.MinTracks = 2
+	}
+
+	if tdcb.Concurrency == 0 {
+		tdcb.Concurrency = 2
+	}
+}
+
+func (tdcb *TidalDownloadCreditsBatch) validate() error {
+	if tdcb.MinTracks < 0 {
+		return errors.New("min_tracks must be greater than 0")
+	}
+
+	if tdcb.Concurrency < 0 {
+		return errors.New("concurrency must be greater than 0")
+	}
+
+	return nil
+}
+
+// TidalDownloadWarmup configures the reduced concurrency of track downloads after startup. Concurrency ramps
+// up from concurrency to the configured track concurrency over duration, or over the first tracks downloads,
+// whichever comes first.
+type TidalDownloadWarmup struct {
+	Disabled    bool     `yaml:"disabled"`
+	Duration    Duration `yaml:"duration"`
+	Tracks      int      `yaml:"tracks"`
+	Concurrency int      `yaml:"concurrency"`
+}
+
+func (tdw *TidalDownloadWarmup) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("disabled", tdw.Disabled).
+		Dur("duration", tdw.Duration.Duration).
+		Int("tracks", tdw.Tracks).
+		Int("concurrency", tdw.Concurrency)
+}
+
+func (tdw *TidalDownloadWarmup) setDefaults() {
+	if tdw.Duration.Duration == 0 {
+		tdw.Duration.Duration = 5 * time.Minute
+	}
+
+	if tdw.Tracks == 0 {
+		tdw.Tracks = 50
+	}
+
+	if tdw.Concurrency == 0 {
+		tdw.Concurrency = 1
+	}
+}
+
+func (tdw *TidalDownloadWarmup) validate() error {
+	if tdw.Duration.Duration < 0 {
+		return errors.New("duration must be greater than 0")
+	}
+
+	if tdw.Tracks < 0 {
+		return errors.New("tracks must be greater than 0")
+	}
+
+	if tdw.Concurrency < 0 {
+		return errors.New("concurrency must be greater than 0")
+	}
+
+	return nil
+}
+
+const (
+	// CoverResolution1280 downloads covers in 1280x1280, or the highest resolution below it that is available.
+	CoverResolution1280 = "1280"
+	// CoverResolutionOrigin downloads covers in the resolution they were uploaded to Tidal in, or the highest
+	// available resolution of 1280x1280 and below if the original is not available.
+	CoverResolutionOrigin = "origin"
+)
+
+// TidalDownloadCover configures the resolution of downloaded covers. Covers larger than embed_size are embedded
+// in tracks scaled down to it, and the original album covers are posted as photos before the albums. Video
+// covers of the albums that have them are downloaded, and posted before the albums, if video is set.
+type TidalDownloadCover struct {
+	Resolution string `yaml:"resolution"`
+	EmbedSize  int    `yaml:"embed_size"`
+	Video      bool   `yaml:"video"`
+}
+
+func (tdc *TidalDownloadCover) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("resolution", tdc.Resolution).
+		Int("embed_size", tdc.EmbedSize).
+		Bool("video", tdc.Video)
+}
+
+func (tdc *TidalDownloadCover) setDefaults() {
+	if tdc.Resolution == "" {
+		tdc.Resolution = CoverResolution1280
+	}
+}
+
+func (tdc *TidalDownloadCover) validate() error {
+	if tdc.Resolution != CoverResolution1280 && tdc.Resolution != CoverResolutionOrigin {
+		return fmt.Errorf("resolution must be either %s or %s, got: %s", CoverResolution1280, CoverResolutionOrigin, tdc.Resolution)
+	}
+
+	if tdc.EmbedSize != 0 && tdc.EmbedSize < 320 {
+		return errors.New("embed_size must be 0 or greater than or equal to 320")
+	}
+
+	return nil
+}
+
+// TidalDownloadFailedTracks configures how the tracks of playlists, mixes, and artist credits that fail to
+// download are handled. Failures that would fail the rest of the tracks as well, e.g., hitting the rate limit,
+// or running out of disk space, always fail the download.
+type TidalDownloadFailedTracks struct {
+	Policy     string `yaml:"policy"`
+	MaxSkipped int    `yaml:"max_skipped"`
+}
+
+func (tdft *TidalDownloadFailedTracks) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("policy", tdft.Policy).
+		Int("max_skipped", tdft.MaxSkipped)
+}
+
+func (tdft *TidalDownloadFailedTracks) setDefaults() {
+	if tdft.Policy == "" {
+		tdft.Policy = FailedTracksAbort
+	}
+
+	if tdft.MaxSkipped == 0 {
+		tdft.MaxSkipped = 10
 	}
 }
 
-func (tdc *TidalDownloadConcurrency) validate() error {
-	if tdc.AlbumTracks < 0 {
-		return errors.New("album_tracks must be greater than 0")
+func (tdft *TidalDownloadFailedTracks) validate() error {
+	if tdft.Policy != FailedTracksAbort && tdft.Policy != FailedTracksSkip {
+		return fmt.Errorf("policy must be either %s or %s, got: %s", FailedTracksAbort, FailedTracksSkip, tdft.Policy)
 	}
 
-	if tdc.PlaylistTracks < 0 {
-		return errors.New("playlist_tracks must be greater than 0")
+	if tdft.MaxSkipped < 1 {
+		return errors.New("max_skipped must be at least 1")
 	}
 
-	if tdc.MixTracks < 0 {
-		return errors.New("mix_tracks must be greater than 0")
-	}
+	return nil
+}
 
-	if tdc.ArtistCreditsTracks < 0 {
-		return errors.New("artist_credits_tracks must be greater than 0")
+// TidalDownloadFilters configures the tracks of playlists and mixes that are skipped, rather than downloaded.
+// Skipped tracks are listed in the job result message.
+type TidalDownloadFilters struct {
+	// MaxDuration skips the tracks longer than it, unless it is zero.
+	MaxDuration Duration `yaml:"max_duration"`
+	// SkipTitles skips the tracks whose titles, including their versions, e.g., Song (Instrumental), match any
+	// of these regular expressions.
+	SkipTitles []string `yaml:"skip_titles"`
+	// DedupeISRC skips the tracks whose ISRC is the same as the one of another track of the same job, i.e., the
+	// same recording released as another track, e.g., on a compilation.
+	DedupeISRC bool `yaml:"dedupe_isrc"`
+}
+
+func (tdf *TidalDownloadFilters) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Dur("max_duration", tdf.MaxDuration.Duration).
+		Strs("skip_titles", tdf.SkipTitles).
+		Bool("dedupe_isrc", tdf.DedupeISRC)
+}
+
+func (tdf *TidalDownloadFilters) validate() error {
+	if tdf.MaxDuration.Duration < 0 {
+		return errors.New("max_duration must be greater than or equal to 0")
 	}
 
-	if tdc.VNDTrackParts < 0 {
-		return errors.New("vnd_track_parts must be greater than 0")
+	for i, pattern := range tdf.SkipTitles {
+		if _, err := regexp.Compile(pattern); nil != err {
+			return fmt.Errorf("skip_titles[%d] is not a valid regular expression: %v", i, err)
+		}
 	}
 
 	return nil
 }
 
+// SkipTitlePatterns returns the compiled SkipTitles. It must only be called once the config is validated.
+func (tdf *TidalDownloadFilters) SkipTitlePatterns() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(tdf.SkipTitles))
+	for i, pattern := range tdf.SkipTitles {
+		patterns[i] = regexp.MustCompile(pattern)
+	}
+
+	return patterns
+}
+
 type Telegram struct {
 	AppID   int             `yaml:"app_id"`
 	AppHash string          `yaml:"app_hash"`
@@ -521,23 +1730,50 @@ func (tg *Telegram) validate() error {
 	return nil
 }
 
+// DefaultTelegramSession is the name of the session that is used unless another session is configured. It is
+// the session that was stored before named sessions were supported.
+const DefaultTelegramSession = "default"
+
+var telegramSessionNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
 type TelegramStorage struct {
 	Path string `yaml:"path"`
+	// Session is the name of the session of the account to use, e.g., to switch between upload accounts
+	// without logging in again. Uploaded files, and resolved peers are stored per session.
+	Session string `yaml:"session"`
 }
 
 func (ts *TelegramStorage) ToDict() *zerolog.Event {
 	return zerolog.
 		Dict().
-		Str("path", ts.Path)
+		Str("path", ts.Path).
+		Str("session", ts.Session)
 }
 
 func (ts *TelegramStorage) setDefaults() {
 	if ts.Path == "" {
 		ts.Path = "./telegram.db"
 	}
+
+	if ts.Session == "" {
+		ts.Session = DefaultTelegramSession
+	}
 }
 
 func (ts *TelegramStorage) validate() error {
+	if err := ValidateTelegramSession(ts.Session); nil != err {
+		return fmt.Errorf("session is invalid: %v", err)
+	}
+
+	return nil
+}
+
+// ValidateTelegramSession fails if name is not a valid session name.
+func ValidateTelegramSession(name string) error {
+	if !telegramSessionNameRegex.MatchString(name) {
+		return fmt.Errorf("session name must be 1 to 64 letters, digits, underscores, or hyphens, got: %q", name)
+	}
+
 	return nil
 }
 
@@ -607,24 +1843,88 @@ func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
 	return nil
 }
 
+// DefaultUploadCaption renders the album title and release date, followed by the disc and track numbers.
+const DefaultUploadCaption = "<blockquote>{{.Title}} ({{.ReleaseDate}})</blockquote>\n<i>Disc {{.Disc}} / Track {{.Track}}</i>"
+
+// Typing indicator modes shown to upload peers while tracks are being uploaded.
+const (
+	// TypingOff does not show any indicator.
+	TypingOff = "off"
+	// TypingProgress shows an uploading document indicator with the upload progress, updated every second.
+	TypingProgress = "progress"
+	// TypingSimple shows an uploading document indicator without the upload progress, refreshed only
+	// as often as needed to keep it visible.
+	TypingSimple = "simple"
+)
+
+const (
+	// SignatureRotationRoundRobin uses the signatures in order, one post after another.
+	SignatureRotationRoundRobin = "round_robin"
+	// SignatureRotationRandom uses a random signature for each post.
+	SignatureRotationRandom = "random"
+	// SignatureRotationWeekday uses the signature at the index of the weekday of the post, starting from Sunday,
+	// wrapping around if there are fewer than seven signatures.
+	SignatureRotationWeekday = "weekday"
+)
+
 type TelegramUpload struct {
-	Threads       int                `yaml:"threads"`
-	PoolSize      int                `yaml:"pool_size"`
-	Limit         int                `yaml:"limit"`
-	Signature     string             `yaml:"signature"`
-	Peer          TelegramUploadPeer `yaml:"peer"`
-	PauseDuration Duration           `yaml:"pause_duration"`
+	Threads           int                       `yaml:"threads"`
+	PoolSize          int                       `yaml:"pool_size"`
+	Limit             int                       `yaml:"limit"`
+	Caption           string                    `yaml:"caption"`
+	Signature         string                    `yaml:"signature"`
+	Signatures        []string                  `yaml:"signatures"`
+	SignatureRotation string                    `yaml:"signature_rotation"`
+	Peer              TelegramUploadPeer        `yaml:"peer"`
+	Peers             []TelegramUploadPeer      `yaml:"peers"`
+	JoinInvites       bool                      `yaml:"join_invites"`
+	Destinations      []string                  `yaml:"destinations"`
+	PauseDuration     Duration                  `yaml:"pause_duration"`
+	Pacing            TelegramUploadPacing      `yaml:"pacing"`
+	Archive           TelegramUploadArchive     `yaml:"archive"`
+	Sidecars          bool                      `yaml:"metadata_sidecars"`
+	LyricsFiles       bool                      `yaml:"lyrics_files"`
+	Pipelined         bool                      `yaml:"pipelined"`
+	StreamSingles     bool                      `yaml:"stream_singles"`
+	Oversized         TelegramUploadOversized   `yaml:"oversized"`
+	Typing            string                    `yaml:"typing"`
+	ReadHistory       TelegramUploadReadHistory `yaml:"read_history"`
+	Summary           TelegramUploadSummary     `yaml:"summary"`
+	Notify            bool                      `yaml:"notify"`
+	MaxBandwidth      int                       `yaml:"max_bandwidth"`
 }
 
 func (tu *TelegramUpload) ToDict() *zerolog.Event {
+	peers := zerolog.Arr()
+	for _, p := range tu.Peers {
+		peers.Dict(p.ToDict())
+	}
+
 	return zerolog.
 		Dict().
 		Int("threads", tu.Threads).
 		Int("pool_size", tu.PoolSize).
 		Int("limit", tu.Limit).
+		Str("caption", tu.Caption).
 		Str("signature", tu.Signature).
-		Dict("peer", tu.Peer.ToDict()).
-		Dur("pause_duration", tu.PauseDuration.Duration)
+		Strs("signatures", tu.Signatures).
+		Str("signature_rotation", tu.SignatureRotation).
+		Array("peers", peers).
+		Bool("join_invites", tu.JoinInvites).
+		Strs("destinations", tu.Destinations).
+		Dur("pause_duration", tu.PauseDuration.Duration).
+		Dict("pacing", tu.Pacing.ToDict()).
+		Dict("archive", tu.Archive.ToDict()).
+		Bool("metadata_sidecars", tu.Sidecars).
+		Bool("lyrics_files", tu.LyricsFiles).
+		Bool("pipelined", tu.Pipelined).
+		Bool("stream_singles", tu.StreamSingles).
+		Dict("oversized", tu.Oversized.ToDict()).
+		Str("typing", tu.Typing).
+		Dict("read_history", tu.ReadHistory.ToDict()).
+		Dict("summary", tu.Summary.ToDict()).
+		Bool("notify", tu.Notify).
+		Int("max_bandwidth", tu.MaxBandwidth)
 }
 
 func (tu *TelegramUpload) setDefaults() {
@@ -644,7 +1944,42 @@ func (tu *TelegramUpload) setDefaults() {
 		tu.PauseDuration.Duration = 1500 * time.Millisecond
 	}
 
-	tu.Peer.setDefaults()
+	if tu.Caption == "" {
+		tu.Caption = DefaultUploadCaption
+	}
+
+	if tu.Typing == "" {
+		tu.Typing = TypingProgress
+	}
+
+	if tu.SignatureRotation == "" {
+		tu.SignatureRotation = SignatureRotationRoundRobin
+	}
+
+	tu.Archive.setDefaults()
+	tu.Oversized.setDefaults()
+	tu.ReadHistory.setDefaults()
+
+	// Peer is the single peer form of Peers, kept for existing configs.
+	if len(tu.Peers) == 0 && tu.Peer.isSet() {
+		tu.Peers = []TelegramUploadPeer{tu.Peer}
+		tu.Peer = TelegramUploadPeer{} //nolint:exhaustruct
+	}
+
+	for i := range tu.Peers {
+		tu.Peers[i].normalize()
+	}
+
+	// Peers without a signature override use the rotated signatures, if any.
+	if len(tu.Signatures) == 0 {
+		for i := range tu.Peers {
+			tu.Peers[i].setDefaults(tu.Signature)
+		}
+	}
+
+	for i, d := range tu.Destinations {
+		tu.Destinations[i] = strings.TrimPrefix(strings.TrimSpace(d), "@")
+	}
 }
 
 func (tu *TelegramUpload) validate() error {
@@ -664,30 +1999,351 @@ func (tu *TelegramUpload) validate() error {
 		return errors.New("pause_duration must be greater than 0")
 	}
 
-	if err := tu.Peer.validate(); nil != err {
-		return fmt.Errorf("peer config validation: %v", err)
+	if tu.MaxBandwidth < 0 {
+		return errors.New("max_bandwidth must be greater than or equal to 0")
+	}
+
+	if !slices.Contains([]string{TypingOff, TypingProgress, TypingSimple}, tu.Typing) {
+		return fmt.Errorf("typing must be one of: off, progress, simple, got: %s", tu.Typing)
+	}
+
+	if len(tu.Signatures) > 0 && tu.Signature != "" {
+		return errors.New("signature and signatures cannot be used together")
+	}
+
+	validRotations := []string{SignatureRotationRoundRobin, SignatureRotationRandom, SignatureRotationWeekday}
+	if !slices.Contains(validRotations, tu.SignatureRotation) {
+		return fmt.Errorf("signature_rotation must be one of: round_robin, random, weekday, got: %s", tu.SignatureRotation)
+	}
+
+	if err := tu.Pacing.validate(); nil != err {
+		return fmt.Errorf("pacing config validation: %v", err)
+	}
+
+	if err := tu.Archive.validate(); nil != err {
+		return fmt.Errorf("archive config validation: %v", err)
+	}
+
+	if err := tu.Oversized.validate(); nil != err {
+		return fmt.Errorf("oversized config validation: %v", err)
+	}
+
+	if err := tu.ReadHistory.validate(); nil != err {
+		return fmt.Errorf("read_history config validation: %v", err)
+	}
+
+	if err := tu.Summary.validate(); nil != err {
+		return fmt.Errorf("summary config validation: %v", err)
+	}
+
+	if _, err := template.New("caption").Parse(tu.Caption); nil != err {
+		return fmt.Errorf("invalid caption template: %v", err)
+	}
+
+	if tu.Peer.isSet() {
+		return errors.New("peer and peers cannot be used together")
+	}
+
+	if len(tu.Peers) == 0 {
+		return errors.New("at least one peer is required")
+	}
+
+	seen := make(map[string]struct{}, len(tu.Peers))
+	for i, p := range tu.Peers {
+		if err := p.validate(); nil != err {
+			return fmt.Errorf("peer %d config validation: %v", i, err)
+		}
+
+		if _, ok := seen[p.key()]; ok {
+			return fmt.Errorf("peer %d is a duplicate of another peer", i)
+		}
+		seen[p.key()] = struct{}{}
+	}
+
+	for i, d := range tu.Destinations {
+		if d == "" {
+			return fmt.Errorf("destination %d must not be empty", i)
+		}
+	}
+
+	return nil
+}
+
+const (
+	// OversizedFail fails uploads of links with tracks larger than the max file size, before uploading any.
+	OversizedFail = "fail"
+	// OversizedSkip uploads links without their tracks that are larger than the max file size.
+	OversizedSkip = "skip"
+	// OversizedTranscode uploads tracks larger than the max file size transcoded down to 16-bit 44.1 kHz.
+	OversizedTranscode = "transcode"
+	// OversizedSplit uploads tracks larger than the max file size split into parts that fit in it.
+	OversizedSplit = "split"
+)
+
+// TelegramUploadOversized configures how tracks larger than the max file size Telegram accepts are uploaded.
+type TelegramUploadOversized struct {
+	Strategy      string `yaml:"strategy"`
+	MaxFileSizeMB int64  `yaml:"max_file_size_mb"`
+}
+
+func (tuo *TelegramUploadOversized) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Str("strategy", tuo.Strategy).
+		Int64("max_file_size_mb", tuo.MaxFileSizeMB)
+}
+
+func (tuo *TelegramUploadOversized) setDefaults() {
+	if tuo.Strategy == "" {
+		tuo.Strategy = OversizedFail
+	}
+
+	if tuo.MaxFileSizeMB == 0 {
+		tuo.MaxFileSizeMB = 2000
+	}
+}
+
+func (tuo *TelegramUploadOversized) validate() error {
+	if !slices.Contains([]string{OversizedFail, OversizedSkip, OversizedTranscode, OversizedSplit}, tuo.Strategy) {
+		return fmt.Errorf("strategy must be one of: fail, skip, transcode, split, got: %s", tuo.Strategy)
+	}
+
+	if tuo.MaxFileSizeMB < 0 {
+		return errors.New("max_file_size_mb must be greater than 0")
+	}
+
+	return nil
+}
+
+// TelegramUploadPacing varies the pause after each sent message or album, so that posting does not
+// happen at a fixed rhythm. Pause is pause_duration, plus per_track for each track of the sent batch,
+// plus a uniformly random offset within [-jitter, +jitter]. It is never negative.
+type TelegramUploadPacing struct {
+	Jitter   Duration `yaml:"jitter"`
+	PerTrack Duration `yaml:"per_track"`
+}
+
+func (tup *TelegramUploadPacing) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Dur("jitter", tup.Jitter.Duration).
+		Dur("per_track", tup.PerTrack.Duration)
+}
+
+func (tup *TelegramUploadPacing) validate() error {
+	if tup.Jitter.Duration < 0 {
+		return errors.New("jitter must be greater than or equal to 0")
+	}
+
+	if tup.PerTrack.Duration < 0 {
+		return errors.New("per_track must be greater than or equal to 0")
+	}
+
+	return nil
+}
+
+// TelegramUploadArchive configures uploading albums as ZIP archives, which are split into parts of at most
+// part_size_mb megabytes to stay within the Telegram file size limit.
+type TelegramUploadArchive struct {
+	Albums     bool `yaml:"albums"`
+	NFO        bool `yaml:"nfo"`
+	PartSizeMB int  `yaml:"part_size_mb"`
+}
+
+func (tua *TelegramUploadArchive) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("albums", tua.Albums).
+		Bool("nfo", tua.NFO).
+		Int("part_size_mb", tua.PartSizeMB)
+}
+
+func (tua *TelegramUploadArchive) setDefaults() {
+	if tua.PartSizeMB == 0 {
+		tua.PartSizeMB = 2000
+	}
+}
+
+func (tua *TelegramUploadArchive) validate() error {
+	if tua.PartSizeMB < 0 || tua.PartSizeMB > 4000 {
+		return errors.New("part_size_mb must be between 1 and 4000")
+	}
+
+	return nil
+}
+
+// TelegramUploadReadHistory configures how often upload peers are marked as read. Peers that received
+// uploads are marked as read once every every_links uploaded links, and also every interval, if set, which
+// saves a request per job on busy bots.
+type TelegramUploadReadHistory struct {
+	Disabled   bool     `yaml:"disabled"`
+	EveryLinks int      `yaml:"every_links"`
+	Interval   Duration `yaml:"interval"`
+}
+
+func (turh *TelegramUploadReadHistory) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("disabled", turh.Disabled).
+		Int("every_links", turh.EveryLinks).
+		Dur("interval", turh.Interval.Duration)
+}
+
+func (turh *TelegramUploadReadHistory) setDefaults() {
+	if turh.EveryLinks == 0 {
+		turh.EveryLinks = 1
+	}
+}
+
+func (turh *TelegramUploadReadHistory) validate() error {
+	if turh.EveryLinks < 0 {
+		return errors.New("every_links must be greater than 0")
+	}
+
+	if turh.Interval.Duration < 0 {
+		return errors.New("interval must be greater than or equal to 0")
+	}
+
+	return nil
+}
+
+// TelegramUploadSummary configures the message summarizing an uploaded album, which is sent after its tracks,
+// and links to its posts.
+type TelegramUploadSummary struct {
+	Enabled bool `yaml:"enabled"`
+	Pin     bool `yaml:"pin"`
+}
+
+func (tus *TelegramUploadSummary) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("enabled", tus.Enabled).
+		Bool("pin", tus.Pin)
+}
+
+func (tus *TelegramUploadSummary) validate() error {
+	if tus.Pin && !tus.Enabled {
+		return errors.New("pin requires enabled to be set")
 	}
 
 	return nil
 }
 
+// usernamePattern matches Telegram usernames.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// TelegramUploadPeer is a peer to upload to, set either by its ID and kind, or by its username, or invite link,
+// which are resolved on start.
 type TelegramUploadPeer struct {
 	ID   int64  `yaml:"id"`
 	Kind string `yaml:"kind"`
+	// Username is the username of a user, or public channel, either as is, prefixed with @, or as its t.me link.
+	Username string `yaml:"username"`
+	// Invite is the hash of the invite link of a private chat, or channel, e.g., t.me/+hash.
+	Invite string `yaml:"invite"`
+	// Topic is the ID of the forum topic of a supergroup with topics enabled to upload to, instead of its
+	// General topic. It is zero for peers without topics.
+	Topic int `yaml:"topic"`
+	// AutoDelete is the auto-delete timer set for the peer on start, after which messages sent to it are
+	// deleted. It is zero to leave the timer of the peer as is.
+	AutoDelete Duration `yaml:"auto_delete"`
+	// Signature overrides the upload signature for this peer. Set it to an empty string to upload without a signature.
+	// It is nil if the peer uses the rotated signatures.
+	Signature *string `yaml:"signature"`
 }
 
 func (tup *TelegramUploadPeer) ToDict() *zerolog.Event {
 	return zerolog.
 		Dict().
 		Int64("id", tup.ID).
-		Str("kind", tup.Kind)
+		Str("kind", tup.Kind).
+		Str("username", tup.Username).
+		Bool("invite", tup.Invite != "").
+		Int("topic", tup.Topic).
+		Dur("auto_delete", tup.AutoDelete.Duration).
+		Str("signature", lo.FromPtr(tup.Signature))
+}
+
+// isSet reports whether the peer is set in any form.
+func (tup *TelegramUploadPeer) isSet() bool {
+	return tup.ID != 0 || tup.Username != "" || tup.Invite != ""
+}
+
+// normalize strips the t.me link prefixes of the username and invite link, if any, and the @ prefix of the
+// username.
+func (tup *TelegramUploadPeer) normalize() {
+	tup.Username = strings.TrimPrefix(trimTelegramLink(tup.Username), "@")
+
+	invite := trimTelegramLink(tup.Invite)
+	if hash, ok := strings.CutPrefix(invite, "joinchat/"); ok {
+		invite = hash
+	}
+	tup.Invite = strings.TrimPrefix(invite, "+")
+}
+
+// trimTelegramLink returns the path of a t.me link, or s as is if it is not a link.
+func trimTelegramLink(s string) string {
+	s = strings.TrimSpace(s)
+	for _, prefix := range []string{"https://", "http://"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	for _, host := range []string{"t.me/", "telegram.me/"} {
+		s = strings.TrimPrefix(s, host)
+	}
+
+	return strings.TrimSuffix(s, "/")
 }
 
-func (tup *TelegramUploadPeer) setDefaults() {}
+// key identifies the peer among the configured peers, by the form it is set in.
+func (tup *TelegramUploadPeer) key() string {
+	switch {
+	case tup.Username != "":
+		return "username/" + strings.ToLower(tup.Username)
+	case tup.Invite != "":
+		return "invite/" + tup.Invite
+	default:
+		return tup.Kind + "/" + strconv.FormatInt(tup.ID, 10)
+	}
+}
+
+func (tup *TelegramUploadPeer) setDefaults(signature string) {
+	if nil == tup.Signature {
+		tup.Signature = &signature
+	}
+}
 
 func (tup *TelegramUploadPeer) validate() error {
+	forms := lo.Count([]bool{tup.ID != 0, tup.Username != "", tup.Invite != ""}, true)
+	if forms == 0 {
+		return errors.New("one of id, username, or invite is required")
+	} else if forms > 1 {
+		return errors.New("only one of id, username, or invite can be set")
+	}
+
+	if tup.Topic < 0 {
+		return errors.New("topic must be greater than or equal to 0")
+	}
+
+	// Telegram only supports auto-delete timers between a day and a year.
+	if d := tup.AutoDelete.Duration; d != 0 && (d < 24*time.Hour || d > 365*24*time.Hour) {
+		return fmt.Errorf("auto_delete must be between 24h and 8760h, got: %s", d)
+	}
+
+	// Forums are supergroups, which are channels.
+	if tup.Topic > 0 && (tup.Kind == "user" || tup.Kind == "chat") {
+		return fmt.Errorf("topic can only be set for channel peers, got: %s", tup.Kind)
+	}
+
 	if tup.ID == 0 {
-		return errors.New("id is required")
+		if tup.Kind != "" {
+			return errors.New("kind must not be set along with username, or invite, as it is resolved")
+		}
+
+		if tup.Username != "" && !usernamePattern.MatchString(tup.Username) {
+			return fmt.Errorf("invalid username: %s", tup.Username)
+		}
+
+		return nil
 	}
 
 	if tup.Kind == "" {
@@ -699,12 +2355,29 @@ func (tup *TelegramUploadPeer) validate() error {
 	return nil
 }
 
-func Load(filename string) (*Config, error) {
+// Load reads the config file at filename, and applies overrides to it before validation. Overrides are
+// key=value pairs, where key is the dot separated path of the field, e.g., telegram.upload.limit=4.
+func Load(filename string, overrides []string) (*Config, error) {
 	data, err := os.ReadFile(lo.Ternary(len(filename) > 0, filename, "config.yaml"))
 	if nil != err {
 		return nil, fmt.Errorf("read config file %s: %v", filename, err)
 	}
 
+	if len(overrides) > 0 {
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); nil != err {
+			return nil, fmt.Errorf("parse config file %s: %v", filename, err)
+		}
+
+		if err := applyOverrides(&root, overrides); nil != err {
+			return nil, fmt.Errorf("apply config overrides: %v", err)
+		}
+
+		if data, err = yaml.Marshal(&root); nil != err {
+			return nil, fmt.Errorf("encode overridden config: %v", err)
+		}
+	}
+
 	var conf Config
 	decoder := yaml.NewDecoder(bytes.NewReader(data))
 	decoder.KnownFields(true)
@@ -713,6 +2386,7 @@ func Load(filename string) (*Config, error) {
 	}
 
 	conf.Bot.Token = os.Getenv("BOT_TOKEN")
+	conf.Encryption.Passphrase = os.Getenv("ENCRYPTION_PASSPHRASE")
 	conf.setDefaults()
 
 	if err := conf.validate(); nil != err {
@@ -721,3 +2395,128 @@ func Load(filename string) (*Config, error) {
 
 	return &conf, nil
 }
+
+// API configures the optional HTTP API, which streams job lifecycle and upload progress events.
+type API struct {
+	Enabled    bool   `yaml:"enabled"`
+	ListenAddr string `yaml:"listen_addr"`
+	Token      string `yaml:"token"`
+}
+
+func (a *API) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("enabled", a.Enabled).
+		Str("listen_addr", a.ListenAddr).
+		Str("token", redact.String(a.Token))
+}
+
+func (a *API) setDefaults() {
+	if a.ListenAddr == "" {
+		a.ListenAddr = "127.0.0.1:8080"
+	}
+}
+
+func (a *API) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(a.ListenAddr); nil != err {
+		return fmt.Errorf("invalid listen_addr: %v", err)
+	}
+
+	return nil
+}
+
+// Health configures the optional health check server, which serves liveness and readiness probes for container
+// orchestrators.
+type Health struct {
+	Enabled    bool   `yaml:"enabled"`
+	ListenAddr string `yaml:"listen_addr"`
+	// Interval is how often the checks are run. Probes are answered with the results of the last run.
+	Interval Duration `yaml:"interval"`
+	Timeout  Duration `yaml:"timeout"`
+	// MaxJobDuration is how long a job can run before the worker is considered stuck. Paused jobs are never
+	// considered stuck. Zero disables the check.
+	MaxJobDuration Duration `yaml:"max_job_duration"`
+}
+
+func (h *Health) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("enabled", h.Enabled).
+		Str("listen_addr", h.ListenAddr).
+		Dur("interval", h.Interval.Duration).
+		Dur("timeout", h.Timeout.Duration).
+		Dur("max_job_duration", h.MaxJobDuration.Duration)
+}
+
+func (h *Health) setDefaults() {
+	if h.ListenAddr == "" {
+		h.ListenAddr = ":8081"
+	}
+
+	if h.Interval.Duration == 0 {
+		h.Interval.Duration = time.Minute
+	}
+
+	if h.Timeout.Duration == 0 {
+		h.Timeout.Duration = 15 * time.Second
+	}
+}
+
+func (h *Health) validate() error {
+	if !h.Enabled {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(h.ListenAddr); nil != err {
+		return fmt.Errorf("invalid listen_addr: %v", err)
+	}
+
+	if h.Interval.Duration < 10*time.Second {
+		return errors.New("interval must be at least 10s")
+	}
+
+	if h.Timeout.Duration < time.Second || h.Timeout.Duration > h.Interval.Duration {
+		return errors.New("timeout must be at least 1s, and at most interval")
+	}
+
+	if h.MaxJobDuration.Duration < 0 {
+		return errors.New("max_job_duration must be greater than or equal to 0")
+	}
+
+	return nil
+}
+
+// minPassphraseLength is the minimum length of the encryption passphrase.
+const minPassphraseLength = 12
+
+// Encryption configures the encryption of Tidal credentials and the Telegram session at rest. Plaintext files
+// written before encryption was enabled are encrypted the first time they are read.
+type Encryption struct {
+	Enabled bool `yaml:"enabled"`
+	// Passphrase is what the encryption key is derived from. It is read from the ENCRYPTION_PASSPHRASE
+	// environment variable.
+	Passphrase string `yaml:"-"`
+}
+
+func (e *Encryption) ToDict() *zerolog.Event {
+	return zerolog.
+		Dict().
+		Bool("enabled", e.Enabled).
+		Str("passphrase", redact.String(e.Passphrase))
+}
+
+func (e *Encryption) validate() error {
+	if !e.Enabled {
+		return nil
+	}
+
+	if len(e.Passphrase) < minPassphraseLength {
+		return fmt.Errorf("ENCRYPTION_PASSPHRASE environment variable must be at least %d characters", minPassphraseLength)
+	}
+
+	return nil
+}
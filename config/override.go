@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvOverridePrefix is the prefix of environment variables that override config fields. The rest of the
+// variable name is the field path, with path segments separated by double underscores, e.g.,
+// TIDALGRAM_TELEGRAM__UPLOAD__LIMIT overrides telegram.upload.limit.
+const EnvOverridePrefix = "TIDALGRAM_"
+
+// EnvOverrides returns the config field overrides set in environ, formatted as key=value pairs, and the names
+// of the prefixed variables that do not match the path of any config field, which are not returned as overrides,
+// as they would fail loading the config otherwise.
+func EnvOverrides(environ []string) (overrides []string, unknown []string) {
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, EnvOverridePrefix) {
+			continue
+		}
+
+		path := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, EnvOverridePrefix), "__", "."))
+		if !isFieldPath(reflect.TypeFor[Config](), strings.Split(path, ".")) {
+			unknown = append(unknown, key)
+			continue
+		}
+		overrides = append(overrides, path+"="+value)
+	}
+
+	return overrides, unknown
+}
+
+// isFieldPath reports whether path, split into its segments, addresses a field, a list item, or a mapping
+// value of a value of type t, or of any of its nested values.
+func isFieldPath(t reflect.Type, path []string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if len(path) == 0 {
+		return true
+	}
+	if len(path[0]) == 0 {
+		return false
+	}
+	if _, ok := reflect.PointerTo(t).MethodByName("UnmarshalYAML"); ok {
+		// Values decoded by themselves, e.g., durations, are set as a whole.
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if len(name) == 0 {
+				name = strings.ToLower(field.Name)
+			}
+			if name == path[0] {
+				return isFieldPath(field.Type, path[1:])
+			}
+		}
+
+		return false
+	case reflect.Slice, reflect.Array:
+		if idx, err := strconv.Atoi(path[0]); nil != err || idx < 0 {
+			return false
+		}
+
+		return isFieldPath(t.Elem(), path[1:])
+	case reflect.Map:
+		return isFieldPath(t.Elem(), path[1:])
+	default:
+		return false
+	}
+}
+
+// applyOverrides sets the config fields of the YAML document root to the values of overrides, formatted as
+// key=value pairs, where key is the dot separated field path, e.g., telegram.upload.limit=4. Values are
+// parsed as YAML, so lists and mappings can be set too. Items of lists are addressed by their index.
+func applyOverrides(root *yaml.Node, overrides []string) error {
+	if root.Kind == 0 {
+		// Empty config files decode to a zero node.
+		*root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}} //nolint:exhaustruct
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) != 1 {
+		return errors.New("config file is not a single YAML document")
+	}
+
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok || len(key) == 0 {
+			return fmt.Errorf("invalid override %q: must be formatted as key=value", override)
+		}
+
+		if err := setNode(root.Content[0], strings.Split(key, "."), parseOverrideValue(value)); nil != err {
+			return fmt.Errorf("override %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+func parseOverrideValue(value string) *yaml.Node {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); nil != err || len(doc.Content) != 1 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value} //nolint:exhaustruct
+	}
+
+	return doc.Content[0]
+}
+
+func setNode(node *yaml.Node, path []string, value *yaml.Node) error {
+	name := path[0]
+	if len(name) == 0 {
+		return errors.New("empty path segment")
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != name {
+				continue
+			}
+
+			if len(path) == 1 {
+				node.Content[i+1] = value
+				return nil
+			}
+
+			return setNode(node.Content[i+1], path[1:], value)
+		}
+
+		child := value
+		if len(path) > 1 {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"} //nolint:exhaustruct
+			if err := setNode(child, path[1:], value); nil != err {
+				return err
+			}
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, child) //nolint:exhaustruct
+
+		return nil
+	case yaml.SequenceNode:
+		idx, err := strconv.Atoi(name)
+		if nil != err || idx < 0 || idx > len(node.Content) {
+			return fmt.Errorf("invalid list index %q, must be between 0 and %d", name, len(node.Content))
+		}
+
+		if idx == len(node.Content) {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}) //nolint:exhaustruct
+		}
+
+		if len(path) == 1 {
+			node.Content[idx] = value
+			return nil
+		}
+
+		return setNode(node.Content[idx], path[1:], value)
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"} //nolint:exhaustruct
+			return setNode(node, path, value)
+		}
+
+		return fmt.Errorf("cannot set %s of a scalar value", name)
+	default:
+		return fmt.Errorf("unsupported YAML node kind at %s", name)
+	}
+}
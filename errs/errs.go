@@ -0,0 +1,120 @@
+// Package errs categorizes errors, so that failures are reported to users as a short code and message, while
+// logs keep the full error.
+package errs
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+type Category string
+
+const (
+	CategoryAuth          Category = "auth"
+	CategoryRateLimit     Category = "ratelimit"
+	CategoryNetwork       Category = "network"
+	CategoryFFmpeg        Category = "ffmpeg"
+	CategoryTelegramFlood Category = "telegram-flood"
+	CategoryDisk          Category = "disk"
+	// CategoryInternal is the category of errors that do not belong to any other category.
+	CategoryInternal Category = "internal"
+)
+
+// Code returns the user-facing code of the category, e.g., E-AUTH.
+func (c Category) Code() string {
+	switch c {
+	case CategoryAuth:
+		return "E-AUTH"
+	case CategoryRateLimit:
+		return "E-RATELIMIT"
+	case CategoryNetwork:
+		return "E-NETWORK"
+	case CategoryFFmpeg:
+		return "E-FFMPEG"
+	case CategoryTelegramFlood:
+		return "E-TGFLOOD"
+	case CategoryDisk:
+		return "E-DISK"
+	default:
+		return "E-INTERNAL"
+	}
+}
+
+// Message returns the user-facing description of the category.
+func (c Category) Message() string {
+	switch c {
+	case CategoryAuth:
+		return "Authorization failed. You might need to login again."
+	case CategoryRateLimit:
+		return "Tidal rate limit was hit. Try again later."
+	case CategoryNetwork:
+		return "Network request failed. Try again later."
+	case CategoryFFmpeg:
+		return "Processing the track with ffmpeg failed."
+	case CategoryTelegramFlood:
+		return "Telegram asked to slow down. Try again later."
+	case CategoryDisk:
+		return "Not enough disk space, or the disk is not writable."
+	default:
+		return "Unexpected error."
+	}
+}
+
+// Error is an error of a category.
+type Error struct {
+	Category Category
+	Err      error
+}
+
+// New returns an error of category with text, e.g., to be used as a sentinel error.
+func New(category Category, text string) error {
+	return &Error{Category: category, Err: errors.New(text)}
+}
+
+// Wrap returns err as an error of category. It returns nil if err is nil.
+func Wrap(category Category, err error) error {
+	if nil == err {
+		return nil
+	}
+
+	return &Error{Category: category, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) ErrorCategory() Category {
+	return e.Category
+}
+
+// categorized is implemented by errors that belong to a category, such as Error.
+type categorized interface {
+	error
+	ErrorCategory() Category
+}
+
+// Classify returns the category of err. Errors that are not explicitly categorized are categorized by their
+// cause, e.g., running out of disk space, or a failed network request, and are CategoryInternal otherwise.
+func Classify(err error) Category {
+	var c categorized
+	if errors.As(err, &c) {
+		return c.ErrorCategory()
+	}
+
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || errors.Is(err, syscall.EROFS) {
+		return CategoryDisk
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return CategoryNetwork
+	}
+
+	return CategoryInternal
+}
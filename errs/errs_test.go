@@ -0,0 +1,38 @@
+package errs_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xeptore/tidalgram/errs"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	errRateLimited := errs.New(errs.CategoryRateLimit, "too many requests")
+
+	pairs := []struct {
+		err      error
+		category errs.Category
+	}{
+		{errRateLimited, errs.CategoryRateLimit},
+		{fmt.Errorf("get track meta: %w", errRateLimited), errs.CategoryRateLimit},
+		{errs.Wrap(errs.CategoryFFmpeg, errors.New("exit status 1")), errs.CategoryFFmpeg},
+		{&os.PathError{Op: "write", Path: "track.flac", Err: syscall.ENOSPC}, errs.CategoryDisk},
+		{fmt.Errorf("send request: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("refused")}), errs.CategoryNetwork},
+		{errors.New("unexpected response"), errs.CategoryInternal},
+	}
+	for _, pair := range pairs {
+		assert.Equal(t, pair.category, errs.Classify(pair.err), pair.err.Error())
+	}
+
+	assert.ErrorIs(t, fmt.Errorf("wrapped: %w", errRateLimited), errRateLimited)
+	assert.NoError(t, errs.Wrap(errs.CategoryDisk, nil))
+}
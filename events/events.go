@@ -0,0 +1,94 @@
+// Package events fans out job lifecycle and upload progress events to any number of subscribers,
+// e.g., HTTP API event stream clients.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const subscriberBufferSize = 64
+
+type Kind string
+
+const (
+	KindJobStarted     Kind = "job_started"
+	KindJobFinished    Kind = "job_finished"
+	KindLinkStarted    Kind = "link_started"
+	KindLinkFinished   Kind = "link_finished"
+	KindUploadProgress Kind = "upload_progress"
+	// KindUploadFloodWait is published each time an upload waits on a FLOOD_WAIT error before sending again.
+	KindUploadFloodWait Kind = "upload_flood_wait"
+)
+
+type Event struct {
+	Kind    Kind      `json:"kind"`
+	At      time.Time `json:"at"`
+	ChatID  int64     `json:"chat_id,omitempty"`
+	Links   []string  `json:"links,omitempty"`
+	Link    string    `json:"link,omitempty"`
+	Outcome string    `json:"outcome,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Peer    string    `json:"peer,omitempty"`
+	Percent int       `json:"percent,omitempty"`
+	// WaitSeconds is the time waited on a FLOOD_WAIT error, and TotalWaitSeconds is the cumulative time waited
+	// on FLOOD_WAIT errors during the upload so far.
+	WaitSeconds      int `json:"wait_seconds,omitempty"`
+	TotalWaitSeconds int `json:"total_wait_seconds,omitempty"`
+}
+
+// Bus delivers published events to all current subscribers. Publishing never blocks: events are dropped
+// for subscribers that do not keep up.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		mu:   sync.Mutex{},
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish sends e to all subscribers. At is set to the current time if it is zero. It is a no-op on a nil bus.
+func (b *Bus) Publish(e Event) {
+	if nil == b {
+		return
+	}
+
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of published events, and a function that unsubscribes and closes the channel.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
@@ -0,0 +1,41 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/events"
+)
+
+func TestBus(t *testing.T) {
+	t.Parallel()
+
+	bus := events.NewBus()
+
+	first, unsubscribeFirst := bus.Subscribe()
+	second, unsubscribeSecond := bus.Subscribe()
+	defer unsubscribeSecond()
+
+	bus.Publish(events.Event{Kind: events.KindLinkStarted, Link: "https://tidal.com/album/1"}) //nolint:exhaustruct
+
+	for _, ch := range []<-chan events.Event{first, second} {
+		e := <-ch
+		assert.Equal(t, events.KindLinkStarted, e.Kind)
+		assert.Equal(t, "https://tidal.com/album/1", e.Link)
+		assert.False(t, e.At.IsZero())
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	_, ok := <-first
+	require.False(t, ok)
+
+	bus.Publish(events.Event{Kind: events.KindJobFinished}) //nolint:exhaustruct
+	e := <-second
+	assert.Equal(t, events.KindJobFinished, e.Kind)
+
+	var nilBus *events.Bus
+	nilBus.Publish(events.Event{Kind: events.KindJobStarted}) //nolint:exhaustruct
+}
@@ -0,0 +1,212 @@
+// Package health serves the optional liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Check is a single health check.
+type Check struct {
+	Name string
+	// Live marks checks whose failures are not expected to go away without a restart, e.g., a revoked session,
+	// which fail the liveness probe too. Other checks only fail the readiness probe.
+	Live bool
+	Run  func(ctx context.Context) error
+}
+
+// Result is the result of the last run of a check.
+type Result struct {
+	OK        bool      `json:"ok"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type response struct {
+	OK     bool              `json:"ok"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Server runs the checks every configured interval, and answers the probes with their last results, so that
+// probes are cheap and do not hit Telegram or Tidal themselves.
+type Server struct {
+	logger zerolog.Logger
+	conf   config.Health
+	checks []Check
+
+	mu      sync.Mutex
+	results map[string]Result
+	// lastRunAt is when the last round of checks finished, or when the server was created if none has yet.
+	lastRunAt time.Time
+}
+
+func New(logger zerolog.Logger, conf config.Health, checks []Check) *Server {
+	return &Server{
+		logger:    logger,
+		conf:      conf,
+		checks:    checks,
+		mu:        sync.Mutex{},
+		results:   make(map[string]Result, len(checks)),
+		lastRunAt: time.Now(),
+	}
+}
+
+// Handler returns the HTTP handler of the probe endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { s.respond(w, true) })
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) { s.respond(w, false) })
+
+	return mux
+}
+
+// Run runs the checks every configured interval, and serves the probes on the configured listen address until
+// ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{ //nolint:exhaustruct
+		Addr:              s.conf.ListenAddr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	go s.runChecks(ctx)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("listen and serve: %v", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); nil != err {
+		return fmt.Errorf("shutdown server: %v", err)
+	}
+
+	if err := <-errs; nil != err && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("listen and serve: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Server) runChecks(ctx context.Context) {
+	ticker := time.NewTicker(s.conf.Interval.Duration)
+	defer ticker.Stop()
+
+	for {
+		s.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check runs all checks concurrently, each within the configured timeout, and records their results.
+func (s *Server) Check(ctx context.Context) {
+	var (
+		wg      sync.WaitGroup
+		results = make([]Result, len(s.checks))
+	)
+	for i, check := range s.checks {
+		wg.Go(func() {
+			checkCtx, cancel := context.WithTimeout(ctx, s.conf.Timeout.Duration)
+			defer cancel()
+
+			result := Result{OK: true, Error: "", CheckedAt: time.Time{}}
+			if err := check.Run(checkCtx); nil != err {
+				if errors.Is(err, context.Canceled) && nil != ctx.Err() {
+					return
+				}
+				s.logger.Warn().Err(err).Str("check", check.Name).Msg("Health check failed")
+				result = Result{OK: false, Error: err.Error(), CheckedAt: time.Time{}}
+			}
+			result.CheckedAt = time.Now()
+			results[i] = result
+		})
+	}
+	wg.Wait()
+
+	if nil != ctx.Err() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, check := range s.checks {
+		s.results[check.Name] = results[i]
+	}
+	s.lastRunAt = time.Now()
+}
+
+// respond answers a liveness probe if live is set, and a readiness probe otherwise. Liveness only fails on
+// failed live checks, or if the checks have not finished for a while, e.g., because one of them hangs. Readiness
+// also fails on any failed check, and before the first round of checks finishes.
+func (s *Server) respond(w http.ResponseWriter, live bool) {
+	s.mu.Lock()
+	resp := response{OK: true, Checks: make(map[string]Result, len(s.checks))}
+	for _, check := range s.checks {
+		result, ok := s.results[check.Name]
+		if !ok {
+			if !live {
+				resp.OK = false
+			}
+			continue
+		}
+
+		resp.Checks[check.Name] = result
+		if !result.OK && (check.Live || !live) {
+			resp.OK = false
+		}
+	}
+	lastRunAt := s.lastRunAt
+	s.mu.Unlock()
+
+	// A round of checks takes at most a timeout, and they are run every interval.
+	if maxAge := 3 * (s.conf.Interval.Duration + s.conf.Timeout.Duration); time.Since(lastRunAt) > maxAge {
+		resp.OK = false
+	}
+
+	status := http.StatusOK
+	if !resp.OK {
+		status = http.StatusServiceUnavailable
+	}
+
+	body, err := json.Marshal(resp)
+	if nil != err {
+		s.logger.Error().Err(err).Msg("Failed to encode health check response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); nil != err {
+		s.logger.Debug().Err(err).Msg("Failed to write health check response")
+	}
+}
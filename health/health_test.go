@@ -0,0 +1,90 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/health"
+)
+
+func TestProbes(t *testing.T) {
+	t.Parallel()
+
+	var tidalErr error
+	checks := []health.Check{
+		{Name: "telegram", Live: true, Run: func(context.Context) error { return nil }},
+		{Name: "tidal", Live: false, Run: func(context.Context) error { return tidalErr }},
+	}
+	conf := config.Health{
+		Enabled:        true,
+		ListenAddr:     "",
+		Interval:       config.Duration{Duration: time.Minute},
+		Timeout:        config.Duration{Duration: time.Second},
+		MaxJobDuration: config.Duration{Duration: 0},
+	}
+	s := health.New(zerolog.Nop(), conf, checks)
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	get := func(path string) (int, string) {
+		t.Helper()
+
+		resp, err := http.Get(srv.URL + path) //nolint:noctx
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp.StatusCode, string(body)
+	}
+
+	status, _ := get("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	s.Check(t.Context())
+	status, _ = get("/readyz")
+	assert.Equal(t, http.StatusOK, status)
+
+	tidalErr = errors.New("login required")
+	s.Check(t.Context())
+	status, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	status, body := get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, body, `"error":"login required"`)
+}
+
+func TestLiveCheckFailure(t *testing.T) {
+	t.Parallel()
+
+	checks := []health.Check{
+		{Name: "telegram", Live: true, Run: func(context.Context) error { return errors.New("unauthorized") }},
+	}
+	conf := config.Health{
+		Enabled:        true,
+		ListenAddr:     "",
+		Interval:       config.Duration{Duration: time.Minute},
+		Timeout:        config.Duration{Duration: time.Second},
+		MaxJobDuration: config.Duration{Duration: 0},
+	}
+	s := health.New(zerolog.Nop(), conf, checks)
+	s.Check(t.Context())
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+}
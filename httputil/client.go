@@ -0,0 +1,45 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client sends the requests of a service over a single pooled transport, so that connections and TLS sessions
+// are reused across requests. Unlike http.Client, the timeout is set per request.
+type Client struct {
+	client *http.Client
+}
+
+func NewClient(transport http.RoundTripper) *Client {
+	return &Client{client: &http.Client{Transport: transport}} //nolint:exhaustruct
+}
+
+// Do sends req, canceling it if it does not complete within timeout, including reading the response body.
+// The timeout is applied on top of the context of req.
+func (c *Client) Do(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+	resp, err := c.client.Do(req.WithContext(ctx)) //nolint:gosec
+	if nil != err {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// cancelOnCloseBody cancels the context of its request once it is closed, as canceling it earlier would abort
+// reading the body.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/goccy/go-json"
 )
@@ -60,3 +63,40 @@ func IsTooManyErrorResponse(resp *http.Response, respBody []byte) (bool, error)
 
 	return responseBody.Code == "AccessDenied" && responseBody.Message == "Access Denied", nil
 }
+
+// ErrorResponse is the payload of Tidal API error responses.
+type ErrorResponse struct {
+	Status      int    `json:"status"`
+	SubStatus   int    `json:"subStatus"`
+	UserMessage string `json:"userMessage"`
+}
+
+// ParseErrorResponse decodes the Tidal API error response payload b. It returns false if b is not one, e.g., it
+// is empty, or it is the response of a CDN.
+func ParseErrorResponse(b []byte) (ErrorResponse, bool) {
+	var body ErrorResponse
+	if err := json.Unmarshal(b, &body); nil != err || body.Status == 0 {
+		return ErrorResponse{Status: 0, SubStatus: 0, UserMessage: ""}, false
+	}
+
+	return body, true
+}
+
+// RetryAfter returns how long the Retry-After header of resp tells to wait at now before retrying the request,
+// which is either a number of seconds, or an HTTP date. It returns 0 if resp has no valid Retry-After header.
+func RetryAfter(resp *http.Response, now time.Time) time.Duration {
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if len(value) == 0 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); nil == err {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); nil == err {
+		return max(at.Sub(now), 0)
+	}
+
+	return 0
+}
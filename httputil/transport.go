@@ -0,0 +1,33 @@
+package httputil
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	maxIdleConns        = 100
+	maxIdleConnsPerHost = 32
+	idleConnTimeout     = 90 * time.Second
+)
+
+// NewTransport returns a transport that is meant to be shared by the clients of the same service, so that
+// connections are reused across requests. Requests are sent through proxy, unless it is nil.
+func NewTransport(proxy *url.URL) *http.Transport {
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		panic("default HTTP transport is not an *http.Transport")
+	}
+
+	t = t.Clone()
+	t.MaxIdleConns = maxIdleConns
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	t.IdleConnTimeout = idleConnTimeout
+	t.ForceAttemptHTTP2 = true
+	if nil != proxy {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	return t
+}
@@ -0,0 +1,106 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+type artifact struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// CleanArtifacts prunes job artifacts, i.e., debug bundles, oldest first, while any of them is older than the
+// configured artifacts max age, or all of them are larger than the configured artifacts max size.
+func (j *Janitor) CleanArtifacts(ctx context.Context) error {
+	conf := j.conf.Artifacts
+	if conf.MaxAge.Duration == 0 && conf.MaxSizeMB == 0 {
+		return nil
+	}
+
+	bundles, err := j.dir.DebugBundles()
+	if nil != err {
+		return fmt.Errorf("get debug bundles: %v", err)
+	}
+
+	var (
+		artifacts = make([]artifact, 0, len(bundles))
+		size      int64
+	)
+	for _, bundle := range bundles {
+		info, err := os.Lstat(bundle.Path)
+		if nil != err {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return fmt.Errorf("stat debug bundle: %v", err)
+		}
+
+		artifacts = append(artifacts, artifact{path: bundle.Path, size: info.Size(), modTime: info.ModTime()})
+		size += info.Size()
+	}
+	slices.SortFunc(artifacts, func(a, b artifact) int { return a.modTime.Compare(b.modTime) })
+
+	var (
+		now        = time.Now()
+		pruned     int
+		freedBytes int64
+	)
+	for _, a := range artifacts {
+		if err := ctx.Err(); nil != err {
+			return fmt.Errorf("clean job artifacts: %w", err)
+		}
+
+		reason := j.artifactPruneReason(now, a, size)
+		if reason == "" {
+			// Artifacts are sorted by modification time, hence the rest of them are not subject to pruning either.
+			break
+		}
+
+		if err := os.Remove(a.path); nil != err && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove job artifact: %v", err)
+		}
+
+		j.logger.
+			Info().
+			Str("path", a.path).
+			Time("modified_at", a.modTime).
+			Str("reason", reason).
+			Int64("freed_bytes", a.size).
+			Msg("Pruned job artifact")
+
+		size -= a.size
+		freedBytes += a.size
+		pruned++
+	}
+
+	if pruned > 0 {
+		j.logger.Info().Int("artifacts", pruned).Int64("freed_bytes", freedBytes).Msg("Job artifacts cleaned")
+
+		j.mu.Lock()
+		j.stats.PrunedArtifacts += pruned
+		j.stats.ArtifactsFreedBytes += freedBytes
+		j.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (j *Janitor) artifactPruneReason(now time.Time, a artifact, size int64) string {
+	conf := j.conf.Artifacts
+	if conf.MaxAge.Duration > 0 && now.Sub(a.modTime) > conf.MaxAge.Duration {
+		return "max_age"
+	}
+
+	if conf.MaxSizeMB > 0 && size > conf.MaxSizeMB*mb {
+		return "max_size"
+	}
+
+	return ""
+}
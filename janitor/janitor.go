@@ -0,0 +1,343 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/storage"
+	tidalfs "github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const mb = 1024 * 1024
+
+var ErrLowDiskSpace = errors.New("low disk space")
+
+type JobLocker interface {
+	TryAcquireJob(ctx context.Context) (context.Context, bool)
+	ReleaseJob()
+}
+
+// Janitor prunes files of already uploaded links from the downloads directory, least recently uploaded first,
+// to keep the downloads directory within the configured age, size, and free space limits. It also prunes job
+// artifacts, oldest first, within their own age and size limits. If a mirror storage backend is configured,
+// uploaded links are mirrored to it before they are pruned.
+type Janitor struct {
+	logger zerolog.Logger
+	conf   config.BotJanitor
+	dir    tidalfs.DownloadsDir
+	store  *audit.Store
+	mirror storage.Backend
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats is what the janitor reclaimed since startup.
+type Stats struct {
+	// LastRunAt is when the last cleanup round finished. It is zero if no round has run yet.
+	LastRunAt           time.Time
+	PrunedLinks         int
+	DownloadsFreedBytes int64
+	PrunedArtifacts     int
+	ArtifactsFreedBytes int64
+	// MirroredLinks and ExpiredMirroredLinks are the number of links mirrored to the storage backend, and removed
+	// from it after the retention period, respectively.
+	MirroredLinks        int
+	ExpiredMirroredLinks int
+}
+
+func New(logger zerolog.Logger, conf config.BotJanitor, dir tidalfs.DownloadsDir, store *audit.Store, mirror storage.Backend) *Janitor {
+	return &Janitor{
+		logger: logger,
+		conf:   conf,
+		dir:    dir,
+		store:  store,
+		mirror: mirror,
+		mu:     sync.Mutex{},
+		stats:  Stats{}, //nolint:exhaustruct
+	}
+}
+
+// Stats returns what the janitor reclaimed since startup.
+func (j *Janitor) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.stats
+}
+
+// CheckFreeSpace returns ErrLowDiskSpace if the free space of the downloads directory file system
+// is below the configured minimum.
+func (j *Janitor) CheckFreeSpace() error {
+	if j.conf.MinFreeSpaceMB == 0 {
+		return nil
+	}
+
+	free, err := freeSpace(string(j.dir))
+	if nil != err {
+		return fmt.Errorf("get downloads directory free space: %v", err)
+	}
+
+	if free < uint64(j.conf.MinFreeSpaceMB)*mb {
+		return ErrLowDiskSpace
+	}
+
+	return nil
+}
+
+// Run cleans the downloads directory and job artifacts every configured interval until ctx is done. Rounds
+// are skipped while a job is running, as its files must not be touched.
+func (j *Janitor) Run(ctx context.Context, locker JobLocker) {
+	ticker := time.NewTicker(j.conf.Interval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			jobCtx, ok := locker.TryAcquireJob(ctx)
+			if !ok {
+				j.logger.Debug().Msg("Another job is in progress. Skipping downloads directory cleanup round")
+				continue
+			}
+
+			if err := j.Mirror(jobCtx); nil != err {
+				j.logger.Error().Err(err).Msg("Failed to mirror uploaded links")
+			}
+			if err := j.Clean(jobCtx); nil != err {
+				j.logger.Error().Err(err).Msg("Failed to clean downloads directory")
+			}
+			if err := j.CleanArtifacts(jobCtx); nil != err {
+				j.logger.Error().Err(err).Msg("Failed to clean job artifacts")
+			}
+			locker.ReleaseJob()
+
+			j.mu.Lock()
+			j.stats.LastRunAt = time.Now()
+			j.mu.Unlock()
+		}
+	}
+}
+
+// Clean prunes uploaded links, least recently uploaded first, while any of them is older than the configured
+// max age, the downloads directory is larger than the configured max size, or the free space is below the
+// configured minimum. Links that are not mirrored yet are kept if a mirror storage backend is configured. Files
+// of pruned links that other links have too, e.g., tracks in several playlists, are kept until they are pruned.
+func (j *Janitor) Clean(ctx context.Context) error {
+	uploads, err := j.store.Uploads()
+	if nil != err {
+		return fmt.Errorf("get uploaded links: %v", err)
+	}
+
+	var mirrorState *types.StoredMirror
+	if nil != j.mirror {
+		if mirrorState, err = j.dir.Mirror().Read(); nil != err {
+			return fmt.Errorf("read mirror state: %v", err)
+		}
+	}
+
+	refs, err := j.fileRefs()
+	if nil != err {
+		return fmt.Errorf("get link files references: %v", err)
+	}
+
+	var size int64
+	if j.conf.MaxSizeMB > 0 {
+		if size, err = dirSize(string(j.dir)); nil != err {
+			return fmt.Errorf("get downloads directory size: %v", err)
+		}
+	}
+
+	var (
+		now         = time.Now()
+		prunedLinks int
+		freedBytes  int64
+	)
+	for _, upload := range uploads {
+		if err := ctx.Err(); nil != err {
+			return fmt.Errorf("clean downloads directory: %w", err)
+		}
+
+		reason := j.pruneReason(now, upload, size)
+		if reason == "" {
+			// Uploads are sorted by upload time, hence the rest of them are not subject to pruning either.
+			break
+		}
+
+		if nil != mirrorState && !j.mirrorExpired(now, upload) && mirrorPending(mirrorState, upload) {
+			continue
+		}
+
+		kind, ok := types.ParseLinkKind(upload.LinkKind)
+		if !ok {
+			continue
+		}
+		link := types.Link{Kind: kind, ID: upload.LinkID}
+
+		freed, err := j.prune(link, refs)
+		if nil != err {
+			return fmt.Errorf("prune %s %s: %v", upload.LinkKind, upload.LinkID, err)
+		}
+		if freed == 0 {
+			continue
+		}
+
+		j.logger.
+			Info().
+			Str("link_kind", upload.LinkKind).
+			Str("link_id", upload.LinkID).
+			Time("uploaded_at", upload.At).
+			Str("reason", reason).
+			Int64("freed_bytes", freed).
+			Msg("Pruned uploaded link files")
+
+		size -= freed
+		freedBytes += freed
+		prunedLinks++
+	}
+
+	if prunedLinks > 0 {
+		j.logger.Info().Int("links", prunedLinks).Int64("freed_bytes", freedBytes).Msg("Downloads directory cleaned")
+
+		j.mu.Lock()
+		j.stats.PrunedLinks += prunedLinks
+		j.stats.DownloadsFreedBytes += freedBytes
+		j.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (j *Janitor) pruneReason(now time.Time, upload audit.Upload, size int64) string {
+	if j.conf.MaxAge.Duration > 0 && now.Sub(upload.At) > j.conf.MaxAge.Duration {
+		return "max_age"
+	}
+
+	if j.conf.MaxSizeMB > 0 && size > j.conf.MaxSizeMB*mb {
+		return "max_size"
+	}
+
+	if errors.Is(j.CheckFreeSpace(), ErrLowDiskSpace) {
+		return "min_free_space"
+	}
+
+	return ""
+}
+
+// fileRefs returns the number of links with downloaded files in the downloads directory that each of the files
+// belongs to. Track files are shared by every link that has the track, as they are stored once.
+func (j *Janitor) fileRefs() (map[string]int, error) {
+	entries, err := j.store.All()
+	if nil != err {
+		return nil, fmt.Errorf("get jobs: %v", err)
+	}
+
+	var (
+		refs  = make(map[string]int)
+		links = make(map[types.Link]struct{}, len(entries))
+	)
+	for _, e := range entries {
+		kind, ok := types.ParseLinkKind(e.LinkKind)
+		if !ok {
+			continue
+		}
+
+		link := types.Link{Kind: kind, ID: e.LinkID}
+		if _, ok := links[link]; ok {
+			continue
+		}
+		links[link] = struct{}{}
+
+		files, err := j.dir.LinkFiles(link)
+		if nil != err {
+			return nil, fmt.Errorf("get %s %s files: %v", e.LinkKind, e.LinkID, err)
+		}
+		for _, f := range lo.Uniq(files) {
+			refs[f]++
+		}
+	}
+
+	return refs, nil
+}
+
+// prune removes the files of link that no other link with files in the downloads directory has, according to
+// refs, which is updated to not count link anymore.
+func (j *Janitor) prune(link types.Link, refs map[string]int) (int64, error) {
+	files, err := j.dir.LinkFiles(link)
+	if nil != err {
+		return 0, fmt.Errorf("get link files: %v", err)
+	}
+
+	var freed int64
+	for _, f := range lo.Uniq(files) {
+		if refs[f]--; refs[f] > 0 {
+			continue
+		}
+
+		info, err := os.Lstat(f)
+		if nil != err {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return freed, fmt.Errorf("stat file: %v", err)
+		}
+
+		if err := os.Remove(f); nil != err && !errors.Is(err, os.ErrNotExist) {
+			return freed, fmt.Errorf("remove file: %v", err)
+		}
+		freed += info.Size()
+	}
+
+	return freed, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if nil != err {
+			return err
+		}
+
+		// Job artifacts have their own retention limits.
+		if !d.Type().IsRegular() || tidalfs.IsDebugBundle(path) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if nil != err {
+			return fmt.Errorf("get file info: %v", err)
+		}
+		size += info.Size()
+
+		return nil
+	})
+	if nil != err {
+		return 0, fmt.Errorf("walk directory: %v", err)
+	}
+
+	return size, nil
+}
+
+func freeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); nil != err {
+		return 0, fmt.Errorf("statfs: %v", err)
+	}
+
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,55 @@
+package janitor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/janitor"
+	tidalfs "github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+func TestCleanKeepsSharedTrackFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := tidalfs.DownloadsDirFrom(t.TempDir())
+	store, err := audit.Open(filepath.Join(t.TempDir(), "audit.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	writePlaylist := func(id string, trackIDs []string, uploadedAt time.Time) {
+		t.Helper()
+
+		playlist := dir.Playlist(id)
+		require.NoError(t, playlist.InfoFile.Write(types.StoredPlaylist{Caption: id, TrackIDs: trackIDs})) //nolint:exhaustruct
+		for _, trackID := range trackIDs {
+			require.NoError(t, os.WriteFile(playlist.Track(trackID).Path, []byte("audio"), 0o600))
+		}
+
+		entry := audit.NewEntry(types.Link{Kind: types.LinkKindPlaylist, ID: id}, 1, 1)
+		entry.Outcome = audit.OutcomeSucceeded
+		entry.StartedAt = uploadedAt
+		require.NoError(t, store.Record(entry))
+	}
+	writePlaylist("old", []string{"shared", "only-old"}, time.Now().Add(-48*time.Hour))
+	writePlaylist("new", []string{"shared", "only-new"}, time.Now())
+
+	conf := config.BotJanitor{MaxAge: config.Duration{Duration: 24 * time.Hour}} //nolint:exhaustruct
+	j := janitor.New(zerolog.Nop(), conf, dir, store, nil)
+	require.NoError(t, j.Clean(t.Context()))
+
+	assert.NoFileExists(t, dir.Playlist("old").InfoFile.Path)
+	assert.NoFileExists(t, dir.Playlist("old").Track("only-old").Path)
+	assert.FileExists(t, dir.Playlist("new").InfoFile.Path)
+	assert.FileExists(t, dir.Playlist("new").Track("only-new").Path)
+	assert.FileExists(t, dir.Playlist("new").Track("shared").Path)
+	assert.Equal(t, 1, j.Stats().PrunedLinks)
+}
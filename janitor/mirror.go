@@ -0,0 +1,162 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/xeptore/tidalgram/audit"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Mirror mirrors the tracks of uploaded links that were not mirrored since they were last uploaded to the
+// storage backend, and removes the mirrored tracks of links uploaded longer than the configured retention ago.
+// Links whose files were already pruned are not mirrored.
+func (j *Janitor) Mirror(ctx context.Context) error {
+	if nil == j.mirror {
+		return nil
+	}
+
+	uploads, err := j.store.Uploads()
+	if nil != err {
+		return fmt.Errorf("get uploaded links: %v", err)
+	}
+
+	mirror := j.dir.Mirror()
+	state, err := mirror.Read()
+	if nil != err {
+		return fmt.Errorf("read mirror state: %v", err)
+	}
+
+	var (
+		now           = time.Now()
+		mirroredLinks int
+		expiredLinks  int
+	)
+	for _, upload := range uploads {
+		if err := ctx.Err(); nil != err {
+			return fmt.Errorf("mirror uploaded links: %w", err)
+		}
+
+		if j.mirrorExpired(now, upload) {
+			expired, err := j.expireMirror(ctx, state, upload)
+			if nil != err {
+				return fmt.Errorf("expire mirrored %s %s: %v", upload.LinkKind, upload.LinkID, err)
+			}
+			if !expired {
+				continue
+			}
+
+			if err := mirror.InfoFile.Write(*state); nil != err {
+				return fmt.Errorf("write mirror state: %v", err)
+			}
+			j.logger.Info().Str("link_kind", upload.LinkKind).Str("link_id", upload.LinkID).Msg("Removed expired mirrored link tracks")
+			expiredLinks++
+
+			continue
+		}
+
+		if !mirrorPending(state, upload) {
+			continue
+		}
+
+		kind, ok := types.ParseLinkKind(upload.LinkKind)
+		if !ok {
+			continue
+		}
+		link := types.Link{Kind: kind, ID: upload.LinkID}
+
+		if files, err := j.dir.LinkFiles(link); nil != err {
+			return fmt.Errorf("get %s %s files: %v", upload.LinkKind, upload.LinkID, err)
+		} else if len(files) == 0 {
+			continue
+		}
+
+		tracks, err := j.dir.NamedTracks(link)
+		if nil != err {
+			return fmt.Errorf("get %s %s tracks: %v", upload.LinkKind, upload.LinkID, err)
+		}
+
+		keys := make([]string, 0, len(tracks))
+		for _, track := range tracks {
+			key := path.Join(j.conf.Mirror.Prefix, filepath.ToSlash(track.RelPath))
+			if err := j.mirror.Put(ctx, key, track.Path); nil != err {
+				return fmt.Errorf("mirror %s %s track %s: %w", upload.LinkKind, upload.LinkID, track.ID, err)
+			}
+			keys = append(keys, key)
+		}
+
+		state.Links[mirrorID(upload)] = types.MirroredLink{MirroredAt: now, Keys: keys, Expired: false}
+		if err := mirror.InfoFile.Write(*state); nil != err {
+			return fmt.Errorf("write mirror state: %v", err)
+		}
+
+		j.logger.
+			Info().
+			Str("link_kind", upload.LinkKind).
+			Str("link_id", upload.LinkID).
+			Int("tracks", len(keys)).
+			Msg("Mirrored uploaded link tracks")
+		mirroredLinks++
+	}
+
+	if mirroredLinks > 0 || expiredLinks > 0 {
+		j.mu.Lock()
+		j.stats.MirroredLinks += mirroredLinks
+		j.stats.ExpiredMirroredLinks += expiredLinks
+		j.mu.Unlock()
+	}
+
+	return nil
+}
+
+// expireMirror removes the mirrored tracks of upload from the storage backend, except for those also mirrored
+// for other links that are not expired yet, and reports whether there were any.
+func (j *Janitor) expireMirror(ctx context.Context, state *types.StoredMirror, upload audit.Upload) (bool, error) {
+	id := mirrorID(upload)
+	record, ok := state.Links[id]
+	if !ok || record.Expired {
+		return false, nil
+	}
+
+	shared := make(map[string]struct{})
+	for otherID, other := range state.Links {
+		if otherID == id {
+			continue
+		}
+		for _, key := range other.Keys {
+			shared[key] = struct{}{}
+		}
+	}
+
+	for _, key := range record.Keys {
+		if _, ok := shared[key]; ok {
+			continue
+		}
+		if err := j.mirror.Delete(ctx, key); nil != err {
+			return false, fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+
+	state.Links[id] = types.MirroredLink{MirroredAt: record.MirroredAt, Keys: nil, Expired: true}
+
+	return true, nil
+}
+
+// mirrorExpired reports whether the mirrored tracks of upload are past the configured retention.
+func (j *Janitor) mirrorExpired(now time.Time, upload audit.Upload) bool {
+	return j.conf.Mirror.Retention.Duration > 0 && now.Sub(upload.At) > j.conf.Mirror.Retention.Duration
+}
+
+// mirrorPending reports whether upload was not mirrored since it was last uploaded.
+func mirrorPending(state *types.StoredMirror, upload audit.Upload) bool {
+	record, ok := state.Links[mirrorID(upload)]
+
+	return !ok || record.MirroredAt.Before(upload.At)
+}
+
+func mirrorID(upload audit.Upload) string {
+	return upload.LinkKind + ":" + upload.LinkID
+}
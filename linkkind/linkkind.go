@@ -0,0 +1,80 @@
+// Package linkkind lets third parties add custom link kinds, e.g., internal catalog IDs, with their own
+// downloaders. Custom link kinds are registered using [Register] from the init function of a package that is
+// blank imported in the main package. Links of custom kinds are recognized in bot messages, downloaded using
+// their kind, and then captioned, and uploaded, like playlists.
+package linkkind
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Kind is a custom link kind.
+type Kind interface {
+	// Name is the unique name of the kind, e.g., catalog. It is shown in job messages, and the audit log.
+	Name() string
+	// ParseURL returns the ID of the link at u, and whether u is a link of the kind.
+	ParseURL(u *url.URL) (id string, ok bool)
+	// URL returns the URL of the link with id.
+	URL(id string) string
+	// Download downloads the tracks of the link with id to playlist, in the layout of downloaded playlists: the
+	// playlist info file lists the caption and the IDs of the tracks, and every track has its track file, info
+	// file, and cover at the paths returned by [fs.Playlist.Track].
+	Download(ctx context.Context, logger zerolog.Logger, playlist fs.Playlist, id string) error
+}
+
+var (
+	mu    sync.RWMutex
+	kinds = make(map[types.LinkKind]Kind)
+	// order holds the registered link kinds in registration order, which is the order URLs are matched in.
+	order []types.LinkKind
+)
+
+// Register registers k, and returns its link kind. It panics if a link kind with the same name is already
+// registered.
+func Register(k Kind) types.LinkKind {
+	mu.Lock()
+	defer mu.Unlock()
+
+	kind := types.RegisterLinkKind(k.Name(), k.URL)
+	kinds[kind] = k
+	order = append(order, kind)
+
+	return kind
+}
+
+// Lookup returns the custom link kind registered as kind.
+func Lookup(kind types.LinkKind) (Kind, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	k, ok := kinds[kind]
+
+	return k, ok
+}
+
+// ParseURL returns the link at rawURL, and whether it is a link of any of the registered custom link kinds. Kinds
+// are tried in registration order.
+func ParseURL(rawURL string) (types.Link, bool) {
+	u, err := url.Parse(rawURL)
+	if nil != err {
+		return types.Link{}, false //nolint:exhaustruct
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, kind := range order {
+		if id, ok := kinds[kind].ParseURL(u); ok && len(id) > 0 {
+			return types.Link{Kind: kind, ID: id}, true
+		}
+	}
+
+	return types.Link{}, false //nolint:exhaustruct
+}
@@ -0,0 +1,62 @@
+package linkkind_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/linkkind"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+type catalog struct{}
+
+func (catalog) Name() string { return "catalog" }
+
+func (catalog) ParseURL(u *url.URL) (string, bool) {
+	if u.Host != "catalog.example.com" {
+		return "", false
+	}
+
+	return strings.TrimPrefix(u.Path, "/"), true
+}
+
+func (catalog) URL(id string) string { return "https://catalog.example.com/" + id }
+
+func (catalog) Download(context.Context, zerolog.Logger, fs.Playlist, string) error { return nil }
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	kind := linkkind.Register(catalog{})
+	require.True(t, kind.Custom())
+	require.Equal(t, "catalog", kind.String())
+
+	parsed, ok := types.ParseLinkKind("catalog")
+	require.True(t, ok)
+	require.Equal(t, kind, parsed)
+
+	k, ok := linkkind.Lookup(kind)
+	require.True(t, ok)
+	require.Equal(t, "catalog", k.Name())
+
+	link, ok := linkkind.ParseURL("https://catalog.example.com/A-1")
+	require.True(t, ok)
+	require.Equal(t, types.Link{Kind: kind, ID: "A-1"}, link)
+	require.Equal(t, "https://catalog.example.com/A-1", link.URL())
+	require.Equal(t, types.Link{Kind: types.LinkKindPlaylist, ID: "catalog-A-1"}, link.StoredAs())
+
+	_, ok = linkkind.ParseURL("https://tidal.com/album/1")
+	require.False(t, ok)
+
+	_, ok = linkkind.Lookup(types.LinkKindAlbum)
+	require.False(t, ok)
+	require.False(t, types.LinkKindAlbum.Custom())
+
+	require.Panics(t, func() { linkkind.Register(catalog{}) })
+}
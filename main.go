@@ -1,24 +1,40 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 
 	"github.com/joho/godotenv"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
 	"github.com/urfave/cli/v3"
 
+	"github.com/xeptore/tidalgram/api"
+	"github.com/xeptore/tidalgram/audit"
 	"github.com/xeptore/tidalgram/bot"
 	"github.com/xeptore/tidalgram/config"
 	"github.com/xeptore/tidalgram/constant"
+	"github.com/xeptore/tidalgram/events"
+	"github.com/xeptore/tidalgram/health"
+	"github.com/xeptore/tidalgram/janitor"
 	"github.com/xeptore/tidalgram/log"
+	"github.com/xeptore/tidalgram/pause"
+	"github.com/xeptore/tidalgram/secret"
+	"github.com/xeptore/tidalgram/storage"
 	"github.com/xeptore/tidalgram/telegram"
 	"github.com/xeptore/tidalgram/tidal"
+	"github.com/xeptore/tidalgram/tidal/fs"
 )
 
 func main() {
@@ -36,6 +52,8 @@ func main() {
 		EnableShellCompletion:      true,
 		ShellCompletionCommandName: "shell-completion",
 		AllowExtFlags:              false,
+		// Config overrides may contain commas, e.g., in list values.
+		DisableSliceFlagSeparator: true,
 		Flags: []cli.Flag{
 			//nolint:exhaustruct
 			&cli.StringFlag{
@@ -43,6 +61,14 @@ func main() {
 				Usage:    "Config file path",
 				Required: false,
 			},
+			//nolint:exhaustruct
+			&cli.StringSliceFlag{
+				Name: "set",
+				Usage: "Overrides a config field, e.g., --set telegram.upload.limit=4. Can be repeated. " +
+					"Fields can also be overridden using " + config.EnvOverridePrefix + " environment variables, " +
+					"e.g., " + config.EnvOverridePrefix + "TELEGRAM__UPLOAD__LIMIT=4, which --set takes precedence over",
+				Required: false,
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -51,8 +77,37 @@ func main() {
 				Commands: []*cli.Command{
 					//nolint:exhaustruct
 					{
-						Name:   "login",
-						Usage:  "Login to Telegram",
+						Name:  "login",
+						Usage: "Login to Telegram",
+						Description: strings.Join(
+							[]string{
+								"Logs in by scanning a QR code using a logged in Telegram app, or using the code sent to",
+								"the account of a phone number, asking for the 2FA password if the account has one.",
+								"Use --via-bot on headless servers without a TTY to be asked in the chat with the bot instead.",
+							},
+							"\n",
+						),
+						Flags: []cli.Flag{
+							//nolint:exhaustruct
+							&cli.StringFlag{
+								Name:  "method",
+								Usage: "Login method: qr, or phone",
+								Value: string(telegram.LoginMethodQR),
+								Validator: func(method string) error {
+									methods := []string{string(telegram.LoginMethodQR), string(telegram.LoginMethodPhone)}
+									if !slices.Contains(methods, method) {
+										return fmt.Errorf("method must be one of: qr, phone, got: %s", method)
+									}
+
+									return nil
+								},
+							},
+							//nolint:exhaustruct
+							&cli.BoolFlag{
+								Name:  "via-bot",
+								Usage: "Ask for the login details in the chat of papa with the bot instead of the terminal",
+							},
+						},
 						Action: telegramLogin,
 					},
 					{
@@ -60,6 +115,119 @@ func main() {
 						Usage:  "Logout from Telegram",
 						Action: telegramLogout,
 					},
+					{
+						Name:  "peers",
+						Usage: "List the chats of the logged in user with their IDs and kinds",
+						Description: strings.Join(
+							[]string{
+								"Lists the users, chats, and channels the logged in user has dialogs with,",
+								"with the id and kind to set for them in telegram.upload.peers config.",
+							},
+							"\n",
+						),
+						Flags: []cli.Flag{
+							//nolint:exhaustruct
+							&cli.StringFlag{
+								Name:  "kind",
+								Usage: "Only list peers of this kind: user, chat, or channel",
+								Validator: func(kind string) error {
+									if !slices.Contains([]string{"user", "chat", "channel"}, kind) {
+										return fmt.Errorf("kind must be one of: user, chat, channel, got: %s", kind)
+									}
+
+									return nil
+								},
+							},
+							//nolint:exhaustruct
+							&cli.StringFlag{
+								Name:  "query",
+								Usage: "Only list peers whose title or username contains this text",
+							},
+							//nolint:exhaustruct
+							&cli.IntFlag{
+								Name:  "page",
+								Usage: "Page of peers to list",
+								Value: 1,
+							},
+							//nolint:exhaustruct
+							&cli.IntFlag{
+								Name:  "page-size",
+								Usage: "Number of peers per page",
+								Value: 50,
+							},
+						},
+						Action: telegramPeers,
+					},
+					{
+						Name:  "sessions",
+						Usage: "Manage the stored sessions of Telegram accounts",
+						Description: strings.Join(
+							[]string{
+								"Each account is logged in to as a named session, set by telegram.storage.session config,",
+								"so that the upload account can be switched by changing it without logging in again.",
+							},
+							"\n",
+						),
+						Commands: []*cli.Command{
+							{
+								Name:   "list",
+								Usage:  "List the stored sessions, marking the configured one",
+								Action: telegramSessionsList,
+							},
+							//nolint:exhaustruct
+							{
+								Name:  "export",
+								Usage: "Export a session to a file, e.g., to import it into another instance",
+								Description: strings.Join(
+									[]string{
+										"The session is exported decrypted. Anyone having the exported file has full access",
+										"to the account, so keep it safe, and remove it once it is imported.",
+									},
+									"\n",
+								),
+								Flags: []cli.Flag{
+									//nolint:exhaustruct
+									&cli.StringFlag{
+										Name:      "name",
+										Usage:     "Name of the session to export. Defaults to the configured session",
+										Validator: config.ValidateTelegramSession,
+									},
+									//nolint:exhaustruct
+									&cli.StringFlag{
+										Name:     "output",
+										Usage:    "Path of the file to export the session to, which must not exist, or - for stdout",
+										Required: true,
+									},
+								},
+								Action: telegramSessionsExport,
+							},
+							//nolint:exhaustruct
+							{
+								Name:  "import",
+								Usage: "Import a session exported by the export command",
+								Flags: []cli.Flag{
+									//nolint:exhaustruct
+									&cli.StringFlag{
+										Name:      "name",
+										Usage:     "Name to store the session with. Defaults to the configured session",
+										Validator: config.ValidateTelegramSession,
+									},
+									//nolint:exhaustruct
+									&cli.StringFlag{
+										Name:     "input",
+										Usage:    "Path of the exported session file, or - for stdin",
+										Required: true,
+									},
+									//nolint:exhaustruct
+									&cli.BoolFlag{
+										Name:  "overwrite",
+										Usage: "Replace the stored session with the same name, if any, along with its stored uploads",
+									},
+								},
+								Action: telegramSessionsImport,
+							},
+						},
+					},
 				},
 			},
 			{
@@ -140,7 +308,7 @@ func telegramLogin(ctx context.Context, cmd *cli.Command) error {
 		logger.Debug().Msg(".env file was loaded")
 	}
 
-	conf, err := config.Load(cmd.String("config"))
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
 	if nil != err {
 		return fmt.Errorf("load config: %v", err)
 	}
@@ -149,13 +317,55 @@ func telegramLogin(ctx context.Context, cmd *cli.Command) error {
 
 	logger.Debug().Dict("config", conf.ToDict()).Msg("Config loaded")
 
-	if err := telegram.Login(ctx, logger, conf.Telegram); nil != err {
-		if errors.Is(err, syscall.ENOTTY) {
-			logger.Error().Msg("No TTY detected. Please run the container with `--tty` or set `tty: true` in Docker Compose.")
+	box, err := encryptionBox(conf.Encryption)
+	if nil != err {
+		return fmt.Errorf("create encryption box: %v", err)
+	}
+
+	method := telegram.LoginMethod(cmd.String("method"))
+
+	if !cmd.Bool("via-bot") {
+		if !isatty.IsTerminal(os.Stdout.Fd()) {
+			logger.
+				Error().
+				Msg("No TTY detected. Please run the container with `--tty` or set `tty: true` in Docker Compose, or login with `--via-bot`.")
 			return exitCodeError(1)
 		}
 
-		return fmt.Errorf("login to telegram: %w", err)
+		prompter := telegram.NewTerminalPrompter(os.Stdin, os.Stdout)
+		if err := telegram.Login(ctx, logger, conf.Telegram, box, method, prompter); nil != err {
+			return fmt.Errorf("login to telegram: %w", err)
+		}
+
+		return nil
+	}
+
+	if conf.Bot.Frontend != config.BotFrontendBotAPI {
+		logger.Error().Str("frontend", conf.Bot.Frontend).Msg("Logging in with `--via-bot` requires the bot_api frontend.")
+		return exitCodeError(1)
+	}
+
+	b, err := bot.New(ctx, logger, conf.Bot)
+	if nil != err {
+		return fmt.Errorf("create tidalgram bot: %w", err)
+	}
+
+	prompter, err := b.LoginPrompter(ctx)
+	if nil != err {
+		return fmt.Errorf("create bot login prompter: %w", err)
+	}
+	logger.Info().Msg("Asking for the login details in the chat with the bot")
+
+	loginErr := telegram.Login(ctx, logger, conf.Telegram, box, method, prompter)
+	if errors.Is(loginErr, bot.ErrLoginCancelled) {
+		logger.Warn().Msg("Login was cancelled in the chat with the bot")
+		return exitCodeError(1)
+	}
+	if err := prompter.Done(ctx, loginErr); nil != err {
+		logger.Error().Err(err).Msg("Failed to tell papa the login result")
+	}
+	if nil != loginErr {
+		return fmt.Errorf("login to telegram: %w", loginErr)
 	}
 
 	return nil
@@ -176,7 +386,7 @@ func telegramLogout(ctx context.Context, cmd *cli.Command) error {
 		logger.Debug().Msg(".env file was loaded")
 	}
 
-	conf, err := config.Load(cmd.String("config"))
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
 	if nil != err {
 		return fmt.Errorf("load config: %v", err)
 	}
@@ -185,7 +395,12 @@ func telegramLogout(ctx context.Context, cmd *cli.Command) error {
 
 	logger.Debug().Dict("config", conf.ToDict()).Msg("Config loaded")
 
-	if err := telegram.Logout(ctx, logger, conf.Telegram); nil != err {
+	box, err := encryptionBox(conf.Encryption)
+	if nil != err {
+		return fmt.Errorf("create encryption box: %v", err)
+	}
+
+	if err := telegram.Logout(ctx, logger, conf.Telegram, box); nil != err {
 		if errors.Is(err, syscall.ENOTTY) {
 			logger.Error().Msg("No TTY detected. Please run the container with `--tty` or set `tty: true` in Docker Compose.")
 			return exitCodeError(1)
@@ -199,10 +414,245 @@ func telegramLogout(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+func telegramPeers(ctx context.Context, cmd *cli.Command) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger := log.NewDefault()
+
+	if err := godotenv.Load(); nil != err {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("load .env file: %v", err)
+		}
+		logger.Info().Msg(".env file was not found")
+	} else {
+		logger.Debug().Msg(".env file was loaded")
+	}
+
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
+	if nil != err {
+		return fmt.Errorf("load config: %v", err)
+	}
+
+	logger = log.FromConfig(conf.Log)
+
+	logger.Debug().Dict("config", conf.ToDict()).Msg("Config loaded")
+
+	page, pageSize := cmd.Int("page"), cmd.Int("page-size")
+	if page < 1 || pageSize < 1 {
+		return errors.New("page and page-size must be at least 1")
+	}
+
+	filter := telegram.PeersFilter{Kind: cmd.String("kind"), Query: cmd.String("query")}
+	box, err := encryptionBox(conf.Encryption)
+	if nil != err {
+		return fmt.Errorf("create encryption box: %v", err)
+	}
+
+	peers, err := telegram.Peers(ctx, logger, conf.Telegram, box, filter)
+	if nil != err {
+		if errors.Is(err, telegram.ErrNotLoggedIn) {
+			logger.Error().Msg("Telegram client is not logged in. Please login to Telegram.")
+			return exitCodeError(2)
+		}
+
+		return fmt.Errorf("list telegram peers: %w", err)
+	}
+
+	pages := max(1, (len(peers)+pageSize-1)/pageSize)
+	start, end := min(len(peers), (page-1)*pageSize), min(len(peers), page*pageSize)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tTITLE\tUSERNAME")
+	for _, p := range peers[start:end] {
+		username := ""
+		if len(p.Username) > 0 {
+			username = "@" + p.Username
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", p.ID, p.Kind, p.Title, username)
+	}
+	if err := w.Flush(); nil != err {
+		return fmt.Errorf("write peers: %v", err)
+	}
+	fmt.Printf("\nPage %d of %d (%d peers)\n", page, pages, len(peers))
+
+	return nil
+}
+
+func telegramSessionsList(_ context.Context, cmd *cli.Command) error {
+	logger := log.NewDefault()
+
+	if err := godotenv.Load(); nil != err {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("load .env file: %v", err)
+		}
+		logger.Info().Msg(".env file was not found")
+	} else {
+		logger.Debug().Msg(".env file was loaded")
+	}
+
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
+	if nil != err {
+		return fmt.Errorf("load config: %v", err)
+	}
+
+	logger = log.FromConfig(conf.Log)
+
+	logger.Debug().Dict("config", conf.ToDict()).Msg("Config loaded")
+
+	box, err := encryptionBox(conf.Encryption)
+	if nil != err {
+		return fmt.Errorf("create encryption box: %v", err)
+	}
+
+	names, err := telegram.Sessions(conf.Telegram, box)
+	if nil != err {
+		return fmt.Errorf("list telegram sessions: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tACTIVE")
+	for _, name := range names {
+		active := ""
+		if name == conf.Telegram.Storage.Session {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, active)
+	}
+	if err := w.Flush(); nil != err {
+		return fmt.Errorf("write sessions: %v", err)
+	}
+	if !slices.Contains(names, conf.Telegram.Storage.Session) {
+		fmt.Printf("\nConfigured session %s is not logged in.\n", conf.Telegram.Storage.Session)
+	}
+
+	return nil
+}
+
+func telegramSessionsExport(ctx context.Context, cmd *cli.Command) (err error) {
+	logger := log.NewDefault()
+
+	if err := godotenv.Load(); nil != err {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("load .env file: %v", err)
+		}
+		logger.Info().Msg(".env file was not found")
+	} else {
+		logger.Debug().Msg(".env file was loaded")
+	}
+
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
+	if nil != err {
+		return fmt.Errorf("load config: %v", err)
+	}
+
+	logger = log.FromConfig(conf.Log)
+
+	logger.Debug().Dict("config", conf.ToDict()).Msg("Config loaded")
+
+	box, err := encryptionBox(conf.Encryption)
+	if nil != err {
+		return fmt.Errorf("create encryption box: %v", err)
+	}
+
+	name := cmp.Or(cmd.String("name"), conf.Telegram.Storage.Session)
+	session, err := telegram.ExportSession(ctx, conf.Telegram, box, name)
+	if nil != err {
+		if errors.Is(err, telegram.ErrSessionNotFound) {
+			logger.Error().Str("session", name).Msg("Session was not found. Use the list command to see the stored sessions.")
+			return exitCodeError(2)
+		}
+
+		return fmt.Errorf("export telegram session: %w", err)
+	}
+
+	output := cmd.String("output")
+	if output == "-" {
+		if _, err := os.Stdout.Write(session); nil != err {
+			return fmt.Errorf("write session: %v", err)
+		}
+
+		return nil
+	}
+
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if nil != err {
+		return fmt.Errorf("create output file: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close output file: %v", closeErr))
+		}
+	}()
+
+	if _, err := f.Write(session); nil != err {
+		return fmt.Errorf("write session: %v", err)
+	}
+
+	logger.Warn().Str("session", name).Str("output", output).Msg("Session was exported. The file gives full access to the account, so keep it safe.")
+
+	return nil
+}
+
+func telegramSessionsImport(ctx context.Context, cmd *cli.Command) error {
+	logger := log.NewDefault()
+
+	if err := godotenv.Load(); nil != err {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("load .env file: %v", err)
+		}
+		logger.Info().Msg(".env file was not found")
+	} else {
+		logger.Debug().Msg(".env file was loaded")
+	}
+
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
+	if nil != err {
+		return fmt.Errorf("load config: %v", err)
+	}
+
+	logger = log.FromConfig(conf.Log)
+
+	logger.Debug().Dict("config", conf.ToDict()).Msg("Config loaded")
+
+	box, err := encryptionBox(conf.Encryption)
+	if nil != err {
+		return fmt.Errorf("create encryption box: %v", err)
+	}
+
+	var session []byte
+	if input := cmd.String("input"); input == "-" {
+		session, err = io.ReadAll(os.Stdin)
+	} else {
+		session, err = os.ReadFile(input)
+	}
+	if nil != err {
+		return fmt.Errorf("read session: %v", err)
+	}
+
+	name := cmp.Or(cmd.String("name"), conf.Telegram.Storage.Session)
+	if err := telegram.ImportSession(ctx, conf.Telegram, box, name, session, cmd.Bool("overwrite")); nil != err {
+		if errors.Is(err, telegram.ErrSessionExists) {
+			logger.Error().Str("session", name).Msg("Session already exists. Use --overwrite to replace it.")
+			return exitCodeError(2)
+		}
+
+		return fmt.Errorf("import telegram session: %w", err)
+	}
+
+	logger.Info().Str("session", name).Msg("Session was imported successfully")
+
+	return nil
+}
+
 func botRun(ctx context.Context, cmd *cli.Command) error {
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// The bot is shut down with telegram.ErrUnauthorized as cause once the Telegram session is lost.
+	ctx, shutdown := context.WithCancelCause(ctx)
+	defer shutdown(nil)
+
 	logger := log.NewDefault()
 
 	if err := godotenv.Load(); nil != err {
@@ -214,7 +664,7 @@ func botRun(ctx context.Context, cmd *cli.Command) error {
 		logger.Debug().Msg(".env file was loaded")
 	}
 
-	conf, err := config.Load(cmd.String("config"))
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
 	if nil != err {
 		return fmt.Errorf("load config: %v", err)
 	}
@@ -223,45 +673,105 @@ func botRun(ctx context.Context, cmd *cli.Command) error {
 
 	logger.Debug().Dict("config", conf.ToDict()).Msg("Config loaded")
 
-	td, err := tidal.NewClient(logger, conf.Bot.CredsDir, conf.Bot.DownloadsDir, conf.Tidal)
+	networkFS, err := downloadsOnNetworkFS(logger, conf.Bot)
+	if nil != err {
+		return fmt.Errorf("detect downloads directory filesystem: %v", err)
+	}
+
+	box, err := encryptionBox(conf.Encryption)
+	if nil != err {
+		return fmt.Errorf("create encryption box: %v", err)
+	}
+
+	gate := pause.NewGate()
+
+	td, err := tidal.NewClient(logger, conf.Bot.CredsDir, conf.Bot.DownloadsDir, networkFS, gate, conf.Tidal, box)
 	if nil != err {
 		return fmt.Errorf("create tidal client: %v", err)
 	}
+	defer func() {
+		if err := td.Close(); nil != err {
+			logger.Error().Err(err).Msg("close tidal client")
+		}
+	}()
 	logger.Debug().Msg("Tidal client created")
 
-	b, err := bot.New(ctx, logger, conf.Bot)
-	if nil != err {
-		return fmt.Errorf("create tidalgram bot: %w", err)
+	if err := td.CheckClockSkew(ctx, logger); nil != err {
+		logger.Warn().Err(err).Msg("Failed to check clock skew. Token expiry is computed using the local clock")
+	}
+
+	bus := events.NewBus()
+	upOpts := telegram.UploaderOptions{
+		Box:     box,
+		Bus:     bus,
+		Gate:    gate,
+		Updates: nil,
+	}
+
+	// The user account frontend receives, and replies to, messages over the upload session, hence it is created
+	// once the uploader is connected.
+	var (
+		b           *bot.Bot
+		userUpdates *bot.UserUpdates
+	)
+	if conf.Bot.Frontend == config.BotFrontendUserAccount {
+		userUpdates = bot.NewUserUpdates(logger)
+		upOpts.Updates = userUpdates
+	} else {
+		b, err = bot.New(ctx, logger, conf.Bot)
+		if nil != err {
+			return fmt.Errorf("create tidalgram bot: %w", err)
+		}
+		logger.Info().Dict("account", b.Account.ToDict()).Msg("Bot instance created")
 	}
-	logger.Info().Dict("account", b.Account.ToDict()).Msg("Bot instance created")
 
-	up, err := telegram.NewUploader(ctx, logger, conf.Telegram)
+	up, err := telegram.NewUploader(ctx, logger, conf.Telegram, upOpts)
 	if nil != err {
 		if errors.Is(err, telegram.ErrUnauthorized) {
-			logger.Error().Msg("Telegram client is not authorized. Please login to Telegram.")
+			logger.
+				Error().
+				Msg("Telegram client is not authorized. Please login to Telegram, using `tidalgram telegram login --via-bot` on servers without a TTY.")
 			return exitCodeError(2)
 		}
 
-		if errors.Is(err, telegram.ErrPeerNotFound) {
-			switch kind := conf.Telegram.Upload.Peer.Kind; kind {
+		var peerErr *telegram.PeerNotFoundError
+		if errors.As(err, &peerErr) {
+			if len(peerErr.Candidates) > 0 {
+				candidates := zerolog.Arr()
+				for _, c := range peerErr.Candidates {
+					candidate := zerolog.Dict().Int64("id", c.ID).Str("kind", c.Kind).Str("title", c.Title)
+					candidates.Dict(candidate.Str("username", c.Username))
+				}
+				logger.
+					Warn().
+					Array("candidates", candidates).
+					Msg("Configured Telegram peer might be mistyped. Found dialogs with the closest IDs")
+			}
+			if nil != b {
+				if err := b.NotifyPeerNotFound(ctx, peerErr); nil != err {
+					logger.Error().Err(err).Msg("Failed to notify papa about the configured Telegram peer that was not found")
+				}
+			}
+			logger.Info().Msg("Run `tidalgram telegram peers` to list the IDs and kinds of the chats you can upload to.")
+			switch kind := peerErr.Peer.Kind; kind {
 			case "channel":
 				logger.
 					Error().
-					Int64("channel_id", conf.Telegram.Upload.Peer.ID).
+					Int64("channel_id", peerErr.Peer.ID).
 					Msg("Telegram channel not found. Please make sure you are an admin of the channel.")
 
 				return exitCodeError(3)
 			case "chat":
 				logger.
 					Error().
-					Int64("chat_id", conf.Telegram.Upload.Peer.ID).
+					Int64("chat_id", peerErr.Peer.ID).
 					Msg("Telegram chat (legacy group) not found. Please make sure you are a member of the chat.")
 
 				return exitCodeError(3)
 			case "user":
 				logger.
 					Error().
-					Int64("user_id", conf.Telegram.Upload.Peer.ID).
+					Int64("user_id", peerErr.Peer.ID).
 					Msg("Telegram user not found. Please make sure you have already have a private chat with the user.")
 
 				return exitCodeError(3)
@@ -279,9 +789,59 @@ func botRun(ctx context.Context, cmd *cli.Command) error {
 	}()
 	logger.Debug().Msg("Telegram uploader created")
 
-	worker := bot.NewWorker(1)
+	if nil != userUpdates {
+		b, err = bot.NewUserAccount(ctx, logger, conf.Bot, up.API(), userUpdates)
+		if nil != err {
+			return fmt.Errorf("create tidalgram user account bot: %w", err)
+		}
+		logger.Info().Dict("account", b.Account.ToDict()).Msg("Bot instance created")
+	}
 
-	b.RegisterHandlers(ctx, logger, conf.Bot, td, up, worker)
+	if err := up.ResumePendingBatches(ctx); nil != err {
+		logger.Error().Err(err).Msg("Failed to send pending media groups")
+	}
+
+	store, err := audit.Open(conf.Bot.Audit.Path)
+	if nil != err {
+		return fmt.Errorf("open audit store: %v", err)
+	}
+	defer func() {
+		if err := store.Close(); nil != err {
+			logger.Error().Err(err).Msg("close audit store")
+		}
+	}()
+	logger.Debug().Msg("Audit store opened")
+
+	worker := bot.NewWorker(1, gate)
+
+	jn := janitor.New(logger, conf.Bot.Janitor, td.DownloadsDirFs, store, storage.New(conf.Bot.Janitor.Mirror, http.DefaultClient))
+	outbox := bot.NewOutbox(b, logger, conf.Bot.Outbox)
+
+	maintenance, err := bot.NewMaintenance(td.DownloadsDirFs)
+	if nil != err {
+		return fmt.Errorf("load maintenance mode: %v", err)
+	}
+	if state := maintenance.State(); state.Enabled {
+		logger.Warn().Time("since", state.Since).Msg("Maintenance mode is on. New links are declined until it is turned off")
+	}
+
+	watchSchedule, err := config.ParseWatchSchedule(conf.Bot.Watch.Schedule)
+	if nil != err {
+		return fmt.Errorf("parse watch schedule: %v", err)
+	}
+
+	watcher, err := bot.NewWatcher(logger, watchSchedule, conf.Bot.Watch.Links, td, up, worker, store, jn, outbox, bus, maintenance)
+	if nil != err {
+		return fmt.Errorf("create watcher: %v", err)
+	}
+
+	reloader := bot.NewReloader(logger, *conf, func() (*config.Config, error) {
+		return config.Load(cmd.String("config"), configOverrides(logger, cmd))
+	}, td, up, worker)
+
+	b.RegisterHandlers(ctx, logger, conf.Bot, td, up, worker, store, watcher, jn, outbox, bus, maintenance, reloader)
+
+	go outbox.Run(ctx)
 
 	logger.Debug().Msg("Starting Tidalgram bot")
 	if err := b.Start(ctx); nil != err {
@@ -289,6 +849,48 @@ func botRun(ctx context.Context, cmd *cli.Command) error {
 	}
 	logger.Info().Msg("Tidalgram bot started and listening for updates")
 
+	go func() {
+		if err := bot.ResumePendingJob(ctx, logger, td, up, worker, store, outbox, bus); nil != err {
+			logger.Error().Err(err).Msg("Failed to resume pending job")
+		}
+	}()
+	go watcher.Run(ctx, b)
+	go jn.Run(ctx, worker)
+	go reloadOnSignal(ctx, logger, reloader)
+	go shutdownOnUnauthorized(ctx, logger, b, up, worker, shutdown)
+
+	if conf.API.Enabled {
+		srv := api.New(logger, conf.API, bus)
+		go func() {
+			if err := srv.Run(ctx); nil != err {
+				logger.Error().Err(err).Msg("HTTP API server stopped with error")
+			}
+		}()
+		logger.Info().Str("listen_addr", conf.API.ListenAddr).Msg("HTTP API server started")
+	}
+
+	if conf.Health.Enabled {
+		checks := []health.Check{
+			{Name: "telegram", Live: true, Run: up.CheckSession},
+			{Name: "tidal", Live: false, Run: func(ctx context.Context) error { return td.CheckLogin(ctx, logger) }},
+		}
+		if maxJobDuration := conf.Health.MaxJobDuration.Duration; maxJobDuration > 0 {
+			checks = append(checks, health.Check{
+				Name: "worker",
+				Live: true,
+				Run:  func(context.Context) error { return worker.CheckStuck(maxJobDuration) },
+			})
+		}
+
+		srv := health.New(logger, conf.Health, checks)
+		go func() {
+			if err := srv.Run(ctx); nil != err {
+				logger.Error().Err(err).Msg("Health check server stopped with error")
+			}
+		}()
+		logger.Info().Str("listen_addr", conf.Health.ListenAddr).Msg("Health check server started")
+	}
+
 	<-ctx.Done()
 	logger.Warn().Msg("Stopping Tidalgram application")
 
@@ -297,9 +899,111 @@ func botRun(ctx context.Context, cmd *cli.Command) error {
 	}
 	logger.Info().Msg("Tidalgram bot stopped successfully")
 
+	if errors.Is(context.Cause(ctx), telegram.ErrUnauthorized) {
+		return exitCodeError(2)
+	}
+
 	return nil
 }
 
+// shutdownOnUnauthorized shuts down the bot once the Telegram session is lost, after the running job, if any,
+// stores itself to be resumed, so that the session storage is released for logging in again.
+func shutdownOnUnauthorized(
+	ctx context.Context,
+	logger zerolog.Logger,
+	b *bot.Bot,
+	up *telegram.Uploader,
+	worker *bot.Worker,
+	shutdown context.CancelCauseFunc,
+) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-up.Unauthorized():
+	}
+
+	logger.Error().Msg("Telegram session was lost. Shutting down. Please login to Telegram, and start the bot again.")
+	if err := b.NotifyTelegramUnauthorized(ctx); nil != err {
+		logger.Error().Err(err).Msg("Failed to notify papa about the lost Telegram session")
+	}
+
+	// The job lock is kept, as the bot is shutting down.
+	if _, err := worker.AcquireJob(ctx); nil != err {
+		return
+	}
+	shutdown(telegram.ErrUnauthorized)
+}
+
+// reloadOnSignal reloads the config file on each SIGHUP until ctx is done.
+func reloadOnSignal(ctx context.Context, logger zerolog.Logger, reloader *bot.Reloader) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			logger.Info().Msg("Received SIGHUP. Reloading config")
+			if _, err := reloader.Reload(ctx); nil != err {
+				logger.Error().Err(err).Msg("Failed to reload config")
+			}
+		}
+	}
+}
+
+// encryptionBox returns the box Tidal credentials and the Telegram session are encrypted at rest with, or nil if
+// encryption is disabled.
+func encryptionBox(conf config.Encryption) (*secret.Box, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	return secret.New(conf.Passphrase)
+}
+
+// configOverrides returns the config field overrides set using environment variables, followed by the ones
+// set using flags, so that the latter take precedence. Prefixed environment variables that do not match any
+// config field are ignored with a warning.
+func configOverrides(logger zerolog.Logger, cmd *cli.Command) []string {
+	overrides, unknown := config.EnvOverrides(os.Environ())
+	for _, name := range unknown {
+		logger.Warn().Str("name", name).Msg("Ignoring environment variable that does not match any config field")
+	}
+
+	return append(overrides, cmd.StringSlice("set")...)
+}
+
+// downloadsOnNetworkFS reports whether the downloads directory is on a network filesystem, either as configured,
+// or as detected from its mount.
+func downloadsOnNetworkFS(logger zerolog.Logger, conf config.Bot) (bool, error) {
+	networkFS := conf.DownloadsFS == config.DownloadsFSNetwork
+	if conf.DownloadsFS == config.DownloadsFSAuto {
+		detected, err := fs.DetectFilesystem(conf.DownloadsDir)
+		if nil != err {
+			return false, err
+		}
+		logger.
+			Debug().
+			Str("type", detected.Type).
+			Str("mount_point", detected.MountPoint).
+			Bool("network", detected.Network).
+			Msg("Downloads directory filesystem detected")
+		networkFS = detected.Network
+	}
+
+	if networkFS {
+		logger.
+			Warn().
+			Str("downloads_dir", conf.DownloadsDir).
+			Msg("Downloads directory is on a network filesystem. Tracks are assembled in a local temporary " +
+				"directory and copied over, which makes downloads slower and needs free space in the temporary directory")
+	}
+
+	return networkFS, nil
+}
+
 func botLogout(ctx context.Context, cmd *cli.Command) error {
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -315,7 +1019,7 @@ func botLogout(ctx context.Context, cmd *cli.Command) error {
 		logger.Debug().Msg(".env file was loaded")
 	}
 
-	conf, err := config.Load(cmd.String("config"))
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
 	if nil != err {
 		return fmt.Errorf("load config: %v", err)
 	}
@@ -353,7 +1057,7 @@ func botClose(ctx context.Context, cmd *cli.Command) error {
 		logger.Info().Msg(".env file was loaded")
 	}
 
-	conf, err := config.Load(cmd.String("config"))
+	conf, err := config.Load(cmd.String("config"), configOverrides(logger, cmd))
 	if nil != err {
 		return fmt.Errorf("load config: %v", err)
 	}
@@ -0,0 +1,245 @@
+// Package markdown parses the Markdown, and MarkdownV2, formatting of Bot API messages into plain text, and its
+// MTProto message entities, so that messages formatted for the Bot API can be sent by a user account.
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+// Parse returns text without its formatting, and the entities of its formatting, in the legacy Markdown style of
+// the Bot API, or in the MarkdownV2 style if v2 is set. Unlike the Bot API, it does not reject text with unclosed
+// entities, whose markers are dropped instead. Text of links is not formatted.
+func Parse(text string, v2 bool) (string, []tg.MessageEntityClass) {
+	p := &parser{
+		v2:         v2,
+		out:        strings.Builder{},
+		pos:        0,
+		entities:   nil,
+		open:       make(map[string]int),
+		quoteStart: -1,
+	}
+	p.parse([]rune(text))
+	p.closeQuote()
+
+	slices.SortStableFunc(p.entities, func(a, b tg.MessageEntityClass) int {
+		return a.GetOffset() - b.GetOffset()
+	})
+
+	return p.out.String(), p.entities
+}
+
+type parser struct {
+	v2  bool
+	out strings.Builder
+	// pos is the length of out in UTF-16 code units, which entity offsets are measured in.
+	pos      int
+	entities []tg.MessageEntityClass
+	// open holds the offsets of the entities that are not closed yet, by their markers.
+	open map[string]int
+	// quoteStart is the offset of the block quote that is not closed yet, or -1 if there is none.
+	quoteStart int
+}
+
+func (p *parser) parse(rs []rune) {
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs) && p.escapable(rs[i+1]):
+			i++
+			p.write(rs[i])
+		case r == '`' && hasPrefix(rs[i:], "```"):
+			i = p.pre(rs, i)
+		case r == '`':
+			i = p.code(rs, i)
+		case r == '[':
+			if end, ok := p.link(rs, i); ok {
+				i = end
+			} else {
+				p.write(r)
+			}
+		case r == '*':
+			p.toggle("*")
+		case r == '_' && p.v2 && i+1 < len(rs) && rs[i+1] == '_':
+			i++
+			p.toggle("__")
+		case r == '_':
+			p.toggle("_")
+		case r == '~' && p.v2:
+			p.toggle("~")
+		case r == '|' && p.v2 && i+1 < len(rs) && rs[i+1] == '|':
+			i++
+			p.toggle("||")
+		case r == '>' && p.v2 && (i == 0 || rs[i-1] == '\n'):
+			if p.quoteStart < 0 {
+				p.quoteStart = p.pos
+			}
+			if i+1 < len(rs) && rs[i+1] == ' ' {
+				i++
+			}
+		case r == '\n':
+			// Block quotes span consecutive lines starting with >.
+			if i+1 >= len(rs) || rs[i+1] != '>' {
+				p.closeQuote()
+			}
+			p.write(r)
+		default:
+			p.write(r)
+		}
+	}
+}
+
+func (p *parser) escapable(r rune) bool {
+	if p.v2 {
+		return r > 0 && r < 127
+	}
+
+	return r == '_' || r == '*' || r == '`' || r == '['
+}
+
+func (p *parser) write(r rune) {
+	p.out.WriteRune(r)
+	p.pos += utf16.RuneLen(r)
+}
+
+func (p *parser) toggle(marker string) {
+	start, ok := p.open[marker]
+	if !ok {
+		p.open[marker] = p.pos
+		return
+	}
+	delete(p.open, marker)
+
+	if p.pos == start {
+		return
+	}
+
+	length := p.pos - start
+	switch marker {
+	case "*":
+		p.entities = append(p.entities, &tg.MessageEntityBold{Offset: start, Length: length})
+	case "_":
+		p.entities = append(p.entities, &tg.MessageEntityItalic{Offset: start, Length: length})
+	case "__":
+		p.entities = append(p.entities, &tg.MessageEntityUnderline{Offset: start, Length: length})
+	case "~":
+		p.entities = append(p.entities, &tg.MessageEntityStrike{Offset: start, Length: length})
+	case "||":
+		p.entities = append(p.entities, &tg.MessageEntitySpoiler{Offset: start, Length: length})
+	}
+}
+
+func (p *parser) closeQuote() {
+	if p.quoteStart < 0 {
+		return
+	}
+
+	if p.pos > p.quoteStart {
+		p.entities = append(p.entities, &tg.MessageEntityBlockquote{Offset: p.quoteStart, Length: p.pos - p.quoteStart}) //nolint:exhaustruct
+	}
+	p.quoteStart = -1
+}
+
+// code writes the inline code starting with the backtick at rs[i], and returns the index of its closing backtick.
+// An unclosed backtick is dropped.
+func (p *parser) code(rs []rune, i int) int {
+	end := p.closing(rs, i+1, "`")
+	if end < 0 {
+		return i
+	}
+
+	start := p.pos
+	p.literal(rs[i+1 : end])
+	if p.pos > start {
+		p.entities = append(p.entities, &tg.MessageEntityCode{Offset: start, Length: p.pos - start})
+	}
+
+	return end
+}
+
+// pre writes the code block starting with the backticks at rs[i], and returns the index of its last closing
+// backtick. The first line of the block is its language if it is a single word.
+func (p *parser) pre(rs []rune, i int) int {
+	end := p.closing(rs, i+3, "```")
+	if end < 0 {
+		return i + 2
+	}
+
+	content := rs[i+3 : end]
+	var lang string
+	if nl := slices.Index(content, '\n'); nl >= 0 {
+		if first := string(content[:nl]); !strings.ContainsAny(first, " \t`") {
+			lang, content = first, content[nl+1:]
+		}
+	}
+
+	start := p.pos
+	p.literal(content)
+	if p.pos > start {
+		p.entities = append(p.entities, &tg.MessageEntityPre{Offset: start, Length: p.pos - start, Language: lang})
+	}
+
+	return end + 2
+}
+
+// link writes the inline link starting with the bracket at rs[i], and returns the index of the parenthesis
+// closing its URL. It returns false if rs[i] does not start a link.
+func (p *parser) link(rs []rune, i int) (int, bool) {
+	textEnd := p.closing(rs, i+1, "]")
+	if textEnd < 0 || textEnd+1 >= len(rs) || rs[textEnd+1] != '(' {
+		return 0, false
+	}
+	urlEnd := p.closing(rs, textEnd+2, ")")
+	if urlEnd < 0 {
+		return 0, false
+	}
+
+	start := p.pos
+	p.literal(rs[i+1 : textEnd])
+
+	var url strings.Builder
+	for j := textEnd + 2; j < urlEnd; j++ {
+		if rs[j] == '\\' && p.v2 && j+1 < urlEnd {
+			j++
+		}
+		url.WriteRune(rs[j])
+	}
+	if p.pos > start {
+		p.entities = append(p.entities, &tg.MessageEntityTextURL{Offset: start, Length: p.pos - start, URL: url.String()})
+	}
+
+	return urlEnd, true
+}
+
+// literal writes rs as is, except for the escaped characters, which are only escaped in MarkdownV2 entities.
+func (p *parser) literal(rs []rune) {
+	for j := 0; j < len(rs); j++ {
+		if rs[j] == '\\' && p.v2 && j+1 < len(rs) {
+			j++
+		}
+		p.write(rs[j])
+	}
+}
+
+// closing returns the index of the first unescaped marker in rs from index from on, or -1 if there is none.
+func (p *parser) closing(rs []rune, from int, marker string) int {
+	for j := from; j < len(rs); j++ {
+		if rs[j] == '\\' && p.v2 {
+			j++
+			continue
+		}
+		if hasPrefix(rs[j:], marker) {
+			return j
+		}
+	}
+
+	return -1
+}
+
+func hasPrefix(rs []rune, prefix string) bool {
+	prefixRunes := []rune(prefix)
+	return len(rs) >= len(prefixRunes) && slices.Equal(rs[:len(prefixRunes)], prefixRunes)
+}
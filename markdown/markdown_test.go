@@ -0,0 +1,91 @@
+package markdown_test
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/markdown"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		text     string
+		v2       bool
+		plain    string
+		entities []tg.MessageEntityClass
+	}{
+		{
+			name:     "plain",
+			text:     "I'm online, papa 🙂",
+			plain:    "I'm online, papa 🙂",
+			entities: nil,
+		},
+		{
+			name:  "legacy",
+			text:  "🚧 Downloading *album* `123`, see [it](https://tidal.com/album/123)",
+			plain: "🚧 Downloading album 123, see it",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBold{Offset: 15, Length: 5},
+				&tg.MessageEntityCode{Offset: 21, Length: 3},
+				&tg.MessageEntityTextURL{Offset: 30, Length: 2, URL: "https://tidal.com/album/123"},
+			},
+		},
+		{
+			name:     "legacy escapes",
+			text:     `max\_file\_size\_mb`,
+			plain:    "max_file_size_mb",
+			entities: nil,
+		},
+		{
+			name:  "v2",
+			text:  "__under__ ~strike~ ||spoiler|| _it_ 1\\.0",
+			v2:    true,
+			plain: "under strike spoiler it 1.0",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityUnderline{Offset: 0, Length: 5},
+				&tg.MessageEntityStrike{Offset: 6, Length: 6},
+				&tg.MessageEntitySpoiler{Offset: 13, Length: 7},
+				&tg.MessageEntityItalic{Offset: 21, Length: 2},
+			},
+		},
+		{
+			name:  "v2 quote",
+			text:  "I'm online\n\n> 🏷️ Version: `1.0`\n> 🕒 Compiled",
+			v2:    true,
+			plain: "I'm online\n\n🏷️ Version: 1.0\n🕒 Compiled",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityBlockquote{Offset: 12, Length: 28},
+				&tg.MessageEntityCode{Offset: 25, Length: 3},
+			},
+		},
+		{
+			name:  "pre",
+			text:  "```go\nfmt.Println()```",
+			plain: "fmt.Println()",
+			entities: []tg.MessageEntityClass{
+				&tg.MessageEntityPre{Offset: 0, Length: 13, Language: "go"},
+			},
+		},
+		{
+			name:     "unclosed",
+			text:     "a *b",
+			plain:    "a b",
+			entities: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			plain, entities := markdown.Parse(test.text, test.v2)
+			require.Equal(t, test.plain, plain)
+			require.Equal(t, test.entities, entities)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package pause
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate suspends jobs at their checkpoints while it is paused. A nil gate is never paused.
+type Gate struct {
+	mu sync.Mutex
+	// resumed is closed when the gate is resumed. It is nil while the gate is not paused.
+	resumed chan struct{}
+}
+
+func NewGate() *Gate {
+	return &Gate{mu: sync.Mutex{}, resumed: nil}
+}
+
+// Pause pauses g. It returns false if g was already paused.
+func (g *Gate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if nil != g.resumed {
+		return false
+	}
+	g.resumed = make(chan struct{})
+
+	return true
+}
+
+// Resume resumes g, releasing all waiting jobs. It returns false if g was not paused.
+func (g *Gate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if nil == g.resumed {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+
+	return true
+}
+
+func (g *Gate) Paused() bool {
+	if nil == g {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return nil != g.resumed
+}
+
+// Wait is a job checkpoint. It blocks while g is paused, until g is resumed, or ctx is done.
+func (g *Gate) Wait(ctx context.Context) error {
+	if nil == g {
+		return nil
+	}
+
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+
+	if nil == resumed {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
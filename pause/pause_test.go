@@ -0,0 +1,46 @@
+package pause_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/pause"
+)
+
+func TestGate(t *testing.T) {
+	t.Parallel()
+
+	g := pause.NewGate()
+	require.NoError(t, g.Wait(t.Context()))
+	require.False(t, g.Resume())
+
+	require.True(t, g.Pause())
+	require.False(t, g.Pause())
+	require.True(t, g.Paused())
+
+	done := make(chan error, 1)
+	go func() { done <- g.Wait(t.Context()) }()
+
+	select {
+	case <-done:
+		t.Fatal("wait returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.True(t, g.Resume())
+	require.NoError(t, <-done)
+	require.False(t, g.Paused())
+
+	ctx, cancel := context.WithCancelCause(t.Context())
+	require.True(t, g.Pause())
+	cancel(errors.New("job canceled"))
+	require.ErrorIs(t, g.Wait(ctx), context.Canceled)
+
+	var nilGate *pause.Gate
+	require.NoError(t, nilGate.Wait(t.Context()))
+	require.False(t, nilGate.Paused())
+}
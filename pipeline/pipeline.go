@@ -0,0 +1,181 @@
+// Package pipeline hands album tracks over from their download to their upload as soon as they are downloaded,
+// so that uploading an album starts before all of its tracks are downloaded. Single tracks can be streamed to
+// their upload while they are being written, too.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+var (
+	ErrTrackNotDownloaded = errors.New("album download finished without downloading the track")
+	errLayoutNotSet       = errors.New("album download finished without setting the album layout")
+)
+
+// Album is the download state of an album, shared by its download and upload. A nil album has nothing to wait
+// for, i.e., it is already downloaded.
+type Album struct {
+	mu     sync.Mutex
+	layout *types.StoredAlbum
+	ready  map[string]struct{}
+	done   bool
+	err    error
+	// changed is closed, and replaced, whenever the state changes.
+	changed chan struct{}
+}
+
+func NewAlbum() *Album {
+	return &Album{
+		mu:      sync.Mutex{},
+		layout:  nil,
+		ready:   make(map[string]struct{}),
+		done:    false,
+		err:     nil,
+		changed: make(chan struct{}),
+	}
+}
+
+// SetLayout sets the album metadata and the IDs of the tracks of each of its volumes, in order, once they are
+// known, and before any of its tracks is ready.
+func (a *Album) SetLayout(info types.StoredAlbum) {
+	if nil == a {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.layout = &info
+	a.notify()
+}
+
+// TrackReady marks the track with id downloaded, so that it can be uploaded.
+func (a *Album) TrackReady(id string) {
+	if nil == a {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.ready[id] = struct{}{}
+	a.notify()
+}
+
+// Finish marks the album download finished, failed with err if it is not nil.
+func (a *Album) Finish(err error) {
+	if nil == a {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.done, a.err = true, err
+	a.notify()
+}
+
+// Layout waits until the album layout is set, and returns it. Unlike the rest of the methods, it must not be
+// called on a nil album.
+func (a *Album) Layout(ctx context.Context) (*types.StoredAlbum, error) {
+	var layout *types.StoredAlbum
+	err := a.wait(ctx, func() (bool, error) {
+		if nil != a.layout {
+			layout = a.layout
+			return true, nil
+		}
+
+		if err := a.finishedErr(); nil != err {
+			return false, err
+		} else if a.done {
+			return false, errLayoutNotSet
+		}
+
+		return false, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	return layout, nil
+}
+
+// WaitTracks waits until all tracks with ids are downloaded. It fails if the album download finishes before.
+func (a *Album) WaitTracks(ctx context.Context, ids []string) error {
+	if nil == a {
+		return nil
+	}
+
+	return a.wait(ctx, func() (bool, error) {
+		for _, id := range ids {
+			if _, ok := a.ready[id]; !ok {
+				if err := a.finishedErr(); nil != err {
+					return false, err
+				} else if a.done {
+					return false, fmt.Errorf("track %s: %w", id, ErrTrackNotDownloaded)
+				}
+
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// Wait waits until the album download finishes, and returns its error.
+func (a *Album) Wait(ctx context.Context) error {
+	if nil == a {
+		return nil
+	}
+
+	return a.wait(ctx, func() (bool, error) {
+		return a.done, a.finishedErr()
+	})
+}
+
+// wait waits until cond, which is called with a.mu held, is satisfied or fails.
+func (a *Album) wait(ctx context.Context, cond func() (bool, error)) error {
+	return waitFor(ctx, &a.mu, &a.changed, cond)
+}
+
+func (a *Album) finishedErr() error {
+	if a.done && nil != a.err {
+		return fmt.Errorf("album download failed: %w", a.err)
+	}
+
+	return nil
+}
+
+func (a *Album) notify() {
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// waitFor waits until cond, which is called with mu held, is satisfied or fails. changed, which is guarded by
+// mu, must be closed, and replaced, whenever the state cond checks changes.
+func waitFor(ctx context.Context, mu *sync.Mutex, changed *chan struct{}, cond func() (bool, error)) error {
+	for {
+		mu.Lock()
+		ok, err := cond()
+		ch := *changed
+		mu.Unlock()
+
+		if nil != err {
+			return err
+		} else if ok {
+			return nil
+		}
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
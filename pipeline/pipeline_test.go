@@ -0,0 +1,150 @@
+package pipeline_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/pipeline"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+func TestAlbum(t *testing.T) {
+	t.Parallel()
+
+	a := pipeline.NewAlbum()
+
+	layout := make(chan *types.StoredAlbum, 1)
+	go func() {
+		info, err := a.Layout(t.Context())
+		require.NoError(t, err)
+		layout <- info
+	}()
+
+	a.SetLayout(types.StoredAlbum{VolumeTrackIDs: [][]string{{"1", "2"}, {"3"}}}) //nolint:exhaustruct
+	require.Equal(t, [][]string{{"1", "2"}, {"3"}}, (<-layout).VolumeTrackIDs)
+
+	done := make(chan error, 1)
+	go func() { done <- a.WaitTracks(t.Context(), []string{"1", "2"}) }()
+
+	a.TrackReady("2")
+	select {
+	case <-done:
+		t.Fatal("wait returned before all tracks were ready")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	a.TrackReady("1")
+	require.NoError(t, <-done)
+
+	a.Finish(nil)
+	require.NoError(t, a.Wait(t.Context()))
+	require.ErrorIs(t, a.WaitTracks(t.Context(), []string{"3"}), pipeline.ErrTrackNotDownloaded)
+}
+
+func TestAlbumFailed(t *testing.T) {
+	t.Parallel()
+
+	a := pipeline.NewAlbum()
+	errDownload := errors.New("download failed")
+
+	done := make(chan error, 1)
+	go func() { done <- a.WaitTracks(t.Context(), []string{"1"}) }()
+
+	a.Finish(errDownload)
+	require.ErrorIs(t, <-done, errDownload)
+	require.ErrorIs(t, a.Wait(t.Context()), errDownload)
+
+	_, err := a.Layout(t.Context())
+	require.ErrorIs(t, err, errDownload)
+
+	var nilAlbum *pipeline.Album
+	require.NoError(t, nilAlbum.WaitTracks(t.Context(), []string{"1"}))
+	require.NoError(t, nilAlbum.Wait(t.Context()))
+}
+
+func TestTrackStream(t *testing.T) {
+	t.Parallel()
+
+	tr := pipeline.NewTrack()
+
+	taken := make(chan *pipeline.TrackStream, 1)
+	go func() {
+		stream, err := tr.Take(t.Context())
+		require.NoError(t, err)
+		taken <- stream
+	}()
+
+	info := types.StoredTrack{Track: types.Track{Title: "Song"}} //nolint:exhaustruct
+	w := tr.Stream(info)
+	require.NotNil(t, w)
+	require.Nil(t, tr.Stream(info), "track was streamed twice")
+
+	go func() {
+		_, _ = w.Write([]byte("tagged "))
+		_, _ = w.Write([]byte("track"))
+		w.CloseWrite(nil)
+	}()
+
+	r := <-taken
+	require.Equal(t, "Song", r.Info.Title)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "tagged track", string(b))
+
+	tr.Finish(nil)
+	require.NoError(t, tr.Wait(t.Context()))
+
+	// The stream is only taken once, e.g., by the upload to the first peer.
+	r, err = tr.Take(t.Context())
+	require.NoError(t, err)
+	require.Nil(t, r)
+}
+
+func TestTrackStreamFailed(t *testing.T) {
+	t.Parallel()
+
+	tr := pipeline.NewTrack()
+	errTags := errors.New("mismatching tags")
+
+	w := tr.Stream(types.StoredTrack{}) //nolint:exhaustruct
+	go func() {
+		_, _ = w.Write([]byte("mistagged"))
+		w.CloseWrite(errTags)
+	}()
+
+	r, err := tr.Take(t.Context())
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, errTags)
+
+	tr.Finish(errTags)
+	require.ErrorIs(t, tr.Wait(t.Context()), errTags)
+}
+
+func TestTrackNotStreamed(t *testing.T) {
+	t.Parallel()
+
+	tr := pipeline.NewTrack()
+	tr.Finish(nil)
+
+	r, err := tr.Take(t.Context())
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	// Writing a stream that is not read anymore does not block.
+	tr = pipeline.NewTrack()
+	w := tr.Stream(types.StoredTrack{}) //nolint:exhaustruct
+	tr.Close()
+	n, err := w.Write([]byte("track"))
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Nil(t, tr.Stream(types.StoredTrack{})) //nolint:exhaustruct
+
+	var nilTrack *pipeline.Track
+	require.Nil(t, nilTrack.Stream(types.StoredTrack{})) //nolint:exhaustruct
+	require.NoError(t, nilTrack.Wait(t.Context()))
+}
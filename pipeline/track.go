@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Track is the download state of a single track, shared by its download and upload, through which the tagged
+// track file is streamed to the upload while it is being written. A nil track is never streamed.
+type Track struct {
+	mu     sync.Mutex
+	stream *TrackStream
+	taken  bool
+	closed bool
+	done   bool
+	err    error
+	// changed is closed, and replaced, whenever the state changes.
+	changed chan struct{}
+}
+
+func NewTrack() *Track {
+	return &Track{
+		mu:      sync.Mutex{},
+		stream:  nil,
+		taken:   false,
+		closed:  false,
+		done:    false,
+		err:     nil,
+		changed: make(chan struct{}),
+	}
+}
+
+// Stream starts streaming the track described by info, and returns the stream the track file is to be written
+// to, along with the file. It returns nil if the track is not to be streamed, i.e., it was already streamed,
+// e.g., by a failed download attempt, or its upload does not wait for it anymore.
+func (t *Track) Stream(info types.StoredTrack) *TrackStream {
+	if nil == t {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if nil != t.stream || t.closed {
+		return nil
+	}
+
+	r, w := io.Pipe()
+	t.stream = &TrackStream{Info: info, r: r, w: w}
+	t.notify()
+
+	return t.stream
+}
+
+// Finish marks the track download finished, failed with err if it is not nil.
+func (t *Track) Finish(err error) {
+	if nil == t {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done, t.err = true, err
+	t.notify()
+}
+
+// Take waits until the track is streamed, and returns its stream, which must be closed once it is read. It
+// returns nil if the track download finished without streaming it, or if its stream was already taken.
+func (t *Track) Take(ctx context.Context) (*TrackStream, error) {
+	if nil == t {
+		return nil, nil
+	}
+
+	var stream *TrackStream
+	err := waitFor(ctx, &t.mu, &t.changed, func() (bool, error) {
+		if t.taken || t.closed {
+			return true, nil
+		}
+
+		if nil != t.stream {
+			t.taken, stream = true, t.stream
+			return true, nil
+		}
+
+		return t.done, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// Wait waits until the track download finishes, and returns its error.
+func (t *Track) Wait(ctx context.Context) error {
+	if nil == t {
+		return nil
+	}
+
+	return waitFor(ctx, &t.mu, &t.changed, func() (bool, error) {
+		if t.done && nil != t.err {
+			return false, fmt.Errorf("track download failed: %w", t.err)
+		}
+
+		return t.done, nil
+	})
+}
+
+// Close stops streaming the track, as its upload does not wait for it anymore. A stream that is already being
+// written, and was not taken, is closed, so that the rest of it is written without being read.
+func (t *Track) Close() {
+	if nil == t {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	if nil != t.stream && !t.taken {
+		_ = t.stream.Close()
+	}
+	t.notify()
+}
+
+func (t *Track) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// TrackStream is a tagged track file that is read by its upload while it is written by its download. Reads
+// end once the whole file is written, and fail with the error writing it, including checking it, failed with.
+type TrackStream struct {
+	// Info is the metadata of the track, without the size and checksum of its file, which are only known once
+	// the whole file is written.
+	Info types.StoredTrack
+	r    *io.PipeReader
+	w    *io.PipeWriter
+}
+
+// Read reads the next written bytes of the track file, waiting for them to be written.
+func (s *TrackStream) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+// Close stops reading the stream. The rest of the track file is still written, but not streamed.
+func (s *TrackStream) Close() error {
+	return s.r.Close()
+}
+
+// Write writes p to the stream, waiting for it to be read. Writing never fails, as the track file is written
+// elsewhere too, and the bytes written after the stream was closed are discarded.
+func (s *TrackStream) Write(p []byte) (int, error) {
+	_, _ = s.w.Write(p)
+	return len(p), nil
+}
+
+// CloseWrite ends the stream once the whole track file is written, and checked, failed with err if it is not
+// nil, in which case reads fail with it.
+func (s *TrackStream) CloseWrite(err error) {
+	_ = s.w.CloseWithError(err)
+}
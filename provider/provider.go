@@ -0,0 +1,34 @@
+// Package provider abstracts the music source links are downloaded from, so that the bot can process links of
+// sources other than Tidal, e.g., Qobuz, using the same job pipeline. A provider downloads the metadata, the
+// streams, the covers, and the lyrics of the tracks of a link to its downloads directory, in the layout of
+// [fs.DownloadsDir], which is all the uploader, and the rest of the pipeline read.
+package provider
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Provider is a music source. It is implemented by [tidal.Client].
+type Provider interface {
+	// Name is the name of the provider, e.g., tidal.
+	Name() string
+	// ResolveLink returns the link at rawURL, and whether it is a link of the provider.
+	ResolveLink(rawURL string) (types.Link, bool)
+	// DownloadsDir is the directory links are downloaded to.
+	DownloadsDir() fs.DownloadsDir
+	// TryDownloadLink downloads the tracks of link, retrying transient failures.
+	TryDownloadLink(ctx context.Context, logger zerolog.Logger, link types.Link) error
+	// TrySyncLink downloads the playlist or mix tracks of link that were not uploaded in a previous sync, and
+	// returns the IDs of all of its tracks. See [Provider.MarkSynced].
+	TrySyncLink(ctx context.Context, logger zerolog.Logger, link types.Link) ([]string, error)
+	// TryUpdateAlbum downloads the tracks of the album of link that are not in uploadedIDs, and returns the IDs
+	// of all of its tracks.
+	TryUpdateAlbum(ctx context.Context, logger zerolog.Logger, link types.Link, uploadedIDs []string) ([]string, error)
+	// MarkSynced records trackIDs as uploaded, so that the next sync of link skips them.
+	MarkSynced(link types.Link, trackIDs []string) error
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// loosenAfter is the number of consecutive successful requests after which the rate is raised.
+	loosenAfter = 20
+	// loosenSteps is the number of raises it takes the rate to recover from its minimum to its maximum.
+	loosenSteps = 10
+	// tightenInterval is how long the rate is not lowered again after it was lowered, so that the burst of
+	// requests that were sent before the rate was lowered does not lower it once per request.
+	tightenInterval = 5 * time.Second
+	// minRate is the lowest rate the minimum rate is raised to, so that throttled requests never stall for good.
+	minRate = 0.01
+)
+
+// Adaptive is a token bucket limiter whose rate is halved once requests are throttled, down to a minimum, and
+// raised step by step on sustained success, up to a maximum.
+type Adaptive struct {
+	limiter *rate.Limiter
+	max     rate.Limit
+	min     rate.Limit
+
+	mu          sync.Mutex
+	successes   int
+	tightenedAt time.Time
+}
+
+// NewAdaptive returns a limiter that starts at, and never exceeds maxPerSecond requests per second, with bursts of
+// up to burst requests, and that is never lowered below minPerSecond, which is raised to one request every 100
+// seconds if it is lower than that, e.g., zero. It returns nil, which does not limit requests, if maxPerSecond is
+// not positive.
+func NewAdaptive(maxPerSecond, minPerSecond float64, burst int) *Adaptive {
+	if maxPerSecond <= 0 {
+		return nil
+	}
+
+	return &Adaptive{
+		limiter:     rate.NewLimiter(rate.Limit(maxPerSecond), max(burst, 1)),
+		max:         rate.Limit(maxPerSecond),
+		min:         rate.Limit(min(max(minPerSecond, minRate), maxPerSecond)),
+		mu:          sync.Mutex{},
+		successes:   0,
+		tightenedAt: time.Time{},
+	}
+}
+
+// Wait waits until a request can be sent.
+func (a *Adaptive) Wait(ctx context.Context) error {
+	if nil == a {
+		return nil
+	}
+
+	return a.limiter.Wait(ctx)
+}
+
+// Throttled halves the rate, unless it was lowered shortly before, or it is at its minimum. It returns the new
+// rate, and whether it was lowered.
+func (a *Adaptive) Throttled() (rate.Limit, bool) {
+	if nil == a {
+		return rate.Inf, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.successes = 0
+	current := a.limiter.Limit()
+	if current <= a.min || time.Since(a.tightenedAt) < tightenInterval {
+		return current, false
+	}
+
+	next := max(current/2, a.min)
+	a.limiter.SetLimit(next)
+	a.tightenedAt = time.Now()
+
+	return next, true
+}
+
+// Succeeded raises the rate by a step after enough consecutive successful requests, unless it is at its
+// maximum.
+func (a *Adaptive) Succeeded() {
+	if nil == a {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.successes++
+	if a.successes < loosenAfter {
+		return
+	}
+	a.successes = 0
+
+	if current := a.limiter.Limit(); current < a.max {
+		a.limiter.SetLimit(min(current+(a.max-a.min)/loosenSteps, a.max))
+	}
+}
+
+// Limit returns the current rate in requests per second.
+func (a *Adaptive) Limit() rate.Limit {
+	if nil == a {
+		return rate.Inf
+	}
+
+	return a.limiter.Limit()
+}
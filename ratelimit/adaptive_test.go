@@ -0,0 +1,89 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/xeptore/tidalgram/ratelimit"
+)
+
+func TestAdaptive(t *testing.T) {
+	t.Parallel()
+
+	var disabled *ratelimit.Adaptive
+	assert.Nil(t, ratelimit.NewAdaptive(0, 1, 1))
+	require.NoError(t, disabled.Wait(t.Context()))
+	assert.Equal(t, rate.Inf, disabled.Limit())
+
+	a := ratelimit.NewAdaptive(10, 1, 1)
+	require.NoError(t, a.Wait(t.Context()))
+
+	limit, ok := a.Throttled()
+	assert.True(t, ok)
+	assert.Equal(t, rate.Limit(5), limit)
+
+	// Throttles right after the rate was lowered are caused by the requests sent before it.
+	limit, ok = a.Throttled()
+	assert.False(t, ok)
+	assert.Equal(t, rate.Limit(5), limit)
+
+	for range 19 {
+		a.Succeeded()
+	}
+	assert.Equal(t, rate.Limit(5), a.Limit())
+	a.Succeeded()
+	assert.InDelta(t, 5.9, float64(a.Limit()), 1e-9)
+
+	for range 200 {
+		a.Succeeded()
+	}
+	assert.Equal(t, rate.Limit(10), a.Limit())
+}
+
+func TestAdaptiveMinRateIsPositive(t *testing.T) {
+	t.Parallel()
+
+	for _, minPerSecond := range []float64{0, -1} {
+		a := ratelimit.NewAdaptive(0.015, minPerSecond, 1)
+
+		limit, ok := a.Throttled()
+		assert.True(t, ok)
+		assert.Equal(t, rate.Limit(0.01), limit)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	api := ratelimit.NewAdaptive(100, 1, 10)
+	media := ratelimit.NewAdaptive(100, 1, 10)
+	limiters := map[ratelimit.Class]*ratelimit.Adaptive{ratelimit.ClassAPI: api, ratelimit.ClassMedia: media}
+	client := &http.Client{Transport: ratelimit.NewTransport(zerolog.Nop(), http.DefaultTransport, limiters)} //nolint:exhaustruct
+
+	req, err := http.NewRequestWithContext(ratelimit.WithClass(t.Context(), ratelimit.ClassMedia), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, rate.Limit(50), media.Limit())
+	assert.Equal(t, rate.Limit(100), api.Limit())
+
+	// Requests of classes without limiters are not limited.
+	req, err = http.NewRequestWithContext(ratelimit.WithClass(t.Context(), ratelimit.ClassStream), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// NewBandwidth returns a limiter of kibPerSecond KiB per second, or nil, meaning unlimited, if kibPerSecond is 0.
+func NewBandwidth(kibPerSecond int) *rate.Limiter {
+	if kibPerSecond <= 0 {
+		return nil
+	}
+
+	bytesPerSecond := kibPerSecond * 1024
+
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond)
+}
+
+// Reader returns a reader of r that reads no faster than limiter allows. It returns r itself if limiter is nil.
+func Reader(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if nil == limiter {
+		return r
+	}
+
+	return &limitedReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+type limitedReader struct {
+	ctx     context.Context //nolint:containedctx
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// Waiting for more tokens than the burst size always fails.
+	if burst := l.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if waitErr := l.limiter.WaitN(l.ctx, n); nil != waitErr {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
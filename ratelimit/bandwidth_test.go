@@ -0,0 +1,38 @@
+package ratelimit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/ratelimit"
+)
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte{1}, 3*1024)
+
+	r := ratelimit.Reader(t.Context(), bytes.NewReader(data), nil)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, out)
+
+	start := time.Now()
+	r = ratelimit.Reader(t.Context(), bytes.NewReader(data), ratelimit.NewBandwidth(1))
+	out, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, out)
+	// The first KiB is allowed by the initial burst, and each of the rest take a second.
+	require.GreaterOrEqual(t, time.Since(start), 1900*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	r = ratelimit.Reader(ctx, bytes.NewReader(data), ratelimit.NewBandwidth(1))
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, context.Canceled)
+}
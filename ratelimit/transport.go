@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// Class is the class of endpoints a request is sent to, each of which is limited separately.
+type Class int
+
+const (
+	// ClassAPI is the class of the Tidal API, and auth requests. It is the class of requests without a class.
+	ClassAPI Class = iota
+	// ClassStream is the class of the requests of track stream URLs.
+	ClassStream
+	// ClassMedia is the class of the downloads of covers, and track files.
+	ClassMedia
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassAPI:
+		return "api"
+	case ClassStream:
+		return "stream"
+	case ClassMedia:
+		return "media"
+	default:
+		return "unknown"
+	}
+}
+
+type classContextKey struct{}
+
+// WithClass returns a copy of ctx, the requests of which are limited as class.
+func WithClass(ctx context.Context, class Class) context.Context {
+	return context.WithValue(ctx, classContextKey{}, class)
+}
+
+func classOf(ctx context.Context) Class {
+	if class, ok := ctx.Value(classContextKey{}).(Class); ok {
+		return class
+	}
+
+	return ClassAPI
+}
+
+// Transport sends requests no faster than the limiter of their class allows, lowering its rate once a request
+// is throttled, and raising it on sustained success.
+type Transport struct {
+	next     http.RoundTripper
+	logger   zerolog.Logger
+	limiters map[Class]*Adaptive
+}
+
+// NewTransport returns a transport that sends requests through next, limited by the limiters of their classes.
+// Requests of classes without a limiter are not limited.
+func NewTransport(logger zerolog.Logger, next http.RoundTripper, limiters map[Class]*Adaptive) *Transport {
+	return &Transport{next: next, logger: logger, limiters: limiters}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	class := classOf(req.Context())
+	limiter := t.limiters[class]
+	if err := limiter.Wait(req.Context()); nil != err {
+		return nil, fmt.Errorf("wait for %s rate limit: %w", class, err)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if nil != err {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if limit, ok := limiter.Throttled(); ok {
+			t.logger.Warn().Stringer("class", class).Float64("rate", float64(limit)).Msg("Request was throttled. Lowered request rate")
+		}
+	case resp.StatusCode < http.StatusBadRequest:
+		limiter.Succeeded()
+	}
+
+	return resp, nil
+}
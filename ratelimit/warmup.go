@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// warmupRecheckInterval is how often a waiting operation checks whether the limit was raised over time.
+const warmupRecheckInterval = time.Second
+
+// Warmup limits the number of concurrent operations right after startup, so that resumed jobs do not burst
+// requests while nothing is cached yet. The limit starts at from, and ramps up linearly to to as time passes
+// until duration, or as operations start until requests, whichever is further. After that, operations are
+// not limited.
+type Warmup struct {
+	mu       sync.Mutex
+	start    time.Time
+	duration time.Duration
+	requests int
+	from     int
+	to       int
+	started  int
+	active   int
+	// released is closed, and replaced, once an operation is done, to wake up waiting ones.
+	released chan struct{}
+}
+
+// NewWarmup returns a warmup that starts now. It returns nil, which does not limit operations, if duration or
+// requests is not positive, or if from is not less than to.
+func NewWarmup(duration time.Duration, requests, from, to int) *Warmup {
+	if duration <= 0 || requests <= 0 || from >= to {
+		return nil
+	}
+
+	return &Warmup{
+		mu:       sync.Mutex{},
+		start:    time.Now(),
+		duration: duration,
+		requests: requests,
+		from:     max(from, 1),
+		to:       to,
+		started:  0,
+		active:   0,
+		released: make(chan struct{}),
+	}
+}
+
+// Acquire waits until the operation can be started, and returns the function that must be called once it is
+// done.
+func (w *Warmup) Acquire(ctx context.Context) (release func(), err error) {
+	if nil == w {
+		return func() {}, nil
+	}
+
+	for {
+		w.mu.Lock()
+		limit, done := w.limit(time.Now())
+		if done {
+			w.mu.Unlock()
+			return func() {}, nil
+		}
+
+		if w.active < limit {
+			w.active++
+			w.started++
+			w.mu.Unlock()
+
+			return sync.OnceFunc(w.release), nil
+		}
+		released := w.released
+		w.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		case <-time.After(warmupRecheckInterval):
+		}
+	}
+}
+
+func (w *Warmup) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.active--
+	close(w.released)
+	w.released = make(chan struct{})
+}
+
+// limit returns the number of operations that can run concurrently at now, and whether the warmup is over.
+func (w *Warmup) limit(now time.Time) (int, bool) {
+	progress := max(float64(now.Sub(w.start))/float64(w.duration), float64(w.started)/float64(w.requests))
+	if progress >= 1 {
+		return 0, true
+	}
+
+	return w.from + int(progress*float64(w.to-w.from)), false
+}
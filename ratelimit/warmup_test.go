@@ -0,0 +1,65 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/ratelimit"
+)
+
+func TestWarmup(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, ratelimit.NewWarmup(0, 10, 1, 4))
+	assert.Nil(t, ratelimit.NewWarmup(time.Minute, 0, 1, 4))
+	assert.Nil(t, ratelimit.NewWarmup(time.Minute, 10, 4, 4))
+
+	var disabled *ratelimit.Warmup
+	release, err := disabled.Acquire(t.Context())
+	require.NoError(t, err)
+	release()
+
+	w := ratelimit.NewWarmup(time.Hour, 4, 1, 3)
+
+	first, err := w.Acquire(t.Context())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err = w.Acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := w.Acquire(t.Context())
+		assert.NoError(t, err)
+		second()
+		close(acquired)
+	}()
+	first()
+	first()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("operation was not started after the previous one was done")
+	}
+
+	// Half of the requests are started, which raises the limit to 2.
+	third, err := w.Acquire(t.Context())
+	require.NoError(t, err)
+	fourth, err := w.Acquire(t.Context())
+	require.NoError(t, err)
+
+	// All requests are started, which ends the warmup.
+	fifth, err := w.Acquire(t.Context())
+	require.NoError(t, err)
+
+	third()
+	fourth()
+	fifth()
+}
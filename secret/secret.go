@@ -0,0 +1,165 @@
+// Package secret encrypts credentials and sessions at rest with a key derived from a passphrase.
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrPassphraseRequired = errors.New("data is encrypted, but no encryption passphrase is set")
+	ErrDecrypt            = errors.New("decrypt data: wrong passphrase, or corrupted data")
+)
+
+const (
+	// header prefixes encrypted data, so that data written in plaintext before encryption was enabled can still
+	// be told apart, and read.
+	header   = "tidalgram-secret-v1\n"
+	saltSize = 16
+	keySize  = 32
+	// iterations is the PBKDF2-HMAC-SHA256 iteration count recommended by OWASP.
+	iterations = 600_000
+)
+
+// Box encrypts and decrypts data using AES-256-GCM, with a key derived from a passphrase using PBKDF2. A nil Box
+// encrypts nothing, and only reads plaintext data.
+type Box struct {
+	passphrase string
+	// salt is the salt of the key new data is encrypted with.
+	salt []byte
+
+	mu sync.Mutex
+	// keys caches the keys derived from each salt, as deriving keys is slow by design.
+	keys map[string][]byte
+}
+
+// New returns a Box keyed from passphrase, or nil if passphrase is empty.
+func New(passphrase string) (*Box, error) {
+	if passphrase == "" {
+		return nil, nil
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); nil != err {
+		return nil, fmt.Errorf("generate salt: %v", err)
+	}
+
+	return &Box{
+		passphrase: passphrase,
+		salt:       salt,
+		mu:         sync.Mutex{},
+		keys:       make(map[string][]byte),
+	}, nil
+}
+
+// Enabled reports whether b encrypts data.
+func (b *Box) Enabled() bool {
+	return nil != b
+}
+
+// IsSealed reports whether data was encrypted by a Box.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header))
+}
+
+// Seal encrypts plaintext. A nil Box returns plaintext as is.
+func (b *Box) Seal(plaintext []byte) ([]byte, error) {
+	if nil == b {
+		return plaintext, nil
+	}
+
+	aead, err := b.aead(b.salt)
+	if nil != err {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); nil != err {
+		return nil, fmt.Errorf("generate nonce: %v", err)
+	}
+
+	out := make([]byte, 0, len(header)+saltSize+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, header...)
+	out = append(out, b.salt...)
+	out = append(out, nonce...)
+
+	return aead.Seal(out, nonce, plaintext, []byte(header)), nil
+}
+
+// Open decrypts data encrypted by Seal. Plaintext data is returned as is, so that files written before
+// encryption was enabled are still read. It returns ErrPassphraseRequired if b is nil, and data is encrypted.
+func (b *Box) Open(data []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(data, []byte(header))
+	if !ok {
+		return data, nil
+	}
+
+	if nil == b {
+		return nil, ErrPassphraseRequired
+	}
+
+	if len(rest) < saltSize {
+		return nil, ErrDecrypt
+	}
+	salt, rest := rest[:saltSize], rest[saltSize:]
+
+	aead, err := b.aead(salt)
+	if nil != err {
+		return nil, err
+	}
+
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(header))
+	if nil != err {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func (b *Box) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := b.key(salt)
+	if nil != err {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if nil != err {
+		return nil, fmt.Errorf("create cipher: %v", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if nil != err {
+		return nil, fmt.Errorf("create gcm: %v", err)
+	}
+
+	return aead, nil
+}
+
+func (b *Box) key(salt []byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if key, ok := b.keys[string(salt)]; ok {
+		return key, nil
+	}
+
+	key, err := pbkdf2.Key(sha256.New, b.passphrase, salt, iterations, keySize)
+	if nil != err {
+		return nil, fmt.Errorf("derive key: %v", err)
+	}
+	b.keys[string(salt)] = key
+
+	return key, nil
+}
@@ -0,0 +1,71 @@
+package secret_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/secret"
+)
+
+func TestBox(t *testing.T) {
+	t.Parallel()
+
+	box, err := secret.New("correct horse battery staple")
+	require.NoError(t, err)
+
+	sealed, err := box.Seal([]byte(`{"token":"abc"}`))
+	require.NoError(t, err)
+	assert.True(t, secret.IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "abc")
+
+	opened, err := box.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"abc"}`, string(opened))
+
+	// Keys are derived from the salt stored along with the data, hence other boxes with the same passphrase
+	// open it too.
+	other, err := secret.New("correct horse battery staple")
+	require.NoError(t, err)
+	opened, err = other.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"abc"}`, string(opened))
+
+	wrong, err := secret.New("wrong passphrase")
+	require.NoError(t, err)
+	_, err = wrong.Open(sealed)
+	require.ErrorIs(t, err, secret.ErrDecrypt)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = box.Open(sealed)
+	require.ErrorIs(t, err, secret.ErrDecrypt)
+}
+
+func TestNilBox(t *testing.T) {
+	t.Parallel()
+
+	box, err := secret.New("")
+	require.NoError(t, err)
+	assert.False(t, box.Enabled())
+
+	sealed, err := box.Seal([]byte("plain"))
+	require.NoError(t, err)
+	assert.Equal(t, "plain", string(sealed))
+
+	opened, err := box.Open([]byte("plain"))
+	require.NoError(t, err)
+	assert.Equal(t, "plain", string(opened))
+
+	encrypted, err := secret.New("passphrase")
+	require.NoError(t, err)
+	sealed, err = encrypted.Seal([]byte("plain"))
+	require.NoError(t, err)
+	_, err = box.Open(sealed)
+	require.ErrorIs(t, err, secret.ErrPassphraseRequired)
+
+	// Plaintext data written before encryption was enabled is read as is.
+	opened, err = encrypted.Open([]byte("plain"))
+	require.NoError(t, err)
+	assert.Equal(t, "plain", string(opened))
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+const (
+	s3Algorithm = "AWS4-HMAC-SHA256"
+	// s3UnsignedPayload skips hashing request bodies, so that files are streamed instead of read twice.
+	s3UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	s3SignedHeaders    = "host;x-amz-content-sha256;x-amz-date"
+	s3DateLayout       = "20060102"
+	s3DateTimeLayout   = "20060102T150405Z"
+	s3UnreservedSymbol = "-._~"
+)
+
+// S3 stores files as objects of an S3 compatible object storage bucket. Requests are signed using AWS
+// Signature Version 4.
+type S3 struct {
+	conf   config.BotJanitorMirrorS3
+	client *http.Client
+}
+
+func NewS3(conf config.BotJanitorMirrorS3, client *http.Client) *S3 {
+	return &S3{conf: conf, client: client}
+}
+
+func (s *S3) Put(ctx context.Context, key, path string) error {
+	f, size, err := openFile(path)
+	if nil != err {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, f)
+	if nil != err {
+		return errors.Join(err, f.Close())
+	}
+	req.ContentLength = size
+
+	if err := doRequest(s.client, req, http.StatusOK); nil != err {
+		return fmt.Errorf("put object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if nil != err {
+		return err
+	}
+
+	if err := doRequest(s.client, req, http.StatusOK, http.StatusNoContent, http.StatusNotFound); nil != err {
+		return fmt.Errorf("delete object: %w", err)
+	}
+
+	return nil
+}
+
+// newRequest returns a signed request to the object with key.
+func (s *S3) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	endpoint := strings.TrimSuffix(s.conf.Endpoint, "/")
+	scheme, host, _ := strings.Cut(endpoint, "://")
+
+	objectPath := "/" + s3EscapePath(key)
+	if s.conf.PathStyle {
+		objectPath = "/" + s3EscapePath(s.conf.Bucket) + objectPath
+	} else {
+		host = s.conf.Bucket + "." + host
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, scheme+"://"+host+objectPath, body)
+	if nil != err {
+		return nil, fmt.Errorf("create request: %v", err)
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format(s3DateTimeLayout)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		objectPath,
+		"",
+		"host:" + host,
+		"x-amz-content-sha256:" + s3UnsignedPayload,
+		"x-amz-date:" + amzDate,
+		"",
+		s3SignedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := now.Format(s3DateLayout) + "/" + s.conf.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.conf.SecretAccessKey), now.Format(s3DateLayout))
+	for _, part := range []string{s.conf.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set(
+		"Authorization",
+		s3Algorithm+" Credential="+s.conf.AccessKeyID+"/"+scope+", SignedHeaders="+s3SignedHeaders+", Signature="+signature,
+	)
+
+	return req, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// s3EscapePath escapes each segment of the slash-separated path as AWS Signature Version 4 requires, i.e.,
+// everything but unreserved characters is percent-encoded.
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '/', strings.IndexByte(s3UnreservedSymbol, c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+// maxErrorBodySize is the maximum number of bytes of error responses included in errors.
+const maxErrorBodySize = 1024
+
+// Backend stores files by their slash-separated keys.
+type Backend interface {
+	// Put stores the file at path as key, replacing it if it already exists.
+	Put(ctx context.Context, key, path string) error
+	// Delete deletes key. Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the configured mirror storage backend, or nil if mirroring is disabled.
+func New(conf config.BotJanitorMirror, client *http.Client) Backend {
+	switch conf.Backend {
+	case config.MirrorBackendS3:
+		return NewS3(conf.S3, client)
+	case config.MirrorBackendWebDAV:
+		return NewWebDAV(conf.WebDAV, client)
+	default:
+		return nil
+	}
+}
+
+// openFile opens the file at path to be sent as a request body, and returns its size.
+func openFile(path string) (*os.File, int64, error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return nil, 0, fmt.Errorf("open file: %v", err)
+	}
+
+	stat, err := f.Stat()
+	if nil != err {
+		return nil, 0, errors.Join(fmt.Errorf("stat file: %v", err), f.Close())
+	}
+
+	return f, stat.Size(), nil
+}
+
+// doRequest sends req, and returns an error holding the beginning of the response body if its status is not
+// one of okStatuses.
+func doRequest(client *http.Client, req *http.Request, okStatuses ...int) (err error) {
+	resp, err := client.Do(req)
+	if nil != err {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close response body: %v", closeErr))
+		}
+	}()
+
+	for _, status := range okStatuses {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if nil != err {
+		return fmt.Errorf("unexpected status code %d, and read response body: %v", resp.StatusCode, err)
+	}
+
+	return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
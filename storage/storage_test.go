@@ -0,0 +1,138 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/storage"
+)
+
+type request struct {
+	method string
+	path   string
+	body   string
+	auth   string
+}
+
+func newServer(t *testing.T, status func(r *http.Request) int) (*httptest.Server, func() []request) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		requests []request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		mu.Lock()
+		requests = append(requests, request{method: r.Method, path: r.URL.EscapedPath(), body: string(body), auth: r.Header.Get("Authorization")})
+		mu.Unlock()
+
+		w.WriteHeader(status(r))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return requests
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "track")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestWebDAV(t *testing.T) {
+	t.Parallel()
+
+	srv, requests := newServer(t, func(r *http.Request) int {
+		switch r.Method {
+		case "MKCOL":
+			if r.URL.Path == "/dav/Artist/" {
+				return http.StatusMethodNotAllowed
+			}
+
+			return http.StatusCreated
+		case http.MethodPut:
+			return http.StatusCreated
+		default:
+			return http.StatusNotFound
+		}
+	})
+
+	conf := config.BotJanitorMirrorWebDAV{URL: srv.URL + "/dav/", Username: "user", Password: "pass"}
+	backend := storage.NewWebDAV(conf, srv.Client())
+
+	require.NoError(t, backend.Put(context.Background(), "Artist/Album (2024)/01 Title.flac", writeFile(t, "flac")))
+	require.NoError(t, backend.Delete(context.Background(), "Artist/Album (2024)/01 Title.flac"))
+
+	got := requests()
+	require.Len(t, got, 4)
+	assert.Equal(t, "MKCOL", got[0].method)
+	assert.Equal(t, "/dav/Artist/", got[0].path)
+	assert.Equal(t, "MKCOL", got[1].method)
+	assert.Equal(t, "/dav/Artist/Album%20%282024%29/", got[1].path)
+	assert.Equal(t, http.MethodPut, got[2].method)
+	assert.Equal(t, "/dav/Artist/Album%20%282024%29/01%20Title.flac", got[2].path)
+	assert.Equal(t, "flac", got[2].body)
+	assert.True(t, strings.HasPrefix(got[2].auth, "Basic "))
+	assert.Equal(t, http.MethodDelete, got[3].method)
+}
+
+func TestS3(t *testing.T) {
+	t.Parallel()
+
+	srv, requests := newServer(t, func(r *http.Request) int {
+		if r.Method == http.MethodPut {
+			return http.StatusOK
+		}
+
+		return http.StatusNoContent
+	})
+
+	conf := config.BotJanitorMirrorS3{
+		Endpoint:        srv.URL,
+		Region:          "us-east-1",
+		Bucket:          "music",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+	backend := storage.NewS3(conf, srv.Client())
+
+	require.NoError(t, backend.Put(context.Background(), "tidalgram/Album (2024)/01 Title.flac", writeFile(t, "flac")))
+	require.NoError(t, backend.Delete(context.Background(), "tidalgram/Album (2024)/01 Title.flac"))
+
+	got := requests()
+	require.Len(t, got, 2)
+	assert.Equal(t, http.MethodPut, got[0].method)
+	assert.Equal(t, "/music/tidalgram/Album%20%282024%29/01%20Title.flac", got[0].path)
+	assert.Equal(t, "flac", got[0].body)
+	assert.True(t, strings.HasPrefix(got[0].auth, "AWS4-HMAC-SHA256 Credential=AKID/"))
+	assert.Contains(t, got[0].auth, "/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=")
+	assert.Equal(t, http.MethodDelete, got[1].method)
+
+	srv, _ = newServer(t, func(*http.Request) int { return http.StatusForbidden })
+	conf.Endpoint = srv.URL
+	backend = storage.NewS3(conf, srv.Client())
+	require.Error(t, backend.Delete(context.Background(), "key"))
+}
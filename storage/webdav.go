@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+const methodMkcol = "MKCOL"
+
+// WebDAV stores files on a WebDAV server, e.g., one served by rclone, creating their parent collections as needed.
+type WebDAV struct {
+	conf   config.BotJanitorMirrorWebDAV
+	client *http.Client
+}
+
+func NewWebDAV(conf config.BotJanitorMirrorWebDAV, client *http.Client) *WebDAV {
+	return &WebDAV{conf: conf, client: client}
+}
+
+func (w *WebDAV) Put(ctx context.Context, key, path string) error {
+	segments := strings.Split(key, "/")
+	for i := range segments[:len(segments)-1] {
+		collection := strings.Join(segments[:i+1], "/") + "/"
+		req, err := w.newRequest(ctx, methodMkcol, collection, nil)
+		if nil != err {
+			return err
+		}
+
+		// Existing collections are reported as not allowed to be created.
+		if err := doRequest(w.client, req, http.StatusCreated, http.StatusMethodNotAllowed); nil != err {
+			return fmt.Errorf("create collection %s: %w", collection, err)
+		}
+	}
+
+	f, size, err := openFile(path)
+	if nil != err {
+		return err
+	}
+
+	req, err := w.newRequest(ctx, http.MethodPut, key, f)
+	if nil != err {
+		return errors.Join(err, f.Close())
+	}
+	req.ContentLength = size
+
+	if err := doRequest(w.client, req, http.StatusOK, http.StatusCreated, http.StatusNoContent); nil != err {
+		return fmt.Errorf("put file: %w", err)
+	}
+
+	return nil
+}
+
+func (w *WebDAV) Delete(ctx context.Context, key string) error {
+	req, err := w.newRequest(ctx, http.MethodDelete, key, nil)
+	if nil != err {
+		return err
+	}
+
+	if err := doRequest(w.client, req, http.StatusOK, http.StatusNoContent, http.StatusNotFound); nil != err {
+		return fmt.Errorf("delete file: %w", err)
+	}
+
+	return nil
+}
+
+func (w *WebDAV) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(w.conf.URL, "/")+"/"+strings.Join(segments, "/"), body)
+	if nil != err {
+		return nil, fmt.Errorf("create request: %v", err)
+	}
+
+	if len(w.conf.Username) > 0 {
+		req.SetBasicAuth(w.conf.Username, w.conf.Password)
+	}
+
+	return req, nil
+}
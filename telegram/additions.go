@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// sendAdditionsHeader announces the tracks added to the album with id, replying to the album post in peer if it
+// is known. It returns the ID of the message the added tracks should reply to.
+func (u *Uploader) sendAdditionsHeader(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	id string,
+	info *types.StoredAlbum,
+) (int, error) {
+	var count int
+	for _, trackIDs := range info.VolumeTrackIDs {
+		count += len(trackIDs)
+	}
+
+	const notCollapsed = false
+	text := []message.StyledTextOption{
+		styling.Bold("🆕 Deluxe additions"),
+		styling.Plain("\n"),
+		styling.Blockquote(info.Album.Title+" ("+info.Album.ReleaseDate.Format(types.ReleaseDateLayout)+")", notCollapsed),
+		styling.Italic(strconv.Itoa(count) + " new track(s)"),
+	}
+
+	sender := newSender(u.client, peer)
+
+	post, err := u.storage.LoadAlbumPost(peerKey(peer.InputPeer), id)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to load album post. Sending additions without replying to it")
+	} else if nil == post {
+		logger.Warn().Msg("Album post not found. Sending additions without replying to it")
+	} else {
+		sender = sender.Reply(post.MessageID)
+	}
+
+	updates, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return sender.StyledText(ctx, text...)
+	})
+	if nil != err {
+		return 0, fmt.Errorf("send additions header: %w", err)
+	}
+
+	time.Sleep(u.pause(1))
+
+	if nil != post {
+		return post.MessageID, nil
+	}
+
+	return firstMessageID(updates), nil
+}
+
+// recordAlbumPost stores the first message of updates as the post of the album with id in peer, so that its
+// additions can reply to it later.
+func (u *Uploader) recordAlbumPost(logger zerolog.Logger, peer uploadPeer, id string, updates tg.UpdatesClass) {
+	msgID := firstMessageID(updates)
+	if msgID == 0 {
+		logger.Warn().Msg("Sent album message not found in updates. Skipping recording album post")
+		return
+	}
+
+	post := StoredPost{MessageID: msgID, PostedAt: time.Now().UTC()}
+	if err := u.storage.StoreAlbumPost(peerKey(peer.InputPeer), id, post); nil != err {
+		logger.Error().Err(err).Msg("Failed to record album post")
+	}
+}
+
+// firstMessageID returns the lowest ID of the messages sent in updates, or 0 if there is none.
+func firstMessageID(updates tg.UpdatesClass) int {
+	if short, ok := updates.(*tg.UpdateShortSentMessage); ok {
+		return short.ID
+	}
+
+	msgs := sentMessages(updates, false)
+	if len(msgs) == 0 {
+		return 0
+	}
+
+	return msgs[0].ID
+}
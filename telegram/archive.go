@@ -0,0 +1,347 @@
+package telegram
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/telegram/progress"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const (
+	archiveMIME     = "application/zip"
+	archiveCoverKey = "cover.jpg"
+	archiveNFOKey   = "album.nfo"
+)
+
+// uploadAlbumArchive uploads the album tracks and cover as a ZIP archive, split into numbered parts
+// if it is larger than the configured part size.
+func (u *Uploader) uploadAlbumArchive(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	id string,
+) (err error) {
+	albumFs := dir.Album(id)
+
+	info, err := albumFs.InfoFile.Read()
+	if nil != err {
+		return fmt.Errorf("read album info file: %v", err)
+	}
+
+	partSize := int64(u.conf.Upload.Archive.PartSizeMB) * 1024 * 1024
+	paths, err := writeAlbumArchive(albumFs, info, filepath.Join(albumFs.DirPath, id+".zip"), partSize, u.conf.Upload.Archive.NFO)
+	if nil != err {
+		return fmt.Errorf("write album archive: %v", err)
+	}
+	defer func() {
+		for _, path := range paths {
+			if removeErr := os.Remove(path); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+				logger.Error().Err(removeErr).Str("path", path).Msg("Failed to remove album archive part")
+			}
+		}
+	}()
+
+	monitor := progress.NewAlbumMonitor(len(paths))
+	for i, path := range paths {
+		stat, err := os.Stat(path)
+		if nil != err {
+			return fmt.Errorf("stat album archive part: %v", err)
+		}
+		monitor.Set(i, &progress.Track{Size: stat.Size()})
+	}
+
+	typingWait := make(chan struct{})
+	go u.keepTyping(ctx, peer, monitor, typingWait, logger)
+
+	files := make([]tg.InputFileClass, len(paths))
+	for i, path := range paths {
+		if files[i], err = u.uploadFile(ctx, logger, path, monitor.At(i)); nil != err {
+			return newUploadError(nil, fmt.Errorf("upload album archive part %d: %w", i+1, err))
+		}
+	}
+
+	select {
+	case <-typingWait:
+	case <-ctx.Done():
+		return fmt.Errorf("wait for typing: %w", ctx.Err())
+	}
+
+	// All parts of the archive are captioned with the same signature.
+	peer = peer.forPost()
+	name := archiveName(info.Album)
+	for i, file := range files {
+		fileName := name
+		if len(files) > 1 {
+			fileName = partName(name, i)
+		}
+
+		doc := message.
+			UploadedDocument(file, archiveCaption(peer, info.Album, i, len(files))...).
+			MIME(archiveMIME).
+			Attributes(&tg.DocumentAttributeFilename{FileName: fileName})
+
+		sender := newSender(u.client, peer)
+		updates, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+			return sender.Media(ctx, doc)
+		})
+		if nil != err {
+			u.forgetStaleFiles(logger, err)
+			return newUploadError(nil, fmt.Errorf("send album archive part %d: %w", i+1, err))
+		}
+		if i == 0 {
+			u.recordAlbumPost(logger, peer, id, updates)
+			peer.posts.record(peer, updates)
+		}
+
+		time.Sleep(u.pause(1))
+	}
+
+	return nil
+}
+
+func archiveCaption(peer uploadPeer, album types.StoredAlbumMeta, part, parts int) []message.StyledTextOption {
+	const notCollapsed = false
+	caption := []message.StyledTextOption{
+		styling.Blockquote(album.Title+" ("+album.ReleaseDate.Format(types.ReleaseDateLayout)+")", notCollapsed),
+	}
+	if parts > 1 {
+		caption = append(
+			caption,
+			styling.Plain("\n"),
+			styling.Italic("Part "+strconv.Itoa(part+1)+" / "+strconv.Itoa(parts)),
+		)
+	}
+	if sig := peer.signature; len(sig) > 0 {
+		caption = append(caption, html.String(nil, sig))
+	}
+
+	return caption
+}
+
+func archiveName(album types.StoredAlbumMeta) string {
+	return fmt.Sprintf("%s - %s (%d).zip", album.Artist, album.Title, album.ReleaseDate.Year())
+}
+
+func partName(name string, i int) string {
+	return fmt.Sprintf("%s.%03d", name, i+1)
+}
+
+// writeAlbumArchive writes the album tracks, cover, and optionally an NFO file, to a ZIP archive at path,
+// and returns the paths of the written files. Archives larger than partSize are split into numbered parts
+// that can be joined back by concatenating them in order.
+func writeAlbumArchive(albumFs fs.Album, info *types.StoredAlbum, path string, partSize int64, nfo bool) (paths []string, err error) {
+	w := &splitWriter{base: path, limit: partSize, paths: nil, f: nil, n: 0}
+	defer func() {
+		if nil != err {
+			for _, p := range w.paths {
+				_ = os.Remove(p)
+			}
+		}
+	}()
+
+	zw := zip.NewWriter(w)
+	modified := info.Album.ReleaseDate
+
+	tracks := make([]types.StoredAlbumTrack, 0)
+	for volIdx, trackIDs := range info.VolumeTrackIDs {
+		for _, trackID := range trackIDs {
+			track := albumFs.Track(volIdx+1, trackID)
+
+			trackInfo, err := track.InfoFile.Read()
+			if nil != err {
+				return nil, fmt.Errorf("read album track info file: %v", err)
+			}
+			tracks = append(tracks, *trackInfo)
+
+			name := trackInfo.UploadFilename()
+			if len(info.VolumeTrackIDs) > 1 {
+				name = "Disc " + strconv.Itoa(volIdx+1) + "/" + name
+			}
+
+			if err := addArchiveFile(zw, name, track.Path, modified); nil != err {
+				return nil, fmt.Errorf("add album track %s: %v", trackID, err)
+			}
+
+			if exists, err := track.Lyrics.Exists(); nil != err {
+				return nil, fmt.Errorf("check if album track %s lyrics file exists: %v", trackID, err)
+			} else if exists {
+				lrcName := strings.TrimSuffix(name, filepath.Ext(name)) + ".lrc"
+				if err := addArchiveFile(zw, lrcName, track.Lyrics.Path, modified); nil != err {
+					return nil, fmt.Errorf("add album track %s lyrics: %v", trackID, err)
+				}
+			}
+		}
+	}
+
+	// Archives carry the original cover, if the embedded one is scaled down.
+	coverPath := albumFs.Cover.Path
+	if ok, err := albumFs.Cover.HasOriginal(); nil != err {
+		return nil, fmt.Errorf("check if original album cover exists: %v", err)
+	} else if ok {
+		coverPath = albumFs.Cover.OriginalPath()
+	}
+	if err := addArchiveFile(zw, archiveCoverKey, coverPath, modified); nil != err {
+		return nil, fmt.Errorf("add album cover: %v", err)
+	}
+
+	if nfo {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: archiveNFOKey, Method: zip.Deflate, Modified: modified}) //nolint:exhaustruct
+		if nil != err {
+			return nil, fmt.Errorf("create album nfo: %v", err)
+		}
+
+		if _, err := io.WriteString(f, albumNFO(info.Album, tracks)); nil != err {
+			return nil, fmt.Errorf("write album nfo: %v", err)
+		}
+	}
+
+	if err := zw.Close(); nil != err {
+		return nil, fmt.Errorf("close zip writer: %v", err)
+	}
+
+	if err := w.Close(); nil != err {
+		return nil, fmt.Errorf("close archive file: %v", err)
+	}
+
+	return w.paths, nil
+}
+
+func addArchiveFile(zw *zip.Writer, name, path string, modified time.Time) (err error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return fmt.Errorf("open file: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+		}
+	}()
+
+	// Audio files and covers are already compressed.
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified}) //nolint:exhaustruct
+	if nil != err {
+		return fmt.Errorf("create archive entry: %v", err)
+	}
+
+	if _, err := io.Copy(dst, f); nil != err {
+		return fmt.Errorf("copy file: %v", err)
+	}
+
+	return nil
+}
+
+func albumNFO(album types.StoredAlbumMeta, tracks []types.StoredAlbumTrack) string {
+	var sb strings.Builder
+	sb.WriteString("Artist:   " + album.Artist + "\n")
+	sb.WriteString("Album:    " + album.Title + "\n")
+	sb.WriteString("Released: " + album.ReleaseDate.Format(types.ReleaseDateLayout) + "\n")
+	sb.WriteString("Quality:  " + types.TrackQuality + "\n")
+	sb.WriteString("Tracks:   " + strconv.Itoa(len(tracks)) + "\n")
+
+	volume := 0
+	for _, t := range tracks {
+		if t.VolumeNumber != volume {
+			volume = t.VolumeNumber
+			sb.WriteString("\nDisc " + strconv.Itoa(volume) + "\n")
+		}
+
+		fmt.Fprintf(
+			&sb,
+			"%02d. %s - %s (%d:%02d)\n",
+			t.TrackNumber,
+			types.JoinArtists(t.Artists),
+			t.UploadTitle(),
+			t.Duration/60,
+			t.Duration%60,
+		)
+	}
+
+	return sb.String()
+}
+
+// splitWriter writes to numbered part files, e.g., base.001, base.002, etc., of at most limit bytes each.
+// If only one part was written on close, it is renamed to base.
+type splitWriter struct {
+	base  string
+	limit int64
+	paths []string
+	f     *os.File
+	n     int64
+}
+
+func (w *splitWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		if nil == w.f || w.n == w.limit {
+			if err := w.next(); nil != err {
+				return written, err
+			}
+		}
+
+		chunk := min(int64(len(p)), w.limit-w.n)
+		n, err := w.f.Write(p[:chunk])
+		written += n
+		w.n += int64(n)
+		if nil != err {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+
+	return written, nil
+}
+
+func (w *splitWriter) next() error {
+	if nil != w.f {
+		if err := w.f.Close(); nil != err {
+			return fmt.Errorf("close archive part: %v", err)
+		}
+	}
+
+	path := partName(w.base, len(w.paths))
+	f, err := os.Create(path)
+	if nil != err {
+		return fmt.Errorf("create archive part: %v", err)
+	}
+	w.f, w.n = f, 0
+	w.paths = append(w.paths, path)
+
+	return nil
+}
+
+func (w *splitWriter) Close() error {
+	if nil == w.f {
+		return nil
+	}
+
+	if err := w.f.Close(); nil != err {
+		return fmt.Errorf("close archive part: %v", err)
+	}
+	w.f = nil
+
+	if len(w.paths) == 1 {
+		if err := os.Rename(w.paths[0], w.base); nil != err {
+			return fmt.Errorf("rename archive part: %v", err)
+		}
+		w.paths[0] = w.base
+	}
+
+	return nil
+}
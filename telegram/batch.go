@@ -0,0 +1,177 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+)
+
+// sendBatch sends the media of batch as a media group to peer, and records the sent tracks in the upload
+// ledger. It returns the IDs of the tracks that were not sent if it fails.
+//
+// The batch is stored until it is sent, so that it can be sent by ResumePendingBatches if the process exits
+// before sending it.
+func (u *Uploader) sendBatch(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	batch StoredBatch,
+) ([]string, error) {
+	var (
+		trackIDs = lo.Map(batch.Media, func(m StoredBatchMedia, _ int) string { return m.TrackID })
+		reused   = lo.Map(batch.Media, func(m StoredBatchMedia, _ int) bool { return nil != m.Document })
+	)
+
+	// The stored peer keeps the signature picked for the media group, so that it is resumed with the same one.
+	peer = peer.forPost()
+	album, err := u.batchAlbum(peer, batch.Media)
+	if nil != err {
+		return trackIDs, fmt.Errorf("build media group: %v", err)
+	}
+
+	sender := newSender(u.client, peer)
+	if batch.ReplyTo != 0 {
+		sender = sender.Reply(batch.ReplyTo)
+	}
+
+	batch.Peer = newStoredPeer(peer)
+	if batch.CreatedAt.IsZero() {
+		batch.CreatedAt = time.Now().UTC()
+	}
+	key := batchKey(batch)
+	if err := u.storage.StoreBatch(key, batch); nil != err {
+		logger.Error().Err(err).Msg("Failed to store pending media group. It is lost if the process exits before sending it")
+	}
+	defer func() {
+		if err := u.storage.DeleteBatch(key); nil != err {
+			logger.Error().Err(err).Msg("Failed to delete sent media group from pending media groups")
+		}
+	}()
+
+	updates, unsent, err := u.sendMediaGroup(ctx, logger, peer, sender, trackIDs, album, reused)
+	if nil != err {
+		return unsent, err
+	}
+
+	if len(batch.AlbumID) > 0 {
+		u.recordAlbumPost(logger, peer, batch.AlbumID, updates)
+	}
+	peer.posts.record(peer, updates)
+
+	return nil, nil
+}
+
+// sendMediaGroup sends album, the documents of trackIDs, as a media group to peer using sender, and records the sent
+// tracks in the upload ledger. It returns the updates of the first sent message, or the IDs of the tracks that
+// were not sent if it fails.
+//
+// A media group that fails to send is repaired, rather than failing the whole batch. Its files are already
+// uploaded, and stored, by then, so it is sent once more reusing them, and if that fails too, the tracks that
+// are not sent yet are sent one by one. Failures caused by stale uploads are not repaired, as sending the same
+// files again fails the same way.
+func (u *Uploader) sendMediaGroup(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	sender *message.Builder,
+	trackIDs []string,
+	album []message.MultiMediaOption,
+	reused []bool,
+) (tg.UpdatesClass, []string, error) {
+	sendAlbum := func() (tg.UpdatesClass, error) { return sender.Album(ctx, album[0], album[1:]...) }
+
+	updates, err := u.sendFloodWaiting(ctx, logger, peer, sendAlbum)
+	if nil == err {
+		u.recordUploads(logger, trackIDs, updates)
+		return updates, nil, nil
+	}
+
+	if !u.repairable(ctx, logger, trackIDs, reused, err) {
+		return nil, trackIDs, err
+	}
+
+	logger.Warn().Err(err).Msg("Failed to send media group. Sending it again reusing uploaded files")
+	if err := sleepCtx(ctx, u.pause(len(trackIDs))); nil != err {
+		return nil, trackIDs, err
+	}
+
+	updates, err = u.sendFloodWaiting(ctx, logger, peer, sendAlbum)
+	if nil == err {
+		u.recordUploads(logger, trackIDs, updates)
+		return updates, nil, nil
+	}
+
+	if !u.repairable(ctx, logger, trackIDs, reused, err) {
+		return nil, trackIDs, err
+	}
+
+	logger.Warn().Err(err).Msg("Failed to send media group again. Sending tracks one by one")
+
+	var first tg.UpdatesClass
+	for i, doc := range album {
+		if err := sleepCtx(ctx, u.pause(1)); nil != err {
+			return nil, trackIDs[i:], err
+		}
+
+		updates, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+			return sender.Media(ctx, doc)
+		})
+		if nil != err {
+			u.forgetStaleUploads(logger, lo.Filter(trackIDs[i:], func(_ string, j int) bool { return reused[i+j] }), err)
+			u.forgetStaleFiles(logger, err)
+			logger.Error().Err(err).Str("track_id", trackIDs[i]).Msg("Failed to send track of media group")
+
+			return nil, trackIDs[i:], fmt.Errorf("send track %s: %w", trackIDs[i], err)
+		}
+		u.recordUploads(logger, trackIDs[i:i+1], updates)
+
+		if nil == first {
+			first = updates
+		}
+	}
+
+	return first, nil, nil
+}
+
+// repairable reports whether sending the media group of trackIDs that failed with err can be repaired. It
+// forgets the stale uploads and files err reports, if any.
+func (u *Uploader) repairable(
+	ctx context.Context,
+	logger zerolog.Logger,
+	trackIDs []string,
+	reused []bool,
+	err error,
+) bool {
+	if nil != ctx.Err() {
+		return false
+	}
+
+	u.forgetStaleUploads(logger, lo.Filter(trackIDs, func(_ string, i int) bool { return reused[i] }), err)
+	u.forgetStaleFiles(logger, err)
+
+	rpcErr, ok := tgerr.As(err)
+
+	return !ok || !isStaleUploadErrorType(rpcErr.Type)
+}
+
+// isStaleUploadErrorType reports whether an RPC error of typ is caused by uploaded files, or documents, that
+// are no longer available.
+func isStaleUploadErrorType(typ string) bool {
+	return strings.HasPrefix(typ, "FILE_PART") || strings.HasPrefix(typ, "FILE_REFERENCE_") || typ == "MEDIA_EMPTY"
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
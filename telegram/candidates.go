@@ -0,0 +1,115 @@
+package telegram
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+const (
+	// maxPeerCandidates is the maximum number of dialogs suggested for a configured peer that is not found.
+	maxPeerCandidates = 5
+	// maxPeerIDDistance is the maximum number of digit edits between the ID of a suggested dialog and the
+	// configured one, e.g., a mistyped, missing, or extra digit.
+	maxPeerIDDistance = 2
+	// minPeerIDPrefix is the minimum number of leading digits a suggested dialog ID shares with the configured
+	// one, if they are further apart than maxPeerIDDistance.
+	minPeerIDPrefix = 5
+)
+
+// ClosestPeers returns the dialogs whose IDs are the closest to the ID of the configured peer, the closest
+// first. Dialogs with the same ID but another kind are the closest, followed by the ones whose IDs are a few
+// digits apart, or share a long prefix. IDs copied from Bot API clients, e.g., -1001234567890 for channels, are
+// matched without their Bot API prefix.
+func ClosestPeers(peer config.TelegramUploadPeer, dialogs []Peer) []Peer {
+	id := configPeerID(peer.ID)
+
+	type candidate struct {
+		peer     Peer
+		distance int
+		prefix   int
+	}
+
+	var candidates []candidate
+	for _, d := range dialogs {
+		if d.ID == peer.ID && d.Kind == peer.Kind {
+			continue
+		}
+
+		dialogID := strconv.FormatInt(d.ID, 10)
+		c := candidate{peer: d, distance: digitsDistance(id, dialogID), prefix: commonPrefixLen(id, dialogID)}
+		if c.distance <= maxPeerIDDistance || c.prefix >= minPeerIDPrefix {
+			candidates = append(candidates, c)
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(
+			cmp.Compare(a.distance, b.distance),
+			cmp.Compare(b.prefix, a.prefix),
+			// Dialogs of the configured kind are the likelier ones among equally close IDs.
+			cmp.Compare(boolRank(a.peer.Kind != peer.Kind), boolRank(b.peer.Kind != peer.Kind)),
+		)
+	})
+
+	out := make([]Peer, 0, min(len(candidates), maxPeerCandidates))
+	for _, c := range candidates[:min(len(candidates), maxPeerCandidates)] {
+		out = append(out, c.peer)
+	}
+
+	return out
+}
+
+// configPeerID returns the digits of id as MTProto identifies the peer, i.e., without the sign and -100 prefix
+// Bot API IDs of chats and channels have.
+func configPeerID(id int64) string {
+	s := strconv.FormatInt(id, 10)
+	if after, ok := strings.CutPrefix(s, "-100"); ok && len(after) >= 10 {
+		return after
+	}
+
+	return strings.TrimPrefix(s, "-")
+}
+
+// digitsDistance returns the Levenshtein distance of a and b.
+func digitsDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// CaptionData holds the fields available to the upload caption template.
+type CaptionData struct {
+	Artist      string
+	Title       string
+	ReleaseDate string
+	Quality     string
+	TrackCount  int
+	TrackTitle  string
+	Disc        int
+	Track       int
+	// SkippedAudioMode is the spatial audio mode whose stereo version was uploaded instead, if any. It is noted
+	// after the rendered caption.
+	SkippedAudioMode string
+	// TrackRange describes the album tracks of the media group of the last track of the group, e.g., "Tracks
+	// 11–20 of 34". It is noted after the rendered caption, if set.
+	TrackRange string
+}
+
+func newCaptionData(album types.StoredAlbumMeta, track types.Track) CaptionData {
+	return CaptionData{
+		Artist:           album.Artist,
+		Title:            album.Title,
+		ReleaseDate:      album.ReleaseDate.Format(types.ReleaseDateLayout),
+		Quality:          types.TrackQuality,
+		TrackCount:       album.TotalTracks,
+		TrackTitle:       track.UploadTitle(),
+		Disc:             track.VolumeNumber,
+		Track:            track.TrackNumber,
+		SkippedAudioMode: track.SkippedAudioMode,
+		TrackRange:       "",
+	}
+}
+
+// albumTrackRange describes the album tracks from first to last, which are on the same disc, e.g., "Tracks
+// 11–20 of 34". Discs are only named for albums with multiple discs, whose track counts are left out, as track
+// numbers restart on every disc.
+func albumTrackRange(first, last CaptionData, discs int) string {
+	var out string
+	if discs > 1 {
+		out = "Disc " + strconv.Itoa(first.Disc) + " · "
+	}
+
+	if first.Track == last.Track {
+		out += "Track " + strconv.Itoa(first.Track)
+	} else {
+		out += "Tracks " + strconv.Itoa(first.Track) + "–" + strconv.Itoa(last.Track)
+	}
+
+	if discs <= 1 {
+		out += " of " + strconv.Itoa(first.TrackCount)
+	}
+
+	return out
+}
+
+// RenderCaption renders the caption template with data.
+func RenderCaption(tmpl *template.Template, data CaptionData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); nil != err {
+		return "", fmt.Errorf("execute caption template: %v", err)
+	}
+
+	return sb.String(), nil
+}
+
+func (u *Uploader) caption(peer uploadPeer, data CaptionData) ([]message.StyledTextOption, error) {
+	rendered, err := RenderCaption(u.tmpl, data)
+	if nil != err {
+		return nil, err
+	}
+
+	caption := []message.StyledTextOption{html.String(nil, rendered)}
+	if mode := data.SkippedAudioMode; len(mode) > 0 {
+		caption = append(caption, html.String(nil, "\n<i>🎧 "+audioModeName(mode)+" version skipped. Stereo uploaded.</i>"))
+	}
+	if r := data.TrackRange; len(r) > 0 {
+		caption = append(caption, html.String(nil, "\n<i>🔢 "+r+"</i>"))
+	}
+	if sig := peer.signature; len(sig) > 0 {
+		caption = append(caption, html.String(nil, sig))
+	}
+
+	return caption, nil
+}
+
+// audioModeName returns the display name of the Tidal audio mode.
+func audioModeName(mode string) string {
+	switch mode {
+	case "DOLBY_ATMOS":
+		return "Dolby Atmos"
+	case "SONY_360RA":
+		return "Sony 360 Reality Audio"
+	default:
+		return mode
+	}
+}
@@ -0,0 +1,64 @@
+package telegram_test
+
+import (
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/telegram"
+)
+
+func TestRenderCaption(t *testing.T) {
+	t.Parallel()
+
+	data := telegram.CaptionData{
+		Artist:      "Simon & Garfunkel",
+		Title:       "Bridge <Over> Troubled Water",
+		ReleaseDate: "1970/01/26",
+		Quality:     "HI_RES_LOSSLESS",
+		TrackCount:  11,
+		TrackTitle:  "The Boxer",
+		Disc:        1,
+		Track:       5,
+	}
+
+	tmpl := template.Must(template.New("caption").Parse(config.DefaultUploadCaption))
+	rendered, err := telegram.RenderCaption(tmpl, data)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"<blockquote>Bridge &lt;Over&gt; Troubled Water (1970/01/26)</blockquote>\n<i>Disc 1 / Track 5</i>",
+		rendered,
+	)
+
+	tmpl = template.Must(template.New("caption").Parse("{{.Artist}} - {{.TrackTitle}} [{{.Quality}}, {{.TrackCount}} tracks]"))
+	rendered, err = telegram.RenderCaption(tmpl, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Simon &amp; Garfunkel - The Boxer [HI_RES_LOSSLESS, 11 tracks]", rendered)
+}
+
+func TestSignatureRotation(t *testing.T) {
+	t.Parallel()
+
+	signatures := []string{"a", "b", "c"}
+	sunday := time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)
+
+	rotation := telegram.NewSignatureRotation(config.SignatureRotationRoundRobin, signatures)
+	picked := make([]string, 0, 4)
+	for range 4 {
+		picked = append(picked, rotation.Next(sunday))
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a"}, picked)
+
+	rotation = telegram.NewSignatureRotation(config.SignatureRotationWeekday, signatures)
+	assert.Equal(t, "a", rotation.Next(sunday))
+	assert.Equal(t, "b", rotation.Next(sunday.AddDate(0, 0, 1)))
+	assert.Equal(t, "a", rotation.Next(sunday.AddDate(0, 0, 3)))
+
+	rotation = telegram.NewSignatureRotation(config.SignatureRotationRandom, signatures)
+	assert.Contains(t, signatures, rotation.Next(sunday))
+}
@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+var ErrDestinationNotAllowed = errors.New("destination is not allowed")
+
+// AllowsDestination reports whether links can be uploaded to username instead of the configured peers.
+func (u *Uploader) AllowsDestination(username string) bool {
+	username = strings.TrimPrefix(username, "@")
+	for _, d := range u.conf.Upload.Destinations {
+		if strings.EqualFold(d, username) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Destinations returns the usernames links can be uploaded to instead of the configured peers.
+func (u *Uploader) Destinations() []string {
+	return lo.Map(u.conf.Upload.Destinations, func(d string, _ int) string { return strings.TrimPrefix(d, "@") })
+}
+
+func (u *Uploader) resolveDestination(ctx context.Context, username string) (uploadPeer, error) {
+	if !u.AllowsDestination(username) {
+		return uploadPeer{}, ErrDestinationNotAllowed //nolint:exhaustruct
+	}
+
+	resolved, err := resolveUsername(ctx, u.client, username)
+	if nil != err {
+		return uploadPeer{}, err //nolint:exhaustruct
+	}
+
+	return uploadPeer{
+		InputPeer: resolved.inputPeer(),
+		conf: config.TelegramUploadPeer{
+			ID:         resolved.ID,
+			Kind:       resolved.Kind,
+			Username:   "",
+			Invite:     "",
+			Topic:      0,
+			AutoDelete: config.Duration{Duration: 0},
+			Signature:  nil,
+		},
+		signature:  u.conf.Upload.Signature,
+		signatures: u.signatures,
+		username:   resolved.Username,
+		posts:      nil,
+		waits:      nil,
+		fits:       nil,
+		notify:     false,
+		scheduleAt: time.Time{},
+	}, nil
+}
+
+func lookupUser(users []tg.UserClass, id int64) (*tg.User, bool) {
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok && user.ID == id {
+			return user, true
+		}
+	}
+
+	return nil, false
+}
+
+func lookupChannel(chats []tg.ChatClass, id int64) (*tg.Channel, bool) {
+	for _, c := range chats {
+		if channel, ok := c.(*tg.Channel); ok && channel.ID == id {
+			return channel, true
+		}
+	}
+
+	return nil, false
+}
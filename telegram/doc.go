@@ -0,0 +1,8 @@
+// Package telegram uploads files to Telegram peers using a user account, i.e., MTProto, rather than a bot, so
+// that files of up to 4 GiB can be uploaded.
+//
+// An [Uploader] is created using [NewUploader] from a logged in session, see [Login]. It uploads the downloaded
+// Tidal links using [Uploader.Upload], and arbitrary audio files, regardless of where they come from, using
+// [Uploader.UploadMedia]. Uploaded files are deduplicated by their content, and failed uploads are resumed from
+// the parts uploaded before they failed.
+package telegram
@@ -0,0 +1,157 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/rs/zerolog"
+)
+
+// inputFileTTL is how long an uploaded file is reused for. Telegram only keeps the parts of uploaded files
+// for a limited time, after which they must be uploaded again.
+const inputFileTTL = time.Hour
+
+// fileProgress reports upload progress of a file, and can be marked as complete when the file is not uploaded.
+type fileProgress interface {
+	uploader.Progress
+	Complete()
+}
+
+// uploadFile uploads the file at path, unless a file with identical content was uploaded recently, in which
+// case the previously uploaded file is returned. Concurrent uploads of identical files are deduplicated.
+func (u *Uploader) uploadFile(
+	ctx context.Context,
+	logger zerolog.Logger,
+	path string,
+	progress fileProgress,
+) (tg.InputFileClass, error) {
+	if err := u.gate.Wait(ctx); nil != err {
+		return nil, fmt.Errorf("wait for paused job: %w", err)
+	}
+
+	hash, err := fileHash(path)
+	if nil != err {
+		return nil, fmt.Errorf("hash file: %v", err)
+	}
+
+	if stored, err := u.storage.LoadInputFile(hash); nil != err {
+		logger.Error().Err(err).Msg("Failed to load uploaded file. Uploading it again")
+	} else if nil != stored && time.Since(stored.UploadedAt) < inputFileTTL {
+		progress.Complete()
+		return stored.inputFile(), nil
+	}
+
+	v, err, shared := u.files.Do(hash, func() (any, error) {
+		file, err := u.uploadResumable(ctx, logger, path, hash, progress)
+		if nil != err {
+			return nil, err
+		}
+
+		if stored, ok := newStoredInputFile(file); !ok {
+			logger.Warn().Str("type", fmt.Sprintf("%T", file)).Msg("Unexpected uploaded file type. Not storing it")
+		} else if err := u.storage.StoreInputFile(hash, stored); nil != err {
+			logger.Error().Err(err).Msg("Failed to store uploaded file")
+		}
+
+		return file, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	if shared {
+		progress.Complete()
+	}
+
+	file, ok := v.(tg.InputFileClass)
+	if !ok {
+		panic(fmt.Sprintf("unexpected uploaded file type: %T", v))
+	}
+
+	return file, nil
+}
+
+// forgetStaleFiles removes all stored uploaded files if sending a message failed because the parts of an
+// uploaded file are no longer available.
+func (u *Uploader) forgetStaleFiles(logger zerolog.Logger, err error) {
+	rpcErr, ok := tgerr.As(err)
+	if !ok || !strings.HasPrefix(rpcErr.Type, "FILE_PART") {
+		return
+	}
+
+	if err := u.storage.ClearInputFiles(); nil != err {
+		logger.Error().Err(err).Msg("Failed to forget stale uploaded files")
+		return
+	}
+	logger.Warn().Str("error_type", rpcErr.Type).Msg("Forgot stale uploaded files. They will be uploaded again next time")
+}
+
+func newStoredInputFile(file tg.InputFileClass) (StoredInputFile, bool) {
+	switch f := file.(type) {
+	case *tg.InputFile:
+		return StoredInputFile{
+			ID:          f.ID,
+			Parts:       f.Parts,
+			Name:        f.Name,
+			MD5Checksum: f.MD5Checksum,
+			Big:         false,
+			UploadedAt:  time.Now().UTC(),
+		}, true
+	case *tg.InputFileBig:
+		return StoredInputFile{
+			ID:          f.ID,
+			Parts:       f.Parts,
+			Name:        f.Name,
+			MD5Checksum: "",
+			Big:         true,
+			UploadedAt:  time.Now().UTC(),
+		}, true
+	default:
+		return StoredInputFile{}, false //nolint:exhaustruct
+	}
+}
+
+func (f StoredInputFile) inputFile() tg.InputFileClass {
+	if f.Big {
+		return &tg.InputFileBig{
+			ID:    f.ID,
+			Parts: f.Parts,
+			Name:  f.Name,
+		}
+	}
+
+	return &tg.InputFile{
+		ID:          f.ID,
+		Parts:       f.Parts,
+		Name:        f.Name,
+		MD5Checksum: f.MD5Checksum,
+	}
+}
+
+func fileHash(path string) (out string, err error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return "", fmt.Errorf("open file: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+		}
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); nil != err {
+		return "", fmt.Errorf("read file: %v", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/events"
+)
+
+const (
+	// maxFloodWaits is the number of FLOOD_WAIT errors of a single request that are waited out before failing.
+	maxFloodWaits = 5
+	// floodWaitMaxJitter is the maximum extra time waited after the duration requested by FLOOD_WAIT, so that
+	// requests waiting for the same duration are not retried at once.
+	floodWaitMaxJitter = 3 * time.Second
+)
+
+// floodWaits accumulates the time waited on FLOOD_WAIT errors during an upload.
+type floodWaits struct {
+	total atomic.Int64
+}
+
+// add adds d to the total waited time, and returns the new total. It is a no-op on a nil receiver, e.g., for
+// media groups resumed on startup, and returns d.
+func (w *floodWaits) add(d time.Duration) time.Duration {
+	if nil == w {
+		return d
+	}
+
+	return time.Duration(w.total.Add(int64(d)))
+}
+
+// sendFloodWaiting calls send, and calls it again after waiting the requested duration, plus jitter, each time
+// it fails with a FLOOD_WAIT error the client middlewares did not wait out, up to maxFloodWaits times.
+func (u *Uploader) sendFloodWaiting(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	send func() (tg.UpdatesClass, error),
+) (tg.UpdatesClass, error) {
+	for attempt := 1; ; attempt++ {
+		updates, err := send()
+		if nil == err {
+			return updates, nil
+		}
+
+		d, ok := tgerr.AsFloodWait(err)
+		if !ok || attempt > maxFloodWaits {
+			return nil, err
+		}
+
+		wait := d + rand.N(floodWaitMaxJitter) //nolint:gosec
+		total := peer.waits.add(wait)
+		logger.
+			Warn().
+			Dur("wait", wait).
+			Dur("total_wait", total).
+			Int("attempt", attempt).
+			Msg("Got FLOOD_WAIT while sending message. Sending it again after waiting")
+		u.bus.Publish(events.Event{ //nolint:exhaustruct
+			Kind:             events.KindUploadFloodWait,
+			Peer:             peer.conf.Kind + "/" + strconv.FormatInt(peer.conf.ID, 10),
+			WaitSeconds:      int(wait.Seconds()),
+			TotalWaitSeconds: int(total.Seconds()),
+		})
+
+		if err := sleepCtx(ctx, wait); nil != err {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const (
+	lyricsFileMIME = "text/plain"
+	// maxGroupedDocuments is the maximum number of documents Telegram allows in a single grouped message.
+	maxGroupedDocuments = 10
+)
+
+// lyricsFile is the stored LRC file of an uploaded track, along with the name it is uploaded with.
+type lyricsFile struct {
+	path string
+	name string
+}
+
+// uploadLyricsFiles sends the LRC files of the link tracks with synced lyrics, grouped in as few messages as
+// possible. They are named after the uploaded track files so that players pick them up next to the tracks.
+func (u *Uploader) uploadLyricsFiles(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	link types.Link,
+) error {
+	files, err := readLyricsFiles(dir, link)
+	if nil != err {
+		return fmt.Errorf("read lyrics files: %v", err)
+	}
+
+	for batch := range slices.Chunk(files, maxGroupedDocuments) {
+		docs := make([]message.MultiMediaOption, len(batch))
+		for i, f := range batch {
+			file, err := u.uploadPath(ctx, u.newUploader(ctx), f.path)
+			if nil != err {
+				return newUploadError(nil, fmt.Errorf("upload lyrics file: %w", err))
+			}
+
+			docs[i] = message.
+				UploadedDocument(file).
+				MIME(lyricsFileMIME).
+				Attributes(&tg.DocumentAttributeFilename{FileName: f.name})
+		}
+
+		sender := newSender(u.client, peer)
+		_, err = u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+			return sender.Album(ctx, docs[0], docs[1:]...)
+		})
+		if nil != err {
+			return newUploadError(nil, fmt.Errorf("send lyrics files: %w", err))
+		}
+		logger.Debug().Int("count", len(batch)).Msg("Lyrics files sent")
+
+		time.Sleep(u.pause(1))
+	}
+
+	return nil
+}
+
+func readLyricsFiles(dir fs.DownloadsDir, link types.Link) ([]lyricsFile, error) {
+	var out []lyricsFile
+
+	add := func(lyrics fs.Lyrics, uploadFilename string) error {
+		if exists, err := lyrics.Exists(); nil != err {
+			return fmt.Errorf("check if lyrics file exists: %v", err)
+		} else if !exists {
+			return nil
+		}
+
+		name := strings.TrimSuffix(uploadFilename, filepath.Ext(uploadFilename)) + ".lrc"
+		out = append(out, lyricsFile{path: lyrics.Path, name: name})
+
+		return nil
+	}
+
+	addTracks := func(trackIDs []string, track func(id string) fs.Track) error {
+		for _, id := range trackIDs {
+			trackFs := track(id)
+			info, err := trackFs.InfoFile.Read()
+			if nil != err {
+				return fmt.Errorf("read track %s info file: %v", id, err)
+			}
+			if err := add(trackFs.Lyrics, info.UploadFilename()); nil != err {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// Custom link kinds are stored as playlists.
+	switch link := link.StoredAs(); link.Kind {
+	case types.LinkKindTrack:
+		if err := addTracks([]string{link.ID}, dir.Track); nil != err {
+			return nil, err
+		}
+	case types.LinkKindAlbum:
+		albumFs := dir.Album(link.ID)
+		info, err := albumFs.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read album info file: %v", err)
+		}
+		for volIdx, trackIDs := range info.VolumeTrackIDs {
+			for _, id := range trackIDs {
+				trackFs := albumFs.Track(volIdx+1, id)
+				trackInfo, err := trackFs.InfoFile.Read()
+				if nil != err {
+					return nil, fmt.Errorf("read track %s info file: %v", id, err)
+				}
+				if err := add(trackFs.Lyrics, trackInfo.UploadFilename()); nil != err {
+					return nil, err
+				}
+			}
+		}
+	case types.LinkKindPlaylist:
+		info, err := dir.Playlist(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
+		}
+		if err := addTracks(info.TrackIDs, dir.Playlist(link.ID).Track); nil != err {
+			return nil, err
+		}
+	case types.LinkKindMix:
+		info, err := dir.Mix(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+		if err := addTracks(info.TrackIDs, dir.Mix(link.ID).Track); nil != err {
+			return nil, err
+		}
+	case types.LinkKindArtistCredits:
+		info, err := dir.ArtistCredits(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read artist credits info file: %v", err)
+		}
+		if err := addTracks(info.TrackIDs, dir.ArtistCredits(link.ID).Track); nil != err {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported link kind: %s", link.Kind)
+	}
+
+	return out, nil
+}
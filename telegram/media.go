@@ -0,0 +1,183 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/mathutil"
+	"github.com/xeptore/tidalgram/telegram/progress"
+)
+
+// Media is an audio file to upload using [Uploader.UploadMedia], regardless of where, and how it was downloaded.
+type Media struct {
+	// Path is the path of the audio file.
+	Path string
+	// FileName is the name the file is sent with. It defaults to the base name of Path.
+	FileName string
+	// Thumb is the path of the JPEG thumbnail of the file, if set. Telegram only shows thumbnails of at most
+	// 320x320 pixels, and 200 KiB.
+	Thumb string
+	// Caption is the HTML caption of the file. The signature of the peer, if any, is appended to it.
+	Caption   string
+	Title     string
+	Performer string
+	// Duration is the duration of the audio in seconds.
+	Duration int
+}
+
+// UploadMedia uploads media to all configured peers, or to the destination of opts, if set, in order, as media
+// groups of about the same size. Files are only uploaded once, and are sent to the rest of the peers by reusing
+// the uploaded files. Only the Destination, Notify, and ScheduleAt options of opts apply. It returns the t.me
+// links of the posts sent to channels, in the order they were sent.
+func (u *Uploader) UploadMedia(
+	ctx context.Context,
+	logger zerolog.Logger,
+	media []Media,
+	opts UploadOptions,
+) ([]string, error) {
+	if len(media) == 0 {
+		return nil, nil
+	}
+
+	posts := &postLinks{urls: nil}
+	waits := new(floodWaits)
+	notify := u.conf.Upload.Notify || opts.Notify
+	defer func() {
+		if total := time.Duration(waits.total.Load()); total > 0 {
+			logger.Info().Dur("total_flood_wait", total).Msg("Waited on FLOOD_WAIT errors during upload")
+		}
+	}()
+
+	err := u.eachPeer(ctx, logger, opts.Destination, func(logger zerolog.Logger, peer uploadPeer) error {
+		peer.posts = posts
+		peer.waits = waits
+		peer.notify = notify
+		peer.scheduleAt = opts.ScheduleAt
+
+		return u.uploadMediaTo(ctx, logger, peer, media)
+	})
+	if nil != err {
+		return nil, withFloodCategory(u.checkUnauthorized(err))
+	}
+
+	return posts.urls, nil
+}
+
+func (u *Uploader) uploadMediaTo(ctx context.Context, logger zerolog.Logger, peer uploadPeer, media []Media) error {
+	batches := slices.Collect(slices.Chunk(media, mathutil.OptimalAlbumSize(len(media))))
+	for i, batch := range batches {
+		if i > 0 {
+			if err := sleepCtx(ctx, u.pause(len(batch))); nil != err {
+				return err
+			}
+		}
+
+		// The media group is captioned with the signature picked for it.
+		post := peer.forPost()
+		album := make([]message.MultiMediaOption, len(batch))
+		for j, m := range batch {
+			doc, err := u.mediaDocument(ctx, logger, post, m)
+			if nil != err {
+				return fmt.Errorf("upload media file %q: %w", m.Path, err)
+			}
+			album[j] = doc
+		}
+
+		sender := newSender(u.client, post)
+		updates, err := u.sendFloodWaiting(ctx, logger, post, func() (tg.UpdatesClass, error) {
+			return sender.Album(ctx, album[0], album[1:]...)
+		})
+		if nil != err {
+			u.forgetStaleFiles(logger, err)
+			return fmt.Errorf("send media group: %w", err)
+		}
+		peer.posts.record(peer, updates)
+	}
+
+	return nil
+}
+
+// mediaDocument uploads the file, and the thumbnail of m, and returns the document it is sent as to peer.
+func (u *Uploader) mediaDocument(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	m Media,
+) (message.MultiMediaOption, error) {
+	logger = logger.With().Str("path", m.Path).Logger()
+
+	stat, err := os.Lstat(m.Path)
+	if nil != err {
+		return nil, fmt.Errorf("stat file: %v", err)
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("file %q is not a regular file", m.Path)
+	}
+	if stat.Size() == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	mime, err := mimetype.DetectFile(m.Path)
+	if nil != err {
+		return nil, fmt.Errorf("detect mime: %v", err)
+	}
+
+	file, err := u.uploadFile(ctx, logger, m.Path, &progress.Track{Size: stat.Size()})
+	if nil != err {
+		return nil, fmt.Errorf("upload file: %w", err)
+	}
+
+	fileName := m.FileName
+	if len(fileName) == 0 {
+		fileName = filepath.Base(m.Path)
+	}
+
+	caption := []message.StyledTextOption{html.String(nil, m.Caption)}
+	if sig := peer.signature; len(sig) > 0 {
+		caption = append(caption, html.String(nil, sig))
+	}
+
+	doc := message.
+		UploadedDocument(file, caption...).
+		MIME(mime.String()).
+		Attributes(
+			&tg.DocumentAttributeFilename{
+				FileName: fileName,
+			},
+			//nolint:exhaustruct
+			&tg.DocumentAttributeAudio{
+				Title:     m.Title,
+				Performer: m.Performer,
+				Duration:  m.Duration,
+			})
+
+	if len(m.Thumb) > 0 {
+		thumbStat, err := os.Stat(m.Thumb)
+		if nil != err {
+			return nil, fmt.Errorf("stat thumbnail file: %v", err)
+		}
+
+		thumb, err := u.uploadFile(ctx, logger, m.Thumb, &progress.Track{Size: thumbStat.Size()})
+		if nil != err {
+			return nil, fmt.Errorf("upload thumbnail file: %w", err)
+		}
+		doc = doc.Thumb(thumb)
+	}
+
+	return doc.
+		Audio().
+		DurationSeconds(m.Duration).
+		Performer(m.Performer).
+		Title(m.Title), nil
+}
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/telegram/progress"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// maxPhotoSize is the maximum size of photos accepted by Telegram.
+const maxPhotoSize = 10 * 1024 * 1024
+
+// sendOriginalCover posts the original cover of the album as a photo, if the cover embedded in its tracks is
+// scaled down. Covers too large for photos are skipped.
+func (u *Uploader) sendOriginalCover(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	albumFs fs.Album,
+	album types.StoredAlbumMeta,
+) error {
+	if ok, err := albumFs.Cover.HasOriginal(); nil != err {
+		return fmt.Errorf("check if original album cover exists: %v", err)
+	} else if !ok {
+		return nil
+	}
+
+	path := albumFs.Cover.OriginalPath()
+	stat, err := os.Lstat(path)
+	if nil != err {
+		return fmt.Errorf("stat original album cover file: %v", err)
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("original album cover file %q is not a regular file", path)
+	}
+	if stat.Size() == 0 {
+		return errors.New("original album cover file is empty")
+	}
+	if stat.Size() > maxPhotoSize {
+		logger.Warn().Int64("size", stat.Size()).Msg("Original album cover is too large to be sent as a photo. Skipping it")
+		return nil
+	}
+
+	file, err := u.uploadFile(ctx, logger, path, &progress.Cover{Size: stat.Size()})
+	if nil != err {
+		return newUploadError(nil, fmt.Errorf("upload original album cover file: %w", err))
+	}
+
+	const notCollapsed = false
+	photo := message.UploadedPhoto(
+		file,
+		styling.Blockquote(album.Title+" ("+album.ReleaseDate.Format(types.ReleaseDateLayout)+")", notCollapsed),
+	)
+
+	sender := newSender(u.client, peer)
+	if _, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return sender.Media(ctx, photo)
+	}); nil != err {
+		u.forgetStaleFiles(logger, err)
+		return newUploadError(nil, fmt.Errorf("send original album cover: %w", err))
+	}
+
+	time.Sleep(u.pause(1))
+
+	return nil
+}
@@ -0,0 +1,376 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/telegram/progress"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const (
+	mb = 1024 * 1024
+	// splitSizeMargin is the fraction of the max file size track parts are cut at, as parts are cut at their
+	// duration rather than their size, and the bitrate of tracks varies.
+	splitSizeMargin = 0.9
+)
+
+// ErrTrackTooLarge is returned when a track is larger than the max upload file size, and the configured
+// oversized strategy neither skips it, nor fits it in the max file size.
+var ErrTrackTooLarge = errors.New("track is larger than the max upload file size")
+
+// OversizedTracks lists the IDs of the tracks of an upload that were larger than the max upload file size, by
+// how they were uploaded.
+type OversizedTracks struct {
+	Skipped    []string
+	Transcoded []string
+	Split      []string
+}
+
+// trackFit is how an oversized track is uploaded.
+type trackFit struct {
+	// path is the file uploaded instead of the track file, if the track is transcoded.
+	path string
+	// parts are the files of the track parts, in order, if the track is split. The track itself is not
+	// uploaded then.
+	parts []string
+	skip  bool
+	info  *types.Track
+}
+
+// trackFits holds how the oversized tracks of an upload are uploaded. It is shared by all peers the upload is
+// sent to, so that tracks are only transcoded or split once. A nil trackFits uploads all tracks as is, e.g., for
+// media groups resumed on startup.
+type trackFits struct {
+	mu     sync.Mutex
+	conf   config.TelegramUploadOversized
+	dir    fs.DownloadsDir
+	fits   map[string]*trackFit
+	split  []string
+	report *OversizedTracks
+}
+
+// newTrackFits returns the oversized tracks state of an upload from dir, which reports the oversized tracks to
+// report, if it is not nil.
+func newTrackFits(conf config.TelegramUploadOversized, dir fs.DownloadsDir, report *OversizedTracks) *trackFits {
+	return &trackFits{
+		mu:     sync.Mutex{},
+		conf:   conf,
+		dir:    dir,
+		fits:   make(map[string]*trackFit),
+		split:  nil,
+		report: report,
+	}
+}
+
+// fitAll decides how each of the tracks with ids is uploaded, if it is larger than the max file size, and
+// transcodes or splits it accordingly. Tracks that were already decided on are left as they are.
+func (f *trackFits) fitAll(ctx context.Context, logger zerolog.Logger, ids []string) error {
+	if nil == f {
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := f.fit(ctx, logger.With().Str("track_id", id).Logger(), id); nil != err {
+			return fmt.Errorf("fit track %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+func (f *trackFits) fit(ctx context.Context, logger zerolog.Logger, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.fits[id]; ok {
+		return nil
+	}
+
+	track := f.dir.TrackFile(id)
+	stat, err := os.Lstat(track.Path)
+	if nil != err {
+		return fmt.Errorf("stat track file: %v", err)
+	}
+
+	maxSize := f.conf.MaxFileSizeMB * mb
+	if stat.Size() <= maxSize {
+		return nil
+	}
+
+	info, err := track.InfoFile.Read()
+	if nil != err {
+		return fmt.Errorf("read track info file: %v", err)
+	}
+
+	logger = logger.With().Int64("size", stat.Size()).Int64("max_size", maxSize).Str("strategy", f.conf.Strategy).Logger()
+	logger.Warn().Msg("Track is larger than the max upload file size")
+
+	fit := &trackFit{path: "", parts: nil, skip: false, info: info}
+	switch f.conf.Strategy {
+	case config.OversizedSkip:
+		fit.skip = true
+		f.reportTrack(&f.report.Skipped, id)
+	case config.OversizedTranscode:
+		if fit.path, err = transcodeTrack(ctx, logger, track.Path, info.Ext); nil != err {
+			return fmt.Errorf("transcode track: %v", err)
+		}
+		if stat, err = os.Lstat(fit.path); nil != err {
+			return fmt.Errorf("stat transcoded track file: %v", err)
+		} else if stat.Size() > maxSize {
+			return fmt.Errorf("transcoded track is %d bytes: %w", stat.Size(), ErrTrackTooLarge)
+		}
+		f.reportTrack(&f.report.Transcoded, id)
+	case config.OversizedSplit:
+		segment := time.Duration(float64(info.Duration)*float64(maxSize)/float64(stat.Size())*splitSizeMargin) * time.Second
+		if fit.parts, err = splitTrack(ctx, logger, track.Path, info.Ext, max(segment, time.Second)); nil != err {
+			return fmt.Errorf("split track: %v", err)
+		}
+		for _, part := range fit.parts {
+			if stat, err := os.Lstat(part); nil != err {
+				return fmt.Errorf("stat track part file: %v", err)
+			} else if stat.Size() > maxSize {
+				return fmt.Errorf("track part is %d bytes: %w", stat.Size(), ErrTrackTooLarge)
+			}
+		}
+		f.split = append(f.split, id)
+		f.reportTrack(&f.report.Split, id)
+	default:
+		return fmt.Errorf("track is %d bytes: %w", stat.Size(), ErrTrackTooLarge)
+	}
+	f.fits[id] = fit
+
+	return nil
+}
+
+func (f *trackFits) reportTrack(ids *[]string, id string) {
+	if nil != f.report {
+		*ids = append(*ids, id)
+	}
+}
+
+// uploadable returns ids without the tracks that are skipped or split, which are not uploaded as is.
+func (f *trackFits) uploadable(ids []string) []string {
+	if nil == f {
+		return ids
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.DeleteFunc(slices.Clone(ids), func(id string) bool {
+		fit, ok := f.fits[id]
+		return ok && (fit.skip || len(fit.parts) > 0)
+	})
+}
+
+// path returns the path of the file uploaded for the track with id, whose file is at path.
+func (f *trackFits) path(id, path string) string {
+	if nil == f {
+		return path
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if fit, ok := f.fits[id]; ok && len(fit.path) > 0 {
+		return fit.path
+	}
+
+	return path
+}
+
+// remove removes the transcoded tracks and track parts.
+func (f *trackFits) remove(logger zerolog.Logger) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, fit := range f.fits {
+		for _, path := range append(slices.Clone(fit.parts), fit.path) {
+			if len(path) == 0 {
+				continue
+			}
+			if err := os.Remove(path); nil != err && !errors.Is(err, os.ErrNotExist) {
+				logger.Error().Err(err).Str("track_id", id).Str("path", path).Msg("Failed to remove oversized track file")
+			}
+		}
+	}
+}
+
+// sendTrackParts sends the parts of the split tracks as separate documents, in order.
+func (u *Uploader) sendTrackParts(ctx context.Context, logger zerolog.Logger, peer uploadPeer) error {
+	f := peer.fits
+	if nil == f {
+		return nil
+	}
+
+	f.mu.Lock()
+	split := slices.Clone(f.split)
+	f.mu.Unlock()
+
+	for _, id := range split {
+		f.mu.Lock()
+		fit := f.fits[id]
+		f.mu.Unlock()
+
+		logger := logger.With().Str("track_id", id).Logger()
+		for i, part := range fit.parts {
+			if err := u.sendTrackPart(ctx, logger, peer.forPost(), fit.info, part, i, len(fit.parts)); nil != err {
+				return fmt.Errorf("send track %s part %d: %w", id, i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (u *Uploader) sendTrackPart(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	info *types.Track,
+	path string,
+	part, parts int,
+) error {
+	stat, err := os.Lstat(path)
+	if nil != err {
+		return fmt.Errorf("stat track part file: %v", err)
+	}
+
+	file, err := u.uploadFile(ctx, logger, path, &progress.Track{Size: stat.Size()})
+	if nil != err {
+		return newUploadError(nil, fmt.Errorf("upload track part file: %w", err))
+	}
+
+	mime, err := mimetype.DetectFile(path)
+	if nil != err {
+		return fmt.Errorf("detect track part mime: %v", err)
+	}
+
+	const notCollapsed = false
+	partLabel := "Part " + strconv.Itoa(part+1) + " / " + strconv.Itoa(parts)
+	caption := []message.StyledTextOption{
+		styling.Blockquote(info.UploadTitle(), notCollapsed),
+		styling.Italic(partLabel),
+	}
+	if sig := peer.signature; len(sig) > 0 {
+		caption = append(caption, html.String(nil, sig))
+	}
+
+	name := info.UploadFilename()
+	doc := message.
+		UploadedDocument(file, caption...).
+		MIME(mime.String()).
+		Attributes(
+			&tg.DocumentAttributeFilename{FileName: name[:len(name)-len(filepath.Ext(name))] + fmt.Sprintf(".part%03d", part+1) + filepath.Ext(name)},
+			&tg.DocumentAttributeAudio{ //nolint:exhaustruct
+				Title:     info.UploadTitle() + " (" + partLabel + ")",
+				Performer: types.JoinArtists(info.Artists),
+			},
+		)
+
+	sender := newSender(u.client, peer)
+	if _, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return sender.Media(ctx, doc)
+	}); nil != err {
+		u.forgetStaleFiles(logger, err)
+		return newUploadError(nil, fmt.Errorf("send track part: %w", err))
+	}
+
+	time.Sleep(u.pause(1))
+
+	return nil
+}
+
+// transcodeTrack transcodes the track file at path with extension ext down to 16-bit 44.1 kHz FLAC, keeping
+// its container, tags, and cover, and returns the path of the transcoded file.
+func transcodeTrack(ctx context.Context, logger zerolog.Logger, path, ext string) (string, error) {
+	outPath := path + ".transcoded." + ext
+	args := []string{
+		"-hide_banner", "-y", "-i", path,
+		"-map", "0", "-map_metadata", "0", "-c", "copy",
+		"-c:a", "flac", "-sample_fmt", "s16", "-ar", "44100", "-strict", "experimental",
+		"-f", trackFormat(ext),
+		outPath,
+	}
+	if err := runFFmpeg(ctx, logger, args, outPath); nil != err {
+		return "", err
+	}
+
+	return outPath, nil
+}
+
+// splitTrack splits the track file at path with extension ext into parts of segment duration, without
+// re-encoding it, and returns the paths of the parts, in order.
+func splitTrack(ctx context.Context, logger zerolog.Logger, path, ext string, segment time.Duration) ([]string, error) {
+	pattern := path + ".part%03d." + ext
+	args := []string{
+		"-hide_banner", "-y", "-i", path,
+		"-map", "0:a:0", "-map_metadata", "0", "-c", "copy",
+		"-f", "segment", "-segment_time", strconv.Itoa(int(segment.Seconds())),
+		"-segment_format", trackFormat(ext), "-reset_timestamps", "1",
+		pattern,
+	}
+	if err := runFFmpeg(ctx, logger, args, ""); nil != err {
+		return nil, err
+	}
+
+	parts, err := filepath.Glob(path + ".part[0-9][0-9][0-9]." + ext)
+	if nil != err {
+		return nil, fmt.Errorf("glob track parts: %v", err)
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("ffmpeg wrote no track parts")
+	}
+	slices.Sort(parts)
+
+	return parts, nil
+}
+
+func trackFormat(ext string) string {
+	if ext == "flac" {
+		return "flac"
+	}
+
+	return "mp4"
+}
+
+// runFFmpeg runs ffmpeg with args, and removes outPath, if set, if it fails.
+func runFFmpeg(ctx context.Context, logger zerolog.Logger, args []string, outPath string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running ffmpeg for oversized track")
+	if err := cmd.Run(); nil != err {
+		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffmpeg failed for oversized track")
+		if len(outPath) > 0 {
+			if removeErr := os.Remove(outPath); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+				logger.Error().Err(removeErr).Msg("Failed to remove partially written track file")
+			}
+		}
+
+		return fmt.Errorf("run ffmpeg (%v): %s", err, stdErr.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package telegram
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+// pause returns how long to wait after sending a batch of batchSize tracks.
+func (u *Uploader) pause(batchSize int) time.Duration {
+	return pauseDuration(u.conf.Upload, batchSize, rand.Int64N)
+}
+
+// pauseDuration computes the pause after sending a batch of batchSize tracks, as described by
+// [config.TelegramUploadPacing]. randN returns a random number in [0, n).
+func pauseDuration(conf config.TelegramUpload, batchSize int, randN func(n int64) int64) time.Duration {
+	d := conf.PauseDuration.Duration + time.Duration(batchSize)*conf.Pacing.PerTrack.Duration
+	if jitter := conf.Pacing.Jitter.Duration; jitter > 0 {
+		d += time.Duration(randN(2*int64(jitter)+1)) - jitter
+	}
+
+	return max(d, 0)
+}
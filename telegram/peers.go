@@ -0,0 +1,142 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/query"
+	"github.com/gotd/td/telegram/query/dialogs"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/secret"
+)
+
+var ErrNotLoggedIn = errors.New("telegram client is not logged in")
+
+// Peer is a dialog of the logged-in user, with the ID and kind that identify it in the upload peers config.
+type Peer struct {
+	ID       int64
+	Kind     string
+	Title    string
+	Username string
+}
+
+// PeersFilter selects the peers listed by Peers. Empty fields match all peers.
+type PeersFilter struct {
+	// Kind is one of the upload peer kinds: user, chat, or channel.
+	Kind string
+	// Query is matched case-insensitively against peer titles and usernames.
+	Query string
+}
+
+func (f PeersFilter) Matches(p Peer) bool {
+	if len(f.Kind) > 0 && f.Kind != p.Kind {
+		return false
+	}
+
+	if q := strings.ToLower(f.Query); len(q) > 0 {
+		return strings.Contains(strings.ToLower(p.Title), q) || strings.Contains(strings.ToLower(p.Username), q)
+	}
+
+	return true
+}
+
+// Peers lists the dialogs of the logged-in user that match filter, in the order Telegram returns them, i.e.,
+// the most recently active first.
+func Peers(
+	ctx context.Context,
+	logger zerolog.Logger,
+	conf config.Telegram,
+	box *secret.Box,
+	filter PeersFilter,
+) (out []Peer, err error) {
+	storage, err := NewStorage(conf.Storage.Path, conf.Storage.Session, box)
+	if nil != err {
+		return nil, fmt.Errorf("create storage: %v", err)
+	}
+	defer func() {
+		if closeErr := storage.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close storage: %v", closeErr))
+		}
+	}()
+
+	opts, err := newClientOptions(ctx, logger, storage, conf)
+	if nil != err {
+		return nil, fmt.Errorf("get client options: %w", err)
+	}
+
+	opts.Middlewares = []telegram.Middleware{
+		newSimpleWaiterMiddleware(),
+	}
+
+	client := telegram.NewClient(conf.AppID, conf.AppHash, *opts)
+
+	err = client.Run(ctx, func(ctx context.Context) error {
+		status, err := client.Auth().Status(ctx)
+		if nil != err {
+			return fmt.Errorf("get auth status: %w", err)
+		}
+		if !status.Authorized {
+			return ErrNotLoggedIn
+		}
+
+		iter := query.GetDialogs(client.API()).BatchSize(100).Iter()
+		for iter.Next(ctx) {
+			elem := iter.Value()
+
+			p, ok := dialogPeer(elem)
+			if !ok {
+				continue
+			}
+
+			if filter.Matches(p) {
+				out = append(out, p)
+			}
+		}
+		if err := iter.Err(); nil != err {
+			return fmt.Errorf("iterate dialogs: %w", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("list peers: %w", err)
+	}
+
+	return out, nil
+}
+
+// dialogPeer returns the peer of the dialog elem. It returns false if the dialog entity is missing.
+func dialogPeer(elem dialogs.Elem) (Peer, bool) {
+	switch dp := elem.Dialog.GetPeer().(type) {
+	case *tg.PeerUser:
+		user, ok := elem.Entities.User(dp.UserID)
+		if !ok {
+			return Peer{}, false //nolint:exhaustruct
+		}
+		title := strings.TrimSpace(user.FirstName + " " + user.LastName)
+
+		return Peer{ID: user.ID, Kind: "user", Title: title, Username: user.Username}, true
+	case *tg.PeerChat:
+		chat, ok := elem.Entities.Chat(dp.ChatID)
+		if !ok {
+			return Peer{}, false //nolint:exhaustruct
+		}
+
+		return Peer{ID: chat.ID, Kind: "chat", Title: chat.Title, Username: ""}, true
+	case *tg.PeerChannel:
+		channel, ok := elem.Entities.Channel(dp.ChannelID)
+		if !ok {
+			return Peer{}, false //nolint:exhaustruct
+		}
+
+		return Peer{ID: channel.ID, Kind: "channel", Title: channel.Title, Username: channel.Username}, true
+	default:
+		return Peer{}, false //nolint:exhaustruct
+	}
+}
@@ -0,0 +1,57 @@
+package telegram_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/telegram"
+)
+
+func TestPeersFilterMatches(t *testing.T) {
+	t.Parallel()
+
+	channel := telegram.Peer{ID: 1234, Kind: "channel", Title: "Lossless Archive", Username: "flac_drops"}
+
+	assert.True(t, telegram.PeersFilter{Kind: "", Query: ""}.Matches(channel))
+	assert.True(t, telegram.PeersFilter{Kind: "channel", Query: "archive"}.Matches(channel))
+	assert.True(t, telegram.PeersFilter{Kind: "", Query: "FLAC"}.Matches(channel))
+	assert.False(t, telegram.PeersFilter{Kind: "user", Query: ""}.Matches(channel))
+	assert.False(t, telegram.PeersFilter{Kind: "channel", Query: "mp3"}.Matches(channel))
+}
+
+func TestClosestPeers(t *testing.T) {
+	t.Parallel()
+
+	dialogs := []telegram.Peer{
+		{ID: 1234567890, Kind: "channel", Title: "Lossless Archive", Username: "flac_drops"},
+		{ID: 1234567809, Kind: "channel", Title: "Hi-Res Drops", Username: ""},
+		{ID: 1234599999, Kind: "chat", Title: "Friends", Username: ""},
+		{ID: 987654321, Kind: "user", Title: "John Doe", Username: "john"},
+	}
+
+	titles := func(peers []telegram.Peer) []string {
+		out := make([]string, len(peers))
+		for i, p := range peers {
+			out[i] = p.Title
+		}
+
+		return out
+	}
+
+	// A mistyped digit.
+	peer := config.TelegramUploadPeer{ID: 1234567891, Kind: "channel", Signature: nil}
+	assert.Equal(t, []string{"Lossless Archive", "Hi-Res Drops", "Friends"}, titles(telegram.ClosestPeers(peer, dialogs)))
+
+	// A Bot API channel ID.
+	peer = config.TelegramUploadPeer{ID: -1001234567890, Kind: "channel", Signature: nil}
+	assert.Equal(t, []string{"Lossless Archive", "Hi-Res Drops", "Friends"}, titles(telegram.ClosestPeers(peer, dialogs)))
+
+	// The same ID of another kind.
+	peer = config.TelegramUploadPeer{ID: 987654321, Kind: "channel", Signature: nil}
+	assert.Equal(t, []string{"John Doe"}, titles(telegram.ClosestPeers(peer, dialogs)))
+
+	peer = config.TelegramUploadPeer{ID: 5555, Kind: "channel", Signature: nil}
+	assert.Empty(t, telegram.ClosestPeers(peer, dialogs))
+}
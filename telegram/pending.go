@@ -0,0 +1,212 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// ResumePendingBatches sends the media groups whose files were uploaded, but which were not sent because the
+// process exited before sending them. It is meant to be called on start, before new jobs run. Media groups
+// that fail to send are dropped, and their tracks are uploaded again the next time their links are sent.
+func (u *Uploader) ResumePendingBatches(ctx context.Context) error {
+	batches, err := u.storage.LoadBatches()
+	if nil != err {
+		return fmt.Errorf("load pending batches: %v", err)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(batches)) {
+		batch := batches[key]
+		logger := u.logger.With().Str("batch", key).Int("tracks", len(batch.Media)).Logger()
+
+		peer, err := batch.Peer.uploadPeer()
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to restore pending media group peer. Dropping it")
+			if err := u.storage.DeleteBatch(key); nil != err {
+				logger.Error().Err(err).Msg("Failed to delete pending media group")
+			}
+
+			continue
+		}
+
+		if _, err := u.sendBatch(ctx, logger, peer, batch); nil != err {
+			if nil != ctx.Err() {
+				return fmt.Errorf("send pending batch: %w", ctx.Err())
+			}
+			logger.Error().Err(err).Msg("Failed to send pending media group. Dropping it")
+
+			continue
+		}
+		logger.Info().Msg("Sent pending media group")
+	}
+
+	return nil
+}
+
+// batchKey returns the key batch is stored with. Keys sort in the order the batches are created.
+func batchKey(batch StoredBatch) string {
+	return fmt.Sprintf("%020d/%s/%d", batch.CreatedAt.UnixNano(), batch.Peer.Kind, batch.Peer.ID)
+}
+
+func newStoredPeer(peer uploadPeer) StoredPeer {
+	var accessHash int64
+	switch p := peer.InputPeerClass.(type) {
+	case *tg.InputPeerUser:
+		accessHash = p.AccessHash
+	case *tg.InputPeerChannel:
+		accessHash = p.AccessHash
+	}
+
+	return StoredPeer{
+		Kind:       peer.conf.Kind,
+		ID:         peer.conf.ID,
+		AccessHash: accessHash,
+		Signature:  peer.signature,
+		Topic:      peer.conf.Topic,
+		Notify:     peer.notify,
+		ScheduleAt: peer.scheduleAt,
+	}
+}
+
+func (p StoredPeer) uploadPeer() (uploadPeer, error) {
+	var inputPeer tg.InputPeerClass
+	switch p.Kind {
+	case "user":
+		inputPeer = &tg.InputPeerUser{UserID: p.ID, AccessHash: p.AccessHash}
+	case "chat":
+		inputPeer = &tg.InputPeerChat{ChatID: p.ID}
+	case "channel":
+		inputPeer = &tg.InputPeerChannel{ChannelID: p.ID, AccessHash: p.AccessHash}
+	default:
+		return uploadPeer{}, fmt.Errorf("unsupported peer kind: %s", p.Kind) //nolint:exhaustruct
+	}
+
+	return uploadPeer{
+		InputPeer: InputPeer{
+			InputPeerClass: inputPeer,
+			isChannel:      p.Kind == "channel",
+		},
+		conf: config.TelegramUploadPeer{
+			ID:         p.ID,
+			Kind:       p.Kind,
+			Username:   "",
+			Invite:     "",
+			Topic:      p.Topic,
+			AutoDelete: config.Duration{Duration: 0},
+			Signature:  &p.Signature,
+		},
+		signature:  p.Signature,
+		signatures: nil,
+		username:   "",
+		posts:      nil,
+		waits:      nil,
+		fits:       nil,
+		notify:     p.Notify,
+		scheduleAt: p.ScheduleAt,
+	}, nil
+}
+
+// reusedBatchMedia returns the media of the track with trackID that is sent as its previously uploaded doc.
+func reusedBatchMedia(trackID string, caption CaptionData, doc *tg.InputDocument) StoredBatchMedia {
+	//nolint:exhaustruct
+	return StoredBatchMedia{
+		TrackID: trackID,
+		Caption: caption,
+		Document: &StoredUpload{ //nolint:exhaustruct
+			DocumentID:    doc.ID,
+			AccessHash:    doc.AccessHash,
+			FileReference: doc.FileReference,
+		},
+	}
+}
+
+// uploadedBatchMedia returns the media of the track with trackID that is sent as its uploaded file named
+// fileName, with thumb as its thumbnail.
+func uploadedBatchMedia(
+	trackID string,
+	caption CaptionData,
+	file tg.InputFileClass,
+	thumb tg.InputFileClass,
+	mime string,
+	fileName string,
+	track types.Track,
+) (StoredBatchMedia, error) {
+	storedFile, ok := newStoredInputFile(file)
+	if !ok {
+		return StoredBatchMedia{}, fmt.Errorf("unsupported track input file type: %T", file) //nolint:exhaustruct
+	}
+
+	storedThumb, ok := newStoredInputFile(thumb)
+	if !ok {
+		return StoredBatchMedia{}, fmt.Errorf("unsupported cover input file type: %T", thumb) //nolint:exhaustruct
+	}
+
+	return StoredBatchMedia{
+		TrackID:   trackID,
+		Caption:   caption,
+		Document:  nil,
+		File:      &storedFile,
+		Thumb:     &storedThumb,
+		MIME:      mime,
+		FileName:  fileName,
+		Title:     track.Title,
+		Performer: types.JoinArtists(track.Artists),
+		Duration:  track.Duration,
+	}, nil
+}
+
+// batchAlbum returns the media group options of media, captioned for peer.
+func (u *Uploader) batchAlbum(peer uploadPeer, media []StoredBatchMedia) ([]message.MultiMediaOption, error) {
+	album := make([]message.MultiMediaOption, len(media))
+	for i, m := range media {
+		caption, err := u.caption(peer, m.Caption)
+		if nil != err {
+			return nil, fmt.Errorf("render caption of track %s: %v", m.TrackID, err)
+		}
+
+		if nil != m.Document {
+			album[i] = message.Document(
+				&tg.InputDocument{
+					ID:            m.Document.DocumentID,
+					AccessHash:    m.Document.AccessHash,
+					FileReference: m.Document.FileReference,
+				},
+				caption...,
+			)
+
+			continue
+		}
+
+		if nil == m.File || nil == m.Thumb {
+			return nil, fmt.Errorf("track %s has neither a document nor an uploaded file", m.TrackID)
+		}
+
+		album[i] = message.
+			UploadedDocument(m.File.inputFile(), caption...).
+			MIME(m.MIME).
+			Attributes(
+				&tg.DocumentAttributeFilename{
+					FileName: m.FileName,
+				},
+				//nolint:exhaustruct
+				&tg.DocumentAttributeAudio{
+					Title:     m.Title,
+					Performer: m.Performer,
+					Duration:  m.Duration,
+				}).
+			Thumb(m.Thumb.inputFile()).
+			Audio().
+			DurationSeconds(m.Duration).
+			Performer(m.Performer).
+			Title(m.Title)
+	}
+
+	return album, nil
+}
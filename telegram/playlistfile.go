@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const playlistFileMIME = "audio/x-mpegurl"
+
+// uploadPlaylistFile sends an M3U8 playlist of the tracks with trackIDs, in order, so that the uploaded tracks
+// can be played locally as a playlist once downloaded. It is sent after the final track batch, as Telegram
+// does not allow grouping it with audio files.
+func (u *Uploader) uploadPlaylistFile(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	title string,
+	trackIDs []string,
+	trackInfoFile func(id string) fs.InfoFile[types.StoredTrack],
+) error {
+	if len(trackIDs) == 0 {
+		return nil
+	}
+
+	tracks := make([]types.StoredTrack, 0, len(trackIDs))
+	for _, id := range trackIDs {
+		info, err := trackInfoFile(id).Read()
+		if nil != err {
+			return fmt.Errorf("read track %s info file: %v", id, err)
+		}
+		tracks = append(tracks, *info)
+	}
+
+	name := strings.ReplaceAll(title, "/", "-") + ".m3u8"
+	file, err := u.newUploader(ctx).FromBytes(ctx, name, []byte(m3u8(title, tracks)))
+	if nil != err {
+		return newUploadError(nil, fmt.Errorf("upload playlist file: %w", err))
+	}
+
+	doc := message.
+		UploadedDocument(file).
+		MIME(playlistFileMIME).
+		Attributes(&tg.DocumentAttributeFilename{FileName: name})
+
+	sender := newSender(u.client, peer)
+	_, err = u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return sender.Media(ctx, doc)
+	})
+	if nil != err {
+		return newUploadError(nil, fmt.Errorf("send playlist file: %w", err))
+	}
+	logger.Debug().Str("name", name).Msg("Playlist file sent")
+
+	time.Sleep(u.pause(1))
+
+	return nil
+}
+
+// m3u8 returns an extended M3U playlist of tracks, referencing them by their uploaded file names.
+func m3u8(title string, tracks []types.StoredTrack) string {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	sb.WriteString("#PLAYLIST:" + title + "\n")
+	for _, t := range tracks {
+		sb.WriteString("#EXTINF:" + strconv.Itoa(t.Duration) + "," + types.JoinArtists(t.Artists) + " - " + t.UploadTitle() + "\n")
+		sb.WriteString(t.UploadFilename() + "\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package telegram
+
+import (
+	"strconv"
+
+	"github.com/gotd/td/tg"
+)
+
+// postLinks collects the t.me links of the posts sent while uploading a link.
+type postLinks struct {
+	urls []string
+}
+
+// record adds the link of the first message sent in updates to peer. Only channel posts have links, so posts
+// sent to users and basic groups are skipped. It is a no-op on a nil receiver, e.g., for media groups resumed
+// on startup.
+func (l *postLinks) record(peer uploadPeer, updates tg.UpdatesClass) {
+	if nil == l || !peer.isChannel {
+		return
+	}
+
+	msgID := firstMessageID(updates)
+	if msgID == 0 {
+		return
+	}
+
+	l.urls = append(l.urls, postURL(peer, msgID))
+}
+
+// postURL returns the t.me link of the message with msgID in the channel peer. Public channels are linked by
+// their username, so that the link works for anyone, not only the channel members. Messages in forum topics
+// are linked within their topics.
+func postURL(peer uploadPeer, msgID int) string {
+	path := strconv.Itoa(msgID)
+	if peer.conf.Topic != 0 {
+		path = strconv.Itoa(peer.conf.Topic) + "/" + path
+	}
+
+	if len(peer.username) > 0 {
+		return "https://t.me/" + peer.username + "/" + path
+	}
+
+	return "https://t.me/c/" + strconv.FormatInt(peer.conf.ID, 10) + "/" + path
+}
+
+// count returns the number of recorded links. It is zero on a nil receiver.
+func (l *postLinks) count() int {
+	if nil == l {
+		return 0
+	}
+
+	return len(l.urls)
+}
+
+// since returns the links recorded after the first n ones.
+func (l *postLinks) since(n int) []string {
+	if nil == l {
+		return nil
+	}
+
+	return l.urls[n:]
+}
@@ -133,6 +133,11 @@ func (c *Cover) Chunk(ctx context.Context, state uploader.ProgressState) error {
 	return nil
 }
 
+// Complete marks the cover as fully uploaded, e.g., when its track is not uploaded again.
+func (c *Cover) Complete() {
+	c.uploaded.Store(c.Size)
+}
+
 type Track struct {
 	Size     int64
 	uploaded atomic.Int64
@@ -142,3 +147,8 @@ func (t *Track) Chunk(ctx context.Context, state uploader.ProgressState) error {
 	t.uploaded.Store(state.Uploaded)
 	return nil
 }
+
+// Complete marks the track as fully uploaded, e.g., when it is not uploaded again.
+func (t *Track) Complete() {
+	t.uploaded.Store(t.Size)
+}
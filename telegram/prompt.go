@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/skip2/go-qrcode"
+)
+
+// Prompter asks for the details of logging in to Telegram, e.g., on a terminal, or in the bot chat.
+type Prompter interface {
+	// ShowQR shows the QR code of url to scan using a logged in Telegram app. It is called again with a new url
+	// each time the previous one expires.
+	ShowQR(ctx context.Context, url string) error
+	// HideQR hides the last shown QR code, if any.
+	HideQR(ctx context.Context) error
+	// Phone asks for the phone number of the account, in international format.
+	Phone(ctx context.Context) (string, error)
+	// Code asks for the login code sent to the account. retry is set if the previous code was wrong.
+	Code(ctx context.Context, retry bool) (string, error)
+	// Password asks for the 2FA password of the account. retry is set if the previous password was wrong.
+	Password(ctx context.Context, retry bool) (string, error)
+}
+
+// TerminalPrompter prompts on an interactive terminal.
+type TerminalPrompter struct {
+	stdin  *os.File
+	stdout *os.File
+	// lines is the number of lines of the last shown QR code.
+	lines int
+}
+
+func NewTerminalPrompter(stdin, stdout *os.File) *TerminalPrompter {
+	return &TerminalPrompter{
+		stdin:  stdin,
+		stdout: stdout,
+		lines:  0,
+	}
+}
+
+func (p *TerminalPrompter) ShowQR(_ context.Context, url string) error {
+	qr, err := qrcode.New(url, qrcode.Highest)
+	if nil != err {
+		return fmt.Errorf("create qr code: %v", err)
+	}
+
+	const noInverseColor = false
+	code := qr.ToSmallString(noInverseColor)
+	p.lines = strings.Count(code, "\n")
+
+	fmt.Fprint(p.stdout, code)
+	fmt.Fprint(p.stdout, strings.Repeat(text.CursorUp.Sprint(), p.lines))
+
+	return nil
+}
+
+func (p *TerminalPrompter) HideQR(context.Context) error {
+	if p.lines == 0 {
+		return nil
+	}
+
+	// Clear the QR code from the console
+	var out strings.Builder
+	for range p.lines {
+		out.WriteString(text.EraseLine.Sprint())
+		out.WriteString(text.CursorDown.Sprint())
+	}
+	out.WriteString(text.CursorUp.Sprintn(p.lines))
+	out.WriteString(text.EraseLine.Sprint())
+	fmt.Fprint(p.stdout, out.String())
+	p.lines = 0
+
+	return nil
+}
+
+func (p *TerminalPrompter) Phone(context.Context) (string, error) {
+	prompt := &survey.Input{ //nolint:exhaustruct
+		Message: "Enter phone number (e.g., +15551234567):",
+	}
+
+	return p.ask(prompt)
+}
+
+func (p *TerminalPrompter) Code(_ context.Context, retry bool) (string, error) {
+	msg := "Enter login code:"
+	if retry {
+		msg = "Wrong login code. Enter login code:"
+	}
+	prompt := &survey.Input{ //nolint:exhaustruct
+		Message: msg,
+	}
+
+	return p.ask(prompt)
+}
+
+func (p *TerminalPrompter) Password(_ context.Context, retry bool) (string, error) {
+	msg := "Enter 2FA Password:"
+	if retry {
+		msg = "Wrong 2FA password. Enter 2FA Password:"
+	}
+	prompt := &survey.Password{ //nolint:exhaustruct
+		Message: msg,
+	}
+
+	return p.ask(prompt, survey.WithHideCharacter('*'))
+}
+
+func (p *TerminalPrompter) ask(prompt survey.Prompt, opts ...survey.AskOpt) (string, error) {
+	var answer string
+	askOpts := append(
+		[]survey.AskOpt{
+			survey.WithValidator(survey.Required),
+			survey.WithStdio(p.stdin, p.stdout, p.stdout),
+			survey.WithShowCursor(true),
+		},
+		opts...,
+	)
+	if err := survey.AskOne(prompt, &answer, askOpts...); nil != err {
+		return "", err
+	}
+
+	return strings.TrimSpace(answer), nil
+}
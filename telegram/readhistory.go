@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+// historyReader batches marking upload peers as read, as configured by [config.TelegramUploadReadHistory],
+// instead of sending a read request to every peer after every uploaded link.
+type historyReader struct {
+	client *tg.Client
+	conf   config.TelegramUploadReadHistory
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	pending map[string]InputPeer
+	links   int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newHistoryReader(logger zerolog.Logger, client *tg.Client, conf config.TelegramUploadReadHistory) *historyReader {
+	return &historyReader{
+		client:  client,
+		conf:    conf,
+		logger:  logger,
+		mu:      sync.Mutex{},
+		pending: make(map[string]InputPeer),
+		links:   0,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// run marks pending peers as read every configured interval, until ctx is done or the reader is closed.
+func (r *historyReader) run(ctx context.Context) {
+	defer close(r.done)
+
+	if r.conf.Disabled || r.conf.Interval.Duration == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.conf.Interval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+// add marks peer as having received uploads since it was last marked as read.
+func (r *historyReader) add(peer InputPeer) {
+	if r.conf.Disabled {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending[peerKey(peer)] = peer
+}
+
+// linkUploaded counts an uploaded link, and marks pending peers as read once every configured number of links.
+func (r *historyReader) linkUploaded(ctx context.Context) {
+	if r.conf.Disabled {
+		return
+	}
+
+	r.mu.Lock()
+	r.links++
+	due := r.links >= r.conf.EveryLinks
+	r.mu.Unlock()
+
+	if due {
+		r.flush(ctx)
+	}
+}
+
+// flush marks all pending peers as read.
+func (r *historyReader) flush(ctx context.Context) {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[string]InputPeer, len(pending))
+	r.links = 0
+	r.mu.Unlock()
+
+	for key, peer := range pending {
+		if err := peer.ReadHistory(ctx, r.client); nil != err {
+			r.logger.Error().Err(err).Str("peer", key).Msg("Failed to read peer history")
+		}
+	}
+}
+
+// close stops the interval loop and marks the remaining pending peers as read.
+func (r *historyReader) close(ctx context.Context) {
+	close(r.stop)
+	<-r.done
+	r.flush(ctx)
+}
+
+func peerKey(peer InputPeer) string {
+	switch p := peer.InputPeerClass.(type) {
+	case *tg.InputPeerUser:
+		return "user/" + strconv.FormatInt(p.UserID, 10)
+	case *tg.InputPeerChat:
+		return "chat/" + strconv.FormatInt(p.ChatID, 10)
+	case *tg.InputPeerChannel:
+		return "channel/" + strconv.FormatInt(p.ChannelID, 10)
+	default:
+		return peer.String()
+	}
+}
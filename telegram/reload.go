@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"fmt"
+	"html/template"
+	"reflect"
+
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+// Reload applies the upload options of conf that do not require reconnecting, e.g., the caption template,
+// signatures, concurrency, and pacing, to the uploads started after it. Peer signatures are reloaded too, as
+// long as the peers themselves are unchanged. It must not be called while an upload is running. It reports
+// whether other options changed, which require a restart to take effect.
+func (u *Uploader) Reload(conf config.Telegram) (bool, error) {
+	tmpl, err := template.New("caption").Parse(conf.Upload.Caption)
+	if nil != err {
+		return false, fmt.Errorf("parse caption template: %v", err)
+	}
+
+	next := u.conf
+	next.Upload.Threads = conf.Upload.Threads
+	next.Upload.Limit = conf.Upload.Limit
+	next.Upload.Caption = conf.Upload.Caption
+	next.Upload.Signature = conf.Upload.Signature
+	next.Upload.Signatures = conf.Upload.Signatures
+	next.Upload.SignatureRotation = conf.Upload.SignatureRotation
+	next.Upload.PauseDuration = conf.Upload.PauseDuration
+	next.Upload.Pacing = conf.Upload.Pacing
+	next.Upload.Archive = conf.Upload.Archive
+	next.Upload.Sidecars = conf.Upload.Sidecars
+	next.Upload.LyricsFiles = conf.Upload.LyricsFiles
+	next.Upload.Pipelined = conf.Upload.Pipelined
+	next.Upload.Oversized = conf.Upload.Oversized
+	next.Upload.Typing = conf.Upload.Typing
+	next.Upload.Summary = conf.Upload.Summary
+	next.Upload.Notify = conf.Upload.Notify
+	if samePeers(u.conf.Upload.Peers, conf.Upload.Peers) {
+		next.Upload.Peers = conf.Upload.Peers
+	}
+
+	var signatures *SignatureRotation
+	if len(next.Upload.Signatures) > 0 {
+		signatures = NewSignatureRotation(next.Upload.SignatureRotation, next.Upload.Signatures)
+	}
+
+	for i := range u.peers {
+		p := next.Upload.Peers[i]
+		// Peers set by their usernames, or invite links keep the ID and kind they were resolved to.
+		p.ID, p.Kind = u.peers[i].conf.ID, u.peers[i].conf.Kind
+		u.peers[i].conf = p
+		u.peers[i].signature = lo.FromPtr(p.Signature)
+		u.peers[i].signatures = nil
+		if nil == p.Signature {
+			u.peers[i].signatures = signatures
+		}
+	}
+	u.conf = next
+	u.tmpl = tmpl
+	u.signatures = signatures
+
+	return !reflect.DeepEqual(next, conf), nil
+}
+
+// samePeers reports whether a and b are the same peers, in the same order, with the same auto-delete timers,
+// which are only set on start, regardless of their signatures.
+func samePeers(a, b []config.TelegramUploadPeer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Kind != b[i].Kind || a[i].Username != b[i].Username || a[i].Invite != b[i].Invite ||
+			a[i].AutoDelete != b[i].AutoDelete {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,196 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+var ErrInviteNotJoined = errors.New("chat of invite link is not joined")
+
+// resolvePeer resolves the configured peer set by its username, or invite link, joining the chat of the invite
+// link if join is set, and it is not joined yet. Resolved peers are cached in storage, so that they are only
+// resolved once.
+func resolvePeer(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client *tg.Client,
+	storage *Storage,
+	conf config.TelegramUploadPeer,
+	join bool,
+) (*StoredResolvedPeer, error) {
+	key := resolvedPeerKey(conf)
+	if cached, err := storage.LoadResolvedPeer(key); nil != err {
+		return nil, fmt.Errorf("load cached peer: %v", err)
+	} else if nil != cached {
+		return cached, nil
+	}
+
+	var (
+		resolved *StoredResolvedPeer
+		err      error
+	)
+	if conf.Username != "" {
+		resolved, err = resolveUsername(ctx, client, conf.Username)
+	} else {
+		resolved, err = resolveInvite(ctx, logger, client, conf.Invite, join)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	if err := storage.StoreResolvedPeer(key, *resolved); nil != err {
+		logger.Error().Err(err).Msg("Failed to cache resolved peer. It is resolved again on next start")
+	}
+	logger.Info().Str("kind", resolved.Kind).Int64("id", resolved.ID).Msg("Resolved upload peer")
+
+	return resolved, nil
+}
+
+// resolvedPeerKey returns the key the peer set by its username, or invite link is cached with.
+func resolvedPeerKey(conf config.TelegramUploadPeer) string {
+	if conf.Username != "" {
+		return "username/" + strings.ToLower(conf.Username)
+	}
+
+	return "invite/" + conf.Invite
+}
+
+// resolveUsername resolves the user, or channel with username.
+func resolveUsername(ctx context.Context, client *tg.Client, username string) (*StoredResolvedPeer, error) {
+	resolved, err := client.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{ //nolint:exhaustruct
+		Username: strings.TrimPrefix(username, "@"),
+	})
+	if nil != err {
+		return nil, fmt.Errorf("resolve username: %w", err)
+	}
+
+	switch p := resolved.Peer.(type) {
+	case *tg.PeerUser:
+		user, ok := lookupUser(resolved.Users, p.UserID)
+		if !ok {
+			return nil, fmt.Errorf("resolved user %d is missing from response", p.UserID)
+		}
+
+		return &StoredResolvedPeer{
+			Kind:       "user",
+			ID:         user.ID,
+			AccessHash: user.AccessHash,
+			Username:   "",
+			ResolvedAt: time.Now().UTC(),
+		}, nil
+	case *tg.PeerChannel:
+		channel, ok := lookupChannel(resolved.Chats, p.ChannelID)
+		if !ok {
+			return nil, fmt.Errorf("resolved channel %d is missing from response", p.ChannelID)
+		}
+
+		return channelPeer(channel), nil
+	default:
+		return nil, fmt.Errorf("unsupported resolved peer type: %T", resolved.Peer)
+	}
+}
+
+// resolveInvite resolves the chat, or channel of the invite link with hash, joining it if join is set, and it
+// is not joined yet.
+func resolveInvite(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client *tg.Client,
+	hash string,
+	join bool,
+) (*StoredResolvedPeer, error) {
+	invite, err := client.MessagesCheckChatInvite(ctx, hash)
+	if nil != err {
+		return nil, fmt.Errorf("check invite link: %w", err)
+	}
+
+	if already, ok := invite.(*tg.ChatInviteAlready); ok {
+		return chatPeer(already.Chat)
+	}
+
+	if !join {
+		return nil, fmt.Errorf("%w: set join_invites to join it", ErrInviteNotJoined)
+	}
+
+	result, err := client.MessagesImportChatInvite(ctx, hash)
+	if nil != err {
+		if tgerr.Is(err, "INVITE_REQUEST_SENT") {
+			return nil, fmt.Errorf("%w: join request was sent, and is waiting for admin approval", ErrInviteNotJoined)
+		}
+
+		return nil, fmt.Errorf("join invite link chat: %w", err)
+	}
+	logger.Info().Msg("Joined chat of upload peer invite link")
+
+	joined, ok := result.(*tg.MessagesChatInviteJoinResultOk)
+	if !ok {
+		return nil, fmt.Errorf("unexpected join invite link chat result: %T", result)
+	}
+
+	var chats []tg.ChatClass
+	switch u := joined.Updates.(type) {
+	case *tg.Updates:
+		chats = u.Chats
+	case *tg.UpdatesCombined:
+		chats = u.Chats
+	}
+	if len(chats) == 0 {
+		return nil, errors.New("joined chat is missing from response")
+	}
+
+	return chatPeer(chats[0])
+}
+
+func chatPeer(chat tg.ChatClass) (*StoredResolvedPeer, error) {
+	switch c := chat.(type) {
+	case *tg.Chat:
+		return &StoredResolvedPeer{
+			Kind:       "chat",
+			ID:         c.ID,
+			AccessHash: 0,
+			Username:   "",
+			ResolvedAt: time.Now().UTC(),
+		}, nil
+	case *tg.Channel:
+		return channelPeer(c), nil
+	default:
+		return nil, fmt.Errorf("unsupported or forbidden chat type: %T", chat)
+	}
+}
+
+func channelPeer(channel *tg.Channel) *StoredResolvedPeer {
+	return &StoredResolvedPeer{
+		Kind:       "channel",
+		ID:         channel.ID,
+		AccessHash: channel.AccessHash,
+		Username:   channel.Username,
+		ResolvedAt: time.Now().UTC(),
+	}
+}
+
+// inputPeer returns the input peer of p.
+func (p StoredResolvedPeer) inputPeer() InputPeer {
+	var inputPeer tg.InputPeerClass
+	switch p.Kind {
+	case "user":
+		inputPeer = &tg.InputPeerUser{UserID: p.ID, AccessHash: p.AccessHash}
+	case "chat":
+		inputPeer = &tg.InputPeerChat{ChatID: p.ID}
+	default:
+		inputPeer = &tg.InputPeerChannel{ChannelID: p.ID, AccessHash: p.AccessHash}
+	}
+
+	return InputPeer{
+		InputPeerClass: inputPeer,
+		isChannel:      p.Kind == "channel",
+	}
+}
@@ -0,0 +1,169 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/crypto"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+)
+
+// resumableClient uploads the parts of a file, skipping the parts that were uploaded by a previous attempt which
+// failed midway, and records the uploaded parts, so that a failed attempt is resumed by the next one instead of
+// uploading the file from scratch.
+type resumableClient struct {
+	rpc       uploader.Client
+	logger    zerolog.Logger
+	storage   *Storage
+	bandwidth *rate.Limiter
+	// hash is the content hash of the file the upload is stored with.
+	hash string
+
+	mu       sync.Mutex
+	upload   StoredPartialUpload
+	uploaded map[int]struct{}
+}
+
+// newResumableClient returns a client that resumes the partial upload of the file with content hash, if any, and
+// the parts of which Telegram still keeps. Parts are sent no faster than bandwidth allows, unless it is nil.
+func newResumableClient(
+	logger zerolog.Logger,
+	rpc uploader.Client,
+	storage *Storage,
+	bandwidth *rate.Limiter,
+	hash string,
+) (*resumableClient, error) {
+	c := &resumableClient{
+		rpc:       rpc,
+		logger:    logger,
+		storage:   storage,
+		bandwidth: bandwidth,
+		hash:      hash,
+		mu:        sync.Mutex{},
+		upload:    StoredPartialUpload{ID: 0, Parts: nil, StartedAt: time.Time{}},
+		uploaded:  make(map[int]struct{}),
+	}
+
+	if stored, err := storage.LoadPartialUpload(hash); nil != err {
+		logger.Error().Err(err).Msg("Failed to load partial upload. Uploading file from scratch")
+	} else if nil != stored && time.Since(stored.StartedAt) < inputFileTTL {
+		c.upload = *stored
+		for _, part := range stored.Parts {
+			c.uploaded[part] = struct{}{}
+		}
+		logger.Info().Int("parts", len(stored.Parts)).Msg("Resuming partial upload of file")
+
+		return c, nil
+	}
+
+	id, err := crypto.RandInt64(crypto.DefaultRand())
+	if nil != err {
+		return nil, fmt.Errorf("generate file id: %v", err)
+	}
+	c.upload = StoredPartialUpload{ID: id, Parts: nil, StartedAt: time.Now().UTC()}
+
+	return c, nil
+}
+
+// fileID returns the identifier the file is uploaded with, which must be the same across attempts for the
+// uploaded parts to be reused.
+func (c *resumableClient) fileID() (int64, error) {
+	return c.upload.ID, nil
+}
+
+func (c *resumableClient) UploadSaveFilePart(ctx context.Context, req *tg.UploadSaveFilePartRequest) (bool, error) {
+	return c.savePart(ctx, req.FilePart, len(req.Bytes), func() (bool, error) {
+		return c.rpc.UploadSaveFilePart(ctx, req)
+	})
+}
+
+func (c *resumableClient) UploadSaveBigFilePart(ctx context.Context, req *tg.UploadSaveBigFilePartRequest) (bool, error) {
+	return c.savePart(ctx, req.FilePart, len(req.Bytes), func() (bool, error) {
+		return c.rpc.UploadSaveBigFilePart(ctx, req)
+	})
+}
+
+func (c *resumableClient) savePart(ctx context.Context, part, size int, save func() (bool, error)) (bool, error) {
+	c.mu.Lock()
+	_, ok := c.uploaded[part]
+	c.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+
+	if err := c.waitBandwidth(ctx, size); nil != err {
+		return false, err
+	}
+
+	saved, err := save()
+	if nil != err || !saved {
+		return saved, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploaded[part] = struct{}{}
+	c.upload.Parts = append(c.upload.Parts, part)
+	if err := c.storage.StorePartialUpload(c.hash, c.upload); nil != err {
+		c.logger.Error().Err(err).Int("part", part).Msg("Failed to store uploaded file part. It is uploaded again if the upload fails")
+	}
+
+	return true, nil
+}
+
+// waitBandwidth waits until size bytes can be sent without exceeding the upload bandwidth limit.
+func (c *resumableClient) waitBandwidth(ctx context.Context, size int) error {
+	if nil == c.bandwidth {
+		return nil
+	}
+
+	// Waiting for more tokens than the burst size always fails.
+	for burst := c.bandwidth.Burst(); size > 0; size -= burst {
+		if err := c.bandwidth.WaitN(ctx, min(size, burst)); nil != err {
+			return fmt.Errorf("wait for upload bandwidth: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// complete forgets the partial upload once the file is uploaded.
+func (c *resumableClient) complete() {
+	if err := c.storage.DeletePartialUpload(c.hash); nil != err {
+		c.logger.Error().Err(err).Msg("Failed to delete partial upload of uploaded file")
+	}
+}
+
+// uploadResumable uploads the file at path with content hash, resuming the previous attempt to upload it, if it
+// failed midway.
+func (u *Uploader) uploadResumable(
+	ctx context.Context,
+	logger zerolog.Logger,
+	path string,
+	hash string,
+	progress uploader.Progress,
+) (tg.InputFileClass, error) {
+	client, err := newResumableClient(logger, u.pool.Default(ctx), u.storage, u.bandwidth, hash)
+	if nil != err {
+		return nil, err
+	}
+
+	up := uploader.
+		NewUploader(client).
+		WithPartSize(MaxPartSize).
+		WithThreads(u.conf.Upload.Threads).
+		WithIDGenerator(client.fileID).
+		WithProgress(progress)
+	file, err := up.FromPath(ctx, path)
+	if nil != err {
+		return nil, err
+	}
+	client.complete()
+
+	return file, nil
+}
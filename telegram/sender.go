@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+)
+
+// minScheduleDelay is how far in the future posts must be scheduled at. Posts scheduled at sooner, e.g., as
+// the upload took longer than expected, are posted right away.
+const minScheduleDelay = 30 * time.Second
+
+// newSender returns the builder of the background messages sent to peer, which are silent, unless the peer is
+// notified, and are scheduled at the schedule time of the peer, if set. Messages are sent to the forum topic of
+// peer, if set, and messages replying to another message are sent to the topic of the replied message.
+func newSender(client *tg.Client, peer uploadPeer) *message.Builder {
+	sender := message.
+		NewSender(client).
+		To(peer).
+		Clear().
+		Background()
+	if !peer.notify {
+		sender = sender.Silent()
+	}
+	if !peer.scheduleAt.IsZero() && time.Until(peer.scheduleAt) > minScheduleDelay {
+		sender = sender.Schedule(peer.scheduleAt)
+	}
+	if peer.conf.Topic != 0 {
+		// Replying to the topic creation message, whose ID is the topic ID, sends the message to the topic.
+		sender = sender.Reply(peer.conf.Topic)
+	}
+
+	return sender
+}
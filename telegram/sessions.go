@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/goccy/go-json"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/secret"
+)
+
+var (
+	// ErrSessionNotFound is returned on exporting a session that is not stored.
+	ErrSessionNotFound = errors.New("session was not found")
+	// ErrSessionExists is returned on importing a session with the name of a stored session, unless it is to be
+	// overwritten.
+	ErrSessionExists = errors.New("session already exists")
+)
+
+// Sessions returns the names of the sessions stored in the storage of conf, sorted.
+func Sessions(conf config.Telegram, box *secret.Box) (names []string, err error) {
+	storage, err := NewStorage(conf.Storage.Path, conf.Storage.Session, box)
+	if nil != err {
+		return nil, fmt.Errorf("create storage: %v", err)
+	}
+	defer func() {
+		if closeErr := storage.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close storage: %v", closeErr))
+		}
+	}()
+
+	names, err = storage.Sessions()
+	if nil != err {
+		return nil, fmt.Errorf("list sessions: %v", err)
+	}
+
+	return names, nil
+}
+
+// ExportSession returns the decrypted session with name, which can be imported using ImportSession, e.g., into the
+// storage of another instance. Anyone having it has full access to the account of the session.
+func ExportSession(ctx context.Context, conf config.Telegram, box *secret.Box, name string) (session []byte, err error) {
+	storage, err := NewStorage(conf.Storage.Path, name, box)
+	if nil != err {
+		return nil, fmt.Errorf("create storage: %v", err)
+	}
+	defer func() {
+		if closeErr := storage.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close storage: %v", closeErr))
+		}
+	}()
+
+	session, err = storage.LoadSession(ctx)
+	if nil != err {
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	if nil == session {
+		return nil, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// ImportSession stores session, which was exported using ExportSession, with name, encrypting it using box,
+// unless it is nil. A stored session with name is only replaced if overwrite is true, along with the rest of its
+// stored data.
+func ImportSession(
+	ctx context.Context,
+	conf config.Telegram,
+	box *secret.Box,
+	name string,
+	session []byte,
+	overwrite bool,
+) (err error) {
+	if !json.Valid(session) {
+		return errors.New("session is not a valid exported session")
+	}
+
+	storage, err := NewStorage(conf.Storage.Path, name, box)
+	if nil != err {
+		return fmt.Errorf("create storage: %v", err)
+	}
+	defer func() {
+		if closeErr := storage.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close storage: %v", closeErr))
+		}
+	}()
+
+	names, err := storage.Sessions()
+	if nil != err {
+		return fmt.Errorf("list sessions: %v", err)
+	}
+	if slices.Contains(names, name) {
+		if !overwrite {
+			return ErrSessionExists
+		}
+
+		// The uploaded files, and access hashes of the replaced session might be of another account.
+		if err := storage.ClearAccount(); nil != err {
+			return fmt.Errorf("clear replaced session data: %v", err)
+		}
+	}
+
+	if err := storage.StoreSession(ctx, session); nil != err {
+		return fmt.Errorf("store session: %v", err)
+	}
+
+	return nil
+}
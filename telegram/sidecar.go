@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const sidecarMIME = "application/json"
+
+// linkSidecar is the machine-readable metadata of an uploaded link, sent as a JSON file after its tracks.
+type linkSidecar struct {
+	Kind    string                `json:"kind"`
+	TidalID string                `json:"tidal_id"`
+	Title   string                `json:"title,omitempty"`
+	Tracks  []types.TrackMetadata `json:"tracks"`
+}
+
+// uploadSidecar sends the metadata sidecar of the downloaded link, which lists its tracks in upload order.
+func (u *Uploader) uploadSidecar(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	link types.Link,
+) error {
+	sidecar, err := readLinkSidecar(dir, link)
+	if nil != err {
+		return fmt.Errorf("read link sidecar: %v", err)
+	}
+
+	content, err := json.MarshalIndent(sidecar, "", "  ")
+	if nil != err {
+		return fmt.Errorf("marshal link sidecar: %v", err)
+	}
+
+	name := link.Kind.String() + "-" + link.ID + ".json"
+	if len(sidecar.Title) > 0 {
+		name = strings.ReplaceAll(sidecar.Title, "/", "-") + ".json"
+	}
+
+	file, err := u.newUploader(ctx).FromBytes(ctx, name, content)
+	if nil != err {
+		return newUploadError(nil, fmt.Errorf("upload sidecar file: %w", err))
+	}
+
+	doc := message.
+		UploadedDocument(file).
+		MIME(sidecarMIME).
+		Attributes(&tg.DocumentAttributeFilename{FileName: name})
+
+	sender := newSender(u.client, peer)
+	_, err = u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return sender.Media(ctx, doc)
+	})
+	if nil != err {
+		return newUploadError(nil, fmt.Errorf("send sidecar file: %w", err))
+	}
+	logger.Debug().Str("name", name).Msg("Metadata sidecar sent")
+
+	time.Sleep(u.pause(1))
+
+	return nil
+}
+
+func readLinkSidecar(dir fs.DownloadsDir, link types.Link) (*linkSidecar, error) {
+	out := &linkSidecar{Kind: link.Kind.String(), TidalID: link.ID, Tracks: nil}
+
+	readTracks := func(trackIDs []string, trackInfoFile func(id string) fs.InfoFile[types.StoredTrack]) error {
+		for _, id := range trackIDs {
+			info, err := trackInfoFile(id).Read()
+			if nil != err {
+				return fmt.Errorf("read track %s info file: %v", id, err)
+			}
+			out.Tracks = append(out.Tracks, info.Metadata)
+		}
+
+		return nil
+	}
+
+	// Custom link kinds are stored as playlists.
+	switch link := link.StoredAs(); link.Kind {
+	case types.LinkKindTrack:
+		info, err := dir.Track(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read track info file: %v", err)
+		}
+		out.Title = types.JoinArtists(info.Artists) + " - " + info.UploadTitle()
+		out.Tracks = []types.TrackMetadata{info.Metadata}
+	case types.LinkKindAlbum:
+		albumFs := dir.Album(link.ID)
+		info, err := albumFs.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read album info file: %v", err)
+		}
+		out.Title = info.Album.Artist + " - " + info.Album.Title
+		for volIdx, trackIDs := range info.VolumeTrackIDs {
+			for _, id := range trackIDs {
+				trackInfo, err := albumFs.Track(volIdx+1, id).InfoFile.Read()
+				if nil != err {
+					return nil, fmt.Errorf("read track %s info file: %v", id, err)
+				}
+				out.Tracks = append(out.Tracks, trackInfo.Metadata)
+			}
+		}
+	case types.LinkKindPlaylist:
+		playlistFs := dir.Playlist(link.ID)
+		info, err := playlistFs.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
+		}
+		out.Title = info.Caption
+		if err := readTracks(info.TrackIDs, func(id string) fs.InfoFile[types.StoredTrack] { return playlistFs.Track(id).InfoFile }); nil != err {
+			return nil, err
+		}
+	case types.LinkKindMix:
+		mixFs := dir.Mix(link.ID)
+		info, err := mixFs.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+		out.Title = info.Caption
+		if err := readTracks(info.TrackIDs, func(id string) fs.InfoFile[types.StoredTrack] { return mixFs.Track(id).InfoFile }); nil != err {
+			return nil, err
+		}
+	case types.LinkKindArtistCredits:
+		creditsFs := dir.ArtistCredits(link.ID)
+		info, err := creditsFs.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read artist credits info file: %v", err)
+		}
+		if err := readTracks(info.TrackIDs, func(id string) fs.InfoFile[types.StoredTrack] { return creditsFs.Track(id).InfoFile }); nil != err {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported link kind: %s", link.Kind)
+	}
+
+	return out, nil
+}
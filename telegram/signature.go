@@ -0,0 +1,42 @@
+package telegram
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+// SignatureRotation picks one of the configured signatures for each post.
+type SignatureRotation struct {
+	policy     string
+	signatures []string
+	next       atomic.Uint64
+}
+
+func NewSignatureRotation(policy string, signatures []string) *SignatureRotation {
+	return &SignatureRotation{policy: policy, signatures: signatures, next: atomic.Uint64{}}
+}
+
+// Next returns the signature of the next post, which is sent at now.
+func (r *SignatureRotation) Next(now time.Time) string {
+	n := uint64(len(r.signatures))
+	switch r.policy {
+	case config.SignatureRotationRandom:
+		return r.signatures[rand.N(n)] //nolint:gosec
+	case config.SignatureRotationWeekday:
+		return r.signatures[uint64(now.Weekday())%n]
+	default:
+		return r.signatures[(r.next.Add(1)-1)%n]
+	}
+}
+
+// forPost returns peer with the signature of the next post, if its signature is rotated.
+func (p uploadPeer) forPost() uploadPeer {
+	if nil != p.signatures {
+		p.signature = p.signatures.Next(time.Now())
+	}
+
+	return p
+}
@@ -1,23 +1,144 @@
 package telegram
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/goccy/go-json"
 	"go.etcd.io/bbolt"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/secret"
 )
 
 var (
 	sessionBucketName = []byte("session")
 	sessionKeyName    = []byte("session")
+	uploadsBucketName = []byte("uploads")
+	filesBucketName   = []byte("files")
+	postsBucketName   = []byte("posts")
+	batchesBucketName = []byte("batches")
+	aliasesBucketName = []byte("aliases")
+	peersBucketName   = []byte("peers")
+	partsBucketName   = []byte("parts")
+	// sessionsBucketName is the bucket of the buckets of the named sessions other than the default one, each of
+	// which holds the account buckets of its session.
+	sessionsBucketName = []byte("sessions")
 )
 
+// accountBucketNames are the buckets whose values are only valid for the account they were stored by, e.g., the
+// session itself, uploaded files, and access hashes, which are stored per session.
+var accountBucketNames = [][]byte{
+	sessionBucketName,
+	uploadsBucketName,
+	filesBucketName,
+	batchesBucketName,
+	peersBucketName,
+	partsBucketName,
+}
+
+// StoredUpload is the Telegram document a track was uploaded as, which can be sent again without re-uploading it.
+type StoredUpload struct {
+	DocumentID    int64     `json:"document_id"`
+	AccessHash    int64     `json:"access_hash"`
+	FileReference []byte    `json:"file_reference"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+}
+
+// StoredPost is the first message an album was posted with to a peer, which later additions to the album reply to.
+type StoredPost struct {
+	MessageID int       `json:"message_id"`
+	PostedAt  time.Time `json:"posted_at"`
+}
+
+// StoredBatch is a media group of tracks whose files are uploaded, but which is not sent yet. It is kept until
+// the media group is sent, so that it can be sent on the next start if the process exits before sending it.
+type StoredBatch struct {
+	Peer    StoredPeer `json:"peer"`
+	ReplyTo int        `json:"reply_to"`
+	// AlbumID is the ID of the album the media group is the post of, if any.
+	AlbumID   string             `json:"album_id"`
+	Media     []StoredBatchMedia `json:"media"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// StoredPeer is the peer a media group is sent to.
+type StoredPeer struct {
+	Kind       string `json:"kind"`
+	ID         int64  `json:"id"`
+	AccessHash int64  `json:"access_hash"`
+	Signature  string `json:"signature"`
+	Topic      int    `json:"topic,omitempty"`
+	Notify     bool   `json:"notify,omitempty"`
+	// ScheduleAt is when the media group is scheduled to be posted, or zero to post it right away.
+	ScheduleAt time.Time `json:"schedule_at,omitzero"`
+}
+
+// StoredBatchMedia is a track of a media group. It is sent either as its previously uploaded document, or as
+// its uploaded file, with its cover as thumbnail.
+type StoredBatchMedia struct {
+	TrackID   string           `json:"track_id"`
+	Caption   CaptionData      `json:"caption"`
+	Document  *StoredUpload    `json:"document"`
+	File      *StoredInputFile `json:"file"`
+	Thumb     *StoredInputFile `json:"thumb"`
+	MIME      string           `json:"mime"`
+	FileName  string           `json:"file_name"`
+	Title     string           `json:"title"`
+	Performer string           `json:"performer"`
+	Duration  int              `json:"duration"`
+}
+
+// StoredResolvedPeer is an upload peer set by its username, or invite link, as it was resolved, so that it is
+// only resolved once.
+type StoredResolvedPeer struct {
+	Kind       string    `json:"kind"`
+	ID         int64     `json:"id"`
+	AccessHash int64     `json:"access_hash"`
+	Username   string    `json:"username"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
 type Storage struct {
 	db *bbolt.DB
+	// session is the name of the session whose account buckets are used.
+	session string
+	// box encrypts the session at rest. It is nil if encryption is disabled.
+	box *secret.Box
+}
+
+// bucketParent is either a transaction, or a bucket, that buckets are nested in.
+type bucketParent interface {
+	Bucket(name []byte) *bbolt.Bucket
+	CreateBucket(name []byte) (*bbolt.Bucket, error)
+	CreateBucketIfNotExists(name []byte) (*bbolt.Bucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// StoredInputFile is an uploaded file that can be attached to messages again while Telegram keeps its parts.
+type StoredInputFile struct {
+	ID          int64     `json:"id"`
+	Parts       int       `json:"parts"`
+	Name        string    `json:"name"`
+	MD5Checksum string    `json:"md5_checksum"`
+	Big         bool      `json:"big"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// StoredPartialUpload is a file whose upload failed midway, whose uploaded parts are not uploaded again when the
+// upload is retried while Telegram keeps them.
+type StoredPartialUpload struct {
+	ID        int64     `json:"id"`
+	Parts     []int     `json:"parts"`
+	StartedAt time.Time `json:"started_at"`
 }
 
-func NewStorage(path string) (*Storage, error) {
+// NewStorage opens the storage at path, using the account buckets of session, e.g., [config.DefaultTelegramSession].
+// The session is encrypted using box, unless it is nil.
+func NewStorage(path string, session string, box *secret.Box) (*Storage, error) {
 	opts := &bbolt.Options{ //nolint:exhaustruct
 		NoFreelistSync: true,
 		ReadOnly:       false,
@@ -34,7 +155,12 @@ func NewStorage(path string) (*Storage, error) {
 		return nil, fmt.Errorf("create buckets: %v", err)
 	}
 
-	return &Storage{db: db}, nil
+	s := &Storage{db: db, session: session, box: box}
+	if err := s.createAccountBuckets(); nil != err {
+		return nil, fmt.Errorf("create account buckets: %v", err)
+	}
+
+	return s, nil
 }
 
 func createBuckets(db *bbolt.DB) error {
@@ -44,6 +170,46 @@ func createBuckets(db *bbolt.DB) error {
 			return fmt.Errorf("create session bucket: %v", err)
 		}
 
+		_, err = tx.CreateBucketIfNotExists(uploadsBucketName)
+		if nil != err {
+			return fmt.Errorf("create uploads bucket: %v", err)
+		}
+
+		_, err = tx.CreateBucketIfNotExists(filesBucketName)
+		if nil != err {
+			return fmt.Errorf("create files bucket: %v", err)
+		}
+
+		_, err = tx.CreateBucketIfNotExists(postsBucketName)
+		if nil != err {
+			return fmt.Errorf("create posts bucket: %v", err)
+		}
+
+		_, err = tx.CreateBucketIfNotExists(batchesBucketName)
+		if nil != err {
+			return fmt.Errorf("create batches bucket: %v", err)
+		}
+
+		_, err = tx.CreateBucketIfNotExists(aliasesBucketName)
+		if nil != err {
+			return fmt.Errorf("create aliases bucket: %v", err)
+		}
+
+		_, err = tx.CreateBucketIfNotExists(peersBucketName)
+		if nil != err {
+			return fmt.Errorf("create peers bucket: %v", err)
+		}
+
+		_, err = tx.CreateBucketIfNotExists(partsBucketName)
+		if nil != err {
+			return fmt.Errorf("create parts bucket: %v", err)
+		}
+
+		_, err = tx.CreateBucketIfNotExists(sessionsBucketName)
+		if nil != err {
+			return fmt.Errorf("create sessions bucket: %v", err)
+		}
+
 		return nil
 	})
 	if nil != err {
@@ -53,6 +219,101 @@ func createBuckets(db *bbolt.DB) error {
 	return nil
 }
 
+// createAccountBuckets creates the account buckets of the session of s, unless it is the default session, whose
+// account buckets are the top-level ones created by createBuckets.
+func (s *Storage) createAccountBuckets() error {
+	if s.session == config.DefaultTelegramSession {
+		return nil
+	}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		parent, err := tx.Bucket(sessionsBucketName).CreateBucketIfNotExists([]byte(s.session))
+		if nil != err {
+			return fmt.Errorf("create session %s bucket: %v", s.session, err)
+		}
+
+		for _, name := range accountBucketNames {
+			if _, err := parent.CreateBucketIfNotExists(name); nil != err {
+				return fmt.Errorf("create %s bucket: %v", string(name), err)
+			}
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("create account buckets: %v", err)
+	}
+
+	return nil
+}
+
+// accountBuckets returns the parent of the account buckets of the session of s in tx.
+func (s *Storage) accountBuckets(tx *bbolt.Tx) bucketParent {
+	if s.session == config.DefaultTelegramSession {
+		return tx
+	}
+
+	return tx.Bucket(sessionsBucketName).Bucket([]byte(s.session))
+}
+
+// accountBucket returns the account bucket with name of the session of s in tx.
+func (s *Storage) accountBucket(tx *bbolt.Tx, name []byte) *bbolt.Bucket {
+	return s.accountBuckets(tx).Bucket(name)
+}
+
+// ClearAccount removes the data stored for the account of the session, i.e., the contents of its account buckets
+// other than the session itself.
+func (s *Storage) ClearAccount() error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		parent := s.accountBuckets(tx)
+		for _, name := range accountBucketNames {
+			if bytes.Equal(name, sessionBucketName) {
+				continue
+			}
+
+			if err := parent.DeleteBucket(name); nil != err {
+				return fmt.Errorf("delete %s bucket: %v", string(name), err)
+			}
+
+			if _, err := parent.CreateBucket(name); nil != err {
+				return fmt.Errorf("create %s bucket: %v", string(name), err)
+			}
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("clear account: %v", err)
+	}
+
+	return nil
+}
+
+// Sessions returns the names of the stored sessions, sorted.
+func (s *Storage) Sessions() ([]string, error) {
+	var names []string
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		if nil != tx.Bucket(sessionBucketName).Get(sessionKeyName) {
+			names = append(names, config.DefaultTelegramSession)
+		}
+
+		return tx.Bucket(sessionsBucketName).ForEachBucket(func(name []byte) error {
+			if nil != tx.Bucket(sessionsBucketName).Bucket(name).Bucket(sessionBucketName).Get(sessionKeyName) {
+				names = append(names, string(name))
+			}
+
+			return nil
+		})
+	})
+	if nil != err {
+		return nil, fmt.Errorf("list sessions: %v", err)
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
 func (s *Storage) Close() error {
 	if err := s.db.Close(); nil != err {
 		return fmt.Errorf("close database: %v", err)
@@ -61,22 +322,44 @@ func (s *Storage) Close() error {
 	return nil
 }
 
-func (s *Storage) LoadSession(_ context.Context) ([]byte, error) {
-	var session []byte
+// LoadSession returns the stored session. A plaintext session is encrypted in place if encryption is enabled,
+// e.g., the first time it is loaded after encryption was enabled.
+func (s *Storage) LoadSession(ctx context.Context) ([]byte, error) {
+	var stored []byte
 	err := s.db.View(func(tx *bbolt.Tx) error {
-		session = tx.Bucket(sessionBucketName).Get(sessionKeyName)
+		stored = slices.Clone(s.accountBucket(tx, sessionBucketName).Get(sessionKeyName))
 		return nil
 	})
 	if nil != err {
 		return nil, fmt.Errorf("load session: %v", err)
 	}
 
+	if nil == stored {
+		return nil, nil
+	}
+
+	session, err := s.box.Open(stored)
+	if nil != err {
+		return nil, fmt.Errorf("decrypt session: %w", err)
+	}
+
+	if s.box.Enabled() && !secret.IsSealed(stored) {
+		if err := s.StoreSession(ctx, session); nil != err {
+			return nil, fmt.Errorf("encrypt plaintext session: %v", err)
+		}
+	}
+
 	return session, nil
 }
 
 func (s *Storage) StoreSession(_ context.Context, session []byte) error {
-	err := s.db.Update(func(tx *bbolt.Tx) error {
-		if err := tx.Bucket(sessionBucketName).Put(sessionKeyName, session); nil != err {
+	sealed, err := s.box.Seal(session)
+	if nil != err {
+		return fmt.Errorf("encrypt session: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, sessionBucketName).Put(sessionKeyName, sealed); nil != err {
 			return fmt.Errorf("store session: %v", err)
 		}
 
@@ -91,7 +374,7 @@ func (s *Storage) StoreSession(_ context.Context, session []byte) error {
 
 func (s *Storage) DeleteSession(_ context.Context) error {
 	err := s.db.Update(func(tx *bbolt.Tx) error {
-		if err := tx.Bucket(sessionBucketName).Delete(sessionKeyName); nil != err {
+		if err := s.accountBucket(tx, sessionBucketName).Delete(sessionKeyName); nil != err {
 			return fmt.Errorf("delete session: %v", err)
 		}
 
@@ -103,3 +386,380 @@ func (s *Storage) DeleteSession(_ context.Context) error {
 
 	return nil
 }
+
+// LoadUpload returns the stored upload of the track with trackID in quality, or nil if it was not uploaded before.
+func (s *Storage) LoadUpload(trackID, quality string) (*StoredUpload, error) {
+	var upload *StoredUpload
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := s.accountBucket(tx, uploadsBucketName).Get(uploadKey(canonicalTrackID(tx, trackID), quality))
+		if nil == v {
+			return nil
+		}
+
+		upload = new(StoredUpload)
+		if err := json.Unmarshal(v, upload); nil != err {
+			return fmt.Errorf("decode upload: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("load upload: %v", err)
+	}
+
+	return upload, nil
+}
+
+func (s *Storage) StoreUpload(trackID, quality string, upload StoredUpload) error {
+	v, err := json.Marshal(upload)
+	if nil != err {
+		return fmt.Errorf("encode upload: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, uploadsBucketName).Put(uploadKey(canonicalTrackID(tx, trackID), quality), v); nil != err {
+			return fmt.Errorf("put upload: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store upload: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteUpload(trackID, quality string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, uploadsBucketName).Delete(uploadKey(canonicalTrackID(tx, trackID), quality)); nil != err {
+			return fmt.Errorf("delete upload: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("delete upload: %v", err)
+	}
+
+	return nil
+}
+
+// StoreTrackAlias records that the track with alias was merged into the track with canonicalID, so that uploads
+// of either track are looked up and stored under the canonical one.
+func (s *Storage) StoreTrackAlias(alias, canonicalID string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket(aliasesBucketName).Put(trackAliasKey(alias), []byte(canonicalID)); nil != err {
+			return fmt.Errorf("put track alias: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store track alias: %v", err)
+	}
+
+	return nil
+}
+
+// canonicalTrackID returns the ID of the track that the track with trackID was merged into, or trackID itself
+// if it was not merged.
+func canonicalTrackID(tx *bbolt.Tx, trackID string) string {
+	if v := tx.Bucket(aliasesBucketName).Get(trackAliasKey(trackID)); nil != v {
+		return string(v)
+	}
+
+	return trackID
+}
+
+// LoadInputFile returns the uploaded file with content hash, or nil if no such file was uploaded before.
+func (s *Storage) LoadInputFile(hash string) (*StoredInputFile, error) {
+	var file *StoredInputFile
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := s.accountBucket(tx, filesBucketName).Get([]byte(hash))
+		if nil == v {
+			return nil
+		}
+
+		file = new(StoredInputFile)
+		if err := json.Unmarshal(v, file); nil != err {
+			return fmt.Errorf("decode input file: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("load input file: %v", err)
+	}
+
+	return file, nil
+}
+
+func (s *Storage) StoreInputFile(hash string, file StoredInputFile) error {
+	v, err := json.Marshal(file)
+	if nil != err {
+		return fmt.Errorf("encode input file: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, filesBucketName).Put([]byte(hash), v); nil != err {
+			return fmt.Errorf("put input file: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store input file: %v", err)
+	}
+
+	return nil
+}
+
+// ClearInputFiles removes all stored uploaded files of the session.
+func (s *Storage) ClearInputFiles() error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		parent := s.accountBuckets(tx)
+		if err := parent.DeleteBucket(filesBucketName); nil != err {
+			return fmt.Errorf("delete files bucket: %v", err)
+		}
+
+		if _, err := parent.CreateBucket(filesBucketName); nil != err {
+			return fmt.Errorf("create files bucket: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("clear input files: %v", err)
+	}
+
+	return nil
+}
+
+// LoadPartialUpload returns the partial upload of the file with content hash, or nil if no upload of the file
+// failed midway.
+func (s *Storage) LoadPartialUpload(hash string) (*StoredPartialUpload, error) {
+	var upload *StoredPartialUpload
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := s.accountBucket(tx, partsBucketName).Get([]byte(hash))
+		if nil == v {
+			return nil
+		}
+
+		upload = new(StoredPartialUpload)
+		if err := json.Unmarshal(v, upload); nil != err {
+			return fmt.Errorf("decode partial upload: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("load partial upload: %v", err)
+	}
+
+	return upload, nil
+}
+
+func (s *Storage) StorePartialUpload(hash string, upload StoredPartialUpload) error {
+	v, err := json.Marshal(upload)
+	if nil != err {
+		return fmt.Errorf("encode partial upload: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, partsBucketName).Put([]byte(hash), v); nil != err {
+			return fmt.Errorf("put partial upload: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store partial upload: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeletePartialUpload(hash string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, partsBucketName).Delete([]byte(hash)); nil != err {
+			return fmt.Errorf("delete partial upload: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("delete partial upload: %v", err)
+	}
+
+	return nil
+}
+
+// LoadAlbumPost returns the post of the album with albumID in peer, or nil if the album was not posted there before.
+func (s *Storage) LoadAlbumPost(peer, albumID string) (*StoredPost, error) {
+	var post *StoredPost
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(postsBucketName).Get(albumPostKey(peer, albumID))
+		if nil == v {
+			return nil
+		}
+
+		post = new(StoredPost)
+		if err := json.Unmarshal(v, post); nil != err {
+			return fmt.Errorf("decode post: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("load album post: %v", err)
+	}
+
+	return post, nil
+}
+
+func (s *Storage) StoreAlbumPost(peer, albumID string, post StoredPost) error {
+	v, err := json.Marshal(post)
+	if nil != err {
+		return fmt.Errorf("encode post: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket(postsBucketName).Put(albumPostKey(peer, albumID), v); nil != err {
+			return fmt.Errorf("put post: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store album post: %v", err)
+	}
+
+	return nil
+}
+
+// LoadBatches returns the stored media groups that are not sent yet, keyed by their keys.
+func (s *Storage) LoadBatches() (map[string]StoredBatch, error) {
+	batches := make(map[string]StoredBatch)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return s.accountBucket(tx, batchesBucketName).ForEach(func(k, v []byte) error {
+			var batch StoredBatch
+			if err := json.Unmarshal(v, &batch); nil != err {
+				return fmt.Errorf("decode batch %s: %v", string(k), err)
+			}
+			batches[string(k)] = batch
+
+			return nil
+		})
+	})
+	if nil != err {
+		return nil, fmt.Errorf("load batches: %v", err)
+	}
+
+	return batches, nil
+}
+
+func (s *Storage) StoreBatch(key string, batch StoredBatch) error {
+	v, err := json.Marshal(batch)
+	if nil != err {
+		return fmt.Errorf("encode batch: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, batchesBucketName).Put([]byte(key), v); nil != err {
+			return fmt.Errorf("put batch: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store batch: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteBatch(key string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, batchesBucketName).Delete([]byte(key)); nil != err {
+			return fmt.Errorf("delete batch: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("delete batch: %v", err)
+	}
+
+	return nil
+}
+
+// LoadResolvedPeer returns the peer resolved by key, or nil if it was not resolved before.
+func (s *Storage) LoadResolvedPeer(key string) (*StoredResolvedPeer, error) {
+	var peer *StoredResolvedPeer
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := s.accountBucket(tx, peersBucketName).Get([]byte(key))
+		if nil == v {
+			return nil
+		}
+
+		peer = new(StoredResolvedPeer)
+		if err := json.Unmarshal(v, peer); nil != err {
+			return fmt.Errorf("decode peer: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return nil, fmt.Errorf("load resolved peer: %v", err)
+	}
+
+	return peer, nil
+}
+
+func (s *Storage) StoreResolvedPeer(key string, peer StoredResolvedPeer) error {
+	v, err := json.Marshal(peer)
+	if nil != err {
+		return fmt.Errorf("encode peer: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, peersBucketName).Put([]byte(key), v); nil != err {
+			return fmt.Errorf("put peer: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("store resolved peer: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteResolvedPeer(key string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.accountBucket(tx, peersBucketName).Delete([]byte(key)); nil != err {
+			return fmt.Errorf("delete peer: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("delete resolved peer: %v", err)
+	}
+
+	return nil
+}
+
+func albumPostKey(peer, albumID string) []byte {
+	return []byte(peer + "/album/" + albumID)
+}
+
+func trackAliasKey(trackID string) []byte {
+	return []byte("track/" + trackID)
+}
+
+func uploadKey(trackID, quality string) []byte {
+	return []byte(trackID + "/" + quality)
+}
@@ -0,0 +1,345 @@
+package telegram_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/secret"
+	"github.com/xeptore/tidalgram/telegram"
+)
+
+func TestStorageUploads(t *testing.T) {
+	t.Parallel()
+
+	storage, err := telegram.NewStorage(filepath.Join(t.TempDir(), "telegram.db"), config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	upload, err := storage.LoadUpload("1", "LOSSLESS")
+	require.NoError(t, err)
+	assert.Nil(t, upload)
+
+	stored := telegram.StoredUpload{
+		DocumentID:    10,
+		AccessHash:    20,
+		FileReference: []byte{1, 2, 3},
+		UploadedAt:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, storage.StoreUpload("1", "LOSSLESS", stored))
+
+	upload, err = storage.LoadUpload("1", "LOSSLESS")
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	assert.Equal(t, stored.DocumentID, upload.DocumentID)
+	assert.Equal(t, stored.AccessHash, upload.AccessHash)
+	assert.Equal(t, stored.FileReference, upload.FileReference)
+	assert.True(t, stored.UploadedAt.Equal(upload.UploadedAt))
+
+	upload, err = storage.LoadUpload("1", "HI_RES_LOSSLESS")
+	require.NoError(t, err)
+	assert.Nil(t, upload)
+
+	require.NoError(t, storage.DeleteUpload("1", "LOSSLESS"))
+
+	upload, err = storage.LoadUpload("1", "LOSSLESS")
+	require.NoError(t, err)
+	assert.Nil(t, upload)
+}
+
+func TestStorageInputFiles(t *testing.T) {
+	t.Parallel()
+
+	storage, err := telegram.NewStorage(filepath.Join(t.TempDir(), "telegram.db"), config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	file, err := storage.LoadInputFile("abc")
+	require.NoError(t, err)
+	assert.Nil(t, file)
+
+	stored := telegram.StoredInputFile{
+		ID:          1,
+		Parts:       2,
+		Name:        "cover.jpg",
+		MD5Checksum: "d41d8cd98f00b204e9800998ecf8427e",
+		Big:         false,
+		UploadedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, storage.StoreInputFile("abc", stored))
+
+	file, err = storage.LoadInputFile("abc")
+	require.NoError(t, err)
+	require.NotNil(t, file)
+	assert.Equal(t, stored.ID, file.ID)
+	assert.Equal(t, stored.Parts, file.Parts)
+	assert.Equal(t, stored.Name, file.Name)
+	assert.Equal(t, stored.MD5Checksum, file.MD5Checksum)
+
+	require.NoError(t, storage.ClearInputFiles())
+
+	file, err = storage.LoadInputFile("abc")
+	require.NoError(t, err)
+	assert.Nil(t, file)
+}
+
+func TestStoragePartialUploads(t *testing.T) {
+	t.Parallel()
+
+	storage, err := telegram.NewStorage(filepath.Join(t.TempDir(), "telegram.db"), config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	upload, err := storage.LoadPartialUpload("abc")
+	require.NoError(t, err)
+	assert.Nil(t, upload)
+
+	stored := telegram.StoredPartialUpload{
+		ID:        1,
+		Parts:     []int{0, 2, 1},
+		StartedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, storage.StorePartialUpload("abc", stored))
+
+	upload, err = storage.LoadPartialUpload("abc")
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	assert.Equal(t, stored.ID, upload.ID)
+	assert.Equal(t, stored.Parts, upload.Parts)
+	assert.True(t, stored.StartedAt.Equal(upload.StartedAt))
+
+	require.NoError(t, storage.DeletePartialUpload("abc"))
+
+	upload, err = storage.LoadPartialUpload("abc")
+	require.NoError(t, err)
+	assert.Nil(t, upload)
+}
+
+func TestStorageAlbumPosts(t *testing.T) {
+	t.Parallel()
+
+	storage, err := telegram.NewStorage(filepath.Join(t.TempDir(), "telegram.db"), config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	post, err := storage.LoadAlbumPost("channel/1", "10")
+	require.NoError(t, err)
+	assert.Nil(t, post)
+
+	stored := telegram.StoredPost{MessageID: 42, PostedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	require.NoError(t, storage.StoreAlbumPost("channel/1", "10", stored))
+
+	post, err = storage.LoadAlbumPost("channel/1", "10")
+	require.NoError(t, err)
+	require.NotNil(t, post)
+	assert.Equal(t, 42, post.MessageID)
+
+	post, err = storage.LoadAlbumPost("user/1", "10")
+	require.NoError(t, err)
+	assert.Nil(t, post)
+}
+
+func TestStorageBatches(t *testing.T) {
+	t.Parallel()
+
+	storage, err := telegram.NewStorage(filepath.Join(t.TempDir(), "telegram.db"), config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	batches, err := storage.LoadBatches()
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+
+	stored := telegram.StoredBatch{
+		Peer:    telegram.StoredPeer{Kind: "channel", ID: 10, AccessHash: 20, Signature: "@tidalgram"},
+		ReplyTo: 30,
+		AlbumID: "40",
+		Media: []telegram.StoredBatchMedia{
+			{
+				TrackID:   "1",
+				Caption:   telegram.CaptionData{Title: "Album", TrackTitle: "Track", Disc: 1, Track: 1},     //nolint:exhaustruct
+				Document:  &telegram.StoredUpload{DocumentID: 50, AccessHash: 60, FileReference: []byte{1}}, //nolint:exhaustruct
+				File:      nil,
+				Thumb:     nil,
+				MIME:      "",
+				FileName:  "",
+				Title:     "",
+				Performer: "",
+				Duration:  0,
+			},
+		},
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, storage.StoreBatch("a", stored))
+
+	batches, err = storage.LoadBatches()
+	require.NoError(t, err)
+	require.Contains(t, batches, "a")
+	assert.Equal(t, stored.Peer, batches["a"].Peer)
+	assert.Equal(t, stored.AlbumID, batches["a"].AlbumID)
+	assert.Equal(t, stored.Media[0].Caption, batches["a"].Media[0].Caption)
+	assert.Equal(t, stored.Media[0].Document.DocumentID, batches["a"].Media[0].Document.DocumentID)
+
+	require.NoError(t, storage.DeleteBatch("a"))
+
+	batches, err = storage.LoadBatches()
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+}
+
+func TestStorageTrackAliases(t *testing.T) {
+	t.Parallel()
+
+	storage, err := telegram.NewStorage(filepath.Join(t.TempDir(), "telegram.db"), config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	stored := telegram.StoredUpload{
+		DocumentID:    10,
+		AccessHash:    20,
+		FileReference: []byte{1, 2, 3},
+		UploadedAt:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, storage.StoreUpload("2", "LOSSLESS", stored))
+	require.NoError(t, storage.StoreTrackAlias("1", "2"))
+
+	upload, err := storage.LoadUpload("1", "LOSSLESS")
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	assert.Equal(t, stored.DocumentID, upload.DocumentID)
+
+	require.NoError(t, storage.DeleteUpload("1", "LOSSLESS"))
+
+	upload, err = storage.LoadUpload("2", "LOSSLESS")
+	require.NoError(t, err)
+	assert.Nil(t, upload)
+}
+
+func TestStorageEncryptedSession(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "telegram.db")
+	ctx := context.Background()
+
+	storage, err := telegram.NewStorage(path, config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	require.NoError(t, storage.StoreSession(ctx, []byte("session")))
+	require.NoError(t, storage.Close())
+
+	// Sessions stored before encryption was enabled are read, and encrypted in place.
+	box, err := secret.New("passphrase")
+	require.NoError(t, err)
+	storage, err = telegram.NewStorage(path, config.DefaultTelegramSession, box)
+	require.NoError(t, err)
+	session, err := storage.LoadSession(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "session", string(session))
+	require.NoError(t, storage.Close())
+
+	storage, err = telegram.NewStorage(path, config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	_, err = storage.LoadSession(ctx)
+	require.ErrorIs(t, err, secret.ErrPassphraseRequired)
+	require.NoError(t, storage.Close())
+
+	storage, err = telegram.NewStorage(path, config.DefaultTelegramSession, box)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+	session, err = storage.LoadSession(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "session", string(session))
+}
+
+func TestStorageResolvedPeers(t *testing.T) {
+	t.Parallel()
+
+	storage, err := telegram.NewStorage(filepath.Join(t.TempDir(), "telegram.db"), config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	peer, err := storage.LoadResolvedPeer("username/music")
+	require.NoError(t, err)
+	assert.Nil(t, peer)
+
+	stored := telegram.StoredResolvedPeer{
+		Kind:       "channel",
+		ID:         10,
+		AccessHash: 20,
+		Username:   "music",
+		ResolvedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, storage.StoreResolvedPeer("username/music", stored))
+
+	peer, err = storage.LoadResolvedPeer("username/music")
+	require.NoError(t, err)
+	require.NotNil(t, peer)
+	assert.Equal(t, stored.Kind, peer.Kind)
+	assert.Equal(t, stored.ID, peer.ID)
+	assert.Equal(t, stored.AccessHash, peer.AccessHash)
+	assert.Equal(t, stored.Username, peer.Username)
+
+	peer, err = storage.LoadResolvedPeer("invite/abc")
+	require.NoError(t, err)
+	assert.Nil(t, peer)
+
+	require.NoError(t, storage.DeleteResolvedPeer("username/music"))
+
+	peer, err = storage.LoadResolvedPeer("username/music")
+	require.NoError(t, err)
+	assert.Nil(t, peer)
+}
+
+func TestStorageNamedSessions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "telegram.db")
+	ctx := context.Background()
+	upload := telegram.StoredUpload{DocumentID: 10, AccessHash: 20, FileReference: nil, UploadedAt: time.Time{}}
+
+	storage, err := telegram.NewStorage(path, config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	require.NoError(t, storage.StoreSession(ctx, []byte(`{"default":true}`)))
+	require.NoError(t, storage.StoreUpload("1", "LOSSLESS", upload))
+	require.NoError(t, storage.Close())
+
+	// Sessions, and the uploads of their accounts, are stored separately.
+	storage, err = telegram.NewStorage(path, "work", nil)
+	require.NoError(t, err)
+	session, err := storage.LoadSession(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, session)
+	stored, err := storage.LoadUpload("1", "LOSSLESS")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	require.NoError(t, storage.StoreSession(ctx, []byte(`{"work":true}`)))
+	require.NoError(t, storage.StoreUpload("1", "LOSSLESS", upload))
+
+	names, err := storage.Sessions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "work"}, names)
+
+	require.NoError(t, storage.ClearAccount())
+	stored, err = storage.LoadUpload("1", "LOSSLESS")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	session, err = storage.LoadSession(ctx)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"work":true}`, string(session))
+	require.NoError(t, storage.Close())
+
+	storage, err = telegram.NewStorage(path, config.DefaultTelegramSession, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+	session, err = storage.LoadSession(ctx)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"default":true}`, string(session))
+	stored, err = storage.LoadUpload("1", "LOSSLESS")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, upload.DocumentID, stored.DocumentID)
+}
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// sendAlbumSummary sends the message summarizing the album with id uploaded to peer, linking to the posts of
+// its parts in urls, and pins it if set. Failing to pin the summary is only logged, as it requires an admin
+// right the account might not have.
+func (u *Uploader) sendAlbumSummary(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	id string,
+	urls []string,
+) error {
+	albumFs := dir.Album(id)
+	info, err := albumFs.InfoFile.Read()
+	if nil != err {
+		return fmt.Errorf("read album info file: %v", err)
+	}
+
+	var (
+		count   int
+		formats []string
+	)
+	for volIdx, trackIDs := range info.VolumeTrackIDs {
+		count += len(trackIDs)
+		for _, trackID := range trackIDs {
+			trackInfo, err := albumFs.Track(volIdx+1, trackID).InfoFile.Read()
+			if nil != err {
+				return fmt.Errorf("read album track info file: %v", err)
+			}
+			formats = append(formats, strings.ToUpper(trackInfo.Ext))
+		}
+	}
+
+	text := []message.StyledTextOption{
+		styling.Bold("💿 " + info.Album.Title),
+		styling.Plain("\n👤 " + info.Album.Artist),
+		styling.Plain("\n📅 " + info.Album.ReleaseDate.Format(types.ReleaseDateLayout)),
+		styling.Plain("\n🎚️ "),
+		styling.Code(types.TrackQuality),
+		styling.Plain(" · " + strings.Join(lo.Uniq(formats), ", ")),
+		styling.Plain("\n🎵 " + strconv.Itoa(count) + " track(s)"),
+	}
+	for i, url := range urls {
+		text = append(text, styling.Plain("\n"), styling.TextURL("🔗 Part "+strconv.Itoa(i+1), url))
+	}
+
+	updates, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return newSender(u.client, peer).StyledText(ctx, text...)
+	})
+	if nil != err {
+		return fmt.Errorf("send album summary: %w", err)
+	}
+	peer.posts.record(peer, updates)
+
+	if u.conf.Upload.Summary.Pin {
+		msgID := firstMessageID(updates)
+		if msgID == 0 {
+			logger.Warn().Msg("Sent album summary message not found in updates, e.g., as it is scheduled. Skipping pinning it")
+		} else if _, err := u.client.MessagesUpdatePinnedMessage(ctx, &tg.MessagesUpdatePinnedMessageRequest{ //nolint:exhaustruct
+			Silent: true,
+			Peer:   peer,
+			ID:     msgID,
+		}); nil != err {
+			logger.Warn().Err(err).Msg("Failed to pin album summary message")
+		}
+	}
+
+	time.Sleep(u.pause(1))
+
+	return nil
+}
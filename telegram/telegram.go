@@ -4,34 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
-	"syscall"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
 	"github.com/gotd/td/telegram/auth/qrlogin"
 	"github.com/gotd/td/tg"
 	"github.com/gotd/td/tgerr"
-	"github.com/jedib0t/go-pretty/v6/text"
-	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
-	"github.com/skip2/go-qrcode"
 
 	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/secret"
 )
 
-func Login(ctx context.Context, logger zerolog.Logger, conf config.Telegram) (err error) {
-	var (
-		stdin  = os.Stdin
-		stdout = os.Stdout
-	)
+// LoginMethod is how Login authorizes the account.
+type LoginMethod string
 
-	if !isatty.IsTerminal(os.Stdout.Fd()) {
-		return syscall.ENOTTY
-	}
+const (
+	// LoginMethodQR logs in by scanning a QR code using a logged in Telegram app.
+	LoginMethodQR LoginMethod = "qr"
+	// LoginMethodPhone logs in using the code sent to the account of a phone number.
+	LoginMethodPhone LoginMethod = "phone"
+)
 
-	storage, err := NewStorage(conf.Storage.Path)
+// maxPromptAttempts is the number of times a wrong login code, or 2FA password is asked again for.
+const maxPromptAttempts = 3
+
+// Login authorizes the Telegram account using method, asking prompter for the login details, and the 2FA
+// password, if the account has one.
+func Login(
+	ctx context.Context,
+	logger zerolog.Logger,
+	conf config.Telegram,
+	box *secret.Box,
+	method LoginMethod,
+	prompter Prompter,
+) (err error) {
+	storage, err := NewStorage(conf.Storage.Path, conf.Storage.Session, box)
 	if nil != err {
 		return fmt.Errorf("create storage: %v", err)
 	}
@@ -54,58 +63,23 @@ func Login(ctx context.Context, logger zerolog.Logger, conf config.Telegram) (er
 	client := telegram.NewClient(conf.AppID, conf.AppHash, *opts)
 
 	err = client.Run(ctx, func(ctx context.Context) error {
-		var lines int
-		_, err = client.QR().Auth(
-			ctx,
-			qrlogin.OnLoginToken(dispatcher),
-			func(ctx context.Context, token qrlogin.Token) error {
-				qr, err := qrcode.New(token.URL(), qrcode.Highest)
-				if nil != err {
-					return fmt.Errorf("create qr code: %v", err)
-				}
-
-				const noInverseColor = false
-				code := qr.ToSmallString(noInverseColor)
-				lines = strings.Count(code, "\n")
-
-				fmt.Fprint(stdout, code)
-				fmt.Fprint(stdout, strings.Repeat(text.CursorUp.Sprint(), lines))
-
-				return nil
-			},
-		)
-
-		// Clear the QR code from the console
-		var out strings.Builder
-		for range lines {
-			out.WriteString(text.EraseLine.Sprint())
-			out.WriteString(text.CursorDown.Sprint())
+		var err error
+		switch method {
+		case LoginMethodQR:
+			err = qrLogin(ctx, client, dispatcher, prompter)
+		case LoginMethodPhone:
+			err = phoneLogin(ctx, client, prompter)
+		default:
+			return fmt.Errorf("unsupported login method: %s", method)
 		}
-		out.WriteString(text.CursorUp.Sprintn(lines))
-		fmt.Fprint(stdout, out.String())
-
 		if nil != err {
 			// https://core.telegram.org/api/auth#2fa
-			if !tgerr.Is(err, "SESSION_PASSWORD_NEEDED") {
-				return fmt.Errorf("unknown error from QR code login: %w", err)
-			}
-
-			var pwd string
-			prompt := &survey.Password{ //nolint:exhaustruct
-				Message: "Enter 2FA Password:",
-			}
-			askOpts := []survey.AskOpt{
-				survey.WithValidator(survey.Required),
-				survey.WithHideCharacter('*'),
-				survey.WithStdio(stdin, stdout, stdout),
-				survey.WithShowCursor(true),
-			}
-			if err = survey.AskOne(prompt, &pwd, askOpts...); nil != err {
-				return fmt.Errorf("ask for 2fa password: %v", err)
+			if !errors.Is(err, auth.ErrPasswordAuthNeeded) && !tgerr.Is(err, "SESSION_PASSWORD_NEEDED") {
+				return err
 			}
 
-			if _, err = client.Auth().Password(ctx, pwd); nil != err {
-				return fmt.Errorf("finalize login with 2fa password: %v", err)
+			if err := passwordLogin(ctx, client, prompter); nil != err {
+				return err
 			}
 		}
 
@@ -114,8 +88,6 @@ func Login(ctx context.Context, logger zerolog.Logger, conf config.Telegram) (er
 			return fmt.Errorf("get logged in user: %w", err)
 		}
 
-		fmt.Fprint(stdout, text.EraseLine.Sprint())
-
 		logger.
 			Info().
 			Int64("id", user.ID).
@@ -135,8 +107,109 @@ func Login(ctx context.Context, logger zerolog.Logger, conf config.Telegram) (er
 	return nil
 }
 
-func Logout(ctx context.Context, logger zerolog.Logger, conf config.Telegram) (err error) {
-	storage, err := NewStorage(conf.Storage.Path)
+func qrLogin(ctx context.Context, client *telegram.Client, dispatcher tg.UpdateDispatcher, prompter Prompter) error {
+	_, err := client.QR().Auth(
+		ctx,
+		qrlogin.OnLoginToken(dispatcher),
+		func(ctx context.Context, token qrlogin.Token) error {
+			return prompter.ShowQR(ctx, token.URL())
+		},
+	)
+
+	if hideErr := prompter.HideQR(ctx); nil != hideErr {
+		err = errors.Join(err, fmt.Errorf("hide qr code: %v", hideErr))
+	}
+
+	if nil != err {
+		return fmt.Errorf("qr code login: %w", err)
+	}
+
+	return nil
+}
+
+func phoneLogin(ctx context.Context, client *telegram.Client, prompter Prompter) error {
+	phone, err := prompter.Phone(ctx)
+	if nil != err {
+		return fmt.Errorf("ask for phone number: %w", err)
+	}
+
+	sentCode, err := client.Auth().SendCode(ctx, phone, auth.SendCodeOptions{}) //nolint:exhaustruct
+	if nil != err {
+		return fmt.Errorf("send login code: %w", err)
+	}
+
+	var codeHash string
+	switch sentCode := sentCode.(type) {
+	case *tg.AuthSentCode:
+		codeHash = sentCode.PhoneCodeHash
+	case *tg.AuthSentCodeSuccess:
+		return nil
+	default:
+		return fmt.Errorf("unexpected sent code type: %T", sentCode)
+	}
+
+	for attempt := 1; ; attempt++ {
+		code, err := prompter.Code(ctx, attempt > 1)
+		if nil != err {
+			return fmt.Errorf("ask for login code: %w", err)
+		}
+		// Codes sent in chats are separated by spaces, or other characters, as Telegram expires the codes
+		// it finds in outgoing messages.
+		code = strings.Map(
+			func(r rune) rune {
+				if r >= '0' && r <= '9' {
+					return r
+				}
+
+				return -1
+			},
+			code,
+		)
+
+		_, err = client.Auth().SignIn(ctx, phone, code, codeHash)
+		if nil == err {
+			return nil
+		}
+
+		if tgerr.Is(err, "PHONE_CODE_INVALID") && attempt < maxPromptAttempts {
+			continue
+		}
+
+		var signUpErr *auth.SignUpRequired
+		if errors.As(err, &signUpErr) {
+			return errors.New("phone number is not registered to a telegram account")
+		}
+
+		// Returned as is, so that the 2FA password is asked for.
+		if errors.Is(err, auth.ErrPasswordAuthNeeded) {
+			return err
+		}
+
+		return fmt.Errorf("sign in with login code: %w", err)
+	}
+}
+
+func passwordLogin(ctx context.Context, client *telegram.Client, prompter Prompter) error {
+	for attempt := 1; ; attempt++ {
+		pwd, err := prompter.Password(ctx, attempt > 1)
+		if nil != err {
+			return fmt.Errorf("ask for 2fa password: %w", err)
+		}
+
+		if _, err := client.Auth().Password(ctx, pwd); nil != err {
+			if errors.Is(err, auth.ErrPasswordInvalid) && attempt < maxPromptAttempts {
+				continue
+			}
+
+			return fmt.Errorf("finalize login with 2fa password: %v", err)
+		}
+
+		return nil
+	}
+}
+
+func Logout(ctx context.Context, logger zerolog.Logger, conf config.Telegram, box *secret.Box) (err error) {
+	storage, err := NewStorage(conf.Storage.Path, conf.Storage.Session, box)
 	if nil != err {
 		return fmt.Errorf("create storage: %v", err)
 	}
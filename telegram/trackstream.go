@@ -0,0 +1,120 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/pipeline"
+	"github.com/xeptore/tidalgram/ratelimit"
+	"github.com/xeptore/tidalgram/telegram/progress"
+	"github.com/xeptore/tidalgram/tidal/fs"
+)
+
+// errStreamTooLarge is returned on reading more of a streamed track than the max upload file size.
+var errStreamTooLarge = errors.New("streamed track is larger than the max upload file size")
+
+// uploadStreamedTrack uploads the track with id while its file is streamed by its download, and sends it to peer
+// once the whole file is uploaded, and its tags are verified. It reports false, without failing, if the track
+// was not streamed, or streaming it failed, in which case it is to be uploaded as usual once it is downloaded.
+func (u *Uploader) uploadStreamedTrack(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	id string,
+	track *pipeline.Track,
+) (bool, error) {
+	stream, err := track.Take(ctx)
+	if nil != err {
+		return false, fmt.Errorf("wait for track stream: %w", err)
+	}
+	if nil == stream {
+		return false, nil
+	}
+	defer func() { _ = stream.Close() }()
+
+	info := stream.Info
+	if nil != u.uploadedTrack(logger, id, info.Metadata.TidalID) {
+		return false, nil
+	}
+
+	logger = logger.With().Str("track_id", id).Logger()
+	if err := u.gate.Wait(ctx); nil != err {
+		return false, fmt.Errorf("wait for paused job: %w", err)
+	}
+
+	cover := dir.Track(id).Cover
+	if _, err := cover.Thumbnail(); nil != err {
+		logger.Error().Err(err).Msg("Failed to create track cover thumbnail")
+		return false, fmt.Errorf("create track cover thumbnail: %v", err)
+	}
+	coverInputFile, err := u.uploadFile(ctx, logger, cover.ThumbnailPath(), &progress.Cover{}) //nolint:exhaustruct
+	if nil != err {
+		return false, newUploadError([]string{id}, fmt.Errorf("upload track cover file: %w", err))
+	}
+
+	r := &streamReader{r: stream, limit: u.conf.Upload.Oversized.MaxFileSizeMB * mb, read: 0, err: nil}
+	var from io.Reader = r
+	if nil != u.bandwidth {
+		from = ratelimit.Reader(ctx, r, u.bandwidth)
+	}
+
+	// The size of the file is only known once it is written, so it is uploaded as a big file whose number of
+	// parts is counted as it is read.
+	trackInputFile, err := u.newUploader(ctx).Upload(ctx, uploader.NewUpload(info.UploadFilename(), from, -1))
+	if nil != err {
+		if nil != ctx.Err() {
+			return false, fmt.Errorf("upload streamed track file: %w", ctx.Err())
+		}
+
+		// The file is still written, and checked, by its download, and is uploaded again from it if it is.
+		logger.Warn().Err(err).AnErr("stream_err", r.err).Msg("Failed to upload streamed track. Uploading it once it is downloaded")
+
+		return false, nil
+	}
+
+	peer = peer.forPost()
+	caption, err := u.caption(peer, newCaptionData(info.Album, info.Track))
+	if nil != err {
+		return false, fmt.Errorf("render caption: %v", err)
+	}
+
+	media := trackAudioDocument(trackInputFile, coverInputFile, "audio/flac", &info, caption)
+	if err := u.sendTrack(ctx, logger, peer, id, media, nil); nil != err {
+		return false, err
+	}
+
+	// Whatever is uploaded after the track, e.g., its sidecar, reads the track info file.
+	if err := track.Wait(ctx); nil != err {
+		return false, fmt.Errorf("wait for track download: %w", err)
+	}
+
+	return true, nil
+}
+
+// streamReader reads a streamed track file, recording the error reading it failed with, and failing once more
+// than limit bytes are read.
+type streamReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+	err   error
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if nil == err && r.read > r.limit {
+		err = errStreamTooLarge
+	}
+	if nil != err && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
+
+	return n, err
+}
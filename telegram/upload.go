@@ -4,8 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gabriel-vasile/mimetype"
@@ -13,19 +18,27 @@ import (
 	"github.com/gotd/td/constant"
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/message"
-	"github.com/gotd/td/telegram/message/html"
-	"github.com/gotd/td/telegram/message/styling"
 	"github.com/gotd/td/telegram/query"
 	"github.com/gotd/td/telegram/query/dialogs"
 	"github.com/gotd/td/telegram/uploader"
 	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
 	"github.com/iyear/tdl/core/dcpool"
 	"github.com/iyear/tdl/core/tclient"
 	"github.com/rs/zerolog"
+	"github.com/samber/lo"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 
 	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/errs"
+	"github.com/xeptore/tidalgram/events"
 	"github.com/xeptore/tidalgram/mathutil"
+	"github.com/xeptore/tidalgram/pause"
+	"github.com/xeptore/tidalgram/pipeline"
+	"github.com/xeptore/tidalgram/ratelimit"
+	"github.com/xeptore/tidalgram/secret"
 	"github.com/xeptore/tidalgram/telegram/progress"
 	"github.com/xeptore/tidalgram/tidal/fs"
 	"github.com/xeptore/tidalgram/tidal/types"
@@ -34,18 +47,89 @@ import (
 const MaxPartSize = constant.UploadMaxPartSize
 
 var (
-	ErrUnauthorized = errors.New("unauthorized")
+	ErrUnauthorized = errs.New(errs.CategoryAuth, "unauthorized")
 	ErrPeerNotFound = errors.New("peer not found")
 )
 
+// UploadError annotates an upload failure with the IDs of the tracks it affected.
+// TrackIDs is empty when the failure is not specific to any track, e.g., album cover upload.
+type UploadError struct {
+	TrackIDs []string
+	Err      error
+}
+
+func (e *UploadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+func newUploadError(trackIDs []string, err error) error {
+	return &UploadError{TrackIDs: trackIDs, Err: err}
+}
+
 type Uploader struct {
+	files   singleflight.Group
 	storage *Storage
 	client  *tg.Client
 	pool    dcpool.Pool
 	stop    bg.StopFunc
 	conf    config.Telegram
-	peer    InputPeer
-	logger  zerolog.Logger
+	peers   []uploadPeer
+	// signatures rotates the signatures of the posts sent to destinations. It is nil if no signatures are set.
+	signatures *SignatureRotation
+	tmpl       *template.Template
+	bus        *events.Bus
+	reads      *historyReader
+	logger     zerolog.Logger
+	// bandwidth limits the total upload bandwidth of files. It is nil if unlimited.
+	bandwidth *rate.Limiter
+	gate      *pause.Gate
+	// api is the client of the main connection, which receives the updates, and follows file DC migrations.
+	api *tg.Client
+	// unauthorized is closed once the session is found revoked, or expired.
+	unauthorized     chan struct{}
+	unauthorizedOnce sync.Once
+}
+
+// uploadPeer is a resolved upload destination.
+type uploadPeer struct {
+	InputPeer
+
+	conf      config.TelegramUploadPeer
+	signature string
+	// signatures rotates the signature of each post sent to the peer. It is nil if signature is used instead.
+	signatures *SignatureRotation
+	// username is the username of the peer if it is a public channel, which its post links are composed with.
+	username string
+	// posts collects the links of the posts sent to the peer during an upload. It is nil outside of uploads.
+	posts *postLinks
+	// waits accumulates the time waited on FLOOD_WAIT errors during an upload. It is nil outside of uploads.
+	waits *floodWaits
+	// fits holds how the tracks larger than the max file size are uploaded. It is nil outside of uploads.
+	fits *trackFits
+	// notify sends the posts with notifications, rather than silently.
+	notify bool
+	// scheduleAt is when the posts are scheduled to be posted, or zero to post them right away.
+	scheduleAt time.Time
+}
+
+// PeerNotFoundError reports a configured upload peer that is not among the account dialogs.
+type PeerNotFoundError struct {
+	Peer config.TelegramUploadPeer
+	// Candidates are the dialogs whose IDs are the closest to the peer ID, the closest first, to help fixing a
+	// mistyped ID. See [ClosestPeers].
+	Candidates []Peer
+}
+
+func (e *PeerNotFoundError) Error() string {
+	return fmt.Sprintf("%s peer %d not found", e.Peer.Kind, e.Peer.ID)
+}
+
+func (e *PeerNotFoundError) Is(target error) bool {
+	return target == ErrPeerNotFound
 }
 
 type InputPeer struct {
@@ -84,24 +168,53 @@ func (p *InputPeer) ReadHistory(ctx context.Context, client *tg.Client) error {
 	return nil
 }
 
-func NewUploader(ctx context.Context, logger zerolog.Logger, conf config.Telegram) (*Uploader, error) {
-	storage, err := NewStorage(conf.Storage.Path)
+// UploaderOptions are the optional dependencies of an [Uploader]. The zero value is valid.
+type UploaderOptions struct {
+	// Box encrypts the session at rest, if set.
+	Box *secret.Box
+	// Bus receives the upload progress, and FLOOD_WAIT events, if set.
+	Bus *events.Bus
+	// Gate suspends uploads at their checkpoints while it is paused, if set.
+	Gate *pause.Gate
+	// Updates receives the updates of the logged in account, if set, e.g., to receive messages over the upload
+	// session.
+	Updates telegram.UpdateHandler
+}
+
+// NewUploader connects to Telegram using the logged in session, and resolves the configured upload peers. It
+// returns [ErrUnauthorized] if the session is not logged in, and a [*PeerNotFoundError] if a configured peer
+// is not among the account dialogs.
+func NewUploader(
+	ctx context.Context,
+	logger zerolog.Logger,
+	conf config.Telegram,
+	opts UploaderOptions,
+) (*Uploader, error) {
+	tmpl, err := template.New("caption").Parse(conf.Upload.Caption)
+	if nil != err {
+		return nil, fmt.Errorf("parse caption template: %v", err)
+	}
+
+	storage, err := NewStorage(conf.Storage.Path, conf.Storage.Session, opts.Box)
 	if nil != err {
 		return nil, fmt.Errorf("create storage: %v", err)
 	}
 
-	opts, err := newClientOptions(ctx, logger, storage, conf)
+	clientOpts, err := newClientOptions(ctx, logger, storage, conf)
 	if nil != err {
 		return nil, fmt.Errorf("get client options: %w", err)
 	}
 
 	waiter := newWaiterMiddleware(logger)
-	opts.Middlewares = []telegram.Middleware{
+	clientOpts.Middlewares = []telegram.Middleware{
 		waiter,
 		newRateLimitMiddleware(),
 	}
+	if nil != opts.Updates {
+		clientOpts.UpdateHandler = opts.Updates
+	}
 
-	client := telegram.NewClient(conf.AppID, conf.AppHash, *opts)
+	client := telegram.NewClient(conf.AppID, conf.AppHash, *clientOpts)
 
 	stop, err := connect(ctx, logger, client, waiter)
 	if nil != err {
@@ -120,6 +233,13 @@ func NewUploader(ctx context.Context, logger zerolog.Logger, conf config.Telegra
 	}
 	logger.Info().Int64("id", user.ID).Msg("Got self")
 
+	if nil != opts.Updates {
+		// Updates are only pushed to sessions that have fetched the updates state.
+		if _, err := client.API().UpdatesGetState(ctx); nil != err {
+			return nil, fmt.Errorf("get updates state: %w", err)
+		}
+	}
+
 	const maxRecoveryElapsedTime = 5 * time.Minute
 	pool := dcpool.NewPool(
 		client,
@@ -128,84 +248,185 @@ func NewUploader(ctx context.Context, logger zerolog.Logger, conf config.Telegra
 	)
 	tgClient := pool.Default(ctx)
 
+	var signatures *SignatureRotation
+	if len(conf.Upload.Signatures) > 0 {
+		signatures = NewSignatureRotation(conf.Upload.SignatureRotation, conf.Upload.Signatures)
+	}
+
 	var (
-		peer      InputPeer
+		peers     = make([]uploadPeer, len(conf.Upload.Peers))
+		found     int
 		dialogKey dialogs.DialogKey
+		// scanned are the dialogs scanned so far, which are all of them if any peer is not found.
+		scanned []Peer
 	)
 
-	err = query.
-		GetDialogs(tgClient).
-		ForEach(ctx, func(ctx context.Context, elem dialogs.Elem) error {
-			if err := dialogKey.FromInputPeer(elem.Peer); nil != err {
-				return fmt.Errorf("get dialog key: %v", err)
-			}
+	// Peers set by their usernames, or invite links are resolved directly, rather than looked up among dialogs.
+	for i, p := range conf.Upload.Peers {
+		if p.ID != 0 {
+			continue
+		}
 
-			switch dialogKey.Kind {
-			case dialogs.User:
-				if dialogKey.ID == conf.Upload.Peer.ID && conf.Upload.Peer.Kind == "user" {
-					peer = InputPeer{
-						InputPeerClass: elem.Peer,
-						isChannel:      false,
-					}
+		peerLogger := logger.With().Int("peer", i).Str("username", p.Username).Logger()
+		resolved, err := resolvePeer(ctx, peerLogger, tgClient, storage, p, conf.Upload.JoinInvites)
+		if nil != err {
+			return nil, fmt.Errorf("resolve peer %d: %w", i, err)
+		}
+		p.ID, p.Kind = resolved.ID, resolved.Kind
+
+		peers[i] = uploadPeer{
+			InputPeer:  resolved.inputPeer(),
+			conf:       p,
+			signature:  lo.FromPtr(p.Signature),
+			signatures: nil,
+			username:   resolved.Username,
+			posts:      nil,
+			waits:      nil,
+			fits:       nil,
+			notify:     false,
+			scheduleAt: time.Time{},
+		}
+		if nil == p.Signature {
+			peers[i].signatures = signatures
+		}
+		found++
+	}
 
-					return os.ErrExist
+	if found < len(peers) {
+		err = query.
+			GetDialogs(tgClient).
+			ForEach(ctx, func(ctx context.Context, elem dialogs.Elem) error {
+				if err := dialogKey.FromInputPeer(elem.Peer); nil != err {
+					return fmt.Errorf("get dialog key: %v", err)
 				}
-			case dialogs.Chat:
-				if dialogKey.ID == conf.Upload.Peer.ID && conf.Upload.Peer.Kind == "chat" {
-					peer = InputPeer{
-						InputPeerClass: elem.Peer,
-						isChannel:      false,
-					}
 
-					return os.ErrExist
+				var kind string
+				switch dialogKey.Kind {
+				case dialogs.User:
+					kind = "user"
+				case dialogs.Chat:
+					kind = "chat"
+				case dialogs.Channel:
+					kind = "channel"
+				default:
+					panic(fmt.Sprintf("invalid peer kind: %d", dialogKey.Kind))
+				}
+
+				if p, ok := dialogPeer(elem); ok {
+					scanned = append(scanned, p)
 				}
-			case dialogs.Channel:
-				if dialogKey.ID == conf.Upload.Peer.ID && conf.Upload.Peer.Kind == "channel" {
-					peer = InputPeer{
-						InputPeerClass: elem.Peer,
-						isChannel:      true,
+
+				for i, p := range conf.Upload.Peers {
+					if dialogKey.ID != p.ID || kind != p.Kind || nil != peers[i].InputPeerClass {
+						continue
+					}
+
+					var username string
+					if channel, ok := elem.Entities.Channel(dialogKey.ID); ok {
+						username = channel.Username
+					}
+
+					peers[i] = uploadPeer{
+						InputPeer: InputPeer{
+							InputPeerClass: elem.Peer,
+							isChannel:      kind == "channel",
+						},
+						conf:       p,
+						signature:  lo.FromPtr(p.Signature),
+						signatures: nil,
+						username:   username,
+						posts:      nil,
+						waits:      nil,
+						fits:       nil,
+						notify:     false,
+						scheduleAt: time.Time{},
 					}
+					if nil == p.Signature {
+						peers[i].signatures = signatures
+					}
+					found++
+				}
 
+				if found == len(peers) {
 					return os.ErrExist
 				}
-			default:
-				panic(fmt.Sprintf("invalid peer kind: %d", dialogKey.Kind))
-			}
 
-			return nil
-		})
-	if nil != err {
-		if !errors.Is(err, os.ErrExist) {
-			return nil, fmt.Errorf("get dialogs: %w", err)
+				return nil
+			})
+		if nil != err {
+			if !errors.Is(err, os.ErrExist) {
+				return nil, fmt.Errorf("get dialogs: %w", err)
+			}
 		}
 	}
-	if peer.InputPeerClass == nil {
-		return nil, ErrPeerNotFound
+	for i, peer := range peers {
+		if peer.InputPeerClass == nil {
+			p := conf.Upload.Peers[i]
+			return nil, &PeerNotFoundError{Peer: p, Candidates: ClosestPeers(p, scanned)}
+		}
 	}
 
-	_, err = message.
-		NewSender(tgClient).
-		To(peer).
-		Clear().
-		Background().
-		Silent().
-		Text(ctx, "Hey! I'm here to upload your Tidal links.")
-	if nil != err {
-		return nil, fmt.Errorf("send message to peer: %w", err)
+	for _, peer := range peers {
+		_, err = newSender(tgClient, peer).
+			Text(ctx, "Hey! I'm here to upload your Tidal links.")
+		if nil != err {
+			// The cached peer might be stale, e.g., if the username was taken by another peer since.
+			if peer.conf.Username != "" || peer.conf.Invite != "" {
+				if err := storage.DeleteResolvedPeer(resolvedPeerKey(peer.conf)); nil != err {
+					logger.Error().Err(err).Msg("Failed to delete cached resolved peer")
+				}
+			}
+
+			return nil, fmt.Errorf("send message to %s peer %d: %w", peer.conf.Kind, peer.conf.ID, err)
+		}
+
+		if ttl := peer.conf.AutoDelete.Duration; ttl > 0 {
+			_, err := tgClient.MessagesSetHistoryTTL(ctx, &tg.MessagesSetHistoryTTLRequest{
+				Peer:   peer,
+				Period: int(ttl.Seconds()),
+			})
+			if nil != err && !tgerr.Is(err, "CHAT_NOT_MODIFIED") {
+				logger.Warn().Err(err).Int64("peer_id", peer.conf.ID).Msg("Failed to set peer auto-delete timer")
+			}
+		}
 	}
 
+	reads := newHistoryReader(logger, tgClient, conf.Upload.ReadHistory)
+	go reads.run(ctx)
+
 	return &Uploader{
-		storage: storage,
-		client:  tgClient,
-		pool:    pool,
-		stop:    stop,
-		conf:    conf,
-		peer:    peer,
-		logger:  logger,
+		files:            singleflight.Group{},
+		storage:          storage,
+		client:           tgClient,
+		pool:             pool,
+		stop:             stop,
+		conf:             conf,
+		peers:            peers,
+		signatures:       signatures,
+		tmpl:             tmpl,
+		bus:              opts.Bus,
+		reads:            reads,
+		logger:           logger,
+		bandwidth:        ratelimit.NewBandwidth(conf.Upload.MaxBandwidth),
+		gate:             opts.Gate,
+		api:              client.API(),
+		unauthorized:     make(chan struct{}),
+		unauthorizedOnce: sync.Once{},
 	}, nil
 }
 
+// API returns the client of the main connection of the upload session, which receives the updates of
+// [UploaderOptions.Updates].
+func (u *Uploader) API() *tg.Client {
+	return u.api
+}
+
 func (u *Uploader) Close() error {
+	u.logger.Debug().Msg("Marking pending peers as read")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	u.reads.close(ctx)
+	cancel()
+
 	u.logger.Debug().Msg("Closing telegram uploader")
 	if err := u.stop(); nil != err {
 		return fmt.Errorf("stop background client: %v", err)
@@ -227,31 +448,241 @@ func (u *Uploader) Close() error {
 	return nil
 }
 
+// CheckSession returns ErrUnauthorized if the Telegram session was revoked or expired, or another error if
+// Telegram cannot be reached using it, e.g., because the connection silently died.
+func (u *Uploader) CheckSession(ctx context.Context) error {
+	if _, err := u.client.UsersGetUsers(ctx, []tg.InputUserClass{&tg.InputUserSelf{}}); nil != err {
+		if err := u.checkUnauthorized(err); errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+
+		return fmt.Errorf("get self: %w", err)
+	}
+
+	return nil
+}
+
+// Unauthorized returns a channel that is closed once an upload, or a session check finds the session revoked,
+// or expired, e.g., because it was terminated from another device.
+func (u *Uploader) Unauthorized() <-chan struct{} {
+	return u.unauthorized
+}
+
+// checkUnauthorized returns err as ErrUnauthorized if it is caused by the session being no longer authorized,
+// and closes the Unauthorized channel. Other errors are returned as is.
+func (u *Uploader) checkUnauthorized(err error) error {
+	unauthorized := tgerr.Is(
+		err,
+		"AUTH_KEY_UNREGISTERED",
+		"AUTH_KEY_INVALID",
+		"AUTH_KEY_DUPLICATED",
+		"SESSION_REVOKED",
+		"SESSION_EXPIRED",
+		"USER_DEACTIVATED",
+	)
+	if !unauthorized {
+		return err
+	}
+
+	u.unauthorizedOnce.Do(func() {
+		u.logger.Error().Err(err).Msg("Telegram session is no longer authorized")
+		close(u.unauthorized)
+	})
+
+	return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+}
+
+// UploadOptions overrides how a single link is uploaded.
+type UploadOptions struct {
+	// Destination is the username to upload to instead of the configured peers, if set. It must be one of
+	// the allowed destinations.
+	Destination string
+	// Archive uploads albums as ZIP archives, regardless of the configured upload mode.
+	Archive bool
+	// Additions uploads the downloaded album tracks as additions to the album posted before, e.g., tracks of
+	// its deluxe edition, replying to the album post. Additions are never uploaded as archives.
+	Additions bool
+	// Pipeline hands over the album tracks as they are downloaded, if set, so that the album is uploaded while
+	// it is still being downloaded. See [Uploader.Pipelines].
+	Pipeline *pipeline.Album
+	// Oversized collects the tracks larger than the max upload file size, by how they were uploaded, if set.
+	Oversized *OversizedTracks
+	// Notify sends the posts with notifications, regardless of the configured notify option.
+	Notify bool
+	// ScheduleAt schedules the posts to be posted at it, if set, rather than posting them right away. Posts
+	// are scheduled at the same time, and are posted in order.
+	ScheduleAt time.Time
+	// Stream streams the track file while it is being tagged, if set, so that the track is uploaded while it is
+	// still being downloaded. See [Uploader.Streams].
+	Stream *pipeline.Track
+}
+
+// Pipelines reports whether link can be uploaded with opts while it is still being downloaded. Only albums
+// that are uploaded as separate tracks, and not as additions, can.
+func (u *Uploader) Pipelines(link types.Link, opts UploadOptions) bool {
+	archive := opts.Archive || u.conf.Upload.Archive.Albums
+
+	return u.conf.Upload.Pipelined && link.Kind == types.LinkKindAlbum && !archive && !opts.Additions
+}
+
+// Streams reports whether link can be uploaded with opts while its file is still being tagged. Only single
+// tracks can.
+func (u *Uploader) Streams(link types.Link, opts UploadOptions) bool {
+	return u.conf.Upload.StreamSingles && link.Kind == types.LinkKindTrack && !opts.Additions
+}
+
+// Upload uploads the downloaded link to all configured peers, in order. Tracks are only uploaded once, and
+// are sent to the rest of the peers by reusing their uploaded documents. It returns the t.me links of the
+// posts sent to channels, in the order they were sent.
 func (u *Uploader) Upload(
 	ctx context.Context,
 	logger zerolog.Logger,
 	dir fs.DownloadsDir,
 	link types.Link,
-) (err error) {
+	opts UploadOptions,
+) ([]string, error) {
+	urls, err := u.upload(ctx, logger, dir, link, opts)
+	if nil != err {
+		return nil, withFloodCategory(u.checkUnauthorized(err))
+	}
+
+	return urls, nil
+}
+
+func (u *Uploader) upload(
+	ctx context.Context,
+	logger zerolog.Logger,
+	dir fs.DownloadsDir,
+	link types.Link,
+	opts UploadOptions,
+) ([]string, error) {
+	opts.Archive = (opts.Archive || u.conf.Upload.Archive.Albums) && !opts.Additions
+	// The track is not streamed anymore once the upload ends, e.g., as it failed before the track was streamed.
+	defer opts.Stream.Close()
+	posts := &postLinks{urls: nil}
+	waits := new(floodWaits)
+	fits := newTrackFits(u.conf.Upload.Oversized, dir, opts.Oversized)
+	notify := u.conf.Upload.Notify || opts.Notify
 	defer func() {
-		if nil == err {
-			if err := u.peer.ReadHistory(ctx, u.client); nil != err {
-				logger.Error().Err(err).Msg("Failed to read peer history")
-			}
+		if total := time.Duration(waits.total.Load()); total > 0 {
+			logger.Info().Dur("total_flood_wait", total).Msg("Waited on FLOOD_WAIT errors during upload")
 		}
+		fits.remove(logger)
 	}()
 
-	switch link.Kind {
+	err := u.eachPeer(ctx, logger, opts.Destination, func(logger zerolog.Logger, peer uploadPeer) error {
+		peer.posts = posts
+		peer.waits = waits
+		peer.fits = fits
+		peer.notify = notify
+		peer.scheduleAt = opts.ScheduleAt
+
+		return u.uploadTo(ctx, logger, peer, dir, link, opts)
+	})
+	if nil != err {
+		return nil, err
+	}
+	u.reads.linkUploaded(ctx)
+
+	return posts.urls, nil
+}
+
+// eachPeer calls fn with the peer of the username dest, if set, or with each configured peer, in order, and
+// a logger describing it. It stops at the first peer fn fails for.
+func (u *Uploader) eachPeer(
+	ctx context.Context,
+	logger zerolog.Logger,
+	dest string,
+	fn func(logger zerolog.Logger, peer uploadPeer) error,
+) error {
+	if len(dest) > 0 {
+		peer, err := u.resolveDestination(ctx, dest)
+		if nil != err {
+			return fmt.Errorf("resolve destination @%s: %w", dest, err)
+		}
+
+		return fn(logger.With().Str("destination", dest).Logger(), peer)
+	}
+
+	for _, peer := range u.peers {
+		logger := logger.With().Str("peer_kind", peer.conf.Kind).Int64("peer_id", peer.conf.ID).Logger()
+		if err := fn(logger, peer); nil != err {
+			if len(u.peers) == 1 {
+				return err
+			}
+
+			return fmt.Errorf("upload to %s peer %d: %w", peer.conf.Kind, peer.conf.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (u *Uploader) uploadTo(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	link types.Link,
+	opts UploadOptions,
+) error {
+	// The posts of the link parts are linked in the album summary.
+	posted := peer.posts.count()
+
+	if err := u.uploadLink(ctx, logger, peer, dir, link, opts); nil != err {
+		return err
+	}
+
+	if err := u.sendTrackParts(ctx, logger, peer); nil != err {
+		return fmt.Errorf("send track parts: %w", err)
+	}
+
+	if u.conf.Upload.Sidecars {
+		if err := u.uploadSidecar(ctx, logger, peer, dir, link); nil != err {
+			return fmt.Errorf("upload metadata sidecar: %w", err)
+		}
+	}
+
+	if u.conf.Upload.LyricsFiles {
+		if err := u.uploadLyricsFiles(ctx, logger, peer, dir, link); nil != err {
+			return fmt.Errorf("upload lyrics files: %w", err)
+		}
+	}
+
+	if link.Kind == types.LinkKindAlbum && !opts.Additions && u.conf.Upload.Summary.Enabled {
+		if err := u.sendAlbumSummary(ctx, logger, peer, dir, link.ID, peer.posts.since(posted)); nil != err {
+			return err
+		}
+	}
+	u.reads.add(peer.InputPeer)
+
+	return nil
+}
+
+func (u *Uploader) uploadLink(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	link types.Link,
+	opts UploadOptions,
+) error {
+	// Custom link kinds are stored, and uploaded, as playlists.
+	switch link := link.StoredAs(); link.Kind {
 	case types.LinkKindTrack:
-		return u.uploadTrack(ctx, logger, dir, link.ID)
+		return u.uploadTrack(ctx, logger, peer, dir, link.ID, opts.Stream)
 	case types.LinkKindAlbum:
-		return u.uploadAlbum(ctx, logger, dir, link.ID)
+		if opts.Archive {
+			return u.uploadAlbumArchive(ctx, logger, peer, dir, link.ID)
+		}
+
+		return u.uploadAlbum(ctx, logger, peer, dir, link.ID, opts.Additions, opts.Pipeline)
 	case types.LinkKindPlaylist:
-		return u.uploadPlaylist(ctx, logger, dir, link.ID)
+		return u.uploadPlaylist(ctx, logger, peer, dir, link.ID)
 	case types.LinkKindMix:
-		return u.uploadMix(ctx, logger, dir, link.ID)
+		return u.uploadMix(ctx, logger, peer, dir, link.ID)
 	case types.LinkKindArtistCredits:
-		return u.uploadArtistCredits(ctx, logger, dir, link.ID)
+		return u.uploadArtistCredits(ctx, logger, peer, dir, link.ID)
 	case types.LinkKindVideo:
 		return errors.New("artist links are not supported")
 	case types.LinkKindArtist:
@@ -261,6 +692,15 @@ func (u *Uploader) Upload(
 	}
 }
 
+// trackBatches splits trackIDs into media groups of about the same size.
+func trackBatches(trackIDs []string) [][]string {
+	if len(trackIDs) == 0 {
+		return nil
+	}
+
+	return slices.Collect(slices.Chunk(trackIDs, mathutil.OptimalAlbumSize(len(trackIDs))))
+}
+
 func (u *Uploader) newUploader(ctx context.Context) *uploader.Uploader {
 	return uploader.
 		NewUploader(u.pool.Default(ctx)).
@@ -268,39 +708,83 @@ func (u *Uploader) newUploader(ctx context.Context) *uploader.Uploader {
 		WithThreads(u.conf.Upload.Threads)
 }
 
+// uploadPath uploads the file at path using up, reading it no faster than the upload bandwidth limit allows.
+func (u *Uploader) uploadPath(ctx context.Context, up *uploader.Uploader, path string) (_ tg.InputFileClass, err error) {
+	if nil == u.bandwidth {
+		return up.FromPath(ctx, path)
+	}
+
+	f, err := os.Open(path)
+	if nil != err {
+		return nil, fmt.Errorf("open file: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+		}
+	}()
+
+	stat, err := f.Stat()
+	if nil != err {
+		return nil, fmt.Errorf("stat file: %v", err)
+	}
+
+	return up.Upload(ctx, uploader.NewUpload(filepath.Base(path), ratelimit.Reader(ctx, f, u.bandwidth), stat.Size()))
+}
+
 func (u *Uploader) uploadAlbum(
 	ctx context.Context,
 	logger zerolog.Logger,
+	peer uploadPeer,
 	dir fs.DownloadsDir,
 	id string,
+	additions bool,
+	pipe *pipeline.Album,
 ) (err error) {
 	albumFs := dir.Album(id)
 
-	info, err := albumFs.InfoFile.Read()
-	if nil != err {
+	var info *types.StoredAlbum
+	if nil != pipe {
+		// The album info file is only written once the album is downloaded.
+		if info, err = pipe.Layout(ctx); nil != err {
+			return fmt.Errorf("wait for album layout: %w", err)
+		}
+	} else if info, err = albumFs.InfoFile.Read(); nil != err {
 		return fmt.Errorf("read playlist info file: %v", err)
 	}
 
-	coverStat, err := os.Lstat(albumFs.Cover.Path)
+	var replyTo int
+	if additions {
+		if replyTo, err = u.sendAdditionsHeader(ctx, logger, peer, id, info); nil != err {
+			return fmt.Errorf("send album additions header: %w", err)
+		}
+	}
+
+	// Covers are uploaded as document thumbnails, which Telegram requires to be small.
+	if _, err := albumFs.Cover.Thumbnail(); nil != err {
+		return fmt.Errorf("create album cover thumbnail: %v", err)
+	}
+
+	coverStat, err := os.Lstat(albumFs.Cover.ThumbnailPath())
 	if nil != err {
-		return fmt.Errorf("stat album cover file: %v", err)
+		return fmt.Errorf("stat album cover thumbnail file: %v", err)
 	}
 	if !coverStat.Mode().IsRegular() {
-		return fmt.Errorf("album cover file %q is not a regular file", albumFs.Cover.Path)
+		return fmt.Errorf("album cover thumbnail file %q is not a regular file", albumFs.Cover.ThumbnailPath())
 	}
 	if coverStat.Size() == 0 {
-		return errors.New("album cover file is empty")
+		return errors.New("album cover thumbnail file is empty")
 	}
 
 	coverProgress := &progress.Cover{Size: coverStat.Size()}
 	coverMonitor := progress.NewCoverMonitor(coverProgress)
 
 	typingWait := make(chan struct{})
-	go u.keepTyping(ctx, coverMonitor, typingWait, logger)
+	go u.keepTyping(ctx, peer, coverMonitor, typingWait, logger)
 
-	coverInputFile, err := u.newUploader(ctx).WithProgress(coverProgress).FromPath(ctx, albumFs.Cover.Path)
+	coverInputFile, err := u.uploadFile(ctx, logger, albumFs.Cover.ThumbnailPath(), coverProgress)
 	if nil != err {
-		return fmt.Errorf("upload album track cover file: %w", err)
+		return newUploadError(nil, fmt.Errorf("upload album track cover file: %w", err))
 	}
 
 	select {
@@ -309,145 +793,251 @@ func (u *Uploader) uploadAlbum(
 		return fmt.Errorf("wait for typing: %w", ctx.Err())
 	}
 
+	if !additions {
+		if err := u.sendVideoCover(ctx, logger, peer, albumFs); nil != err {
+			return fmt.Errorf("send album video cover: %w", err)
+		}
+		if err := u.sendOriginalCover(ctx, logger, peer, albumFs, info.Album); nil != err {
+			return fmt.Errorf("send original album cover: %w", err)
+		}
+	}
+
+	// Pipelined albums tracks are only checked as they are downloaded.
+	if nil == pipe {
+		if err := peer.fits.fitAll(ctx, logger, slices.Concat(info.VolumeTrackIDs...)); nil != err {
+			return fmt.Errorf("fit oversized album tracks: %w", err)
+		}
+	}
+
+	type albumBatchSpec struct {
+		volNum   int
+		trackIDs []string
+	}
+	var specs []albumBatchSpec
 	for volIdx, trackIDs := range info.VolumeTrackIDs {
-		var (
-			volNum    = volIdx + 1
-			batchSize = mathutil.OptimalAlbumSize(len(trackIDs))
-			batches   = slices.Collect(slices.Chunk(trackIDs, batchSize))
-		)
-		for _, trackIDs := range batches {
-			monitor := progress.NewAlbumMonitor(len(trackIDs))
-			for i, trackID := range trackIDs {
-				logger := logger.With().Int("index", i).Str("track_id", trackID).Logger()
+		if len(trackIDs) == 0 {
+			// Volumes without added tracks are empty when uploading album additions.
+			continue
+		}
 
-				track := albumFs.Track(volNum, trackID)
+		batchSize := mathutil.OptimalAlbumSize(len(trackIDs))
+		for trackIDs := range slices.Chunk(trackIDs, batchSize) {
+			specs = append(specs, albumBatchSpec{volNum: volIdx + 1, trackIDs: trackIDs})
+		}
+	}
 
-				trackStat, err := os.Lstat(track.Path)
-				if nil != err {
-					logger.Error().Err(err).Msg("Failed to stat album track file")
-					return fmt.Errorf("stat album track file: %v", err)
-				}
-				if !trackStat.Mode().IsRegular() {
-					return fmt.Errorf("album track file %q is not a regular file", track.Path)
-				}
-				if trackStat.Size() == 0 {
-					return errors.New("album track file is empty")
-				}
+	// The files of the next media group are uploaded while the current one is sent, and the pause after it
+	// passes. Media groups are still sent in order. The upload in progress is canceled, and waited for, on
+	// return, as its files might be removed afterward.
+	uploadCtx, cancelUpload := context.WithCancel(ctx)
+	var next <-chan albumBatch
+	startUpload := func(spec albumBatchSpec) {
+		ch := make(chan albumBatch, 1)
+		go func() {
+			media, typingWait, err := u.uploadAlbumBatch(
+				uploadCtx,
+				logger,
+				peer,
+				albumFs,
+				info,
+				pipe,
+				spec.volNum,
+				spec.trackIDs,
+				coverInputFile,
+			)
+			ch <- albumBatch{media: media, typingWait: typingWait, err: err}
+		}()
+		next = ch
+	}
+	defer func() {
+		cancelUpload()
+		if nil != next {
+			<-next
+		}
+	}()
+	if len(specs) > 0 {
+		startUpload(specs[0])
+	}
 
-				trackProgress := &progress.Track{Size: trackStat.Size()}
+	posted := false
+	for i := range specs {
+		batch := <-next
+		next = nil
+		if nil != batch.err {
+			return batch.err
+		}
+		if i+1 < len(specs) {
+			startUpload(specs[i+1])
+		}
+		if len(batch.media) == 0 {
+			continue
+		}
 
-				monitor.Set(i, trackProgress)
-			}
+		// Track numbers are taken from the stored tracks, as skipped tracks leave gaps in them.
+		media := batch.media
+		last := &media[len(media)-1].Caption
+		last.TrackRange = albumTrackRange(media[0].Caption, *last, len(info.VolumeTrackIDs))
 
-			wg, wgctx := errgroup.WithContext(ctx)
-			wg.SetLimit(u.conf.Upload.Limit)
+		stored := StoredBatch{ReplyTo: replyTo, Media: media} //nolint:exhaustruct
+		if !additions && !posted {
+			stored.AlbumID = id
+		}
+		if unsent, err := u.sendBatch(ctx, logger, peer, stored); nil != err {
+			return newUploadError(unsent, fmt.Errorf("send album: %w", err))
+		}
+		posted = true
 
-			typingWait := make(chan struct{})
-			go u.keepTyping(ctx, monitor, typingWait, logger)
+		select {
+		case <-batch.typingWait:
+			time.Sleep(u.pause(len(media)))
+		case <-ctx.Done():
+			return fmt.Errorf("wait for typing: %w", ctx.Err())
+		}
+	}
 
-			album := make([]message.MultiMediaOption, len(trackIDs))
-			for idx, trackID := range trackIDs {
-				wg.Go(func() error {
-					select {
-					case <-wgctx.Done():
-						return nil
-					default:
-					}
+	// Whatever is uploaded after the album tracks, e.g., its sidecar, reads the album info file.
+	if err := pipe.Wait(ctx); nil != err {
+		return fmt.Errorf("wait for album download: %w", err)
+	}
 
-					logger := logger.With().Int("index", idx).Str("track_id", trackID).Logger()
+	return nil
+}
 
-					track := albumFs.Track(volNum, trackID)
+// albumBatch is a media group of album tracks whose files are uploaded, and are ready to be sent.
+type albumBatch struct {
+	media []StoredBatchMedia
+	// typingWait is closed once the typing action sent during the upload is stopped.
+	typingWait <-chan struct{}
+	err        error
+}
 
-					trackInfo, err := track.InfoFile.Read()
-					if nil != err {
-						logger.Error().Err(err).Msg("Failed to read album track info file")
-						return fmt.Errorf("read album track info file: %v", err)
-					}
+// uploadAlbumBatch waits for the tracks with trackIDs of the volume with volNum of the album to be downloaded,
+// and uploads their files. It returns the media group of the tracks, which is empty if none of the tracks are
+// uploadable, e.g., as they are skipped for being larger than the max file size, and a channel that is closed
+// once the typing action sent during the upload is stopped.
+func (u *Uploader) uploadAlbumBatch(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	albumFs fs.Album,
+	info *types.StoredAlbum,
+	pipe *pipeline.Album,
+	volNum int,
+	trackIDs []string,
+	coverInputFile tg.InputFileClass,
+) ([]StoredBatchMedia, <-chan struct{}, error) {
+	if err := pipe.WaitTracks(ctx, trackIDs); nil != err {
+		return nil, nil, fmt.Errorf("wait for album tracks download: %w", err)
+	}
 
-					trackProgress := monitor.At(idx)
+	if err := peer.fits.fitAll(ctx, logger, trackIDs); nil != err {
+		return nil, nil, fmt.Errorf("fit oversized album tracks: %w", err)
+	}
+	if trackIDs = peer.fits.uploadable(trackIDs); len(trackIDs) == 0 {
+		return nil, nil, nil
+	}
 
-					trackInputFile, err := u.newUploader(wgctx).WithProgress(trackProgress).FromPath(wgctx, track.Path)
-					if nil != err {
-						logger.Error().Err(err).Msg("Failed to upload album track file")
-						return fmt.Errorf("upload album track file: %w", err)
-					}
+	monitor := progress.NewAlbumMonitor(len(trackIDs))
+	for i, trackID := range trackIDs {
+		logger := logger.With().Int("index", i).Str("track_id", trackID).Logger()
 
-					mime, err := mimetype.DetectFile(track.Path)
-					if nil != err {
-						logger.Error().Err(err).Msg("Failed to detect album track mime")
-						return fmt.Errorf("detect album track mime: %v", err)
-					}
+		track := albumFs.Track(volNum, trackID)
+		track.Path = peer.fits.path(trackID, track.Path)
 
-					const notCollapsed = false
-					caption := []message.StyledTextOption{
-						styling.Blockquote(info.Caption, notCollapsed),
-						styling.Plain("\n"),
-						styling.Italic(fmt.Sprintf("Disc %d / Track %d", trackInfo.VolumeNumber, trackInfo.TrackNumber)),
-					}
-					if sig := u.conf.Upload.Signature; len(sig) > 0 {
-						caption = append(caption, html.String(nil, sig))
-					}
+		trackStat, err := os.Lstat(track.Path)
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to stat album track file")
+			return nil, nil, fmt.Errorf("stat album track file: %v", err)
+		}
+		if !trackStat.Mode().IsRegular() {
+			return nil, nil, fmt.Errorf("album track file %q is not a regular file", track.Path)
+		}
+		if trackStat.Size() == 0 {
+			return nil, nil, errors.New("album track file is empty")
+		}
 
-					doc := message.
-						UploadedDocument(trackInputFile, caption...).
-						MIME(mime.String()).
-						Attributes(
-							&tg.DocumentAttributeFilename{
-								FileName: trackInfo.UploadFilename(),
-							},
-							//nolint:exhaustruct
-							&tg.DocumentAttributeAudio{
-								Title:     trackInfo.Title,
-								Performer: types.JoinArtists(trackInfo.Artists),
-								Duration:  trackInfo.Duration,
-							}).
-						Thumb(coverInputFile).
-						Audio().
-						DurationSeconds(trackInfo.Duration).
-						Performer(types.JoinArtists(trackInfo.Artists)).
-						Title(trackInfo.Title)
-
-					album[idx] = doc
+		trackProgress := &progress.Track{Size: trackStat.Size()}
 
-					return nil
-				})
+		monitor.Set(i, trackProgress)
+	}
+
+	wg, wgctx := errgroup.WithContext(ctx)
+	wg.SetLimit(u.conf.Upload.Limit)
+
+	typingWait := make(chan struct{})
+	go u.keepTyping(ctx, peer, monitor, typingWait, logger)
+
+	media := make([]StoredBatchMedia, len(trackIDs))
+	for idx, trackID := range trackIDs {
+		wg.Go(func() error {
+			select {
+			case <-wgctx.Done():
+				return nil
+			default:
 			}
 
-			if err := wg.Wait(); nil != err {
-				return fmt.Errorf("upload album: %w", err)
+			logger := logger.With().Int("index", idx).Str("track_id", trackID).Logger()
+
+			track := albumFs.Track(volNum, trackID)
+			track.Path = peer.fits.path(trackID, track.Path)
+
+			trackInfo, err := track.InfoFile.Read()
+			if nil != err {
+				logger.Error().Err(err).Msg("Failed to read album track info file")
+				return fmt.Errorf("read album track info file: %v", err)
 			}
 
-			var rest []message.MultiMediaOption
-			if len(album) > 1 {
-				rest = album[1:]
+			trackProgress := monitor.At(idx)
+
+			caption := newCaptionData(info.Album, trackInfo.Track)
+
+			if uploaded := u.uploadedTrack(logger, trackID, trackInfo.Metadata.TidalID); nil != uploaded {
+				trackProgress.Complete()
+				media[idx] = reusedBatchMedia(trackID, caption, uploaded)
+
+				return nil
 			}
 
-			_, err = message.
-				NewSender(u.client).
-				To(u.peer).
-				Clear().
-				Background().
-				Silent().
-				Album(ctx, album[0], rest...)
+			trackInputFile, err := u.uploadFile(wgctx, logger, track.Path, trackProgress)
 			if nil != err {
-				return fmt.Errorf("send mix: %w", err)
+				logger.Error().Err(err).Msg("Failed to upload album track file")
+				return newUploadError([]string{trackID}, fmt.Errorf("upload album track file: %w", err))
 			}
 
-			select {
-			case <-typingWait:
-				time.Sleep(u.conf.Upload.PauseDuration.Duration)
-			case <-ctx.Done():
-				return fmt.Errorf("wait for typing: %w", ctx.Err())
+			mime, err := mimetype.DetectFile(track.Path)
+			if nil != err {
+				logger.Error().Err(err).Msg("Failed to detect album track mime")
+				return fmt.Errorf("detect album track mime: %v", err)
 			}
-		}
+
+			media[idx], err = uploadedBatchMedia(
+				trackID,
+				caption,
+				trackInputFile,
+				coverInputFile,
+				mime.String(),
+				trackInfo.UploadFilename(),
+				trackInfo.Track,
+			)
+			if nil != err {
+				return fmt.Errorf("build track media: %v", err)
+			}
+
+			return nil
+		})
 	}
 
-	return nil
+	if err := wg.Wait(); nil != err {
+		return nil, nil, fmt.Errorf("upload album: %w", err)
+	}
+
+	return media, typingWait, nil
 }
 
 func (u *Uploader) uploadMix(
 	ctx context.Context,
 	logger zerolog.Logger,
+	peer uploadPeer,
 	dir fs.DownloadsDir,
 	id string,
 ) (err error) {
@@ -457,16 +1047,18 @@ func (u *Uploader) uploadMix(
 		return fmt.Errorf("read playlist info file: %v", err)
 	}
 
-	var (
-		batchSize = mathutil.OptimalAlbumSize(len(info.TrackIDs))
-		batches   = slices.Collect(slices.Chunk(info.TrackIDs, batchSize))
-	)
+	if err := peer.fits.fitAll(ctx, logger, info.TrackIDs); nil != err {
+		return fmt.Errorf("fit oversized mix tracks: %w", err)
+	}
+
+	batches := trackBatches(peer.fits.uploadable(info.TrackIDs))
 	for _, trackIDs := range batches {
 		monitor := progress.NewBatchMonitor(len(trackIDs))
 		for i, trackID := range trackIDs {
 			logger := logger.With().Int("index", i).Str("track_id", trackID).Logger()
 
 			track := mixFs.Track(trackID)
+			track.Path = peer.fits.path(trackID, track.Path)
 
 			trackStat, err := os.Lstat(track.Path)
 			if nil != err {
@@ -482,16 +1074,21 @@ func (u *Uploader) uploadMix(
 
 			trackProgress := &progress.Track{Size: trackStat.Size()}
 
-			coverStat, err := os.Lstat(track.Cover.Path)
+			if _, err := track.Cover.Thumbnail(); nil != err {
+				logger.Error().Err(err).Msg("Failed to create mix track cover thumbnail")
+				return fmt.Errorf("create mix track cover thumbnail: %v", err)
+			}
+
+			coverStat, err := os.Lstat(track.Cover.ThumbnailPath())
 			if nil != err {
-				logger.Error().Err(err).Msg("Failed to stat mix track cover file")
-				return fmt.Errorf("stat mix track cover file: %v", err)
+				logger.Error().Err(err).Msg("Failed to stat mix track cover thumbnail file")
+				return fmt.Errorf("stat mix track cover thumbnail file: %v", err)
 			}
 			if !coverStat.Mode().IsRegular() {
-				return fmt.Errorf("mix track cover file %q is not a regular file", track.Cover.Path)
+				return fmt.Errorf("mix track cover thumbnail file %q is not a regular file", track.Cover.ThumbnailPath())
 			}
 			if coverStat.Size() == 0 {
-				return errors.New("mix track cover file is empty")
+				return errors.New("mix track cover thumbnail file is empty")
 			}
 
 			coverProgress := &progress.Cover{Size: coverStat.Size()}
@@ -503,9 +1100,9 @@ func (u *Uploader) uploadMix(
 		wg.SetLimit(u.conf.Upload.Limit)
 
 		typingWait := make(chan struct{})
-		go u.keepTyping(ctx, monitor, typingWait, logger)
+		go u.keepTyping(ctx, peer, monitor, typingWait, logger)
 
-		album := make([]message.MultiMediaOption, len(trackIDs))
+		media := make([]StoredBatchMedia, len(trackIDs))
 		for i, trackID := range trackIDs {
 			wg.Go(func() (err error) {
 				select {
@@ -517,17 +1114,34 @@ func (u *Uploader) uploadMix(
 				logger := logger.With().Int("index", i).Str("track_id", trackID).Logger()
 
 				track := mixFs.Track(trackID)
+				track.Path = peer.fits.path(trackID, track.Path)
 
 				trackProgress, coverProgress := monitor.At(i)
 
-				trackInputFile, err := u.newUploader(wgctx).WithProgress(trackProgress).FromPath(wgctx, track.Path)
+				trackInfo, err := track.InfoFile.Read()
+				if nil != err {
+					logger.Error().Err(err).Msg("Failed to read mix track info file")
+					return fmt.Errorf("read mix track info file: %v", err)
+				}
+
+				caption := newCaptionData(trackInfo.Album, trackInfo.Track)
+
+				if uploaded := u.uploadedTrack(logger, trackID, trackInfo.Metadata.TidalID); nil != uploaded {
+					trackProgress.Complete()
+					coverProgress.Complete()
+					media[i] = reusedBatchMedia(trackID, caption, uploaded)
+
+					return nil
+				}
+
+				trackInputFile, err := u.uploadFile(wgctx, logger, track.Path, trackProgress)
 				if nil != err {
-					return fmt.Errorf("upload mix track file: %w", err)
+					return newUploadError([]string{trackID}, fmt.Errorf("upload mix track file: %w", err))
 				}
 
-				coverInputFile, err := u.newUploader(wgctx).WithProgress(coverProgress).FromPath(wgctx, track.Cover.Path)
+				coverInputFile, err := u.uploadFile(wgctx, logger, track.Cover.ThumbnailPath(), coverProgress)
 				if nil != err {
-					return fmt.Errorf("upload mix track cover file: %w", err)
+					return newUploadError([]string{trackID}, fmt.Errorf("upload mix track cover file: %w", err))
 				}
 
 				mime, err := mimetype.DetectFile(track.Path)
@@ -536,43 +1150,19 @@ func (u *Uploader) uploadMix(
 					return fmt.Errorf("detect mix mime: %v", err)
 				}
 
-				trackInfo, err := track.InfoFile.Read()
+				media[i], err = uploadedBatchMedia(
+					trackID,
+					caption,
+					trackInputFile,
+					coverInputFile,
+					mime.String(),
+					trackInfo.UploadFilename(),
+					trackInfo.Track,
+				)
 				if nil != err {
-					logger.Error().Err(err).Msg("Failed to read mix track info file")
-					return fmt.Errorf("read mix track info file: %v", err)
-				}
-
-				const notCollapsed = false
-				caption := []message.StyledTextOption{
-					styling.Blockquote(trackInfo.Caption, notCollapsed),
-					styling.Plain("\n"),
-					styling.Italic(fmt.Sprintf("Disc %d / Track %d", trackInfo.VolumeNumber, trackInfo.TrackNumber)),
-				}
-				if sig := u.conf.Upload.Signature; len(sig) > 0 {
-					caption = append(caption, html.String(nil, sig))
+					return fmt.Errorf("build track media: %v", err)
 				}
 
-				doc := message.
-					UploadedDocument(trackInputFile, caption...).
-					MIME(mime.String()).
-					Attributes(
-						&tg.DocumentAttributeFilename{
-							FileName: trackInfo.UploadFilename(),
-						},
-						//nolint:exhaustruct
-						&tg.DocumentAttributeAudio{
-							Title:     trackInfo.Title,
-							Performer: types.JoinArtists(trackInfo.Artists),
-							Duration:  trackInfo.Duration,
-						}).
-					Thumb(coverInputFile).
-					Audio().
-					DurationSeconds(trackInfo.Duration).
-					Performer(types.JoinArtists(trackInfo.Artists)).
-					Title(trackInfo.Title)
-
-				album[i] = doc
-
 				return nil
 			})
 		}
@@ -581,36 +1171,31 @@ func (u *Uploader) uploadMix(
 			return fmt.Errorf("wait for upload mix tracks: %w", err)
 		}
 
-		var rest []message.MultiMediaOption
-		if len(album) > 1 {
-			rest = album[1:]
-		}
-
-		_, err = message.
-			NewSender(u.client).
-			To(u.peer).
-			Clear().
-			Background().
-			Silent().
-			Album(ctx, album[0], rest...)
-		if nil != err {
-			return fmt.Errorf("send mix: %w", err)
+		batch := StoredBatch{Media: media} //nolint:exhaustruct
+		if unsent, err := u.sendBatch(ctx, logger, peer, batch); nil != err {
+			return newUploadError(unsent, fmt.Errorf("send mix: %w", err))
 		}
 
 		select {
 		case <-typingWait:
-			time.Sleep(u.conf.Upload.PauseDuration.Duration)
+			time.Sleep(u.pause(len(trackIDs)))
 		case <-ctx.Done():
 			return fmt.Errorf("wait for typing: %w", ctx.Err())
 		}
 	}
 
+	trackInfoFile := func(id string) fs.InfoFile[types.StoredTrack] { return mixFs.Track(id).InfoFile }
+	if err := u.uploadPlaylistFile(ctx, logger, peer, info.Caption, info.TrackIDs, trackInfoFile); nil != err {
+		return fmt.Errorf("upload playlist file: %w", err)
+	}
+
 	return nil
 }
 
 func (u *Uploader) uploadArtistCredits(
 	ctx context.Context,
 	logger zerolog.Logger,
+	peer uploadPeer,
 	dir fs.DownloadsDir,
 	id string,
 ) (err error) {
@@ -620,16 +1205,18 @@ func (u *Uploader) uploadArtistCredits(
 		return fmt.Errorf("read artist credits info file: %v", err)
 	}
 
-	var (
-		batchSize = mathutil.OptimalAlbumSize(len(info.TrackIDs))
-		batches   = slices.Collect(slices.Chunk(info.TrackIDs, batchSize))
-	)
+	if err := peer.fits.fitAll(ctx, logger, info.TrackIDs); nil != err {
+		return fmt.Errorf("fit oversized artist credits tracks: %w", err)
+	}
+
+	batches := trackBatches(peer.fits.uploadable(info.TrackIDs))
 	for _, trackIDs := range batches {
 		monitor := progress.NewBatchMonitor(len(trackIDs))
 		for i, trackID := range trackIDs {
 			logger := logger.With().Int("index", i).Str("track_id", trackID).Logger()
 
 			track := creditsFs.Track(trackID)
+			track.Path = peer.fits.path(trackID, track.Path)
 
 			trackStat, err := os.Lstat(track.Path)
 			if nil != err {
@@ -645,16 +1232,21 @@ func (u *Uploader) uploadArtistCredits(
 
 			trackProgress := &progress.Track{Size: trackStat.Size()}
 
-			coverStat, err := os.Lstat(track.Cover.Path)
+			if _, err := track.Cover.Thumbnail(); nil != err {
+				logger.Error().Err(err).Msg("Failed to create artist credits track cover thumbnail")
+				return fmt.Errorf("create artist credits track cover thumbnail: %v", err)
+			}
+
+			coverStat, err := os.Lstat(track.Cover.ThumbnailPath())
 			if nil != err {
-				logger.Error().Err(err).Msg("Failed to stat artist credits track cover file")
-				return fmt.Errorf("stat artist credits track cover file: %v", err)
+				logger.Error().Err(err).Msg("Failed to stat artist credits track cover thumbnail file")
+				return fmt.Errorf("stat artist credits track cover thumbnail file: %v", err)
 			}
 			if !coverStat.Mode().IsRegular() {
-				return fmt.Errorf("artist credits track cover file %q is not a regular file", track.Cover.Path)
+				return fmt.Errorf("artist credits track cover thumbnail file %q is not a regular file", track.Cover.ThumbnailPath())
 			}
 			if coverStat.Size() == 0 {
-				return errors.New("artist credits track cover file is empty")
+				return errors.New("artist credits track cover thumbnail file is empty")
 			}
 
 			coverProgress := &progress.Cover{Size: coverStat.Size()}
@@ -666,9 +1258,9 @@ func (u *Uploader) uploadArtistCredits(
 		wg.SetLimit(u.conf.Upload.Limit)
 
 		typingWait := make(chan struct{})
-		go u.keepTyping(ctx, monitor, typingWait, logger)
+		go u.keepTyping(ctx, peer, monitor, typingWait, logger)
 
-		album := make([]message.MultiMediaOption, len(trackIDs))
+		media := make([]StoredBatchMedia, len(trackIDs))
 		for idx, trackID := range trackIDs {
 			wg.Go(func() error {
 				select {
@@ -680,23 +1272,34 @@ func (u *Uploader) uploadArtistCredits(
 				logger := logger.With().Int("index", idx).Str("track_id", trackID).Logger()
 
 				track := creditsFs.Track(trackID)
+				track.Path = peer.fits.path(trackID, track.Path)
 
 				trackProgress, coverProgress := monitor.At(idx)
 
-				trackInputFile, err := u.newUploader(wgctx).WithProgress(trackProgress).FromPath(wgctx, track.Path)
+				trackInfo, err := track.InfoFile.Read()
 				if nil != err {
-					return fmt.Errorf("upload artist credits track file: %w", err)
+					logger.Error().Err(err).Msg("Failed to read artist credits track info file")
+					return fmt.Errorf("read artist credits track info file: %v", err)
+				}
+
+				caption := newCaptionData(trackInfo.Album, trackInfo.Track)
+
+				if uploaded := u.uploadedTrack(logger, trackID, trackInfo.Metadata.TidalID); nil != uploaded {
+					trackProgress.Complete()
+					coverProgress.Complete()
+					media[idx] = reusedBatchMedia(trackID, caption, uploaded)
+
+					return nil
 				}
 
-				coverInputFile, err := u.newUploader(wgctx).WithProgress(coverProgress).FromPath(wgctx, track.Cover.Path)
+				trackInputFile, err := u.uploadFile(wgctx, logger, track.Path, trackProgress)
 				if nil != err {
-					return fmt.Errorf("upload artist credits track cover file: %w", err)
+					return newUploadError([]string{trackID}, fmt.Errorf("upload artist credits track file: %w", err))
 				}
 
-				trackInfo, err := track.InfoFile.Read()
+				coverInputFile, err := u.uploadFile(wgctx, logger, track.Cover.ThumbnailPath(), coverProgress)
 				if nil != err {
-					logger.Error().Err(err).Msg("Failed to read artist credits track info file")
-					return fmt.Errorf("read artist credits track info file: %v", err)
+					return newUploadError([]string{trackID}, fmt.Errorf("upload artist credits track cover file: %w", err))
 				}
 
 				mime, err := mimetype.DetectFile(track.Path)
@@ -705,37 +1308,19 @@ func (u *Uploader) uploadArtistCredits(
 					return fmt.Errorf("detect artist credits track mime: %v", err)
 				}
 
-				const notCollapsed = false
-				caption := []message.StyledTextOption{
-					styling.Blockquote(trackInfo.Caption, notCollapsed),
-					styling.Plain("\n"),
-					styling.Italic(fmt.Sprintf("Disc %d / Track %d", trackInfo.VolumeNumber, trackInfo.TrackNumber)),
-				}
-				if sig := u.conf.Upload.Signature; len(sig) > 0 {
-					caption = append(caption, html.String(nil, sig))
+				media[idx], err = uploadedBatchMedia(
+					trackID,
+					caption,
+					trackInputFile,
+					coverInputFile,
+					mime.String(),
+					trackInfo.UploadFilename(),
+					trackInfo.Track,
+				)
+				if nil != err {
+					return fmt.Errorf("build track media: %v", err)
 				}
 
-				doc := message.
-					UploadedDocument(trackInputFile, caption...).
-					MIME(mime.String()).
-					Attributes(
-						&tg.DocumentAttributeFilename{
-							FileName: trackInfo.UploadFilename(),
-						},
-						//nolint:exhaustruct
-						&tg.DocumentAttributeAudio{
-							Title:     trackInfo.Title,
-							Performer: types.JoinArtists(trackInfo.Artists),
-							Duration:  trackInfo.Duration,
-						}).
-					Thumb(coverInputFile).
-					Audio().
-					DurationSeconds(trackInfo.Duration).
-					Performer(types.JoinArtists(trackInfo.Artists)).
-					Title(trackInfo.Title)
-
-				album[idx] = doc
-
 				return nil
 			})
 		}
@@ -744,25 +1329,14 @@ func (u *Uploader) uploadArtistCredits(
 			return fmt.Errorf("upload artist credits: %w", err)
 		}
 
-		var rest []message.MultiMediaOption
-		if len(album) > 1 {
-			rest = album[1:]
-		}
-
-		_, err = message.
-			NewSender(u.client).
-			To(u.peer).
-			Clear().
-			Background().
-			Silent().
-			Album(ctx, album[0], rest...)
-		if nil != err {
-			return fmt.Errorf("send artist credits: %w", err)
+		batch := StoredBatch{Media: media} //nolint:exhaustruct
+		if unsent, err := u.sendBatch(ctx, logger, peer, batch); nil != err {
+			return newUploadError(unsent, fmt.Errorf("send artist credits: %w", err))
 		}
 
 		select {
 		case <-typingWait:
-			time.Sleep(u.conf.Upload.PauseDuration.Duration)
+			time.Sleep(u.pause(len(trackIDs)))
 		case <-ctx.Done():
 			return fmt.Errorf("wait for typing: %w", ctx.Err())
 		}
@@ -774,6 +1348,7 @@ func (u *Uploader) uploadArtistCredits(
 func (u *Uploader) uploadPlaylist(
 	ctx context.Context,
 	logger zerolog.Logger,
+	peer uploadPeer,
 	dir fs.DownloadsDir,
 	id string,
 ) (err error) {
@@ -783,16 +1358,18 @@ func (u *Uploader) uploadPlaylist(
 		return fmt.Errorf("read playlist info file: %v", err)
 	}
 
-	var (
-		batchSize = mathutil.OptimalAlbumSize(len(info.TrackIDs))
-		batches   = slices.Collect(slices.Chunk(info.TrackIDs, batchSize))
-	)
+	if err := peer.fits.fitAll(ctx, logger, info.TrackIDs); nil != err {
+		return fmt.Errorf("fit oversized playlist tracks: %w", err)
+	}
+
+	batches := trackBatches(peer.fits.uploadable(info.TrackIDs))
 	for _, trackIDs := range batches {
 		monitor := progress.NewBatchMonitor(len(trackIDs))
 		for i, trackID := range trackIDs {
 			logger := logger.With().Int("index", i).Str("track_id", trackID).Logger()
 
 			track := playlistFs.Track(trackID)
+			track.Path = peer.fits.path(trackID, track.Path)
 
 			trackStat, err := os.Lstat(track.Path)
 			if nil != err {
@@ -808,16 +1385,21 @@ func (u *Uploader) uploadPlaylist(
 
 			trackProgress := &progress.Track{Size: trackStat.Size()}
 
-			coverStat, err := os.Lstat(track.Cover.Path)
+			if _, err := track.Cover.Thumbnail(); nil != err {
+				logger.Error().Err(err).Msg("Failed to create playlist track cover thumbnail")
+				return fmt.Errorf("create playlist track cover thumbnail: %v", err)
+			}
+
+			coverStat, err := os.Lstat(track.Cover.ThumbnailPath())
 			if nil != err {
-				logger.Error().Err(err).Msg("Failed to stat playlist track cover file")
-				return fmt.Errorf("stat playlist track cover file: %v", err)
+				logger.Error().Err(err).Msg("Failed to stat playlist track cover thumbnail file")
+				return fmt.Errorf("stat playlist track cover thumbnail file: %v", err)
 			}
 			if !coverStat.Mode().IsRegular() {
-				return fmt.Errorf("playlist track cover file %q is not a regular file", track.Cover.Path)
+				return fmt.Errorf("playlist track cover thumbnail file %q is not a regular file", track.Cover.ThumbnailPath())
 			}
 			if coverStat.Size() == 0 {
-				return errors.New("playlist track cover file is empty")
+				return errors.New("playlist track cover thumbnail file is empty")
 			}
 
 			coverProgress := &progress.Cover{Size: coverStat.Size()}
@@ -829,9 +1411,9 @@ func (u *Uploader) uploadPlaylist(
 		wg.SetLimit(u.conf.Upload.Limit)
 
 		typingWait := make(chan struct{})
-		go u.keepTyping(ctx, monitor, typingWait, logger)
+		go u.keepTyping(ctx, peer, monitor, typingWait, logger)
 
-		album := make([]message.MultiMediaOption, len(trackIDs))
+		media := make([]StoredBatchMedia, len(trackIDs))
 		for idx, trackID := range trackIDs {
 			wg.Go(func() error {
 				select {
@@ -843,23 +1425,34 @@ func (u *Uploader) uploadPlaylist(
 				logger := logger.With().Int("index", idx).Str("track_id", trackID).Logger()
 
 				track := playlistFs.Track(trackID)
+				track.Path = peer.fits.path(trackID, track.Path)
 
 				trackProgress, coverProgress := monitor.At(idx)
 
-				trackInputFile, err := u.newUploader(wgctx).WithProgress(trackProgress).FromPath(wgctx, track.Path)
+				trackInfo, err := track.InfoFile.Read()
 				if nil != err {
-					return fmt.Errorf("upload playlist track file: %w", err)
+					logger.Error().Err(err).Msg("Failed to read playlist track info file")
+					return fmt.Errorf("read track info file: %v", err)
+				}
+
+				caption := newCaptionData(trackInfo.Album, trackInfo.Track)
+
+				if uploaded := u.uploadedTrack(logger, trackID, trackInfo.Metadata.TidalID); nil != uploaded {
+					trackProgress.Complete()
+					coverProgress.Complete()
+					media[idx] = reusedBatchMedia(trackID, caption, uploaded)
+
+					return nil
 				}
 
-				coverInputFile, err := u.newUploader(wgctx).WithProgress(coverProgress).FromPath(wgctx, track.Cover.Path)
+				trackInputFile, err := u.uploadFile(wgctx, logger, track.Path, trackProgress)
 				if nil != err {
-					return fmt.Errorf("upload playlist track cover file: %w", err)
+					return newUploadError([]string{trackID}, fmt.Errorf("upload playlist track file: %w", err))
 				}
 
-				trackInfo, err := track.InfoFile.Read()
+				coverInputFile, err := u.uploadFile(wgctx, logger, track.Cover.ThumbnailPath(), coverProgress)
 				if nil != err {
-					logger.Error().Err(err).Msg("Failed to read playlist track info file")
-					return fmt.Errorf("read track info file: %v", err)
+					return newUploadError([]string{trackID}, fmt.Errorf("upload playlist track cover file: %w", err))
 				}
 
 				mime, err := mimetype.DetectFile(track.Path)
@@ -868,37 +1461,19 @@ func (u *Uploader) uploadPlaylist(
 					return fmt.Errorf("detect playlist mime: %v", err)
 				}
 
-				const notCollapsed = false
-				caption := []message.StyledTextOption{
-					styling.Blockquote(trackInfo.Caption, notCollapsed),
-					styling.Plain("\n"),
-					styling.Italic(fmt.Sprintf("Disc %d / Track %d", trackInfo.VolumeNumber, trackInfo.TrackNumber)),
-				}
-				if sig := u.conf.Upload.Signature; len(sig) > 0 {
-					caption = append(caption, html.String(nil, sig))
+				media[idx], err = uploadedBatchMedia(
+					trackID,
+					caption,
+					trackInputFile,
+					coverInputFile,
+					mime.String(),
+					trackInfo.UploadFilename(),
+					trackInfo.Track,
+				)
+				if nil != err {
+					return fmt.Errorf("build track media: %v", err)
 				}
 
-				doc := message.
-					UploadedDocument(trackInputFile, caption...).
-					MIME(mime.String()).
-					Attributes(
-						&tg.DocumentAttributeFilename{
-							FileName: trackInfo.UploadFilename(),
-						},
-						//nolint:exhaustruct
-						&tg.DocumentAttributeAudio{
-							Title:     trackInfo.Title,
-							Performer: types.JoinArtists(trackInfo.Artists),
-							Duration:  trackInfo.Duration,
-						}).
-					Thumb(coverInputFile).
-					Audio().
-					DurationSeconds(trackInfo.Duration).
-					Performer(types.JoinArtists(trackInfo.Artists)).
-					Title(trackInfo.Title)
-
-				album[idx] = doc
-
 				return nil
 			})
 		}
@@ -907,107 +1482,188 @@ func (u *Uploader) uploadPlaylist(
 			return fmt.Errorf("upload playlist: %w", err)
 		}
 
-		var rest []message.MultiMediaOption
-		if len(album) > 1 {
-			rest = album[1:]
-		}
-
-		_, err = message.
-			NewSender(u.client).
-			To(u.peer).
-			Clear().
-			Background().
-			Silent().
-			Album(ctx, album[0], rest...)
-		if nil != err {
-			return fmt.Errorf("send playlist: %w", err)
+		batch := StoredBatch{Media: media} //nolint:exhaustruct
+		if unsent, err := u.sendBatch(ctx, logger, peer, batch); nil != err {
+			return newUploadError(unsent, fmt.Errorf("send playlist: %w", err))
 		}
 
 		select {
 		case <-typingWait:
-			time.Sleep(u.conf.Upload.PauseDuration.Duration)
+			time.Sleep(u.pause(len(trackIDs)))
 		case <-ctx.Done():
 			return fmt.Errorf("wait for typing: %w", ctx.Err())
 		}
 	}
 
+	trackInfoFile := func(id string) fs.InfoFile[types.StoredTrack] { return playlistFs.Track(id).InfoFile }
+	if err := u.uploadPlaylistFile(ctx, logger, peer, info.Caption, info.TrackIDs, trackInfoFile); nil != err {
+		return fmt.Errorf("upload playlist file: %w", err)
+	}
+
 	return nil
 }
 
-func (u *Uploader) uploadTrack(ctx context.Context, logger zerolog.Logger, dir fs.DownloadsDir, id string) error {
+func (u *Uploader) uploadTrack(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	dir fs.DownloadsDir,
+	id string,
+	stream *pipeline.Track,
+) error {
+	if streamed, err := u.uploadStreamedTrack(ctx, logger, peer, dir, id, stream); nil != err {
+		return err
+	} else if streamed {
+		return nil
+	}
+	if err := stream.Wait(ctx); nil != err {
+		return fmt.Errorf("wait for track download: %w", err)
+	}
+
+	if err := peer.fits.fitAll(ctx, logger, []string{id}); nil != err {
+		return fmt.Errorf("fit oversized track: %w", err)
+	}
+	if len(peer.fits.uploadable([]string{id})) == 0 {
+		return nil
+	}
+
 	track := dir.Track(id)
+	track.Path = peer.fits.path(id, track.Path)
 	trackInfo, err := track.InfoFile.Read()
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to read track info file")
 		return fmt.Errorf("read track info file: %v", err)
 	}
 
+	peer = peer.forPost()
+	caption, err := u.caption(peer, newCaptionData(trackInfo.Album, trackInfo.Track))
+	if nil != err {
+		return fmt.Errorf("render caption: %v", err)
+	}
+
+	var (
+		media  message.MediaOption
+		reused []string
+	)
+	if uploaded := u.uploadedTrack(logger, id, trackInfo.Metadata.TidalID); nil != uploaded {
+		media = message.Document(uploaded, caption...)
+		reused = []string{id}
+	} else if media, err = u.uploadTrackDocument(ctx, logger, peer, id, track, trackInfo, caption); nil != err {
+		return err
+	}
+
+	return u.sendTrack(ctx, logger, peer, id, media, reused)
+}
+
+// sendTrack sends media of the track with id to peer. reused is the IDs of the tracks whose previously uploaded
+// documents media reuses.
+func (u *Uploader) sendTrack(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	id string,
+	media message.MediaOption,
+	reused []string,
+) error {
+	sender := newSender(u.client, peer)
+	updates, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return sender.Media(ctx, media)
+	})
+	if nil != err {
+		u.forgetStaleUploads(logger, reused, err)
+		u.forgetStaleFiles(logger, err)
+		return newUploadError([]string{id}, fmt.Errorf("send message: %w", err))
+	}
+	u.recordUploads(logger, []string{id}, updates)
+	peer.posts.record(peer, updates)
+
+	time.Sleep(u.pause(1))
+
+	return nil
+}
+
+func (u *Uploader) uploadTrackDocument(
+	ctx context.Context,
+	logger zerolog.Logger,
+	peer uploadPeer,
+	id string,
+	track fs.Track,
+	trackInfo *types.StoredTrack,
+	caption []message.StyledTextOption,
+) (*message.AudioDocumentBuilder, error) {
 	trackStat, err := os.Lstat(track.Path)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to stat track file")
-		return fmt.Errorf("stat track file: %v", err)
+		return nil, fmt.Errorf("stat track file: %v", err)
 	}
 	if !trackStat.Mode().IsRegular() {
-		return fmt.Errorf("track file %q is not a regular file", track.Path)
+		return nil, fmt.Errorf("track file %q is not a regular file", track.Path)
 	}
 	if trackStat.Size() == 0 {
-		return errors.New("track file is empty")
+		return nil, errors.New("track file is empty")
 	}
 	trackProgress := &progress.Track{Size: trackStat.Size()}
 
-	coverStat, err := os.Lstat(track.Cover.Path)
+	if _, err := track.Cover.Thumbnail(); nil != err {
+		logger.Error().Err(err).Msg("Failed to create track cover thumbnail")
+		return nil, fmt.Errorf("create track cover thumbnail: %v", err)
+	}
+
+	coverStat, err := os.Lstat(track.Cover.ThumbnailPath())
 	if nil != err {
-		logger.Error().Err(err).Msg("Failed to stat track cover file")
-		return fmt.Errorf("stat track cover file: %v", err)
+		logger.Error().Err(err).Msg("Failed to stat track cover thumbnail file")
+		return nil, fmt.Errorf("stat track cover thumbnail file: %v", err)
 	}
 	if !coverStat.Mode().IsRegular() {
-		return fmt.Errorf("track cover file %q is not a regular file", track.Cover.Path)
+		return nil, fmt.Errorf("track cover thumbnail file %q is not a regular file", track.Cover.ThumbnailPath())
 	}
 	if coverStat.Size() == 0 {
-		return errors.New("track cover file is empty")
+		return nil, errors.New("track cover thumbnail file is empty")
 	}
 	coverProgress := &progress.Cover{Size: coverStat.Size()}
 
 	monitor := progress.NewTrackMonitor(coverProgress, trackProgress)
 
 	typingWait := make(chan struct{})
-	go u.keepTyping(ctx, monitor, typingWait, logger)
+	go u.keepTyping(ctx, peer, monitor, typingWait, logger)
 
-	trackInputFile, err := u.newUploader(ctx).WithProgress(trackProgress).FromPath(ctx, track.Path)
+	trackInputFile, err := u.uploadFile(ctx, logger, track.Path, trackProgress)
 	if nil != err {
-		return fmt.Errorf("upload track file: %w", err)
+		return nil, newUploadError([]string{id}, fmt.Errorf("upload track file: %w", err))
 	}
 
-	coverInputFile, err := u.newUploader(ctx).WithProgress(coverProgress).FromPath(ctx, track.Cover.Path)
+	coverInputFile, err := u.uploadFile(ctx, logger, track.Cover.ThumbnailPath(), coverProgress)
 	if nil != err {
-		return fmt.Errorf("upload track cover file: %w", err)
+		return nil, newUploadError([]string{id}, fmt.Errorf("upload track cover file: %w", err))
 	}
 
 	select {
 	case <-typingWait:
 	case <-ctx.Done():
-		return fmt.Errorf("wait for typing: %w", ctx.Err())
+		return nil, fmt.Errorf("wait for typing: %w", ctx.Err())
 	}
 
 	mime, err := mimetype.DetectFile(track.Path)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to detect track mime")
-		return fmt.Errorf("detect mime: %v", err)
+		return nil, fmt.Errorf("detect mime: %v", err)
 	}
 
-	const notCollapsed = false
-	caption := []message.StyledTextOption{
-		styling.Blockquote(trackInfo.Caption, notCollapsed),
-		styling.Plain("\n"),
-		styling.Italic(fmt.Sprintf("Disc %d / Track %d", trackInfo.VolumeNumber, trackInfo.TrackNumber)),
-	}
-	if sig := u.conf.Upload.Signature; len(sig) > 0 {
-		caption = append(caption, html.String(nil, sig))
-	}
+	return trackAudioDocument(trackInputFile, coverInputFile, mime.String(), trackInfo, caption), nil
+}
 
-	doc := message.
-		UploadedDocument(trackInputFile, caption...).
-		MIME(mime.String()).
+// trackAudioDocument returns the audio document of the uploaded file of the track described by trackInfo, with
+// mime type, and thumb as its thumbnail.
+func trackAudioDocument(
+	file tg.InputFileClass,
+	thumb tg.InputFileClass,
+	mime string,
+	trackInfo *types.StoredTrack,
+	caption []message.StyledTextOption,
+) *message.AudioDocumentBuilder {
+	return message.
+		UploadedDocument(file, caption...).
+		MIME(mime).
 		Attributes(
 			&tg.DocumentAttributeFilename{
 				FileName: trackInfo.UploadFilename(),
@@ -1018,32 +1674,108 @@ func (u *Uploader) uploadTrack(ctx context.Context, logger zerolog.Logger, dir f
 				Performer: types.JoinArtists(trackInfo.Artists),
 				Duration:  trackInfo.Duration,
 			}).
-		Thumb(coverInputFile).
+		Thumb(thumb).
 		Audio().
 		DurationSeconds(trackInfo.Duration).
 		Performer(types.JoinArtists(trackInfo.Artists)).
 		Title(trackInfo.Title)
+}
 
-	_, err = message.
-		NewSender(u.client).
-		To(u.peer).
-		Clear().
-		Background().
-		Silent().
-		Media(ctx, doc)
+// uploadedTrack returns the document the track with trackID was previously uploaded as, or nil if it was not.
+// If the track was downloaded by tidalID, which it was merged into, it is recorded as an alias of it first, so
+// that both IDs share the same upload.
+func (u *Uploader) uploadedTrack(logger zerolog.Logger, trackID, tidalID string) *tg.InputDocument {
+	if tidalID != "" && tidalID != trackID {
+		if err := u.storage.StoreTrackAlias(trackID, tidalID); nil != err {
+			logger.Error().Err(err).Str("canonical_id", tidalID).Msg("Failed to record track alias in ledger")
+		}
+	}
+
+	upload, err := u.storage.LoadUpload(trackID, types.TrackQuality)
 	if nil != err {
-		return fmt.Errorf("send message: %w", err)
+		logger.Error().Err(err).Msg("Failed to load track upload from ledger. Uploading it again")
+		return nil
 	}
 
-	time.Sleep(u.conf.Upload.PauseDuration.Duration)
+	if nil == upload {
+		return nil
+	}
+	logger.Debug().Time("uploaded_at", upload.UploadedAt).Msg("Reusing previously uploaded track document")
 
-	return nil
+	return &tg.InputDocument{
+		ID:            upload.DocumentID,
+		AccessHash:    upload.AccessHash,
+		FileReference: upload.FileReference,
+	}
 }
 
-func (u *Uploader) cancelTyping(ctx context.Context) {
-	req := &tg.MessagesSetTypingRequest{ //nolint:exhaustruct
-		Peer:   u.peer,
-		Action: &tg.SendMessageCancelAction{},
+// recordUploads stores the documents of the sent messages in the upload ledger, so that the tracks can be
+// reused in later jobs. Sent messages are matched with trackIDs in the order they were sent.
+func (u *Uploader) recordUploads(logger zerolog.Logger, trackIDs []string, updates tg.UpdatesClass) {
+	docs := sentDocuments(updates)
+	if len(docs) != len(trackIDs) {
+		logger.
+			Warn().
+			Int("documents", len(docs)).
+			Int("tracks", len(trackIDs)).
+			Msg("Sent documents count does not match tracks count. Skipping recording uploads")
+
+		return
+	}
+
+	now := time.Now().UTC()
+	for i, doc := range docs {
+		upload := StoredUpload{
+			DocumentID:    doc.ID,
+			AccessHash:    doc.AccessHash,
+			FileReference: doc.FileReference,
+			UploadedAt:    now,
+		}
+		if err := u.storage.StoreUpload(trackIDs[i], types.TrackQuality, upload); nil != err {
+			logger.Error().Err(err).Str("track_id", trackIDs[i]).Msg("Failed to record track upload")
+		}
+	}
+}
+
+// withFloodCategory categorizes err as a Telegram flood error if Telegram rejected a request for being sent
+// too often, e.g., with FLOOD_WAIT, which the waiter middleware gave up on.
+func withFloodCategory(err error) error {
+	if rpcErr, ok := tgerr.As(err); ok && strings.HasPrefix(rpcErr.Type, "FLOOD_") {
+		return errs.Wrap(errs.CategoryTelegramFlood, err)
+	}
+
+	return err
+}
+
+// forgetStaleUploads removes the reused tracks from the upload ledger if sending them failed because their
+// stored documents are no longer valid, so that they are uploaded again next time.
+func (u *Uploader) forgetStaleUploads(logger zerolog.Logger, reused []string, err error) {
+	if len(reused) == 0 {
+		return
+	}
+
+	rpcErr, ok := tgerr.As(err)
+	if !ok || (!strings.HasPrefix(rpcErr.Type, "FILE_REFERENCE_") && rpcErr.Type != "MEDIA_EMPTY") {
+		return
+	}
+
+	for _, trackID := range reused {
+		if err := u.storage.DeleteUpload(trackID, types.TrackQuality); nil != err {
+			logger.Error().Err(err).Str("track_id", trackID).Msg("Failed to forget stale track upload")
+		}
+	}
+	logger.Warn().Strs("track_ids", reused).Msg("Forgot stale track uploads. They will be uploaded again next time")
+}
+
+func (u *Uploader) cancelTyping(ctx context.Context, peer uploadPeer) {
+	if u.conf.Upload.Typing == config.TypingOff {
+		return
+	}
+
+	req := &tg.MessagesSetTypingRequest{
+		Peer:     peer,
+		TopMsgID: peer.conf.Topic,
+		Action:   &tg.SendMessageCancelAction{},
 	}
 	if ok, err := u.client.MessagesSetTyping(ctx, req); nil != err {
 		u.logger.Error().Err(err).Msg("Failed to cancel typing action")
@@ -1052,16 +1784,32 @@ func (u *Uploader) cancelTyping(ctx context.Context) {
 	}
 }
 
-func (u *Uploader) sendTyping(ctx context.Context, logger zerolog.Logger, mon progress.Monitor) error {
+func (u *Uploader) sendTyping(ctx context.Context, logger zerolog.Logger, peer uploadPeer, mon progress.Monitor) error {
 	percent := mon.Percent()
 	logger.Debug().Int("percent", percent).Msg("Sending typing action")
 
+	u.bus.Publish(events.Event{ //nolint:exhaustruct
+		Kind:    events.KindUploadProgress,
+		Peer:    peer.conf.Kind + "/" + strconv.FormatInt(peer.conf.ID, 10),
+		Percent: percent,
+	})
+
 	if percent == 100 {
 		return os.ErrProcessDone
 	}
 
+	if u.conf.Upload.Typing == config.TypingOff {
+		return nil
+	}
+
+	// Simple indicator is shown as a document upload that has not made any progress yet.
+	if u.conf.Upload.Typing == config.TypingSimple {
+		percent = 0
+	}
+
 	req := &tg.MessagesSetTypingRequest{ //nolint:exhaustruct
-		Peer: u.peer,
+		Peer:     peer,
+		TopMsgID: peer.conf.Topic,
 		Action: &tg.SendMessageUploadDocumentAction{
 			Progress: percent,
 		},
@@ -1077,17 +1825,18 @@ func (u *Uploader) sendTyping(ctx context.Context, logger zerolog.Logger, mon pr
 
 func (u *Uploader) keepTyping(
 	ctx context.Context,
+	peer uploadPeer,
 	mon progress.Monitor,
 	wait chan<- struct{},
 	logger zerolog.Logger,
 ) {
 	defer close(wait)
 
-	ticker := time.NewTicker(1221 * time.Millisecond)
+	ticker := time.NewTicker(u.typingInterval())
 	defer ticker.Stop()
-	defer u.cancelTyping(ctx)
+	defer u.cancelTyping(ctx, peer)
 
-	if err := u.sendTyping(ctx, logger, mon); nil != err {
+	if err := u.sendTyping(ctx, logger, peer, mon); nil != err {
 		if !errors.Is(err, os.ErrProcessDone) {
 			logger.Error().Err(err).Msg("Failed to send typing action")
 			return
@@ -1101,7 +1850,7 @@ func (u *Uploader) keepTyping(
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := u.sendTyping(ctx, logger, mon); nil != err {
+			if err := u.sendTyping(ctx, logger, peer, mon); nil != err {
 				if !errors.Is(err, os.ErrProcessDone) {
 					logger.Error().Err(err).Msg("Failed to send typing action")
 					return
@@ -1112,3 +1861,77 @@ func (u *Uploader) keepTyping(
 		}
 	}
 }
+
+// typingInterval returns how often the typing indicator is refreshed. Telegram clients hide it after about
+// 6 seconds, so the simple indicator is only refreshed right before that. Upload progress events are also
+// published at this interval, even if the indicator is off.
+func (u *Uploader) typingInterval() time.Duration {
+	if u.conf.Upload.Typing == config.TypingSimple {
+		return 4500 * time.Millisecond
+	}
+
+	return 1221 * time.Millisecond
+}
+
+// sentMessages returns the messages in updates, ordered by message ID. Scheduled messages are only returned if
+// scheduled is set, as their IDs differ from the IDs they are posted with.
+func sentMessages(updates tg.UpdatesClass, scheduled bool) []*tg.Message {
+	var list []tg.UpdateClass
+	switch updates := updates.(type) {
+	case *tg.Updates:
+		list = updates.Updates
+	case *tg.UpdatesCombined:
+		list = updates.Updates
+	default:
+		return nil
+	}
+
+	msgs := make([]*tg.Message, 0, len(list))
+	for _, update := range list {
+		var msg tg.MessageClass
+		switch update := update.(type) {
+		case *tg.UpdateNewMessage:
+			msg = update.Message
+		case *tg.UpdateNewChannelMessage:
+			msg = update.Message
+		case *tg.UpdateNewScheduledMessage:
+			if !scheduled {
+				continue
+			}
+			msg = update.Message
+		default:
+			continue
+		}
+
+		if m, ok := msg.(*tg.Message); ok {
+			msgs = append(msgs, m)
+		}
+	}
+	slices.SortFunc(msgs, func(a, b *tg.Message) int { return a.ID - b.ID })
+
+	return msgs
+}
+
+// sentDocuments returns the documents of the messages in updates, scheduled ones included, ordered by message ID.
+func sentDocuments(updates tg.UpdatesClass) []*tg.Document {
+	msgs := sentMessages(updates, true)
+
+	docs := make([]*tg.Document, 0, len(msgs))
+	for _, msg := range msgs {
+		media, ok := msg.Media.(*tg.MessageMediaDocument)
+		if !ok {
+			continue
+		}
+
+		doc, ok := media.GetDocument()
+		if !ok {
+			continue
+		}
+
+		if doc, ok := doc.(*tg.Document); ok {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs
+}
@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/telegram/progress"
+	"github.com/xeptore/tidalgram/tidal/fs"
+)
+
+const videoCoverMIME = "video/mp4"
+
+// sendVideoCover posts the video cover of the album as a muted looping video, i.e., an animation, if it is
+// downloaded. Telegram does not allow videos in media groups of audio documents, hence it is posted right
+// before the album.
+func (u *Uploader) sendVideoCover(ctx context.Context, logger zerolog.Logger, peer uploadPeer, albumFs fs.Album) error {
+	if ok, err := albumFs.VideoCover.AlreadyDownloaded(); nil != err {
+		return fmt.Errorf("check if album video cover exists: %v", err)
+	} else if !ok {
+		return nil
+	}
+
+	info, err := albumFs.VideoCover.InfoFile.Read()
+	if nil != err {
+		return fmt.Errorf("read album video cover info file: %v", err)
+	}
+
+	path := albumFs.VideoCover.Path
+	stat, err := os.Lstat(path)
+	if nil != err {
+		return fmt.Errorf("stat album video cover file: %v", err)
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("album video cover file %q is not a regular file", path)
+	}
+	if stat.Size() == 0 {
+		return errors.New("album video cover file is empty")
+	}
+
+	file, err := u.uploadFile(ctx, logger, path, &progress.Cover{Size: stat.Size()})
+	if nil != err {
+		return newUploadError(nil, fmt.Errorf("upload album video cover file: %w", err))
+	}
+
+	video := message.
+		UploadedDocument(file).
+		MIME(videoCoverMIME).
+		Attributes(
+			&tg.DocumentAttributeVideo{ //nolint:exhaustruct
+				SupportsStreaming: true,
+				Nosound:           true,
+				Duration:          info.Duration,
+				W:                 info.Width,
+				H:                 info.Height,
+			},
+			&tg.DocumentAttributeAnimated{},
+			&tg.DocumentAttributeFilename{FileName: "cover.mp4"},
+		)
+
+	sender := newSender(u.client, peer)
+	if _, err := u.sendFloodWaiting(ctx, logger, peer, func() (tg.UpdatesClass, error) {
+		return sender.Media(ctx, video)
+	}); nil != err {
+		u.forgetStaleFiles(logger, err)
+		return newUploadError(nil, fmt.Errorf("send album video cover: %w", err))
+	}
+
+	time.Sleep(u.pause(1))
+
+	return nil
+}
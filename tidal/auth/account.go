@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/httputil"
+)
+
+const subscriptionURLFormat = "https://api.tidal.com/v1/users/%d/subscription"
+
+// Account is the Tidal account the credentials were authorized for.
+type Account struct {
+	UserID      int64
+	Name        string
+	CountryCode string
+	// Subscription is the subscription tier of the account, e.g., HIFI, or FREE.
+	Subscription string
+	// HighestSoundQuality is the highest quality the subscription can stream, e.g., HI_RES_LOSSLESS.
+	HighestSoundQuality string
+}
+
+// Account fetches the account the current credentials were authorized for.
+func (a *Auth) Account(ctx context.Context, logger zerolog.Logger) (*Account, error) {
+	creds := a.credentials.Load()
+
+	me, err := getMe(ctx, logger, a.client, creds.Token)
+	if nil != err {
+		return nil, fmt.Errorf("get me: %w", err)
+	}
+
+	sub, err := getSubscription(ctx, logger, a.client, creds.Token, me.UserID, me.CountryCode)
+	if nil != err {
+		return nil, fmt.Errorf("get subscription: %w", err)
+	}
+
+	return &Account{
+		UserID:              me.UserID,
+		Name:                me.Name,
+		CountryCode:         me.CountryCode,
+		Subscription:        sub.Subscription.Type,
+		HighestSoundQuality: sub.HighestSoundQuality,
+	}, nil
+}
+
+type subscriptionResponse struct {
+	Subscription struct {
+		Type string `json:"type"`
+	} `json:"subscription"`
+	HighestSoundQuality string `json:"highestSoundQuality"`
+}
+
+func getSubscription(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client *httputil.Client,
+	token string,
+	userID int64,
+	countryCode string,
+) (out *subscriptionResponse, err error) {
+	reqURL, err := url.Parse(fmt.Sprintf(subscriptionURLFormat, userID))
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to parse subscription URL")
+		return nil, fmt.Errorf("parse subscription URL: %v", err)
+	}
+	params := make(url.Values, 1)
+	params.Add("countryCode", countryCode)
+	reqURL.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to create subscription request")
+		return nil, fmt.Errorf("create subscription request %s: %w", reqURL, err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := client.Do(req, 5*time.Second)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to send subscription request")
+		return nil, fmt.Errorf("send subscription request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); nil != closeErr {
+			logger.Error().Err(closeErr).Msg("Failed to close subscription response body")
+			err = errors.Join(err, fmt.Errorf("close subscription response body: %v", closeErr))
+		}
+	}()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if nil != err {
+		logger.Error().Err(err).Int("status_code", resp.StatusCode).Msg("Failed to read response body")
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		logger.
+			Error().
+			Int("status_code", resp.StatusCode).
+			Bytes("response_body", respBytes).
+			Msg("Unexpected response status code")
+
+		return nil, fmt.Errorf("unexpected status code %d with body: %s", resp.StatusCode, string(respBytes))
+	}
+
+	var respBody subscriptionResponse
+	if err := json.Unmarshal(respBytes, &respBody); nil != err {
+		logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to decode 200 response body")
+		return nil, fmt.Errorf("decode 200 response body: %w", err)
+	}
+
+	return &respBody, nil
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
 
+	"github.com/xeptore/tidalgram/errs"
+	"github.com/xeptore/tidalgram/httputil"
+	"github.com/xeptore/tidalgram/secret"
 	"github.com/xeptore/tidalgram/tidal/fs"
 )
 
@@ -24,7 +27,7 @@ const (
 )
 
 var (
-	ErrUnauthorized     = errors.New("unauthorized")
+	ErrUnauthorized     = errs.New(errs.CategoryAuth, "unauthorized")
 	ErrLoginLinkExpired = errors.New("login link has expired")
 	ErrLoginInProgress  = errors.New("another login flow is in progress")
 )
@@ -32,6 +35,10 @@ var (
 type Auth struct {
 	authFile    fs.AuthFile
 	credentials atomic.Pointer[Credentials]
+	// skew is how far the local clock is behind the auth server clock, in nanoseconds.
+	skew atomic.Int64
+	// client is shared by all auth requests.
+	client *httputil.Client
 }
 
 type Credentials struct {
@@ -41,8 +48,8 @@ type Credentials struct {
 	ExpiresAt    time.Time
 }
 
-func New(logger zerolog.Logger, dir string) (*Auth, error) {
-	authFile := fs.AuthFileFrom(dir, tokenFileName)
+func New(logger zerolog.Logger, dir string, client *httputil.Client, box *secret.Box) (*Auth, error) {
+	authFile := fs.AuthFileFrom(dir, tokenFileName, box)
 	content, err := authFile.Read()
 	if nil != err {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -70,6 +77,8 @@ func New(logger zerolog.Logger, dir string) (*Auth, error) {
 	a := &Auth{
 		credentials: atomic.Pointer[Credentials]{},
 		authFile:    authFile,
+		skew:        atomic.Int64{},
+		client:      client,
 	}
 	a.credentials.Store(creds)
 
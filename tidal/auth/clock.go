@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ClockSkewThreshold is the clock skew above which token expiry calculations are considered unreliable.
+const ClockSkewThreshold = 30 * time.Second
+
+// MeasureClockSkew measures how far the local clock is behind the Tidal auth server clock, using the Date
+// header of a HEAD request. It is negative if the local clock is ahead. The Date header has a resolution of
+// one second, so the measured skew is only accurate to about a second.
+func (a *Auth) MeasureClockSkew(ctx context.Context, logger zerolog.Logger) (skew time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+	if nil != err {
+		return 0, fmt.Errorf("create clock skew request: %v", err)
+	}
+
+	sentAt := time.Now()
+	resp, err := a.client.Do(req, 5*time.Second)
+	if nil != err {
+		return 0, fmt.Errorf("issue clock skew request: %w", err)
+	}
+	receivedAt := time.Now()
+	defer func() {
+		if closeErr := resp.Body.Close(); nil != closeErr {
+			logger.Error().Err(closeErr).Msg("Failed to close response body")
+			err = errors.Join(err, fmt.Errorf("close response body: %v", closeErr))
+		}
+	}()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if nil != err {
+		return 0, fmt.Errorf("parse response date header: %v", err)
+	}
+
+	// The server stamps the response somewhere between sending the request and receiving the response.
+	localAt := sentAt.Add(receivedAt.Sub(sentAt) / 2)
+
+	return date.Sub(localAt).Truncate(time.Second), nil
+}
+
+// SetClockSkew sets the clock skew factored into token expiry decisions, as measured by [Auth.MeasureClockSkew].
+func (a *Auth) SetClockSkew(skew time.Duration) {
+	a.skew.Store(int64(skew))
+}
+
+// Now returns the current time of the Tidal auth server clock, i.e., the local time corrected by the clock skew.
+func (a *Auth) Now() time.Time {
+	return time.Now().Add(time.Duration(a.skew.Load()))
+}
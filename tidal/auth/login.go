@@ -9,11 +9,13 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
 
+	"github.com/xeptore/tidalgram/httputil"
 	"github.com/xeptore/tidalgram/must"
 	"github.com/xeptore/tidalgram/tidal/fs"
 )
@@ -24,7 +26,7 @@ type LoginLink struct {
 }
 
 func (a *Auth) InitiateLoginFlow(ctx context.Context, logger zerolog.Logger) (*LoginLink, <-chan error, error) {
-	res, err := issueAuthorizationRequest(ctx, logger)
+	res, err := issueAuthorizationRequest(ctx, logger, a.client)
 	if nil != err {
 		return nil, nil, fmt.Errorf("issue authorization request: %w", err)
 	}
@@ -56,7 +58,7 @@ func (a *Auth) InitiateLoginFlow(ctx context.Context, logger zerolog.Logger) (*L
 
 				panic("unexpected context error in initiate login flow")
 			case <-ticker.C:
-				creds, err := res.poll(ctx, logger)
+				creds, err := res.poll(ctx, logger, a.client)
 				if nil != err {
 					if errors.Is(err, ErrUnauthorized) {
 						continue waitloop
@@ -115,7 +117,11 @@ type authorizationResponse struct {
 	Interval   int
 }
 
-func issueAuthorizationRequest(ctx context.Context, logger zerolog.Logger) (out *authorizationResponse, err error) {
+func issueAuthorizationRequest(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client *httputil.Client,
+) (out *authorizationResponse, err error) {
 	reqURL, err := url.JoinPath(baseURL, "/device_authorization")
 	must.Be(nil == err, "device authorization URL must be a valid URL")
 
@@ -133,8 +139,7 @@ func issueAuthorizationRequest(ctx context.Context, logger zerolog.Logger) (out
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Accept", "application/json")
 
-	client := http.Client{Timeout: 5 * time.Second} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := client.Do(req, 5*time.Second)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to issue device authorization request")
 		return nil, fmt.Errorf("issue device authorization request: %w", err)
@@ -195,7 +200,11 @@ func issueAuthorizationRequest(ctx context.Context, logger zerolog.Logger) (out
 	}, nil
 }
 
-func (r *authorizationResponse) poll(ctx context.Context, logger zerolog.Logger) (*Credentials, error) {
+func (r *authorizationResponse) poll(
+	ctx context.Context,
+	logger zerolog.Logger,
+	client *httputil.Client,
+) (*Credentials, error) {
 	reqURL, err := url.JoinPath(baseURL, "/token")
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to join token URL")
@@ -222,8 +231,7 @@ func (r *authorizationResponse) poll(ctx context.Context, logger zerolog.Logger)
 		"Basic "+base64.StdEncoding.Strict().EncodeToString([]byte(clientID+":"+clientSecret)),
 	)
 
-	client := http.Client{Timeout: 10 * time.Second} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := client.Do(req, 10*time.Second)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to issue token request")
 		return nil, fmt.Errorf("issue token request: %w", err)
@@ -304,7 +312,7 @@ func (r *authorizationResponse) poll(ctx context.Context, logger zerolog.Logger)
 		return nil, fmt.Errorf("extract expires at from access token: %w", err)
 	}
 
-	me, err := getMe(ctx, logger, respBody.AccessToken)
+	me, err := getMe(ctx, logger, client, respBody.AccessToken)
 	if nil != err {
 		return nil, fmt.Errorf("get me: %w", err)
 	}
@@ -318,10 +326,12 @@ func (r *authorizationResponse) poll(ctx context.Context, logger zerolog.Logger)
 }
 
 type Me struct {
+	UserID      int64
+	Name        string
 	CountryCode string
 }
 
-func getMe(ctx context.Context, logger zerolog.Logger, token string) (*Me, error) {
+func getMe(ctx context.Context, logger zerolog.Logger, client *httputil.Client, token string) (*Me, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meURL, nil)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to create me request")
@@ -331,8 +341,7 @@ func getMe(ctx context.Context, logger zerolog.Logger, token string) (*Me, error
 	req.Header.Add("Authorization", "Bearer "+token)
 	req.Header.Add("Accept", "application/json")
 
-	client := http.Client{Timeout: 5 * time.Second} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := client.Do(req, 5*time.Second)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send me request")
 		return nil, fmt.Errorf("send me request: %w", err)
@@ -367,7 +376,14 @@ func getMe(ctx context.Context, logger zerolog.Logger, token string) (*Me, error
 	}
 
 	var respBody struct {
+		UserID      int64  `json:"userId"`
 		CountryCode string `json:"countryCode"`
+		FullName    string `json:"fullName"`
+		FirstName   string `json:"firstName"`
+		LastName    string `json:"lastName"`
+		Nickname    string `json:"nickname"`
+		Username    string `json:"username"`
+		Email       string `json:"email"`
 	}
 	if err := json.Unmarshal(respBytes, &respBody); nil != err {
 		logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to decode 200 response body")
@@ -378,5 +394,13 @@ func getMe(ctx context.Context, logger zerolog.Logger, token string) (*Me, error
 		return nil, errors.New("country code is empty")
 	}
 
-	return &Me{CountryCode: respBody.CountryCode}, nil
+	name := respBody.FullName
+	for _, n := range []string{strings.TrimSpace(respBody.FirstName + " " + respBody.LastName), respBody.Nickname, respBody.Username, respBody.Email} {
+		if len(name) > 0 {
+			break
+		}
+		name = n
+	}
+
+	return &Me{UserID: respBody.UserID, Name: name, CountryCode: respBody.CountryCode}, nil
 }
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/httputil"
+	"github.com/xeptore/tidalgram/secret"
+)
+
+// PrimaryAccount is the name of the account whose credentials are stored in the credentials directory itself.
+const PrimaryAccount = "primary"
+
+var ErrUnknownAccount = errors.New("unknown account")
+
+// Pool is a set of Tidal accounts, one of which is in use at a time. The account in use is put on cooldown
+// when it hits a rate limit, and the next available account is used instead.
+type Pool struct {
+	mu       sync.Mutex
+	accounts []*PoolAccount
+	active   int
+	cooldown time.Duration
+}
+
+// PoolAccount is a named account of a pool.
+type PoolAccount struct {
+	*Auth
+	Name string
+	// cooldownUntil is guarded by the mutex of the pool.
+	cooldownUntil time.Time
+}
+
+// AccountStatus is the state of an account of a pool.
+type AccountStatus struct {
+	Name     string
+	Active   bool
+	LoggedIn bool
+	// CooldownUntil is when the account can be used again after hitting a rate limit. It is in the past if
+	// the account is not on cooldown.
+	CooldownUntil time.Time
+}
+
+// NewPool creates a pool of the primary account, whose credentials are stored in dir, and the accounts with
+// names, whose credentials are stored in the subdirectories of dir with the same names.
+func NewPool(
+	logger zerolog.Logger,
+	dir string,
+	names []string,
+	cooldown time.Duration,
+	client *httputil.Client,
+	box *secret.Box,
+) (*Pool, error) {
+	primary, err := New(logger, dir, client, box)
+	if nil != err {
+		return nil, fmt.Errorf("create primary account auth: %w", err)
+	}
+
+	accounts := make([]*PoolAccount, 0, len(names)+1)
+	accounts = append(accounts, &PoolAccount{Auth: primary, Name: PrimaryAccount, cooldownUntil: time.Time{}})
+	for _, name := range names {
+		accountDir := filepath.Join(dir, name)
+		if err := os.MkdirAll(accountDir, 0o0700); nil != err {
+			return nil, fmt.Errorf("create account %s credentials directory: %v", name, err)
+		}
+
+		a, err := New(logger.With().Str("account", name).Logger(), accountDir, client, box)
+		if nil != err {
+			return nil, fmt.Errorf("create account %s auth: %w", name, err)
+		}
+		accounts = append(accounts, &PoolAccount{Auth: a, Name: name, cooldownUntil: time.Time{}})
+	}
+
+	return &Pool{
+		mu:       sync.Mutex{},
+		accounts: accounts,
+		active:   0,
+		cooldown: cooldown,
+	}, nil
+}
+
+// Active returns the account in use. If it is not logged in, the first account that is logged in is used
+// instead, if any.
+func (p *Pool) Active() *PoolAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accounts[p.active].Credentials().ExpiresAt.IsZero() {
+		for i, account := range p.accounts {
+			if !account.Credentials().ExpiresAt.IsZero() {
+				p.active = i
+				break
+			}
+		}
+	}
+
+	return p.accounts[p.active]
+}
+
+// Lookup returns the account with name, or ErrUnknownAccount if there is no such account.
+func (p *Pool) Lookup(name string) (*PoolAccount, error) {
+	for _, account := range p.accounts {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+
+	return nil, ErrUnknownAccount
+}
+
+// Credentials returns the credentials of the account in use.
+func (p *Pool) Credentials() *Credentials {
+	return p.Active().Credentials()
+}
+
+// SetClockSkew sets the clock skew of all accounts, as they are all authorized by the same server.
+func (p *Pool) SetClockSkew(skew time.Duration) {
+	for _, account := range p.accounts {
+		account.SetClockSkew(skew)
+	}
+}
+
+// Rotate puts the account in use on cooldown, and switches to the next logged in account that is not on
+// cooldown. It returns false, and keeps using the same account, if there is no such account.
+func (p *Pool) Rotate(logger zerolog.Logger) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.accounts) == 1 {
+		return false
+	}
+
+	now := time.Now()
+	current := p.accounts[p.active]
+	current.cooldownUntil = now.Add(p.cooldown)
+
+	for i := 1; i < len(p.accounts); i++ {
+		next := (p.active + i) % len(p.accounts)
+		account := p.accounts[next]
+		if account.cooldownUntil.After(now) || account.Credentials().ExpiresAt.IsZero() {
+			continue
+		}
+
+		p.active = next
+		logger.
+			Warn().
+			Str("from", current.Name).
+			Str("to", account.Name).
+			Time("cooldown_until", current.cooldownUntil).
+			Msg("Tidal account hit a rate limit. Switched to the next account")
+
+		return true
+	}
+
+	return false
+}
+
+// Statuses returns the states of the accounts, in the configured order.
+func (p *Pool) Statuses() []AccountStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]AccountStatus, len(p.accounts))
+	for i, account := range p.accounts {
+		out[i] = AccountStatus{
+			Name:          account.Name,
+			Active:        i == p.active,
+			LoggedIn:      !account.Credentials().ExpiresAt.IsZero(),
+			CooldownUntil: account.cooldownUntil,
+		}
+	}
+
+	return out
+}
@@ -74,8 +74,7 @@ func (a *Auth) refreshToken(ctx context.Context, logger zerolog.Logger) (creds *
 		"Basic "+base64.StdEncoding.Strict().EncodeToString([]byte(clientID+":"+clientSecret)),
 	)
 
-	client := http.Client{Timeout: 5 * time.Second} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := a.client.Do(req, 5*time.Second)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to issue refresh token request")
 		return nil, fmt.Errorf("issue refresh token request: %w", err)
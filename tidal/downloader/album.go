@@ -8,17 +8,20 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
+	"github.com/samber/lo"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/xeptore/tidalgram/cache"
 	"github.com/xeptore/tidalgram/httputil"
 	"github.com/xeptore/tidalgram/mathutil"
 	"github.com/xeptore/tidalgram/tidal/auth"
+	"github.com/xeptore/tidalgram/tidal/fs"
 	"github.com/xeptore/tidalgram/tidal/types"
 )
 
@@ -36,39 +39,55 @@ type AlbumTrackMeta struct {
 	Credits      types.TrackCredits
 }
 
-func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string) error {
+func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string, skipIDs []string) error {
 	logger.Debug().Msg("Downloading album")
 
 	creds := d.auth.Credentials()
-	album, err := d.getAlbumMeta(ctx, logger, creds.Token, creds.CountryCode, id)
+	album, err := d.getAlbumMeta(ctx, logger, creds.Token, d.countryCode(creds), id)
 	if nil != err {
-		return fmt.Errorf("get album meta: %w", err)
+		return newStageError(StageMetadata, "", fmt.Errorf("get album meta: %w", err))
 	}
 
 	albumFs := d.dir.Album(id)
 	if exists, err := albumFs.Cover.AlreadyDownloaded(); nil != err {
 		logger.Error().Err(err).Msg("Failed to check if track cover file exists")
 		return fmt.Errorf("check if track cover file exists: %v", err)
-	} else if !exists {
+	} else if !exists && !d.copyCachedCover(logger, album.CoverID, albumFs.Cover) {
 		coverBytes, err := d.getCover(ctx, logger, creds.Token, album.CoverID)
 		if nil != err {
-			return fmt.Errorf("get album cover: %w", err)
+			return newStageError(StageMetadata, "", fmt.Errorf("get album cover: %w", err))
 		}
-		if err := albumFs.Cover.Write(coverBytes); nil != err {
+		if err := d.writeCover(albumFs.Cover, album.CoverID, coverBytes); nil != err {
 			logger.Error().Err(err).Msg("Failed to write album cover")
-			return fmt.Errorf("write album cover: %v", err)
+			return newStageError(StageMetadata, "", fmt.Errorf("write album cover: %v", err))
 		}
 	}
 
-	volumes, err := d.getAlbumVolumes(ctx, logger, creds.Token, creds.CountryCode, id)
+	if d.conf.Cover.Video && len(album.VideoCoverID) > 0 {
+		// Video covers are optional artwork, hence they do not fail the album download.
+		if exists, err := albumFs.VideoCover.AlreadyDownloaded(); nil != err {
+			logger.Error().Err(err).Msg("Failed to check if album video cover exists")
+			return fmt.Errorf("check if album video cover exists: %v", err)
+		} else if !exists {
+			if err := d.downloadVideoCover(ctx, logger, creds.Token, album.VideoCoverID, albumFs.VideoCover); nil != err {
+				if nil != ctx.Err() {
+					return fmt.Errorf("download album video cover: %w", ctx.Err())
+				}
+				logger.Warn().Err(err).Str("video_cover_id", album.VideoCoverID).Msg("Failed to download album video cover. Skipping it")
+			}
+		}
+	}
+
+	volumes, err := d.getAlbumVolumes(ctx, logger, creds.Token, d.countryCode(creds), id)
 	if nil != err {
-		return fmt.Errorf("get album volumes: %w", err)
+		return newStageError(StageMetadata, "", fmt.Errorf("get album volumes: %w", err))
 	}
 
-	for _, volTracks := range volumes {
+	for i, volTracks := range volumes {
 		for _, track := range volTracks {
 			d.cache.TrackCredits.Set(track.ID, &track.Credits, cache.DefaultTrackCreditsTTL)
 		}
+		volumes[i] = withoutAlbumTracks(volTracks, skipIDs)
 	}
 
 	var (
@@ -79,17 +98,37 @@ func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string
 	wg.SetLimit(d.conf.Concurrency.AlbumTracks)
 
 	for volIdx, tracks := range volumes {
-		volNum := volIdx + 1
 		for trackIdx, track := range tracks {
 			albumVolumeTrackIDs[volIdx][trackIdx] = track.ID
+		}
+	}
 
+	// Tracks are only handed over to the upload once their files are final, i.e., after the album gain is
+	// applied if replay gain is enabled.
+	pipe := pipelineFrom(ctx)
+	pipe.SetLayout(types.StoredAlbum{Album: album.Stored(), VolumeTrackIDs: albumVolumeTrackIDs})
+	trackReady := func(id string) {
+		if !d.conf.ReplayGain {
+			pipe.TrackReady(id)
+		}
+	}
+
+	for volIdx, tracks := range volumes {
+		volNum := volIdx + 1
+		for trackIdx, track := range tracks {
 			wg.Go(func() (err error) {
 				select {
 				case <-wgctx.Done():
-					return nil
+					return wgctx.Err()
 				default:
 				}
 
+				release, err := d.warmup.Acquire(wgctx)
+				if nil != err {
+					return fmt.Errorf("wait for warmup: %w", err)
+				}
+				defer release()
+
 				logger := logger.With().Int("volume_index", volIdx).Int("track_index", trackIdx).Str("track_id", track.ID).Logger()
 
 				trackFs := albumFs.Track(volNum, track.ID)
@@ -98,6 +137,7 @@ func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string
 					logger.Error().Err(err).Msg("Failed to check if track file exists")
 					return fmt.Errorf("check if track file exists: %v", err)
 				} else if exists {
+					trackReady(track.ID)
 					return nil
 				}
 				defer func() {
@@ -111,14 +151,14 @@ func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string
 					}
 				}()
 
-				trackLyrics, err := d.downloadTrackLyrics(wgctx, logger, creds.Token, creds.CountryCode, track.ID)
+				trackLyrics, err := d.downloadTrackLyrics(wgctx, logger, creds.Token, d.countryCode(creds), track.ID)
 				if nil != err {
-					return fmt.Errorf("download track lyrics: %w", err)
+					return newStageError(StageMetadata, track.ID, fmt.Errorf("download track lyrics: %w", err))
 				}
 
-				ext, err := d.downloadTrack(wgctx, logger, creds.Token, track.ID, trackFs.Path)
+				ext, skippedAudioMode, err := d.downloadTrack(wgctx, logger, creds.Token, track.ID, trackFs.Path)
 				if nil != err {
-					return fmt.Errorf("download track: %w", err)
+					return newStageError(StageDownload, track.ID, fmt.Errorf("download track: %w", err))
 				}
 
 				attrs := TrackEmbeddedAttrs{
@@ -137,29 +177,51 @@ func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string
 					VolumeNumber: track.VolumeNumber,
 					TotalVolumes: album.TotalVolumes,
 					Credits:      track.Credits,
-					Lyrics:       trackLyrics,
+					Lyrics:       *trackLyrics,
 					Ext:          ext,
 				}
-				if err := embedTrackAttributes(wgctx, logger, trackFs.Path, attrs); nil != err {
-					return fmt.Errorf("embed track attributes: %w", err)
+				if err := d.checkMetadata(wgctx, track.ID, album.CoverID, attrs); nil != err {
+					return err
+				}
+				if err := d.embedTrackAttributes(wgctx, logger, trackFs.Path, attrs); nil != err {
+					return newStageError(StageTagging, track.ID, fmt.Errorf("embed track attributes: %w", err))
+				}
+				if err := verifyTrackTags(wgctx, logger, track.ID, trackFs.Path, attrs); nil != err {
+					return err
+				}
+
+				if err := writeLyricsFile(trackFs.Lyrics, attrs); nil != err {
+					logger.Error().Err(err).Msg("Failed to write track lyrics file")
+					return fmt.Errorf("write track lyrics file: %v", err)
+				}
+
+				size, sum, err := fs.FileChecksum(trackFs.Path)
+				if nil != err {
+					logger.Error().Err(err).Msg("Failed to get track file checksum")
+					return fmt.Errorf("get track file checksum: %v", err)
 				}
 
 				info := types.StoredAlbumTrack{
 					Track: types.Track{
-						Artists:      track.Artists,
-						Title:        track.Title,
-						TrackNumber:  track.TrackNumber,
-						VolumeNumber: track.VolumeNumber,
-						Duration:     track.Duration,
-						Version:      track.Version,
-						CoverID:      album.CoverID,
-						Ext:          ext,
+						Artists:          track.Artists,
+						Title:            track.Title,
+						TrackNumber:      track.TrackNumber,
+						VolumeNumber:     track.VolumeNumber,
+						Duration:         track.Duration,
+						Version:          track.Version,
+						CoverID:          album.CoverID,
+						Ext:              ext,
+						SkippedAudioMode: skippedAudioMode,
+						Size:             size,
+						SHA256:           sum,
 					},
+					Metadata: attrs.metadata(track.ID, id, track.Duration),
 				}
 				if err := trackFs.InfoFile.Write(info); nil != err {
 					logger.Error().Err(err).Msg("Failed to write track info file")
 					return fmt.Errorf("write track info file: %v", err)
 				}
+				trackReady(track.ID)
 
 				return nil
 			})
@@ -170,12 +232,40 @@ func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string
 		return fmt.Errorf("wait for track download workers: %w", err)
 	}
 
+	// Only the downloaded tracks are analyzed, so the album gain of tracks downloaded by an album update
+	// is computed from the added tracks alone.
+	if d.conf.ReplayGain {
+		var tracks []replayGainTrack
+		for volIdx, trackIDs := range albumVolumeTrackIDs {
+			for _, trackID := range trackIDs {
+				trackFs := albumFs.Track(volIdx+1, trackID)
+				info, err := trackFs.InfoFile.Read()
+				if nil != err {
+					logger.Error().Err(err).Str("track_id", trackID).Msg("Failed to read track info file")
+					return fmt.Errorf("read track info file: %v", err)
+				}
+				tracks = append(tracks, replayGainTrack{ID: trackID, Path: trackFs.Path, Ext: info.Ext, Duration: info.Duration})
+			}
+		}
+
+		if err := d.applyAlbumReplayGain(ctx, logger, tracks); nil != err {
+			return fmt.Errorf("apply album replay gain: %w", err)
+		}
+
+		// Album gain tags are written after the track info files, so their recorded checksums are updated.
+		for volIdx, trackIDs := range albumVolumeTrackIDs {
+			for _, trackID := range trackIDs {
+				if err := updateTrackChecksum(albumFs.Track(volIdx+1, trackID)); nil != err {
+					logger.Error().Err(err).Str("track_id", trackID).Msg("Failed to update track checksum")
+					return fmt.Errorf("update track checksum: %v", err)
+				}
+				pipe.TrackReady(trackID)
+			}
+		}
+	}
+
 	info := types.StoredAlbum{
-		Caption: fmt.Sprintf(
-			"%s (%s)",
-			album.Title,
-			album.ReleaseDate.Format(types.ReleaseDateLayout),
-		),
+		Album:          album.Stored(),
 		VolumeTrackIDs: albumVolumeTrackIDs,
 	}
 	if err := albumFs.InfoFile.Write(info); nil != err {
@@ -186,6 +276,40 @@ func (d *Downloader) album(ctx context.Context, logger zerolog.Logger, id string
 	return nil
 }
 
+// updateTrackChecksum records the checksum of the current track file contents in its info file.
+func updateTrackChecksum(trackFs fs.AlbumTrack) error {
+	info, err := trackFs.InfoFile.Read()
+	if nil != err {
+		return fmt.Errorf("read track info file: %v", err)
+	}
+
+	size, sum, err := fs.FileChecksum(trackFs.Path)
+	if nil != err {
+		return fmt.Errorf("get track file checksum: %v", err)
+	}
+	info.Size = size
+	info.SHA256 = sum
+
+	if err := trackFs.InfoFile.Write(*info); nil != err {
+		return fmt.Errorf("write track info file: %v", err)
+	}
+
+	return nil
+}
+
+func withoutAlbumTracks(tracks []AlbumTrackMeta, ids []string) []AlbumTrackMeta {
+	if len(ids) == 0 {
+		return tracks
+	}
+
+	skip := lo.Keyify(ids)
+
+	return lo.Reject(tracks, func(t AlbumTrackMeta, _ int) bool {
+		_, ok := skip[t.ID]
+		return ok
+	})
+}
+
 func (d *Downloader) getAlbumVolumes(
 	ctx context.Context,
 	logger zerolog.Logger,
@@ -206,13 +330,18 @@ func (d *Downloader) getAlbumVolumes(
 		}
 
 		for _, track := range pageTracks {
-			switch track.VolumeNumber {
-			case currentVolume:
+			switch {
+			case track.VolumeNumber == currentVolume:
 				currentVolumeTracks = append(currentVolumeTracks, track)
-			case currentVolume + 1:
+			case track.VolumeNumber > currentVolume:
+				// Volumes whose tracks are all skipped, e.g., as they are not stream ready, are kept empty, so
+				// that volumes stay at the indexes of their numbers.
 				tracks = append(tracks, currentVolumeTracks)
+				for range track.VolumeNumber - currentVolume - 1 {
+					tracks = append(tracks, nil)
+				}
 				currentVolumeTracks = []AlbumTrackMeta{track}
-				currentVolume++
+				currentVolume = track.VolumeNumber
 			default:
 				return nil, fmt.Errorf("unexpected volume number: %d", track.VolumeNumber)
 			}
@@ -225,6 +354,11 @@ func (d *Downloader) getAlbumVolumes(
 
 	tracks = append(tracks, currentVolumeTracks)
 
+	// Tracks are uploaded in the order of their numbers, rather than the order they are listed in.
+	for _, volTracks := range tracks {
+		slices.SortStableFunc(volTracks, func(a, b AlbumTrackMeta) int { return a.TrackNumber - b.TrackNumber })
+	}
+
 	return tracks, nil
 }
 
@@ -375,10 +509,7 @@ func (d *Downloader) downloadAlbumMeta(
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 	req.Header.Add("Accept", "application/json")
 
-	client := http.Client{ //nolint:exhaustruct
-		Timeout: time.Duration(d.conf.Timeouts.GetAlbumInfo) * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.albumInfo)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get album info request")
 		return nil, fmt.Errorf("send get album info request: %w", err)
@@ -417,7 +548,7 @@ func (d *Downloader) downloadAlbumMeta(
 
 		return nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return nil, ErrTooManyRequests
+		return nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -429,7 +560,7 @@ func (d *Downloader) downloadAlbumMeta(
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
 			return nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return nil, ErrTooManyRequests
+			return nil, newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
@@ -460,6 +591,7 @@ func (d *Downloader) downloadAlbumMeta(
 		Title        string `json:"title"`
 		ReleaseDate  string `json:"releaseDate"`
 		CoverID      string `json:"cover"`
+		VideoCoverID string `json:"videoCover"`
 		TotalTracks  int    `json:"numberOfTracks"`
 		TotalVolumes int    `json:"numberOfVolumes"`
 	}
@@ -479,6 +611,7 @@ func (d *Downloader) downloadAlbumMeta(
 		Title:        respBody.Title,
 		ReleaseDate:  releaseDate,
 		CoverID:      respBody.CoverID,
+		VideoCoverID: respBody.VideoCoverID,
 		TotalTracks:  respBody.TotalTracks,
 		TotalVolumes: respBody.TotalVolumes,
 	}, nil
@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// cacheURL rewrites a CDN link to be fetched through the caching proxy at cacheBaseURL.
+// The original host, path, and query are appended to the proxy base URL, e.g.,
+// https://sp-ad-fa.audio.tidal.com/mediatracks/x?token=y is rewritten to
+// http://cache.lan/sp-ad-fa.audio.tidal.com/mediatracks/x?token=y.
+func cacheURL(cacheBaseURL, link string) (string, error) {
+	u, err := url.Parse(link)
+	if nil != err {
+		return "", fmt.Errorf("parse CDN link: %v", err)
+	}
+
+	out := strings.TrimSuffix(cacheBaseURL, "/") + "/" + u.Host + u.EscapedPath()
+	if len(u.RawQuery) > 0 {
+		out += "?" + u.RawQuery
+	}
+
+	return out, nil
+}
+
+// withCDNFallback calls fetch with the caching proxy URL of link, if cacheBaseURL is set, and falls back
+// to calling it with the direct link if that fails. If f is not nil, it is rewound to its initial position
+// before falling back, so partially written proxy responses do not end up in the file.
+func withCDNFallback(
+	ctx context.Context,
+	logger zerolog.Logger,
+	cacheBaseURL string,
+	link string,
+	f *os.File,
+	fetch func(link string) error,
+) error {
+	if cacheBaseURL == "" {
+		return fetch(link)
+	}
+
+	proxied, err := cacheURL(cacheBaseURL, link)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to build CDN cache URL. Falling back to direct URL")
+		return fetch(link)
+	}
+
+	var pos int64
+	if nil != f {
+		if pos, err = f.Seek(0, io.SeekCurrent); nil != err {
+			return fmt.Errorf("get file position: %v", err)
+		}
+	}
+
+	proxyErr := fetch(proxied)
+	if nil == proxyErr {
+		return nil
+	}
+
+	if nil != ctx.Err() {
+		return proxyErr
+	}
+
+	logger.Warn().Err(proxyErr).Msg("Failed to fetch from CDN cache. Falling back to direct URL")
+
+	if nil != f {
+		if err := f.Truncate(pos); nil != err {
+			return errors.Join(proxyErr, fmt.Errorf("truncate file: %v", err))
+		}
+
+		if _, err := f.Seek(pos, io.SeekStart); nil != err {
+			return errors.Join(proxyErr, fmt.Errorf("seek file: %v", err))
+		}
+	}
+
+	return fetch(link)
+}
@@ -0,0 +1,45 @@
+package downloader
+
+import (
+	"time"
+
+	"github.com/xeptore/tidalgram/config"
+)
+
+const trackInfoTimeout = 5 * time.Second
+
+// requestTimeouts holds the timeouts of each request kind. All requests are sent using the same client, so that
+// connections are reused across requests.
+type requestTimeouts struct {
+	trackInfo    time.Duration
+	trackCredits time.Duration
+	trackLyrics  time.Duration
+	cover        time.Duration
+	albumInfo    time.Duration
+	streamURLs   time.Duration
+	playlistInfo time.Duration
+	mixInfo      time.Duration
+	pagedTracks  time.Duration
+	dashSegment  time.Duration
+	vndFileSize  time.Duration
+	vndSegment   time.Duration
+}
+
+func newRequestTimeouts(conf config.TidalDownloadTimeouts) requestTimeouts {
+	seconds := func(n int) time.Duration { return time.Duration(n) * time.Second }
+
+	return requestTimeouts{
+		trackInfo:    trackInfoTimeout,
+		trackCredits: seconds(conf.GetTrackCredits),
+		trackLyrics:  seconds(conf.GetTrackLyrics),
+		cover:        seconds(conf.DownloadCover),
+		albumInfo:    seconds(conf.GetAlbumInfo),
+		streamURLs:   seconds(conf.GetStreamURLs),
+		playlistInfo: seconds(conf.GetPlaylistInfo),
+		mixInfo:      seconds(conf.GetMixInfo),
+		pagedTracks:  seconds(conf.GetPagedTracks),
+		dashSegment:  seconds(conf.DownloadDashSegment),
+		vndFileSize:  seconds(conf.GetVNDTrackFileSize),
+		vndSegment:   seconds(conf.DownloadVNDSegment),
+	}
+}
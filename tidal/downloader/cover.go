@@ -9,18 +9,60 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/rs/zerolog"
 
 	"github.com/xeptore/tidalgram/cache"
+	"github.com/xeptore/tidalgram/config"
 	"github.com/xeptore/tidalgram/httputil"
+	"github.com/xeptore/tidalgram/ratelimit"
 	"github.com/xeptore/tidalgram/tidal/auth"
+	"github.com/xeptore/tidalgram/tidal/fs"
 )
 
 //go:embed placeholder-cover.jpg
 var placeholderCoverBytes []byte
 
+// errCoverNotFound is returned when a cover is not available in the requested resolution.
+var errCoverNotFound = errors.New("cover not found")
+
+// coverResolutions returns the names of the cover resolutions to download, in order, until one is available.
+func coverResolutions(resolution string) []string {
+	fallbacks := []string{"1280x1280", "640x640", "320x320"}
+	if resolution == config.CoverResolutionOrigin {
+		return append([]string{"origin"}, fallbacks...)
+	}
+
+	return fallbacks
+}
+
+// writeCover writes cover b with coverID to c, scaled down to the configured embed size. The cover is copied from
+// c to where it is needed next, rather than being kept in memory. See [Downloader.copyCachedCover].
+func (d *Downloader) writeCover(c fs.Cover, coverID string, b []byte) error {
+	if err := c.WriteScaled(b, d.conf.Cover.EmbedSize); nil != err {
+		return err
+	}
+	d.cache.Covers.SetPath(coverID, c.Path, cache.DefaultDownloadedCoverTTL)
+
+	return nil
+}
+
+// copyCachedCover copies the cover with coverID to c from where it was written last, if it is still there. It
+// reports whether the cover was copied.
+func (d *Downloader) copyCachedCover(logger zerolog.Logger, coverID string, c fs.Cover) bool {
+	path, ok := d.cache.Covers.Path(coverID)
+	if !ok || path == c.Path {
+		return false
+	}
+
+	if err := (fs.Cover{Path: path}).CopyTo(c); nil != err {
+		logger.Debug().Err(err).Str("cover_id", coverID).Msg("Failed to copy cached cover. Getting it again")
+		return false
+	}
+
+	return true
+}
+
 func (d *Downloader) getCover(
 	ctx context.Context,
 	logger zerolog.Logger,
@@ -35,7 +77,7 @@ func (d *Downloader) getCover(
 				return placeholderCoverBytes, nil
 			}
 
-			return d.downloadCover(ctx, logger, accessToken, coverID)
+			return d.downloadValidCover(ctx, logger, accessToken, coverID)
 		},
 	)
 	if nil != err {
@@ -45,21 +87,91 @@ func (d *Downloader) getCover(
 	return cachedCover.Value(), nil
 }
 
+// downloadValidCover downloads the cover with coverID, and downloads it once more if the downloaded
+// bytes are not a valid cover image, so that invalid covers are neither cached nor stored.
+func (d *Downloader) downloadValidCover(
+	ctx context.Context,
+	logger zerolog.Logger,
+	accessToken string,
+	coverID string,
+) ([]byte, error) {
+	b, resolution, err := d.downloadAvailableCover(ctx, logger, accessToken, coverID)
+	if nil != err {
+		return nil, err
+	}
+
+	validateErr := fs.ValidateCover(b)
+	if nil == validateErr {
+		return b, nil
+	}
+	logger.Warn().Err(validateErr).Str("cover_id", coverID).Msg("Downloaded cover is invalid. Downloading it again")
+
+	b, err = d.downloadCover(ctx, logger, accessToken, coverID, resolution)
+	if nil != err {
+		return nil, err
+	}
+
+	if err := fs.ValidateCover(b); nil != err {
+		logger.Error().Err(err).Str("cover_id", coverID).Msg("Re-downloaded cover is invalid")
+		return nil, fmt.Errorf("validate re-downloaded cover: %w", err)
+	}
+
+	return b, nil
+}
+
+// downloadAvailableCover downloads the cover with coverID in the highest of the configured resolutions that is
+// available, and returns it along with its resolution.
+func (d *Downloader) downloadAvailableCover(
+	ctx context.Context,
+	logger zerolog.Logger,
+	accessToken string,
+	coverID string,
+) ([]byte, string, error) {
+	resolutions := coverResolutions(d.conf.Cover.Resolution)
+	for _, resolution := range resolutions {
+		b, err := d.downloadCover(ctx, logger, accessToken, coverID, resolution)
+		if nil != err {
+			if errors.Is(err, errCoverNotFound) {
+				logger.Debug().Str("cover_id", coverID).Str("resolution", resolution).Msg("Cover is not available in resolution. Trying a lower one")
+				continue
+			}
+
+			return nil, "", err
+		}
+
+		return b, resolution, nil
+	}
+
+	return nil, "", fmt.Errorf("%w in any of resolutions %v", errCoverNotFound, resolutions)
+}
+
 func (d *Downloader) downloadCover(
 	ctx context.Context,
 	logger zerolog.Logger,
 	accessToken string,
 	coverID string,
+	resolution string,
 ) ([]byte, error) {
 	coverURL, err := url.JoinPath(
-		fmt.Sprintf(coverURLFormat, strings.ReplaceAll(coverID, "-", "/")),
+		fmt.Sprintf(coverURLFormat, strings.ReplaceAll(coverID, "-", "/"), resolution),
 	)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to join cover base URL with cover filepath")
 		return nil, fmt.Errorf("join cover base URL with cover filepath: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, coverURL, nil)
+	return d.downloadCoverURL(ctx, logger, accessToken, coverURL)
+}
+
+// downloadCoverURL downloads the cover image or video at coverURL. It returns errCoverNotFound if coverURL does
+// not exist.
+func (d *Downloader) downloadCoverURL(
+	ctx context.Context,
+	logger zerolog.Logger,
+	accessToken string,
+	coverURL string,
+) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ratelimit.WithClass(ctx, ratelimit.ClassMedia), http.MethodGet, coverURL, nil)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to create get cover request")
 		return nil, fmt.Errorf("create get cover request: %w", err)
@@ -67,10 +179,7 @@ func (d *Downloader) downloadCover(
 
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	client := http.Client{ //nolint:exhaustruct
-		Timeout: time.Duration(d.conf.Timeouts.DownloadCover) * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.cover)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send download cover request")
 		return nil, fmt.Errorf("send download cover request: %w", err)
@@ -108,8 +217,10 @@ func (d *Downloader) downloadCover(
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 401 response")
 
 		return nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
+	case http.StatusNotFound:
+		return nil, errCoverNotFound
 	case http.StatusTooManyRequests:
-		return nil, ErrTooManyRequests
+		return nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -121,7 +232,7 @@ func (d *Downloader) downloadCover(
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
 			return nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return nil, ErrTooManyRequests
+			return nil, newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
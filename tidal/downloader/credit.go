@@ -14,32 +14,40 @@ import (
 	"github.com/tidwall/gjson"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/xeptore/tidalgram/tidal/fs"
 	"github.com/xeptore/tidalgram/tidal/types"
 )
 
 func (d *Downloader) artistCredits(ctx context.Context, logger zerolog.Logger, id string) error {
 	creds := d.auth.Credentials()
 
-	tracks, err := d.getArtistCreditsTracks(ctx, logger, creds.Token, creds.CountryCode, id)
+	tracks, err := d.getArtistCreditsTracks(ctx, logger, creds.Token, d.countryCode(creds), id)
 	if nil != err {
-		return fmt.Errorf("get artist credits tracks: %w", err)
+		return newStageError(StageMetadata, "", fmt.Errorf("get artist credits tracks: %w", err))
 	}
 
 	var (
 		creditsFs = d.dir.ArtistCredits(id)
 		wg, wgctx = errgroup.WithContext(ctx)
+		failed    = newFailedTracks(d.conf.FailedTracks)
 	)
 
 	wg.SetLimit(d.conf.Concurrency.ArtistCreditsTracks)
 
 	for i, track := range tracks {
-		wg.Go(func() (err error) {
+		wg.Go(failed.guard(wgctx, logger, func() (err error) {
 			select {
 			case <-wgctx.Done():
-				return nil
+				return wgctx.Err()
 			default:
 			}
 
+			release, err := d.warmup.Acquire(wgctx)
+			if nil != err {
+				return fmt.Errorf("wait for warmup: %w", err)
+			}
+			defer release()
+
 			logger := logger.With().Int("track_index", i).Str("track_id", track.ID).Logger()
 
 			trackFs := creditsFs.Track(track.ID)
@@ -47,15 +55,15 @@ func (d *Downloader) artistCredits(ctx context.Context, logger zerolog.Logger, i
 			if exists, err := trackFs.Cover.AlreadyDownloaded(); nil != err {
 				logger.Error().Err(err).Msg("Failed to check if track cover exists")
 				return fmt.Errorf("check if track cover exists: %v", err)
-			} else if !exists {
+			} else if !exists && !d.copyCachedCover(logger, track.CoverID, trackFs.Cover) {
 				coverBytes, err := d.getCover(wgctx, logger, creds.Token, track.CoverID)
 				if nil != err {
-					return fmt.Errorf("get track cover: %w", err)
+					return newStageError(StageMetadata, track.ID, fmt.Errorf("get track cover: %w", err))
 				}
 
-				if err := trackFs.Cover.Write(coverBytes); nil != err {
+				if err := d.writeCover(trackFs.Cover, track.CoverID, coverBytes); nil != err {
 					logger.Error().Err(err).Msg("Failed to write track cover")
-					return fmt.Errorf("write track cover: %v", err)
+					return newStageError(StageMetadata, track.ID, fmt.Errorf("write track cover: %v", err))
 				}
 			}
 
@@ -76,24 +84,24 @@ func (d *Downloader) artistCredits(ctx context.Context, logger zerolog.Logger, i
 				}
 			}()
 
-			ext, err := d.downloadTrack(wgctx, logger, creds.Token, track.ID, trackFs.Path)
+			ext, skippedAudioMode, err := d.downloadTrack(wgctx, logger, creds.Token, track.ID, trackFs.Path)
 			if nil != err {
-				return fmt.Errorf("download track: %w", err)
+				return newStageError(StageDownload, track.ID, fmt.Errorf("download track: %w", err))
 			}
 
-			trackCredits, err := d.getTrackCredits(wgctx, logger, creds.Token, creds.CountryCode, track.ID)
+			trackCredits, err := d.getTrackCredits(wgctx, logger, creds.Token, d.countryCode(creds), track.ID)
 			if nil != err {
-				return fmt.Errorf("get track credits: %w", err)
+				return newStageError(StageMetadata, track.ID, fmt.Errorf("get track credits: %w", err))
 			}
 
-			trackLyrics, err := d.downloadTrackLyrics(wgctx, logger, creds.Token, creds.CountryCode, track.ID)
+			trackLyrics, err := d.downloadTrackLyrics(wgctx, logger, creds.Token, d.countryCode(creds), track.ID)
 			if nil != err {
-				return fmt.Errorf("download track lyrics: %w", err)
+				return newStageError(StageMetadata, track.ID, fmt.Errorf("download track lyrics: %w", err))
 			}
 
-			album, err := d.getAlbumMeta(wgctx, logger, creds.Token, creds.CountryCode, track.AlbumID)
+			album, err := d.getAlbumMeta(wgctx, logger, creds.Token, d.countryCode(creds), track.AlbumID)
 			if nil != err {
-				return fmt.Errorf("get album meta: %w", err)
+				return newStageError(StageMetadata, track.ID, fmt.Errorf("get album meta: %w", err))
 			}
 
 			attrs := TrackEmbeddedAttrs{
@@ -112,25 +120,53 @@ func (d *Downloader) artistCredits(ctx context.Context, logger zerolog.Logger, i
 				VolumeNumber: track.VolumeNumber,
 				TotalVolumes: album.TotalVolumes,
 				Credits:      *trackCredits,
-				Lyrics:       trackLyrics,
+				Lyrics:       *trackLyrics,
 				Ext:          ext,
 			}
-			if err := embedTrackAttributes(wgctx, logger, trackFs.Path, attrs); nil != err {
-				return fmt.Errorf("embed track attributes: %w", err)
+			if err := d.checkMetadata(wgctx, track.ID, track.CoverID, attrs); nil != err {
+				return err
+			}
+			if err := d.embedTrackAttributes(wgctx, logger, trackFs.Path, attrs); nil != err {
+				return newStageError(StageTagging, track.ID, fmt.Errorf("embed track attributes: %w", err))
+			}
+			if err := verifyTrackTags(wgctx, logger, track.ID, trackFs.Path, attrs); nil != err {
+				return err
+			}
+
+			if d.conf.ReplayGain {
+				rgTrack := replayGainTrack{ID: track.ID, Path: trackFs.Path, Ext: ext, Duration: track.Duration}
+				if err := applyTrackReplayGain(wgctx, logger, rgTrack); nil != err {
+					return newStageError(StageTagging, track.ID, fmt.Errorf("apply replay gain: %w", err))
+				}
+			}
+
+			if err := writeLyricsFile(trackFs.Lyrics, attrs); nil != err {
+				logger.Error().Err(err).Msg("Failed to write track lyrics file")
+				return fmt.Errorf("write track lyrics file: %v", err)
+			}
+
+			size, sum, err := fs.FileChecksum(trackFs.Path)
+			if nil != err {
+				logger.Error().Err(err).Msg("Failed to get track file checksum")
+				return fmt.Errorf("get track file checksum: %v", err)
 			}
 
 			info := types.StoredTrack{
 				Track: types.Track{
-					Artists:      track.Artists,
-					Title:        track.Title,
-					TrackNumber:  track.TrackNumber,
-					VolumeNumber: track.VolumeNumber,
-					Duration:     track.Duration,
-					Version:      track.Version,
-					CoverID:      track.CoverID,
-					Ext:          ext,
+					Artists:          track.Artists,
+					Title:            track.Title,
+					TrackNumber:      track.TrackNumber,
+					VolumeNumber:     track.VolumeNumber,
+					Duration:         track.Duration,
+					Version:          track.Version,
+					CoverID:          track.CoverID,
+					Ext:              ext,
+					SkippedAudioMode: skippedAudioMode,
+					Size:             size,
+					SHA256:           sum,
 				},
-				Caption: trackCaption(album.Title, album.ReleaseDate),
+				Album:    album.Stored(),
+				Metadata: attrs.metadata(track.ID, track.AlbumID, track.Duration),
 			}
 			if err := trackFs.InfoFile.Write(info); nil != err {
 				logger.Error().Err(err).Msg("Failed to write track info")
@@ -138,7 +174,7 @@ func (d *Downloader) artistCredits(ctx context.Context, logger zerolog.Logger, i
 			}
 
 			return nil
-		})
+		}))
 	}
 
 	if err := wg.Wait(); nil != err {
@@ -146,7 +182,8 @@ func (d *Downloader) artistCredits(ctx context.Context, logger zerolog.Logger, i
 	}
 
 	info := types.StoredArtistCredits{
-		TrackIDs: lo.Map(tracks, func(t ListTrackMeta, _ int) string { return t.ID }),
+		TrackIDs:     failed.without(lo.Map(tracks, func(t ListTrackMeta, _ int) string { return t.ID })),
+		FailedTracks: failed.list(),
 	}
 	if err := creditsFs.InfoFile.Write(info); nil != err {
 		logger.Error().Err(err).Msg("Failed to write artist credits info")
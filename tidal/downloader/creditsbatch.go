@@ -0,0 +1,82 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/xeptore/tidalgram/cache"
+)
+
+// prefetchTrackCredits caches the credits of tracks in batches of their parent albums, with the credits of the
+// rest of the tracks of the albums, so that the credits of most of the tracks of a big playlist or mix are not
+// fetched one by one. Only albums that at least the configured number of tracks without cached credits belong
+// to are fetched. Failures are only logged, as the credits of the tracks that were not cached are fetched one by
+// one as usual.
+func (d *Downloader) prefetchTrackCredits(
+	ctx context.Context,
+	logger zerolog.Logger,
+	accessToken string,
+	countryCode string,
+	tracks []ListTrackMeta,
+) {
+	conf := d.conf.CreditsBatch
+	if conf.Disabled {
+		return
+	}
+
+	uncached := lo.Filter(tracks, func(t ListTrackMeta, _ int) bool {
+		return len(t.AlbumID) > 0 && !d.cache.TrackCredits.Cached(t.ID)
+	})
+	albumIDs := lo.Keys(lo.PickBy(
+		lo.CountValuesBy(uncached, func(t ListTrackMeta) string { return t.AlbumID }),
+		func(_ string, n int) bool { return n >= conf.MinTracks },
+	))
+	if len(albumIDs) == 0 {
+		return
+	}
+
+	var wg errgroup.Group
+	wg.SetLimit(conf.Concurrency)
+	for _, albumID := range albumIDs {
+		wg.Go(func() error {
+			if nil != ctx.Err() {
+				return nil
+			}
+
+			logger := logger.With().Str("album_id", albumID).Logger()
+			if err := d.cacheAlbumTracksCredits(ctx, logger, accessToken, countryCode, albumID); nil != err && nil == ctx.Err() {
+				logger.Warn().Err(err).Msg("Failed to prefetch album tracks credits. Track credits will be fetched one by one")
+			}
+
+			return nil
+		})
+	}
+	_ = wg.Wait()
+
+	logger.Debug().Int("albums", len(albumIDs)).Int("tracks", len(uncached)).Msg("Prefetched tracks credits")
+}
+
+// cacheAlbumTracksCredits caches the credits of the tracks of album id, all of which are listed with the album
+// tracks.
+func (d *Downloader) cacheAlbumTracksCredits(
+	ctx context.Context,
+	logger zerolog.Logger,
+	accessToken string,
+	countryCode string,
+	id string,
+) error {
+	volumes, err := d.getAlbumVolumes(ctx, logger, accessToken, countryCode, id)
+	if nil != err {
+		return fmt.Errorf("get album volumes: %w", err)
+	}
+
+	for _, track := range lo.Flatten(volumes) {
+		d.cache.TrackCredits.Set(track.ID, &track.Credits, cache.DefaultTrackCreditsTTL)
+	}
+
+	return nil
+}
@@ -13,16 +13,23 @@ import (
 
 	"github.com/rs/zerolog"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 
 	"github.com/xeptore/tidalgram/httputil"
 	"github.com/xeptore/tidalgram/mathutil"
+	"github.com/xeptore/tidalgram/pause"
+	"github.com/xeptore/tidalgram/ratelimit"
 	"github.com/xeptore/tidalgram/tidal/auth"
 	"github.com/xeptore/tidalgram/tidal/mpd"
 )
 
 type DashTrackStream struct {
-	Info            mpd.StreamInfo
-	DownloadTimeout time.Duration
+	Info         mpd.StreamInfo
+	Client       *httputil.Client
+	Timeout      time.Duration
+	CacheBaseURL string
+	Bandwidth    *rate.Limiter
+	Gate         *pause.Gate
 }
 
 func (d *DashTrackStream) saveTo(
@@ -41,7 +48,7 @@ func (d *DashTrackStream) saveTo(
 		wg.Go(func() error {
 			select {
 			case <-wgctx.Done():
-				return nil
+				return wgctx.Err()
 			default:
 			}
 
@@ -93,7 +100,7 @@ func (d *DashTrackStream) saveTo(
 	return nil
 }
 
-func writeChunkToTrackFile(f *os.File, logger zerolog.Logger, chunkFileName string) (err error) {
+func writeChunkToTrackFile(f io.Writer, logger zerolog.Logger, chunkFileName string) (err error) {
 	fp, err := os.OpenFile(chunkFileName, os.O_RDONLY, 0o0600)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to open track chunk file")
@@ -160,13 +167,18 @@ func (d *DashTrackStream) downloadChunk(
 	end := min(d.Info.Parts.Count, (idx+1)*maxChunkParts)
 
 	for i := start; i < end; i++ {
+		if err := d.Gate.Wait(ctx); nil != err {
+			return fmt.Errorf("wait for paused job: %w", err)
+		}
+
 		link := strings.Replace(
 			d.Info.Parts.InitializationURLTemplate,
 			"$Number$",
 			strconv.Itoa(i),
 			1,
 		)
-		if err := d.downloadSegment(ctx, logger, accessToken, link, f); nil != err {
+		fetch := func(link string) error { return d.downloadSegment(ctx, logger, accessToken, link, f) }
+		if err := withCDNFallback(ctx, logger, d.CacheBaseURL, link, f, fetch); nil != err {
 			return fmt.Errorf("download track segment: %w", err)
 		}
 	}
@@ -181,7 +193,7 @@ func (d *DashTrackStream) downloadSegment(
 	link string,
 	f *os.File,
 ) (err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	req, err := http.NewRequestWithContext(ratelimit.WithClass(ctx, ratelimit.ClassMedia), http.MethodGet, link, nil)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to create get track segment request")
 		return fmt.Errorf("create get track segment request: %w", err)
@@ -189,8 +201,7 @@ func (d *DashTrackStream) downloadSegment(
 
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	client := http.Client{Timeout: d.DownloadTimeout} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := d.Client.Do(req, d.Timeout)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send track segment download request")
 		return fmt.Errorf("send track segment download request: %w", err)
@@ -229,7 +240,7 @@ func (d *DashTrackStream) downloadSegment(
 
 		return fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return ErrTooManyRequests
+		return readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -241,7 +252,7 @@ func (d *DashTrackStream) downloadSegment(
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
 			return fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return ErrTooManyRequests
+			return newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
@@ -259,7 +270,7 @@ func (d *DashTrackStream) downloadSegment(
 		return fmt.Errorf("unexpected response code %d with body: %s", code, string(respBytes))
 	}
 
-	if n, err := io.Copy(f, resp.Body); nil != err {
+	if n, err := copyVerified(ctx, f, resp, d.Bandwidth); nil != err {
 		logger.Error().Err(err).Msg("Failed to write track segment to file")
 		return fmt.Errorf("write track segment to file: %w", err)
 	} else if n == 0 {
@@ -0,0 +1,134 @@
+package downloader
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/errs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// FFmpegError reports a failed ffmpeg run, along with what is needed to reproduce it.
+type FFmpegError struct {
+	Args   []string
+	Stderr string
+	// Probe is the ffprobe output of the input track file, or the reason it could not be probed.
+	Probe string
+	Err   error
+}
+
+func (e *FFmpegError) Error() string {
+	return fmt.Sprintf("write track attributes using ffmpeg (%v): %s", e.Err, e.Stderr)
+}
+
+func (e *FFmpegError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FFmpegError) ErrorCategory() errs.Category {
+	return errs.CategoryFFmpeg
+}
+
+// ffmpegVersion returns the output of ffmpeg -version, which is only run once.
+var ffmpegVersion = sync.OnceValue(func() string {
+	out, err := exec.Command("ffmpeg", "-version").CombinedOutput()
+	if nil != err {
+		return "failed to get ffmpeg version: " + err.Error() + "\n" + string(out)
+	}
+
+	return string(out)
+})
+
+// probeTrack returns the ffprobe output of the track file at path, or the reason it could not be probed.
+func probeTrack(ctx context.Context, path string) string {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.
+		CommandContext(ctx, "ffprobe", "-v", "error", "-show_format", "-show_streams", "-of", "json", path).
+		CombinedOutput()
+	if nil != err {
+		return "failed to probe track file: " + err.Error() + "\n" + string(out)
+	}
+
+	return string(out)
+}
+
+// writeDebugBundle stores the details of the failed ffmpeg run of err, if any, as a ZIP archive, so that it
+// can be requested later using the debug command.
+func (d *Downloader) writeDebugBundle(logger zerolog.Logger, link types.Link, err error) {
+	var (
+		ffmpegErr *FFmpegError
+		stageErr  *StageError
+	)
+	if !errors.As(err, &ffmpegErr) || !errors.As(err, &stageErr) || len(stageErr.TrackID) == 0 {
+		return
+	}
+
+	bundle := d.dir.DebugBundle(link, stageErr.TrackID)
+	logger = logger.With().Str("track_id", stageErr.TrackID).Str("debug_bundle", bundle.Path).Logger()
+
+	if err := writeFFmpegBundle(bundle.Path, ffmpegErr); nil != err {
+		logger.Error().Err(err).Msg("Failed to write debug bundle")
+		return
+	}
+	logger.Info().Msg("Debug bundle written")
+}
+
+func writeFFmpegBundle(path string, ffmpegErr *FFmpegError) (err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if nil != err {
+		return fmt.Errorf("create debug bundle file: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close debug bundle file: %v", closeErr))
+		}
+	}()
+
+	quoted := make([]string, 0, len(ffmpegErr.Args)+1)
+	quoted = append(quoted, "ffmpeg")
+	for _, arg := range ffmpegErr.Args {
+		quoted = append(quoted, strconv.Quote(arg))
+	}
+
+	entries := []struct {
+		name    string
+		content string
+	}{
+		{name: "command.txt", content: strings.Join(quoted, " ") + "\n"},
+		{name: "error.txt", content: ffmpegErr.Err.Error() + "\n"},
+		{name: "stderr.txt", content: ffmpegErr.Stderr},
+		{name: "probe.json", content: ffmpegErr.Probe},
+		{name: "ffmpeg-version.txt", content: ffmpegVersion()},
+	}
+
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if nil != err {
+			return fmt.Errorf("create %s entry: %v", e.name, err)
+		}
+
+		if _, err := io.WriteString(w, e.content); nil != err {
+			return fmt.Errorf("write %s entry: %v", e.name, err)
+		}
+	}
+
+	if err := zw.Close(); nil != err {
+		return fmt.Errorf("close zip writer: %v", err)
+	}
+
+	return nil
+}
@@ -8,13 +8,18 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
-	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+	"golang.org/x/time/rate"
 
 	"github.com/xeptore/tidalgram/cache"
 	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/errs"
 	"github.com/xeptore/tidalgram/httputil"
+	"github.com/xeptore/tidalgram/linkkind"
+	"github.com/xeptore/tidalgram/pause"
+	"github.com/xeptore/tidalgram/ratelimit"
 	"github.com/xeptore/tidalgram/tidal/auth"
 	"github.com/xeptore/tidalgram/tidal/fs"
 	"github.com/xeptore/tidalgram/tidal/types"
@@ -29,7 +34,7 @@ const (
 	albumItemsCreditsAPIFormat = "https://api.tidal.com/v1/albums/%s/items/credits" //nolint:gosec
 	playlistItemsAPIFormat     = "https://api.tidal.com/v1/playlists/%s/items"
 	mixItemsAPIFormat          = "https://api.tidal.com/v1/mixes/%s/items"
-	coverURLFormat             = "https://resources.tidal.com/images/%s/1280x1280.jpg"
+	coverURLFormat             = "https://resources.tidal.com/images/%s/%s.jpg"
 	pageSize                   = 100
 	artistCreditsPageSize      = 50
 	maxChunkParts              = 10
@@ -37,9 +42,11 @@ const (
 )
 
 var (
-	ErrTooManyRequests           = errors.New("too many requests")
+	ErrTooManyRequests           = errs.New(errs.CategoryRateLimit, "too many requests")
 	ErrUnsupportedArtistLinkKind = errors.New("artist link kind is not supported")
 	ErrUnsupportedVideoLinkKind  = errors.New("video link kind is not supported")
+	ErrUnsupportedSyncLinkKind   = errors.New("only playlist and mix links can be synced")
+	ErrUnsupportedUpdateLinkKind = errors.New("only album links can be updated")
 )
 
 type ListTrackMeta struct {
@@ -59,47 +66,210 @@ type ListTrackMeta struct {
 }
 
 type Downloader struct {
-	dir   fs.DownloadsDir
-	auth  *auth.Auth
-	conf  config.TidalDownloader
-	cache *cache.Cache
+	dir      fs.DownloadsDir
+	auth     *auth.Pool
+	conf     config.TidalDownloader
+	cache    *cache.Cache
+	client   *httputil.Client
+	timeouts requestTimeouts
+	playback *playbackInfoCache
+	// networkFS assembles track files in a local temporary directory before copying them to dir.
+	networkFS bool
+	// bandwidth limits the total download bandwidth of track files. It is nil if unlimited.
+	bandwidth *rate.Limiter
+	gate      *pause.Gate
+	// warmup limits the concurrency of track downloads right after startup. It is nil if disabled.
+	warmup *ratelimit.Warmup
+	// country overrides the country of the logged in account in API requests, if set.
+	country string
+}
+
+// newWarmup returns the warmup of track downloads, which ramps up to the highest configured track concurrency.
+func newWarmup(conf config.TidalDownloader) *ratelimit.Warmup {
+	if conf.Warmup.Disabled {
+		return nil
+	}
+
+	c := conf.Concurrency
+	to := max(c.AlbumTracks, c.PlaylistTracks, c.MixTracks, c.ArtistCreditsTracks)
+
+	return ratelimit.NewWarmup(conf.Warmup.Duration.Duration, conf.Warmup.Tracks, conf.Warmup.Concurrency, to)
 }
 
 func NewDownloader(
 	dir fs.DownloadsDir,
 	conf config.TidalDownloader,
-	auth *auth.Auth,
+	auth *auth.Pool,
 	cache *cache.Cache,
+	networkFS bool,
+	gate *pause.Gate,
+	country string,
+	client *httputil.Client,
 ) *Downloader {
 	return &Downloader{
-		dir:   dir,
-		conf:  conf,
-		auth:  auth,
-		cache: cache,
+		dir:       dir,
+		conf:      conf,
+		auth:      auth,
+		cache:     cache,
+		client:    client,
+		timeouts:  newRequestTimeouts(conf.Timeouts),
+		playback:  newPlaybackInfoCache(),
+		networkFS: networkFS,
+		bandwidth: ratelimit.NewBandwidth(conf.MaxBandwidth),
+		gate:      gate,
+		warmup:    newWarmup(conf),
+		country:   country,
+	}
+}
+
+// Reload applies conf to the downloads started after it, except for the bandwidth limit and warmup, which are
+// set up once. It must not be called while a download is running. It reports whether either of them changed,
+// which requires a restart to take effect.
+func (d *Downloader) Reload(conf config.TidalDownloader) bool {
+	restart := conf.MaxBandwidth != d.conf.MaxBandwidth || conf.Warmup != d.conf.Warmup
+	conf.MaxBandwidth, conf.Warmup = d.conf.MaxBandwidth, d.conf.Warmup
+
+	// Written covers are scaled down to the embed size, hence they are not copied once it changes.
+	if conf.Cover.EmbedSize != d.conf.Cover.EmbedSize {
+		d.cache.Covers.ClearPaths()
+	}
+
+	d.conf = conf
+	d.timeouts = newRequestTimeouts(conf.Timeouts)
+
+	return restart
+}
+
+// countryCode returns the country code sent in API requests, which is the configured one if set, and the
+// country of the account of creds otherwise.
+func (d *Downloader) countryCode(creds *auth.Credentials) string {
+	if len(d.country) > 0 {
+		return d.country
 	}
+
+	return creds.CountryCode
+}
+
+// ResetPlaybackInfo forgets the track streams fetched by the finished job.
+func (d *Downloader) ResetPlaybackInfo() {
+	d.playback.clear()
 }
 
 func (d *Downloader) Download(ctx context.Context, logger zerolog.Logger, link types.Link) error {
+	err := d.download(ctx, logger, link)
+	if nil != err {
+		d.writeDebugBundle(logger, link, err)
+	} else {
+		d.linkLibrary(logger, link)
+	}
+
+	return err
+}
+
+func (d *Downloader) download(ctx context.Context, logger zerolog.Logger, link types.Link) error {
 	switch k := link.Kind; k {
 	case types.LinkKindArtistCredits:
 		return d.artistCredits(ctx, logger, link.ID)
 	case types.LinkKindAlbum:
-		return d.album(ctx, logger, link.ID)
+		return d.album(ctx, logger, link.ID, nil)
 	case types.LinkKindTrack:
 		return d.track(ctx, logger, link.ID)
 	case types.LinkKindMix:
-		return d.mix(ctx, logger, link.ID)
+		return d.mix(ctx, logger, link.ID, nil)
 	case types.LinkKindPlaylist:
-		return d.playlist(ctx, logger, link.ID)
+		return d.playlist(ctx, logger, link.ID, nil)
 	case types.LinkKindArtist:
 		return ErrUnsupportedArtistLinkKind
 	case types.LinkKindVideo:
 		return ErrUnsupportedVideoLinkKind
 	default:
+		if custom, ok := linkkind.Lookup(k); ok {
+			if err := custom.Download(ctx, logger, d.dir.Playlist(link.StoredAs().ID), link.ID); nil != err {
+				return fmt.Errorf("download %s link: %w", k, err)
+			}
+
+			return nil
+		}
+
 		panic("unexpected link kind: " + strconv.Itoa(int(k)))
 	}
 }
 
+// Sync downloads the playlist or mix tracks that are not in syncedIDs. The stored info file
+// of the link only lists the newly downloaded tracks.
+func (d *Downloader) Sync(ctx context.Context, logger zerolog.Logger, link types.Link, syncedIDs []string) error {
+	err := d.sync(ctx, logger, link, syncedIDs)
+	if nil != err {
+		d.writeDebugBundle(logger, link, err)
+	} else {
+		d.linkLibrary(logger, link)
+	}
+
+	return err
+}
+
+func (d *Downloader) sync(ctx context.Context, logger zerolog.Logger, link types.Link, syncedIDs []string) error {
+	switch link.Kind {
+	case types.LinkKindMix:
+		return d.mix(ctx, logger, link.ID, syncedIDs)
+	case types.LinkKindPlaylist:
+		return d.playlist(ctx, logger, link.ID, syncedIDs)
+	default:
+		return ErrUnsupportedSyncLinkKind
+	}
+}
+
+// Update downloads the album tracks that are not in uploadedIDs, e.g., tracks added to a deluxe edition after
+// the album was uploaded. The stored info file of the album only lists the newly downloaded tracks.
+func (d *Downloader) Update(ctx context.Context, logger zerolog.Logger, link types.Link, uploadedIDs []string) error {
+	if link.Kind != types.LinkKindAlbum {
+		return ErrUnsupportedUpdateLinkKind
+	}
+
+	err := d.album(ctx, logger, link.ID, uploadedIDs)
+	if nil != err {
+		d.writeDebugBundle(logger, link, err)
+	} else {
+		d.linkLibrary(logger, link)
+	}
+
+	return err
+}
+
+func withoutTracks(tracks []ListTrackMeta, ids []string) []ListTrackMeta {
+	if len(ids) == 0 {
+		return tracks
+	}
+
+	skip := lo.Keyify(ids)
+
+	return lo.Reject(tracks, func(t ListTrackMeta, _ int) bool {
+		_, ok := skip[t.ID]
+		return ok
+	})
+}
+
+// applyDuplicatesPolicy returns the distinct tracks of tracks to download, and the IDs of the tracks to upload,
+// in order, according to the configured duplicate tracks policy. Duplicates are the IDs of the tracks that
+// were dropped by the policy, one per dropped occurrence.
+func (d *Downloader) applyDuplicatesPolicy(tracks []ListTrackMeta) (unique []ListTrackMeta, trackIDs, duplicates []string) {
+	unique = lo.UniqBy(tracks, func(t ListTrackMeta) string { return t.ID })
+	trackIDs = lo.Map(tracks, func(t ListTrackMeta, _ int) string { return t.ID })
+	if d.conf.DuplicateTracks == config.DuplicateTracksKeep {
+		return unique, trackIDs, nil
+	}
+
+	seen := make(map[string]struct{}, len(unique))
+	for _, id := range trackIDs {
+		if _, ok := seen[id]; ok {
+			duplicates = append(duplicates, id)
+		}
+		seen[id] = struct{}{}
+	}
+
+	return unique, lo.Uniq(trackIDs), duplicates
+}
+
 func (d *Downloader) getListPagedItems(
 	ctx context.Context,
 	logger zerolog.Logger,
@@ -141,10 +311,7 @@ func (d *Downloader) httpGet(
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 	req.Header.Add("Accept", "application/json")
 
-	client := http.Client{ //nolint:exhaustruct
-		Timeout: time.Duration(d.conf.Timeouts.GetPagedTracks) * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.pagedTracks)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get paged tracks request")
 		return nil, fmt.Errorf("send get paged tracks request: %w", err)
@@ -183,7 +350,7 @@ func (d *Downloader) httpGet(
 
 		return nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return nil, ErrTooManyRequests
+		return nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -195,7 +362,7 @@ func (d *Downloader) httpGet(
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
 			return nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return nil, ErrTooManyRequests
+			return nil, newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/httputil"
+)
+
+type Stage string
+
+const (
+	StageMetadata Stage = "metadata"
+	StageDownload Stage = "download"
+	StageTagging  Stage = "tagging"
+)
+
+// StageError annotates a download failure with the stage it happened in and, if it was
+// specific to a single track, the ID of that track.
+type StageError struct {
+	Stage   Stage
+	TrackID string
+	Err     error
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+func newStageError(stage Stage, trackID string, err error) error {
+	return &StageError{Stage: stage, TrackID: trackID, Err: err}
+}
+
+// RateLimitedError is ErrTooManyRequests with the backoff hint of the throttled response.
+type RateLimitedError struct {
+	// RetryAfter is how long the response told to wait before retrying, or zero if it did not tell.
+	RetryAfter time.Duration
+	// SubStatus is the Tidal sub-status of the response, or zero if it has none.
+	SubStatus int
+	// UserMessage is the message of the response, if any.
+	UserMessage string
+}
+
+func (e *RateLimitedError) Error() string {
+	msg := ErrTooManyRequests.Error()
+	if e.SubStatus != 0 {
+		msg += fmt.Sprintf(" (sub-status %d: %s)", e.SubStatus, e.UserMessage)
+	}
+	if e.RetryAfter > 0 {
+		msg += ", retry after " + e.RetryAfter.String()
+	}
+
+	return msg
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrTooManyRequests
+}
+
+// newRateLimitedError returns the error of the throttled resp with body, extracting its backoff hint.
+func newRateLimitedError(resp *http.Response, body []byte) error {
+	err := &RateLimitedError{
+		RetryAfter:  httputil.RetryAfter(resp, time.Now()),
+		SubStatus:   0,
+		UserMessage: "",
+	}
+	if payload, ok := httputil.ParseErrorResponse(body); ok {
+		err.SubStatus = payload.SubStatus
+		err.UserMessage = payload.UserMessage
+	}
+
+	return err
+}
+
+// readRateLimitedError reads the body of the 429 resp, and returns its error. The backoff hint of the headers of
+// resp is kept if its body cannot be read.
+func readRateLimitedError(logger zerolog.Logger, resp *http.Response) error {
+	respBytes, err := io.ReadAll(resp.Body)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to read 429 response body")
+	}
+
+	return newRateLimitedError(resp, respBytes)
+}
@@ -0,0 +1,106 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/config"
+	"github.com/xeptore/tidalgram/errs"
+	"github.com/xeptore/tidalgram/tidal/auth"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// failedTracks collects the tracks of a playlist, mix, or artist credits that fail to download, and are skipped
+// according to the failed tracks policy.
+type failedTracks struct {
+	skip       bool
+	maxSkipped int
+
+	mu     sync.Mutex
+	tracks []types.StoredFailedTrack
+}
+
+func newFailedTracks(conf config.TidalDownloadFailedTracks) *failedTracks {
+	return &failedTracks{
+		skip:       conf.Policy == config.FailedTracksSkip,
+		maxSkipped: conf.MaxSkipped,
+		mu:         sync.Mutex{},
+		tracks:     nil,
+	}
+}
+
+// guard returns fn, which downloads a track, skipping its failure if it can be skipped.
+func (f *failedTracks) guard(ctx context.Context, logger zerolog.Logger, fn func() error) func() error {
+	return func() error {
+		err := fn()
+		if nil == err || !f.record(ctx, logger, err) {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// record records the failed track of err, and reports whether it is skipped. Failures are not skipped if the
+// policy does not allow it, if too many tracks failed already, or if err would fail the rest of the tracks as
+// well.
+func (f *failedTracks) record(ctx context.Context, logger zerolog.Logger, err error) bool {
+	if !f.skip || nil != ctx.Err() {
+		return false
+	}
+
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || len(stageErr.TrackID) == 0 {
+		return false
+	}
+
+	var metaErr *MissingMetadataError
+	if errors.As(err, &metaErr) || errors.Is(err, auth.ErrUnauthorized) {
+		return false
+	}
+
+	category := errs.Classify(err)
+	switch category {
+	case errs.CategoryAuth, errs.CategoryRateLimit, errs.CategoryDisk:
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.tracks) >= f.maxSkipped {
+		return false
+	}
+
+	f.tracks = append(f.tracks, types.StoredFailedTrack{
+		ID:    stageErr.TrackID,
+		Stage: string(stageErr.Stage),
+		Code:  category.Code(),
+		Error: err.Error(),
+	})
+	logger.Error().Err(err).Str("track_id", stageErr.TrackID).Msg("Track failed to download. Skipping it")
+
+	return true
+}
+
+// list returns the skipped tracks.
+func (f *failedTracks) list() []types.StoredFailedTrack {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.tracks)
+}
+
+// without returns trackIDs without the skipped tracks.
+func (f *failedTracks) without(trackIDs []string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.DeleteFunc(slices.Clone(trackIDs), func(id string) bool {
+		return slices.ContainsFunc(f.tracks, func(t types.StoredFailedTrack) bool { return t.ID == id })
+	})
+}
@@ -0,0 +1,38 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// ffmpegCommand returns an ffmpeg command that is interrupted, along with its child processes, once ctx is done.
+func ffmpegCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} //nolint:exhaustruct
+
+	cmd.Cancel = func() error {
+		proc := cmd.Process
+		if proc == nil {
+			return os.ErrProcessDone
+		}
+
+		// Sends the signal to process group (-pid) so child processes get it too.
+		_ = syscall.Kill(-proc.Pid, syscall.SIGINT)
+
+		for {
+			time.Sleep(300 * time.Millisecond)
+
+			if err := syscall.Kill(proc.Pid, 0); nil != err {
+				return os.ErrProcessDone
+			}
+
+			_ = syscall.Kill(-proc.Pid, syscall.SIGINT)
+		}
+	}
+
+	return cmd
+}
@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+type jobISRCsKey struct{}
+
+// jobISRCs maps the ISRCs of the tracks of a job to the IDs of the tracks they were first seen with.
+type jobISRCs struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// WithJob returns a copy of ctx under which the downloaded links are deduplicated by ISRC as a single job, if
+// enabled. Links are deduplicated on their own otherwise.
+func WithJob(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jobISRCsKey{}, &jobISRCs{mu: sync.Mutex{}, ids: make(map[string]string)})
+}
+
+// filterTracks returns tracks without the ones the configured filters skip, and the skipped ones.
+func (d *Downloader) filterTracks(ctx context.Context, tracks []ListTrackMeta) ([]ListTrackMeta, []types.StoredFilteredTrack) {
+	var (
+		conf     = d.conf.Filters
+		patterns = conf.SkipTitlePatterns()
+		kept     = make([]ListTrackMeta, 0, len(tracks))
+		skipped  []types.StoredFilteredTrack
+	)
+
+	isrcs, ok := ctx.Value(jobISRCsKey{}).(*jobISRCs)
+	if !ok {
+		isrcs = &jobISRCs{mu: sync.Mutex{}, ids: make(map[string]string)}
+	}
+	isrcs.mu.Lock()
+	defer isrcs.mu.Unlock()
+
+	for _, t := range tracks {
+		title := t.Title
+		if nil != t.Version && len(*t.Version) > 0 {
+			title += " (" + *t.Version + ")"
+		}
+		skip := func(reason string) {
+			skipped = append(skipped, types.StoredFilteredTrack{ID: t.ID, Title: title, Reason: reason})
+		}
+
+		if maxDuration := conf.MaxDuration.Duration; maxDuration > 0 && time.Duration(t.Duration)*time.Second > maxDuration {
+			skip("longer than " + maxDuration.String())
+			continue
+		}
+
+		if i := slices.IndexFunc(patterns, func(p *regexp.Regexp) bool { return p.MatchString(title) }); i >= 0 {
+			skip("title matches " + conf.SkipTitles[i])
+			continue
+		}
+
+		if conf.DedupeISRC && len(t.ISRC) > 0 {
+			if id, seen := isrcs.ids[t.ISRC]; seen && id != t.ID {
+				skip("same ISRC as track " + id)
+				continue
+			}
+			isrcs.ids[t.ISRC] = t.ID
+		}
+
+		kept = append(kept, t)
+	}
+
+	return kept, skipped
+}
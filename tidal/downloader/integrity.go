@@ -0,0 +1,121 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+
+	"github.com/xeptore/tidalgram/ratelimit"
+)
+
+// responseMD5 returns the MD5 of the full content of a response with header, if the server advertises it.
+func responseMD5(header http.Header) ([]byte, bool) {
+	if v := header.Get("Content-MD5"); v != "" {
+		if sum, err := base64.StdEncoding.DecodeString(v); nil == err && len(sum) == md5.Size {
+			return sum, true
+		}
+	}
+
+	for _, v := range header.Values("X-Goog-Hash") {
+		for part := range strings.SplitSeq(v, ",") {
+			if encoded, ok := strings.CutPrefix(strings.TrimSpace(part), "md5="); ok {
+				if sum, err := base64.StdEncoding.DecodeString(encoded); nil == err && len(sum) == md5.Size {
+					return sum, true
+				}
+			}
+		}
+	}
+
+	// Entity tags of objects uploaded at once are their MD5, while multipart ones have a part count suffix,
+	// and weak ones are not tied to the content bytes.
+	if etag := header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+		if sum, err := hex.DecodeString(strings.Trim(etag, `"`)); nil == err && len(sum) == md5.Size {
+			return sum, true
+		}
+	}
+
+	return nil, false
+}
+
+// copyVerified copies the body of resp to w. It returns ErrCorruptedTrack if the copied content is shorter or
+// longer than the response content length, or, for full content responses, if it does not match the MD5
+// advertised by the server, if any.
+func copyVerified(ctx context.Context, w io.Writer, resp *http.Response, bandwidth *rate.Limiter) (int64, error) {
+	hash := md5.New() //nolint:gosec
+	n, err := io.Copy(io.MultiWriter(w, hash), ratelimit.Reader(ctx, resp.Body, bandwidth))
+	if nil != err {
+		return n, err
+	}
+
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return n, fmt.Errorf("%w: received %d bytes, expected %d", ErrCorruptedTrack, n, resp.ContentLength)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		if expected, ok := responseMD5(resp.Header); ok {
+			if actual := hash.Sum(nil); !bytes.Equal(actual, expected) {
+				return n, fmt.Errorf(
+					"%w: received content MD5 %x, expected %x",
+					ErrCorruptedTrack,
+					actual,
+					expected,
+				)
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// verifyTrack checks the integrity of the downloaded track file at path with extension ext. FLAC tracks are
+// fully decoded and checked against their embedded MD5, while the others are probed for a decodable audio
+// stream. It returns ErrCorruptedTrack if the track is corrupted.
+func verifyTrack(ctx context.Context, logger zerolog.Logger, path, ext string) error {
+	if ext == "flac" {
+		return verifyFLACTrack(ctx, logger, path)
+	}
+
+	args := []string{"-v", "error", "-select_streams", "a:0", "-show_entries", "stream=codec_name,duration", "-of", "json", path}
+
+	var stdOut, stdErr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running ffprobe sanity check")
+	if err := cmd.Run(); nil != err {
+		if nil != ctx.Err() {
+			return fmt.Errorf("run ffprobe: %w", ctx.Err())
+		}
+
+		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffprobe sanity check failed")
+
+		return fmt.Errorf("%w: ffprobe failed: %v: %s", ErrCorruptedTrack, err, stdErr.String())
+	}
+
+	var out struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(stdOut.Bytes(), &out); nil != err {
+		return fmt.Errorf("decode ffprobe output: %v", err)
+	}
+
+	if len(out.Streams) == 0 || out.Streams[0].CodecName == "" {
+		return fmt.Errorf("%w: no audio stream found", ErrCorruptedTrack)
+	}
+
+	return nil
+}
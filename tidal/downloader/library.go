@@ -0,0 +1,135 @@
+package downloader
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// maxNameLength is the maximum length of each library path segment, in bytes, which is below the file name
+// length limit of common file systems, including the extension.
+const maxNameLength = 200
+
+var unsafeNameChars = strings.NewReplacer(`/`, "_", `\`, "_", ":", "_", "*", "_", "?", "_", `"`, "_", "<", "_", ">", "_", "|", "_")
+
+// linkLibrary links the downloaded tracks of link to the library, named by the configured naming scheme. Tracks
+// are stored by their IDs regardless, hence failures are only logged.
+func (d *Downloader) linkLibrary(logger zerolog.Logger, link types.Link) {
+	if d.conf.Naming == "" {
+		return
+	}
+
+	trackIDs, err := d.dir.TrackIDs(link)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to get downloaded track ids to link to library")
+		return
+	}
+
+	library := d.dir.Library()
+	index, err := library.Read()
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to read library info file")
+		return
+	}
+
+	owners := make(map[string]string, len(index.Paths))
+	for trackID, relPath := range index.Paths {
+		owners[relPath] = trackID
+	}
+
+	for _, trackID := range trackIDs {
+		logger := logger.With().Str("track_id", trackID).Logger()
+
+		track := d.dir.TrackFile(trackID)
+		info, err := fs.InfoFile[types.StoredAlbumTrack]{Path: track.InfoFile.Path}.Read()
+		if nil != err {
+			logger.Error().Err(err).Msg("Failed to read track info file to link to library")
+			continue
+		}
+
+		relPath := trackLibraryPath(d.conf.Naming, trackID, *info)
+		if owner, ok := owners[relPath]; ok && owner != trackID {
+			// Different tracks, e.g., of different editions of an album, can be named the same.
+			relPath = strings.TrimSuffix(relPath, "."+info.Ext) + " [" + trackID + "]." + info.Ext
+		}
+		if err := library.Link(index, trackID, track.Path, relPath); nil != err {
+			logger.Error().Err(err).Str("library_path", relPath).Msg("Failed to link track to library")
+			continue
+		}
+		owners[relPath] = trackID
+	}
+
+	if err := library.InfoFile.Write(*index); nil != err {
+		logger.Error().Err(err).Msg("Failed to write library info file")
+		return
+	}
+	logger.Debug().Int("tracks", len(trackIDs)).Msg("Linked downloaded tracks to library")
+}
+
+// trackLibraryPath renders the library path of the track with id by scheme, replacing characters that are not
+// allowed in file names of common file systems in each of its path segments.
+func trackLibraryPath(scheme, id string, track types.StoredAlbumTrack) string {
+	artist := track.Metadata.AlbumArtist
+	if artist == "" {
+		artist = types.JoinArtists(track.Artists)
+	}
+	year, _, _ := strings.Cut(track.Metadata.ReleaseDate, "-")
+
+	replacer := strings.NewReplacer(
+		"{artist}", sanitizeName(artist),
+		"{album}", sanitizeName(track.Metadata.Album),
+		"{year}", sanitizeName(year),
+		"{disc}", strconv.Itoa(track.VolumeNumber),
+		"{track}", fmt.Sprintf("%02d", track.TrackNumber),
+		"{title}", sanitizeName(track.UploadTitle()),
+		"{ext}", track.Ext,
+		"{id}", id,
+	)
+
+	var (
+		segments = strings.Split(scheme, "/")
+		last     = len(segments) - 1
+	)
+	for i, segment := range segments[:last] {
+		segments[i] = sanitizeName(replacer.Replace(segment))
+	}
+	// Schemes end with the extension, which is kept when the file name is shortened.
+	segments[last] = sanitizeName(replacer.Replace(strings.TrimSuffix(segments[last], ".{ext}"))) + "." + track.Ext
+
+	return path.Join(segments...)
+}
+
+// sanitizeName returns name without path separators, characters not allowed in file names, and trailing dots
+// and spaces, shortened to maxNameLength.
+func sanitizeName(name string) string {
+	name = unsafeNameChars.Replace(name)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+
+		return r
+	}, name)
+
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+		for !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
+	}
+
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" {
+		return "_"
+	}
+
+	return name
+}
@@ -0,0 +1,107 @@
+package downloader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// TrackLyrics holds the lyrics of a track. Either of them can be empty, as not every track has synced lyrics.
+type TrackLyrics struct {
+	// Text is the plain lyrics text.
+	Text string
+	// Synced holds the time-synced lyrics lines, in order.
+	Synced []LyricsLine
+}
+
+// LyricsLine is a lyrics line that starts being sung At the given offset from the start of the track.
+type LyricsLine struct {
+	At   time.Duration
+	Text string
+}
+
+var lrcTimestampRegex = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+
+// parseSyncedLyrics parses LRC formatted lyrics, as returned in the subtitles field of the lyrics API.
+// Lines without a timestamp, e.g., ID tags such as [ar:...], are skipped. A line with multiple
+// timestamps is repeated at each of them.
+func parseSyncedLyrics(lrc string) []LyricsLine {
+	var out []LyricsLine
+	for line := range strings.Lines(lrc) {
+		line = strings.TrimSpace(line)
+
+		var ats []time.Duration
+		for {
+			m := lrcTimestampRegex.FindStringSubmatch(line)
+			if nil == m {
+				break
+			}
+			ats = append(ats, lrcTimestamp(m[1], m[2], m[3]))
+			line = line[len(m[0]):]
+		}
+
+		text := strings.TrimSpace(line)
+		for _, at := range ats {
+			out = append(out, LyricsLine{At: at, Text: text})
+		}
+	}
+
+	return out
+}
+
+func lrcTimestamp(minutes, seconds, fraction string) time.Duration {
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.Atoi(seconds)
+	out := time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if len(fraction) > 0 {
+		// Fractions are hundredths of a second in LRC, but some files use tenths or milliseconds.
+		f, _ := strconv.Atoi(fraction + strings.Repeat("0", 3-len(fraction)))
+		out += time.Duration(f) * time.Millisecond
+	}
+
+	return out
+}
+
+// writeLyricsFile writes the synced lyrics of the track described by attrs to file, if it has any.
+func writeLyricsFile(file fs.Lyrics, attrs TrackEmbeddedAttrs) error {
+	if len(attrs.Lyrics.Synced) == 0 {
+		return nil
+	}
+
+	return file.Write(attrs.Lyrics.lrc(attrs))
+}
+
+// plainText returns the plain lyrics text, falling back to the text of the synced lines if there is none.
+func (l TrackLyrics) plainText() string {
+	if len(l.Text) > 0 || len(l.Synced) == 0 {
+		return l.Text
+	}
+
+	lines := make([]string, len(l.Synced))
+	for i, line := range l.Synced {
+		lines[i] = line.Text
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// lrc formats the synced lyrics of the track described by attrs as an LRC file.
+func (l TrackLyrics) lrc(attrs TrackEmbeddedAttrs) string {
+	var sb strings.Builder
+	sb.WriteString("[ar:" + types.JoinArtists(attrs.Artists) + "]\n")
+	sb.WriteString("[ti:" + attrs.Title + "]\n")
+	sb.WriteString("[al:" + attrs.Album + "]\n")
+	for _, line := range l.Synced {
+		minutes := int(line.At / time.Minute)
+		seconds := int(line.At % time.Minute / time.Second)
+		hundredths := int(line.At % time.Second / (10 * time.Millisecond))
+		fmt.Fprintf(&sb, "[%02d:%02d.%02d]%s\n", minutes, seconds, hundredths, line.Text)
+	}
+
+	return sb.String()
+}
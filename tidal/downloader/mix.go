@@ -9,46 +9,55 @@ import (
 	"net/url"
 	"os"
 	"strconv"
-	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
-	"github.com/samber/lo"
 	"github.com/tidwall/gjson"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/xeptore/tidalgram/httputil"
 	"github.com/xeptore/tidalgram/tidal/auth"
+	"github.com/xeptore/tidalgram/tidal/fs"
 	"github.com/xeptore/tidalgram/tidal/types"
 )
 
-func (d *Downloader) mix(ctx context.Context, logger zerolog.Logger, id string) error {
+func (d *Downloader) mix(ctx context.Context, logger zerolog.Logger, id string, skipIDs []string) error {
 	creds := d.auth.Credentials()
-	mix, err := d.getMixMeta(ctx, logger, creds.Token, creds.CountryCode, id)
+	mix, err := d.getMixMeta(ctx, logger, creds.Token, d.countryCode(creds), id)
 	if nil != err {
-		return fmt.Errorf("get mix meta: %w", err)
+		return newStageError(StageMetadata, "", fmt.Errorf("get mix meta: %w", err))
 	}
 
-	tracks, err := d.getMixTracks(ctx, logger, creds.Token, creds.CountryCode, id)
+	tracks, err := d.getMixTracks(ctx, logger, creds.Token, d.countryCode(creds), id)
 	if nil != err {
-		return fmt.Errorf("get mix tracks: %w", err)
+		return newStageError(StageMetadata, "", fmt.Errorf("get mix tracks: %w", err))
 	}
+	tracks, filtered := d.filterTracks(ctx, withoutTracks(tracks, skipIDs))
+	tracks, trackIDs, duplicateIDs := d.applyDuplicatesPolicy(tracks)
+	d.prefetchTrackCredits(ctx, logger, creds.Token, d.countryCode(creds), tracks)
 
 	var (
 		mixFs     = d.dir.Mix(id)
 		wg, wgctx = errgroup.WithContext(ctx)
+		failed    = newFailedTracks(d.conf.FailedTracks)
 	)
 
 	wg.SetLimit(d.conf.Concurrency.MixTracks)
 
 	for i, track := range tracks {
-		wg.Go(func() (err error) {
+		wg.Go(failed.guard(wgctx, logger, func() (err error) {
 			select {
 			case <-wgctx.Done():
-				return nil
+				return wgctx.Err()
 			default:
 			}
 
+			release, err := d.warmup.Acquire(wgctx)
+			if nil != err {
+				return fmt.Errorf("wait for warmup: %w", err)
+			}
+			defer release()
+
 			logger := logger.With().Int("track_index", i).Str("track_id", track.ID).Logger()
 
 			trackFs := mixFs.Track(track.ID)
@@ -56,15 +65,15 @@ func (d *Downloader) mix(ctx context.Context, logger zerolog.Logger, id string)
 			if exists, err := trackFs.Cover.AlreadyDownloaded(); nil != err {
 				logger.Error().Err(err).Msg("Failed to check if track cover exists")
 				return fmt.Errorf("check if track cover exists: %v", err)
-			} else if !exists {
+			} else if !exists && !d.copyCachedCover(logger, track.CoverID, trackFs.Cover) {
 				coverBytes, err := d.getCover(wgctx, logger, creds.Token, track.CoverID)
 				if nil != err {
-					return fmt.Errorf("get track cover: %w", err)
+					return newStageError(StageMetadata, track.ID, fmt.Errorf("get track cover: %w", err))
 				}
 
-				if err := trackFs.Cover.Write(coverBytes); nil != err {
+				if err := d.writeCover(trackFs.Cover, track.CoverID, coverBytes); nil != err {
 					logger.Error().Err(err).Msg("Failed to write track cover")
-					return fmt.Errorf("write track cover: %v", err)
+					return newStageError(StageMetadata, track.ID, fmt.Errorf("write track cover: %v", err))
 				}
 			}
 
@@ -85,24 +94,24 @@ func (d *Downloader) mix(ctx context.Context, logger zerolog.Logger, id string)
 				}
 			}()
 
-			ext, err := d.downloadTrack(wgctx, logger, creds.Token, track.ID, trackFs.Path)
+			ext, skippedAudioMode, err := d.downloadTrack(wgctx, logger, creds.Token, track.ID, trackFs.Path)
 			if nil != err {
-				return fmt.Errorf("download track: %w", err)
+				return newStageError(StageDownload, track.ID, fmt.Errorf("download track: %w", err))
 			}
 
-			trackCredits, err := d.getTrackCredits(wgctx, logger, creds.Token, creds.CountryCode, track.ID)
+			trackCredits, err := d.getTrackCredits(wgctx, logger, creds.Token, d.countryCode(creds), track.ID)
 			if nil != err {
-				return fmt.Errorf("get track credits: %w", err)
+				return newStageError(StageMetadata, track.ID, fmt.Errorf("get track credits: %w", err))
 			}
 
-			trackLyrics, err := d.downloadTrackLyrics(wgctx, logger, creds.Token, creds.CountryCode, track.ID)
+			trackLyrics, err := d.downloadTrackLyrics(wgctx, logger, creds.Token, d.countryCode(creds), track.ID)
 			if nil != err {
-				return fmt.Errorf("download track lyrics: %w", err)
+				return newStageError(StageMetadata, track.ID, fmt.Errorf("download track lyrics: %w", err))
 			}
 
-			album, err := d.getAlbumMeta(wgctx, logger, creds.Token, creds.CountryCode, track.AlbumID)
+			album, err := d.getAlbumMeta(wgctx, logger, creds.Token, d.countryCode(creds), track.AlbumID)
 			if nil != err {
-				return fmt.Errorf("get album meta: %w", err)
+				return newStageError(StageMetadata, track.ID, fmt.Errorf("get album meta: %w", err))
 			}
 
 			attrs := TrackEmbeddedAttrs{
@@ -121,25 +130,53 @@ func (d *Downloader) mix(ctx context.Context, logger zerolog.Logger, id string)
 				VolumeNumber: track.VolumeNumber,
 				TotalVolumes: album.TotalVolumes,
 				Credits:      *trackCredits,
-				Lyrics:       trackLyrics,
+				Lyrics:       *trackLyrics,
 				Ext:          ext,
 			}
-			if err := embedTrackAttributes(wgctx, logger, trackFs.Path, attrs); nil != err {
-				return fmt.Errorf("embed track attributes: %w", err)
+			if err := d.checkMetadata(wgctx, track.ID, track.CoverID, attrs); nil != err {
+				return err
+			}
+			if err := d.embedTrackAttributes(wgctx, logger, trackFs.Path, attrs); nil != err {
+				return newStageError(StageTagging, track.ID, fmt.Errorf("embed track attributes: %w", err))
+			}
+			if err := verifyTrackTags(wgctx, logger, track.ID, trackFs.Path, attrs); nil != err {
+				return err
+			}
+
+			if d.conf.ReplayGain {
+				rgTrack := replayGainTrack{ID: track.ID, Path: trackFs.Path, Ext: ext, Duration: track.Duration}
+				if err := applyTrackReplayGain(wgctx, logger, rgTrack); nil != err {
+					return newStageError(StageTagging, track.ID, fmt.Errorf("apply replay gain: %w", err))
+				}
+			}
+
+			if err := writeLyricsFile(trackFs.Lyrics, attrs); nil != err {
+				logger.Error().Err(err).Msg("Failed to write track lyrics file")
+				return fmt.Errorf("write track lyrics file: %v", err)
+			}
+
+			size, sum, err := fs.FileChecksum(trackFs.Path)
+			if nil != err {
+				logger.Error().Err(err).Msg("Failed to get track file checksum")
+				return fmt.Errorf("get track file checksum: %v", err)
 			}
 
 			info := types.StoredTrack{
 				Track: types.Track{
-					Artists:      track.Artists,
-					Title:        track.Title,
-					TrackNumber:  track.TrackNumber,
-					VolumeNumber: track.VolumeNumber,
-					Duration:     track.Duration,
-					Version:      track.Version,
-					CoverID:      track.CoverID,
-					Ext:          ext,
+					Artists:          track.Artists,
+					Title:            track.Title,
+					TrackNumber:      track.TrackNumber,
+					VolumeNumber:     track.VolumeNumber,
+					Duration:         track.Duration,
+					Version:          track.Version,
+					CoverID:          track.CoverID,
+					Ext:              ext,
+					SkippedAudioMode: skippedAudioMode,
+					Size:             size,
+					SHA256:           sum,
 				},
-				Caption: trackCaption(album.Title, album.ReleaseDate),
+				Album:    album.Stored(),
+				Metadata: attrs.metadata(track.ID, track.AlbumID, track.Duration),
 			}
 			if err := trackFs.InfoFile.Write(info); nil != err {
 				logger.Error().Err(err).Msg("Failed to write track info")
@@ -147,7 +184,7 @@ func (d *Downloader) mix(ctx context.Context, logger zerolog.Logger, id string)
 			}
 
 			return nil
-		})
+		}))
 	}
 
 	if err := wg.Wait(); nil != err {
@@ -155,8 +192,11 @@ func (d *Downloader) mix(ctx context.Context, logger zerolog.Logger, id string)
 	}
 
 	info := types.StoredMix{
-		Caption:  mix.Title,
-		TrackIDs: lo.Map(tracks, func(t ListTrackMeta, _ int) string { return t.ID }),
+		Caption:           mix.Title,
+		TrackIDs:          failed.without(trackIDs),
+		DuplicateTrackIDs: duplicateIDs,
+		FailedTracks:      failed.list(),
+		FilteredTracks:    filtered,
 	}
 	if err := mixFs.InfoFile.Write(info); nil != err {
 		logger.Error().Err(err).Msg("Failed to write mix info")
@@ -200,10 +240,7 @@ func (d *Downloader) getMixMeta(
 	)
 	req.Header.Add("Accept", "application/json")
 
-	client := http.Client{ //nolint:exhaustruct
-		Timeout: time.Duration(d.conf.Timeouts.GetMixInfo) * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.mixInfo)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get mix info request")
 		return nil, fmt.Errorf("send get mix info request: %w", err)
@@ -242,7 +279,7 @@ func (d *Downloader) getMixMeta(
 
 		return nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return nil, ErrTooManyRequests
+		return nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -253,7 +290,7 @@ func (d *Downloader) getMixMeta(
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
 			return nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return nil, ErrTooManyRequests
+			return nil, newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+)
+
+// saveViaLocalDir downloads stream into a local temporary directory, and copies the complete track file to
+// fileName. Network filesystems do not handle the synchronous chunk writes, and the renames, of stream
+// downloads well, so they only get a single sequential write, synced once at the end.
+func saveViaLocalDir(
+	ctx context.Context,
+	logger zerolog.Logger,
+	stream Stream,
+	accessToken string,
+	fileName string,
+) (err error) {
+	tmpDir, err := os.MkdirTemp("", "tidalgram-")
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to create local temporary directory")
+		return fmt.Errorf("create local temporary directory: %v", err)
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(tmpDir); nil != removeErr {
+			logger.Error().Err(removeErr).Str("dir", tmpDir).Msg("Failed to remove local temporary directory")
+		}
+	}()
+
+	tmpFileName := filepath.Join(tmpDir, filepath.Base(fileName))
+	if err := stream.saveTo(ctx, logger, accessToken, tmpFileName); nil != err {
+		return err
+	}
+
+	if err := copyTrackFile(tmpFileName, fileName); nil != err {
+		logger.Error().Err(err).Msg("Failed to copy track file to downloads directory")
+		return fmt.Errorf("copy track file to downloads directory: %v", err)
+	}
+
+	return nil
+}
+
+func copyTrackFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if nil != err {
+		return fmt.Errorf("open source file: %v", err)
+	}
+	defer func() {
+		if closeErr := in.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close source file: %v", closeErr))
+		}
+	}()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o0600)
+	if nil != err {
+		return fmt.Errorf("create destination file: %v", err)
+	}
+	defer func() {
+		if closeErr := out.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close destination file: %v", closeErr))
+		}
+		if nil != err {
+			if removeErr := os.Remove(dst); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+				err = errors.Join(err, fmt.Errorf("remove incomplete destination file: %v", removeErr))
+			}
+		}
+	}()
+
+	if _, err := io.Copy(out, in); nil != err {
+		return fmt.Errorf("copy file content: %v", err)
+	}
+
+	if err := out.Sync(); nil != err {
+		return fmt.Errorf("sync destination file: %v", err)
+	}
+
+	return nil
+}
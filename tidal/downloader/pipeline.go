@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"context"
+
+	"github.com/xeptore/tidalgram/pipeline"
+)
+
+type pipelineKey struct{}
+
+// WithPipeline returns a copy of ctx under which the album layout and its downloaded tracks are reported to
+// album, so that they are uploaded while the rest of the album is still being downloaded.
+func WithPipeline(ctx context.Context, album *pipeline.Album) context.Context {
+	return context.WithValue(ctx, pipelineKey{}, album)
+}
+
+// pipelineFrom returns the album pipeline of ctx, or nil if it has none.
+func pipelineFrom(ctx context.Context) *pipeline.Album {
+	album, _ := ctx.Value(pipelineKey{}).(*pipeline.Album)
+	return album
+}
+
+type trackStreamKey struct{}
+
+// WithTrackStream returns a copy of ctx under which the tagged file of a downloaded single track is streamed to
+// track while it is being written, so that it is uploaded without waiting for it to be written.
+func WithTrackStream(ctx context.Context, track *pipeline.Track) context.Context {
+	return context.WithValue(ctx, trackStreamKey{}, track)
+}
+
+// trackStreamFrom returns the track stream of ctx, or nil if it has none.
+func trackStreamFrom(ctx context.Context) *pipeline.Track {
+	track, _ := ctx.Value(trackStreamKey{}).(*pipeline.Track)
+	return track
+}
@@ -0,0 +1,124 @@
+package downloader
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const (
+	// playbackInfoTTL bounds how long a fetched track stream is reused, even if its URLs expire later.
+	playbackInfoTTL = 10 * time.Minute
+	// playbackInfoExpiryMargin is subtracted from stream URLs expiry, so that reused URLs do not expire mid-download.
+	playbackInfoExpiryMargin = 2 * time.Minute
+)
+
+type playbackInfo struct {
+	stream           Stream
+	ext              string
+	skippedAudioMode string
+	expiresAt        time.Time
+}
+
+// playbackInfoCache keeps the fetched track streams of the running job, so that retries after transient
+// download failures do not fetch playback info again.
+type playbackInfoCache struct {
+	mu      sync.Mutex
+	entries map[string]playbackInfo
+}
+
+func newPlaybackInfoCache() *playbackInfoCache {
+	return &playbackInfoCache{
+		mu:      sync.Mutex{},
+		entries: make(map[string]playbackInfo),
+	}
+}
+
+func playbackInfoKey(id string) string {
+	return id + "/" + types.TrackQuality
+}
+
+func (c *playbackInfoCache) get(id string) (Stream, string, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := playbackInfoKey(id)
+	info, ok := c.entries[key]
+	if !ok {
+		return nil, "", "", false
+	}
+
+	if !time.Now().Before(info.expiresAt) {
+		delete(c.entries, key)
+		return nil, "", "", false
+	}
+
+	return info.stream, info.ext, info.skippedAudioMode, true
+}
+
+func (c *playbackInfoCache) set(id string, stream Stream, ext, skippedAudioMode string) {
+	expiresAt := time.Now().Add(playbackInfoTTL)
+	if urlExpiresAt, ok := streamExpiry(stream); ok {
+		if urlExpiresAt = urlExpiresAt.Add(-playbackInfoExpiryMargin); urlExpiresAt.Before(expiresAt) {
+			expiresAt = urlExpiresAt
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[playbackInfoKey(id)] = playbackInfo{
+		stream:           stream,
+		ext:              ext,
+		skippedAudioMode: skippedAudioMode,
+		expiresAt:        expiresAt,
+	}
+}
+
+func (c *playbackInfoCache) delete(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, playbackInfoKey(id))
+}
+
+func (c *playbackInfoCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
+
+func streamExpiry(stream Stream) (time.Time, bool) {
+	switch s := stream.(type) {
+	case *VndTrackStream:
+		return urlExpiry(s.URL)
+	case *DashTrackStream:
+		return urlExpiry(s.Info.Parts.InitializationURLTemplate)
+	default:
+		return time.Time{}, false
+	}
+}
+
+// urlExpiry returns the expiry time of a signed CDN link, read from its Expires query parameter in Unix seconds.
+func urlExpiry(link string) (time.Time, bool) {
+	u, err := url.Parse(link)
+	if nil != err {
+		return time.Time{}, false
+	}
+
+	v := u.Query().Get("Expires")
+	if v == "" {
+		return time.Time{}, false
+	}
+
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if nil != err {
+		return time.Time{}, false
+	}
+
+	return time.Unix(sec, 0), true
+}
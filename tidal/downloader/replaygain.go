@@ -0,0 +1,212 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+)
+
+// replayGainReference is the ReplayGain 2.0 reference loudness, in LUFS.
+const replayGainReference = -18.0
+
+var (
+	ebur128IntegratedRegex = regexp.MustCompile(`I:\s+(-?[0-9.]+|-inf) LUFS`)
+	ebur128PeakRegex       = regexp.MustCompile(`Peak:\s+(-?[0-9.]+|-inf) dBFS`)
+)
+
+// loudness is the result of the EBU R128 loudness analysis of a track.
+type loudness struct {
+	// Integrated is the integrated loudness, in LUFS. It is -Inf for silent tracks.
+	Integrated float64
+	// Peak is the true peak, in dBFS.
+	Peak float64
+}
+
+// replayGainTrack is a downloaded track to tag with ReplayGain tags.
+type replayGainTrack struct {
+	ID   string
+	Path string
+	Ext  string
+	// Duration is the track duration, in seconds, used to weight its loudness in the album loudness.
+	Duration int
+}
+
+// analyzeLoudness measures the loudness of the track file at path using the ffmpeg ebur128 filter.
+func analyzeLoudness(ctx context.Context, logger zerolog.Logger, path string) (*loudness, error) {
+	args := []string{"-hide_banner", "-nostats", "-i", path, "-map", "0:a", "-filter:a", "ebur128=peak=true", "-f", "null", "-"}
+	cmd := ffmpegCommand(ctx, args...)
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running ffmpeg loudness analysis")
+	if err := cmd.Run(); nil != err {
+		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffmpeg loudness analysis failed")
+		return nil, &FFmpegError{Args: args, Stderr: stdErr.String(), Probe: probeTrack(ctx, path), Err: err}
+	}
+
+	// Summary is printed after the per frame measurements, so the last matches are the summary values.
+	integrated := ebur128IntegratedRegex.FindAllStringSubmatch(stdErr.String(), -1)
+	peak := ebur128PeakRegex.FindAllStringSubmatch(stdErr.String(), -1)
+	if len(integrated) == 0 || len(peak) == 0 {
+		return nil, fmt.Errorf("unexpected ffmpeg loudness analysis output: %s", stdErr.String())
+	}
+
+	out := &loudness{Integrated: 0, Peak: 0}
+	var err error
+	if out.Integrated, err = parseLoudnessValue(integrated[len(integrated)-1][1]); nil != err {
+		return nil, fmt.Errorf("parse integrated loudness: %v", err)
+	}
+	if out.Peak, err = parseLoudnessValue(peak[len(peak)-1][1]); nil != err {
+		return nil, fmt.Errorf("parse true peak: %v", err)
+	}
+
+	return out, nil
+}
+
+func parseLoudnessValue(s string) (float64, error) {
+	if s == "-inf" {
+		return math.Inf(-1), nil
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+// albumLoudness approximates the integrated loudness of the album consisting of tracks, by averaging the
+// track loudness values in the energy domain, weighted by the track durations. Its peak is the highest
+// track peak.
+func albumLoudness(tracks []replayGainTrack, values []loudness) loudness {
+	var (
+		energy   float64
+		duration float64
+		peak     = math.Inf(-1)
+	)
+	for i, v := range values {
+		peak = max(peak, v.Peak)
+		if math.IsInf(v.Integrated, -1) {
+			continue
+		}
+
+		d := float64(max(tracks[i].Duration, 1))
+		energy += d * math.Pow(10, v.Integrated/10)
+		duration += d
+	}
+
+	if duration == 0 {
+		return loudness{Integrated: math.Inf(-1), Peak: peak}
+	}
+
+	return loudness{Integrated: 10 * math.Log10(energy/duration), Peak: peak}
+}
+
+// replayGainTags returns the ReplayGain tags with the given prefix, e.g., REPLAYGAIN_TRACK, for l.
+// Silent tracks get no gain.
+func replayGainTags(prefix string, l loudness) []string {
+	gain := 0.0
+	if !math.IsInf(l.Integrated, -1) {
+		gain = replayGainReference - l.Integrated
+	}
+
+	return []string{
+		prefix + "_GAIN=" + strconv.FormatFloat(gain, 'f', 2, 64) + " dB",
+		prefix + "_PEAK=" + strconv.FormatFloat(math.Pow(10, l.Peak/20), 'f', 6, 64),
+	}
+}
+
+// writeReplayGainTags adds tags to the track file at path, keeping the rest of its streams and tags as is.
+func writeReplayGainTags(ctx context.Context, logger zerolog.Logger, path, ext string, tags []string) error {
+	outPath := path + "." + ext
+
+	args := []string{"-i", path, "-map", "0", "-c", "copy"}
+	for _, tag := range tags {
+		args = append(args, "-metadata", tag)
+	}
+	if ext != "flac" {
+		// MP4 muxer drops tags it does not know unless it is asked to write them as custom tags.
+		args = append(args, "-movflags", "use_metadata_tags")
+	}
+	args = append(args, outPath)
+
+	cmd := ffmpegCommand(ctx, args...)
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running ffmpeg to write ReplayGain tags")
+	if err := cmd.Run(); nil != err {
+		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffmpeg failed to write ReplayGain tags")
+		if removeErr := os.Remove(outPath); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+			logger.Error().Err(removeErr).Msg("Failed to remove partially written track file")
+		}
+		return &FFmpegError{Args: args, Stderr: stdErr.String(), Probe: probeTrack(ctx, path), Err: err}
+	}
+
+	if err := os.Rename(outPath, path); nil != err {
+		logger.Error().Err(err).Msg("Failed to rename track file")
+		return fmt.Errorf("rename track file: %v", err)
+	}
+
+	return nil
+}
+
+// applyTrackReplayGain writes the ReplayGain track tags of a track that is not downloaded as part of an album.
+func applyTrackReplayGain(ctx context.Context, logger zerolog.Logger, track replayGainTrack) error {
+	l, err := analyzeLoudness(ctx, logger, track.Path)
+	if nil != err {
+		return fmt.Errorf("analyze track loudness: %w", err)
+	}
+
+	if err := writeReplayGainTags(ctx, logger, track.Path, track.Ext, replayGainTags("REPLAYGAIN_TRACK", *l)); nil != err {
+		return fmt.Errorf("write track replay gain tags: %w", err)
+	}
+
+	return nil
+}
+
+// applyAlbumReplayGain writes the ReplayGain track and album tags of all album tracks. The album gain is
+// computed once from the loudness of all tracks.
+func (d *Downloader) applyAlbumReplayGain(ctx context.Context, logger zerolog.Logger, tracks []replayGainTrack) error {
+	values := make([]loudness, len(tracks))
+
+	wg, wgctx := errgroup.WithContext(ctx)
+	wg.SetLimit(d.conf.Concurrency.AlbumTracks)
+	for i, track := range tracks {
+		wg.Go(func() error {
+			l, err := analyzeLoudness(wgctx, logger, track.Path)
+			if nil != err {
+				return newStageError(StageTagging, track.ID, fmt.Errorf("analyze track loudness: %w", err))
+			}
+			values[i] = *l
+
+			return nil
+		})
+	}
+	if err := wg.Wait(); nil != err {
+		return err
+	}
+
+	albumTags := replayGainTags("REPLAYGAIN_ALBUM", albumLoudness(tracks, values))
+
+	wg, wgctx = errgroup.WithContext(ctx)
+	wg.SetLimit(d.conf.Concurrency.AlbumTracks)
+	for i, track := range tracks {
+		wg.Go(func() error {
+			tags := append(replayGainTags("REPLAYGAIN_TRACK", values[i]), albumTags...)
+			if err := writeReplayGainTags(wgctx, logger, track.Path, track.Ext, tags); nil != err {
+				return newStageError(StageTagging, track.ID, fmt.Errorf("write track replay gain tags: %w", err))
+			}
+
+			return nil
+		})
+	}
+
+	return wg.Wait()
+}
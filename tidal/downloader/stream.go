@@ -9,56 +9,109 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
 
 	"github.com/xeptore/tidalgram/httputil"
+	"github.com/xeptore/tidalgram/ratelimit"
 	"github.com/xeptore/tidalgram/tidal/auth"
 	"github.com/xeptore/tidalgram/tidal/mpd"
 	"github.com/xeptore/tidalgram/tidal/types"
 )
 
+const (
+	audioModeDolbyAtmos = "DOLBY_ATMOS"
+	audioModeSony360RA  = "SONY_360RA"
+	// stereoFallbackQuality is requested for tracks served in spatial audio, as spatial audio is only served
+	// for the hi-res quality.
+	stereoFallbackQuality = "LOSSLESS"
+)
+
+// SpatialAudioOnlyError reports a track that is only available in a spatial audio mode, which can not be
+// downloaded.
+type SpatialAudioOnlyError struct {
+	TrackID   string
+	AudioMode string
+}
+
+func (e *SpatialAudioOnlyError) Error() string {
+	return fmt.Sprintf("track %s is only available in %s audio mode", e.TrackID, e.AudioMode)
+}
+
+func isSpatialAudioMode(mode string) bool {
+	return mode == audioModeDolbyAtmos || mode == audioModeSony360RA
+}
+
 type Stream interface {
 	saveTo(ctx context.Context, logger zerolog.Logger, accessToken string, fileName string) error
 }
 
+// getStream returns the stream of the track with id. If the track is served in a spatial audio mode, e.g.,
+// Dolby Atmos, its stereo stream is requested instead, and the skipped spatial audio mode is returned.
 func (d *Downloader) getStream(
 	ctx context.Context,
 	logger zerolog.Logger,
 	id string,
-) (s Stream, ext string, err error) {
+) (s Stream, ext string, skippedAudioMode string, err error) {
+	s, ext, audioMode, err := d.requestStream(ctx, logger, id, types.TrackQuality)
+	if nil != err {
+		return nil, "", "", err
+	}
+
+	if nil != s {
+		return s, ext, "", nil
+	}
+
+	logger.Info().Str("audio_mode", audioMode).Msg("Track is served in spatial audio. Requesting its stereo stream")
+
+	s, ext, fallbackAudioMode, err := d.requestStream(ctx, logger, id, stereoFallbackQuality)
+	if nil != err {
+		return nil, "", "", fmt.Errorf("get stereo stream: %w", err)
+	}
+
+	if nil == s {
+		return nil, "", "", &SpatialAudioOnlyError{TrackID: id, AudioMode: fallbackAudioMode}
+	}
+
+	return s, ext, audioMode, nil
+}
+
+// requestStream returns the stream of the track with id in quality, and its audio mode. Streams of spatial
+// audio modes are not returned, as they can not be downloaded.
+func (d *Downloader) requestStream(
+	ctx context.Context,
+	logger zerolog.Logger,
+	id string,
+	quality string,
+) (s Stream, ext string, audioMode string, err error) {
 	reqURL, err := url.Parse(d.conf.HifiAPI)
 	if nil != err {
-		return nil, "", fmt.Errorf("parse Hi-Fi API URL: %v", err)
+		return nil, "", "", fmt.Errorf("parse Hi-Fi API URL: %v", err)
 	}
 	path, err := url.JoinPath(reqURL.Path, "track")
 	if nil != err {
-		return nil, "", fmt.Errorf("join Hi-Fi API URL with track path: %v", err)
+		return nil, "", "", fmt.Errorf("join Hi-Fi API URL with track path: %v", err)
 	}
 	reqURL.Path = path
 
 	reqParams := make(url.Values, 2)
 	reqParams.Add("id", id)
-	reqParams.Add("quality", "HI_RES_LOSSLESS")
+	reqParams.Add("quality", quality)
 	reqURL.RawQuery = reqParams.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	req, err := http.NewRequestWithContext(ratelimit.WithClass(ctx, ratelimit.ClassStream), http.MethodGet, reqURL.String(), nil)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to create get track stream URLs request")
-		return nil, "", fmt.Errorf("create get track stream URLs request: %v", err)
+		return nil, "", "", fmt.Errorf("create get track stream URLs request: %v", err)
 	}
 
 	req.Header.Add("Accept", "application/json")
 
-	client := http.Client{ //nolint:exhaustruct
-		Timeout: time.Duration(d.conf.Timeouts.GetStreamURLs) * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.streamURLs)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get track stream URLs request")
-		return nil, "", fmt.Errorf("send get stream URLs request: %w", err)
+		return nil, "", "", fmt.Errorf("send get stream URLs request: %w", err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); nil != closeErr {
@@ -73,72 +126,77 @@ func (d *Downloader) getStream(
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Msg("Failed to read 401 response body")
-			return nil, "", fmt.Errorf("read 401 response body: %w", err)
+			return nil, "", "", fmt.Errorf("read 401 response body: %w", err)
 		}
 
 		if ok, err := httputil.IsTokenExpiredResponse(respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 401 response is token expired")
-			return nil, "", fmt.Errorf("check if 401 response is token expired: %v", err)
+			return nil, "", "", fmt.Errorf("check if 401 response is token expired: %v", err)
 		} else if ok {
-			return nil, "", auth.ErrUnauthorized
+			return nil, "", "", auth.ErrUnauthorized
 		}
 
 		if ok, err := httputil.IsTokenInvalidResponse(respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 401 response is token invalid")
-			return nil, "", fmt.Errorf("check if 401 response is token invalid: %v", err)
+			return nil, "", "", fmt.Errorf("check if 401 response is token invalid: %v", err)
 		} else if ok {
-			return nil, "", auth.ErrUnauthorized
+			return nil, "", "", auth.ErrUnauthorized
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 401 response")
 
-		return nil, "", fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
+		return nil, "", "", fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return nil, "", ErrTooManyRequests
+		return nil, "", "", readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Msg("Failed to read 403 response body")
-			return nil, "", fmt.Errorf("read 403 response body: %w", err)
+			return nil, "", "", fmt.Errorf("read 403 response body: %w", err)
 		}
 
 		if ok, err := httputil.IsTooManyErrorResponse(resp, respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
-			return nil, "", fmt.Errorf("check if 403 response is too many requests: %v", err)
+			return nil, "", "", fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return nil, "", ErrTooManyRequests
+			return nil, "", "", newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
 
-		return nil, "", fmt.Errorf("unexpected 403 response with body: %s", string(respBytes))
+		return nil, "", "", fmt.Errorf("unexpected 403 response with body: %s", string(respBytes))
 	default:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Int("status_code", code).Msg("Failed to read response body")
-			return nil, "", fmt.Errorf("read response body: %w", err)
+			return nil, "", "", fmt.Errorf("read response body: %w", err)
 		}
 
 		logger.Error().Int("status_code", code).Bytes("response_body", respBytes).Msg("Unexpected response status code")
 
-		return nil, "", fmt.Errorf("unexpected response code %d with body: %s", code, string(respBytes))
+		return nil, "", "", fmt.Errorf("unexpected response code %d with body: %s", code, string(respBytes))
 	}
 
 	respBytes, err := io.ReadAll(resp.Body)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to read 200 response body")
-		return nil, "", fmt.Errorf("read 200 response body: %w", err)
+		return nil, "", "", fmt.Errorf("read 200 response body: %w", err)
 	}
 
 	var respBody struct {
 		Data struct {
+			AudioMode        string `json:"audioMode"`
 			ManifestMimeType string `json:"manifestMimeType"`
 			Manifest         string `json:"manifest"`
 		} `json:"data"`
 	}
 	if err := json.Unmarshal(respBytes, &respBody); nil != err {
 		logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to decode 200 response body")
-		return nil, "", fmt.Errorf("decode 200 response body: %w", err)
+		return nil, "", "", fmt.Errorf("decode 200 response body: %w", err)
+	}
+
+	if audioMode := respBody.Data.AudioMode; isSpatialAudioMode(audioMode) {
+		return nil, "", audioMode, nil
 	}
 
 	switch mimeType := respBody.Data.ManifestMimeType; mimeType {
@@ -147,7 +205,7 @@ func (d *Downloader) getStream(
 		info, err := mpd.ParseStreamInfo(dec)
 		if nil != err {
 			logger.Error().Err(err).Str("manifest", respBody.Data.Manifest).Msg("Failed to parse stream info")
-			return nil, "", fmt.Errorf("parse stream info: %v", err)
+			return nil, "", "", fmt.Errorf("parse stream info: %v", err)
 		}
 
 		ext, err := types.InferTrackExt(info.MimeType, info.Codec)
@@ -159,32 +217,36 @@ func (d *Downloader) getStream(
 				Str("codec", info.Codec).
 				Msg("Failed to infer track extension")
 
-			return nil, "", fmt.Errorf("infer track extension: %v", err)
+			return nil, "", "", fmt.Errorf("infer track extension: %v", err)
 		}
 
 		return &DashTrackStream{
-			Info:            *info,
-			DownloadTimeout: time.Duration(d.conf.Timeouts.DownloadDashSegment) * time.Second,
-		}, ext, nil
+			Info:         *info,
+			Client:       d.client,
+			Timeout:      d.timeouts.dashSegment,
+			CacheBaseURL: d.conf.CDNCacheURL,
+			Bandwidth:    d.bandwidth,
+			Gate:         d.gate,
+		}, ext, respBody.Data.AudioMode, nil
 	case "application/vnd.tidal.bts", "vnd.tidal.bt":
 		var manifest VNDManifest
 		dec := base64.NewDecoder(base64.StdEncoding, strings.NewReader(respBody.Data.Manifest))
 		if err := json.NewDecoder(dec).Decode(&manifest); nil != err {
 			logger.Error().Err(err).Str("manifest", respBody.Data.Manifest).Msg("Failed to decode vnd.tidal.bt manifest")
-			return nil, "", fmt.Errorf("decode vnd.tidal.bt manifest: %v", err)
+			return nil, "", "", fmt.Errorf("decode vnd.tidal.bt manifest: %v", err)
 		}
 
 		switch manifest.EncryptionType {
 		case "NONE":
 		default:
-			return nil, "", fmt.Errorf(
+			return nil, "", "", fmt.Errorf(
 				"encrypted vnd.tidal.bt manifest is not yet implemented: %s",
 				manifest.EncryptionType,
 			)
 		}
 
 		if len(manifest.URLs) == 0 {
-			return nil, "", errors.New("empty vnd.tidal.bt manifest URLs")
+			return nil, "", "", errors.New("empty vnd.tidal.bt manifest URLs")
 		}
 
 		ext, err := types.InferTrackExt(manifest.MimeType, manifest.Codec)
@@ -196,16 +258,20 @@ func (d *Downloader) getStream(
 				Str("codec", manifest.Codec).
 				Msg("Failed to infer track extension")
 
-			return nil, "", fmt.Errorf("infer track extension: %v", err)
+			return nil, "", "", fmt.Errorf("infer track extension: %v", err)
 		}
 
 		return &VndTrackStream{
 			URL:                      manifest.URLs[0],
-			DownloadTimeout:          time.Duration(d.conf.Timeouts.DownloadVNDSegment) * time.Second,
-			GetTrackFileSizeTimeout:  time.Duration(d.conf.Timeouts.GetVNDTrackFileSize) * time.Second,
+			Client:                   d.client,
+			SegmentTimeout:           d.timeouts.vndSegment,
+			FileSizeTimeout:          d.timeouts.vndFileSize,
 			VNDTrackPartsConcurrency: d.conf.Concurrency.VNDTrackParts,
-		}, ext, nil
+			CacheBaseURL:             d.conf.CDNCacheURL,
+			Bandwidth:                d.bandwidth,
+			Gate:                     d.gate,
+		}, ext, respBody.Data.AudioMode, nil
 	default:
-		return nil, "", fmt.Errorf("unexpected manifest mime type: %s", mimeType)
+		return nil, "", "", fmt.Errorf("unexpected manifest mime type: %s", mimeType)
 	}
 }
@@ -0,0 +1,66 @@
+package downloader
+
+import (
+	"context"
+	"strings"
+)
+
+// Metadata a track can be missing, which fails its download in strict metadata mode.
+const (
+	MetadataLyrics  = "lyrics"
+	MetadataCredits = "credits"
+	MetadataCover   = "cover"
+	MetadataISRC    = "isrc"
+)
+
+type strictMetadataKey struct{}
+
+// WithStrictMetadata returns a copy of ctx under which downloads are in strict metadata mode, regardless of
+// the configured mode.
+func WithStrictMetadata(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strictMetadataKey{}, true)
+}
+
+// IsStrictMetadata reports whether ctx was returned by WithStrictMetadata.
+func IsStrictMetadata(ctx context.Context) bool {
+	strict, _ := ctx.Value(strictMetadataKey{}).(bool)
+	return strict
+}
+
+// MissingMetadataError reports the metadata a track is missing in strict metadata mode.
+type MissingMetadataError struct {
+	TrackID string
+	Missing []string
+}
+
+func (e *MissingMetadataError) Error() string {
+	return "track " + e.TrackID + " is missing metadata: " + strings.Join(e.Missing, ", ")
+}
+
+// checkMetadata fails with a MissingMetadataError if the track with id, whose cover has coverID, is missing
+// any metadata in attrs, and strict metadata mode is enabled, either in config or for ctx.
+func (d *Downloader) checkMetadata(ctx context.Context, id, coverID string, attrs TrackEmbeddedAttrs) error {
+	if !IsStrictMetadata(ctx) && !d.conf.StrictMetadata {
+		return nil
+	}
+
+	var missing []string
+	if len(attrs.Lyrics.Text) == 0 && len(attrs.Lyrics.Synced) == 0 {
+		missing = append(missing, MetadataLyrics)
+	}
+	if credits := attrs.Credits; len(credits.Producers)+len(credits.Composers)+len(credits.Lyricists)+len(credits.AdditionalProducers) == 0 {
+		missing = append(missing, MetadataCredits)
+	}
+	if len(coverID) == 0 {
+		missing = append(missing, MetadataCover)
+	}
+	if len(attrs.ISRC) == 0 {
+		missing = append(missing, MetadataISRC)
+	}
+
+	if len(missing) > 0 {
+		return newStageError(StageMetadata, id, &MissingMetadataError{TrackID: id, Missing: missing})
+	}
+
+	return nil
+}
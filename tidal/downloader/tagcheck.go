@@ -0,0 +1,110 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog"
+)
+
+// TagMismatchError reports the embedded tags of a track file that do not match the metadata of the track they
+// were embedded from, e.g., as the metadata of another track was embedded.
+type TagMismatchError struct {
+	TrackID    string
+	Mismatches []string
+}
+
+func (e *TagMismatchError) Error() string {
+	return "track " + e.TrackID + " has mismatching embedded tags: " + strings.Join(e.Mismatches, ", ")
+}
+
+// verifyTrackTags fails with a TagMismatchError if the title, ISRC, or track number tags of the file at path of
+// the track with id do not match attrs, which they were embedded from. The ISRC is only compared if the file has
+// one, as it is stored in a freeform atom in MP4 files, which not every ffprobe version reads.
+func verifyTrackTags(ctx context.Context, logger zerolog.Logger, id, path string, attrs TrackEmbeddedAttrs) error {
+	args := []string{"-v", "error", "-show_entries", "format_tags", "-of", "json", path}
+
+	var stdOut, stdErr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running ffprobe tags check")
+	if err := cmd.Run(); nil != err {
+		if nil != ctx.Err() {
+			return fmt.Errorf("run ffprobe: %w", ctx.Err())
+		}
+
+		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffprobe tags check failed")
+
+		return newStageError(StageTagging, id, fmt.Errorf("read embedded tags: ffprobe failed: %v: %s", err, stdErr.String()))
+	}
+
+	mismatches, err := TrackTagsMismatches(stdOut.Bytes(), attrs)
+	if nil != err {
+		return newStageError(StageTagging, id, err)
+	}
+
+	if len(mismatches) > 0 {
+		logger.Error().Strs("mismatches", mismatches).Msg("Embedded track tags do not match track metadata")
+		return newStageError(StageTagging, id, &TagMismatchError{TrackID: id, Mismatches: mismatches})
+	}
+
+	return nil
+}
+
+// TrackTagsMismatches returns the description of each of the title, ISRC, and track number tags in probe, the
+// JSON output of ffprobe showing the format tags of a track file, that does not match attrs. The ISRC is only
+// compared if probe has one.
+func TrackTagsMismatches(probe []byte, attrs TrackEmbeddedAttrs) ([]string, error) {
+	var out struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(probe, &out); nil != err {
+		return nil, fmt.Errorf("decode ffprobe output: %v", err)
+	}
+
+	// Tag keys are cased differently by container, e.g., TITLE in FLAC files, and title in MP4 files.
+	tags := make(map[string]string, len(out.Format.Tags))
+	for k, v := range out.Format.Tags {
+		tags[strings.ToLower(k)] = v
+	}
+	tag := func(keys ...string) (string, bool) {
+		for _, k := range keys {
+			if v, ok := tags[k]; ok {
+				return v, true
+			}
+		}
+
+		return "", false
+	}
+
+	var mismatches []string
+	mismatch := func(name, got, expected string) {
+		mismatches = append(mismatches, fmt.Sprintf("%s is %q instead of %q", name, got, expected))
+	}
+
+	if title, _ := tag("title"); title != attrs.Title {
+		mismatch("title", title, attrs.Title)
+	}
+
+	// Track numbers are stored along with the total number of tracks in MP4 files, e.g., 3/12.
+	trackNumber, _ := tag("track", "tracknumber")
+	trackNumber, _, _ = strings.Cut(trackNumber, "/")
+	if expected := strconv.Itoa(attrs.TrackNumber); strings.TrimSpace(trackNumber) != expected {
+		mismatch("track number", trackNumber, expected)
+	}
+
+	if isrc, ok := tag("isrc", "tsrc"); ok && len(attrs.ISRC) > 0 && !strings.EqualFold(isrc, attrs.ISRC) {
+		mismatch("ISRC", isrc, attrs.ISRC)
+	}
+
+	return mismatches, nil
+}
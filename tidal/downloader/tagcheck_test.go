@@ -0,0 +1,70 @@
+package downloader_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/tidal/downloader"
+)
+
+func TestTrackTagsMismatches(t *testing.T) {
+	t.Parallel()
+
+	attrs := downloader.TrackEmbeddedAttrs{ //nolint:exhaustruct
+		Title:       "Song",
+		TrackNumber: 3,
+		ISRC:        "USRC17607839",
+	}
+
+	tests := []struct {
+		name       string
+		probe      string
+		mismatches []string
+	}{
+		{
+			name:  "flac",
+			probe: `{"format":{"tags":{"TITLE":"Song","TRACKNUMBER":"3","ISRC":"USRC17607839"}}}`,
+		},
+		{
+			name:  "mp4",
+			probe: `{"format":{"tags":{"title":"Song","track":"3/12","isrc":"usrc17607839"}}}`,
+		},
+		{
+			name:  "missing isrc",
+			probe: `{"format":{"tags":{"title":"Song","track":"3/12"}}}`,
+		},
+		{
+			name:       "flac mismatching",
+			probe:      `{"format":{"tags":{"TITLE":"Other","TRACKNUMBER":"4","ISRC":"GBAYE0601498"}}}`,
+			mismatches: []string{`title is "Other" instead of "Song"`, `track number is "4" instead of "3"`, `ISRC is "GBAYE0601498" instead of "USRC17607839"`},
+		},
+		{
+			name:       "mp4 mismatching track number",
+			probe:      `{"format":{"tags":{"title":"Song","track":"12/12"}}}`,
+			mismatches: []string{`track number is "12" instead of "3"`},
+		},
+		{
+			name:       "no tags",
+			probe:      `{"format":{}}`,
+			mismatches: []string{`title is "" instead of "Song"`, `track number is "" instead of "3"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			mismatches, err := downloader.TrackTagsMismatches([]byte(test.probe), attrs)
+			require.NoError(t, err)
+			require.Equal(t, test.mismatches, mismatches)
+		})
+	}
+}
+
+func TestTrackTagsMismatchesInvalidProbe(t *testing.T) {
+	t.Parallel()
+
+	_, err := downloader.TrackTagsMismatches([]byte("not json"), downloader.TrackEmbeddedAttrs{}) //nolint:exhaustruct
+	require.Error(t, err)
+}
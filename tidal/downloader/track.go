@@ -11,7 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
-	"syscall"
+	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -20,15 +20,21 @@ import (
 	"github.com/tidwall/gjson"
 
 	"github.com/xeptore/tidalgram/cache"
+	"github.com/xeptore/tidalgram/errs"
 	"github.com/xeptore/tidalgram/httputil"
 	"github.com/xeptore/tidalgram/must"
+	"github.com/xeptore/tidalgram/pipeline"
 	"github.com/xeptore/tidalgram/ptr"
-	"github.com/xeptore/tidalgram/ratelimit"
 	"github.com/xeptore/tidalgram/tidal/auth"
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/tags"
 	"github.com/xeptore/tidalgram/tidal/types"
 )
 
 type TrackMeta struct {
+	// ID is the canonical ID of the track, which differs from the requested one if the track was merged into
+	// another one.
+	ID           string
 	Artist       string
 	AlbumID      string
 	AlbumTitle   string
@@ -45,23 +51,30 @@ type TrackMeta struct {
 
 func (d *Downloader) track(ctx context.Context, logger zerolog.Logger, id string) (err error) {
 	creds := d.auth.Credentials()
-	track, err := getTrackMeta(ctx, logger, creds.Token, creds.CountryCode, id)
+	track, err := d.getTrackMeta(ctx, logger, creds.Token, d.countryCode(creds), id)
 	if nil != err {
-		return fmt.Errorf("get track meta: %w", err)
+		return newStageError(StageMetadata, id, fmt.Errorf("get track meta: %w", err))
+	}
+
+	// The rest of the track is requested by its canonical ID, as not all endpoints redirect merged IDs.
+	tidalID := id
+	if track.ID != id {
+		logger.Info().Str("canonical_id", track.ID).Msg("Track ID was merged into another one. Using the canonical ID")
+		tidalID = track.ID
 	}
 
 	trackFs := d.dir.Track(id)
 	if exists, err := trackFs.Cover.AlreadyDownloaded(); nil != err {
 		logger.Error().Err(err).Msg("Failed to check if track cover exists")
 		return fmt.Errorf("check if track cover exists: %v", err)
-	} else if !exists {
+	} else if !exists && !d.copyCachedCover(logger, track.CoverID, trackFs.Cover) {
 		coverBytes, err := d.getCover(ctx, logger, creds.Token, track.CoverID)
 		if nil != err {
-			return fmt.Errorf("get track cover: %w", err)
+			return newStageError(StageMetadata, id, fmt.Errorf("get track cover: %w", err))
 		}
-		if err := trackFs.Cover.Write(coverBytes); nil != err {
+		if err := d.writeCover(trackFs.Cover, track.CoverID, coverBytes); nil != err {
 			logger.Error().Err(err).Msg("Failed to write track cover")
-			return fmt.Errorf("write track cover: %v", err)
+			return newStageError(StageMetadata, id, fmt.Errorf("write track cover: %v", err))
 		}
 	}
 
@@ -82,24 +95,24 @@ func (d *Downloader) track(ctx context.Context, logger zerolog.Logger, id string
 		}
 	}()
 
-	ext, err := d.downloadTrack(ctx, logger, creds.Token, id, trackFs.Path)
+	ext, skippedAudioMode, err := d.downloadTrack(ctx, logger, creds.Token, tidalID, trackFs.Path)
 	if nil != err {
-		return fmt.Errorf("download track: %w", err)
+		return newStageError(StageDownload, id, fmt.Errorf("download track: %w", err))
 	}
 
-	trackCredits, err := d.getTrackCredits(ctx, logger, creds.Token, creds.CountryCode, id)
+	trackCredits, err := d.getTrackCredits(ctx, logger, creds.Token, d.countryCode(creds), tidalID)
 	if nil != err {
-		return fmt.Errorf("get track credits: %w", err)
+		return newStageError(StageMetadata, id, fmt.Errorf("get track credits: %w", err))
 	}
 
-	trackLyrics, err := d.downloadTrackLyrics(ctx, logger, creds.Token, creds.CountryCode, id)
+	trackLyrics, err := d.downloadTrackLyrics(ctx, logger, creds.Token, d.countryCode(creds), tidalID)
 	if nil != err {
-		return fmt.Errorf("download track lyrics: %w", err)
+		return newStageError(StageMetadata, id, fmt.Errorf("download track lyrics: %w", err))
 	}
 
-	album, err := d.getAlbumMeta(ctx, logger, creds.Token, creds.CountryCode, track.AlbumID)
+	album, err := d.getAlbumMeta(ctx, logger, creds.Token, d.countryCode(creds), track.AlbumID)
 	if nil != err {
-		return fmt.Errorf("get album meta: %w", err)
+		return newStageError(StageMetadata, id, fmt.Errorf("get album meta: %w", err))
 	}
 
 	attrs := TrackEmbeddedAttrs{
@@ -118,26 +131,66 @@ func (d *Downloader) track(ctx context.Context, logger zerolog.Logger, id string
 		VolumeNumber: track.VolumeNumber,
 		TotalVolumes: album.TotalVolumes,
 		Credits:      *trackCredits,
-		Lyrics:       trackLyrics,
+		Lyrics:       *trackLyrics,
 		Ext:          ext,
 	}
-	if err := embedTrackAttributes(ctx, logger, trackFs.Path, attrs); nil != err {
-		return fmt.Errorf("embed track attributes: %v", err)
+	if err := d.checkMetadata(ctx, id, track.CoverID, attrs); nil != err {
+		return err
 	}
 
 	info := types.StoredTrack{
-		Track: types.Track{
-			Artists:      track.Artists,
-			Title:        track.Title,
-			TrackNumber:  track.TrackNumber,
-			VolumeNumber: track.VolumeNumber,
-			Duration:     track.Duration,
-			Version:      track.Version,
-			CoverID:      track.CoverID,
-			Ext:          ext,
+		Track: types.Track{ //nolint:exhaustruct
+			Artists:          track.Artists,
+			Title:            track.Title,
+			TrackNumber:      track.TrackNumber,
+			VolumeNumber:     track.VolumeNumber,
+			Duration:         track.Duration,
+			Version:          track.Version,
+			CoverID:          track.CoverID,
+			Ext:              ext,
+			SkippedAudioMode: skippedAudioMode,
 		},
-		Caption: trackCaption(album.Title, album.ReleaseDate),
+		Album:    album.Stored(),
+		Metadata: attrs.metadata(tidalID, track.AlbumID, track.Duration),
+	}
+
+	// Replay gain is applied to the tagged file, so a streamed file would not be the stored one.
+	var stream *pipeline.TrackStream
+	if ext == "flac" && !d.conf.ReplayGain {
+		stream = trackStreamFrom(ctx).Stream(info)
+	}
+	if nil != stream {
+		if err := d.streamTrack(ctx, logger, id, trackFs.Path, attrs, stream); nil != err {
+			return err
+		}
+	} else {
+		if err := d.embedTrackAttributes(ctx, logger, trackFs.Path, attrs); nil != err {
+			return newStageError(StageTagging, id, fmt.Errorf("embed track attributes: %w", err))
+		}
+		if err := verifyTrackTags(ctx, logger, id, trackFs.Path, attrs); nil != err {
+			return err
+		}
+	}
+
+	if d.conf.ReplayGain {
+		rgTrack := replayGainTrack{ID: id, Path: trackFs.Path, Ext: ext, Duration: track.Duration}
+		if err := applyTrackReplayGain(ctx, logger, rgTrack); nil != err {
+			return newStageError(StageTagging, id, fmt.Errorf("apply replay gain: %w", err))
+		}
 	}
+
+	if err := writeLyricsFile(trackFs.Lyrics, attrs); nil != err {
+		logger.Error().Err(err).Msg("Failed to write track lyrics file")
+		return fmt.Errorf("write track lyrics file: %v", err)
+	}
+
+	size, sum, err := fs.FileChecksum(trackFs.Path)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to get track file checksum")
+		return fmt.Errorf("get track file checksum: %v", err)
+	}
+	info.Size, info.SHA256 = size, sum
+
 	if err := trackFs.InfoFile.Write(info); nil != err {
 		logger.Error().Err(err).Msg("Failed to write track info file")
 		return fmt.Errorf("write track info: %v", err)
@@ -146,7 +199,7 @@ func (d *Downloader) track(ctx context.Context, logger zerolog.Logger, id string
 	return nil
 }
 
-func getTrackMeta(
+func (d *Downloader) getTrackMeta(
 	ctx context.Context,
 	logger zerolog.Logger,
 	accessToken string,
@@ -170,8 +223,7 @@ func getTrackMeta(
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	client := http.Client{Timeout: 5 * time.Second} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.trackInfo)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get track info request")
 		return nil, fmt.Errorf("send get track info request: %w", err)
@@ -210,7 +262,7 @@ func getTrackMeta(
 
 		return nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return nil, ErrTooManyRequests
+		return nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -222,7 +274,7 @@ func getTrackMeta(
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
 			return nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return nil, ErrTooManyRequests
+			return nil, newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
@@ -247,6 +299,7 @@ func getTrackMeta(
 	}
 
 	var respBody struct {
+		ID           int    `json:"id"`
 		Duration     int    `json:"duration"`
 		Title        string `json:"title"`
 		TrackNumber  int    `json:"trackNumber"`
@@ -288,7 +341,17 @@ func getTrackMeta(
 		artists[i] = types.TrackArtist{Name: artist.Name, Type: artist.Type}
 	}
 
+	// Merged track IDs are either redirected to, or served with the canonical ID of the track.
+	canonicalID := id
+	if respBody.ID != 0 {
+		canonicalID = strconv.Itoa(respBody.ID)
+	}
+	if redirected := resp.Request.URL; redirected.Path != reqURL.Path {
+		logger.Debug().Str("redirected_url", redirected.String()).Msg("Track info request was redirected")
+	}
+
 	track := TrackMeta{
+		ID:           canonicalID,
 		Artist:       respBody.Artist.Name,
 		AlbumID:      strconv.Itoa(respBody.Album.ID),
 		AlbumTitle:   respBody.Album.Title,
@@ -306,31 +369,79 @@ func getTrackMeta(
 	return &track, nil
 }
 
+// downloadTrack downloads the track with id to fileName, and returns its extension, and the spatial audio mode
+// that was skipped in favor of its stereo stream, if any. Tracks are downloaded again if their downloaded parts
+// do not match their advertised sizes and hashes, or if the downloaded track fails the checks of verifyTrack.
 func (d *Downloader) downloadTrack(
 	ctx context.Context,
 	logger zerolog.Logger,
 	accessToken string,
 	id string,
 	fileName string,
-) (ext string, err error) {
+) (string, string, error) {
 	logger = logger.With().Str("file_name", fileName).Logger()
 
-	stream, ext, err := d.getStream(ctx, logger, id)
-	if nil != err {
-		return "", fmt.Errorf("get track stream: %w", err)
+	for attempt := 0; ; attempt++ {
+		ext, skippedAudioMode, err := d.fetchTrack(ctx, logger, accessToken, id, fileName)
+		if nil != err {
+			if !errors.Is(err, ErrCorruptedTrack) || attempt == maxCorruptedTrackRetries {
+				return "", "", err
+			}
+		} else if err = verifyTrack(ctx, logger, fileName, ext); nil == err {
+			return ext, skippedAudioMode, nil
+		} else if !errors.Is(err, ErrCorruptedTrack) || attempt == maxCorruptedTrackRetries {
+			return "", "", fmt.Errorf("verify track: %w", err)
+		}
+
+		// Verification of a track is interrupted once ctx is done, which is not to be mistaken for corruption.
+		if nil != ctx.Err() {
+			return "", "", err
+		}
+
+		logger.Warn().Err(err).Int("attempt", attempt+1).Msg("Downloaded track is corrupted. Downloading it again")
+		// The stream might be served corrupted by the same URLs again.
+		d.playback.delete(id)
 	}
+}
 
-	time.Sleep(ratelimit.TrackDownloadSleepMS())
+func (d *Downloader) fetchTrack(
+	ctx context.Context,
+	logger zerolog.Logger,
+	accessToken string,
+	id string,
+	fileName string,
+) (ext string, skippedAudioMode string, err error) {
+	if err := d.gate.Wait(ctx); nil != err {
+		return "", "", fmt.Errorf("wait for paused job: %w", err)
+	}
 
-	if err := stream.saveTo(ctx, logger, accessToken, fileName); nil != err {
-		return "", fmt.Errorf("download track: %w", err)
+	stream, ext, skippedAudioMode, reused := d.playback.get(id)
+	if reused {
+		logger.Debug().Msg("Reusing playback info of previous attempt")
+	} else {
+		if stream, ext, skippedAudioMode, err = d.getStream(ctx, logger, id); nil != err {
+			return "", "", fmt.Errorf("get track stream: %w", err)
+		}
+		d.playback.set(id, stream, ext, skippedAudioMode)
 	}
 
-	return ext, nil
-}
+	save := stream.saveTo
+	if d.networkFS {
+		save = func(ctx context.Context, logger zerolog.Logger, accessToken, fileName string) error {
+			return saveViaLocalDir(ctx, logger, stream, accessToken, fileName)
+		}
+	}
+
+	if err := save(ctx, logger, accessToken, fileName); nil != err {
+		// A reused stream failing again might have stale URLs. Fetch it again on the next attempt.
+		if reused {
+			d.playback.delete(id)
+		}
 
-func trackCaption(albumTitle string, releaseDate time.Time) string {
-	return fmt.Sprintf("%s (%s)", albumTitle, releaseDate.Format(types.ReleaseDateLayout))
+		return "", "", fmt.Errorf("download track: %w", err)
+	}
+
+	return ext, skippedAudioMode, nil
 }
 
 func (d *Downloader) getTrackCredits(
@@ -382,10 +493,7 @@ func (d *Downloader) downloadTrackCredits(
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	client := http.Client{ //nolint:exhaustruct
-		Timeout: time.Duration(d.conf.Timeouts.GetTrackCredits) * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.trackCredits)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get track credits request")
 		return nil, fmt.Errorf("send get track credits request: %w", err)
@@ -424,7 +532,7 @@ func (d *Downloader) downloadTrackCredits(
 
 		return nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return nil, ErrTooManyRequests
+		return nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -436,7 +544,7 @@ func (d *Downloader) downloadTrackCredits(
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
 			return nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return nil, ErrTooManyRequests
+			return nil, newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
@@ -475,12 +583,12 @@ func (d *Downloader) downloadTrackLyrics(
 	accessToken string,
 	countryCode string,
 	id string,
-) (l string, err error) {
+) (l *TrackLyrics, err error) {
 	trackLyricsURL := fmt.Sprintf(trackLyricsAPIFormat, id)
 	reqURL, err := url.Parse(trackLyricsURL)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to parse track lyrics URL")
-		return "", fmt.Errorf("parse track lyrics URL %s: %v", trackLyricsURL, err)
+		return nil, fmt.Errorf("parse track lyrics URL %s: %v", trackLyricsURL, err)
 	}
 
 	reqParams := make(url.Values, 2)
@@ -491,19 +599,16 @@ func (d *Downloader) downloadTrackLyrics(
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to create get track lyrics request")
-		return "", fmt.Errorf("create get track lyrics request %s: %w", reqURL.String(), err)
+		return nil, fmt.Errorf("create get track lyrics request %s: %w", reqURL.String(), err)
 	}
 
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	client := http.Client{ //nolint:exhaustruct
-		Timeout: time.Duration(d.conf.Timeouts.GetTrackLyrics) * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req, d.timeouts.trackLyrics)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get track lyrics request")
-		return "", fmt.Errorf("send get track lyrics request: %w", err)
+		return nil, fmt.Errorf("send get track lyrics request: %w", err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); nil != closeErr {
@@ -515,81 +620,88 @@ func (d *Downloader) downloadTrackLyrics(
 	switch code := resp.StatusCode; code {
 	case http.StatusOK:
 	case http.StatusNotFound:
-		return "", nil
+		return &TrackLyrics{Text: "", Synced: nil}, nil
 	case http.StatusUnauthorized:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Msg("Failed to read 401 response body")
-			return "", fmt.Errorf("read 401 response body: %w", err)
+			return nil, fmt.Errorf("read 401 response body: %w", err)
 		}
 
 		if ok, err := httputil.IsTokenExpiredResponse(respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 401 response is token expired")
-			return "", fmt.Errorf("check if 401 response is token expired: %v", err)
+			return nil, fmt.Errorf("check if 401 response is token expired: %v", err)
 		} else if ok {
-			return "", auth.ErrUnauthorized
+			return nil, auth.ErrUnauthorized
 		}
 
 		if ok, err := httputil.IsTokenInvalidResponse(respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 401 response is token invalid")
-			return "", fmt.Errorf("check if 401 response is token invalid: %v", err)
+			return nil, fmt.Errorf("check if 401 response is token invalid: %v", err)
 		} else if ok {
-			return "", auth.ErrUnauthorized
+			return nil, auth.ErrUnauthorized
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 401 response")
 
-		return "", fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
+		return nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return "", ErrTooManyRequests
+		return nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Msg("Failed to read 403 response body")
-			return "", fmt.Errorf("read 403 response body: %w", err)
+			return nil, fmt.Errorf("read 403 response body: %w", err)
 		}
 
 		if ok, err := httputil.IsTooManyErrorResponse(resp, respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 403 response is too many requests")
-			return "", fmt.Errorf("check if 403 response is too many requests: %v", err)
+			return nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return "", ErrTooManyRequests
+			return nil, newRateLimitedError(resp, respBytes)
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 403 response")
 
-		return "", fmt.Errorf("unexpected 403 response with body: %s", string(respBytes))
+		return nil, fmt.Errorf("unexpected 403 response with body: %s", string(respBytes))
 	default:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Int("status_code", code).Msg("Failed to read response body")
-			return "", fmt.Errorf("read response body: %w", err)
+			return nil, fmt.Errorf("read response body: %w", err)
 		}
 
 		logger.Error().Int("status_code", code).Bytes("response_body", respBytes).Msg("Unexpected response status code")
 
-		return "", fmt.Errorf("unexpected status code %d with body: %s", code, string(respBytes))
+		return nil, fmt.Errorf("unexpected status code %d with body: %s", code, string(respBytes))
 	}
 
 	respBytes, err := io.ReadAll(resp.Body)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to read 200 response body")
-		return "", fmt.Errorf("read 200 response body: %w", err)
+		return nil, fmt.Errorf("read 200 response body: %w", err)
 	}
 
 	if !gjson.ValidBytes(respBytes) {
 		logger.Error().Bytes("response_body", respBytes).Msg("Invalid track lyrics 200 response json")
-		return "", fmt.Errorf("invalid track lyrics 200 response json: %v", err)
+		return nil, fmt.Errorf("invalid track lyrics 200 response json: %v", err)
 	}
 
-	var lyrics string
-	if lyricsKey := gjson.GetBytes(respBytes, "subtitles"); lyricsKey.Type == gjson.String {
-		lyrics = lyricsKey.Str
-	} else if lyricsKey := gjson.GetBytes(respBytes, "lyrics"); lyricsKey.Type == gjson.String {
-		lyrics = lyricsKey.Str
-	} else {
+	var (
+		subtitles = gjson.GetBytes(respBytes, "subtitles")
+		text      = gjson.GetBytes(respBytes, "lyrics")
+	)
+	if subtitles.Type != gjson.String && text.Type != gjson.String {
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected track lyrics 200 response")
-		return "", fmt.Errorf("unexpected track lyrics 200 response: %s", string(respBytes))
+		return nil, fmt.Errorf("unexpected track lyrics 200 response: %s", string(respBytes))
+	}
+
+	lyrics := &TrackLyrics{Text: "", Synced: nil}
+	if subtitles.Type == gjson.String {
+		lyrics.Synced = parseSyncedLyrics(subtitles.Str)
+	}
+	if text.Type == gjson.String {
+		lyrics.Text = text.Str
 	}
 
 	return lyrics, nil
@@ -645,7 +757,7 @@ type TrackEmbeddedAttrs struct {
 	VolumeNumber int
 	TotalVolumes int
 	Credits      types.TrackCredits
-	Lyrics       string
+	Lyrics       TrackLyrics
 	Ext          string
 }
 
@@ -666,12 +778,35 @@ func (t TrackEmbeddedAttrs) toDict() *zerolog.Event {
 		Int("volume_number", t.VolumeNumber).
 		Int("total_volumes", t.TotalVolumes).
 		Dict("credits", t.Credits.ToDict()).
-		Str("lyrics", t.Lyrics).
+		Str("lyrics", t.Lyrics.plainText()).
+		Int("synced_lyrics_lines", len(t.Lyrics.Synced)).
 		Str("version", ptr.ValueOr(t.Version, "<nil>")).
 		Str("ext", t.Ext)
 }
 
-func embedTrackAttributes(
+// metadata returns the sidecar metadata of the track with id, which belongs to the album with albumID.
+func (t TrackEmbeddedAttrs) metadata(id, albumID string, duration int) types.TrackMetadata {
+	return types.TrackMetadata{
+		TidalID:      id,
+		TidalAlbumID: albumID,
+		Title:        t.Title,
+		Version:      t.Version,
+		Artists:      t.Artists,
+		Album:        t.Album,
+		AlbumArtist:  t.AlbumArtist,
+		ISRC:         t.ISRC,
+		Copyright:    t.Copyright,
+		ReleaseDate:  t.ReleaseDate.Format(time.DateOnly),
+		TrackNumber:  t.TrackNumber,
+		TotalTracks:  t.TotalTracks,
+		VolumeNumber: t.VolumeNumber,
+		TotalVolumes: t.TotalVolumes,
+		Duration:     duration,
+		Credits:      t.Credits,
+	}
+}
+
+func (d *Downloader) embedTrackAttributes(
 	ctx context.Context,
 	logger zerolog.Logger,
 	trackFilePath string,
@@ -679,6 +814,37 @@ func embedTrackAttributes(
 ) (err error) {
 	logger = logger.With().Str("track_file_path", trackFilePath).Dict("attrs", attrs.toDict()).Logger()
 
+	metaTags := trackMetaTags(attrs)
+
+	if d.conf.NativeTagging {
+		err := writeNativeTags(trackFilePath, attrs.CoverPath, metaTags)
+		if nil == err {
+			return nil
+		}
+		if errors.Is(err, tags.ErrUnsupported) {
+			logger.Debug().Err(err).Msg("Track file is not supported by native tagging, falling back to ffmpeg")
+		} else {
+			logger.Warn().Err(err).Msg("Failed to write track attributes natively, falling back to ffmpeg")
+		}
+	}
+
+	trackFilenameExt := trackFilePath + "." + attrs.Ext
+
+	args := append(ffmpegTaggingArgs(trackFilePath, attrs.CoverPath, metaTags), trackFilenameExt)
+	if err := runFFmpegTagging(ctx, logger, trackFilePath, args, nil); nil != err {
+		return err
+	}
+
+	if err := os.Rename(trackFilenameExt, trackFilePath); nil != err {
+		logger.Error().Err(err).Msg("Failed to rename track file")
+		return fmt.Errorf("rename track file: %v", err)
+	}
+
+	return nil
+}
+
+// trackMetaTags returns attrs formatted as ffmpeg metadata arguments.
+func trackMetaTags(attrs TrackEmbeddedAttrs) []string {
 	metaTags := []string{
 		"artist=" + types.JoinArtists(attrs.Artists),
 		"lead_performer=" + attrs.LeadArtist,
@@ -693,7 +859,15 @@ func embedTrackAttributes(
 		"disctotal=" + strconv.Itoa(attrs.TotalVolumes),
 		"date=" + attrs.ReleaseDate.Format(time.DateOnly),
 		"year=" + strconv.Itoa(attrs.ReleaseDate.Year()),
-		"lyrics=" + lo.Ternary(len(attrs.Lyrics) == 0, "", attrs.Lyrics),
+	}
+
+	// Unlike ID3, neither FLAC nor MP4 have a synced lyrics frame like SYLT. Players that support synced
+	// lyrics in FLAC files read them in LRC format from the LYRICS comment, so the plain text is stored
+	// in UNSYNCEDLYRICS instead. MP4 lyrics atom is always plain text.
+	if attrs.Ext == "flac" && len(attrs.Lyrics.Synced) > 0 {
+		metaTags = append(metaTags, "lyrics="+attrs.Lyrics.lrc(attrs), "unsyncedlyrics="+attrs.Lyrics.plainText())
+	} else {
+		metaTags = append(metaTags, "lyrics="+attrs.Lyrics.plainText())
 	}
 
 	if len(attrs.Credits.Composers) > 0 {
@@ -716,20 +890,19 @@ func embedTrackAttributes(
 		metaTags = append(metaTags, "version="+*attrs.Version)
 	}
 
-	metaArgs := make([]string, 0, len(metaTags)*2)
-	for _, tag := range metaTags {
-		metaArgs = append(metaArgs, "-metadata", tag)
-	}
-
-	trackFilenameExt := trackFilePath + "." + attrs.Ext
+	return metaTags
+}
 
-	args := make([]string, 0, 10+len(metaTags)+1)
+// ffmpegTaggingArgs returns the ffmpeg arguments, except for the output, that copy the track file at
+// trackFilePath, along with the cover at coverPath, and metaTags embedded.
+func ffmpegTaggingArgs(trackFilePath, coverPath string, metaTags []string) []string {
+	args := make([]string, 0, 12+len(metaTags)*2+3)
 	args = append(
 		args,
 		"-i",
 		trackFilePath,
 		"-i",
-		attrs.CoverPath,
+		coverPath,
 		"-map",
 		"0:a",
 		"-map",
@@ -739,57 +912,62 @@ func embedTrackAttributes(
 		"-disposition:v",
 		"attached_pic",
 	)
-	args = append(args, metaArgs...)
-	args = append(args, trackFilenameExt)
-
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} //nolint:exhaustruct
-
-	cmd.Cancel = func() error {
-		proc := cmd.Process
-		if proc == nil {
-			return os.ErrProcessDone
-		}
-
-		// Sends the signal to process group (-pid) so child processes get it too.
-		_ = syscall.Kill(-proc.Pid, syscall.SIGINT)
-
-		for {
-			time.Sleep(300 * time.Millisecond)
+	for _, tag := range metaTags {
+		args = append(args, "-metadata", tag)
+	}
 
-			if err := syscall.Kill(proc.Pid, 0); nil != err {
-				return os.ErrProcessDone
-			}
+	return args
+}
 
-			_ = syscall.Kill(-proc.Pid, syscall.SIGINT)
-		}
-	}
+// runFFmpegTagging runs ffmpeg with args, which embed tags into the track file at trackFilePath, writing its
+// output to stdOut, unless it is nil.
+func runFFmpegTagging(ctx context.Context, logger zerolog.Logger, trackFilePath string, args []string, stdOut io.Writer) error {
+	cmd := ffmpegCommand(ctx, args...)
 
 	logger.Debug().Strs("args", args).Msg("Running ffmpeg")
 
-	var (
-		stdOut bytes.Buffer
-		stdErr bytes.Buffer
-	)
-
-	cmd.Stdout = &stdOut
+	var stdErr bytes.Buffer
+	if nil == stdOut {
+		stdOut = io.Discard
+	}
+	cmd.Stdout = stdOut
 	cmd.Stderr = &stdErr
 
 	if err := cmd.Run(); nil != err {
 		if errors.Is(err, exec.ErrNotFound) {
 			logger.Error().Err(err).Msg("ffmpeg not found")
-			return fmt.Errorf("ffmpeg not found: %v", err)
+			return errs.Wrap(errs.CategoryFFmpeg, fmt.Errorf("ffmpeg not found: %v", err))
 		}
 
 		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffmpeg failed")
 
-		return fmt.Errorf("write track attributes using ffmpeg (%w): %s", err, stdErr.String())
+		return &FFmpegError{
+			Args:   args,
+			Stderr: stdErr.String(),
+			Probe:  probeTrack(ctx, trackFilePath),
+			Err:    err,
+		}
 	}
 
-	if err := os.Rename(trackFilenameExt, trackFilePath); nil != err {
-		logger.Error().Err(err).Msg("Failed to rename track file")
-		return fmt.Errorf("rename track file: %v", err)
+	return nil
+}
+
+// writeNativeTags writes metaTags, formatted as ffmpeg metadata arguments, and the cover at coverPath
+// directly into the track file at trackFilePath.
+func writeNativeTags(trackFilePath, coverPath string, metaTags []string) error {
+	cover, err := os.ReadFile(coverPath)
+	if nil != err {
+		return fmt.Errorf("read cover file: %v", err)
+	}
+
+	trackTags := make([]tags.Tag, 0, len(metaTags))
+	for _, tag := range metaTags {
+		key, value, _ := strings.Cut(tag, "=")
+		trackTags = append(trackTags, tags.Tag{Key: key, Value: value})
+	}
+
+	if err := tags.Write(trackFilePath, trackTags, cover); nil != err {
+		return fmt.Errorf("write track tags: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/pipeline"
+)
+
+// streamTrack embeds attrs into the downloaded FLAC file at trackFilePath of the track with id, while streaming
+// the tagged file to stream as ffmpeg writes it. The stream is only ended once the embedded tags are verified,
+// so that its reader fails, instead of completing the upload of a mistagged file, if they do not match attrs.
+// Tags are always embedded using ffmpeg, as native tagging rewrites the file in place.
+func (d *Downloader) streamTrack(
+	ctx context.Context,
+	logger zerolog.Logger,
+	id string,
+	trackFilePath string,
+	attrs TrackEmbeddedAttrs,
+	stream *pipeline.TrackStream,
+) (err error) {
+	logger = logger.With().Str("track_file_path", trackFilePath).Dict("attrs", attrs.toDict()).Logger()
+	defer func() { stream.CloseWrite(err) }()
+
+	trackFilenameExt := trackFilePath + "." + attrs.Ext
+	f, err := os.OpenFile(trackFilenameExt, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o0600)
+	if nil != err {
+		logger.Error().Err(err).Msg("Failed to create tagged track file")
+		return fmt.Errorf("create tagged track file: %v", err)
+	}
+	defer func() {
+		if nil != err {
+			if removeErr := os.Remove(trackFilenameExt); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+				logger.Error().Err(removeErr).Msg("Failed to remove tagged track file")
+				err = errors.Join(err, fmt.Errorf("remove tagged track file: %v", removeErr))
+			}
+		}
+	}()
+
+	// The FLAC muxer can not seek back to the stream info header of a piped output, which is copied as is from
+	// the downloaded file instead.
+	args := append(ffmpegTaggingArgs(trackFilePath, attrs.CoverPath, trackMetaTags(attrs)), "-f", "flac", "pipe:1")
+	runErr := runFFmpegTagging(ctx, logger, trackFilePath, args, io.MultiWriter(f, stream))
+	if closeErr := f.Close(); nil != closeErr && nil == runErr {
+		logger.Error().Err(closeErr).Msg("Failed to close tagged track file")
+		runErr = fmt.Errorf("close tagged track file: %v", closeErr)
+	}
+	if nil != runErr {
+		return newStageError(StageTagging, id, fmt.Errorf("embed track attributes: %w", runErr))
+	}
+
+	if err := os.Rename(trackFilenameExt, trackFilePath); nil != err {
+		logger.Error().Err(err).Msg("Failed to rename track file")
+		return fmt.Errorf("rename track file: %v", err)
+	}
+
+	return verifyTrackTags(ctx, logger, id, trackFilePath, attrs)
+}
@@ -0,0 +1,169 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// maxCorruptedTrackRetries is the number of times a track that fails the integrity checks is downloaded again.
+const maxCorruptedTrackRetries = 2
+
+var (
+	ErrCorruptedTrack = errors.New("downloaded track is corrupted")
+
+	flacMagic = []byte("fLaC")
+)
+
+// flacStreamInfo is the part of the FLAC STREAMINFO metadata block needed to verify the decoded audio.
+type flacStreamInfo struct {
+	BitsPerSample int
+	// MD5 is the MD5 of the decoded audio. It is all zeros if the encoder did not compute it.
+	MD5 [16]byte
+}
+
+// readFLACStreamInfo reads the STREAMINFO metadata block of the FLAC file r, which is always its first one.
+func readFLACStreamInfo(r io.Reader) (*flacStreamInfo, error) {
+	// Magic, metadata block header, and the 34 bytes long STREAMINFO block.
+	var buf [4 + 4 + 34]byte
+	if _, err := io.ReadFull(r, buf[:]); nil != err {
+		return nil, fmt.Errorf("read stream info: %v", err)
+	}
+
+	if !bytes.Equal(buf[:4], flacMagic) {
+		return nil, errors.New("not a FLAC file")
+	}
+
+	if blockType := buf[4] & 0x7f; blockType != 0 {
+		return nil, fmt.Errorf("unexpected first metadata block type: %d", blockType)
+	}
+
+	block := buf[8:]
+	info := flacStreamInfo{
+		BitsPerSample: int((block[12]&0x01)<<4|block[13]>>4) + 1,
+		MD5:           [16]byte(block[18:34]),
+	}
+
+	return &info, nil
+}
+
+// verifyFLACTrack decodes the FLAC track file at path, and returns ErrCorruptedTrack if the MD5 of its decoded
+// audio does not match the one embedded in it. Tracks downloaded in MP4 containers are first remuxed to FLAC,
+// as they would be when their attributes are embedded. Tracks without an embedded MD5 are not verified.
+func verifyFLACTrack(ctx context.Context, logger zerolog.Logger, path string) (err error) {
+	isFLAC, err := hasFLACMagic(path)
+	if nil != err {
+		return fmt.Errorf("check track file format: %v", err)
+	}
+
+	if !isFLAC {
+		remuxed := path + ".verify.flac"
+		args := []string{"-hide_banner", "-v", "error", "-y", "-i", path, "-map", "0:a", "-c", "copy", "-f", "flac", remuxed}
+		cmd := ffmpegCommand(ctx, args...)
+
+		var stdErr bytes.Buffer
+		cmd.Stderr = &stdErr
+
+		logger.Debug().Strs("args", args).Msg("Running ffmpeg remux for verification")
+		if err := cmd.Run(); nil != err {
+			logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffmpeg remux for verification failed")
+			return errors.Join(ErrCorruptedTrack, &FFmpegError{Args: args, Stderr: stdErr.String(), Probe: probeTrack(ctx, path), Err: err})
+		}
+		defer func() {
+			if removeErr := os.Remove(remuxed); nil != removeErr {
+				logger.Error().Err(removeErr).Msg("Failed to remove remuxed verification file")
+				err = errors.Join(err, fmt.Errorf("remove remuxed verification file: %v", removeErr))
+			}
+		}()
+		path = remuxed
+	}
+
+	f, err := os.Open(path)
+	if nil != err {
+		return fmt.Errorf("open track file: %v", err)
+	}
+	info, err := readFLACStreamInfo(f)
+	if closeErr := f.Close(); nil != closeErr {
+		err = errors.Join(err, fmt.Errorf("close track file: %v", closeErr))
+	}
+	if nil != err {
+		return fmt.Errorf("read FLAC stream info: %w", err)
+	}
+
+	if info.MD5 == [16]byte{} {
+		logger.Debug().Msg("Track has no embedded MD5. Skipping verification")
+		return nil
+	}
+
+	// FLAC hashes samples as signed little-endian integers, packed to the bytes their bit depth needs.
+	var codec string
+	switch info.BitsPerSample {
+	case 8:
+		codec = "pcm_s8"
+	case 16:
+		codec = "pcm_s16le"
+	case 24:
+		codec = "pcm_s24le"
+	case 32:
+		codec = "pcm_s32le"
+	default:
+		logger.Debug().Int("bits_per_sample", info.BitsPerSample).Msg("Unsupported track bit depth. Skipping verification")
+		return nil
+	}
+
+	args := []string{"-hide_banner", "-v", "error", "-i", path, "-map", "0:a:0", "-c:a", codec, "-f", "md5", "-"}
+	cmd := ffmpegCommand(ctx, args...)
+
+	var stdOut, stdErr bytes.Buffer
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running ffmpeg audio MD5")
+	if err := cmd.Run(); nil != err {
+		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffmpeg audio MD5 failed")
+		// Decoding errors are the most likely sign of a corrupted download.
+		return errors.Join(ErrCorruptedTrack, &FFmpegError{Args: args, Stderr: stdErr.String(), Probe: probeTrack(ctx, path), Err: err})
+	}
+
+	decoded, ok := strings.CutPrefix(strings.TrimSpace(stdOut.String()), "MD5=")
+	if !ok {
+		return fmt.Errorf("unexpected ffmpeg audio MD5 output: %s", stdOut.String())
+	}
+
+	if embedded := hex.EncodeToString(info.MD5[:]); decoded != embedded {
+		logger.Warn().Str("embedded_md5", embedded).Str("decoded_md5", decoded).Msg("Decoded track audio MD5 mismatch")
+		return fmt.Errorf("%w: embedded %s, decoded %s", ErrCorruptedTrack, embedded, decoded)
+	}
+
+	return nil
+}
+
+func hasFLACMagic(path string) (ok bool, err error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return false, fmt.Errorf("open file: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+		}
+	}()
+
+	var magic [4]byte
+	if _, err := io.ReadFull(f, magic[:]); nil != err {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("read file: %v", err)
+	}
+
+	return bytes.Equal(magic[:], flacMagic), nil
+}
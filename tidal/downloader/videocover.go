@@ -0,0 +1,132 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/xeptore/tidalgram/tidal/fs"
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+const videoCoverURLFormat = "https://resources.tidal.com/videos/%s/%s.mp4"
+
+// videoCoverResolutions are the names of the video cover resolutions to download, in order, until one is available.
+var videoCoverResolutions = []string{"1280x1280", "640x640", "320x320"}
+
+// downloadVideoCover downloads the video cover with videoCoverID, and stores it in cover as a muted MP4 video
+// that can be played while it is being downloaded.
+func (d *Downloader) downloadVideoCover(
+	ctx context.Context,
+	logger zerolog.Logger,
+	accessToken string,
+	videoCoverID string,
+	cover fs.VideoCover,
+) error {
+	var b []byte
+	for _, resolution := range videoCoverResolutions {
+		coverURL := fmt.Sprintf(videoCoverURLFormat, strings.ReplaceAll(videoCoverID, "-", "/"), resolution)
+
+		var err error
+		if b, err = d.downloadCoverURL(ctx, logger, accessToken, coverURL); nil == err {
+			break
+		} else if !errors.Is(err, errCoverNotFound) {
+			return fmt.Errorf("download video cover: %w", err)
+		}
+		logger.Debug().Str("video_cover_id", videoCoverID).Str("resolution", resolution).Msg("Video cover is not available in resolution. Trying a lower one")
+	}
+	if nil == b {
+		return fmt.Errorf("%w in any of resolutions %v", errCoverNotFound, videoCoverResolutions)
+	}
+
+	inPath := cover.Path + ".download"
+	if err := os.WriteFile(inPath, b, 0o600); nil != err {
+		return fmt.Errorf("write downloaded video cover file: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(inPath); nil != err && !errors.Is(err, os.ErrNotExist) {
+			logger.Error().Err(err).Msg("Failed to remove downloaded video cover file")
+		}
+	}()
+
+	// ffmpeg picks the output format from the extension, which is not the one of the final path.
+	outPath := cover.Path + ".remux"
+	args := []string{
+		"-hide_banner", "-y", "-i", inPath,
+		"-map", "0:v:0", "-c", "copy", "-an", "-movflags", "+faststart", "-f", "mp4",
+		outPath,
+	}
+	cmd := ffmpegCommand(ctx, args...)
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	logger.Debug().Strs("args", args).Msg("Running ffmpeg to remux video cover")
+	if err := cmd.Run(); nil != err {
+		logger.Error().Err(err).Str("stderr", stdErr.String()).Msg("ffmpeg failed to remux video cover")
+		if removeErr := os.Remove(outPath); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+			logger.Error().Err(removeErr).Msg("Failed to remove partially written video cover file")
+		}
+
+		return fmt.Errorf("remux video cover using ffmpeg (%v): %s", err, stdErr.String())
+	}
+
+	info, err := probeVideoCover(ctx, outPath)
+	if nil != err {
+		return fmt.Errorf("probe video cover: %v", err)
+	}
+
+	if err := os.Rename(outPath, cover.Path); nil != err {
+		return fmt.Errorf("rename remuxed video cover file: %v", err)
+	}
+
+	if err := cover.InfoFile.Write(*info); nil != err {
+		return fmt.Errorf("write video cover info file: %v", err)
+	}
+
+	return nil
+}
+
+// probeVideoCover returns the dimensions and duration of the video cover file at path.
+func probeVideoCover(ctx context.Context, path string) (*types.StoredVideoCover, error) {
+	args := []string{"-v", "error", "-select_streams", "v:0", "-show_entries", "stream=width,height,duration", "-of", "json", path}
+
+	var stdOut, stdErr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &stdErr
+	if err := cmd.Run(); nil != err {
+		return nil, fmt.Errorf("run ffprobe (%v): %s", err, stdErr.String())
+	}
+
+	var out struct {
+		Streams []struct {
+			Width    int    `json:"width"`
+			Height   int    `json:"height"`
+			Duration string `json:"duration"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(stdOut.Bytes(), &out); nil != err {
+		return nil, fmt.Errorf("decode ffprobe output: %v", err)
+	}
+
+	if len(out.Streams) == 0 {
+		return nil, errors.New("no video stream found")
+	}
+	stream := out.Streams[0]
+
+	duration, err := strconv.ParseFloat(stream.Duration, 64)
+	if nil != err {
+		return nil, fmt.Errorf("parse duration: %v", err)
+	}
+
+	return &types.StoredVideoCover{Width: stream.Width, Height: stream.Height, Duration: duration}, nil
+}
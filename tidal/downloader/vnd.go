@@ -1,7 +1,9 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5" //nolint:gosec
 	"errors"
 	"fmt"
 	"io"
@@ -12,17 +14,24 @@ import (
 
 	"github.com/rs/zerolog"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 
 	"github.com/xeptore/tidalgram/httputil"
 	"github.com/xeptore/tidalgram/mathutil"
+	"github.com/xeptore/tidalgram/pause"
+	"github.com/xeptore/tidalgram/ratelimit"
 	"github.com/xeptore/tidalgram/tidal/auth"
 )
 
 type VndTrackStream struct {
 	URL                      string
-	DownloadTimeout          time.Duration
-	GetTrackFileSizeTimeout  time.Duration
+	Client                   *httputil.Client
+	SegmentTimeout           time.Duration
+	FileSizeTimeout          time.Duration
 	VNDTrackPartsConcurrency int
+	CacheBaseURL             string
+	Bandwidth                *rate.Limiter
+	Gate                     *pause.Gate
 }
 
 func (v *VndTrackStream) saveTo(
@@ -31,8 +40,15 @@ func (v *VndTrackStream) saveTo(
 	accessToken string,
 	fileName string,
 ) (err error) {
-	fileSize, err := v.fileSize(ctx, logger, accessToken)
-	if nil != err {
+	var (
+		fileSize int
+		fileMD5  []byte
+	)
+	fetchSize := func(link string) (err error) {
+		fileSize, fileMD5, err = v.fileSize(ctx, logger, accessToken, link)
+		return err
+	}
+	if err := withCDNFallback(ctx, logger, v.CacheBaseURL, v.URL, nil, fetchSize); nil != err {
 		return fmt.Errorf("unexpected error while getting track file size: %w", err)
 	}
 
@@ -44,10 +60,14 @@ func (v *VndTrackStream) saveTo(
 		wg.Go(func() (err error) {
 			select {
 			case <-wgctx.Done():
-				return nil
+				return wgctx.Err()
 			default:
 			}
 
+			if err := v.Gate.Wait(wgctx); nil != err {
+				return fmt.Errorf("wait for paused job: %w", err)
+			}
+
 			logger := logger.With().Int("chunk_index", i).Logger()
 
 			start := i * singlePartChunkSize
@@ -73,7 +93,8 @@ func (v *VndTrackStream) saveTo(
 				}
 			}()
 
-			if err := v.downloadChunkRange(wgctx, logger, accessToken, start, end, f); nil != err {
+			fetch := func(link string) error { return v.downloadChunkRange(wgctx, logger, accessToken, link, start, end, f) }
+			if err := withCDNFallback(wgctx, logger, v.CacheBaseURL, v.URL, f, fetch); nil != err {
 				return fmt.Errorf("download track chunk %d: %w", i, err)
 			}
 
@@ -104,13 +125,27 @@ func (v *VndTrackStream) saveTo(
 		}
 	}()
 
+	// The track file is hashed while its chunks are written, to be checked against the MD5 of the stream.
+	hash := md5.New() //nolint:gosec
 	for i := range numChunks {
 		chunkFileName := fileName + ".chunk." + strconv.Itoa(i)
-		if err := writeChunkToTrackFile(f, logger, chunkFileName); nil != err {
+		if err := writeChunkToTrackFile(io.MultiWriter(f, hash), logger, chunkFileName); nil != err {
 			return fmt.Errorf("write track chunk %d to file: %v", i, err)
 		}
 	}
 
+	if written, err := f.Seek(0, io.SeekCurrent); nil != err {
+		return fmt.Errorf("get track file size: %v", err)
+	} else if written != int64(fileSize) {
+		logger.Warn().Int64("written", written).Int("expected", fileSize).Msg("Track file size mismatch")
+		return fmt.Errorf("%w: track file size is %d bytes, expected %d", ErrCorruptedTrack, written, fileSize)
+	}
+
+	if actual := hash.Sum(nil); nil != fileMD5 && !bytes.Equal(actual, fileMD5) {
+		logger.Warn().Hex("actual_md5", actual).Hex("expected_md5", fileMD5).Msg("Track file MD5 mismatch")
+		return fmt.Errorf("%w: track file MD5 is %x, expected %x", ErrCorruptedTrack, actual, fileMD5)
+	}
+
 	if err := f.Sync(); nil != err {
 		logger.Error().Err(err).Msg("Failed to sync track file")
 		return fmt.Errorf("sync track file: %v", err)
@@ -119,24 +154,25 @@ func (v *VndTrackStream) saveTo(
 	return nil
 }
 
+// fileSize returns the size of the track file at link, and its MD5 if the server advertises it.
 func (v *VndTrackStream) fileSize(
 	ctx context.Context,
 	logger zerolog.Logger,
 	accessToken string,
-) (size int, err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, v.URL, nil)
+	link string,
+) (size int, sum []byte, err error) {
+	req, err := http.NewRequestWithContext(ratelimit.WithClass(ctx, ratelimit.ClassMedia), http.MethodHead, link, nil)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to create get track metadata request")
-		return 0, fmt.Errorf("create get track metada request: %w", err)
+		return 0, nil, fmt.Errorf("create get track metada request: %w", err)
 	}
 
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	client := http.Client{Timeout: v.GetTrackFileSizeTimeout} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := v.Client.Do(req, v.FileSizeTimeout)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send get track file size request")
-		return 0, fmt.Errorf("send get track file size request: %w", err)
+		return 0, nil, fmt.Errorf("send get track file size request: %w", err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); nil != closeErr {
@@ -156,12 +192,12 @@ func (v *VndTrackStream) fileSize(
 			respBytes, readErr := io.ReadAll(resp.Body)
 			if nil != readErr {
 				logger.Error().Err(readErr).Msg("Failed to read 200 response body")
-				return 0, errors.Join(err, fmt.Errorf("read 200 response body: %w", readErr))
+				return 0, nil, errors.Join(err, fmt.Errorf("read 200 response body: %w", readErr))
 			}
 
 			logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 200 response")
 
-			return 0, errors.Join(
+			return 0, nil, errors.Join(
 				err,
 				fmt.Errorf(
 					"failed to parse 200 response content length header %q to int with response body: %s",
@@ -171,60 +207,62 @@ func (v *VndTrackStream) fileSize(
 			)
 		}
 
-		return size, nil
+		sum, _ := responseMD5(resp.Header)
+
+		return size, sum, nil
 	case http.StatusUnauthorized:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Msg("Failed to read 401 response body")
-			return 0, fmt.Errorf("read 401 response body: %w", err)
+			return 0, nil, fmt.Errorf("read 401 response body: %w", err)
 		}
 
 		if ok, err := httputil.IsTokenExpiredResponse(respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 401 response is token expired")
-			return 0, fmt.Errorf("check if 401 response is token expired: %v", err)
+			return 0, nil, fmt.Errorf("check if 401 response is token expired: %v", err)
 		} else if ok {
-			return 0, auth.ErrUnauthorized
+			return 0, nil, auth.ErrUnauthorized
 		}
 
 		if ok, err := httputil.IsTokenInvalidResponse(respBytes); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBytes).Msg("Failed to check if 401 response is token invalid")
-			return 0, fmt.Errorf("check if 401 response is token invalid: %v", err)
+			return 0, nil, fmt.Errorf("check if 401 response is token invalid: %v", err)
 		} else if ok {
-			return 0, auth.ErrUnauthorized
+			return 0, nil, auth.ErrUnauthorized
 		}
 
 		logger.Error().Bytes("response_body", respBytes).Msg("Unexpected 401 response")
 
-		return 0, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
+		return 0, nil, fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return 0, ErrTooManyRequests
+		return 0, nil, readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBody, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Msg("Failed to read 403 response body")
-			return 0, fmt.Errorf("read 403 response body: %w", err)
+			return 0, nil, fmt.Errorf("read 403 response body: %w", err)
 		}
 
 		if ok, err := httputil.IsTooManyErrorResponse(resp, respBody); nil != err {
 			logger.Error().Err(err).Bytes("response_body", respBody).Msg("Failed to check if 403 response is too many requests")
-			return 0, fmt.Errorf("check if 403 response is too many requests: %v", err)
+			return 0, nil, fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return 0, ErrTooManyRequests
+			return 0, nil, newRateLimitedError(resp, respBody)
 		}
 
 		logger.Error().Bytes("response_body", respBody).Msg("Unexpected 403 response")
 
-		return 0, fmt.Errorf("unexpected 403 response with body: %s", string(respBody))
+		return 0, nil, fmt.Errorf("unexpected 403 response with body: %s", string(respBody))
 	default:
 		respBytes, err := io.ReadAll(resp.Body)
 		if nil != err {
 			logger.Error().Err(err).Int("status_code", code).Msg("Failed to read response body")
-			return 0, fmt.Errorf("read response body: %w", err)
+			return 0, nil, fmt.Errorf("read response body: %w", err)
 		}
 
 		logger.Error().Int("status_code", code).Bytes("response_body", respBytes).Msg("Unexpected response status code")
 
-		return 0, fmt.Errorf("unexpected response code %d with body: %s", code, string(respBytes))
+		return 0, nil, fmt.Errorf("unexpected response code %d with body: %s", code, string(respBytes))
 	}
 }
 
@@ -240,10 +278,11 @@ func (v *VndTrackStream) downloadChunkRange(
 	ctx context.Context,
 	logger zerolog.Logger,
 	accessToken string,
+	link string,
 	start, end int,
 	f *os.File,
 ) (err error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.URL, nil)
+	req, err := http.NewRequestWithContext(ratelimit.WithClass(ctx, ratelimit.ClassMedia), http.MethodGet, link, nil)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to create get track chunk request")
 		return fmt.Errorf("create get track chunk request: %w", err)
@@ -252,8 +291,7 @@ func (v *VndTrackStream) downloadChunkRange(
 	req.Header.Add("Authorization", "Bearer "+accessToken)
 	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 
-	client := http.Client{Timeout: v.DownloadTimeout} //nolint:exhaustruct
-	resp, err := client.Do(req)
+	resp, err := v.Client.Do(req, v.SegmentTimeout)
 	if nil != err {
 		logger.Error().Err(err).Msg("Failed to send track chunk download request")
 		return fmt.Errorf("send track chunk download request: %w", err)
@@ -292,7 +330,7 @@ func (v *VndTrackStream) downloadChunkRange(
 
 		return fmt.Errorf("unexpected 401 response with body: %s", string(respBytes))
 	case http.StatusTooManyRequests:
-		return ErrTooManyRequests
+		return readRateLimitedError(logger, resp)
 	case http.StatusForbidden:
 		respBody, err := io.ReadAll(resp.Body)
 		if nil != err {
@@ -304,7 +342,7 @@ func (v *VndTrackStream) downloadChunkRange(
 			logger.Error().Err(err).Bytes("response_body", respBody).Msg("Failed to check if 403 response is too many requests")
 			return fmt.Errorf("check if 403 response is too many requests: %v", err)
 		} else if ok {
-			return ErrTooManyRequests
+			return newRateLimitedError(resp, respBody)
 		}
 
 		logger.Error().Bytes("response_body", respBody).Msg("Unexpected 403 response")
@@ -322,7 +360,7 @@ func (v *VndTrackStream) downloadChunkRange(
 		return fmt.Errorf("unexpected response code %d with body: %s", code, string(respBytes))
 	}
 
-	if n, err := io.Copy(f, resp.Body); nil != err {
+	if n, err := copyVerified(ctx, f, resp, v.Bandwidth); nil != err {
 		logger.Error().Err(err).Msg("Failed to write track chunk response body to file")
 		return fmt.Errorf("write track chunk response body to file: %w", err)
 	} else if n == 0 {
@@ -0,0 +1,77 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes the content written by write to a temporary file next to path, syncs it, and renames
+// it to path. Either the previous or the new content of path is visible at any time, so a crash never leaves
+// it partially written.
+func writeFileAtomic(path string, write func(w io.Writer) error) (err error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if nil != err {
+		return fmt.Errorf("create temporary file: %v", err)
+	}
+	tmpPath := f.Name()
+	defer func() {
+		if nil != err {
+			if removeErr := os.Remove(tmpPath); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+				err = errors.Join(err, fmt.Errorf("remove temporary file: %v", removeErr))
+			}
+		}
+	}()
+
+	if err := f.Chmod(0o600); nil != err {
+		return errors.Join(fmt.Errorf("change temporary file mode: %v", err), f.Close())
+	}
+
+	if err := write(f); nil != err {
+		return errors.Join(err, f.Close())
+	}
+
+	if err := f.Sync(); nil != err {
+		return errors.Join(fmt.Errorf("sync temporary file: %v", err), f.Close())
+	}
+
+	if err := f.Close(); nil != err {
+		return fmt.Errorf("close temporary file: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); nil != err {
+		return fmt.Errorf("rename temporary file: %v", err)
+	}
+
+	// The rename is only durable once the directory entry is synced.
+	if err := syncDir(dir); nil != err {
+		return fmt.Errorf("sync directory: %v", err)
+	}
+
+	return nil
+}
+
+func syncDir(path string) (err error) {
+	d, err := os.Open(path)
+	if nil != err {
+		return fmt.Errorf("open directory: %v", err)
+	}
+	defer func() {
+		if closeErr := d.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close directory: %v", closeErr))
+		}
+	}()
+
+	if err := d.Sync(); nil != err {
+		return fmt.Errorf("sync directory: %v", err)
+	}
+
+	return nil
+}
@@ -1,18 +1,26 @@
 package fs
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/goccy/go-json"
+
+	"github.com/xeptore/tidalgram/secret"
 )
 
-type AuthFile string
+// AuthFile is a Tidal account credentials file. It is encrypted if box is not nil.
+type AuthFile struct {
+	Path string
+	box  *secret.Box
+}
 
-func AuthFileFrom(dir, filename string) AuthFile {
-	return AuthFile(filepath.Join(dir, filename))
+func AuthFileFrom(dir, filename string, box *secret.Box) AuthFile {
+	return AuthFile{Path: filepath.Join(dir, filename), box: box}
 }
 
 type AuthFileContent struct {
@@ -22,48 +30,60 @@ type AuthFileContent struct {
 	CountryCode  string `json:"country_code"`
 }
 
-func (f AuthFile) Read() (c *AuthFileContent, err error) {
-	file, err := os.OpenFile(f.path(), os.O_RDONLY, 0o0600)
+// Read reads the credentials file. A plaintext file is encrypted in place if encryption is enabled, e.g., the
+// first time it is read after encryption was enabled.
+func (f AuthFile) Read() (*AuthFileContent, error) {
+	data, err := os.ReadFile(f.Path)
 	if nil != err {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, os.ErrNotExist
 		}
 
-		return nil, fmt.Errorf("open token file: %v", err)
+		return nil, fmt.Errorf("read token file: %v", err)
 	}
-	defer func() {
-		if closeErr := file.Close(); nil != closeErr {
-			err = errors.Join(err, fmt.Errorf("close token file: %v", closeErr))
-		}
-	}()
 
-	dec := json.NewDecoder(file)
+	plaintext, err := f.box.Open(data)
+	if nil != err {
+		return nil, fmt.Errorf("decrypt token file: %w", err)
+	}
+
+	var c *AuthFileContent
+	dec := json.NewDecoder(bytes.NewReader(plaintext))
 	dec.DisallowUnknownFields()
 	if err := dec.DecodeWithOption(&c, json.DecodeFieldPriorityFirstWin()); nil != err {
 		return nil, fmt.Errorf("decode token file contents: %v", err)
 	}
 
+	if f.box.Enabled() && !secret.IsSealed(data) && nil != c {
+		if err := f.Write(*c); nil != err {
+			return nil, fmt.Errorf("encrypt plaintext token file: %v", err)
+		}
+	}
+
 	return c, nil
 }
 
-func (f AuthFile) Write(c AuthFileContent) (err error) {
-	file, err := os.OpenFile(f.path(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_SYNC, 0o0600)
+func (f AuthFile) Write(c AuthFileContent) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).EncodeWithOption(c); nil != err {
+		return fmt.Errorf("encode token file: %v", err)
+	}
+
+	data, err := f.box.Seal(buf.Bytes())
 	if nil != err {
-		return fmt.Errorf("open token file: %v", err)
+		return fmt.Errorf("encrypt token file: %v", err)
 	}
-	defer func() {
-		if closeErr := file.Close(); nil != closeErr {
-			err = errors.Join(err, fmt.Errorf("close token file: %v", closeErr))
+
+	err = writeFileAtomic(f.Path, func(w io.Writer) error {
+		if _, err := w.Write(data); nil != err {
+			return fmt.Errorf("write token file contents: %v", err)
 		}
-	}()
 
-	if err := json.NewEncoder(file).EncodeWithOption(c); nil != err {
-		return fmt.Errorf("encode token file: %v", err)
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("write token file atomically: %v", err)
 	}
 
 	return nil
 }
-
-func (f AuthFile) path() string {
-	return string(f)
-}
@@ -0,0 +1,103 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"strings"
+)
+
+// scaledCoverQuality is the JPEG quality covers scaled down for embedding are encoded with.
+const scaledCoverQuality = 90
+
+// OriginalPath returns the path of the original cover, which is stored next to the cover only if the cover is
+// scaled down for embedding.
+func (c Cover) OriginalPath() string {
+	return strings.TrimSuffix(c.Path, ".jpg") + ".orig.jpg"
+}
+
+// HasOriginal reports whether the original cover is stored, i.e., the cover is scaled down.
+func (c Cover) HasOriginal() (bool, error) {
+	return fileExists(c.OriginalPath())
+}
+
+// WriteScaled writes b as the cover scaled down to fit in maxDim, and b itself as the original cover, if b is
+// larger than maxDim. Otherwise, or if maxDim is 0, it writes b as the cover, and removes the stored original
+// cover, if any.
+func (c Cover) WriteScaled(b []byte, maxDim int) error {
+	if maxDim == 0 {
+		return c.writeUnscaled(b)
+	}
+
+	if err := ValidateCover(b); nil != err {
+		return err
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(b))
+	if nil != err {
+		return fmt.Errorf("decode cover: %v", err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() <= maxDim && bounds.Dy() <= maxDim {
+		return c.writeUnscaled(b)
+	}
+
+	// The original is written first, so that a cover that is already downloaded always has its original.
+	original := Cover{Path: c.OriginalPath()}
+	if err := original.Write(b); nil != err {
+		return fmt.Errorf("write original cover: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(img, maxDim), &jpeg.Options{Quality: scaledCoverQuality}); nil != err {
+		return fmt.Errorf("encode scaled cover: %v", err)
+	}
+
+	if err := c.Write(buf.Bytes()); nil != err {
+		return fmt.Errorf("write scaled cover: %v", err)
+	}
+
+	return nil
+}
+
+func (c Cover) writeUnscaled(b []byte) error {
+	if err := c.Write(b); nil != err {
+		return err
+	}
+
+	if err := os.Remove(c.OriginalPath()); nil != err && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove original cover file: %v", err)
+	}
+
+	return nil
+}
+
+// CopyTo copies the cover, and its original, if stored, to dst, removing the original of dst otherwise.
+func (c Cover) CopyTo(dst Cover) error {
+	b, err := c.Read()
+	if nil != err {
+		return err
+	}
+
+	hasOriginal, err := c.HasOriginal()
+	if nil != err {
+		return fmt.Errorf("check original cover: %v", err)
+	}
+	if !hasOriginal {
+		return dst.writeUnscaled(b)
+	}
+
+	original, err := Cover{Path: c.OriginalPath()}.Read()
+	if nil != err {
+		return fmt.Errorf("read original cover: %v", err)
+	}
+
+	// The original is written first, so that a cover that is already downloaded always has its original.
+	if err := (Cover{Path: dst.OriginalPath()}).Write(original); nil != err {
+		return fmt.Errorf("write original cover: %v", err)
+	}
+
+	return dst.Write(b)
+}
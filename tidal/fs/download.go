@@ -1,10 +1,17 @@
 package fs
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"image/jpeg"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/goccy/go-json"
 
@@ -24,13 +31,42 @@ func (d DownloadsDir) Album(id string) Album {
 		DirPath:  dirPath,
 		InfoFile: InfoFile[types.StoredAlbum]{Path: filepath.Join(dirPath, id+".json")},
 		Cover:    Cover{Path: filepath.Join(dirPath, id+".jpg")},
+		VideoCover: VideoCover{
+			Path:     filepath.Join(dirPath, id+".cover.mp4"),
+			InfoFile: InfoFile[types.StoredVideoCover]{Path: filepath.Join(dirPath, id+".cover.json")},
+		},
 	}
 }
 
 type Album struct {
-	DirPath  string
-	InfoFile InfoFile[types.StoredAlbum]
-	Cover    Cover
+	DirPath    string
+	InfoFile   InfoFile[types.StoredAlbum]
+	Cover      Cover
+	VideoCover VideoCover
+}
+
+// VideoCover is the animated cover of an album, which only some albums have.
+type VideoCover struct {
+	Path     string
+	InfoFile InfoFile[types.StoredVideoCover]
+}
+
+// AlreadyDownloaded reports whether the video cover was completely downloaded. Its info file is written once the
+// video is.
+func (c VideoCover) AlreadyDownloaded() (bool, error) {
+	if exists, err := fileExists(c.Path); nil != err {
+		return false, fmt.Errorf("check if video cover file exists: %v", err)
+	} else if !exists {
+		return false, nil
+	}
+
+	if exists, err := fileExists(c.InfoFile.Path); nil != err {
+		return false, fmt.Errorf("check if video cover info file exists: %v", err)
+	} else if !exists {
+		return false, nil
+	}
+
+	return true, nil
 }
 
 func (a Album) Track(vol int, id string) AlbumTrack {
@@ -39,16 +75,19 @@ func (a Album) Track(vol int, id string) AlbumTrack {
 	return AlbumTrack{
 		Path:     trackPath,
 		InfoFile: InfoFile[types.StoredAlbumTrack]{Path: trackPath + ".json"},
+		Lyrics:   Lyrics{Path: trackPath + ".lrc"},
 	}
 }
 
 type AlbumTrack struct {
 	Path     string
 	InfoFile InfoFile[types.StoredAlbumTrack]
+	Lyrics   Lyrics
 }
 
+// AlreadyDownloaded reports whether the track was completely downloaded. See Track.AlreadyDownloaded.
 func (t AlbumTrack) AlreadyDownloaded() (bool, error) {
-	return fileExists(t.Path)
+	return trackComplete(t.Path, t.InfoFile.Path)
 }
 
 func (t AlbumTrack) Remove() error {
@@ -66,6 +105,7 @@ func (d DownloadsDir) Track(id string) Track {
 		Path:     trackPath,
 		InfoFile: InfoFile[types.StoredTrack]{Path: trackPath + ".json"},
 		Cover:    Cover{Path: trackPath + ".jpg"},
+		Lyrics:   Lyrics{Path: trackPath + ".lrc"},
 	}
 }
 
@@ -90,6 +130,7 @@ func (p Playlist) Track(id string) Track {
 		Path:     trackPath,
 		InfoFile: InfoFile[types.StoredTrack]{Path: trackPath + ".json"},
 		Cover:    Cover{Path: trackPath + ".jpg"},
+		Lyrics:   Lyrics{Path: trackPath + ".lrc"},
 	}
 }
 
@@ -114,6 +155,7 @@ func (m Mix) Track(id string) Track {
 		Path:     trackPath,
 		InfoFile: InfoFile[types.StoredTrack]{Path: trackPath + ".json"},
 		Cover:    Cover{Path: trackPath + ".jpg"},
+		Lyrics:   Lyrics{Path: trackPath + ".lrc"},
 	}
 }
 
@@ -142,15 +184,208 @@ func (m ArtistCredits) Track(id string) Track {
 		Path:     trackPath,
 		InfoFile: InfoFile[types.StoredTrack]{Path: trackPath + ".json"},
 		Cover:    Cover{Path: trackPath + ".jpg"},
+		Lyrics:   Lyrics{Path: trackPath + ".lrc"},
+	}
+}
+
+// Sync returns the sync mode state of a playlist or mix link.
+func (d DownloadsDir) Sync(link types.Link) Sync {
+	fileName := "sync-" + link.Kind.String() + "-" + link.ID + ".json"
+
+	return Sync{
+		InfoFile: InfoFile[types.StoredSync]{Path: filepath.Join(d.path(), fileName)},
+	}
+}
+
+type Sync struct {
+	InfoFile InfoFile[types.StoredSync]
+}
+
+// Read returns the stored sync state, or an empty state if the link was never synced before.
+func (s Sync) Read() (*types.StoredSync, error) {
+	if exists, err := fileExists(s.InfoFile.Path); nil != err {
+		return nil, fmt.Errorf("check if sync state file exists: %v", err)
+	} else if !exists {
+		return &types.StoredSync{TrackIDs: nil, SyncedAt: time.Time{}}, nil
+	}
+
+	state, err := s.InfoFile.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read sync state file: %v", err)
+	}
+
+	return state, nil
+}
+
+// debugBundlePattern matches the file names of debug bundles.
+const debugBundlePattern = "debug-*.zip"
+
+// DebugBundle returns the debug bundle of a track that failed to be tagged while downloading link.
+func (d DownloadsDir) DebugBundle(link types.Link, trackID string) DebugBundle {
+	fileName := "debug-" + link.Kind.String() + "-" + link.ID + "-" + trackID + ".zip"
+
+	return DebugBundle{Path: filepath.Join(d.path(), fileName)}
+}
+
+// DebugBundles returns all stored debug bundles.
+func (d DownloadsDir) DebugBundles() ([]DebugBundle, error) {
+	paths, err := filepath.Glob(filepath.Join(d.path(), debugBundlePattern))
+	if nil != err {
+		return nil, fmt.Errorf("glob debug bundles: %v", err)
+	}
+
+	out := make([]DebugBundle, len(paths))
+	for i, path := range paths {
+		out[i] = DebugBundle{Path: path}
+	}
+
+	return out, nil
+}
+
+// IsDebugBundle reports whether the file at path is a debug bundle, i.e., a job artifact rather than a
+// downloaded file.
+func IsDebugBundle(path string) bool {
+	ok, _ := filepath.Match(debugBundlePattern, filepath.Base(path))
+	return ok
+}
+
+type DebugBundle struct {
+	Path string
+}
+
+func (b DebugBundle) Exists() (bool, error) {
+	return fileExists(b.Path)
+}
+
+// Watchlist returns the list of links added using the watch command.
+func (d DownloadsDir) Watchlist() Watchlist {
+	return Watchlist{
+		InfoFile: InfoFile[types.StoredWatchlist]{Path: filepath.Join(d.path(), "watchlist.json")},
+	}
+}
+
+type Watchlist struct {
+	InfoFile InfoFile[types.StoredWatchlist]
+}
+
+// Read returns the stored watchlist, or an empty one if nothing was ever watched.
+func (w Watchlist) Read() (*types.StoredWatchlist, error) {
+	if exists, err := fileExists(w.InfoFile.Path); nil != err {
+		return nil, fmt.Errorf("check if watchlist file exists: %v", err)
+	} else if !exists {
+		return &types.StoredWatchlist{URLs: nil}, nil
+	}
+
+	list, err := w.InfoFile.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read watchlist file: %v", err)
+	}
+
+	return list, nil
+}
+
+// Maintenance returns the maintenance mode state set using the maintenance command.
+func (d DownloadsDir) Maintenance() Maintenance {
+	return Maintenance{
+		InfoFile: InfoFile[types.StoredMaintenance]{Path: filepath.Join(d.path(), "maintenance.json")},
+	}
+}
+
+type Maintenance struct {
+	InfoFile InfoFile[types.StoredMaintenance]
+}
+
+// Read returns the stored maintenance mode state, or a disabled one if it was never set.
+func (m Maintenance) Read() (*types.StoredMaintenance, error) {
+	if exists, err := fileExists(m.InfoFile.Path); nil != err {
+		return nil, fmt.Errorf("check if maintenance state file exists: %v", err)
+	} else if !exists {
+		return &types.StoredMaintenance{Enabled: false, Since: time.Time{}}, nil
+	}
+
+	state, err := m.InfoFile.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read maintenance state file: %v", err)
+	}
+
+	return state, nil
+}
+
+// PendingJob returns the job interrupted by the loss of the Telegram session, if any.
+func (d DownloadsDir) PendingJob() PendingJob {
+	return PendingJob{
+		InfoFile: InfoFile[types.StoredPendingJob]{Path: filepath.Join(d.path(), "pending-job.json")},
 	}
 }
 
+type PendingJob struct {
+	InfoFile InfoFile[types.StoredPendingJob]
+}
+
+// Read returns the stored pending job, or nil if there is none.
+func (j PendingJob) Read() (*types.StoredPendingJob, error) {
+	if exists, err := fileExists(j.InfoFile.Path); nil != err {
+		return nil, fmt.Errorf("check if pending job file exists: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+
+	job, err := j.InfoFile.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read pending job file: %v", err)
+	}
+
+	return job, nil
+}
+
+func (j PendingJob) Remove() error {
+	if err := os.Remove(j.InfoFile.Path); nil != err && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove pending job file: %v", err)
+	}
+
+	return nil
+}
+
+// MinCoverDimension is the minimum width and height of a valid cover image, in pixels.
+const MinCoverDimension = 80
+
+var ErrInvalidCover = errors.New("invalid cover image")
+
 type Cover struct {
 	Path string
 }
 
+// ValidateCover checks that b is a decodable JPEG image whose width and height are at least MinCoverDimension.
+func ValidateCover(b []byte) error {
+	if len(b) == 0 {
+		return fmt.Errorf("%w: empty", ErrInvalidCover)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(b))
+	if nil != err {
+		return fmt.Errorf("%w: decode: %v", ErrInvalidCover, err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() < MinCoverDimension || bounds.Dy() < MinCoverDimension {
+		return fmt.Errorf("%w: dimensions %dx%d are smaller than %dx%d", ErrInvalidCover, bounds.Dx(), bounds.Dy(), MinCoverDimension, MinCoverDimension)
+	}
+
+	return nil
+}
+
+// AlreadyDownloaded reports whether a valid cover is stored. An invalid stored cover is reported as
+// not downloaded, so that it is downloaded and written again.
 func (c Cover) AlreadyDownloaded() (bool, error) {
-	return fileExists(c.Path)
+	b, err := os.ReadFile(c.Path)
+	if nil != err {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("read cover file: %v", err)
+	}
+
+	return nil == ValidateCover(b), nil
 }
 
 func fileExists(path string) (bool, error) {
@@ -165,29 +400,20 @@ func fileExists(path string) (bool, error) {
 	return true, nil
 }
 
-func (c Cover) Write(b []byte) (err error) {
-	f, err := os.OpenFile(c.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_SYNC, 0o600)
-	if nil != err {
-		return fmt.Errorf("open cover file for write: %v", err)
+func (c Cover) Write(b []byte) error {
+	if err := ValidateCover(b); nil != err {
+		return err
 	}
-	defer func() {
-		if nil != err {
-			if removeErr := os.Remove(c.Path); nil != removeErr {
-				if !errors.Is(removeErr, os.ErrNotExist) {
-					err = errors.Join(err, fmt.Errorf("remove cover file: %v", removeErr))
-				}
-			}
-		} else if closeErr := f.Close(); nil != closeErr {
-			err = fmt.Errorf("close cover file: %v", closeErr)
-		}
-	}()
 
-	if _, err := f.Write(b); nil != err {
-		return fmt.Errorf("write cover file: %v", err)
-	}
+	err := writeFileAtomic(c.Path, func(w io.Writer) error {
+		if _, err := w.Write(b); nil != err {
+			return fmt.Errorf("write cover file: %v", err)
+		}
 
-	if err := f.Sync(); nil != err {
-		return fmt.Errorf("sync cover file: %v", err)
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("write cover file atomically: %v", err)
 	}
 
 	return nil
@@ -206,10 +432,69 @@ type Track struct {
 	Path     string
 	InfoFile InfoFile[types.StoredTrack]
 	Cover    Cover
+	Lyrics   Lyrics
 }
 
+// AlreadyDownloaded reports whether the track file and its info file are stored, and the track file matches
+// the size and hash recorded in the info file. A partially written or otherwise modified track file is
+// reported as not downloaded, so that it is downloaded again.
 func (t Track) AlreadyDownloaded() (bool, error) {
-	return fileExists(t.Path)
+	return trackComplete(t.Path, t.InfoFile.Path)
+}
+
+func trackComplete(trackPath, infoPath string) (bool, error) {
+	if exists, err := fileExists(trackPath); nil != err {
+		return false, fmt.Errorf("check if track file exists: %v", err)
+	} else if !exists {
+		return false, nil
+	}
+
+	// The info file is written once the track file is completely downloaded and tagged, so a missing info
+	// file means the previous download did not finish.
+	if exists, err := fileExists(infoPath); nil != err {
+		return false, fmt.Errorf("check if track info file exists: %v", err)
+	} else if !exists {
+		return false, nil
+	}
+
+	// A partially written info file can not be decoded, and is reported the same as a missing one.
+	info, err := InfoFile[types.Track]{Path: infoPath}.Read()
+	if nil != err {
+		return false, nil //nolint:nilerr
+	}
+
+	// Info files written before checksums were recorded are trusted as is.
+	if info.Size == 0 && info.SHA256 == "" {
+		return true, nil
+	}
+
+	size, sum, err := FileChecksum(trackPath)
+	if nil != err {
+		return false, fmt.Errorf("get track file checksum: %v", err)
+	}
+
+	return size == info.Size && sum == info.SHA256, nil
+}
+
+// FileChecksum returns the size and the hex-encoded SHA-256 hash of the file at path.
+func FileChecksum(path string) (size int64, sum string, err error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return 0, "", fmt.Errorf("open file: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+		}
+	}()
+
+	h := sha256.New()
+	size, err = io.Copy(h, f)
+	if nil != err {
+		return 0, "", fmt.Errorf("read file: %v", err)
+	}
+
+	return size, hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func (t Track) Remove() error {
@@ -220,6 +505,30 @@ func (t Track) Remove() error {
 	return nil
 }
 
+// Lyrics is the LRC file of a track with synced lyrics. It does not exist for tracks without synced lyrics.
+type Lyrics struct {
+	Path string
+}
+
+func (l Lyrics) Exists() (bool, error) {
+	return fileExists(l.Path)
+}
+
+func (l Lyrics) Write(lrc string) error {
+	err := writeFileAtomic(l.Path, func(w io.Writer) error {
+		if _, err := io.WriteString(w, lrc); nil != err {
+			return fmt.Errorf("write lyrics file: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return fmt.Errorf("write lyrics file atomically: %v", err)
+	}
+
+	return nil
+}
+
 type InfoFile[T any] struct {
 	Path string
 }
@@ -253,32 +562,308 @@ func (p InfoFile[T]) Write(v T) error {
 	return writeInfoFile(p, v)
 }
 
-func writeInfoFile[T any](file InfoFile[T], obj any) (err error) {
-	filePath := file.Path
+func writeInfoFile[T any](file InfoFile[T], obj any) error {
+	err := writeFileAtomic(file.Path, func(w io.Writer) error {
+		if err := json.NewEncoder(w).Encode(obj); nil != err {
+			return fmt.Errorf("write info content: %v", err)
+		}
 
-	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o0600)
+		return nil
+	})
 	if nil != err {
-		return fmt.Errorf("open info file for write: %v", err)
+		return fmt.Errorf("write info file atomically: %v", err)
 	}
-	defer func() {
+
+	return nil
+}
+
+// TrackFile returns the file of the downloaded track with id, whichever kind of link it was downloaded for.
+func (d DownloadsDir) TrackFile(id string) TrackFile {
+	trackPath := filepath.Join(d.path(), id)
+
+	return TrackFile{
+		Path:     trackPath,
+		InfoFile: InfoFile[types.Track]{Path: trackPath + ".json"},
+	}
+}
+
+// TrackFile is a downloaded track file. Its info file only holds the track fields shared by the info files of
+// all kinds of links.
+type TrackFile struct {
+	Path     string
+	InfoFile InfoFile[types.Track]
+}
+
+// TrackCount returns the number of tracks stored for a downloaded link.
+func (d DownloadsDir) TrackCount(link types.Link) (int, error) {
+	trackIDs, err := d.TrackIDs(link)
+	if nil != err {
+		return 0, err
+	}
+
+	return len(trackIDs), nil
+}
+
+// TrackIDs returns the IDs of the tracks stored for a downloaded link, in upload order.
+func (d DownloadsDir) TrackIDs(link types.Link) ([]string, error) {
+	switch link := link.StoredAs(); link.Kind {
+	case types.LinkKindTrack:
+		return []string{link.ID}, nil
+	case types.LinkKindAlbum:
+		info, err := d.Album(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read album info file: %v", err)
+		}
+
+		return slices.Concat(info.VolumeTrackIDs...), nil
+	case types.LinkKindPlaylist:
+		info, err := d.Playlist(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
+		}
+
+		return info.TrackIDs, nil
+	case types.LinkKindMix:
+		info, err := d.Mix(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+
+		return info.TrackIDs, nil
+	case types.LinkKindArtistCredits:
+		info, err := d.ArtistCredits(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read artist credits info file: %v", err)
+		}
+
+		return info.TrackIDs, nil
+	default:
+		return nil, nil
+	}
+}
+
+// DuplicateTrackIDs returns the IDs of the duplicate track occurrences that were skipped while downloading
+// a playlist or mix link. It returns no IDs for other link kinds.
+func (d DownloadsDir) DuplicateTrackIDs(link types.Link) ([]string, error) {
+	switch link := link.StoredAs(); link.Kind {
+	case types.LinkKindPlaylist:
+		info, err := d.Playlist(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
+		}
+
+		return info.DuplicateTrackIDs, nil
+	case types.LinkKindMix:
+		info, err := d.Mix(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+
+		return info.DuplicateTrackIDs, nil
+	default:
+		return nil, nil
+	}
+}
+
+// FilteredTracks returns the tracks that were skipped by the configured filters while downloading a playlist, or
+// mix link. It returns no tracks for other link kinds.
+func (d DownloadsDir) FilteredTracks(link types.Link) ([]types.StoredFilteredTrack, error) {
+	switch link := link.StoredAs(); link.Kind {
+	case types.LinkKindPlaylist:
+		info, err := d.Playlist(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
+		}
+
+		return info.FilteredTracks, nil
+	case types.LinkKindMix:
+		info, err := d.Mix(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+
+		return info.FilteredTracks, nil
+	default:
+		return nil, nil
+	}
+}
+
+// FailedTracks returns the tracks that failed to download, and were skipped while downloading a playlist, mix,
+// or artist credits link. It returns no tracks for other link kinds.
+func (d DownloadsDir) FailedTracks(link types.Link) ([]types.StoredFailedTrack, error) {
+	switch link := link.StoredAs(); link.Kind {
+	case types.LinkKindPlaylist:
+		info, err := d.Playlist(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
+		}
+
+		return info.FailedTracks, nil
+	case types.LinkKindMix:
+		info, err := d.Mix(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+
+		return info.FailedTracks, nil
+	case types.LinkKindArtistCredits:
+		info, err := d.ArtistCredits(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read artist credits info file: %v", err)
+		}
+
+		return info.FailedTracks, nil
+	default:
+		return nil, nil
+	}
+}
+
+// LinkFiles returns paths of all files stored for a downloaded link, including its info file, and the library
+// links of its tracks. It returns no paths if the link info file does not exist.
+func (d DownloadsDir) LinkFiles(link types.Link) ([]string, error) {
+	files, err := d.linkFiles(link)
+	if nil != err || len(files) == 0 {
+		return files, err
+	}
+
+	trackIDs, err := d.TrackIDs(link)
+	if nil != err {
+		return nil, fmt.Errorf("get link track ids: %v", err)
+	}
+
+	libraryFiles, err := d.Library().Paths(trackIDs)
+	if nil != err {
+		return nil, fmt.Errorf("get library links: %v", err)
+	}
+
+	return append(files, libraryFiles...), nil
+}
+
+func (d DownloadsDir) linkFiles(link types.Link) ([]string, error) {
+	trackFiles := func(t Track) []string {
+		return []string{t.Path, t.InfoFile.Path, t.Cover.Path, t.Cover.ThumbnailPath(), t.Cover.OriginalPath(), t.Lyrics.Path}
+	}
+
+	switch link := link.StoredAs(); link.Kind {
+	case types.LinkKindTrack:
+		track := d.Track(link.ID)
+		if exists, err := fileExists(track.InfoFile.Path); nil != err {
+			return nil, fmt.Errorf("check if track info file exists: %v", err)
+		} else if !exists {
+			return nil, nil
+		}
+
+		return trackFiles(track), nil
+	case types.LinkKindAlbum:
+		album := d.Album(link.ID)
+		if exists, err := fileExists(album.InfoFile.Path); nil != err {
+			return nil, fmt.Errorf("check if album info file exists: %v", err)
+		} else if !exists {
+			return nil, nil
+		}
+
+		info, err := album.InfoFile.Read()
 		if nil != err {
-			if removeErr := os.Remove(filePath); nil != removeErr {
-				if !errors.Is(removeErr, os.ErrNotExist) {
-					err = errors.Join(err, fmt.Errorf("remove info file: %v", removeErr))
-				}
+			return nil, fmt.Errorf("read album info file: %v", err)
+		}
+
+		out := []string{
+			album.InfoFile.Path,
+			album.Cover.Path,
+			album.Cover.ThumbnailPath(),
+			album.Cover.OriginalPath(),
+			album.VideoCover.Path,
+			album.VideoCover.InfoFile.Path,
+		}
+		for volIdx, trackIDs := range info.VolumeTrackIDs {
+			for _, trackID := range trackIDs {
+				track := album.Track(volIdx+1, trackID)
+				out = append(out, track.Path, track.InfoFile.Path, track.Lyrics.Path)
 			}
-		} else if closeErr := f.Close(); nil != closeErr {
-			err = fmt.Errorf("close info file: %v", closeErr)
 		}
-	}()
 
-	if err := json.NewEncoder(f).Encode(obj); nil != err {
-		return fmt.Errorf("write info content: %v", err)
+		return out, nil
+	case types.LinkKindPlaylist:
+		playlist := d.Playlist(link.ID)
+		if exists, err := fileExists(playlist.InfoFile.Path); nil != err {
+			return nil, fmt.Errorf("check if playlist info file exists: %v", err)
+		} else if !exists {
+			return nil, nil
+		}
+
+		info, err := playlist.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
+		}
+
+		out := []string{playlist.InfoFile.Path}
+		for _, trackID := range info.TrackIDs {
+			out = append(out, trackFiles(playlist.Track(trackID))...)
+		}
+
+		return out, nil
+	case types.LinkKindMix:
+		mix := d.Mix(link.ID)
+		if exists, err := fileExists(mix.InfoFile.Path); nil != err {
+			return nil, fmt.Errorf("check if mix info file exists: %v", err)
+		} else if !exists {
+			return nil, nil
+		}
+
+		info, err := mix.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+
+		out := []string{mix.InfoFile.Path}
+		for _, trackID := range info.TrackIDs {
+			out = append(out, trackFiles(mix.Track(trackID))...)
+		}
+
+		return out, nil
+	case types.LinkKindArtistCredits:
+		credits := d.ArtistCredits(link.ID)
+		if exists, err := fileExists(credits.InfoFile.Path); nil != err {
+			return nil, fmt.Errorf("check if artist credits info file exists: %v", err)
+		} else if !exists {
+			return nil, nil
+		}
+
+		info, err := credits.InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read artist credits info file: %v", err)
+		}
+
+		out := []string{credits.InfoFile.Path}
+		for _, trackID := range info.TrackIDs {
+			out = append(out, trackFiles(credits.Track(trackID))...)
+		}
+
+		return out, nil
+	default:
+		return nil, nil
+	}
+}
+
+// LinkSize returns the total size in bytes of the files stored for a downloaded link.
+func (d DownloadsDir) LinkSize(link types.Link) (int64, error) {
+	files, err := d.LinkFiles(link)
+	if nil != err {
+		return 0, fmt.Errorf("get link files: %v", err)
 	}
 
-	if err := f.Sync(); nil != err {
-		return fmt.Errorf("sync info file: %v", err)
+	var size int64
+	for _, f := range files {
+		info, err := os.Lstat(f)
+		if nil != err {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return 0, fmt.Errorf("stat file: %v", err)
+		}
+		size += info.Size()
 	}
 
-	return nil
+	return size, nil
 }
@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Library returns the tree of human-readable links to the downloaded tracks.
+func (d DownloadsDir) Library() Library {
+	return Library{
+		DirPath:  filepath.Join(d.path(), "library"),
+		InfoFile: InfoFile[types.StoredLibrary]{Path: filepath.Join(d.path(), "library.json")},
+	}
+}
+
+// Library holds hard links to the downloaded tracks, named by the configured naming scheme. Tracks are still
+// stored by their IDs, and its info file maps them to their library paths.
+type Library struct {
+	DirPath  string
+	InfoFile InfoFile[types.StoredLibrary]
+}
+
+// Read returns the stored library index, or an empty one if no track was linked to the library yet.
+func (l Library) Read() (*types.StoredLibrary, error) {
+	if exists, err := fileExists(l.InfoFile.Path); nil != err {
+		return nil, fmt.Errorf("check if library info file exists: %v", err)
+	} else if !exists {
+		return &types.StoredLibrary{Paths: make(map[string]string)}, nil
+	}
+
+	index, err := l.InfoFile.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read library info file: %v", err)
+	}
+	if nil == index.Paths {
+		index.Paths = make(map[string]string)
+	}
+
+	return index, nil
+}
+
+// Link links the track file at trackPath with id to relPath inside the library, and records it in index. The
+// previous link of the track is removed if it is at another path, e.g., if the naming scheme was changed.
+func (l Library) Link(index *types.StoredLibrary, id, trackPath, relPath string) error {
+	if prev, ok := index.Paths[id]; ok && prev != relPath {
+		if err := os.Remove(filepath.Join(l.DirPath, prev)); nil != err && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove previous library link: %v", err)
+		}
+	}
+
+	linkPath := filepath.Join(l.DirPath, relPath)
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0o0755); nil != err {
+		return fmt.Errorf("create library directory: %v", err)
+	}
+
+	// The track may have been downloaded again since it was linked, which replaces its file.
+	if err := os.Remove(linkPath); nil != err && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove existing library link: %v", err)
+	}
+
+	if err := os.Link(trackPath, linkPath); nil != err {
+		// Hard links are not supported by some file systems, e.g., network ones.
+		if err := copyFile(trackPath, linkPath); nil != err {
+			return fmt.Errorf("copy track file to library: %v", err)
+		}
+	}
+	index.Paths[id] = relPath
+
+	return nil
+}
+
+// Paths returns the library paths of the tracks with ids that were linked to the library.
+func (l Library) Paths(ids []string) ([]string, error) {
+	if exists, err := fileExists(l.InfoFile.Path); nil != err {
+		return nil, fmt.Errorf("check if library info file exists: %v", err)
+	} else if !exists {
+		return nil, nil
+	}
+
+	index, err := l.Read()
+	if nil != err {
+		return nil, err
+	}
+
+	var out []string
+	for _, id := range ids {
+		if relPath, ok := index.Paths[id]; ok {
+			out = append(out, filepath.Join(l.DirPath, relPath))
+		}
+	}
+
+	return out, nil
+}
+
+// NamedTrack is a downloaded track file with the path it is named by outside the downloads directory.
+type NamedTrack struct {
+	ID      string
+	Path    string
+	RelPath string
+}
+
+// NamedTracks returns the downloaded tracks of link, named by their library paths if they are linked to the
+// library, and put in a directory named after link otherwise.
+func (d DownloadsDir) NamedTracks(link types.Link) ([]NamedTrack, error) {
+	trackIDs, err := d.TrackIDs(link)
+	if nil != err {
+		return nil, fmt.Errorf("get link track ids: %v", err)
+	}
+
+	index, err := d.Library().Read()
+	if nil != err {
+		return nil, fmt.Errorf("read library index: %v", err)
+	}
+
+	out := make([]NamedTrack, 0, len(trackIDs))
+	for _, trackID := range trackIDs {
+		track := d.TrackFile(trackID)
+
+		relPath, ok := index.Paths[trackID]
+		if !ok {
+			info, err := track.InfoFile.Read()
+			if nil != err {
+				return nil, fmt.Errorf("read track %s info file: %v", trackID, err)
+			}
+			relPath = filepath.Join(link.Kind.String()+"-"+link.ID, strings.ReplaceAll(info.UploadFilename(), "/", "_"))
+		}
+
+		out = append(out, NamedTrack{ID: trackID, Path: track.Path, RelPath: relPath})
+	}
+
+	return out, nil
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if nil != err {
+		return fmt.Errorf("open source file: %v", err)
+	}
+	defer func() {
+		if closeErr := in.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close source file: %v", closeErr))
+		}
+	}()
+
+	return writeFileAtomic(dst, func(w io.Writer) error {
+		if _, err := io.Copy(w, in); nil != err {
+			return fmt.Errorf("copy file content: %v", err)
+		}
+
+		return nil
+	})
+}
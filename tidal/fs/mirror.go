@@ -0,0 +1,38 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+// Mirror returns the state of the uploaded links mirrored to the storage backend.
+func (d DownloadsDir) Mirror() Mirror {
+	return Mirror{
+		InfoFile: InfoFile[types.StoredMirror]{Path: filepath.Join(d.path(), "mirror.json")},
+	}
+}
+
+type Mirror struct {
+	InfoFile InfoFile[types.StoredMirror]
+}
+
+// Read returns the stored mirror state, or an empty one if no link was mirrored yet.
+func (m Mirror) Read() (*types.StoredMirror, error) {
+	if exists, err := fileExists(m.InfoFile.Path); nil != err {
+		return nil, fmt.Errorf("check if mirror state file exists: %v", err)
+	} else if !exists {
+		return &types.StoredMirror{Links: make(map[string]types.MirroredLink)}, nil
+	}
+
+	state, err := m.InfoFile.Read()
+	if nil != err {
+		return nil, fmt.Errorf("read mirror state file: %v", err)
+	}
+	if nil == state.Links {
+		state.Links = make(map[string]types.MirroredLink)
+	}
+
+	return state, nil
+}
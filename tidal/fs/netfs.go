@@ -0,0 +1,118 @@
+package fs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const mountInfoPath = "/proc/self/mountinfo"
+
+// networkFSTypes are the filesystem types, as listed in mountinfo, that are backed by a remote server.
+var networkFSTypes = map[string]struct{}{
+	"nfs":         {},
+	"nfs4":        {},
+	"cifs":        {},
+	"smb3":        {},
+	"smbfs":       {},
+	"9p":          {},
+	"afs":         {},
+	"ceph":        {},
+	"glusterfs":   {},
+	"lustre":      {},
+	"davfs":       {},
+	"fuse.sshfs":  {},
+	"fuse.rclone": {},
+	"fuse.s3fs":   {},
+}
+
+// Filesystem is the filesystem a directory is on.
+type Filesystem struct {
+	// Type is the filesystem type, e.g., ext4, or nfs4. It is empty if the type could not be detected.
+	Type       string
+	MountPoint string
+	Network    bool
+}
+
+// DetectFilesystem returns the filesystem the directory at path is mounted on. It only detects filesystems
+// on Linux, and returns an empty filesystem on other platforms.
+func DetectFilesystem(path string) (out *Filesystem, err error) {
+	abs, err := filepath.Abs(path)
+	if nil != err {
+		return nil, fmt.Errorf("get absolute path: %v", err)
+	}
+
+	abs, err = filepath.EvalSymlinks(abs)
+	if nil != err {
+		return nil, fmt.Errorf("resolve symlinks: %v", err)
+	}
+
+	f, err := os.Open(mountInfoPath)
+	if nil != err {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Filesystem{Type: "", MountPoint: "", Network: false}, nil
+		}
+
+		return nil, fmt.Errorf("open mountinfo: %v", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); nil != closeErr {
+			err = errors.Join(err, fmt.Errorf("close mountinfo: %v", closeErr))
+		}
+	}()
+
+	out = &Filesystem{Type: "", MountPoint: "", Network: false}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		mountPoint, fsType, ok := parseMountInfoLine(scanner.Text())
+		if !ok || !isWithin(abs, mountPoint) {
+			continue
+		}
+
+		// Mounts are listed in mount order, so the deepest of them, mounted last, is the one in effect.
+		if len(mountPoint) >= len(out.MountPoint) {
+			out.MountPoint = mountPoint
+			out.Type = fsType
+		}
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, fmt.Errorf("read mountinfo: %v", err)
+	}
+
+	_, out.Network = networkFSTypes[out.Type]
+
+	return out, nil
+}
+
+// parseMountInfoLine returns the mount point and the filesystem type of a mountinfo line, e.g.,
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue.
+func parseMountInfoLine(line string) (mountPoint, fsType string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return "", "", false
+	}
+
+	for i := 5; i < len(fields)-1; i++ {
+		if fields[i] == "-" {
+			return unescapeMountInfo(fields[4]), fields[i+1], true
+		}
+	}
+
+	return "", "", false
+}
+
+// unescapeMountInfo decodes the octal escapes mountinfo uses for spaces, tabs, newlines, and backslashes.
+func unescapeMountInfo(s string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(s)
+}
+
+func isWithin(path, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
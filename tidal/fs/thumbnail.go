@@ -0,0 +1,140 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// MaxThumbnailDimension is the maximum width and height of document thumbnails accepted by Telegram.
+	MaxThumbnailDimension = 320
+	// maxThumbnailSize is the maximum size of document thumbnails accepted by Telegram.
+	maxThumbnailSize = 200 * 1024
+)
+
+// thumbnailQualities are the JPEG qualities thumbnails are encoded with, in order, until one fits in
+// maxThumbnailSize.
+var thumbnailQualities = []int{90, 80, 70, 55, 40}
+
+var errThumbnailTooLarge = errors.New("thumbnail is too large at all qualities")
+
+// ThumbnailPath returns the path of the thumbnail of the cover, which is uploaded as the thumbnail of track
+// documents instead of the full-size cover that is embedded in the tracks.
+func (c Cover) ThumbnailPath() string {
+	return strings.TrimSuffix(c.Path, ".jpg") + ".thumb.jpg"
+}
+
+// Thumbnail returns the path of the cover thumbnail, a JPEG image that fits in MaxThumbnailDimension, creating
+// it if it does not exist, or is older than the cover.
+func (c Cover) Thumbnail() (string, error) {
+	path := c.ThumbnailPath()
+
+	coverStat, err := os.Stat(c.Path)
+	if nil != err {
+		return "", fmt.Errorf("stat cover file: %v", err)
+	}
+
+	if thumbStat, err := os.Stat(path); nil == err && !thumbStat.ModTime().Before(coverStat.ModTime()) {
+		return path, nil
+	} else if nil != err && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("stat thumbnail file: %v", err)
+	}
+
+	cover, err := c.Read()
+	if nil != err {
+		return "", err
+	}
+
+	thumb, err := makeThumbnail(cover)
+	if nil != err {
+		return "", fmt.Errorf("make thumbnail: %v", err)
+	}
+
+	err = writeFileAtomic(path, func(w io.Writer) error {
+		if _, err := w.Write(thumb); nil != err {
+			return fmt.Errorf("write thumbnail file: %v", err)
+		}
+
+		return nil
+	})
+	if nil != err {
+		return "", fmt.Errorf("write thumbnail file atomically: %v", err)
+	}
+
+	return path, nil
+}
+
+// makeThumbnail returns the JPEG cover scaled down to fit in MaxThumbnailDimension, encoded in the highest
+// quality that fits in maxThumbnailSize.
+func makeThumbnail(cover []byte) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(cover))
+	if nil != err {
+		return nil, fmt.Errorf("decode cover: %v", err)
+	}
+
+	img = scaleDown(img, MaxThumbnailDimension)
+
+	var buf bytes.Buffer
+	for _, quality := range thumbnailQualities {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); nil != err {
+			return nil, fmt.Errorf("encode thumbnail: %v", err)
+		}
+
+		if buf.Len() <= maxThumbnailSize {
+			return buf.Bytes(), nil
+		}
+	}
+
+	return nil, errThumbnailTooLarge
+}
+
+// scaleDown returns img scaled down to fit in maxDim, keeping its aspect ratio, by averaging the source pixels
+// each destination pixel covers. img is returned as is if it already fits.
+func scaleDown(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxDim && srcH <= maxDim {
+		return img
+	}
+
+	dstW, dstH := maxDim, maxDim
+	if srcW > srcH {
+		dstH = max(1, srcH*maxDim/srcW)
+	} else if srcH > srcW {
+		dstW = max(1, srcW*maxDim/srcH)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := range dstH {
+		y0, y1 := y*srcH/dstH, max((y+1)*srcH/dstH, y*srcH/dstH+1)
+		for x := range dstW {
+			x0, x1 := x*srcW/dstW, max((x+1)*srcW/dstW, x*srcW/dstW+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n) //nolint:gosec
+		}
+	}
+
+	return dst
+}
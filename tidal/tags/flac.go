@@ -0,0 +1,225 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"os"
+	"strings"
+)
+
+var flacMagic = []byte("fLaC")
+
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockPadding       = 1
+	flacBlockVorbisComment = 4
+	flacBlockPicture       = 6
+
+	flacMaxBlockSize = 1<<24 - 1
+	// flacPadding is the padding reserved after the metadata blocks when the file has to be rewritten,
+	// so that the tags can be written in place the next time.
+	flacPadding = 8192
+	// flacFrontCover is the PICTURE block type of the front cover.
+	flacFrontCover = 3
+	flacVendor     = "tidalgram"
+)
+
+// vorbisCommentKeys maps ffmpeg metadata keys to Vorbis comment field names. Keys not listed are uppercased.
+var vorbisCommentKeys = map[string]string{
+	"album_artist":   "ALBUMARTIST",
+	"lead_performer": "PERFORMER",
+	"track":          "TRACKNUMBER",
+	"disc":           "DISCNUMBER",
+}
+
+type flacBlock struct {
+	typ  byte
+	data []byte
+}
+
+// writeFLAC replaces the Vorbis comment and PICTURE blocks of the FLAC file at path. The metadata is written
+// in place if it fits in the space of the existing metadata and padding blocks, otherwise the file is rewritten.
+func writeFLAC(path string, tags []Tag, cover []byte) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if nil != err {
+		return fmt.Errorf("open file: %v", err)
+	}
+
+	blocks, audioOffset, err := readFLACBlocks(f)
+	if nil != err {
+		_ = f.Close()
+		return err
+	}
+
+	kept := make([]flacBlock, 0, len(blocks)+2)
+	for _, b := range blocks {
+		switch b.typ {
+		case flacBlockVorbisComment, flacBlockPicture, flacBlockPadding:
+		default:
+			kept = append(kept, b)
+		}
+	}
+	kept = append(kept, flacBlock{typ: flacBlockVorbisComment, data: vorbisComment(tags)})
+	if len(cover) > 0 {
+		picture, err := flacPicture(cover)
+		if nil != err {
+			_ = f.Close()
+			return fmt.Errorf("create picture block: %v", err)
+		}
+		kept = append(kept, flacBlock{typ: flacBlockPicture, data: picture})
+	}
+
+	size := int64(len(flacMagic))
+	for _, b := range kept {
+		if len(b.data) > flacMaxBlockSize {
+			_ = f.Close()
+			return fmt.Errorf("metadata block of type %d is too large", b.typ)
+		}
+		size += 4 + int64(len(b.data))
+	}
+
+	// Metadata can be written in place if it fills the existing metadata space exactly, or leaves room for
+	// a padding block.
+	if free := audioOffset - size; free == 0 || free >= 4 {
+		if free > 0 {
+			kept = append(kept, flacBlock{typ: flacBlockPadding, data: make([]byte, free-4)})
+		}
+
+		if _, err := f.WriteAt(encodeFLACMetadata(kept), 0); nil != err {
+			_ = f.Close()
+			return fmt.Errorf("write metadata: %v", err)
+		}
+
+		if err := f.Sync(); nil != err {
+			_ = f.Close()
+			return fmt.Errorf("sync file: %v", err)
+		}
+
+		if err := f.Close(); nil != err {
+			return fmt.Errorf("close file: %v", err)
+		}
+
+		return nil
+	}
+
+	kept = append(kept, flacBlock{typ: flacBlockPadding, data: make([]byte, flacPadding)})
+	err = replaceFile(path, func(w io.Writer) error {
+		if _, err := w.Write(encodeFLACMetadata(kept)); nil != err {
+			return fmt.Errorf("write metadata: %v", err)
+		}
+
+		if _, err := io.Copy(w, io.NewSectionReader(f, audioOffset, 1<<62)); nil != err {
+			return fmt.Errorf("copy audio frames: %v", err)
+		}
+
+		return nil
+	})
+	if closeErr := f.Close(); nil != closeErr {
+		err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+	}
+
+	return err
+}
+
+// readFLACBlocks returns the metadata blocks of the FLAC file r, and the offset of its first audio frame.
+func readFLACBlocks(r io.ReaderAt) ([]flacBlock, int64, error) {
+	var (
+		blocks []flacBlock
+		offset = int64(len(flacMagic))
+		header = make([]byte, 4)
+	)
+	for {
+		if _, err := r.ReadAt(header, offset); nil != err {
+			return nil, 0, fmt.Errorf("read metadata block header: %v", err)
+		}
+
+		var (
+			last = header[0]&0x80 != 0
+			typ  = header[0] & 0x7f
+			size = int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		)
+
+		data := make([]byte, size)
+		if _, err := r.ReadAt(data, offset+4); nil != err {
+			return nil, 0, fmt.Errorf("read metadata block of type %d: %v", typ, err)
+		}
+		blocks = append(blocks, flacBlock{typ: typ, data: data})
+		offset += 4 + size
+
+		if last {
+			break
+		}
+	}
+
+	if len(blocks) == 0 || blocks[0].typ != flacBlockStreamInfo {
+		return nil, 0, errors.New("first metadata block is not stream info")
+	}
+
+	return blocks, offset, nil
+}
+
+func encodeFLACMetadata(blocks []flacBlock) []byte {
+	var buf bytes.Buffer
+	buf.Write(flacMagic)
+	for i, b := range blocks {
+		typ := b.typ
+		if i == len(blocks)-1 {
+			typ |= 0x80
+		}
+		size := len(b.data)
+		buf.Write([]byte{typ, byte(size >> 16), byte(size >> 8), byte(size)})
+		buf.Write(b.data)
+	}
+
+	return buf.Bytes()
+}
+
+func vorbisComment(tags []Tag) []byte {
+	var buf bytes.Buffer
+	writeLE := func(s string) {
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(s))) //nolint:gosec
+		buf.WriteString(s)
+	}
+
+	writeLE(flacVendor)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(tags))) //nolint:gosec
+	for _, t := range tags {
+		key, ok := vorbisCommentKeys[t.Key]
+		if !ok {
+			key = strings.ToUpper(t.Key)
+		}
+		writeLE(key + "=" + t.Value)
+	}
+
+	return buf.Bytes()
+}
+
+func flacPicture(cover []byte) ([]byte, error) {
+	conf, err := jpeg.DecodeConfig(bytes.NewReader(cover))
+	if nil != err {
+		return nil, fmt.Errorf("decode cover: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writeBE := func(v int) {
+		_ = binary.Write(&buf, binary.BigEndian, uint32(v)) //nolint:gosec
+	}
+
+	const mime = "image/jpeg"
+	writeBE(flacFrontCover)
+	writeBE(len(mime))
+	buf.WriteString(mime)
+	writeBE(0) // description length
+	writeBE(conf.Width)
+	writeBE(conf.Height)
+	writeBE(24) // color depth
+	writeBE(0)  // number of indexed colors
+	writeBE(len(cover))
+	buf.Write(cover)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,397 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	mp4DataTypeImplicit = 0
+	mp4DataTypeUTF8     = 1
+	mp4DataTypeJPEG     = 13
+	mp4FreeformMean     = "com.apple.iTunes"
+)
+
+// mp4TextAtoms maps ffmpeg metadata keys to the iTunes metadata atoms they are written to. Keys that are
+// not listed, and are not track or disc numbers, are written as freeform atoms named after the uppercased key.
+var mp4TextAtoms = map[string]string{
+	"title":        "\xa9nam",
+	"artist":       "\xa9ART",
+	"album_artist": "aART",
+	"album":        "\xa9alb",
+	"copyright":    "cprt",
+	"date":         "\xa9day",
+	"lyrics":       "\xa9lyr",
+	"composer":     "\xa9wrt",
+}
+
+// mp4SkippedKeys are not written, as their values are already included in other atoms.
+var mp4SkippedKeys = map[string]struct{}{
+	"year":       {},
+	"tracktotal": {},
+	"disctotal":  {},
+}
+
+type mp4Box struct {
+	typ     string
+	payload []byte
+}
+
+func (b mp4Box) encode() []byte {
+	out := make([]byte, 8, 8+len(b.payload))
+	binary.BigEndian.PutUint32(out, uint32(8+len(b.payload))) //nolint:gosec
+	copy(out[4:], b.typ)
+
+	return append(out, b.payload...)
+}
+
+func encodeMP4Boxes(boxes []mp4Box) []byte {
+	var buf bytes.Buffer
+	for _, b := range boxes {
+		buf.Write(b.encode())
+	}
+
+	return buf.Bytes()
+}
+
+// mp4BoxHeader is the location of a box within a file.
+type mp4BoxHeader struct {
+	typ    string
+	offset int64
+	size   int64
+}
+
+// writeMP4 replaces the iTunes metadata list of the MP4 file at path. If the movie box is the last box of the
+// file, it is rewritten in place, otherwise the file is rewritten with its chunk offsets shifted accordingly.
+func writeMP4(path string, tags []Tag, cover []byte) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if nil != err {
+		return fmt.Errorf("open file: %v", err)
+	}
+
+	err = rewriteMP4(path, f, tags, cover)
+	if closeErr := f.Close(); nil != closeErr {
+		err = errors.Join(err, fmt.Errorf("close file: %v", closeErr))
+	}
+
+	return err
+}
+
+func rewriteMP4(path string, f *os.File, tags []Tag, cover []byte) error {
+	stat, err := f.Stat()
+	if nil != err {
+		return fmt.Errorf("stat file: %v", err)
+	}
+	fileSize := stat.Size()
+
+	headers, err := readMP4BoxHeaders(f, fileSize)
+	if nil != err {
+		return err
+	}
+
+	moovIdx := -1
+	for i, h := range headers {
+		switch h.typ {
+		case "moof":
+			return fmt.Errorf("%w: fragmented MP4", ErrUnsupported)
+		case "moov":
+			moovIdx = i
+		}
+	}
+	if moovIdx == -1 {
+		return errors.New("movie box not found")
+	}
+	moov := headers[moovIdx]
+
+	moovBytes := make([]byte, moov.size)
+	if _, err := f.ReadAt(moovBytes, moov.offset); nil != err {
+		return fmt.Errorf("read movie box: %v", err)
+	}
+
+	headerSize := int64(8)
+	if binary.BigEndian.Uint32(moovBytes) == 1 {
+		headerSize = 16
+	}
+	children, err := parseMP4Boxes(moovBytes[headerSize:])
+	if nil != err {
+		return fmt.Errorf("parse movie box: %v", err)
+	}
+
+	newChildren, err := withMetadata(children, tags, cover)
+	if nil != err {
+		return err
+	}
+
+	if moov.offset+moov.size == fileSize {
+		newMoov := mp4Box{typ: "moov", payload: encodeMP4Boxes(newChildren)}.encode()
+		if _, err := f.WriteAt(newMoov, moov.offset); nil != err {
+			return fmt.Errorf("write movie box: %v", err)
+		}
+
+		if err := f.Truncate(moov.offset + int64(len(newMoov))); nil != err {
+			return fmt.Errorf("truncate file: %v", err)
+		}
+
+		if err := f.Sync(); nil != err {
+			return fmt.Errorf("sync file: %v", err)
+		}
+
+		return nil
+	}
+
+	// Media data that comes after the movie box moves by the size difference of the movie box.
+	delta := int64(len(encodeMP4Boxes(newChildren))) + 8 - moov.size
+	if err := shiftChunkOffsets(newChildren, moov.offset+moov.size, delta); nil != err {
+		return err
+	}
+	newMoov := mp4Box{typ: "moov", payload: encodeMP4Boxes(newChildren)}.encode()
+
+	return replaceFile(path, func(w io.Writer) error {
+		if _, err := io.Copy(w, io.NewSectionReader(f, 0, moov.offset)); nil != err {
+			return fmt.Errorf("copy boxes before movie box: %v", err)
+		}
+
+		if _, err := w.Write(newMoov); nil != err {
+			return fmt.Errorf("write movie box: %v", err)
+		}
+
+		end := moov.offset + moov.size
+		if _, err := io.Copy(w, io.NewSectionReader(f, end, fileSize-end)); nil != err {
+			return fmt.Errorf("copy boxes after movie box: %v", err)
+		}
+
+		return nil
+	})
+}
+
+func readMP4BoxHeaders(r io.ReaderAt, fileSize int64) ([]mp4BoxHeader, error) {
+	var (
+		out    []mp4BoxHeader
+		header = make([]byte, 16)
+	)
+	for offset := int64(0); offset < fileSize; {
+		if _, err := r.ReadAt(header[:8], offset); nil != err {
+			return nil, fmt.Errorf("read box header: %v", err)
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		typ := string(header[4:8])
+		switch size {
+		case 0:
+			size = fileSize - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); nil != err {
+				return nil, fmt.Errorf("read box large size: %v", err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16])) //nolint:gosec
+		}
+		if size < 8 || offset+size > fileSize {
+			return nil, fmt.Errorf("invalid size %d of box %q", size, typ)
+		}
+
+		out = append(out, mp4BoxHeader{typ: typ, offset: offset, size: size})
+		offset += size
+	}
+
+	return out, nil
+}
+
+func parseMP4Boxes(b []byte) ([]mp4Box, error) {
+	var out []mp4Box
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errors.New("truncated box header")
+		}
+
+		size := uint64(binary.BigEndian.Uint32(b))
+		typ := string(b[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return nil, errors.New("truncated box large size")
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(b)) {
+			return nil, fmt.Errorf("invalid size %d of box %q", size, typ)
+		}
+
+		out = append(out, mp4Box{typ: typ, payload: b[headerSize:size]})
+		b = b[size:]
+	}
+
+	return out, nil
+}
+
+// withMetadata returns the movie box children with the user data metadata replaced by tags and cover.
+func withMetadata(children []mp4Box, tags []Tag, cover []byte) ([]mp4Box, error) {
+	ilst, err := mp4ItemList(tags, cover)
+	if nil != err {
+		return nil, err
+	}
+
+	hdlr := make([]byte, 25)
+	copy(hdlr[8:], "mdir")
+	copy(hdlr[12:], "appl")
+	meta := mp4Box{
+		typ: "meta",
+		payload: append(
+			[]byte{0, 0, 0, 0},
+			encodeMP4Boxes([]mp4Box{{typ: "hdlr", payload: hdlr}, {typ: "ilst", payload: ilst}})...,
+		),
+	}
+
+	out := make([]mp4Box, 0, len(children)+1)
+	udta := mp4Box{typ: "udta", payload: nil}
+	for _, c := range children {
+		switch c.typ {
+		case "mvex":
+			return nil, fmt.Errorf("%w: fragmented MP4", ErrUnsupported)
+		case "udta":
+			udtaChildren, err := parseMP4Boxes(c.payload)
+			if nil != err {
+				return nil, fmt.Errorf("parse user data box: %v", err)
+			}
+			for _, uc := range udtaChildren {
+				if uc.typ != "meta" {
+					udta.payload = append(udta.payload, uc.encode()...)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	udta.payload = append(udta.payload, meta.encode()...)
+
+	return append(out, udta), nil
+}
+
+func mp4ItemList(tags []Tag, cover []byte) ([]byte, error) {
+	values := make(map[string]string, len(tags))
+	for _, t := range tags {
+		values[t.Key] = t.Value
+	}
+
+	var items []mp4Box
+	for _, t := range tags {
+		if _, ok := mp4SkippedKeys[t.Key]; ok {
+			continue
+		}
+
+		switch t.Key {
+		case "track", "disc":
+			number, err := strconv.Atoi(t.Value)
+			if nil != err {
+				return nil, fmt.Errorf("invalid %s number %q: %v", t.Key, t.Value, err)
+			}
+			total, _ := strconv.Atoi(values[t.Key+"total"])
+			if number > math.MaxUint16 || total > math.MaxUint16 {
+				return nil, fmt.Errorf("%s number %d/%d is too large", t.Key, number, total)
+			}
+
+			data := make([]byte, 8)
+			binary.BigEndian.PutUint16(data[2:], uint16(number)) //nolint:gosec
+			binary.BigEndian.PutUint16(data[4:], uint16(total))  //nolint:gosec
+			if t.Key == "disc" {
+				data = data[:6]
+			}
+			name := "trkn"
+			if t.Key == "disc" {
+				name = "disk"
+			}
+			items = append(items, mp4DataItem(name, mp4DataTypeImplicit, data))
+		default:
+			if name, ok := mp4TextAtoms[t.Key]; ok {
+				items = append(items, mp4DataItem(name, mp4DataTypeUTF8, []byte(t.Value)))
+				continue
+			}
+
+			items = append(items, mp4FreeformItem(strings.ToUpper(t.Key), t.Value))
+		}
+	}
+
+	if len(cover) > 0 {
+		items = append(items, mp4DataItem("covr", mp4DataTypeJPEG, cover))
+	}
+
+	return encodeMP4Boxes(items), nil
+}
+
+func mp4DataItem(name string, dataType uint32, value []byte) mp4Box {
+	data := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint32(data, dataType)
+
+	return mp4Box{typ: name, payload: mp4Box{typ: "data", payload: append(data, value...)}.encode()}
+}
+
+func mp4FreeformItem(name, value string) mp4Box {
+	mean := mp4Box{typ: "mean", payload: append([]byte{0, 0, 0, 0}, mp4FreeformMean...)}
+	nameBox := mp4Box{typ: "name", payload: append([]byte{0, 0, 0, 0}, name...)}
+	data := mp4DataItem("", mp4DataTypeUTF8, []byte(value)).payload
+
+	return mp4Box{typ: "----", payload: append(append(mean.encode(), nameBox.encode()...), data...)}
+}
+
+// shiftChunkOffsets adds delta to the chunk offsets of all tracks that point at or after from.
+func shiftChunkOffsets(boxes []mp4Box, from, delta int64) error {
+	for _, b := range boxes {
+		switch b.typ {
+		case "trak", "mdia", "minf", "stbl":
+			children, err := parseMP4Boxes(b.payload)
+			if nil != err {
+				return fmt.Errorf("parse %s box: %v", b.typ, err)
+			}
+			if err := shiftChunkOffsets(children, from, delta); nil != err {
+				return err
+			}
+		case "stco":
+			if len(b.payload) < 8 {
+				return errors.New("truncated stco box")
+			}
+			count := int(binary.BigEndian.Uint32(b.payload[4:]))
+			if len(b.payload) < 8+count*4 {
+				return errors.New("truncated stco box entries")
+			}
+			for i := range count {
+				entry := b.payload[8+i*4:]
+				offset := int64(binary.BigEndian.Uint32(entry))
+				if offset < from {
+					continue
+				}
+				if offset+delta > math.MaxUint32 {
+					return fmt.Errorf("%w: shifted chunk offset does not fit in stco box", ErrUnsupported)
+				}
+				binary.BigEndian.PutUint32(entry, uint32(offset+delta)) //nolint:gosec
+			}
+		case "co64":
+			if len(b.payload) < 8 {
+				return errors.New("truncated co64 box")
+			}
+			count := int(binary.BigEndian.Uint32(b.payload[4:]))
+			if len(b.payload) < 8+count*8 {
+				return errors.New("truncated co64 box entries")
+			}
+			for i := range count {
+				entry := b.payload[8+i*8:]
+				offset := int64(binary.BigEndian.Uint64(entry)) //nolint:gosec
+				if offset < from {
+					continue
+				}
+				binary.BigEndian.PutUint64(entry, uint64(offset+delta)) //nolint:gosec
+			}
+		}
+	}
+
+	return nil
+}
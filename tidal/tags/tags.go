@@ -0,0 +1,89 @@
+// Package tags writes track tags and cover art directly into FLAC and MP4 files, without re-muxing them.
+package tags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrUnsupported is returned for files whose format, or layout, the tags cannot be natively written to,
+// e.g., FLAC streams in MP4 containers, or fragmented MP4 files.
+var ErrUnsupported = errors.New("unsupported file format")
+
+// Tag is a track tag, keyed the same way as ffmpeg metadata keys, e.g., album_artist, or tracktotal.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Write replaces the tags and the cover of the FLAC or MP4 file at path with tags and the JPEG cover.
+// Tags with empty values are not written.
+func Write(path string, tags []Tag, cover []byte) error {
+	f, err := os.Open(path)
+	if nil != err {
+		return fmt.Errorf("open file: %v", err)
+	}
+
+	magic := make([]byte, 8)
+	_, err = io.ReadFull(f, magic)
+	if closeErr := f.Close(); nil != closeErr {
+		return fmt.Errorf("close file: %v", closeErr)
+	}
+	if nil != err {
+		return fmt.Errorf("read file magic: %v", err)
+	}
+
+	nonEmpty := make([]Tag, 0, len(tags))
+	for _, t := range tags {
+		if len(t.Value) > 0 {
+			nonEmpty = append(nonEmpty, t)
+		}
+	}
+
+	switch {
+	case bytes.Equal(magic[:4], flacMagic):
+		return writeFLAC(path, nonEmpty, cover)
+	case string(magic[4:8]) == "ftyp":
+		return writeMP4(path, nonEmpty, cover)
+	default:
+		return ErrUnsupported
+	}
+}
+
+// replaceFile atomically replaces the file at path with the content written by write.
+func replaceFile(path string, write func(w io.Writer) error) (err error) {
+	tmpPath := path + ".tags"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if nil != err {
+		return fmt.Errorf("create temporary file: %v", err)
+	}
+	defer func() {
+		if nil != err {
+			_ = f.Close()
+			if removeErr := os.Remove(tmpPath); nil != removeErr && !errors.Is(removeErr, os.ErrNotExist) {
+				err = errors.Join(err, fmt.Errorf("remove temporary file: %v", removeErr))
+			}
+		}
+	}()
+
+	if err := write(f); nil != err {
+		return err
+	}
+
+	if err := f.Sync(); nil != err {
+		return fmt.Errorf("sync temporary file: %v", err)
+	}
+
+	if err := f.Close(); nil != err {
+		return fmt.Errorf("close temporary file: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); nil != err {
+		return fmt.Errorf("rename temporary file: %v", err)
+	}
+
+	return nil
+}
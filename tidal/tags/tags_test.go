@@ -0,0 +1,147 @@
+package tags_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/tidal/tags"
+)
+
+func testCover(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil))
+
+	return buf.Bytes()
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(content))) //nolint:gosec
+	out = append(out, typ...)
+
+	return append(out, content...)
+}
+
+func stco(offset uint32) []byte {
+	payload := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	return box("stco", binary.BigEndian.AppendUint32(payload, offset))
+}
+
+func chunkOffset(t *testing.T, file []byte) uint32 {
+	t.Helper()
+
+	i := bytes.Index(file, []byte("stco"))
+	require.Positive(t, i)
+
+	return binary.BigEndian.Uint32(file[i+12:])
+}
+
+func TestWriteFLAC(t *testing.T) {
+	t.Parallel()
+
+	audio := []byte("audio frames")
+	streamInfo := make([]byte, 34)
+	file := append([]byte("fLaC"), 0x80, 0, 0, 34)
+	file = append(append(file, streamInfo...), audio...)
+
+	path := filepath.Join(t.TempDir(), "track")
+	require.NoError(t, os.WriteFile(path, file, 0o600))
+
+	cover := testCover(t)
+	require.NoError(t, tags.Write(path, []tags.Tag{{Key: "title", Value: "First"}, {Key: "album_artist", Value: "A"}}, cover))
+
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(written), "TITLE=First")
+	require.Contains(t, string(written), "ALBUMARTIST=A")
+	require.True(t, bytes.HasSuffix(written, audio))
+	require.True(t, bytes.Contains(written, cover))
+
+	// The second write fits in the padding of the first one, so the file size does not change.
+	require.NoError(t, tags.Write(path, []tags.Tag{{Key: "title", Value: "Second"}, {Key: "version", Value: ""}}, nil))
+
+	rewritten, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, rewritten, len(written))
+	require.Contains(t, string(rewritten), "TITLE=Second")
+	require.NotContains(t, string(rewritten), "TITLE=First")
+	require.NotContains(t, string(rewritten), "VERSION=")
+	require.True(t, bytes.HasSuffix(rewritten, audio))
+}
+
+func TestWriteMP4(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ftyp  = box("ftyp", []byte("M4A \x00\x00\x00\x00"))
+		audio = []byte("audio samples")
+		mdat  = box("mdat", audio)
+		trak  = func(offset uint32) []byte {
+			return box("trak", box("mdia", box("minf", box("stbl", stco(offset)))))
+		}
+		tagList = []tags.Tag{
+			{Key: "title", Value: "Title"},
+			{Key: "track", Value: "3"},
+			{Key: "tracktotal", Value: "12"},
+			{Key: "isrc", Value: "USRC17607839"},
+		}
+	)
+
+	t.Run("MovieBoxLast", func(t *testing.T) {
+		t.Parallel()
+
+		audioOffset := uint32(len(ftyp) + 8) //nolint:gosec
+		file := bytes.Join([][]byte{ftyp, mdat, box("moov", box("mvhd", make([]byte, 100)), trak(audioOffset))}, nil)
+		path := filepath.Join(t.TempDir(), "track")
+		require.NoError(t, os.WriteFile(path, file, 0o600))
+
+		require.NoError(t, tags.Write(path, tagList, testCover(t)))
+
+		written, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, audioOffset, chunkOffset(t, written))
+		require.Equal(t, audio, written[audioOffset:int(audioOffset)+len(audio)])
+		require.Contains(t, string(written), "\xa9nam")
+		require.Contains(t, string(written), "ISRC")
+		require.Contains(t, string(written), "trkn\x00\x00\x00\x18data\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x03\x00\x0c")
+	})
+
+	t.Run("MovieBoxFirst", func(t *testing.T) {
+		t.Parallel()
+
+		moov := func(offset uint32) []byte {
+			return box("moov", box("mvhd", make([]byte, 100)), trak(offset))
+		}
+		audioOffset := uint32(len(ftyp) + len(moov(0)) + 8) //nolint:gosec
+		file := bytes.Join([][]byte{ftyp, moov(audioOffset), mdat}, nil)
+		path := filepath.Join(t.TempDir(), "track")
+		require.NoError(t, os.WriteFile(path, file, 0o600))
+
+		require.NoError(t, tags.Write(path, tagList, nil))
+
+		written, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.True(t, bytes.HasSuffix(written, mdat))
+		shifted := chunkOffset(t, written)
+		require.Equal(t, uint32(len(written)-len(audio)), shifted) //nolint:gosec
+	})
+
+	t.Run("Fragmented", func(t *testing.T) {
+		t.Parallel()
+
+		file := bytes.Join([][]byte{ftyp, box("moov", box("mvex")), box("moof"), mdat}, nil)
+		path := filepath.Join(t.TempDir(), "track")
+		require.NoError(t, os.WriteFile(path, file, 0o600))
+
+		require.ErrorIs(t, tags.Write(path, tagList, nil), tags.ErrUnsupported)
+	})
+}
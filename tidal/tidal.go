@@ -4,16 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/url"
-	"strings"
+	"reflect"
 	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/samber/lo"
 	"github.com/sethvargo/go-retry"
 
 	"github.com/xeptore/tidalgram/cache"
 	"github.com/xeptore/tidalgram/config"
-	"github.com/xeptore/tidalgram/must"
+	"github.com/xeptore/tidalgram/errs"
+	"github.com/xeptore/tidalgram/httputil"
+	"github.com/xeptore/tidalgram/linkkind"
+	"github.com/xeptore/tidalgram/pause"
+	"github.com/xeptore/tidalgram/pipeline"
+	"github.com/xeptore/tidalgram/ratelimit"
+	"github.com/xeptore/tidalgram/secret"
 	"github.com/xeptore/tidalgram/tidal/auth"
 	"github.com/xeptore/tidalgram/tidal/downloader"
 	"github.com/xeptore/tidalgram/tidal/fs"
@@ -21,106 +27,287 @@ import (
 )
 
 type Client struct {
-	auth           *auth.Auth
+	auth           *auth.Pool
 	DownloadsDirFs fs.DownloadsDir
 	dl             *downloader.Downloader
+	cache          *cache.Cache
+	conf           config.Tidal
 }
 
-func NewClient(logger zerolog.Logger, credsDir, dlDir string, conf config.Tidal) (*Client, error) {
-	a, err := auth.New(logger, credsDir)
+// NewClient creates a Tidal client that downloads to dlDir. If networkFS is set, track files are assembled in
+// a local temporary directory, and copied to dlDir afterwards. Account credentials are encrypted at rest if box
+// is not nil.
+func NewClient(
+	logger zerolog.Logger,
+	credsDir, dlDir string,
+	networkFS bool,
+	gate *pause.Gate,
+	conf config.Tidal,
+	box *secret.Box,
+) (*Client, error) {
+	transport := ratelimit.NewTransport(logger, httputil.NewTransport(conf.Proxy.URL()), rateLimiters(conf.RateLimit))
+	client := httputil.NewClient(transport)
+
+	a, err := auth.NewPool(logger, credsDir, conf.Accounts, conf.AccountCooldown.Duration, client, box)
 	if nil != err {
 		return nil, fmt.Errorf("create auth: %v", err)
 	}
 
+	c, err := cache.New(logger, conf.Cache.Path, int64(conf.Cache.CoversMaxSize)*1024*1024)
+	if nil != err {
+		return nil, fmt.Errorf("create cache: %v", err)
+	}
+
 	var (
-		c       = cache.New()
 		dlDirFs = fs.DownloadsDirFrom(dlDir)
-		dl      = downloader.NewDownloader(dlDirFs, conf.Downloader, a, c)
+		dl      = downloader.NewDownloader(dlDirFs, conf.Downloader, a, c, networkFS, gate, conf.CountryCode, client)
 	)
 
 	return &Client{
 		auth:           a,
 		dl:             dl,
 		DownloadsDirFs: dlDirFs,
+		cache:          c,
+		conf:           conf,
 	}, nil
 }
 
+// rateLimiters returns the limiters of the classes of Tidal requests whose rate limits are not disabled.
+func rateLimiters(conf config.TidalRateLimit) map[ratelimit.Class]*ratelimit.Adaptive {
+	classes := map[ratelimit.Class]config.TidalRateLimitClass{
+		ratelimit.ClassAPI:    conf.API,
+		ratelimit.ClassStream: conf.Stream,
+		ratelimit.ClassMedia:  conf.Media,
+	}
+
+	limiters := make(map[ratelimit.Class]*ratelimit.Adaptive, len(classes))
+	for class, c := range classes {
+		if !c.Disabled {
+			limiters[class] = ratelimit.NewAdaptive(c.Rate, c.MinRate, c.Burst)
+		}
+	}
+
+	return limiters
+}
+
+// CoverCacheStats returns the memory usage, and the effectiveness of the covers cache since startup.
+func (c *Client) CoverCacheStats() cache.CoverStats {
+	return c.cache.Covers.Stats()
+}
+
+// Close closes the database fetched metadata is cached in.
+func (c *Client) Close() error {
+	return c.cache.Close()
+}
+
+// Reload applies the downloader options of conf to the downloads started after it. It must not be called while
+// a download is running. It reports whether other options changed, which require a restart to take effect.
+func (c *Client) Reload(conf config.Tidal) bool {
+	restart := c.dl.Reload(conf.Downloader)
+
+	next := c.conf
+	next.Downloader = conf.Downloader
+	if !reflect.DeepEqual(next, conf) {
+		restart = true
+	}
+	c.conf.Downloader = conf.Downloader
+
+	return restart
+}
+
+// Name returns the name of the provider, i.e., tidal.
+func (c *Client) Name() string {
+	return "tidal"
+}
+
+// ResolveLink returns the link at rawURL, and whether it is either a Tidal link, or a link of a registered custom
+// link kind, which are downloaded by the Tidal downloader as well.
+func (c *Client) ResolveLink(rawURL string) (types.Link, bool) {
+	if link, err := types.ParseURL(rawURL); nil == err {
+		return link, true
+	}
+
+	return linkkind.ParseURL(rawURL)
+}
+
+// DownloadsDir returns the directory links are downloaded to.
+func (c *Client) DownloadsDir() fs.DownloadsDir {
+	return c.DownloadsDirFs
+}
+
+// CheckClockSkew measures the skew of the local clock from the Tidal auth server clock, and factors it into token
+// expiry decisions. It logs a warning if the skew exceeds [auth.ClockSkewThreshold].
+func (c *Client) CheckClockSkew(ctx context.Context, logger zerolog.Logger) error {
+	skew, err := c.auth.Active().MeasureClockSkew(ctx, logger)
+	if nil != err {
+		return fmt.Errorf("measure clock skew: %w", err)
+	}
+	c.auth.SetClockSkew(skew)
+
+	if skew.Abs() > auth.ClockSkewThreshold {
+		logger.
+			Warn().
+			Dur("skew", skew).
+			Dur("threshold", auth.ClockSkewThreshold).
+			Msg("Local clock is skewed from Tidal servers. Token expiry is corrected for it, but consider syncing the clock, e.g., using NTP")
+	} else {
+		logger.Debug().Dur("skew", skew).Msg("Measured clock skew")
+	}
+
+	return nil
+}
+
 var (
-	ErrTokenRefreshRequired      = errors.New("auth token refresh required")
+	ErrTokenRefreshRequired      = errs.New(errs.CategoryAuth, "auth token refresh required")
 	ErrTokenRefreshed            = errors.New("auth token refreshed")
-	ErrLoginRequired             = errors.New("login required")
+	ErrLoginRequired             = errs.New(errs.CategoryAuth, "login required")
 	ErrUnauthorized              = auth.ErrUnauthorized
 	ErrLoginLinkExpired          = auth.ErrLoginLinkExpired
+	ErrUnknownAccount            = auth.ErrUnknownAccount
 	ErrUnsupportedArtistLinkKind = downloader.ErrUnsupportedArtistLinkKind
 	ErrUnsupportedVideoLinkKind  = downloader.ErrUnsupportedVideoLinkKind
+	ErrUnsupportedSyncLinkKind   = downloader.ErrUnsupportedSyncLinkKind
+	ErrUnsupportedUpdateLinkKind = downloader.ErrUnsupportedUpdateLinkKind
 )
 
-func (c *Client) TryDownloadLink(ctx context.Context, logger zerolog.Logger, link types.Link) error {
-	err := retry.Do(
-		ctx,
-		retry.WithMaxRetries(3, retry.NewFibonacci(1*time.Second)),
-		func(ctx context.Context) error {
-			if err := c.downloadLink(ctx, logger, link); nil != err {
-				if errors.Is(err, context.Canceled) {
-					return context.Canceled
-				}
+type (
+	Account               = auth.Account
+	AccountStatus         = auth.AccountStatus
+	StageError            = downloader.StageError
+	MissingMetadataError  = downloader.MissingMetadataError
+	SpatialAudioOnlyError = downloader.SpatialAudioOnlyError
+	RateLimitedError      = downloader.RateLimitedError
+)
 
-				if errors.Is(err, context.DeadlineExceeded) {
-					return retry.RetryableError(context.DeadlineExceeded)
-				}
+// WithJob returns a copy of ctx under which the downloaded links are deduplicated by ISRC as a single job, if
+// enabled.
+func WithJob(ctx context.Context) context.Context {
+	return downloader.WithJob(ctx)
+}
 
-				if errors.Is(err, ErrTokenRefreshRequired) {
-					if err := c.auth.RefreshToken(ctx, logger); nil != err {
-						if errors.Is(err, context.Canceled) {
-							return context.Canceled
-						}
+// WithStrictMetadata returns a copy of ctx under which links are downloaded in strict metadata mode, i.e.,
+// tracks with missing metadata fail their downloads.
+func WithStrictMetadata(ctx context.Context) context.Context {
+	return downloader.WithStrictMetadata(ctx)
+}
 
-						if errors.Is(err, context.DeadlineExceeded) {
-							return retry.RetryableError(context.DeadlineExceeded)
-						}
+// IsStrictMetadata reports whether ctx was returned by WithStrictMetadata.
+func IsStrictMetadata(ctx context.Context) bool {
+	return downloader.IsStrictMetadata(ctx)
+}
 
-						if errors.Is(err, auth.ErrUnauthorized) {
-							return ErrLoginRequired
-						}
+// WithPipeline returns a copy of ctx under which the tracks of a downloaded album are handed over to album as
+// soon as they are downloaded. See [pipeline.Album].
+func WithPipeline(ctx context.Context, album *pipeline.Album) context.Context {
+	return downloader.WithPipeline(ctx, album)
+}
 
-						return fmt.Errorf("refresh token: %w", err)
-					}
+// WithTrackStream returns a copy of ctx under which the file of a downloaded single track is streamed to track
+// while it is being tagged.
+func WithTrackStream(ctx context.Context, track *pipeline.Track) context.Context {
+	return downloader.WithTrackStream(ctx, track)
+}
 
-					return retry.RetryableError(ErrTokenRefreshed)
-				}
+func (c *Client) TryDownloadLink(ctx context.Context, logger zerolog.Logger, link types.Link) error {
+	defer c.dl.ResetPlaybackInfo()
 
-				if errors.Is(err, downloader.ErrUnsupportedArtistLinkKind) {
-					return ErrUnsupportedArtistLinkKind
-				}
+	return c.tryWithRetries(ctx, logger, func(ctx context.Context) error {
+		return c.downloadLink(ctx, logger, link)
+	})
+}
 
-				if errors.Is(err, downloader.ErrUnsupportedVideoLinkKind) {
-					return ErrUnsupportedVideoLinkKind
-				}
+// TrySyncLink downloads the playlist or mix tracks that were not uploaded in a previous sync,
+// and returns their IDs.
+func (c *Client) TrySyncLink(ctx context.Context, logger zerolog.Logger, link types.Link) ([]string, error) {
+	state, err := c.DownloadsDirFs.Sync(link).Read()
+	if nil != err {
+		return nil, fmt.Errorf("read sync state: %v", err)
+	}
 
-				return err
-			}
+	defer c.dl.ResetPlaybackInfo()
 
-			return nil
-		},
-	)
+	err = c.tryWithRetries(ctx, logger, func(ctx context.Context) error {
+		return c.syncLink(ctx, logger, link, state.TrackIDs)
+	})
 	if nil != err {
-		if errors.Is(err, ErrTokenRefreshed) {
-			// Give it another chance to download the link even when max retries are reached.
-			return c.downloadLink(ctx, logger, link)
+		return nil, err
+	}
+
+	switch link.Kind {
+	case types.LinkKindPlaylist:
+		info, err := c.DownloadsDirFs.Playlist(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read playlist info file: %v", err)
 		}
 
-		// Make all error kinds handled in the retry loop above available to the caller as we want to handle them.
-		return fmt.Errorf("download link after retries: %w", err)
+		return info.TrackIDs, nil
+	case types.LinkKindMix:
+		info, err := c.DownloadsDirFs.Mix(link.ID).InfoFile.Read()
+		if nil != err {
+			return nil, fmt.Errorf("read mix info file: %v", err)
+		}
+
+		return info.TrackIDs, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSyncLinkKind, link.Kind.String())
+	}
+}
+
+// TryUpdateAlbum downloads the album tracks that are not in uploadedIDs, and returns their IDs.
+func (c *Client) TryUpdateAlbum(
+	ctx context.Context,
+	logger zerolog.Logger,
+	link types.Link,
+	uploadedIDs []string,
+) ([]string, error) {
+	defer c.dl.ResetPlaybackInfo()
+
+	err := c.tryWithRetries(ctx, logger, func(ctx context.Context) error {
+		return c.updateAlbum(ctx, logger, link, uploadedIDs)
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	trackIDs, err := c.DownloadsDirFs.TrackIDs(link)
+	if nil != err {
+		return nil, fmt.Errorf("read album track ids: %v", err)
+	}
+
+	return trackIDs, nil
+}
+
+// MarkSynced records trackIDs as uploaded so the next sync of link skips them.
+func (c *Client) MarkSynced(link types.Link, trackIDs []string) error {
+	syncFs := c.DownloadsDirFs.Sync(link)
+
+	state, err := syncFs.Read()
+	if nil != err {
+		return fmt.Errorf("read sync state: %v", err)
+	}
+
+	state.TrackIDs = lo.Union(state.TrackIDs, trackIDs)
+	state.SyncedAt = time.Now()
+
+	if err := syncFs.InfoFile.Write(*state); nil != err {
+		return fmt.Errorf("write sync state: %v", err)
 	}
 
 	return nil
 }
 
+// TryInitiateLoginFlow initiates the login flow of the account with name. See [Client.Accounts].
 func (c *Client) TryInitiateLoginFlow(
 	ctx context.Context,
 	logger zerolog.Logger,
+	name string,
 ) (*auth.LoginLink, <-chan error, error) {
-	link, wait, err := c.auth.InitiateLoginFlow(ctx, logger)
+	account, err := c.auth.Lookup(name)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	link, wait, err := account.InitiateLoginFlow(ctx, logger)
 	if nil != err {
 		return nil, nil, fmt.Errorf("initiate login flow: %w", err)
 	}
@@ -128,71 +315,69 @@ func (c *Client) TryInitiateLoginFlow(
 	return link, wait, nil
 }
 
-// NormalizePathParts normalizes URL path parts by handling "browse" prefix and "u" suffix.
-// This is used to support both old and new TIDAL link formats.
-// Returns a new slice without modifying the input.
-func NormalizePathParts(path string) []string {
-	pathParts := strings.SplitN(strings.Trim(path, "/"), "/", 4)
-	result := make([]string, 0, len(pathParts))
+// PrimaryAccount is the name of the account whose credentials are stored in the credentials directory itself.
+const PrimaryAccount = auth.PrimaryAccount
+
+// Accounts returns the states of the configured accounts, the primary account first.
+func (c *Client) Accounts() []AccountStatus {
+	return c.auth.Statuses()
+}
+
+// TryAccount fetches the details of the Tidal account with name, refreshing its token if it is about to
+// expire. See [Client.Accounts].
+func (c *Client) TryAccount(ctx context.Context, logger zerolog.Logger, name string) (*Account, error) {
+	account, err := c.auth.Lookup(name)
+	if nil != err {
+		return nil, err
+	}
 
-	// Remove "browse" prefix if present
-	startIdx := 0
-	if len(pathParts) > 0 && pathParts[0] == "browse" {
-		startIdx = 1
+	creds := account.Credentials()
+	if creds.ExpiresAt.IsZero() {
+		return nil, ErrLoginRequired
 	}
 
-	// Copy parts, skipping empty strings
-	for i := startIdx; i < len(pathParts); i++ {
-		if pathParts[i] != "" {
-			result = append(result, pathParts[i])
+	if account.Now().Add(10 * time.Minute).After(creds.ExpiresAt) {
+		if err := account.RefreshToken(ctx, logger); nil != err {
+			if errors.Is(err, auth.ErrUnauthorized) {
+				return nil, ErrLoginRequired
+			}
+
+			return nil, fmt.Errorf("refresh token: %w", err)
 		}
 	}
 
-	// Remove "u" suffix if present (must be the last element)
-	if len(result) == 3 && result[len(result)-1] == "u" {
-		result = result[:len(result)-1]
+	a, err := account.Account(ctx, logger)
+	if nil != err {
+		return nil, fmt.Errorf("get account: %w", err)
 	}
 
-	return result
+	return a, nil
 }
 
-func ParseLink(l string) types.Link {
-	u, err := url.Parse(l)
-	must.NilErr(err)
+// CheckLogin returns ErrLoginRequired if the active account is not logged in, or its token is no longer
+// accepted by Tidal. Its token is refreshed if it is about to expire.
+func (c *Client) CheckLogin(ctx context.Context, logger zerolog.Logger) error {
+	for _, account := range c.Accounts() {
+		if account.Active {
+			if _, err := c.TryAccount(ctx, logger, account.Name); nil != err {
+				return err
+			}
 
-	var (
-		id   string
-		kind types.LinkKind
-	)
+			return nil
+		}
+	}
 
-	pathParts := NormalizePathParts(u.Path)
-
-	if len(pathParts) < 2 {
-		panic(fmt.Sprintf("unexpected link format: not enough path parts in %q", l))
-	}
-
-	id = pathParts[1]
-
-	switch k := pathParts[0]; k {
-	case "mix":
-		kind = types.LinkKindMix
-	case "playlist":
-		kind = types.LinkKindPlaylist
-	case "album":
-		kind = types.LinkKindAlbum
-	case "track":
-		kind = types.LinkKindTrack
-	case "artist":
-		kind = types.LinkKindArtist
-	case "credits":
-		kind = types.LinkKindArtistCredits
-	case "video":
-		kind = types.LinkKindVideo
-	default:
-		panic("unexpected link media type: " + k)
+	return ErrLoginRequired
+}
+
+// ParseLink parses the Tidal URL l, which must be a URL accepted by [types.ParseURL].
+func ParseLink(l string) types.Link {
+	link, err := types.ParseURL(l)
+	if nil != err {
+		panic(fmt.Sprintf("unexpected link format %q: %v", l, err))
 	}
 
-	return types.Link{Kind: kind, ID: id}
+	return link
 }
 
 func (c *Client) downloadLink(ctx context.Context, logger zerolog.Logger, link types.Link) error {
@@ -202,7 +387,7 @@ func (c *Client) downloadLink(ctx context.Context, logger zerolog.Logger, link t
 		return ErrLoginRequired
 	}
 
-	if time.Now().Add(10 * time.Minute).After(creds.ExpiresAt) {
+	if c.auth.Active().Now().Add(10 * time.Minute).After(creds.ExpiresAt) {
 		return ErrTokenRefreshRequired
 	}
 
@@ -212,3 +397,112 @@ func (c *Client) downloadLink(ctx context.Context, logger zerolog.Logger, link t
 
 	return nil
 }
+
+func (c *Client) syncLink(ctx context.Context, logger zerolog.Logger, link types.Link, syncedIDs []string) error {
+	creds := c.auth.Credentials()
+
+	if creds.ExpiresAt.IsZero() {
+		return ErrLoginRequired
+	}
+
+	if c.auth.Active().Now().Add(10 * time.Minute).After(creds.ExpiresAt) {
+		return ErrTokenRefreshRequired
+	}
+
+	if err := c.dl.Sync(ctx, logger, link, syncedIDs); nil != err {
+		return fmt.Errorf("sync link: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) updateAlbum(ctx context.Context, logger zerolog.Logger, link types.Link, uploadedIDs []string) error {
+	creds := c.auth.Credentials()
+
+	if creds.ExpiresAt.IsZero() {
+		return ErrLoginRequired
+	}
+
+	if c.auth.Active().Now().Add(10 * time.Minute).After(creds.ExpiresAt) {
+		return ErrTokenRefreshRequired
+	}
+
+	if err := c.dl.Update(ctx, logger, link, uploadedIDs); nil != err {
+		return fmt.Errorf("update album: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) tryWithRetries(ctx context.Context, logger zerolog.Logger, fn func(ctx context.Context) error) error {
+	err := retry.Do(
+		ctx,
+		retry.WithMaxRetries(3, retry.NewFibonacci(1*time.Second)),
+		func(ctx context.Context) error {
+			if err := fn(ctx); nil != err {
+				if errors.Is(err, context.Canceled) {
+					return context.Canceled
+				}
+
+				if errors.Is(err, context.DeadlineExceeded) {
+					return retry.RetryableError(context.DeadlineExceeded)
+				}
+
+				if errors.Is(err, ErrTokenRefreshRequired) {
+					if err := c.auth.Active().RefreshToken(ctx, logger); nil != err {
+						if errors.Is(err, context.Canceled) {
+							return context.Canceled
+						}
+
+						if errors.Is(err, context.DeadlineExceeded) {
+							return retry.RetryableError(context.DeadlineExceeded)
+						}
+
+						if errors.Is(err, auth.ErrUnauthorized) {
+							return ErrLoginRequired
+						}
+
+						return fmt.Errorf("refresh token: %w", err)
+					}
+
+					return retry.RetryableError(ErrTokenRefreshed)
+				}
+
+				if errors.Is(err, downloader.ErrTooManyRequests) && c.auth.Rotate(logger) {
+					return retry.RetryableError(err)
+				}
+
+				if errors.Is(err, downloader.ErrUnsupportedArtistLinkKind) {
+					return ErrUnsupportedArtistLinkKind
+				}
+
+				if errors.Is(err, downloader.ErrUnsupportedVideoLinkKind) {
+					return ErrUnsupportedVideoLinkKind
+				}
+
+				if errors.Is(err, downloader.ErrUnsupportedSyncLinkKind) {
+					return ErrUnsupportedSyncLinkKind
+				}
+
+				if errors.Is(err, downloader.ErrUnsupportedUpdateLinkKind) {
+					return ErrUnsupportedUpdateLinkKind
+				}
+
+				return err
+			}
+
+			return nil
+		},
+	)
+	if nil != err {
+		if errors.Is(err, ErrTokenRefreshed) {
+			// Give it another chance to download the link even when max retries are reached.
+			return fn(ctx)
+		}
+
+		// Make all error kinds handled in the retry loop above available to the caller as we want to handle them.
+		return fmt.Errorf("download link after retries: %w", err)
+	}
+
+	return nil
+}
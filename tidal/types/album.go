@@ -9,6 +9,17 @@ type AlbumMeta struct {
 	Title        string
 	ReleaseDate  time.Time
 	CoverID      string
+	VideoCoverID string
 	TotalTracks  int
 	TotalVolumes int
 }
+
+// Stored returns the album fields that are stored alongside downloaded tracks for rendering upload captions.
+func (a AlbumMeta) Stored() StoredAlbumMeta {
+	return StoredAlbumMeta{
+		Artist:      a.Artist,
+		Title:       a.Title,
+		ReleaseDate: a.ReleaseDate,
+		TotalTracks: a.TotalTracks,
+	}
+}
@@ -2,15 +2,43 @@ package types
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 )
 
 type StoredMix struct {
 	Caption  string   `json:"caption"`
 	TrackIDs []string `json:"track_ids"`
+	// DuplicateTrackIDs holds the IDs of the duplicate track occurrences that were skipped, if any.
+	DuplicateTrackIDs []string `json:"duplicate_track_ids,omitempty"`
+	// FailedTracks holds the tracks that failed to download, and were skipped, if any.
+	FailedTracks []StoredFailedTrack `json:"failed_tracks,omitempty"`
+	// FilteredTracks holds the tracks that were skipped by the configured filters, if any.
+	FilteredTracks []StoredFilteredTrack `json:"filtered_tracks,omitempty"`
 }
 
 type StoredArtistCredits struct {
 	TrackIDs []string `json:"track_ids"`
+	// FailedTracks holds the tracks that failed to download, and were skipped, if any.
+	FailedTracks []StoredFailedTrack `json:"failed_tracks,omitempty"`
+}
+
+// StoredFilteredTrack is a track of a playlist, or mix that was skipped by the configured filters.
+type StoredFilteredTrack struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Reason describes the filter that skipped the track, e.g., longer than 20m0s.
+	Reason string `json:"reason"`
+}
+
+// StoredFailedTrack is a track of a playlist, mix, or artist credits that failed to download, and was skipped.
+type StoredFailedTrack struct {
+	ID string `json:"id"`
+	// Stage is the download stage the track failed at, e.g., download.
+	Stage string `json:"stage"`
+	// Code is the user-facing code of the failure category, e.g., E-NETWORK.
+	Code  string `json:"code"`
+	Error string `json:"error"`
 }
 
 type Track struct {
@@ -22,6 +50,12 @@ type Track struct {
 	Version      *string       `json:"version"`
 	CoverID      string        `json:"cover_id"`
 	Ext          string        `json:"ext"`
+	// SkippedAudioMode is the spatial audio mode the track was served in, whose stereo stream was downloaded
+	// instead, if any.
+	SkippedAudioMode string `json:"skipped_audio_mode,omitempty"`
+	// Size and SHA256 are the size and the hex-encoded SHA-256 hash of the final, tagged track file.
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
 }
 
 func (t Track) UploadTitle() string {
@@ -45,11 +79,42 @@ func (t Track) UploadFilename() string {
 type StoredTrack struct {
 	Track
 
-	Caption string `json:"caption"`
+	Album    StoredAlbumMeta `json:"album"`
+	Metadata TrackMetadata   `json:"metadata"`
+}
+
+// TrackMetadata holds the machine-readable track metadata that is uploaded as a JSON sidecar, if enabled.
+type TrackMetadata struct {
+	TidalID      string        `json:"tidal_id"`
+	TidalAlbumID string        `json:"tidal_album_id"`
+	Title        string        `json:"title"`
+	Version      *string       `json:"version"`
+	Artists      []TrackArtist `json:"artists"`
+	Album        string        `json:"album"`
+	AlbumArtist  string        `json:"album_artist"`
+	ISRC         string        `json:"isrc"`
+	Copyright    string        `json:"copyright"`
+	ReleaseDate  string        `json:"release_date"`
+	TrackNumber  int           `json:"track_number"`
+	TotalTracks  int           `json:"total_tracks"`
+	VolumeNumber int           `json:"volume_number"`
+	TotalVolumes int           `json:"total_volumes"`
+	Duration     int           `json:"duration"`
+	Credits      TrackCredits  `json:"credits"`
+}
+
+// StoredAlbumMeta holds the album fields that upload captions are rendered from.
+type StoredAlbumMeta struct {
+	Artist      string    `json:"artist"`
+	Title       string    `json:"title"`
+	ReleaseDate time.Time `json:"release_date"`
+	TotalTracks int       `json:"total_tracks"`
 }
 
 type StoredAlbumTrack struct {
 	Track
+
+	Metadata TrackMetadata `json:"metadata"`
 }
 
 func (t StoredAlbumTrack) UploadTitle() string {
@@ -61,21 +126,90 @@ func (t StoredAlbumTrack) UploadTitle() string {
 	return title
 }
 
+// UploadFilename returns the file name of the track prefixed with its track number, zero-padded to the digits
+// of the album track count, and at least two, so that files sort in album order.
 func (t StoredAlbumTrack) UploadFilename() string {
+	width := max(len(strconv.Itoa(t.Metadata.TotalTracks)), 2)
 	artistName := JoinArtists(t.Artists)
 	if nil != t.Version {
-		return fmt.Sprintf("%d. %s - %s (%s).%s", t.TrackNumber, artistName, t.Title, *t.Version, t.Ext)
+		return fmt.Sprintf("%0*d. %s - %s (%s).%s", width, t.TrackNumber, artistName, t.Title, *t.Version, t.Ext)
 	}
 
-	return fmt.Sprintf("%d. %s - %s.%s", t.TrackNumber, artistName, t.Title, t.Ext)
+	return fmt.Sprintf("%0*d. %s - %s.%s", width, t.TrackNumber, artistName, t.Title, t.Ext)
 }
 
 type StoredPlaylist struct {
 	Caption  string   `json:"caption"`
 	TrackIDs []string `json:"track_ids"`
+	// DuplicateTrackIDs holds the IDs of the duplicate track occurrences that were skipped, if any.
+	DuplicateTrackIDs []string `json:"duplicate_track_ids,omitempty"`
+	// FailedTracks holds the tracks that failed to download, and were skipped, if any.
+	FailedTracks []StoredFailedTrack `json:"failed_tracks,omitempty"`
+	// FilteredTracks holds the tracks that were skipped by the configured filters, if any.
+	FilteredTracks []StoredFilteredTrack `json:"filtered_tracks,omitempty"`
+}
+
+// StoredVideoCover is the metadata of a downloaded album video cover.
+type StoredVideoCover struct {
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	Duration float64 `json:"duration"`
 }
 
 type StoredAlbum struct {
-	Caption        string     `json:"caption"`
-	VolumeTrackIDs [][]string `json:"volume_track_ids"`
+	Album          StoredAlbumMeta `json:"album"`
+	VolumeTrackIDs [][]string      `json:"volume_track_ids"`
+}
+
+// StoredSync holds the IDs of the playlist or mix tracks that were already uploaded in sync mode.
+type StoredSync struct {
+	TrackIDs []string  `json:"track_ids"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// StoredWatchlist holds the playlist and mix URLs added using the watch command.
+type StoredWatchlist struct {
+	URLs []string `json:"urls"`
+}
+
+// StoredLibrary maps the IDs of the tracks linked to the library to their paths, relative to the library directory.
+type StoredLibrary struct {
+	Paths map[string]string `json:"paths"`
+}
+
+// StoredMirror maps the uploaded links mirrored to the storage backend, keyed by their kind and ID, to what was
+// mirrored.
+type StoredMirror struct {
+	Links map[string]MirroredLink `json:"links"`
+}
+
+type MirroredLink struct {
+	MirroredAt time.Time `json:"mirrored_at"`
+	// Keys are the storage keys of the mirrored tracks. They are nil if the tracks were removed from the storage
+	// after the retention period.
+	Keys    []string `json:"keys"`
+	Expired bool     `json:"expired,omitempty"`
+}
+
+// StoredMaintenance holds the maintenance mode state set using the maintenance command.
+type StoredMaintenance struct {
+	Enabled bool      `json:"enabled"`
+	Since   time.Time `json:"since"`
+}
+
+// StoredPendingJob is a job interrupted by the loss of the Telegram session, which is resumed once the bot is
+// logged in again.
+type StoredPendingJob struct {
+	ChatID int64 `json:"chat_id"`
+	UserID int64 `json:"user_id"`
+	// Links are the URLs of the links left to process, starting with the one that was interrupted.
+	Links       []string `json:"links"`
+	Mode        string   `json:"mode"`
+	Destination string   `json:"destination"`
+	Archive     bool     `json:"archive"`
+	Strict      bool     `json:"strict"`
+	Notify      bool     `json:"notify,omitempty"`
+	// ScheduleAt is when the posts are scheduled to be posted at, or zero to post them right away.
+	ScheduleAt time.Time `json:"schedule_at,omitzero"`
+	CreatedAt  time.Time `json:"created_at"`
 }
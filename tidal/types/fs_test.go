@@ -0,0 +1,44 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+func TestStoredAlbumTrackUploadFilename(t *testing.T) {
+	t.Parallel()
+
+	version := "Remastered"
+	tests := []struct {
+		name        string
+		trackNumber int
+		totalTracks int
+		version     *string
+		expected    string
+	}{
+		{name: "short album", trackNumber: 3, totalTracks: 8, version: nil, expected: "03. Artist - Title.flac"},
+		{name: "long album", trackNumber: 7, totalTracks: 120, version: nil, expected: "007. Artist - Title.flac"},
+		{name: "with version", trackNumber: 11, totalTracks: 34, version: &version, expected: "11. Artist - Title (Remastered).flac"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			track := types.StoredAlbumTrack{ //nolint:exhaustruct
+				Track: types.Track{ //nolint:exhaustruct
+					Artists:     []types.TrackArtist{{Name: "Artist", Type: types.ArtistTypeMain}},
+					Title:       "Title",
+					TrackNumber: tt.trackNumber,
+					Version:     tt.version,
+					Ext:         "flac",
+				},
+				Metadata: types.TrackMetadata{TotalTracks: tt.totalTracks}, //nolint:exhaustruct
+			}
+			assert.Equal(t, tt.expected, track.UploadFilename())
+		})
+	}
+}
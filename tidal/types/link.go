@@ -20,9 +20,66 @@ func (k LinkKind) String() string {
 		return "video"
 	}
 
+	if k.Custom() {
+		return customLinkKinds[k-linkKindCustom].name
+	}
+
 	return "unknown"
 }
 
+// Custom reports whether k is a custom link kind, registered using [RegisterLinkKind].
+func (k LinkKind) Custom() bool {
+	return k >= linkKindCustom && int(k-linkKindCustom) < len(customLinkKinds)
+}
+
+type customLinkKind struct {
+	name string
+	url  func(id string) string
+}
+
+// linkKindCustom is the first custom link kind.
+const linkKindCustom LinkKind = 1000
+
+var customLinkKinds []customLinkKind
+
+// RegisterLinkKind registers a custom link kind with name, whose link URLs are built using url, and returns
+// it. It is not safe for concurrent use, and is meant to be called from init functions. It panics if name is
+// already the name of a link kind.
+func RegisterLinkKind(name string, url func(id string) string) LinkKind {
+	if _, exists := ParseLinkKind(name); exists {
+		panic("link kind already registered: " + name)
+	}
+
+	customLinkKinds = append(customLinkKinds, customLinkKind{name: name, url: url})
+
+	return linkKindCustom + LinkKind(len(customLinkKinds)-1)
+}
+
+// ParseLinkKind is the inverse of [LinkKind.String].
+func ParseLinkKind(s string) (LinkKind, bool) {
+	for _, k := range []LinkKind{
+		LinkKindPlaylist,
+		LinkKindMix,
+		LinkKindAlbum,
+		LinkKindTrack,
+		LinkKindArtist,
+		LinkKindArtistCredits,
+		LinkKindVideo,
+	} {
+		if k.String() == s {
+			return k, true
+		}
+	}
+
+	for i, k := range customLinkKinds {
+		if k.name == s {
+			return linkKindCustom + LinkKind(i), true
+		}
+	}
+
+	return 0, false
+}
+
 const (
 	LinkKindPlaylist LinkKind = iota
 	LinkKindMix
@@ -37,3 +94,23 @@ type Link struct {
 	Kind LinkKind
 	ID   string
 }
+
+// URL returns the Tidal web URL of the link, or the URL built by its custom link kind.
+func (l Link) URL() string {
+	if l.Kind.Custom() {
+		return customLinkKinds[l.Kind-linkKindCustom].url(l.ID)
+	}
+
+	return "https://tidal.com/" + l.Kind.String() + "/" + l.ID
+}
+
+// StoredAs returns the link l is stored as in the downloads directory. Links of custom link kinds are stored
+// as playlists, with IDs prefixed by the kind name, so that they are uploaded like playlists. Other links are
+// stored as themselves.
+func (l Link) StoredAs() Link {
+	if l.Kind.Custom() {
+		return Link{Kind: LinkKindPlaylist, ID: l.Kind.String() + "-" + l.ID}
+	}
+
+	return l
+}
@@ -10,6 +10,8 @@ import (
 
 const (
 	ReleaseDateLayout = "2006/01/02"
+	// TrackQuality is the audio quality tracks are downloaded in.
+	TrackQuality = "HI_RES_LOSSLESS"
 )
 
 func JoinNames(names []string) string {
@@ -17,10 +19,10 @@ func JoinNames(names []string) string {
 }
 
 type TrackCredits struct {
-	Producers           []string
-	Composers           []string
-	Lyricists           []string
-	AdditionalProducers []string
+	Producers           []string `json:"producers"`
+	Composers           []string `json:"composers"`
+	Lyricists           []string `json:"lyricists"`
+	AdditionalProducers []string `json:"additional_producers"`
 }
 
 func (t TrackCredits) ToDict() *zerolog.Event {
@@ -0,0 +1,94 @@
+package types
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNotTidalURL = errors.New("not a Tidal URL")
+	// ErrShortenedURL is returned for shortened Tidal share links, e.g., https://tidal.link/abc, whose full URLs
+	// are only known after following their redirects.
+	ErrShortenedURL   = errors.New("shortened Tidal URL")
+	ErrUnsupportedURL = errors.New("unsupported Tidal URL")
+)
+
+var urlLinkKinds = map[string]LinkKind{
+	"mix":      LinkKindMix,
+	"playlist": LinkKindPlaylist,
+	"album":    LinkKindAlbum,
+	"track":    LinkKindTrack,
+	"artist":   LinkKindArtist,
+	"credits":  LinkKindArtistCredits,
+	"video":    LinkKindVideo,
+}
+
+// ParseURL parses the Tidal web URL raw into a link. Besides the canonical https://tidal.com/<kind>/<id> format,
+// it accepts:
+//   - the www.tidal.com and listen.tidal.com hosts,
+//   - country subpaths, e.g., /us/album/<id>,
+//   - the /browse prefix, e.g., /browse/album/<id>,
+//   - the share link markers, i.e., the /u suffix, and the u query parameter,
+//   - tracks within albums, e.g., /album/<id>/track/<track id>, or /album/<id>?trackId=<track id>, which are
+//     parsed as links to the tracks.
+//
+// It returns ErrNotTidalURL if raw is not an HTTPS Tidal URL, ErrShortenedURL if it is a shortened share
+// link, and ErrUnsupportedURL if it is a Tidal URL that does not link to a supported kind.
+func ParseURL(raw string) (Link, error) {
+	u, err := url.Parse(raw)
+	if nil != err || u.Scheme != "https" {
+		return Link{}, ErrNotTidalURL //nolint:exhaustruct
+	}
+
+	switch strings.ToLower(u.Hostname()) {
+	case "tidal.com", "www.tidal.com", "listen.tidal.com":
+	case "tidal.link", "link.tidal.com":
+		return Link{}, ErrShortenedURL //nolint:exhaustruct
+	default:
+		return Link{}, ErrNotTidalURL //nolint:exhaustruct
+	}
+
+	var parts []string
+	for p := range strings.SplitSeq(u.Path, "/") {
+		if len(p) > 0 {
+			parts = append(parts, p)
+		}
+	}
+
+	if len(parts) > 0 && isCountryCode(parts[0]) {
+		parts = parts[1:]
+	}
+	if len(parts) > 0 && parts[0] == "browse" {
+		parts = parts[1:]
+	}
+	if len(parts) > 0 && parts[len(parts)-1] == "u" {
+		parts = parts[:len(parts)-1]
+	}
+
+	if len(parts) < 2 {
+		return Link{}, ErrUnsupportedURL //nolint:exhaustruct
+	}
+
+	kind, ok := urlLinkKinds[parts[0]]
+	if !ok {
+		return Link{}, ErrUnsupportedURL //nolint:exhaustruct
+	}
+
+	if kind == LinkKindAlbum {
+		if len(parts) >= 4 && parts[2] == "track" {
+			return Link{Kind: LinkKindTrack, ID: parts[3]}, nil
+		}
+
+		if trackID := u.Query().Get("trackId"); len(trackID) > 0 {
+			return Link{Kind: LinkKindTrack, ID: trackID}, nil
+		}
+	}
+
+	return Link{Kind: kind, ID: parts[1]}, nil
+}
+
+// isCountryCode reports whether s is a two-letter lowercase country code, as used in localized Tidal URLs.
+func isCountryCode(s string) bool {
+	return len(s) == 2 && 'a' <= s[0] && s[0] <= 'z' && 'a' <= s[1] && s[1] <= 'z'
+}
@@ -0,0 +1,146 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xeptore/tidalgram/tidal/types"
+)
+
+func TestParseURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		url      string
+		expected types.Link
+		err      error
+	}{
+		{
+			name:     "album",
+			url:      "https://tidal.com/album/123",
+			expected: types.Link{Kind: types.LinkKindAlbum, ID: "123"},
+		},
+		{
+			name:     "track",
+			url:      "https://tidal.com/track/456",
+			expected: types.Link{Kind: types.LinkKindTrack, ID: "456"},
+		},
+		{
+			name:     "playlist",
+			url:      "https://tidal.com/playlist/0a1b2c3d-4e5f",
+			expected: types.Link{Kind: types.LinkKindPlaylist, ID: "0a1b2c3d-4e5f"},
+		},
+		{
+			name:     "artist credits",
+			url:      "https://tidal.com/credits/789",
+			expected: types.Link{Kind: types.LinkKindArtistCredits, ID: "789"},
+		},
+		{
+			name:     "www host",
+			url:      "https://www.tidal.com/mix/abc",
+			expected: types.Link{Kind: types.LinkKindMix, ID: "abc"},
+		},
+		{
+			name:     "listen host",
+			url:      "https://listen.tidal.com/video/42",
+			expected: types.Link{Kind: types.LinkKindVideo, ID: "42"},
+		},
+		{
+			name:     "browse prefix",
+			url:      "https://tidal.com/browse/album/123",
+			expected: types.Link{Kind: types.LinkKindAlbum, ID: "123"},
+		},
+		{
+			name:     "country subpath",
+			url:      "https://tidal.com/us/album/123",
+			expected: types.Link{Kind: types.LinkKindAlbum, ID: "123"},
+		},
+		{
+			name:     "country subpath with browse prefix",
+			url:      "https://tidal.com/de/browse/track/456",
+			expected: types.Link{Kind: types.LinkKindTrack, ID: "456"},
+		},
+		{
+			name:     "share suffix",
+			url:      "https://tidal.com/browse/track/456/u",
+			expected: types.Link{Kind: types.LinkKindTrack, ID: "456"},
+		},
+		{
+			name:     "share query",
+			url:      "https://tidal.com/album/123?u",
+			expected: types.Link{Kind: types.LinkKindAlbum, ID: "123"},
+		},
+		{
+			name:     "trailing slash",
+			url:      "https://tidal.com/artist/101/",
+			expected: types.Link{Kind: types.LinkKindArtist, ID: "101"},
+		},
+		{
+			name:     "track in album path",
+			url:      "https://tidal.com/browse/album/123/track/456",
+			expected: types.Link{Kind: types.LinkKindTrack, ID: "456"},
+		},
+		{
+			name:     "track in album query",
+			url:      "https://listen.tidal.com/album/123?trackId=456",
+			expected: types.Link{Kind: types.LinkKindTrack, ID: "456"},
+		},
+		{
+			name: "tidal.link shortened",
+			url:  "https://tidal.link/abcDEF",
+			err:  types.ErrShortenedURL,
+		},
+		{
+			name: "link.tidal.com shortened",
+			url:  "https://link.tidal.com/abcDEF",
+			err:  types.ErrShortenedURL,
+		},
+		{
+			name: "unknown kind",
+			url:  "https://tidal.com/browse/genre/pop",
+			err:  types.ErrUnsupportedURL,
+		},
+		{
+			name: "missing id",
+			url:  "https://tidal.com/album",
+			err:  types.ErrUnsupportedURL,
+		},
+		{
+			name: "home page",
+			url:  "https://tidal.com/",
+			err:  types.ErrUnsupportedURL,
+		},
+		{
+			name: "http scheme",
+			url:  "http://tidal.com/album/123",
+			err:  types.ErrNotTidalURL,
+		},
+		{
+			name: "other host",
+			url:  "https://example.com/album/123",
+			err:  types.ErrNotTidalURL,
+		},
+		{
+			name: "not a URL",
+			url:  "album 123",
+			err:  types.ErrNotTidalURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			link, err := types.ParseURL(tt.url)
+			if nil != tt.err {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, link)
+		})
+	}
+}